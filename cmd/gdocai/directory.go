@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+	"github.com/gardar/ocrchestra/pkg/gdocai"
+	"github.com/gardar/ocrchestra/pkg/hocr"
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// directoryMode holds the settings of a run over a directory of PDFs
+type directoryMode struct {
+	InputDir     string // Directory to read PDFs from
+	OutputDir    string // Directory to write the OCR'ed PDFs to
+	Recursive    bool   // Also process PDFs in subdirectories, mirroring them in OutputDir
+	NameTemplate string // File name of each output PDF, may contain placeholders (defaults to the input name)
+	DetectLang   bool   // Detect missing page languages locally
+	Unique       bool   // Number outputs whose name is taken instead of replacing existing files
+	Concurrency  int    // PDFs processed at the same time (1 or less for one by one)
+	Pattern      string // -pdf glob pattern to process the matches of instead of searching InputDir (then its directory)
+
+	Exclude []string // Directories inside InputDir that are not searched, besides OutputDir
+}
+
+// directoryResult is the outcome of processing one PDF of a directory
+type directoryResult struct {
+	Input    string // Path of the input PDF, relative to the input directory
+	Output   string // Path the OCR'ed PDF was written to
+	Warnings bool   // Whether a -warn-on condition was met, e.g. because the PDF already had OCR
+	Err      error  // Why processing failed, nil on success
+
+	Conditions []string // Conditions processing ended with, for -warn-on and -fail-on
+}
+
+// writtenOutputs are the output paths of a run, claimed before they are
+// written so that concurrent PDFs don't write to the same path
+type writtenOutputs struct {
+	mu     sync.Mutex
+	inputs map[string]string // Output path -> input it is written for
+}
+
+func newWrittenOutputs() *writtenOutputs {
+	return &writtenOutputs{inputs: make(map[string]string)}
+}
+
+// claim reserves an output path for an input, first numbering it with unique
+// if it is taken. It fails if the path was already claimed for another input.
+func (w *writtenOutputs) claim(path, input string, unique bool) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if unique {
+		path = uniquePath(path, w.inputs)
+	}
+	if previous, ok := w.inputs[path]; ok {
+		return "", fmt.Errorf("output %s was already written for %s", path, previous)
+	}
+	w.inputs[path] = input
+	return path, nil
+}
+
+// release gives up a claimed path whose output could not be written
+func (w *writtenOutputs) release(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.inputs, path)
+}
+
+// run processes every PDF in the input directory, up to Concurrency at a
+// time, prints a summary and returns the exit code. A failed PDF doesn't stop
+// the others from being processed.
+func (m directoryMode) run(ctx context.Context, cfg *gdocai.Config, pdfOcrConfig pdfocr.OCRConfig) int {
+	source := m.InputDir
+	if m.Pattern != "" {
+		source = m.Pattern
+	}
+	inputs, err := m.findPDFs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to list input directory: %v\n", err)
+		return cli.ExitError
+	}
+	if len(inputs) == 0 {
+		cli.Infof("No PDF files found in %s\n", source)
+		return cli.ExitSuccess
+	}
+
+	workers := min(max(m.Concurrency, 1), len(inputs))
+	if workers > 1 {
+		cli.Infof("Processing %d PDF files from %s, %d at a time\n", len(inputs), source, workers)
+	} else {
+		cli.Infof("Processing %d PDF files from %s\n", len(inputs), source)
+	}
+
+	// Workers take the next input until all are processed; the results keep the input order
+	written := newWrittenOutputs()
+	results := make([]directoryResult, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				cli.Infof("\n[%d/%d] Processing %s\n", i+1, len(inputs), inputs[i])
+				results[i] = m.processFile(ctx, inputs[i], written, cfg, pdfOcrConfig)
+				if results[i].Err != nil {
+					fmt.Printf("Error: %s: %v\n", inputs[i], results[i].Err)
+				}
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, result := range results {
+		report.addFile(result)
+	}
+	return printDirectorySummary(results)
+}
+
+// findPDFs lists the PDFs in the input directory, relative to it and sorted.
+// The output and excluded directories are skipped when they are inside the input directory.
+// With a pattern, its matches are listed in natural order instead.
+func (m directoryMode) findPDFs() ([]string, error) {
+	if m.Pattern != "" {
+		matches, err := expandPattern(m.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		for i, match := range matches {
+			if matches[i], err = filepath.Rel(m.InputDir, match); err != nil {
+				return nil, err
+			}
+		}
+		return matches, nil
+	}
+
+	skip := make(map[string]bool)
+	for _, dir := range append([]string{m.OutputDir}, m.Exclude...) {
+		if abs, err := filepath.Abs(dir); err == nil && dir != "" {
+			skip[abs] = true
+		}
+	}
+
+	var inputs []string
+	err := filepath.WalkDir(m.InputDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == m.InputDir {
+				return nil
+			}
+			if abs, _ := filepath.Abs(path); !m.Recursive || skip[abs] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return nil
+		}
+		rel, err := filepath.Rel(m.InputDir, path)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, rel)
+		return nil
+	})
+	sort.Strings(inputs)
+	return inputs, err
+}
+
+// processFile OCRs one PDF of the input directory. Outputs already written
+// in this run are not overwritten, so placeholders that resolve to the same
+// name for different documents fail instead of losing results.
+func (m directoryMode) processFile(ctx context.Context, input string, written *writtenOutputs,
+	cfg *gdocai.Config, pdfOcrConfig pdfocr.OCRConfig) directoryResult {
+	result := directoryResult{Input: input}
+
+	// Record the warnings of this file separately from the others
+	events := cli.NewEventRecorder(conditions)
+	pdfOcrConfig.EventLogger = slog.New(events)
+	if pdfOcrConfig.Logger != nil {
+		pdfOcrConfig.Logger = cli.LibraryOutput()
+	}
+
+	// Read rather than map the file: other processes may still be writing or
+	// truncating files in a watched directory, and a mapped file that shrinks
+	// crashes the process with SIGBUS
+	data, err := os.ReadFile(filepath.Join(m.InputDir, input))
+	if err != nil {
+		result.Err = fmt.Errorf("failed to read PDF file: %w", err)
+		return result
+	}
+	pdfBytes, err := pdfocr.DecryptPDF(data, pdfOcrConfig.Password)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to decrypt PDF file: %w", err)
+		return result
+	}
+
+	hasOCR, err := detectExistingOCR(pdfBytes, pdfOcrConfig)
+	if hasOCR {
+		events.Record(pdfocr.EventOCRDetected, "PDF already has OCR")
+		report.SetHasOCR()
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	pdfBytes = preprocessPDF(pdfBytes, cfg, events)
+
+	doc, _, err := gdocai.DocumentHOCR(ctx, pdfBytes, cfg)
+	if err != nil {
+		result.Err = fmt.Errorf("error processing document: %w", err)
+		return result
+	}
+	if doc.Hocr != nil && doc.Hocr.Content != nil {
+		report.addPages(len(doc.Hocr.Content.Pages))
+	}
+	if report.addImageQuality(input, doc) {
+		events.Raise(conditionImageQuality)
+	}
+
+	if m.DetectLang && doc.Hocr != nil && doc.Hocr.Content != nil {
+		if detected := hocr.DetectLanguages(doc.Hocr.Content); detected > 0 {
+			cli.Infof("Detected language for %d page(s): %s\n", detected, doc.Hocr.Content.Metadata["ocr-langs"])
+		}
+	}
+
+	// Mirror the subdirectory of the input and name the output after the input or template
+	name := filepath.Base(input)
+	if m.NameTemplate != "" {
+		name = m.NameTemplate
+	}
+	outputPath, err := resolveOutputPath(filepath.Join(m.OutputDir, filepath.Dir(input), name), doc, ".pdf")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if outputPath, err = written.claim(outputPath, input, m.Unique); err != nil {
+		result.Err = err
+		return result
+	}
+
+	if err := writeOutputs(ctx, cfg, doc, "", pdfBytes, nil, outputPaths{PDF: outputPath}, pdfOcrConfig); err != nil {
+		written.release(outputPath)
+		result.Err = err
+		return result
+	}
+
+	result.Output = outputPath
+	result.Conditions = events.Conditions()
+	switch cli.Policy.ExitCode(result.Conditions) {
+	case cli.ExitStrictOCRFailure:
+		result.Err = fmt.Errorf("%w: %s", errFailOn, strings.Join(cli.Policy.Failed(result.Conditions), ", "))
+	case cli.ExitSuccessWithWarns:
+		result.Warnings = true
+	}
+	return result
+}
+
+// printDirectorySummary reports the outcome of every PDF of a directory run
+// and returns the exit code: an error if any PDF failed (strict mode or
+// -fail-on failures only if all failures were such), success with warnings
+// if any PDF met a -warn-on condition, and success otherwise.
+func printDirectorySummary(results []directoryResult) int {
+	var succeeded, warned, failed, strictFailed int
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed++
+			if errors.Is(result.Err, pdfocr.ErrAlreadyHasOCR) || errors.Is(result.Err, errFailOn) {
+				strictFailed++
+			}
+		case result.Warnings:
+			warned++
+		default:
+			succeeded++
+		}
+	}
+
+	cli.Infof("\nSummary: %d PDF files processed: %d succeeded, %d with warnings, %d failed\n",
+		len(results), succeeded, warned, failed)
+	for _, result := range results {
+		printDirectoryResult(result)
+	}
+
+	switch {
+	case failed > 0 && failed == strictFailed:
+		return cli.ExitStrictOCRFailure
+	case failed > 0:
+		return cli.ExitError
+	case warned > 0:
+		return cli.ExitSuccessWithWarns
+	default:
+		return cli.ExitSuccess
+	}
+}
+
+// printDirectoryResult prints the outcome of processing one PDF
+func printDirectoryResult(result directoryResult) {
+	switch {
+	case result.Err != nil:
+		cli.Infof("  FAILED   %s: %v\n", result.Input, result.Err)
+	case result.Warnings:
+		cli.Infof("  WARNING  %s -> %s\n", result.Input, result.Output)
+	default:
+		cli.Infof("  OK       %s -> %s\n", result.Input, result.Output)
+	}
+}
+
+// uniquePath returns the path, or if a file already exists there or it is
+// taken, the first free path with a numeric suffix before the extension
+// (name_2.pdf, name_3.pdf, ...). A path that can't be checked, such as one
+// in a directory without permission, is returned for writing it to fail.
+func uniquePath(path string, taken map[string]string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidate := path
+	for n := 2; ; n++ {
+		if _, ok := taken[candidate]; !ok {
+			if _, err := os.Stat(candidate); err != nil {
+				return candidate
+			}
+		}
+		candidate = fmt.Sprintf("%s_%d%s", stem, n, ext)
+	}
+}
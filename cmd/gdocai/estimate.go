@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+	"github.com/gardar/ocrchestra/pkg/gdocai"
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// estimateMode counts the pages of the inputs locally and prints what
+// processing them with Document AI would cost, without processing them
+type estimateMode struct {
+	Inputs    []string             // PDF files, or a TIFF scan with TIFF set
+	TIFF      bool                 // Whether the input is a multipage TIFF scan
+	Processor gdocai.ProcessorType // Type of the processor, which sets the price of a page
+	Batch     bool                 // Whether -batch-gcs lifts the online page limit
+	Password  string               // Password of encrypted PDFs
+	Pages     []pdfocr.PageRange   // Pages of a PDF selected with -pages, all if empty
+}
+
+// run prints the estimate of every input and their total, recording inputs
+// over the online page limit that can't be split into chunks as warnings
+func (m estimateMode) run(ctx context.Context, cfg *gdocai.Config, events *cli.EventRecorder) error {
+	var total gdocai.Estimate
+	for _, input := range m.Inputs {
+		estimate, err := m.estimate(ctx, cfg, input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+		report.Inputs = append(report.Inputs, input)
+		cli.Infof("%s: %d page(s), $%.4f\n", input, estimate.Pages, estimate.Cost)
+		switch {
+		case !estimate.OverLimit || m.Batch:
+		case m.TIFF:
+			message := fmt.Sprintf("%s has more than the %d pages of online processing; use -batch-gcs", input, gdocai.SyncPageLimit)
+			cli.Warnf("Warning: %s\n", message)
+			events.Record(pdfocr.EventWarning, message)
+		case len(m.Pages) == 0:
+			// PDFs are split into chunks of SyncPageLimit pages
+			cli.Infof("  processed in %d requests of up to %d pages\n",
+				(estimate.Pages+gdocai.SyncPageLimit-1)/gdocai.SyncPageLimit, gdocai.SyncPageLimit)
+		default:
+			message := fmt.Sprintf("%s has more than the %d pages of online processing selected; use -batch-gcs", input, gdocai.SyncPageLimit)
+			cli.Warnf("Warning: %s\n", message)
+			events.Record(pdfocr.EventWarning, message)
+		}
+		total.Pages += estimate.Pages
+		total.Cost += estimate.Cost
+		total.PricePer1000 = estimate.PricePer1000
+	}
+
+	report.Pages = total.Pages
+	report.EstimatedCost = total.Cost
+	cli.Infof("\nEstimated cost of %d page(s) in %d file(s): $%.2f (%s processor at $%.2f per 1,000 pages)\n",
+		total.Pages, len(m.Inputs), total.Cost, m.Processor, total.PricePer1000)
+	return nil
+}
+
+// estimate counts the pages of one input and estimates processing it
+func (m estimateMode) estimate(ctx context.Context, cfg *gdocai.Config, input string) (gdocai.Estimate, error) {
+	data, err := readFile(ctx, cfg, input)
+	if err != nil {
+		return gdocai.Estimate{}, err
+	}
+	if m.TIFF {
+		pages, err := pdfocr.SplitTIFF(data)
+		if err != nil {
+			return gdocai.Estimate{}, err
+		}
+		return gdocai.EstimatePages(len(pages), m.Processor)
+	}
+	if data, err = pdfocr.DecryptPDF(data, m.Password); err != nil {
+		return gdocai.Estimate{}, err
+	}
+	if len(m.Pages) > 0 {
+		pages, err := selectPages(data, m.Pages)
+		if err != nil {
+			return gdocai.Estimate{}, err
+		}
+		return gdocai.EstimatePages(len(pages), m.Processor)
+	}
+	return gdocai.EstimatePDF(data, m.Processor)
+}
+
+// estimateInputs lists the files -estimate counts the pages of for the
+// input flags: the PDFs of a directory or -pdf pattern, the -pdfs list, or
+// the -pdf or -tiff file
+func estimateInputs(dir directoryMode, pdfPath, pdfPaths, tiffPath string) ([]string, error) {
+	switch {
+	case dir.InputDir != "":
+		inputs, err := dir.findPDFs()
+		for i, input := range inputs {
+			inputs[i] = filepath.Join(dir.InputDir, input)
+		}
+		return inputs, err
+	case pdfPaths != "":
+		return expandPDFList(pdfPaths)
+	case tiffPath != "":
+		return []string{tiffPath}, nil
+	default:
+		return []string{pdfPath}, nil
+	}
+}
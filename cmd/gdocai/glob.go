@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// isPattern reports whether an input path is a glob pattern such as
+// scans/2024-*.pdf. Object URIs are never patterns.
+func isPattern(path string) bool {
+	return !isObjectURI(path) && strings.ContainsAny(path, "*?[")
+}
+
+// expandPattern returns the files matching a glob pattern in natural order,
+// so page2.pdf comes before page10.pdf. It fails if no file matches.
+func expandPattern(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	files := matches[:0]
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+			files = append(files, match)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %q", pattern)
+	}
+	slices.SortStableFunc(files, naturalCompare)
+	return files, nil
+}
+
+// expandPDFList splits the comma separated -pdfs list, replacing glob
+// patterns with their matches in natural order
+func expandPDFList(list string) ([]string, error) {
+	var paths []string
+	for _, path := range strings.Split(list, ",") {
+		if path = strings.TrimSpace(path); !isPattern(path) {
+			if path != "" {
+				paths = append(paths, path)
+			}
+			continue
+		}
+		matches, err := expandPattern(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// patternDir returns the directory of a glob pattern up to its first
+// wildcard, which the matches are relative to in the output directory
+func patternDir(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for isPattern(dir) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// naturalCompare compares two strings with runs of digits compared by their
+// numeric value, e.g. scan9 before scan10
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, restA := digitRun(a)
+			nb, restB := digitRun(b)
+			// Compare the numbers without leading zeros by length, then digit by digit
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if c := len(ta) - len(tb); c != 0 {
+				return c
+			}
+			if c := strings.Compare(ta, tb); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+// digitRun splits a string into its leading digits and the rest
+func digitRun(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
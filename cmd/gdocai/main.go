@@ -13,12 +13,38 @@
 //	project_id: "your-gcp-project-id"
 //	location: "us"
 //	processor_id: "your-processor-id"
+//	processor_version: "stable" # optional version ID or alias ("stable", "rc")
+//	endpoint: "eu-documentai.googleapis.com:443" # optional API endpoint override
+//	max_qps: 2                  # optional limit of Document AI requests per second
+//	max_concurrent: 4           # optional limit of Document AI requests in flight
+//	credentials_file: "key.json" # optional credential JSON file
+//	impersonate_service_account: "ocr@your-gcp-project-id.iam.gserviceaccount.com" # optional service account to act as
+//	preprocess: "deskew,contrast" # optional cleanup of scanned pages before OCR
+//	chunk_size: 500             # optional chunk size in tokens for a Layout Parser processor
+//	image_quality_scores: true  # optional image quality scores per page (Enterprise Document OCR)
+//	language_hints: ["en", "de"] # optional languages of the documents, to improve recognition
+//	advanced_ocr_options: ["legacy_layout"] # optional advanced options of the OCR processor
+//	symbol_boxes: true          # optional character boxes in the hOCR (ocrx_cinfo)
+//	cache_dir: ".gdocai-cache"  # optional directory caching Document AI responses
 //
 // Environment Variables:
 //
 //	GDOCAI_PROJECT_ID: Your GCP project ID
 //	GDOCAI_LOCATION: Document AI API location (e.g., "us")
 //	GDOCAI_PROCESSOR_ID: Your Document AI processor ID
+//	GDOCAI_PROCESSOR_VERSION: Optional processor version ID or alias
+//	GDOCAI_ENDPOINT: Optional Document AI API endpoint (host:port)
+//	GDOCAI_MAX_QPS: Optional limit of Document AI requests per second
+//	GDOCAI_MAX_CONCURRENT: Optional limit of Document AI requests in flight
+//	GDOCAI_CREDENTIALS_JSON: Optional credential JSON content, e.g. from a secret
+//	GDOCAI_IMPERSONATE_SERVICE_ACCOUNT: Optional email of a service account to impersonate
+//	GDOCAI_PREPROCESS: Optional cleanup steps for scanned pages, e.g. "deskew,binarize"
+//	GDOCAI_CHUNK_SIZE: Optional chunk size in tokens for a Layout Parser processor
+//	GDOCAI_IMAGE_QUALITY_SCORES: Optional "true" to score the image quality of each page
+//	GDOCAI_LANGUAGE_HINTS: Optional comma separated languages of the documents, e.g. "en,de"
+//	GDOCAI_ADVANCED_OCR_OPTIONS: Optional comma separated advanced options of the OCR processor
+//	GDOCAI_SYMBOL_BOXES: Optional "true" to add character boxes to the hOCR
+//	GDOCAI_CACHE_DIR: Optional directory caching Document AI responses
 //
 // If both config file and environment variables are provided, values from the config file take precedence.
 //
@@ -39,28 +65,100 @@
 //	-pdf string     Path to the input PDF file (required if -pdfs is not defined)
 //	-pdfs string    Comma separated list of input PDF files to process as a single document (required if -pdf is not defined)
 //
+// -pdf and -pdfs take glob patterns too, such as 'scans/2024-*.pdf', expanded in
+// natural order. Several matches of a -pdf pattern are processed one by one
+// like -input-dir, which requires -output-dir.
+//	-tiff string    Path to a multipage TIFF scan to process instead of a PDF; the output PDF is built from its pages
+//	-pages string   Process only these pages of -pdf with Document AI, e.g. "1-3,7,9-", to pay for just the pages
+//	                that need OCR; the output PDF keeps the other pages as they are
+//	-no-page-images Ask Document AI to leave the page images out of its response, which is then far smaller;
+//	                the OCR text of -pdf, -tiff and -input-dir is applied without them, but -images and
+//	                the output PDF of -pdfs need them
+//
+// Directory mode (instead of -pdf or -pdfs):
+//
+//	-input-dir string   Directory of PDF files to process one by one
+//	-output-dir string  Directory to save the OCR'ed PDFs to, named by -output (a file name template) if given
+//	-recursive          Also process subdirectories, mirroring them in -output-dir
+//	-concurrency int    Number of PDF files to process at the same time (default 1)
+//
+// Watch mode (with -input-dir and -output-dir):
+//
+//	-watch                    Keep processing PDF files as they appear in -input-dir, until interrupted
+//	-watch-interval duration  How often -input-dir is scanned (default 5s)
+//	-failed-dir string        Directory failed PDF files are moved to (default <output-dir>/failed)
+//	-archive-dir string       Directory processed PDF files are moved to (default: delete them)
+//
+// Server mode:
+//
+//	gdocai serve -config config.yml [-addr :8080] [-max-upload-mb 50] [-strict] [-force]
+//
+//	Runs an HTTP service until interrupted. POST a PDF to /v1/ocr, as the request body or a
+//	multipart "file" field, to get a JSON response with the OCR'ed PDF (base64 encoded), the
+//	page count, the text, the form fields, the custom extractor fields and any warnings.
+//	GET /healthz returns 200 when the server is up. In strict mode PDFs that already have OCR
+//	are rejected with 409 Conflict, and Document AI failures are reported with 502 Bad Gateway.
+//
 // Output options (at least one required):
 //
 //	-text string             Path to save OCR text output
 //	-hocr string             Path to save HOCR output
+//	-tsv string              Path to save Tesseract-style TSV word data
+//	-words-jsonl string      Path to save word data as JSON Lines (one word per line)
 //	-form-fields string      Path to save form fields JSON
 //	-extractor-fields string Path to save custom extractor fields JSON
+//	-normalized-fields string Path to save custom extractor fields JSON with values normalized by Document AI
+//	-fields-csv string       Path to save form and custom extractor fields as CSV (source, field, value)
+//	-fields-xlsx string      Path to save form and custom extractor fields as an Excel workbook
+//	-field-details string    Path to save every field with its confidence, page and bounding box as JSON
+//	-markdown string         Path to save the document as Markdown (paragraphs, tables, form fields)
+//	-chunks string           Path to save the chunks of a Layout Parser processor as JSON Lines
 //	-images string           Directory to save page images
+//	-tables string           Directory to save tables as CSV and JSON
 //	-output string           Path to save the PDF with OCR applied
+//	-metadata key=value      Set the title, author, subject, keywords, creator or producer of the output PDF
+//	                         (repeatable); the metadata of the input PDF is kept otherwise
+//	-xmp property=value      Set an XMP property of the output PDF, as prefix:name=value (dc, pdf, xmp, ...)
+//	                         or {namespace-uri}prefix:name=value (repeatable)
+//	-keep-annotations        Copy the links, highlights and comments of the input PDF to the output PDF
+//	-password string         Password to open encrypted input PDFs; the output PDF is not encrypted
+//	-image-dpi float         Downsample the page images of a -pdfs or -tiff output PDF to this resolution
+//	-image-source-dpi float  Resolution assumed for page images that don't state one (default 300)
+//	-image-quality int       Re-encode the page images of a -pdfs or -tiff output PDF as JPEG with this quality (1-100)
+//	-compress-bitonal        Embed black-and-white page images of a -pdfs or -tiff output PDF as CCITT Group 4
 //
-// Field placeholder support in output path:
+// Object storage:
 //
-//	The -output flag supports placeholders that use extracted field values from the document.
+//	-pdf, -pdfs, -tiff and all output flags accept gs://bucket/object URIs as well as local paths, so
+//	documents can be read from and written to Cloud Storage directly. For -images and -tables a
+//	gs://bucket/prefix URI is used as the directory. Cloud Storage is accessed with the same
+//	credentials as Document AI. Directory and watch mode work on local directories only.
+//
+//	s3://bucket/key URIs are supported the same way, with the standard AWS credential resolution
+//	(AWS_ACCESS_KEY_ID, AWS_PROFILE, shared config files, instance roles, ...). Set
+//	AWS_ENDPOINT_URL_S3 to use an S3-compatible service such as MinIO.
+//
+// Field placeholder support in output paths:
+//
+//	The -output flag and the other output flags (-text, -hocr, -form-fields, -images, ...)
+//	support placeholders that use extracted field values from the document in their file
+//	or directory name.
 //	Format:
 //	  @{field_name} - Use the value of field_name
 //	  @{field_name:default_value} - Set default value if field is not detected
 //	  @{field_name} or @{field_name:default_value} - Auto-detect source
 //	  @{form_field.field_name} - Explicitly use form fields
 //	  @{extractor_field.field_name} - Explicitly use custom extractor fields
+//	  @{text_match.name} - First match of the pattern named with -text-match name=regex in the
+//	    document text (its first group if it has one), for documents without fields
+//	  @{field_name:default_value|modifier|...} - Transform the value with upper, lower, title,
+//	    trim, dateformat:<Go layout> or regex:<pattern> (first match, or its first group)
 //
 //	Examples:
 //	  -output "invoice-@{invoice_number:unknown}-@{date}.pdf"
 //	  -output "client-@{form_field.client_name}-@{extractor_field.document_id}.pdf"
+//	  -output "@{client|upper}-@{date|dateformat:2006-01-02}.pdf" -text "@{client|upper}-@{date|dateformat:2006-01-02}.txt"
+//	  -text-match 'ref=Our ref: *(\S+)' -output "letter-@{text_match.ref:unknown}.pdf"
 //
 //	Field Resolution Order:
 //	  1. If a field exists in both sources, a warning is shown and form fields take precedence
@@ -69,6 +167,9 @@
 //
 //	Nested fields can be accessed with dot notation: @{address.city}
 //
+//	Custom extractor fields use the value normalized by Document AI when it has
+//	one, so dates come out as ISO 8601 (2024-03-31) rather than as printed.
+//
 //	Filename Sanitization:
 //	  All extracted field values used in output filenames are automatically sanitized to ensure
 //	  they're compatible with filesystems. This includes:
@@ -81,19 +182,76 @@
 //	    - Replacing control characters
 //	    - Providing a default name if empty after sanitization
 //
+// Language options:
+//
+//	-lang string          Comma separated languages of the document, e.g. "en,de", passed to Document AI as
+//	                      hints to improve recognition (overrides the config)
+//	-detect-lang          Detect missing page languages locally and fill in the hOCR language tags
+//
+// Preprocessing:
+//
+//	-preprocess string    Clean up scanned pages before OCR with these comma separated steps (overrides the config):
+//	                      deskew, rotate=90|180|270, contrast, binarize, despeckle. The searchable PDF is built
+//	                      from the cleaned up pages. PDFs with text or vector content are sent unchanged.
+//
+// PDF options:
+//
+//	-encoding-fallback string  How to render words the OCR font can't encode: transliterate, replace or skip (default "transliterate")
+//	-unicode-font string       TrueType font embedded for pages with text outside Windows-1252 (CJK, Arabic, Cyrillic, ...)
+//	-script-font script=path   Draw words of a script (latin, cyrillic, cjk or arabic) with this TrueType font where
+//	                           it has their glyphs (repeatable; fonts listed first are preferred)
+//	-no-layers                 Draw the invisible OCR text straight into the pages instead of onto optional content layers
+//	-fit-height                Size each word's font to the height of its box and scale the glyphs to its width
+//	-min-font-size float       Smallest font size in points -fit-height may choose (0 for no limit)
+//	-max-font-size float       Largest font size in points -fit-height may choose (0 for no limit)
+//
 // OCR Detection:
 //
 //	-strict               Exit with error code 3 if OCR is already detected in the PDF
 //
+// Cost estimation:
+//
+//	-estimate               Count the pages of the inputs locally and print the estimated Document AI
+//	                        cost, flagging documents over the online page limit, instead of processing them
+//	-processor-type string  Type of the processor, which sets the price of a page: ocr, form, layout or
+//	                        custom-extractor (default "ocr")
+//
+// Response cache:
+//
+//	-cache-dir string  Directory caching the Document AI responses by the SHA-256 of the document, processor
+//	                   version and options, so unchanged documents aren't processed and billed again (overrides the config)
+//	-no-cache          Process every document with Document AI, ignoring the configured cache
+//
 // Debug options:
 //
-//	-debug-api string   Path to save raw API response as JSON
-//	-debug-doc string   Path to save transformed Document object as JSON
+//	-debug-api string      Path to save raw API response as JSON
+//	-debug-doc string      Path to save transformed Document object as JSON
+//	-from-api-json string  Build the outputs from an API response saved with -debug-api instead of processing
+//	                       the input with Document AI, e.g. to reproduce a bug or rebuild the outputs with a newer
+//	                       version. With -pdf the OCR text is applied to that PDF, with -tiff to its pages; without
+//	                       an input the output PDF is assembled from the page images of the response.
+//
+// Reporting:
+//
+//	-json string        Write a JSON report of the run to this file, or - for standard output
+//	-warn-on string     Conditions that exit with code 2, comma separated or none (default "existing-ocr,warning"):
+//	                    existing-ocr, warning, encoding-fallback, image-quality
+//	-fail-on string     Conditions that exit with code 3 although the outputs were written (same conditions)
+//
+// Output:
+//
+//	-quiet              Print errors only, for cron jobs and scripts (same as -log-level error)
+//	-verbose            Print debug details as well (same as -log-level debug)
+//	-log-level string   How much to print: error, warn, info or debug (default info; overrides -quiet and -verbose)
 //
 // Authentication:
 //
-// The tool uses the GOOGLE_APPLICATION_CREDENTIALS environment variable
-// for authentication with Google Cloud.
+// The tool uses Application Default Credentials for authentication with Google Cloud:
+// the file named by the GOOGLE_APPLICATION_CREDENTIALS environment variable, the gcloud
+// user credentials, or the attached service account on GCE, Cloud Run and GKE (including
+// workload identity). credentials_file or GDOCAI_CREDENTIALS_JSON provide credentials
+// explicitly, and impersonate_service_account or GDOCAI_IMPERSONATE_SERVICE_ACCOUNT act
+// as another service account using them.
 //
 // Example:
 //
@@ -102,6 +260,8 @@
 //	gdocai -config config.yml -pdf invoice.pdf -output "invoice-@{number:unknown}-@{client}.pdf"
 //	gdocai -config config.yml -pdfs page1.pdf,page2.pdf,page3.pdf -output combo_document_ocr.pdf
 //	gdocai -config config.yml -pdf form.pdf -form-fields fields.json -extractor-fields entities.json
+//	gdocai -config config.yml -input-dir ./inbox -output-dir ./searchable -recursive
+//	gdocai -config config.yml -input-dir ./scans -output-dir ./searchable -watch -archive-dir ./originals
 //
 // Using environment variables instead of config file:
 //
@@ -113,139 +273,200 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/anyascii/go"
 	"gopkg.in/yaml.v3"
 
+	"github.com/gardar/ocrchestra/internal/cli"
 	"github.com/gardar/ocrchestra/pkg/gdocai"
+	"github.com/gardar/ocrchestra/pkg/hocr"
 	"github.com/gardar/ocrchestra/pkg/pdfocr"
-)
-
-const (
-	ExitCodeSuccess          = 0 // Normal successful execution
-	ExitCodeError            = 1 // General error
-	ExitCodeSuccessWithWarns = 2 // Success but with warnings (including OCR already detected)
-	ExitCodeStrictOCRFailure = 3 // OCR already present in strict mode
+	"github.com/gardar/ocrchestra/pkg/preprocess"
 )
 
 type yamlConfig struct {
 	ProjectID   string `yaml:"project_id"`
 	Location    string `yaml:"location"`
 	ProcessorID string `yaml:"processor_id"`
-}
 
-// warningWriter captures warnings written to the logger
-type warningWriter struct {
-	buf    bytes.Buffer
-	target io.Writer // Usually os.Stdout
-}
+	ProcessorVersion string `yaml:"processor_version"`
+	Endpoint         string `yaml:"endpoint"`
 
-func newWarningWriter(target io.Writer) *warningWriter {
-	return &warningWriter{
-		target: target,
-	}
-}
+	MaxQPS        float64 `yaml:"max_qps"`
+	MaxConcurrent int     `yaml:"max_concurrent"`
 
-func (w *warningWriter) Write(p []byte) (n int, err error) {
-	// Write to both the buffer (for tracking) and the target (for display)
-	w.buf.Write(p)
-	return w.target.Write(p)
-}
+	CredentialsFile           string `yaml:"credentials_file"`
+	ImpersonateServiceAccount string `yaml:"impersonate_service_account"`
 
-// HasWarnings checks if any warnings were logged
-func (w *warningWriter) HasWarnings() bool {
-	return strings.Contains(w.buf.String(), "Warning:")
-}
+	Preprocess string `yaml:"preprocess"`
+	ChunkSize  int    `yaml:"chunk_size"`
 
-// HasOCRWarning specifically checks if OCR already exists warning was logged
-func (w *warningWriter) HasOCRWarning() bool {
-	return strings.Contains(w.buf.String(), "already has OCR")
+	ImageQualityScores bool     `yaml:"image_quality_scores"`
+	LanguageHints      []string `yaml:"language_hints"`
+	AdvancedOCROptions []string `yaml:"advanced_ocr_options"`
+	SymbolBoxes        bool     `yaml:"symbol_boxes"`
+
+	CacheDir string `yaml:"cache_dir"`
 }
 
 // PlaceholderData holds data available for placeholder substitution
 type PlaceholderData struct {
 	FormFields            map[string]interface{}
 	CustomExtractorFields map[string]interface{}
+	Text                  string                    // Document text the text matches are searched in
+	TextMatches           map[string]*regexp.Regexp // Named patterns of the text_match placeholders
 }
 
+// textMatches are the named patterns set with -text-match
+var textMatches = make(map[string]*regexp.Regexp)
+
 // processPlaceholders takes a string with placeholders in the format:
 // "@{field_name}" or "@{field_name:default_value}" - Uses prioritization rules
 // "@{form_field.field_name}" - Explicitly use form fields
 // "@{extractor_field.field_name}" - Explicitly use custom extractor fields
+// "@{text_match.name}" - Use the first match of a named pattern in the document text
+// "@{field_name|modifier|...}" - Transform the value, see applyModifier
 //
 // It searches for values according to the specified source or using the
-// prioritization rules, and if not found, uses the provided default value.
+// prioritization rules, transforms them with the modifiers, and if the value
+// is not found or ends up empty, uses the provided default value.
 func processPlaceholders(inputStr string, data *PlaceholderData) (string, error) {
-	// Regular expression to match placeholder patterns with optional source prefix and default value
-	re := regexp.MustCompile(`@\{(?:(form_field|extractor_field)\.)?([^:}]+)(?::([^}]*))?\}`)
-
-	result := re.ReplaceAllStringFunc(inputStr, func(match string) string {
-		// Extract source, field name and default value from the match
-		submatches := re.FindStringSubmatch(match)
+	return replacePlaceholders(inputStr, func(placeholder string) (string, bool, error) {
+		parts := splitModifiers(placeholder)
+		field, defaultValue, _ := strings.Cut(parts[0], ":")
 
+		// Extract the source from the field name
 		source := ""
-		fieldName := ""
-		defaultValue := ""
-
-		if len(submatches) > 1 {
-			source = submatches[1] // This will be "form_field", "extractor_field", or "" (for auto)
+		for _, prefix := range []string{"form_field", "extractor_field", "text_match"} {
+			if name, ok := strings.CutPrefix(field, prefix+"."); ok {
+				source, field = prefix, name
+				break
+			}
 		}
-		if len(submatches) > 2 {
-			fieldName = strings.TrimSpace(submatches[2])
+		fieldName := strings.TrimSpace(field)
+		if fieldName == "" {
+			return "", false, nil
 		}
-		if len(submatches) > 3 && submatches[3] != "" {
-			defaultValue = submatches[3]
+		if _, ok := data.TextMatches[fieldName]; source == "text_match" && !ok {
+			return "", false, fmt.Errorf("@{%s}: no -text-match pattern named %q", placeholder, fieldName)
 		}
 
-		// If explicit source is specified, only check that source
-		if source == "form_field" {
-			if value := lookupFieldValue(fieldName, data.FormFields); value != "" {
-				return value
-			}
-			return defaultValue
-		} else if source == "extractor_field" {
-			if value := lookupFieldValue(fieldName, data.CustomExtractorFields); value != "" {
-				return value
+		value := lookupPlaceholder(source, fieldName, data)
+		for _, modifier := range parts[1:] {
+			var err error
+			if value, err = applyModifier(value, modifier); err != nil {
+				return "", false, fmt.Errorf("@{%s}: %w", placeholder, err)
 			}
-			return defaultValue
 		}
+		if value == "" {
+			return defaultValue, true, nil
+		}
+		return value, true, nil
+	})
+}
 
-		// No explicit source, use prioritization rules:
-		// 1. Check if exists in both - if so, log a warning and use form fields
-		formValue := lookupFieldValue(fieldName, data.FormFields)
-		customValue := lookupFieldValue(fieldName, data.CustomExtractorFields)
+// lookupPlaceholder returns the value of a placeholder field from the source,
+// or if there is none, using the prioritization rules
+func lookupPlaceholder(source, fieldName string, data *PlaceholderData) string {
+	// If explicit source is specified, only check that source
+	if source == "form_field" {
+		return lookupFieldValue(fieldName, data.FormFields)
+	} else if source == "extractor_field" {
+		return lookupFieldValue(fieldName, data.CustomExtractorFields)
+	} else if source == "text_match" {
+		return lookupTextMatch(data.TextMatches[fieldName], data.Text)
+	}
 
-		if formValue != "" && customValue != "" {
-			fmt.Printf("Warning: Field '%s' found in both form fields and custom extractor fields. Using form field value.\n", fieldName)
-			return formValue
-		}
+	// No explicit source, use prioritization rules:
+	// 1. Check if exists in both - if so, log a warning and use form fields
+	formValue := lookupFieldValue(fieldName, data.FormFields)
+	customValue := lookupFieldValue(fieldName, data.CustomExtractorFields)
+
+	if formValue != "" && customValue != "" {
+		cli.Warnf("Warning: Field '%s' found in both form fields and custom extractor fields. Using form field value.\n", fieldName)
+		return formValue
+	}
 
-		// 2. Check form fields first
-		if formValue != "" {
-			return formValue
+	// 2. Check form fields first
+	if formValue != "" {
+		return formValue
+	}
+
+	// 3. Check custom extractor fields, empty if still not found
+	return customValue
+}
+
+// replacePlaceholders calls replace with the contents of every "@{...}"
+// placeholder in s and substitutes the result. Placeholders replace declines
+// and unterminated ones are kept as they are.
+func replacePlaceholders(s string, replace func(placeholder string) (string, bool, error)) (string, error) {
+	var result strings.Builder
+	for {
+		start := strings.Index(s, "@{")
+		if start < 0 {
+			break
 		}
+		end := placeholderEnd(s[start+2:])
+		if end < 0 {
+			break
+		}
+		end += start + 2
 
-		// 3. Check custom extractor fields
-		if customValue != "" {
-			return customValue
+		value, ok, err := replace(s[start+2 : end])
+		if err != nil {
+			return "", err
 		}
+		if !ok {
+			value = s[start : end+1]
+		}
+		result.WriteString(s[:start])
+		result.WriteString(value)
+		s = s[end+1:]
+	}
+	result.WriteString(s)
+	return result.String(), nil
+}
 
-		// 4. If still not found, use default value
-		return defaultValue
+// placeholdersSetCase reports whether a placeholder of s sets the case of
+// its value with a modifier such as upper
+func placeholdersSetCase(s string) bool {
+	setsCase := false
+	replacePlaceholders(s, func(placeholder string) (string, bool, error) {
+		for _, modifier := range splitModifiers(placeholder)[1:] {
+			name, _, _ := strings.Cut(modifier, ":")
+			setsCase = setsCase || caseModifiers[strings.TrimSpace(name)]
+		}
+		return "", false, nil
 	})
+	return setsCase
+}
 
-	return result, nil
+// lookupTextMatch returns the first match of a pattern in the document text,
+// or its first group if it has one, without surrounding spaces
+func lookupTextMatch(pattern *regexp.Regexp, text string) string {
+	match := pattern.FindStringSubmatch(text)
+	switch {
+	case match == nil:
+		return ""
+	case len(match) > 1:
+		return strings.TrimSpace(match[1])
+	default:
+		return strings.TrimSpace(match[0])
+	}
 }
 
 // lookupFieldValue attempts to find a field value in a map, potentially
@@ -297,9 +518,9 @@ func lookupFieldValue(fieldPath string, data map[string]interface{}) string {
 }
 
 // sanitizeFilename ensures a string can be safely used as a filename
-// by transliterating Unicode characters to ASCII, enforcing lowercase,
-// removing path traversal components, and replacing invalid characters
-func sanitizeFilename(filename string) string {
+// by transliterating Unicode characters to ASCII, enforcing lowercase unless
+// keepCase is set, removing path traversal components, and replacing invalid characters
+func sanitizeFilename(filename string, keepCase bool) string {
 	// If filename is empty, return a default name
 	if strings.TrimSpace(filename) == "" {
 		return "unnamed"
@@ -309,7 +530,9 @@ func sanitizeFilename(filename string) string {
 	filename = anyascii.Transliterate(filename)
 
 	// Convert to lowercase
-	filename = strings.ToLower(filename)
+	if !keepCase {
+		filename = strings.ToLower(filename)
+	}
 
 	// First remove any path traversal components
 	// This is explicit even though we also handle slashes in the next step
@@ -343,7 +566,7 @@ func sanitizeFilename(filename string) string {
 	baseName := strings.TrimSuffix(sanitized, ext)
 
 	// Check if base is a reserved name
-	if reservedNames[baseName] {
+	if reservedNames[strings.ToLower(baseName)] {
 		baseName = "_" + baseName
 		sanitized = baseName + ext
 	}
@@ -389,7 +612,7 @@ func safelyLogPath(originalPath, processedPath string) {
 	}
 
 	// Log the path transformation
-	fmt.Printf("Placeholders in output path processed: %s -> %s\n", displayOriginal, displayProcessed)
+	cli.Infof("Placeholders in output path processed: %s -> %s\n", displayOriginal, displayProcessed)
 }
 
 // loadConfig reads configuration from a YAML file and/or environment variables
@@ -400,6 +623,55 @@ func loadConfig(path string) (*gdocai.Config, error) {
 		ProjectID:   os.Getenv("GDOCAI_PROJECT_ID"),
 		Location:    os.Getenv("GDOCAI_LOCATION"),
 		ProcessorID: os.Getenv("GDOCAI_PROCESSOR_ID"),
+
+		ProcessorVersion: os.Getenv("GDOCAI_PROCESSOR_VERSION"),
+		Endpoint:         os.Getenv("GDOCAI_ENDPOINT"),
+
+		CredentialsJSON:           []byte(os.Getenv("GDOCAI_CREDENTIALS_JSON")),
+		ImpersonateServiceAccount: os.Getenv("GDOCAI_IMPERSONATE_SERVICE_ACCOUNT"),
+	}
+	preprocessSpec := os.Getenv("GDOCAI_PREPROCESS")
+	cacheDir := os.Getenv("GDOCAI_CACHE_DIR")
+	if value := os.Getenv("GDOCAI_MAX_QPS"); value != "" {
+		qps, err := strconv.ParseFloat(value, 64)
+		if err != nil || qps < 0 {
+			return nil, fmt.Errorf("invalid GDOCAI_MAX_QPS %q: must be a non-negative number", value)
+		}
+		config.MaxQPS = qps
+	}
+	if value := os.Getenv("GDOCAI_CHUNK_SIZE"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GDOCAI_CHUNK_SIZE %q: must be a non-negative integer", value)
+		}
+		config.ChunkSize = n
+	}
+	if value := os.Getenv("GDOCAI_IMAGE_QUALITY_SCORES"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GDOCAI_IMAGE_QUALITY_SCORES %q: must be true or false", value)
+		}
+		config.ImageQualityScores = enabled
+	}
+	if value := os.Getenv("GDOCAI_SYMBOL_BOXES"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GDOCAI_SYMBOL_BOXES %q: must be true or false", value)
+		}
+		config.SymbolBoxes = enabled
+	}
+	if value := os.Getenv("GDOCAI_LANGUAGE_HINTS"); value != "" {
+		config.LanguageHints = splitList(value)
+	}
+	if value := os.Getenv("GDOCAI_ADVANCED_OCR_OPTIONS"); value != "" {
+		config.AdvancedOCROptions = splitList(value)
+	}
+	if value := os.Getenv("GDOCAI_MAX_CONCURRENT"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GDOCAI_MAX_CONCURRENT %q: must be a non-negative integer", value)
+		}
+		config.MaxConcurrent = n
 	}
 
 	// If a config file path is provided, load and use it (overriding env vars)
@@ -423,344 +695,466 @@ func loadConfig(path string) (*gdocai.Config, error) {
 		if yc.ProcessorID != "" {
 			config.ProcessorID = yc.ProcessorID
 		}
+		if yc.ProcessorVersion != "" {
+			config.ProcessorVersion = yc.ProcessorVersion
+		}
+		if yc.Endpoint != "" {
+			config.Endpoint = yc.Endpoint
+		}
+		if yc.MaxQPS != 0 {
+			config.MaxQPS = yc.MaxQPS
+		}
+		if yc.MaxConcurrent != 0 {
+			config.MaxConcurrent = yc.MaxConcurrent
+		}
+		if yc.CredentialsFile != "" {
+			config.CredentialsFile = yc.CredentialsFile
+			config.CredentialsJSON = nil
+		}
+		if yc.ImpersonateServiceAccount != "" {
+			config.ImpersonateServiceAccount = yc.ImpersonateServiceAccount
+		}
+		if yc.Preprocess != "" {
+			preprocessSpec = yc.Preprocess
+		}
+		if yc.ChunkSize != 0 {
+			config.ChunkSize = yc.ChunkSize
+		}
+		if yc.ImageQualityScores {
+			config.ImageQualityScores = true
+		}
+		if yc.SymbolBoxes {
+			config.SymbolBoxes = true
+		}
+		if len(yc.LanguageHints) > 0 {
+			config.LanguageHints = yc.LanguageHints
+		}
+		if len(yc.AdvancedOCROptions) > 0 {
+			config.AdvancedOCROptions = yc.AdvancedOCROptions
+		}
+		if yc.CacheDir != "" {
+			cacheDir = yc.CacheDir
+		}
+	}
+	if cacheDir != "" {
+		config.Cache = gdocai.DirCache{Dir: cacheDir}
+	}
+	pipeline, err := preprocess.ParsePipeline(preprocessSpec)
+	if err != nil {
+		return nil, err
+	}
+	config.Preprocess = pipeline
+	if config.MaxQPS < 0 || config.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("max_qps and max_concurrent must not be negative")
 	}
+	if config.ChunkSize < 0 {
+		return nil, fmt.Errorf("chunk_size must not be negative")
+	}
+
+	// Log the Document AI requests, including when they are throttled, if limits are set
+	if config.MaxQPS > 0 || config.MaxConcurrent > 0 {
+		config.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cli.OutputLevel.SlogLevel()}))
+	}
+
+	return config, nil
+}
 
-	// Ensure we have the required configuration values
+// requireProcessor ensures the config names the Document AI processor, which
+// everything but offline runs from a saved API response needs
+func requireProcessor(config *gdocai.Config) error {
 	if config.ProjectID == "" {
-		return nil, fmt.Errorf("project_id not provided in config file or GDOCAI_PROJECT_ID environment variable")
+		return fmt.Errorf("project_id not provided in config file or GDOCAI_PROJECT_ID environment variable")
 	}
 	if config.Location == "" {
-		return nil, fmt.Errorf("location not provided in config file or GDOCAI_LOCATION environment variable")
+		return fmt.Errorf("location not provided in config file or GDOCAI_LOCATION environment variable")
 	}
 	if config.ProcessorID == "" {
-		return nil, fmt.Errorf("processor_id not provided in config file or GDOCAI_PROCESSOR_ID environment variable")
+		return fmt.Errorf("processor_id not provided in config file or GDOCAI_PROCESSOR_ID environment variable")
 	}
+	return nil
+}
 
-	return config, nil
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
 }
 
 // checkPDFForOCR checks if a PDF already has OCR
 // Exits if in strict mode and OCR is found
 // Returns true if OCR detected (for reporting)
 func checkPDFForOCR(pdfBytes []byte, config pdfocr.OCRConfig) bool {
-	ocrResult, err := pdfocr.DetectOCR(pdfBytes, config)
+	hasOCR, err := detectExistingOCR(pdfBytes, config)
 	if err != nil {
-		fmt.Printf("Warning: OCR detection failed: %v\n", err)
-		return false
+		failStrict(err)
 	}
+	return hasOCR
+}
 
-	if ocrResult.HasOCR {
-		fmt.Printf("Warning: Document already has OCR\n")
+// preprocessPDF cleans up a scanned PDF with the configured preprocessing
+// steps. PDFs that can't be preprocessed are used as they are, with a warning.
+func preprocessPDF(pdfBytes []byte, cfg *gdocai.Config, events *cli.EventRecorder) []byte {
+	if len(cfg.Preprocess) == 0 {
+		return pdfBytes
+	}
+	processed, err := gdocai.Preprocess(pdfBytes, cfg)
+	if err != nil {
+		message := fmt.Sprintf("not preprocessing the PDF: %v", err)
+		cli.Warnf("Warning: %s\n", message)
+		events.Record(pdfocr.EventWarning, message)
+		return pdfBytes
+	}
+	cli.Infof("Preprocessed the PDF: %s\n", cfg.Preprocess)
+	return processed
+}
 
-		// In strict mode without force, exit with error
-		if config.Strict && !config.Force {
-			fmt.Printf("Error: Document already has OCR and strict mode is enabled\n")
-			os.Exit(ExitCodeStrictOCRFailure)
+// selectPages lists the pages of a PDF selected by the -pages ranges, which
+// Document AI then processes alone
+func selectPages(pdfBytes []byte, ranges []pdfocr.PageRange) ([]int, error) {
+	count, err := pdfocr.PageCount(pdfBytes)
+	if err != nil {
+		return nil, err
+	}
+	var pages []int
+	for page := 1; page <= count; page++ {
+		for _, r := range ranges {
+			if page >= r.First && (r.Last == 0 || page <= r.Last) {
+				pages = append(pages, page)
+				break
+			}
 		}
-
-		return true
 	}
-
-	return false
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("none of the %d page(s) of the PDF are selected", count)
+	}
+	return pages, nil
 }
 
-func main() {
-	// Override the flag usage message to include additional information
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "  %s -config config.yml -pdf input.pdf [options]\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf input.pdf [options] # Using environment variables for config\n\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "Options:\n")
-		flag.PrintDefaults()
+// loadAPIResponse reads a Document AI response saved with -debug-api and
+// converts it the way a processed document is, without contacting Document AI
+func loadAPIResponse(ctx context.Context, cfg *gdocai.Config, path string) (*gdocai.Document, string, error) {
+	data, err := readFile(ctx, cfg, path)
+	if err != nil {
+		return nil, "", err
+	}
+	raw, err := gdocai.RawDocumentFromJSON(data)
+	if err != nil {
+		return nil, "", err
+	}
+	cli.Infof("Loaded the API response from: %s\n", path)
+	doc := gdocai.DocumentFromProto(raw)
+	return doc, doc.Hocr.HTML, nil
+}
 
-		fmt.Fprintf(flag.CommandLine.Output(), "\nEnvironment Variables for Configuration:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROJECT_ID     - Google Cloud project ID\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_LOCATION       - Document AI API location (e.g., \"us\")\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROCESSOR_ID   - Document AI processor ID\n")
+// textMatchFlag sets the named patterns of text_match placeholders from
+// repeated name=regex flags
+type textMatchFlag map[string]*regexp.Regexp
 
-		fmt.Fprintf(flag.CommandLine.Output(), "\nExit Codes:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success\n", ExitCodeSuccess)
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error\n", ExitCodeError)
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success with warnings (including OCR already detected)\n", ExitCodeSuccessWithWarns)
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error: OCR already detected in strict mode\n", ExitCodeStrictOCRFailure)
+func (f textMatchFlag) String() string { return "" }
 
-		fmt.Fprintf(flag.CommandLine.Output(), "\nExamples:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %s -config config.yml -pdf document.pdf -text document.txt -output document_ocr.pdf\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf invoice.pdf -output \"invoice-@{number:unknown}-@{client}.pdf\"\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdfs page1.pdf,page2.pdf,page3.pdf -output combined.pdf\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROJECT_ID=your-project GDOCAI_LOCATION=us GDOCAI_PROCESSOR_ID=your-processor %s -pdf document.pdf -output document_ocr.pdf\n", os.Args[0])
+func (f textMatchFlag) Set(value string) error {
+	name, pattern, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf(`expected name=regex, e.g. letter_date=Date:\s*(\S+)`)
 	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	f[strings.TrimSpace(name)] = re
+	return nil
+}
 
-	// Configuration flags
-	configPath := flag.String("config", "", "Path to the config YAML file (optional if using environment variables)")
-
-	// Input flags
-	pdfPath := flag.String("pdf", "", "Path to the input PDF file (required if -pdfs is not defined)")
-	pdfPaths := flag.String("pdfs", "", "Comma separated list of input PDF files to process as a single document (required if -pdf is not defined)")
+// metadataFlag sets the entries of a Metadata from repeated key=value flags
+type metadataFlag struct {
+	metadata *pdfocr.Metadata
+}
 
-	// Output flags with detailed descriptions
-	textPath := flag.String("text", "", "Path to save OCR text output")
-	hocrPath := flag.String("hocr", "", "Path to save HOCR output")
-	formFieldsPath := flag.String("form-fields", "", "Path to save form fields JSON")
-	extractorFieldsPath := flag.String("extractor-fields", "", "Path to save custom extractor fields JSON")
-	imagesDir := flag.String("images", "", "Directory to save images returned by Document AI API for each processed page")
+func (f metadataFlag) String() string { return "" }
 
-	// OCR detection flag
-	strict := flag.Bool("strict", false, "If set, exit with error code when OCR is already detected in the PDF")
-	force := flag.Bool("force", false, "Force processing even if OCR is already detected")
+func (f metadataFlag) Set(value string) error {
+	key, text, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, e.g. title=Invoice")
+	}
+	return f.metadata.SetInfo(key, text)
+}
 
-	// Output flag with detailed description of placeholder support
-	pdfOcrPath := flag.String("output", "",
-		`Path to save the PDF with OCR applied. Supports field placeholders:
-  @{field_name} or @{field_name:default_value} - Auto-detect source
-  @{form_field.field_name} - Explicitly use form fields
-  @{extractor_field.field_name} - Explicitly use custom extractor fields
-Example: -output "invoice-@{invoice_number:unknown}-@{date}.pdf"
-All filenames are sanitized: Unicode characters are transliterated to ASCII,
-converted to lowercase, and invalid filename characters are replaced.`)
+// xmpFlag sets XMP properties of a Metadata from repeated property=value flags
+type xmpFlag struct {
+	metadata *pdfocr.Metadata
+}
 
-	// Debug options
-	debugAPIPath := flag.String("debug-api", "", "Path to save raw API response as JSON for debugging")
-	debugDocPath := flag.String("debug-doc", "", "Path to save transformed Document object as JSON for debugging")
+func (f xmpFlag) String() string { return "" }
 
-	// Parse command line arguments
-	flag.Parse()
+func (f xmpFlag) Set(value string) error {
+	// The namespace URI of a {uri}prefix:name property may itself contain '='
+	start := 0
+	if strings.HasPrefix(value, "{") {
+		start = max(strings.Index(value, "}"), 0)
+	}
+	i := strings.Index(value[start:], "=")
+	if i < 0 {
+		return fmt.Errorf("expected property=value, e.g. dc:rights=CC-BY-4.0")
+	}
+	return f.metadata.SetXMP(value[:start+i], value[start+i+1:])
+}
 
-	// Create a map of provided flags to validate
-	providedFlags := make(map[string]bool)
-	flag.Visit(func(f *flag.Flag) {
-		providedFlags[f.Name] = true
-	})
+// scriptFontFlag adds TrueType fonts for a script from repeated script=path flags
+type scriptFontFlag struct {
+	font *pdfocr.FontConfig
+}
 
-	// Validate configuration is available (either via file or env vars)
-	if *configPath == "" {
-		// Check if we have env vars
-		hasEnvConfig := os.Getenv("GDOCAI_PROJECT_ID") != "" &&
-			os.Getenv("GDOCAI_LOCATION") != "" &&
-			os.Getenv("GDOCAI_PROCESSOR_ID") != ""
+func (f scriptFontFlag) String() string { return "" }
 
-		if !hasEnvConfig {
-			fmt.Fprintln(os.Stderr, "Error: Either -config flag or environment variables (GDOCAI_PROJECT_ID, GDOCAI_LOCATION, GDOCAI_PROCESSOR_ID) must be provided")
-			flag.Usage()
-			os.Exit(ExitCodeError)
-		}
+func (f scriptFontFlag) Set(value string) error {
+	script, path, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected script=path, e.g. cyrillic=DejaVuSans.ttf")
 	}
+	return f.font.AddScriptFont(script, path)
+}
 
-	// Validate that either pdf or pdfs flag is provided (but not both)
-	if (*pdfPath == "" && *pdfPaths == "") || (*pdfPath != "" && *pdfPaths != "") {
-		fmt.Fprintln(os.Stderr, "Error: Either -pdf or -pdfs flag must be provided (but not both)")
-		flag.Usage()
-		os.Exit(ExitCodeError)
+// detectExistingOCR checks if a PDF already has OCR, returning an error
+// wrapping pdfocr.ErrAlreadyHasOCR if it does in strict mode without force
+func detectExistingOCR(pdfBytes []byte, config pdfocr.OCRConfig) (bool, error) {
+	ocrResult, err := pdfocr.DetectOCR(pdfBytes, config)
+	if err != nil {
+		cli.Warnf("Warning: OCR detection failed: %v\n", err)
+		return false, nil
 	}
 
-	// Validate that provided output flags have values
-	hasError := false
-	validateFlag := func(name string, value string) {
-		if providedFlags[name] && value == "" {
-			fmt.Fprintf(os.Stderr, "Error: -%s flag requires a value\n", name)
-			hasError = true
-		}
-	}
+	if ocrResult.HasOCR {
+		cli.Warnf("Warning: Document already has OCR\n")
 
-	validateFlag("text", *textPath)
-	validateFlag("hocr", *hocrPath)
-	validateFlag("debug-api", *debugAPIPath)
-	validateFlag("debug-doc", *debugDocPath)
-	validateFlag("form-fields", *formFieldsPath)
-	validateFlag("extractor-fields", *extractorFieldsPath)
-	validateFlag("images", *imagesDir)
-	validateFlag("output", *pdfOcrPath)
+		// In strict mode without force, fail
+		if config.Strict && !config.Force {
+			return true, fmt.Errorf("%w and strict mode is enabled", pdfocr.ErrAlreadyHasOCR)
+		}
 
-	if hasError {
-		flag.Usage()
-		os.Exit(ExitCodeError)
+		return true, nil
 	}
 
-	// Check if at least one output flag is provided
-	hasOutputFlag := providedFlags["text"] || providedFlags["hocr"] ||
-		providedFlags["debug-api"] || providedFlags["debug-doc"] ||
-		providedFlags["form-fields"] || providedFlags["extractor-fields"] ||
-		providedFlags["images"] || providedFlags["output"]
-
-	if !hasOutputFlag {
-		fmt.Fprintln(os.Stderr, "Error: At least one output flag must be provided (-text, -hocr, -debug-api, -debug-doc, -form-fields, -images, or -output)")
-		flag.Usage()
-		os.Exit(ExitCodeError)
-	}
+	return false, nil
+}
 
-	// Create a warning writer to capture warnings
-	warningCapture := newWarningWriter(os.Stdout)
+// outputPaths holds the paths the results of a document are written to.
+// Empty paths are skipped.
+type outputPaths struct {
+	Text             string // OCR text
+	HOCR             string // Rendered hOCR
+	TSV              string // Tesseract-style TSV word data
+	WordsJSONL       string // Word data as JSON Lines
+	DebugAPI         string // Raw API response JSON
+	DebugDoc         string // Transformed Document JSON
+	FormFields       string // Form fields JSON
+	ExtractorFields  string // Custom extractor fields JSON
+	NormalizedFields string // Custom extractor fields JSON with normalized values
+	FieldsCSV        string // Form and custom extractor fields as CSV
+	FieldsXLSX       string // Form and custom extractor fields as an Excel workbook
+	FieldDetails     string // Fields with confidence, page and bounding box as JSON
+	Markdown         string // Document as Markdown
+	Chunks           string // Layout Parser chunks as JSON Lines
+	Images           string // Directory for page images
+	Tables           string // Directory for tables as CSV and JSON
+	PDF              string // PDF with OCR applied, placeholders already resolved
+}
 
-	// Build the OCRConfig for any PDF processing that might occur
-	pdfOcrConfig := pdfocr.OCRConfig{
-		Debug:       false,
-		Force:       *force,
-		Strict:      *strict,
-		StartPage:   1,
-		DumpPDF:     false,
-		Font:        pdfocr.DefaultFont,
-		LogWarnings: true,
-		LayerName:   "OCR Text",
-		Logger:      warningCapture, // Use our custom writer to track warnings
+// writeOutputs writes the results of a processed document. The OCR'ed PDF is
+// made by applying the OCR to pdfBytes, or by assembling pageImages when
+// pdfBytes is nil, or the page images returned by Document AI if both are.
+func writeOutputs(ctx context.Context, cfg *gdocai.Config, doc *gdocai.Document, hocrHTML string, pdfBytes []byte,
+	pageImages [][]byte, out outputPaths, pdfOcrConfig pdfocr.OCRConfig) error {
+	// Write OCR text output if flag is provided.
+	if out.Text != "" {
+		if err := writeFile(ctx, cfg, out.Text, []byte(doc.Text.Content)); err != nil {
+			return fmt.Errorf("failed to write text output: %w", err)
+		}
+		cli.Infof("Document text saved to: %s\n", out.Text)
+		report.AddOutput(out.Text)
 	}
 
-	// Load config from file and/or environment variables
-	cfg, err := loadConfig(*configPath)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	// Write hOCR output if flag is provided.
+	if out.HOCR != "" {
+		if err := writeFile(ctx, cfg, out.HOCR, []byte(hocrHTML)); err != nil {
+			return fmt.Errorf("failed to write HOCR output: %w", err)
+		}
+		cli.Infof("Rendered HOCR output saved to: %s\n", out.HOCR)
+		report.AddOutput(out.HOCR)
 	}
 
-	// Process the document based on input flags
-	ctx := context.Background()
-	var doc *gdocai.Document
-	var hocrHTML string
-	var hasOCR bool
-
-	if *pdfPath != "" {
-		// Process a single PDF file
-		fmt.Println("Processing single PDF file:", *pdfPath)
-
-		// Read PDF bytes from disk.
-		pdfBytes, err := os.ReadFile(*pdfPath)
+	// Write Tesseract-style TSV output if flag is provided.
+	if out.TSV != "" {
+		tsv, err := hocr.ToTSV(doc.Hocr.Content)
 		if err != nil {
-			log.Fatalf("Failed to read PDF file: %v", err)
+			return fmt.Errorf("failed to convert HOCR to TSV: %w", err)
 		}
+		if err := writeFile(ctx, cfg, out.TSV, []byte(tsv)); err != nil {
+			return fmt.Errorf("failed to write TSV output: %w", err)
+		}
+		cli.Infof("TSV word data saved to: %s\n", out.TSV)
+		report.AddOutput(out.TSV)
+	}
 
-		// Pre-check for OCR (exits if strict mode and OCR found)
-		hasOCR = checkPDFForOCR(pdfBytes, pdfOcrConfig)
-
-		// Process the PDF using Google Document AI.
-		doc, hocrHTML, err = gdocai.DocumentHOCR(ctx, pdfBytes, cfg)
+	// Write word JSON Lines output if flag is provided.
+	if out.WordsJSONL != "" {
+		jsonl, err := hocr.ToJSONL(doc.Hocr.Content)
 		if err != nil {
-			log.Fatalf("Error processing document: %v", err)
+			return fmt.Errorf("failed to convert HOCR to JSON Lines: %w", err)
 		}
-	} else {
-		// Process multiple PDF files as individual pages
-		pathsList := strings.Split(*pdfPaths, ",")
-		if len(pathsList) == 0 {
-			log.Fatalf("No PDF files specified with -pdfs")
+		if err := writeFile(ctx, cfg, out.WordsJSONL, []byte(jsonl)); err != nil {
+			return fmt.Errorf("failed to write word JSON Lines output: %w", err)
 		}
+		cli.Infof("Word JSON Lines saved to: %s\n", out.WordsJSONL)
+		report.AddOutput(out.WordsJSONL)
+	}
 
-		fmt.Printf("Processing %d PDF files as separate pages\n", len(pathsList))
-
-		// Read each PDF file
-		var pdfPageBytes [][]byte
-		for i, path := range pathsList {
-			// Trim any whitespace
-			path = strings.TrimSpace(path)
-			if path == "" {
-				continue
-			}
-
-			fmt.Printf("Reading page %d: %s\n", i+1, path)
-			pageBytes, err := os.ReadFile(path)
+	// Write API response JSON if flag is provided.
+	if out.DebugAPI != "" {
+		// Note: When using DocumentHOCRFromPages, the Raw.Document field may be nil
+		if doc.Raw != nil && doc.Raw.Document != nil {
+			apiJSON, err := gdocai.ToJSON(doc.Raw.Document)
 			if err != nil {
-				log.Fatalf("Failed to read PDF file %s: %v", path, err)
+				return fmt.Errorf("failed to convert API response to JSON: %w", err)
 			}
-
-			// Check for OCR in this page
-			ocrResult, err := pdfocr.DetectOCR(pageBytes, pdfOcrConfig)
-			if err == nil && ocrResult.HasOCR {
-				fmt.Printf("Warning: Page %d already has OCR\n", i+1)
-				hasOCR = true
-
-				// In strict mode without force, exit with error
-				if *strict && !*force {
-					fmt.Printf("Error: Page %d already has OCR and strict mode is enabled\n", i+1)
-					os.Exit(ExitCodeStrictOCRFailure)
-				}
+			if err := writeFile(ctx, cfg, out.DebugAPI, []byte(apiJSON)); err != nil {
+				return fmt.Errorf("failed to write API response JSON: %w", err)
 			}
-
-			// Add page to processing regardless (OCR check just sets warning flag)
-			pdfPageBytes = append(pdfPageBytes, pageBytes)
+			cli.Infof("API response JSON saved to: %s\n", out.DebugAPI)
+			report.AddOutput(out.DebugAPI)
+		} else {
+			cli.Warnf("Warning: Raw API response not available when processing multiple PDF files\n")
 		}
+	}
 
-		// Process the PDFs using DocumentHOCRFromPages
-		doc, hocrHTML, err = gdocai.DocumentHOCRFromPages(ctx, pdfPageBytes, cfg)
+	// Write transformed Document JSON if flag is provided.
+	if out.DebugDoc != "" {
+		debugJSON, err := gdocai.ToJSON(doc)
 		if err != nil {
-			log.Fatalf("Error processing documents: %v", err)
+			return fmt.Errorf("failed to convert transformed document to JSON: %w", err)
+		}
+		if err := writeFile(ctx, cfg, out.DebugDoc, []byte(debugJSON)); err != nil {
+			return fmt.Errorf("failed to write transformed document JSON: %w", err)
 		}
+		cli.Infof("Transformed document JSON saved to: %s\n", out.DebugDoc)
+		report.AddOutput(out.DebugDoc)
 	}
 
-	// If OCR was detected, add to warning capture for proper exit code later
-	if hasOCR {
-		warningCapture.buf.WriteString("Warning: Document already has OCR\n")
+	// Write form fields JSON if flag is provided.
+	if out.FormFields != "" {
+		formFieldsJSON, err := gdocai.ToJSON(doc.FormFields.Fields)
+		if err != nil {
+			return fmt.Errorf("failed to convert form fields to JSON: %w", err)
+		}
+		if err := writeFile(ctx, cfg, out.FormFields, []byte(formFieldsJSON)); err != nil {
+			return fmt.Errorf("failed to write form fields JSON: %w", err)
+		}
+		cli.Infof("Form fields JSON saved to: %s\n", out.FormFields)
+		report.AddOutput(out.FormFields)
 	}
 
-	// Write OCR text output if flag is provided.
-	if *textPath != "" {
-		if err := os.WriteFile(*textPath, []byte(doc.Text.Content), 0644); err != nil {
-			log.Fatalf("Failed to write text output: %v", err)
+	// Write custom extractor fields JSON if flag is provided.
+	if out.ExtractorFields != "" {
+		extractorFieldsJSON, err := gdocai.ToJSON(doc.CustomExtractorFields.Fields)
+		if err != nil {
+			return fmt.Errorf("failed to convert custom extractor fields to JSON: %w", err)
 		}
-		fmt.Println("Document text saved to:", *textPath)
+		if err := writeFile(ctx, cfg, out.ExtractorFields, []byte(extractorFieldsJSON)); err != nil {
+			return fmt.Errorf("failed to write custom extractor fields JSON: %w", err)
+		}
+		cli.Infof("Custom extractor fields JSON saved to: %s\n", out.ExtractorFields)
+		report.AddOutput(out.ExtractorFields)
 	}
 
-	// Write hOCR output if flag is provided.
-	if *hocrPath != "" {
-		if err := os.WriteFile(*hocrPath, []byte(hocrHTML), 0644); err != nil {
-			log.Fatalf("Failed to write HOCR output: %v", err)
+	// Write normalized custom extractor fields JSON if flag is provided.
+	if out.NormalizedFields != "" {
+		normalizedFieldsJSON, err := gdocai.ToJSON(doc.CustomExtractorFields.Normalized)
+		if err != nil {
+			return fmt.Errorf("failed to convert normalized extractor fields to JSON: %w", err)
 		}
-		fmt.Println("Rendered HOCR output saved to:", *hocrPath)
+		if err := writeFile(ctx, cfg, out.NormalizedFields, []byte(normalizedFieldsJSON)); err != nil {
+			return fmt.Errorf("failed to write normalized extractor fields JSON: %w", err)
+		}
+		cli.Infof("Normalized extractor fields JSON saved to: %s\n", out.NormalizedFields)
+		report.AddOutput(out.NormalizedFields)
 	}
 
-	// Write API response JSON if flag is provided.
-	if *debugAPIPath != "" {
-		// Note: When using DocumentHOCRFromPages, the Raw.Document field may be nil
-		if doc.Raw != nil && doc.Raw.Document != nil {
-			apiJSON, err := gdocai.ToJSON(doc.Raw.Document)
-			if err != nil {
-				log.Fatalf("Failed to convert API response to JSON: %v", err)
-			}
-			if err := os.WriteFile(*debugAPIPath, []byte(apiJSON), 0644); err != nil {
-				log.Fatalf("Failed to write API response JSON: %v", err)
-			}
-			fmt.Println("API response JSON saved to:", *debugAPIPath)
-		} else {
-			fmt.Println("Warning: Raw API response not available when processing multiple PDF files")
+	// Write the form and custom extractor fields as spreadsheets if flags are provided.
+	if out.FieldsCSV != "" {
+		fieldsCSV, err := gdocai.FieldsToCSV(gdocai.FlattenFields(doc))
+		if err != nil {
+			return err
 		}
+		if err := writeFile(ctx, cfg, out.FieldsCSV, []byte(fieldsCSV)); err != nil {
+			return fmt.Errorf("failed to write fields CSV: %w", err)
+		}
+		cli.Infof("Fields CSV saved to: %s\n", out.FieldsCSV)
+		report.AddOutput(out.FieldsCSV)
 	}
-
-	// Write transformed Document JSON if flag is provided.
-	if *debugDocPath != "" {
-		debugJSON, err := gdocai.ToJSON(doc)
+	if out.FieldsXLSX != "" {
+		fieldsXLSX, err := gdocai.FieldsToXLSX(gdocai.FlattenFields(doc))
 		if err != nil {
-			log.Fatalf("Failed to convert transformed document to JSON: %v", err)
+			return err
 		}
-		if err := os.WriteFile(*debugDocPath, []byte(debugJSON), 0644); err != nil {
-			log.Fatalf("Failed to write transformed document JSON: %v", err)
+		if err := writeFile(ctx, cfg, out.FieldsXLSX, fieldsXLSX); err != nil {
+			return fmt.Errorf("failed to write fields XLSX: %w", err)
 		}
-		fmt.Println("Transformed document JSON saved to:", *debugDocPath)
+		cli.Infof("Fields XLSX saved to: %s\n", out.FieldsXLSX)
+		report.AddOutput(out.FieldsXLSX)
 	}
 
-	// Write form fields JSON if flag is provided.
-	if *formFieldsPath != "" {
-		formFieldsJSON, err := gdocai.ToJSON(doc.FormFields.Fields)
+	// Write the fields with their confidence and location if flag is provided.
+	if out.FieldDetails != "" {
+		details := slices.Concat(doc.FormFields.Details, doc.CustomExtractorFields.Details)
+		if details == nil {
+			details = []gdocai.FieldDetail{}
+		}
+		detailsJSON, err := gdocai.ToJSON(details)
 		if err != nil {
-			log.Fatalf("Failed to convert form fields to JSON: %v", err)
+			return fmt.Errorf("failed to convert field details to JSON: %w", err)
 		}
-		if err := os.WriteFile(*formFieldsPath, []byte(formFieldsJSON), 0644); err != nil {
-			log.Fatalf("Failed to write form fields JSON: %v", err)
+		if err := writeFile(ctx, cfg, out.FieldDetails, []byte(detailsJSON)); err != nil {
+			return fmt.Errorf("failed to write field details JSON: %w", err)
 		}
-		fmt.Println("Form fields JSON saved to:", *formFieldsPath)
+		cli.Infof("Field details JSON saved to: %s\n", out.FieldDetails)
+		report.AddOutput(out.FieldDetails)
 	}
 
-	// Write custom extractor fields JSON if flag is provided.
-	if *extractorFieldsPath != "" {
-		extractorFieldsJSON, err := gdocai.ToJSON(doc.CustomExtractorFields.Fields)
+	// Write the document as Markdown if flag is provided.
+	if out.Markdown != "" {
+		if err := writeFile(ctx, cfg, out.Markdown, []byte(gdocai.ToMarkdown(doc))); err != nil {
+			return fmt.Errorf("failed to write Markdown output: %w", err)
+		}
+		cli.Infof("Markdown saved to: %s\n", out.Markdown)
+		report.AddOutput(out.Markdown)
+	}
+
+	// Write the Layout Parser chunks as JSON Lines if flag is provided.
+	if out.Chunks != "" {
+		if len(doc.Layout.Chunks) == 0 {
+			cli.Warnf("Warning: No chunks returned, -chunks requires a Layout Parser processor\n")
+		}
+		chunks, err := gdocai.ChunksToJSONL(doc.Layout.Chunks)
 		if err != nil {
-			log.Fatalf("Failed to convert custom extractor fields to JSON: %v", err)
+			return err
 		}
-		if err := os.WriteFile(*extractorFieldsPath, []byte(extractorFieldsJSON), 0644); err != nil {
-			log.Fatalf("Failed to write custom extractor fields JSON: %v", err)
+		if err := writeFile(ctx, cfg, out.Chunks, []byte(chunks)); err != nil {
+			return fmt.Errorf("failed to write chunks output: %w", err)
 		}
-		fmt.Println("Custom extractor fields JSON saved to:", *extractorFieldsPath)
+		cli.Infof("Chunks JSON Lines saved to: %s\n", out.Chunks)
+		report.AddOutput(out.Chunks)
 	}
 
 	// Extract and write out images for each page if flag is provided.
-	if *imagesDir != "" {
+	if out.Images != "" {
 		// Ensure output directory exists.
-		if err := os.MkdirAll(*imagesDir, 0755); err != nil {
-			log.Fatalf("Failed to create images directory: %v", err)
+		if err := makeDir(out.Images); err != nil {
+			return fmt.Errorf("failed to create images directory: %w", err)
 		}
 
 		// Check if we have structured pages to extract images from
@@ -769,143 +1163,934 @@ converted to lowercase, and invalid filename characters are replaced.`)
 			for i, page := range doc.Structured.Pages {
 				imgBytes, err := gdocai.ExtractImageFromPage(page)
 				if err != nil {
-					log.Printf("Skipping page %d: %v", i+1, err)
+					cli.Warnf("Warning: skipping page %d: %v\n", i+1, err)
 					continue
 				}
-				imagePath := filepath.Join(*imagesDir, fmt.Sprintf("page_%d.png", i+1))
-				if err := os.WriteFile(imagePath, imgBytes, 0644); err != nil {
+				imagePath := joinPath(out.Images, fmt.Sprintf("page_%d.png", i+1))
+				if err := writeFile(ctx, cfg, imagePath, imgBytes); err != nil {
 					log.Printf("Failed to write image for page %d: %v", i+1, err)
 					continue
 				}
-				fmt.Printf("Saved image for page %d to %s\n", i+1, imagePath)
+				cli.Infof("Saved image for page %d to %s\n", i+1, imagePath)
+				report.AddOutput(imagePath)
 			}
 		} else {
-			fmt.Println("Warning: No page images available to extract")
+			cli.Warnf("Warning: No page images available to extract\n")
+		}
+	}
+
+	// Write each table as CSV and JSON if flag is provided.
+	if out.Tables != "" {
+		if err := makeDir(out.Tables); err != nil {
+			return fmt.Errorf("failed to create tables directory: %w", err)
+		}
+
+		if len(doc.Tables.Tables) == 0 {
+			cli.Warnf("Warning: No tables detected in the document\n")
+		}
+		for _, table := range doc.Tables.Tables {
+			base := joinPath(out.Tables, fmt.Sprintf("page_%d_table_%d", table.PageNumber, table.Index))
+
+			tableCSV, err := table.ToCSV()
+			if err != nil {
+				return fmt.Errorf("failed to convert table to CSV: %w", err)
+			}
+			if err := writeFile(ctx, cfg, base+".csv", []byte(tableCSV)); err != nil {
+				return fmt.Errorf("failed to write table CSV: %w", err)
+			}
+
+			tableJSON, err := table.ToJSON()
+			if err != nil {
+				return fmt.Errorf("failed to convert table to JSON: %w", err)
+			}
+			if err := writeFile(ctx, cfg, base+".json", []byte(tableJSON)); err != nil {
+				return fmt.Errorf("failed to write table JSON: %w", err)
+			}
+			cli.Infof("Saved table %d from page %d to %s.csv and %s.json\n", table.Index, table.PageNumber, base, base)
+			report.AddOutput(base + ".csv")
+			report.AddOutput(base + ".json")
 		}
 	}
 
 	// Generate a new OCR'ed PDF if flag is provided.
-	if *pdfOcrPath != "" {
-		// Check if the output path contains placeholders
-		if strings.Contains(*pdfOcrPath, "@{") {
-			// Split the path into directory and filename parts
-			dir, filenameWithPlaceholders := filepath.Split(*pdfOcrPath)
-
-			// Create placeholder data from extracted fields
-			placeholderData := &PlaceholderData{
-				FormFields:            doc.FormFields.Fields,
-				CustomExtractorFields: doc.CustomExtractorFields.Fields,
+	if out.PDF != "" {
+		if doc.Hocr == nil || doc.Hocr.Content == nil {
+			return fmt.Errorf("HOCR content not available for creating searchable PDF")
+		}
+
+		var ocrPdfBytes []byte
+		var err error
+
+		// Process based on input type
+		if pdfBytes != nil {
+			// Single PDF case - use ApplyOCR to modify the existing PDF
+			cli.Infof("Creating searchable PDF by applying OCR to existing PDF...\n")
+
+			// Apply OCR to the PDF read earlier
+			ocrPdfBytes, err = pdfocr.ApplyOCRContext(ctx, pdfBytes, doc.Hocr.Content, pdfOcrConfig)
+			if err != nil {
+				return fmt.Errorf("failed to apply OCR to PDF: %w", err)
 			}
+		} else if pageImages != nil {
+			// Image input case - create a new PDF from the input images
+			cli.Infof("Creating new searchable PDF from the input page images...\n")
+			cli.Infof("Assembling PDF with %d pages...\n", len(pageImages))
 
-			// Process the placeholders only in the filename part
-			processedFilename, err := processPlaceholders(filenameWithPlaceholders, placeholderData)
+			ocrPdfBytes, err = pdfocr.AssembleWithOCRContext(ctx, doc.Hocr.Content, pageImages, pdfOcrConfig)
 			if err != nil {
-				log.Fatalf("Failed to process output path placeholders: %v", err)
+				return fmt.Errorf("failed to create PDF from images: %w", err)
 			}
+		} else {
+			// Multiple PDFs case - create a new PDF from page images
+			cli.Infof("Creating new searchable PDF from Document AI page images...\n")
 
-			// Sanitize only the filename part
-			processedFilename = sanitizeFilename(processedFilename)
+			// Get images from Document AI results (in memory only)
+			if doc.Structured == nil || doc.Structured.Pages == nil {
+				return fmt.Errorf("no page image data available in the document structure")
+			}
+			for i, page := range doc.Structured.Pages {
+				imgBytes, err := gdocai.ExtractImageFromPage(page)
+				if err != nil {
+					return fmt.Errorf("failed to get image data for page %d: %w", i+1, err)
+				}
+				pageImages = append(pageImages, imgBytes)
+				cli.Debugf("Using image data for page %d (%d bytes)\n", i+1, len(imgBytes))
+			}
 
-			// Make sure the filename has the correct extension
-			if !strings.HasSuffix(strings.ToLower(processedFilename), ".pdf") {
-				processedFilename += ".pdf"
+			// Verify we have images for all pages
+			if len(pageImages) == 0 {
+				return fmt.Errorf("no page image data was found")
 			}
 
-			// Recombine with the original directory
-			processedPath := filepath.Join(dir, processedFilename)
+			cli.Infof("Assembling PDF with %d pages...\n", len(pageImages))
 
-			// Notify the user about the placeholder substitution
-			safelyLogPath(*pdfOcrPath, processedPath)
+			// Use AssembleWithOCR to create a new PDF from images
+			ocrPdfBytes, err = pdfocr.AssembleWithOCRContext(ctx, doc.Hocr.Content, pageImages, pdfOcrConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create PDF from images: %w", err)
+			}
+		}
 
-			// Update the output path
-			*pdfOcrPath = processedPath
+		// Create output directory if it doesn't exist
+		outputDir := filepath.Dir(out.PDF)
+		if outputDir != "" && outputDir != "." && !isObjectURI(out.PDF) {
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
 		}
 
-		if doc.Hocr != nil && doc.Hocr.Content != nil {
-			var ocrPdfBytes []byte
-			var err error
+		// Write the final PDF
+		if err := writeFile(ctx, cfg, out.PDF, ocrPdfBytes); err != nil {
+			return fmt.Errorf("failed to write OCR'ed PDF: %w", err)
+		}
+		cli.Infof("OCR'ed PDF saved to: %s\n", out.PDF)
+		report.AddOutput(out.PDF)
+	}
 
-			// Process based on input type
-			if *pdfPath != "" && (*pdfPaths == "" || !providedFlags["pdfs"]) {
-				// Single PDF case - use ApplyOCR to modify the existing PDF
-				fmt.Println("Creating searchable PDF by applying OCR to existing PDF...")
+	return nil
+}
 
-				// Read the PDF
-				pdfBytes, err := os.ReadFile(*pdfPath)
-				if err != nil {
-					log.Fatalf("Failed to read PDF file: %v", err)
-				}
+// resolve substitutes the field placeholders in every output path, so all
+// artifacts of a document get consistent names
+func (o *outputPaths) resolve(doc *gdocai.Document) error {
+	paths := []*string{&o.Text, &o.HOCR, &o.TSV, &o.WordsJSONL, &o.DebugAPI, &o.DebugDoc, &o.FormFields,
+		&o.ExtractorFields, &o.NormalizedFields, &o.FieldsCSV, &o.FieldsXLSX, &o.FieldDetails, &o.Markdown,
+		&o.Chunks, &o.Images, &o.Tables}
+	for _, path := range paths {
+		resolved, err := resolveOutputPath(*path, doc, "")
+		if err != nil {
+			return err
+		}
+		*path = resolved
+	}
+	resolved, err := resolveOutputPath(o.PDF, doc, ".pdf")
+	if err != nil {
+		return err
+	}
+	o.PDF = resolved
+	return nil
+}
 
-				// Apply OCR to the PDF
-				ocrPdfBytes, err = pdfocr.ApplyOCR(pdfBytes, doc.Hocr.Content, pdfOcrConfig)
-				if err != nil {
-					// Special case for OCR already detected in strict mode
-					if strings.Contains(err.Error(), "already has OCR") && *strict {
-						fmt.Printf("Error: %v\n", err)
-						os.Exit(ExitCodeStrictOCRFailure)
-					}
-					log.Fatalf("Failed to apply OCR to PDF: %v", err)
-				}
-			} else {
-				// Multiple PDFs case - create a new PDF from page images
-				fmt.Println("Creating new searchable PDF from Document AI page images...")
-
-				// Get images from Document AI results (in memory only)
-				var pageImages [][]byte
-
-				if doc.Structured != nil && doc.Structured.Pages != nil {
-					for i, page := range doc.Structured.Pages {
-						imgBytes, err := gdocai.ExtractImageFromPage(page)
-						if err != nil {
-							log.Fatalf("Failed to get image data for page %d: %v", i+1, err)
-						}
-						pageImages = append(pageImages, imgBytes)
-						fmt.Printf("Using image data for page %d (%d bytes)\n", i+1, len(imgBytes))
-					}
-				} else {
-					log.Fatalf("No page image data available in the document structure")
-				}
+// resolveOutputPath substitutes the field placeholders in the file name of an
+// output path with values extracted from the document, sanitizes the result
+// and makes sure it has the extension ext, if any. Paths without placeholders
+// are returned unchanged.
+func resolveOutputPath(path string, doc *gdocai.Document, ext string) (string, error) {
+	if !strings.Contains(path, "@{") {
+		return path, nil
+	}
 
-				// Verify we have images for all pages
-				if len(pageImages) == 0 {
-					log.Fatalf("No page image data was found")
-				}
+	// Split the path into directory and filename parts
+	dir, filenameWithPlaceholders := filepath.Split(path)
 
-				fmt.Printf("Assembling PDF with %d pages...\n", len(pageImages))
+	// Create placeholder data from extracted fields
+	placeholderData := &PlaceholderData{
+		FormFields:            doc.FormFields.Fields,
+		CustomExtractorFields: doc.CustomExtractorFields.Normalized,
+		TextMatches:           textMatches,
+	}
+	if doc.Text != nil {
+		placeholderData.Text = doc.Text.Content
+	}
 
-				// Use AssembleWithOCR to create a new PDF from images
-				ocrPdfBytes, err = pdfocr.AssembleWithOCR(doc.Hocr.Content, pageImages, pdfOcrConfig)
-				if err != nil {
-					log.Fatalf("Failed to create PDF from images: %v", err)
-				}
+	// Process the placeholders only in the filename part
+	processedFilename, err := processPlaceholders(filenameWithPlaceholders, placeholderData)
+	if err != nil {
+		return "", fmt.Errorf("failed to process output path placeholders: %w", err)
+	}
+
+	// Sanitize only the filename part, keeping the case modifiers set
+	processedFilename = sanitizeFilename(processedFilename, placeholdersSetCase(filenameWithPlaceholders))
+
+	// Make sure the filename has the correct extension
+	if !strings.HasSuffix(strings.ToLower(processedFilename), ext) {
+		processedFilename += ext
+	}
+
+	// Recombine with the original directory
+	processedPath := joinPath(dir, processedFilename)
+
+	// Notify the user about the placeholder substitution
+	safelyLogPath(path, processedPath)
+
+	return processedPath, nil
+}
+
+func main() {
+	// "gdocai serve" runs the HTTP server instead of processing files
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		os.Exit(runServe(os.Args[2:]))
+	}
+
+	// Override the flag usage message to include additional information
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -config config.yml -pdf input.pdf [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf input.pdf [options] # Using environment variables for config\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s serve -config config.yml [-addr :8080] # Run as an HTTP service, see %s serve -h\n\n", os.Args[0], os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Options:\n")
+		flag.PrintDefaults()
+
+		fmt.Fprintf(flag.CommandLine.Output(), "\nEnvironment Variables for Configuration:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROJECT_ID     - Google Cloud project ID\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_LOCATION       - Document AI API location (e.g., \"us\")\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROCESSOR_ID   - Document AI processor ID\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROCESSOR_VERSION - Document AI processor version or alias (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_ENDPOINT       - Document AI API endpoint, host:port (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_MAX_QPS        - Limit of Document AI requests per second (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_MAX_CONCURRENT - Limit of Document AI requests in flight (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_CREDENTIALS_JSON - Credential JSON content (optional, default: Application Default Credentials)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_IMPERSONATE_SERVICE_ACCOUNT - Service account email to impersonate (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PREPROCESS - Cleanup steps for scanned pages, e.g. deskew,contrast (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_CHUNK_SIZE - Chunk size in tokens for a Layout Parser processor (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_IMAGE_QUALITY_SCORES - true to score the image quality of each page (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_LANGUAGE_HINTS - Comma separated languages of the documents, e.g. en,de (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_ADVANCED_OCR_OPTIONS - Comma separated advanced options of the OCR processor (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_SYMBOL_BOXES - true to add character boxes to the hOCR (optional)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_CACHE_DIR - Directory caching Document AI responses (optional)\n")
+
+		fmt.Fprintf(flag.CommandLine.Output(), "\nExit Codes:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success\n", cli.ExitSuccess)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error\n", cli.ExitError)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success with warnings (the -warn-on conditions)\n", cli.ExitSuccessWithWarns)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error: OCR already detected in strict mode, or a -fail-on condition was met\n", cli.ExitStrictOCRFailure)
+
+		fmt.Fprintf(flag.CommandLine.Output(), "\nExamples:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -config config.yml -pdf document.pdf -text document.txt -output document_ocr.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf invoice.pdf -output \"invoice-@{number:unknown}-@{client}.pdf\"\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdfs page1.pdf,page2.pdf,page3.pdf -output combined.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -tiff scan.tif -text scan.txt -output scan_ocr.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf gs://my-bucket/scans/doc.pdf -output gs://my-bucket/searchable/doc.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -input-dir ./inbox -output-dir ./searchable -recursive\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -input-dir ./scans -output-dir ./searchable -watch -archive-dir ./originals\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  GDOCAI_PROJECT_ID=your-project GDOCAI_LOCATION=us GDOCAI_PROCESSOR_ID=your-processor %s -pdf document.pdf -output document_ocr.pdf\n", os.Args[0])
+	}
+
+	// Configuration flags
+	configPath := flag.String("config", "", "Path to the config YAML file (optional if using environment variables)")
+
+	// Output flags
+	quiet := flag.Bool("quiet", false, "Print errors only, for cron jobs and scripts (same as -log-level error)")
+	verbose := flag.Bool("verbose", false, "Print debug details as well (same as -log-level debug)")
+	logLevelName := flag.String("log-level", "", "How much to print: error, warn, info or debug (default info; overrides -quiet and -verbose)")
+	warnOn := flag.String("warn-on", cli.DefaultWarnOn,
+		"Conditions that exit with code 2: existing-ocr, warning, encoding-fallback, image-quality (comma separated, or none)")
+	failOn := flag.String("fail-on", "",
+		"Conditions that exit with code 3 although the outputs were written: existing-ocr, warning, encoding-fallback, image-quality")
+
+	// Input flags
+	pdfPath := flag.String("pdf", "", "Path, gs:// or s3:// URI of the input PDF file, or a glob pattern such as 'scans/*.pdf' (required if -pdfs is not defined)")
+	pdfPaths := flag.String("pdfs", "", "Comma separated list of input PDF files or glob patterns to process as a single document (required if -pdf is not defined)")
+	tiffPath := flag.String("tiff", "", "Path, gs:// or s3:// URI of a multipage TIFF scan to process instead of a PDF (the output PDF is built from its pages)")
+	inputDir := flag.String("input-dir", "", "Directory of PDF files to process one by one, writing the OCR'ed PDFs to -output-dir")
+	outputDir := flag.String("output-dir", "", "Directory to save the OCR'ed PDFs of -input-dir (named by -output if given, which may use placeholders)")
+	recursive := flag.Bool("recursive", false, "Also process PDF files in subdirectories of -input-dir, mirroring them in -output-dir")
+	concurrency := flag.Int("concurrency", 1, "Number of PDF files of -input-dir to process at the same time")
+	pages := flag.String("pages", "",
+		`Process only these pages of -pdf with Document AI, e.g. "1-3,7,9-" (the output PDF keeps the other pages as they are)`)
+	noPageImages := flag.Bool("no-page-images", false,
+		"Ask Document AI to leave the page images out of its response, for smaller responses when they aren't needed (not with -images or the -output of -pdfs)")
+
+	// Watch mode flags
+	watch := flag.Bool("watch", false, "Keep watching -input-dir and process new PDF files as they appear, until interrupted")
+	watchInterval := flag.Duration("watch-interval", 5*time.Second, "How often -watch scans -input-dir")
+	failedDir := flag.String("failed-dir", "", "Directory to move PDF files that failed in -watch mode to (default <output-dir>/failed)")
+	archiveDir := flag.String("archive-dir", "", "Directory to move processed PDF files to in -watch mode (default: delete them)")
+
+	// Output flags with detailed descriptions
+	textPath := flag.String("text", "", "Path to save OCR text output")
+	hocrPath := flag.String("hocr", "", "Path to save HOCR output")
+	tsvPath := flag.String("tsv", "", "Path to save Tesseract-style TSV word data")
+	wordsJSONLPath := flag.String("words-jsonl", "", "Path to save word data as JSON Lines (page, text, bbox, confidence)")
+	formFieldsPath := flag.String("form-fields", "", "Path to save form fields JSON")
+	extractorFieldsPath := flag.String("extractor-fields", "", "Path to save custom extractor fields JSON")
+	normalizedFieldsPath := flag.String("normalized-fields", "", "Path to save custom extractor fields JSON with values normalized by Document AI (ISO dates, amounts, addresses)")
+	fieldsCSVPath := flag.String("fields-csv", "", "Path to save form and custom extractor fields as CSV (source, field, value; nested fields as dotted paths)")
+	fieldsXLSXPath := flag.String("fields-xlsx", "", "Path to save form and custom extractor fields as an Excel workbook (source, field, value)")
+	markdownPath := flag.String("markdown", "", "Path to save the document as Markdown with paragraphs, tables and form fields (headings and lists with a Layout Parser processor)")
+	chunksPath := flag.String("chunks", "", "Path to save the document chunks as JSON Lines for retrieval (requires a Layout Parser processor)")
+	fieldDetailsPath := flag.String("field-details", "", "Path to save every form and custom extractor field with its confidence, page and bounding box as JSON")
+	imagesDir := flag.String("images", "", "Directory to save images returned by Document AI API for each processed page")
+	tablesDir := flag.String("tables", "", "Directory to save each detected table as CSV and JSON (page_<n>_table_<m>.csv/.json)")
+	flag.Var(textMatchFlag(textMatches), "text-match",
+		"Named pattern for @{text_match.<name>} placeholders as name=regex, matched against the document text (repeatable)")
+
+	// Preprocessing flag
+	preprocessSteps := flag.String("preprocess", "",
+		"Clean up scanned pages before OCR: comma separated deskew, rotate=90|180|270, contrast, binarize, despeckle")
+
+	// Language flags
+	lang := flag.String("lang", "", `Comma separated languages of the document passed to Document AI as hints, e.g. "en,de" (overrides the config)`)
+	detectLang := flag.Bool("detect-lang", false, "Detect missing page languages locally and fill in the hOCR language tags")
+
+	// Text layer encoding flag
+	encodingFallback := flag.String("encoding-fallback", string(pdfocr.EncodingFallbackTransliterate),
+		"How to render words the OCR font can't encode: transliterate, replace or skip")
+	unicodeFont := flag.String("unicode-font", "",
+		"TrueType font to embed for pages with text outside Windows-1252 (CJK, Arabic, Cyrillic, ...)")
+	var scriptFonts pdfocr.FontConfig
+	flag.Var(scriptFontFlag{&scriptFonts}, "script-font",
+		"Draw words of a script with this TrueType font where it has their glyphs, as script=path (scripts: latin, cyrillic, cjk, arabic; repeatable, first listed preferred)")
+	noLayers := flag.Bool("no-layers", false, "Draw the invisible OCR text straight into the page content instead of onto optional content layers")
+	fitHeight := flag.Bool("fit-height", false, "Size each word's font to the height of its box and scale the glyphs to its width")
+	minFontSize := flag.Float64("min-font-size", 0, "Smallest font size in points -fit-height may choose (0 for no limit)")
+	maxFontSize := flag.Float64("max-font-size", 0, "Largest font size in points -fit-height may choose (0 for no limit)")
+	var metadata pdfocr.Metadata
+	flag.Var(metadataFlag{&metadata}, "metadata",
+		"Set a document information entry of the output PDF as key=value, e.g. title=Invoice (keys: title, author, subject, keywords, creator, producer; repeatable)")
+	flag.Var(xmpFlag{&metadata}, "xmp",
+		"Set an XMP property of the output PDF as prefix:name=value, e.g. dc:rights=CC-BY-4.0, or {namespace-uri}prefix:name=value for a custom namespace (repeatable)")
+	keepAnnotations := flag.Bool("keep-annotations", false,
+		"Copy the links, highlights and comments of the input PDF to the output PDF (form fields are always kept)")
+	password := flag.String("password", "",
+		"Password to open encrypted input PDFs, as their user or owner password (the output PDF is not encrypted)")
+	imageDPI := flag.Float64("image-dpi", 0,
+		"Downsample the page images of the output PDF built for -pdfs or -tiff to this resolution (0 keeps every pixel)")
+	imageSourceDPI := flag.Float64("image-source-dpi", pdfocr.DefaultImageSourceDPI,
+		"Resolution of page images that don't state one in their header, for -image-dpi")
+	imageQuality := flag.Int("image-quality", 0,
+		"Re-encode the page images of the output PDF built for -pdfs or -tiff as JPEG with this quality (1-100) where that makes them smaller")
+	compressBitonal := flag.Bool("compress-bitonal", false,
+		"Embed black-and-white page images of the output PDF built for -pdfs or -tiff as CCITT Group 4")
+
+	// OCR detection flag
+	strict := flag.Bool("strict", false, "If set, exit with error code when OCR is already detected in the PDF")
+	force := flag.Bool("force", false, "Force processing even if OCR is already detected")
+
+	// Output flag with detailed description of placeholder support
+	pdfOcrPath := flag.String("output", "",
+		`Path to save the PDF with OCR applied. Supports field placeholders:
+  @{field_name} or @{field_name:default_value} - Auto-detect source
+  @{form_field.field_name} - Explicitly use form fields
+  @{extractor_field.field_name} - Explicitly use custom extractor fields
+Custom extractor fields use the value normalized by Document AI when available.
+Example: -output "invoice-@{invoice_number:unknown}-@{date}.pdf"
+All filenames are sanitized: Unicode characters are transliterated to ASCII,
+converted to lowercase (unless a placeholder sets the case with upper, lower or title),
+and invalid filename characters are replaced.`)
+
+	// Batch processing
+	batchGCS := flag.String("batch-gcs", "",
+		"Process -pdf with Document AI batch processing, staging files at this gs://bucket/prefix (for documents beyond the online page limit)")
+
+	// Cost estimation
+	estimate := flag.Bool("estimate", false,
+		"Count the pages of the inputs locally and print the estimated Document AI cost instead of processing them")
+	processorType := flag.String("processor-type", string(gdocai.ProcessorOCR),
+		"Type of the processor for -estimate, which sets the price of a page: ocr, form, layout or custom-extractor")
+
+	// Response cache
+	cacheDir := flag.String("cache-dir", "",
+		"Directory caching Document AI responses, so unchanged documents aren't processed again (overrides the config)")
+	noCache := flag.Bool("no-cache", false, "Process every document with Document AI, ignoring the configured cache")
+
+	// Debug options
+	debugAPIPath := flag.String("debug-api", "", "Path to save raw API response as JSON for debugging")
+	fromAPIJSON := flag.String("from-api-json", "",
+		"Build the outputs from an API response saved with -debug-api instead of processing the input with Document AI")
+	debugDocPath := flag.String("debug-doc", "", "Path to save transformed Document object as JSON for debugging")
+
+	// Run report
+	jsonReport := flag.String("json", "", "Write a JSON report of the run (inputs, outputs, OCR detected, warnings, pages, timing, exit reason) to this file, or - for standard output")
+
+	// Parse command line arguments
+	flag.Parse()
+
+	// Keep standard output for the report when it is written there
+	report.Path = *jsonReport
+	if *jsonReport == "-" {
+		os.Stdout = os.Stderr
+	}
+
+	if err := cli.SetOutputLevel(*quiet, *verbose, *logLevelName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		cli.Exit(report, cli.ExitError)
+	}
+	var err error
+	if cli.Policy, err = cli.ParseExitPolicy(conditions, *warnOn, *failOn); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		cli.Exit(report, cli.ExitError)
+	}
+
+	// Create a map of provided flags to validate
+	providedFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		providedFlags[f.Name] = true
+	})
+
+	// Validate configuration is available (either via file or env vars),
+	// which offline runs from a saved API response don't need
+	if *configPath == "" && *fromAPIJSON == "" {
+		// Check if we have env vars
+		hasEnvConfig := os.Getenv("GDOCAI_PROJECT_ID") != "" &&
+			os.Getenv("GDOCAI_LOCATION") != "" &&
+			os.Getenv("GDOCAI_PROCESSOR_ID") != ""
+
+		if !hasEnvConfig {
+			fmt.Fprintln(os.Stderr, "Error: Either -config flag or environment variables (GDOCAI_PROJECT_ID, GDOCAI_LOCATION, GDOCAI_PROCESSOR_ID) must be provided")
+			flag.Usage()
+			cli.Exit(report, cli.ExitError)
+		}
+	}
+
+	// Validate that exactly one of the pdf, pdfs, tiff and input-dir flags is provided
+	inputs := 0
+	for _, input := range []string{*pdfPath, *pdfPaths, *tiffPath, *inputDir} {
+		if input != "" {
+			inputs++
+		}
+	}
+	if inputs > 1 || inputs == 0 && *fromAPIJSON == "" {
+		fmt.Fprintln(os.Stderr, "Error: Exactly one of the -pdf, -pdfs, -tiff or -input-dir flags must be provided")
+		flag.Usage()
+		cli.Exit(report, cli.ExitError)
+	}
+
+	// A -pdf glob pattern with a single match is processed as that PDF, and
+	// with several (or with -output-dir) one by one like -input-dir
+	var pdfPattern string
+	if isPattern(*pdfPath) {
+		matches, err := expandPattern(*pdfPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -pdf: %v\n", err)
+			cli.Exit(report, cli.ExitError)
+		}
+		switch {
+		case *recursive || *watch:
+			fmt.Fprintln(os.Stderr, "Error: -recursive and -watch require -input-dir, not a -pdf pattern")
+			cli.Exit(report, cli.ExitError)
+		case len(matches) == 1 && *outputDir == "":
+			*pdfPath = matches[0]
+		case *outputDir == "":
+			fmt.Fprintf(os.Stderr, "Error: -pdf %q matches %d files; add -output-dir to process them one by one\n", *pdfPath, len(matches))
+			cli.Exit(report, cli.ExitError)
+		default:
+			pdfPattern, *pdfPath = *pdfPath, ""
+			*inputDir = patternDir(pdfPattern)
+		}
+	}
+
+	// Validate that provided output flags have values
+	hasError := false
+	validateFlag := func(name string, value string) {
+		if providedFlags[name] && value == "" {
+			fmt.Fprintf(os.Stderr, "Error: -%s flag requires a value\n", name)
+			hasError = true
+		}
+	}
+
+	validateFlag("text", *textPath)
+	validateFlag("hocr", *hocrPath)
+	validateFlag("tsv", *tsvPath)
+	validateFlag("words-jsonl", *wordsJSONLPath)
+	validateFlag("debug-api", *debugAPIPath)
+	validateFlag("debug-doc", *debugDocPath)
+	validateFlag("form-fields", *formFieldsPath)
+	validateFlag("extractor-fields", *extractorFieldsPath)
+	validateFlag("normalized-fields", *normalizedFieldsPath)
+	validateFlag("fields-csv", *fieldsCSVPath)
+	validateFlag("fields-xlsx", *fieldsXLSXPath)
+	validateFlag("field-details", *fieldDetailsPath)
+	validateFlag("markdown", *markdownPath)
+	validateFlag("chunks", *chunksPath)
+	validateFlag("images", *imagesDir)
+	validateFlag("tables", *tablesDir)
+	validateFlag("output", *pdfOcrPath)
+	validateFlag("output-dir", *outputDir)
+
+	if (*inputDir != "") != (*outputDir != "") {
+		fmt.Fprintln(os.Stderr, "Error: -input-dir and -output-dir must be used together")
+		hasError = true
+	}
+	if *recursive && *inputDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -recursive requires -input-dir")
+		hasError = true
+	}
+	if *watch && *inputDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -watch requires -input-dir")
+		hasError = true
+	}
+	if *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -concurrency must be at least 1")
+		hasError = true
+	} else if *concurrency > 1 && (*inputDir == "" || *watch) {
+		fmt.Fprintln(os.Stderr, "Error: -concurrency requires -input-dir and can't be combined with -watch")
+		hasError = true
+	}
+	if (*failedDir != "" || *archiveDir != "") && !*watch {
+		fmt.Fprintln(os.Stderr, "Error: -failed-dir and -archive-dir require -watch")
+		hasError = true
+	}
+	if *watchInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -watch-interval must be positive")
+		hasError = true
+	}
+	if *inputDir != "" {
+		// Only the OCR'ed PDFs are written in directory mode
+		for _, name := range []string{"text", "hocr", "tsv", "words-jsonl", "debug-api", "debug-doc",
+			"form-fields", "extractor-fields", "normalized-fields", "fields-csv", "fields-xlsx", "field-details", "markdown", "chunks", "images", "tables", "batch-gcs"} {
+			if providedFlags[name] {
+				fmt.Fprintf(os.Stderr, "Error: -%s cannot be used with -input-dir\n", name)
+				hasError = true
 			}
+		}
+		if strings.ContainsAny(*pdfOcrPath, `/\`) {
+			fmt.Fprintln(os.Stderr, "Error: with -input-dir, -output must be a file name (the PDFs are saved to -output-dir)")
+			hasError = true
+		}
+		for _, dir := range []string{*inputDir, *outputDir, *failedDir, *archiveDir} {
+			if isObjectURI(dir) {
+				fmt.Fprintln(os.Stderr, "Error: directory and watch mode only support local directories, not gs:// or s3:// URIs")
+				hasError = true
+				break
+			}
+		}
+	}
 
-			// Create output directory if it doesn't exist
-			outputDir := filepath.Dir(*pdfOcrPath)
-			if outputDir != "" && outputDir != "." {
-				if err := os.MkdirAll(outputDir, 0755); err != nil {
-					log.Fatalf("Failed to create output directory: %v", err)
-				}
+	fallback, err := pdfocr.ParseEncodingFallback(*encodingFallback)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		hasError = true
+	}
+
+	if *estimate && *watch {
+		fmt.Fprintln(os.Stderr, "Error: -estimate can't be combined with -watch")
+		hasError = true
+	}
+	if *cacheDir != "" && *noCache {
+		fmt.Fprintln(os.Stderr, "Error: -cache-dir can't be combined with -no-cache")
+		hasError = true
+	}
+	if _, ok := gdocai.PagePrices[gdocai.ProcessorType(*processorType)]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: invalid -processor-type %q (expected ocr, form, layout or custom-extractor)\n", *processorType)
+		hasError = true
+	}
+
+	if *batchGCS != "" && *pdfPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -batch-gcs requires -pdf")
+		hasError = true
+	}
+	var pageRanges []pdfocr.PageRange
+	if *pages != "" {
+		if pageRanges, err = pdfocr.ParsePageRanges(*pages); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -pages: %v\n", err)
+			hasError = true
+		}
+		if *pdfPath == "" {
+			fmt.Fprintln(os.Stderr, "Error: -pages requires a single -pdf")
+			hasError = true
+		}
+	}
+	if *noPageImages && (*imagesDir != "" || *pdfPaths != "" && *pdfOcrPath != "") {
+		fmt.Fprintln(os.Stderr, "Error: -no-page-images can't be combined with -images or with the -output of -pdfs, which need the page images")
+		hasError = true
+	}
+	if *fromAPIJSON != "" && (*pdfPaths != "" || *inputDir != "" || *batchGCS != "" || *estimate) {
+		fmt.Fprintln(os.Stderr, "Error: -from-api-json can only be combined with -pdf or -tiff")
+		hasError = true
+	}
+
+	if hasError {
+		flag.Usage()
+		cli.Exit(report, cli.ExitError)
+	}
+
+	// Check if at least one output flag is provided
+	hasOutputFlag := providedFlags["text"] || providedFlags["hocr"] ||
+		providedFlags["tsv"] || providedFlags["words-jsonl"] ||
+		providedFlags["debug-api"] || providedFlags["debug-doc"] ||
+		providedFlags["form-fields"] || providedFlags["extractor-fields"] || providedFlags["normalized-fields"] ||
+		providedFlags["fields-csv"] || providedFlags["fields-xlsx"] || providedFlags["field-details"] ||
+		providedFlags["markdown"] || providedFlags["chunks"] ||
+		providedFlags["images"] || providedFlags["tables"] || providedFlags["output"]
+
+	if !hasOutputFlag && *inputDir == "" && !*estimate {
+		fmt.Fprintln(os.Stderr, "Error: At least one output flag must be provided (-text, -hocr, -tsv, -words-jsonl, -debug-api, -debug-doc, -form-fields, -extractor-fields, -normalized-fields, -fields-csv, -fields-xlsx, -field-details, -markdown, -chunks, -images, -tables, or -output)")
+		flag.Usage()
+		cli.Exit(report, cli.ExitError)
+	}
+
+	// Record warning events to pick the exit code
+	events := cli.NewEventRecorder(conditions)
+
+	// Build the OCRConfig for any PDF processing that might occur
+	pdfOcrConfig := pdfocr.OCRConfig{
+		Debug:       false,
+		Force:       *force,
+		Strict:      *strict,
+		StartPage:   1,
+		DumpPDF:     false,
+		Font:        pdfocr.DefaultFont,
+		LogWarnings: true,
+		LayerName:   "OCR Text",
+		Logger:      cli.LibraryOutput(),
+		EventLogger: slog.New(events),
+
+		EncodingFallback:     fallback,
+		ReplacementChar:      "?",
+		FailOnEncodingIssues: *strict && !*force,
+	}
+	pdfOcrConfig.Font.UnicodeFontPath = *unicodeFont
+	pdfOcrConfig.Font.ScriptFonts = scriptFonts.ScriptFonts
+	pdfOcrConfig.NoLayers = *noLayers
+	pdfOcrConfig.Font.FitHeight = *fitHeight
+	pdfOcrConfig.Font.MinSize = *minFontSize
+	pdfOcrConfig.Font.MaxSize = *maxFontSize
+	pdfOcrConfig.Metadata = metadata
+	pdfOcrConfig.KeepAnnotations = *keepAnnotations
+	pdfOcrConfig.Password = *password
+	pdfOcrConfig.ImageDPI = *imageDPI
+	pdfOcrConfig.ImageSourceDPI = *imageSourceDPI
+	pdfOcrConfig.ImageQuality = *imageQuality
+	pdfOcrConfig.CompressBitonal = *compressBitonal
+
+	// Load config from file and/or environment variables
+	cfg, err := loadConfig(*configPath)
+	if err == nil && *fromAPIJSON == "" {
+		err = requireProcessor(cfg)
+	}
+	if err != nil {
+		fatalf("Failed to load config: %v", err)
+	}
+	if *preprocessSteps != "" {
+		if cfg.Preprocess, err = preprocess.ParsePipeline(*preprocessSteps); err != nil {
+			fatalf("Invalid -preprocess: %v", err)
+		}
+	}
+	if *lang != "" {
+		cfg.LanguageHints = splitList(*lang)
+	}
+	if *cacheDir != "" {
+		cfg.Cache = gdocai.DirCache{Dir: *cacheDir}
+	}
+	if *noCache {
+		cfg.Cache = nil
+	}
+	cfg.SkipPageImages = *noPageImages
+	pdfOcrConfig.Provenance.ProcessorID = cfg.ProcessorID
+	pdfOcrConfig.Provenance.ProcessorVersion = cfg.ProcessorVersion
+
+	// Process the document based on input flags
+	ctx := context.Background()
+
+	// Only count the pages and print the cost with -estimate
+	if *estimate {
+		report.Mode = "estimate"
+		report.Events = events
+		dir := directoryMode{InputDir: *inputDir, OutputDir: *outputDir, Recursive: *recursive, Pattern: pdfPattern}
+		inputs, err := estimateInputs(dir, *pdfPath, *pdfPaths, *tiffPath)
+		if err != nil {
+			fatalf("Failed to list inputs: %v", err)
+		}
+		mode := estimateMode{
+			Inputs:    inputs,
+			TIFF:      *tiffPath != "",
+			Processor: gdocai.ProcessorType(*processorType),
+			Batch:     *batchGCS != "",
+			Password:  pdfOcrConfig.Password,
+			Pages:     pageRanges,
+		}
+		if err := mode.run(ctx, cfg, events); err != nil {
+			fatalf("Failed to estimate: %v", err)
+		}
+		cli.Finish(report, events)
+	}
+
+	if *inputDir != "" {
+		report.Mode = "directory"
+		report.Inputs = append(report.Inputs, *inputDir)
+		if pdfPattern != "" {
+			report.Mode = "glob"
+			report.Inputs = []string{pdfPattern}
+		}
+		mode := directoryMode{
+			InputDir:     *inputDir,
+			OutputDir:    *outputDir,
+			Recursive:    *recursive,
+			NameTemplate: *pdfOcrPath,
+			DetectLang:   *detectLang,
+			Concurrency:  *concurrency,
+			Pattern:      pdfPattern,
+		}
+		if !*watch {
+			cli.Exit(report, mode.run(ctx, cfg, pdfOcrConfig))
+		}
+
+		// Watched inputs are moved out of the inbox, and results never replace earlier ones
+		report.Mode = "watch"
+		if *failedDir == "" {
+			*failedDir = filepath.Join(*outputDir, "failed")
+		}
+		mode.Unique = true
+		mode.Exclude = []string{*failedDir, *archiveDir}
+		watcher := watchMode{
+			directoryMode: mode,
+			FailedDir:     *failedDir,
+			ArchiveDir:    *archiveDir,
+			Interval:      *watchInterval,
+		}
+		cli.Exit(report, watcher.run(ctx, cfg, pdfOcrConfig))
+	}
+
+	var doc *gdocai.Document
+	var hocrHTML string
+	var hasOCR bool
+	var pdfBytes []byte
+	var pageImages [][]byte
+
+	report.Events = events
+	if *fromAPIJSON != "" && *pdfPath == "" && *tiffPath == "" {
+		// Build the outputs from a saved API response alone; the output PDF is
+		// assembled from the page images it has
+		report.Mode = "offline"
+		report.Inputs = append(report.Inputs, *fromAPIJSON)
+		if doc, hocrHTML, err = loadAPIResponse(ctx, cfg, *fromAPIJSON); err != nil {
+			fatalf("Failed to load API response: %v", err)
+		}
+	} else if *pdfPath != "" {
+		// Process a single PDF file
+		report.Mode = "pdf"
+		report.Inputs = append(report.Inputs, *pdfPath)
+		cli.Infof("Processing single PDF file: %s\n", *pdfPath)
+
+		pdfBytes, err = readFile(ctx, cfg, *pdfPath)
+		if err != nil {
+			fatalf("Failed to read PDF file: %v", err)
+		}
+
+		// Document AI can't read encrypted PDFs, so send it a decrypted copy
+		if pdfBytes, err = pdfocr.DecryptPDF(pdfBytes, pdfOcrConfig.Password); err != nil {
+			fatalf("Failed to decrypt PDF file: %v", err)
+		}
+
+		// Only the selected pages are processed, and get the OCR text
+		if len(pageRanges) > 0 {
+			if cfg.Pages, err = selectPages(pdfBytes, pageRanges); err != nil {
+				fatalf("Failed to select pages: %v", err)
 			}
+			pdfOcrConfig.PageRanges = pageRanges
+			cli.Infof("Processing %d selected page(s): %s\n", len(cfg.Pages), *pages)
+		}
 
-			// Write the final PDF
-			if err := os.WriteFile(*pdfOcrPath, ocrPdfBytes, 0644); err != nil {
-				log.Fatalf("Failed to write OCR'ed PDF: %v", err)
+		// Pre-check for OCR (exits if strict mode and OCR found)
+		hasOCR = checkPDFForOCR(pdfBytes, pdfOcrConfig)
+		pdfBytes = preprocessPDF(pdfBytes, cfg, events)
+
+		// Process the PDF using Google Document AI, or use the saved response of it
+		if *fromAPIJSON != "" {
+			report.Inputs = append(report.Inputs, *fromAPIJSON)
+			doc, hocrHTML, err = loadAPIResponse(ctx, cfg, *fromAPIJSON)
+		} else if *batchGCS != "" {
+			stage, err := gdocai.ParseBatchStorage(*batchGCS)
+			if err != nil {
+				fatalf("Invalid -batch-gcs: %v", err)
 			}
-			fmt.Println("OCR'ed PDF saved to:", *pdfOcrPath)
+			poll := gdocai.DefaultPollOptions()
+			poll.OnProgress = func(s *gdocai.BatchStatus) {
+				done, total := s.Progress()
+				cli.Infof("Batch %s: %d/%d documents\n", s.State, done, total)
+			}
+			doc, hocrHTML, err = gdocai.DocumentHOCRBatch(ctx, pdfBytes, filepath.Base(*pdfPath), cfg, stage, poll)
 		} else {
-			log.Fatalf("HOCR content not available for creating searchable PDF")
+			doc, hocrHTML, err = gdocai.DocumentHOCR(ctx, pdfBytes, cfg)
+		}
+		if err != nil {
+			fatalf("Error processing document: %v", err)
+		}
+	} else if *tiffPath != "" {
+		// Process a multipage TIFF scan, which Document AI reads as it is
+		report.Mode = "tiff"
+		report.Inputs = append(report.Inputs, *tiffPath)
+		cli.Infof("Processing TIFF file: %s\n", *tiffPath)
+
+		tiffBytes, err := readFile(ctx, cfg, *tiffPath)
+		if err != nil {
+			fatalf("Failed to read TIFF file: %v", err)
+		}
+		if !pdfocr.IsTIFF(tiffBytes) {
+			fatalf("%s is not a TIFF file", *tiffPath)
+		}
+		if len(cfg.Preprocess) > 0 {
+			message := "not preprocessing the TIFF file: preprocessing applies to PDFs only"
+			cli.Warnf("Warning: %s\n", message)
+			events.Record(pdfocr.EventWarning, message)
 		}
-	}
 
-	// Exit with appropriate code based on warning capture
-	if warningCapture.HasOCRWarning() {
-		fmt.Println("Note: Completed with OCR warnings - existing OCR was detected")
-		os.Exit(ExitCodeSuccessWithWarns)
-	} else if warningCapture.HasWarnings() {
-		fmt.Println("Note: Completed with warnings")
-		os.Exit(ExitCodeSuccessWithWarns)
+		// The output PDF is built from the pages of the TIFF rather than
+		// from the page images Document AI returns
+		if *pdfOcrPath != "" {
+			if pageImages, err = pdfocr.SplitTIFF(tiffBytes); err != nil {
+				fatalf("Failed to split TIFF file: %v", err)
+			}
+			cli.Infof("Split %d page(s) from the TIFF file\n", len(pageImages))
+		}
+
+		if *fromAPIJSON != "" {
+			report.Inputs = append(report.Inputs, *fromAPIJSON)
+			doc, hocrHTML, err = loadAPIResponse(ctx, cfg, *fromAPIJSON)
+		} else {
+			doc, hocrHTML, err = gdocai.DocumentHOCRFromImage(ctx, tiffBytes, "image/tiff", cfg)
+		}
+		if err != nil {
+			fatalf("Error processing document: %v", err)
+		}
 	} else {
-		os.Exit(ExitCodeSuccess)
+		// Process multiple PDF files as individual pages
+		report.Mode = "pdfs"
+		pathsList, err := expandPDFList(*pdfPaths)
+		if err != nil {
+			fatalf("Failed to expand -pdfs: %v", err)
+		}
+		if len(pathsList) == 0 {
+			fatalf("No PDF files specified with -pdfs")
+		}
+
+		cli.Infof("Processing %d PDF files as separate pages\n", len(pathsList))
+
+		// Read each PDF file
+		var pdfPageBytes [][]byte
+		for i, path := range pathsList {
+			// Trim any whitespace
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+
+			cli.Infof("Reading page %d: %s\n", i+1, path)
+			report.Inputs = append(report.Inputs, path)
+			pageBytes, err := readFile(ctx, cfg, path)
+			if err != nil {
+				fatalf("Failed to read PDF file %s: %v", path, err)
+			}
+			if pageBytes, err = pdfocr.DecryptPDF(pageBytes, pdfOcrConfig.Password); err != nil {
+				fatalf("Failed to decrypt PDF file %s: %v", path, err)
+			}
+
+			// Check for OCR in this page
+			ocrResult, err := pdfocr.DetectOCR(pageBytes, pdfOcrConfig)
+			if err == nil && ocrResult.HasOCR {
+				cli.Warnf("Warning: Page %d already has OCR\n", i+1)
+				hasOCR = true
+
+				// In strict mode without force, exit with error
+				if *strict && !*force {
+					failStrict(fmt.Errorf("%w on page %d and strict mode is enabled", pdfocr.ErrAlreadyHasOCR, i+1))
+				}
+			}
+
+			// Add page to processing regardless (OCR check just sets warning flag)
+			pdfPageBytes = append(pdfPageBytes, preprocessPDF(pageBytes, cfg, events))
+		}
+
+		// Process the PDFs using DocumentHOCRFromPages
+		doc, hocrHTML, err = gdocai.DocumentHOCRFromPages(ctx, pdfPageBytes, cfg)
+		if err != nil {
+			fatalf("Error processing documents: %v", err)
+		}
 	}
+
+	// Fill in missing languages with local detection if requested
+	if *detectLang && doc.Hocr != nil && doc.Hocr.Content != nil {
+		if detected := hocr.DetectLanguages(doc.Hocr.Content); detected > 0 {
+			hocrHTML, err = hocr.GenerateHOCRDocument(doc.Hocr.Content)
+			if err != nil {
+				fatalf("Failed to regenerate HOCR after language detection: %v", err)
+			}
+			doc.Hocr.HTML = hocrHTML
+			cli.Infof("Detected language for %d page(s): %s\n", detected, doc.Hocr.Content.Metadata["ocr-langs"])
+		}
+	}
+
+	// If OCR was detected, add to warning capture for proper exit code later
+	if hasOCR {
+		events.Record(pdfocr.EventOCRDetected, "PDF already has OCR")
+	}
+	report.HasOCR = hasOCR
+	if doc.Hocr != nil && doc.Hocr.Content != nil {
+		report.Pages = len(doc.Hocr.Content.Pages)
+	}
+	if report.addImageQuality("", doc) {
+		events.Raise(conditionImageQuality)
+	}
+
+	// Apply OCR to the single input PDF; pages given with -pdfs or -tiff are assembled from their images
+	applyTo := pdfBytes
+	if *pdfPath == "" {
+		applyTo = nil
+	}
+
+	out := outputPaths{
+		Text:             *textPath,
+		HOCR:             *hocrPath,
+		TSV:              *tsvPath,
+		WordsJSONL:       *wordsJSONLPath,
+		DebugAPI:         *debugAPIPath,
+		DebugDoc:         *debugDocPath,
+		FormFields:       *formFieldsPath,
+		ExtractorFields:  *extractorFieldsPath,
+		NormalizedFields: *normalizedFieldsPath,
+		FieldsCSV:        *fieldsCSVPath,
+		FieldsXLSX:       *fieldsXLSXPath,
+		FieldDetails:     *fieldDetailsPath,
+		Markdown:         *markdownPath,
+		Chunks:           *chunksPath,
+		Images:           *imagesDir,
+		Tables:           *tablesDir,
+		PDF:              *pdfOcrPath,
+	}
+
+	// Resolve placeholders in the output paths with the extracted fields
+	if err := out.resolve(doc); err != nil {
+		fatalf("Failed to resolve output path: %v", err)
+	}
+	if err := writeOutputs(ctx, cfg, doc, hocrHTML, applyTo, pageImages, out, pdfOcrConfig); err != nil {
+		// Special case for OCR already detected in strict mode
+		if errors.Is(err, pdfocr.ErrAlreadyHasOCR) {
+			failStrict(err)
+		}
+		fatalf("Error: %v", err)
+	}
+
+	// Exit with the code the -warn-on and -fail-on policy gives the recorded events
+	cli.Finish(report, events)
 }
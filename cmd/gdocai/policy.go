@@ -0,0 +1,19 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+)
+
+// conditionImageQuality is the condition of pages that may need a rescan
+// (with image_quality_scores), which gdocai adds to the conditions of
+// -warn-on and -fail-on
+const conditionImageQuality = "image-quality"
+
+// conditions are the known conditions, in the order they are reported
+var conditions = []string{cli.ConditionExistingOCR, cli.ConditionWarning, cli.ConditionEncoding, conditionImageQuality}
+
+// errFailOn is the error of a PDF of a directory that met a -fail-on
+// condition, although its output was written
+var errFailOn = errors.New("-fail-on condition met")
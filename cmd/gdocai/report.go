@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+	"github.com/gardar/ocrchestra/pkg/gdocai"
+)
+
+// runReport is the machine-readable summary of a run, written by -json. Its
+// mode is pdf, pdfs, tiff, directory, glob or watch.
+type runReport struct {
+	cli.Report
+	Pages         int           `json:"pages"`                        // Pages processed by Document AI, or counted with -estimate
+	EstimatedCost float64       `json:"estimated_cost_usd,omitempty"` // Document AI cost estimated with -estimate
+	Files         []fileReport  `json:"files,omitempty"`
+	Quality       []pageQuality `json:"page_quality,omitempty"` // With image_quality_scores set
+}
+
+// fileReport is the outcome of one PDF in directory and watch mode
+type fileReport struct {
+	Input      string   `json:"input"`
+	Output     string   `json:"output,omitempty"`
+	Warnings   bool     `json:"warnings"`
+	Conditions []string `json:"conditions,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// pageQuality is the image quality Document AI scored a page with
+type pageQuality struct {
+	Input   string             `json:"input,omitempty"` // PDF of the page in directory and watch mode
+	Page    int                `json:"page"`
+	Score   float64            `json:"score"`   // 0 (worst) to 1 (best)
+	Defects map[string]float64 `json:"defects"` // Confidence of each defect, e.g. "blurry": 0.93
+}
+
+// rescanConfidence is the confidence of a defect from which its page is
+// printed as possibly needing a rescan
+const rescanConfidence = 0.5
+
+// report collects the summary of this run
+var report = &runReport{Report: cli.NewReport("gdocai")}
+
+// addFile records the outcome of a PDF of a directory, and the conditions
+// it met, in the report
+func (r *runReport) addFile(result directoryResult) {
+	file := fileReport{Input: result.Input, Output: result.Output, Warnings: result.Warnings, Conditions: result.Conditions}
+	if result.Err != nil {
+		file.Error = result.Err.Error()
+	}
+	r.Lock()
+	r.Files = append(r.Files, file)
+	r.Unlock()
+	r.AddConditions(result.Conditions)
+}
+
+// addPages adds the pages of a processed document to the report
+func (r *runReport) addPages(pages int) {
+	r.Lock()
+	defer r.Unlock()
+	r.Pages += pages
+}
+
+// addImageQuality records the image quality scores of the pages of a
+// document in the report, prints the pages that may need a rescan and
+// returns whether there were any
+func (r *runReport) addImageQuality(input string, doc *gdocai.Document) bool {
+	if doc.Structured == nil {
+		return false
+	}
+	r.Lock()
+	defer r.Unlock()
+	rescan := false
+	for _, page := range doc.Structured.Pages {
+		if page.ImageQuality == nil {
+			continue
+		}
+		quality := pageQuality{Input: input, Page: page.PageNumber, Score: page.ImageQuality.Score, Defects: map[string]float64{}}
+		for _, defect := range page.ImageQuality.Defects {
+			quality.Defects[defect.Name()] = defect.Confidence
+		}
+		r.Quality = append(r.Quality, quality)
+
+		if detected := page.ImageQuality.Detected(rescanConfidence); len(detected) > 0 {
+			defects := make([]string, len(detected))
+			for i, defect := range detected {
+				defects[i] = fmt.Sprintf("%s (%.2f)", defect.Name(), defect.Confidence)
+			}
+			cli.Warnf("Page %d may need a rescan, image quality %.2f: %s\n",
+				page.PageNumber, page.ImageQuality.Score, strings.Join(defects, ", "))
+			rescan = true
+		}
+	}
+	return rescan
+}
+
+// fatalf logs an error, records it in the report and exits with an error code
+func fatalf(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Print(message)
+	report.Error = message
+	cli.Exit(report, cli.ExitError)
+}
+
+// failStrict prints the error of existing OCR in strict mode, records it in
+// the report and exits with the strict mode exit code
+func failStrict(err error) {
+	fmt.Printf("Error: %v\n", err)
+	report.HasOCR = true
+	report.Error = strings.TrimPrefix(err.Error(), "Error: ")
+	cli.Exit(report, cli.ExitStrictOCRFailure)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+	"github.com/gardar/ocrchestra/pkg/gdocai"
+	"github.com/gardar/ocrchestra/pkg/hocr"
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// server exposes the OCR pipeline of gdocai over HTTP
+type server struct {
+	cfg          *gdocai.Config
+	pdfOcrConfig pdfocr.OCRConfig
+	maxUpload    int64 // Largest accepted PDF in bytes
+	detectLang   bool  // Detect missing page languages locally
+}
+
+// ocrResponse is the JSON body returned for a processed PDF
+type ocrResponse struct {
+	PDF              []byte                 `json:"pdf"` // The OCR'ed PDF, base64 encoded
+	Pages            int                    `json:"pages"`
+	HasOCR           bool                   `json:"has_ocr"` // Whether the uploaded PDF already had OCR
+	Text             string                 `json:"text"`
+	FormFields       map[string]interface{} `json:"form_fields"`
+	ExtractorFields  map[string]interface{} `json:"extractor_fields"`
+	NormalizedFields map[string]interface{} `json:"normalized_extractor_fields"` // Extractor fields with normalized values
+	Warnings         []string               `json:"warnings"`
+}
+
+// errorResponse is the JSON body returned when a request fails
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// runServe implements "gdocai serve", which runs an HTTP server until it is
+// interrupted and returns the exit code
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to the config YAML file (optional if using environment variables)")
+	addr := flags.String("addr", ":8080", "Address to listen on")
+	maxUploadMB := flags.Int64("max-upload-mb", 50, "Largest accepted PDF upload in megabytes")
+	lang := flags.String("lang", "", `Comma separated languages of the documents passed to Document AI as hints, e.g. "en,de" (overrides the config)`)
+	detectLang := flags.Bool("detect-lang", false, "Detect missing page languages locally and fill in the hOCR language tags")
+	encodingFallback := flags.String("encoding-fallback", string(pdfocr.EncodingFallbackTransliterate),
+		"How to render words the OCR font can't encode: transliterate, replace or skip")
+	unicodeFont := flags.String("unicode-font", "",
+		"TrueType font to embed for pages with text outside Windows-1252 (CJK, Arabic, Cyrillic, ...)")
+	var scriptFonts pdfocr.FontConfig
+	flags.Var(scriptFontFlag{&scriptFonts}, "script-font",
+		"Draw words of a script with this TrueType font where it has their glyphs, as script=path (scripts: latin, cyrillic, cjk, arabic; repeatable, first listed preferred)")
+	strict := flags.Bool("strict", false, "Reject PDFs that already have OCR with 409 Conflict")
+	force := flags.Bool("force", false, "Process PDFs even if OCR is already detected")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s serve:\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "  %s serve -config config.yml [-addr :8080]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Options:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\nEndpoints:\n")
+		fmt.Fprintf(flags.Output(), "  POST /v1/ocr  PDF as the request body or a multipart \"file\" field; returns the OCR'ed PDF and extracted fields as JSON\n")
+		fmt.Fprintf(flags.Output(), "  GET /healthz  Returns 200 when the server is up\n")
+	}
+	flags.Parse(args)
+
+	fallback, err := pdfocr.ParseEncodingFallback(*encodingFallback)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return cli.ExitError
+	}
+	cfg, err := loadConfig(*configPath)
+	if err == nil {
+		err = requireProcessor(cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+		return cli.ExitError
+	}
+	if *lang != "" {
+		cfg.LanguageHints = splitList(*lang)
+	}
+
+	// Share one Document AI client between all requests
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	client, err := gdocai.NewClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return cli.ExitError
+	}
+	defer client.Close()
+	cfg.Client = client
+
+	s := &server{
+		cfg: cfg,
+		pdfOcrConfig: pdfocr.OCRConfig{
+			Force:     *force,
+			Strict:    *strict,
+			StartPage: 1,
+			Font:      pdfocr.DefaultFont,
+			LayerName: "OCR Text",
+
+			EncodingFallback:     fallback,
+			ReplacementChar:      "?",
+			FailOnEncodingIssues: *strict && !*force,
+		},
+		maxUpload:  *maxUploadMB << 20,
+		detectLang: *detectLang,
+	}
+	s.pdfOcrConfig.Font.UnicodeFontPath = *unicodeFont
+	s.pdfOcrConfig.Font.ScriptFonts = scriptFonts.ScriptFonts
+	s.pdfOcrConfig.Provenance.ProcessorID = cfg.ProcessorID
+	s.pdfOcrConfig.Provenance.ProcessorVersion = cfg.ProcessorVersion
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/ocr", s.handleOCR)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	httpServer := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	// Finish the requests in progress on SIGINT or SIGTERM
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Listening on %s", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Server failed: %v", err)
+		return cli.ExitError
+	}
+	return cli.ExitSuccess
+}
+
+// handleOCR processes an uploaded PDF with Document AI and responds with the
+// OCR'ed PDF and the extracted fields
+func (s *server) handleOCR(w http.ResponseWriter, r *http.Request) {
+	pdfBytes, err := s.readUpload(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: fmt.Sprintf("PDF is larger than %d bytes", s.maxUpload)})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if !bytes.HasPrefix(pdfBytes, []byte("%PDF-")) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "upload is not a PDF"})
+		return
+	}
+
+	// Record the warnings of this request separately from the others
+	events := cli.NewEventRecorder(conditions)
+	config := s.pdfOcrConfig
+	config.LogWarnings = false
+	config.EventLogger = slog.New(events)
+
+	detection, err := pdfocr.DetectOCR(pdfBytes, config)
+	hasOCR := err == nil && detection.HasOCR
+	if hasOCR && config.Strict && !config.Force {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: pdfocr.ErrAlreadyHasOCR.Error()})
+		return
+	}
+
+	// Scans that can't be preprocessed are sent as they are
+	if processed, err := gdocai.Preprocess(pdfBytes, s.cfg); err != nil {
+		events.Record(pdfocr.EventWarning, fmt.Sprintf("not preprocessing the PDF: %v", err))
+	} else {
+		pdfBytes = processed
+	}
+
+	doc, _, err := gdocai.DocumentHOCR(r.Context(), pdfBytes, s.cfg)
+	if err != nil {
+		log.Printf("%s %s: Document AI failed: %v", r.Method, r.URL.Path, err)
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: fmt.Sprintf("error processing document: %v", err)})
+		return
+	}
+	if doc.Hocr == nil || doc.Hocr.Content == nil {
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: "HOCR content not available for creating searchable PDF"})
+		return
+	}
+	if s.detectLang {
+		hocr.DetectLanguages(doc.Hocr.Content)
+	}
+
+	result, err := pdfocr.ApplyOCRWithResultContext(r.Context(), pdfBytes, doc.Hocr.Content, config)
+	if err != nil {
+		log.Printf("%s %s: applying OCR failed: %v", r.Method, r.URL.Path, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("failed to apply OCR to PDF: %v", err)})
+		return
+	}
+
+	response := ocrResponse{
+		PDF:      result.PDF,
+		Pages:    len(doc.Hocr.Content.Pages),
+		HasOCR:   hasOCR,
+		Warnings: events.Warnings(),
+	}
+	if doc.Text != nil {
+		response.Text = doc.Text.Content
+	}
+	if doc.FormFields != nil {
+		response.FormFields = doc.FormFields.Fields
+	}
+	if doc.CustomExtractorFields != nil {
+		response.ExtractorFields = doc.CustomExtractorFields.Fields
+		response.NormalizedFields = doc.CustomExtractorFields.Normalized
+	}
+	log.Printf("%s %s: processed %d page(s)", r.Method, r.URL.Path, response.Pages)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// readUpload reads the PDF from a multipart "file" field or the raw request body
+func (s *server) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Type") != "" && r.Header.Get("Content-Type") != "application/pdf" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("expected a PDF request body or a multipart \"file\" field: %w", err)
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, pdfocr.ErrEmptyPDF
+	}
+	return data, nil
+}
+
+// writeJSON writes a JSON response with the status code
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gardar/ocrchestra/pkg/gdocai"
+	"github.com/gardar/ocrchestra/pkg/s3store"
+)
+
+// isObjectURI reports whether a path is a gs:// or s3:// object storage URI
+func isObjectURI(name string) bool {
+	return gdocai.IsGCSURI(name) || s3store.IsURI(name)
+}
+
+// readFile reads a local file, a gs:// Cloud Storage object or an s3:// object.
+// Cloud Storage is accessed with the credentials of cfg.
+func readFile(ctx context.Context, cfg *gdocai.Config, name string) ([]byte, error) {
+	switch {
+	case gdocai.IsGCSURI(name):
+		return gdocai.ReadObject(ctx, cfg, name)
+	case s3store.IsURI(name):
+		return s3store.ReadObject(ctx, name)
+	}
+	return os.ReadFile(name)
+}
+
+// writeFile writes a local file, a gs:// Cloud Storage object or an s3://
+// object. Objects get a content type matching their extension. Cloud Storage
+// is accessed with the credentials of cfg.
+func writeFile(ctx context.Context, cfg *gdocai.Config, name string, data []byte) error {
+	switch {
+	case gdocai.IsGCSURI(name):
+		return gdocai.WriteObject(ctx, cfg, name, data, mime.TypeByExtension(path.Ext(name)))
+	case s3store.IsURI(name):
+		return s3store.WriteObject(ctx, name, data, mime.TypeByExtension(path.Ext(name)))
+	}
+	return os.WriteFile(name, data, 0644)
+}
+
+// makeDir creates a local directory and its parents. Object storage has no
+// directories, so nothing is done for gs:// and s3:// URIs.
+func makeDir(dir string) error {
+	if isObjectURI(dir) {
+		return nil
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// joinPath joins a local directory or object storage URI prefix with a file name
+func joinPath(dir, name string) string {
+	if isObjectURI(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
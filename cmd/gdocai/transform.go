@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+)
+
+// dateLayouts are the date formats the dateformat modifier reads, tried in
+// order. Dates with slashes are read month first, like Document AI does.
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006/01/02",
+	"02.01.2006",
+	"01/02/2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// caseModifiers are the modifiers that set the case of a value, which keeps
+// the output file name from being lowercased
+var caseModifiers = map[string]bool{"upper": true, "lower": true, "title": true}
+
+// applyModifier transforms a placeholder value with a modifier such as
+// "upper", "dateformat:2006-01-02" or "regex:\d+"
+func applyModifier(value, modifier string) (string, error) {
+	name, arg, _ := strings.Cut(modifier, ":")
+	switch strings.TrimSpace(name) {
+	case "upper":
+		return strings.ToUpper(value), nil
+	case "lower":
+		return strings.ToLower(value), nil
+	case "title":
+		return titleCase(value), nil
+	case "trim":
+		return strings.TrimSpace(value), nil
+	case "dateformat":
+		if arg == "" {
+			return "", fmt.Errorf("dateformat needs a layout, e.g. dateformat:2006-01-02")
+		}
+		if value == "" {
+			return "", nil
+		}
+		for _, layout := range dateLayouts {
+			if date, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+				return date.Format(arg), nil
+			}
+		}
+		cli.Warnf("Warning: %q is not a date dateformat can read\n", value)
+		return "", nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid regex modifier: %w", err)
+		}
+		// The first capture group if there is one, the whole match otherwise
+		match := re.FindStringSubmatch(value)
+		switch {
+		case match == nil:
+			return "", nil
+		case len(match) > 1:
+			return match[1], nil
+		default:
+			return match[0], nil
+		}
+	default:
+		return "", fmt.Errorf("unknown placeholder modifier %q (expected upper, lower, title, trim, dateformat or regex)", name)
+	}
+}
+
+// titleCase capitalizes the first letter of every word and lowercases the rest
+func titleCase(s string) string {
+	runes := []rune(s)
+	start := true
+	for i, r := range runes {
+		if start {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+		start = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}
+	return string(runes)
+}
+
+// splitModifiers splits the contents of a placeholder at the | characters
+// that separate its modifiers. Escaped characters, such as \| in a regex,
+// don't split.
+func splitModifiers(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '|':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// placeholderEnd returns the index of the } closing a placeholder whose
+// contents start s, or -1. Braces inside it, as in regex:\d{4}, must be balanced.
+func placeholderEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
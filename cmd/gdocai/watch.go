@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+	"github.com/gardar/ocrchestra/pkg/gdocai"
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// watchMode keeps processing the PDFs that appear in a hot folder, such as
+// the inbox a scanner saves to, until it is interrupted
+type watchMode struct {
+	directoryMode
+	FailedDir  string        // Directory failed inputs are moved to
+	ArchiveDir string        // Directory processed inputs are moved to, or "" to delete them
+	Interval   time.Duration // How often the input directory is scanned
+}
+
+// fileState is the size and modification time of a file, used to tell
+// whether it is still being written
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// same reports whether a file is unchanged between two states
+func (s fileState) same(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// unmovedFile is a processed input that could not be moved out of the input
+// directory, kept so it is not sent to Document AI again
+type unmovedFile struct {
+	state fileState
+	ok    bool // Whether processing succeeded, which decides where it is moved
+}
+
+// run scans the input directory until SIGINT or SIGTERM is received. A PDF
+// is processed once its size and modification time are unchanged between two
+// scans, then moved out of the input directory. A PDF that can't be moved is
+// not processed again while it is unchanged; moving it is retried on every
+// scan instead. The PDF being processed when the signal arrives is finished
+// first.
+func (w watchMode) run(ctx context.Context, cfg *gdocai.Config, pdfOcrConfig pdfocr.OCRConfig) int {
+	stop, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	cli.Infof("Watching %s for PDF files every %s (press Ctrl+C to stop)\n", w.InputDir, w.Interval)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]fileState) // State of the files seen in the previous scan
+	unmoved := make(map[string]unmovedFile)
+	var succeeded, warned, failed int
+	for {
+		inputs, err := w.findPDFs()
+		if err != nil {
+			cli.Warnf("Warning: failed to list input directory: %v\n", err)
+		}
+
+		seen := make(map[string]fileState)
+		for _, input := range inputs {
+			if stop.Err() != nil {
+				break
+			}
+			info, err := os.Stat(filepath.Join(w.InputDir, input))
+			if err != nil {
+				continue
+			}
+			state := fileState{size: info.Size(), modTime: info.ModTime()}
+			if file, ok := unmoved[input]; ok {
+				if file.state.same(state) {
+					if err := w.moveInput(input, file.ok); err == nil {
+						delete(unmoved, input)
+					}
+					continue
+				}
+				// Replaced by a new document of the same name
+				delete(unmoved, input)
+			}
+			if previous, ok := pending[input]; !ok || !previous.same(state) {
+				// New or still being written, check again on the next scan
+				seen[input] = state
+				continue
+			}
+
+			cli.Infof("\n[%s] Processing %s\n", time.Now().Format(time.DateTime), input)
+			result := w.processFile(ctx, input, newWrittenOutputs(), cfg, pdfOcrConfig)
+			printDirectoryResult(result)
+			report.addFile(result)
+			switch {
+			case result.Err != nil:
+				failed++
+			case result.Warnings:
+				warned++
+			default:
+				succeeded++
+			}
+
+			if err := w.moveInput(input, result.Err == nil); err != nil {
+				// Keep watching, and keep moving the file rather than processing it again
+				cli.Warnf("Warning: failed to move %s out of the input directory: %v\n", input, err)
+				unmoved[input] = unmovedFile{state: state, ok: result.Err == nil}
+			}
+		}
+		pending = seen
+
+		// Forget unmoved files that were removed from the input directory
+		if err == nil {
+			listed := make(map[string]bool, len(inputs))
+			for _, input := range inputs {
+				listed[input] = true
+			}
+			for input := range unmoved {
+				if !listed[input] {
+					delete(unmoved, input)
+				}
+			}
+		}
+
+		select {
+		case <-stop.Done():
+			cli.Infof("\nStopped watching: %d succeeded, %d with warnings, %d failed\n", succeeded, warned, failed)
+			return cli.ExitSuccess
+		case <-ticker.C:
+		}
+	}
+}
+
+// moveInput moves a processed input to the archive directory, or deletes it
+// if there is none, and moves a failed input to the failed directory.
+// Subdirectories of the input are mirrored and existing files are not replaced.
+func (w watchMode) moveInput(input string, ok bool) error {
+	source := filepath.Join(w.InputDir, input)
+	dir := w.FailedDir
+	if ok {
+		if w.ArchiveDir == "" {
+			return os.Remove(source)
+		}
+		dir = w.ArchiveDir
+	}
+
+	target := uniquePath(filepath.Join(dir, input), nil)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return moveFile(source, target)
+}
+
+// moveFile renames a file, falling back to copying it when the target is on
+// another file system
+func moveFile(source, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return os.Remove(source)
+}
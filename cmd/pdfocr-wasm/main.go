@@ -0,0 +1,183 @@
+//go:build js && wasm
+
+// pdfocr-wasm exposes the pdfocr package to JavaScript so an OCR text layer can be
+// added to a scanned PDF entirely in the browser, without uploading the document.
+//
+// Build:
+//
+//	GOOS=js GOARCH=wasm go build -o pdfocr.wasm ./cmd/pdfocr-wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// After loading pdfocr.wasm with wasm_exec.js, these functions are available on the global object:
+//
+//	pdfocrApply(pdf: Uint8Array, hocr: string | Uint8Array, options?: object)
+//	  → { pdf: Uint8Array, warnings: string[] } or { error: string }
+//
+//	pdfocrDetect(pdf: Uint8Array)
+//	  → { hasOCR: boolean, layers: string[], warnings: string[], pagesNeedingOCR: number[], pagesWithOCR: number[] } or { error: string }
+//
+// Supported options (all optional):
+//
+//	layerName            Base name of the OCR layer (default "OCR Text")
+//	noLayers             Draw the invisible text straight into the pages, without optional content layers
+//	layerNameTemplate    Go template naming each page's layer, e.g. "{{.Base}} p{{.Page}} - {{.Engine}}"
+//	startPage            Start applying OCR from this page number (default 1)
+//	debug                Render visible text and bounding boxes
+//	force                Apply OCR even if an OCR layer is already present
+//	strict               Fail when an OCR layer is already present (unless force is set)
+//	skipPagesWithText    Apply OCR only to the pages without text, leaving the others as they are
+//	encodingFallback     "transliterate", "replace" or "skip"
+//	failOnEncodingIssues Fail when more than a tenth of a page's words needed an encoding fallback
+//	unicodeFont          TrueType font (Uint8Array) embedded for text outside Windows-1252
+//	password             Password to open an encrypted PDF (user or owner password)
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+func main() {
+	js.Global().Set("pdfocrApply", js.FuncOf(apply))
+	js.Global().Set("pdfocrDetect", js.FuncOf(detect))
+
+	// Keep the Go runtime alive so the exported functions stay callable
+	select {}
+}
+
+// apply implements pdfocrApply
+func apply(this js.Value, args []js.Value) any {
+	if len(args) < 2 {
+		return errorResult("pdfocrApply requires a PDF and hOCR data")
+	}
+
+	pdfData := bytesFromJS(args[0])
+	hocrData := bytesFromJS(args[1])
+
+	config := pdfocr.DefaultConfig()
+	config.LogWarnings = false
+	if len(args) > 2 && args[2].Type() == js.TypeObject {
+		if err := applyOptions(&config, args[2]); err != nil {
+			return errorResult(err.Error())
+		}
+	}
+
+	result, err := pdfocr.ApplyOCRWithResult(pdfData, hocrData, config)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	return map[string]any{
+		"pdf":      bytesToJS(result.PDF),
+		"warnings": stringsToJS(result.Warnings),
+	}
+}
+
+// detect implements pdfocrDetect
+func detect(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return errorResult("pdfocrDetect requires a PDF")
+	}
+
+	config := pdfocr.DefaultConfig()
+	config.LogWarnings = false
+
+	result, err := pdfocr.DetectOCR(bytesFromJS(args[0]), config)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	return map[string]any{
+		"hasOCR":          result.HasOCR,
+		"layers":          stringsToJS(result.LayerInfo.Layers),
+		"warnings":        stringsToJS(result.Warnings),
+		"pagesNeedingOCR": intsToJS(result.PagesNeedingOCR()),
+		"pagesWithOCR":    intsToJS(result.PagesWithOCR()),
+	}
+}
+
+// applyOptions copies the JavaScript options object onto the OCR config
+func applyOptions(config *pdfocr.OCRConfig, options js.Value) error {
+	if v := options.Get("layerName"); v.Type() == js.TypeString {
+		config.LayerName = v.String()
+	}
+	if v := options.Get("noLayers"); v.Type() == js.TypeBoolean {
+		config.NoLayers = v.Bool()
+	}
+	if v := options.Get("layerNameTemplate"); v.Type() == js.TypeString {
+		config.LayerNameTemplate = v.String()
+	}
+	if v := options.Get("startPage"); v.Type() == js.TypeNumber {
+		config.StartPage = v.Int()
+	}
+	if v := options.Get("debug"); v.Type() == js.TypeBoolean {
+		config.Debug = v.Bool()
+	}
+	if v := options.Get("force"); v.Type() == js.TypeBoolean {
+		config.Force = v.Bool()
+	}
+	if v := options.Get("strict"); v.Type() == js.TypeBoolean {
+		config.Strict = v.Bool()
+	}
+	if v := options.Get("skipPagesWithText"); v.Type() == js.TypeBoolean {
+		config.SkipPagesWithText = v.Bool()
+	}
+	if v := options.Get("encodingFallback"); v.Type() == js.TypeString {
+		fallback, err := pdfocr.ParseEncodingFallback(v.String())
+		if err != nil {
+			return err
+		}
+		config.EncodingFallback = fallback
+	}
+	if v := options.Get("failOnEncodingIssues"); v.Type() == js.TypeBoolean {
+		config.FailOnEncodingIssues = v.Bool()
+	}
+	if v := options.Get("unicodeFont"); v.Type() == js.TypeObject {
+		config.Font.UnicodeFontData = bytesFromJS(v)
+	}
+	if v := options.Get("password"); v.Type() == js.TypeString {
+		config.Password = v.String()
+	}
+	return nil
+}
+
+// bytesFromJS copies a Uint8Array (or string) into a Go byte slice
+func bytesFromJS(v js.Value) []byte {
+	if v.Type() == js.TypeString {
+		return []byte(v.String())
+	}
+	data := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(data, v)
+	return data
+}
+
+// bytesToJS copies a Go byte slice into a new Uint8Array
+func bytesToJS(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// stringsToJS converts a string slice into a JavaScript array
+func stringsToJS(values []string) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// intsToJS converts an int slice into a JavaScript array
+func intsToJS(values []int) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// errorResult wraps an error message in a result object
+func errorResult(message string) map[string]any {
+	return map[string]any{"error": message}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the names of the environment variables that set flags
+const envPrefix = "PDFOCR_"
+
+// commandLineFlags returns the names of the flags given on the command line
+func commandLineFlags() map[string]bool {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return explicit
+}
+
+// flagSetting holds the values a flag gets from the environment or a
+// configuration file
+type flagSetting struct {
+	source string   // Where the values come from, for errors
+	values []string // One value, or one for every time a repeatable flag is given
+}
+
+// environmentSettings returns the flags set with PDFOCR_* environment
+// variables, named after the flags in upper case with underscores for dashes
+// (PDFOCR_START_PAGE, PDFOCR_LAYER_NAME_TEMPLATE, ...). Flags that can be
+// repeated, such as -xmp, take one value per line.
+func environmentSettings() map[string]flagSetting {
+	settings := make(map[string]flagSetting)
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return
+		}
+		setting := flagSetting{source: name}
+		for _, line := range strings.Split(strings.TrimRight(value, "\n"), "\n") {
+			setting.values = append(setting.values, strings.TrimSuffix(line, "\r"))
+		}
+		settings[f.Name] = setting
+	})
+	return settings
+}
+
+// configFileSettings returns the flags set in a YAML configuration file. Its
+// keys are the flag names with underscores for dashes (start_page,
+// layer_name_template, ...), and its values the flag values: a list for flags
+// that can be repeated, such as xmp, and a mapping for key=value flags, such
+// as metadata.
+func configFileSettings(path string) (map[string]flagSetting, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	settings := make(map[string]flagSetting)
+	if len(root.Content) == 0 {
+		return settings, nil
+	}
+	mapping := root.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping of settings to values", path)
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, node := mapping.Content[i], mapping.Content[i+1]
+		name := strings.ReplaceAll(key.Value, "_", "-")
+		if name == "config" || flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, key.Value)
+		}
+		values, err := configValues(node)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, key.Value, err)
+		}
+		settings[name] = flagSetting{source: path + ": " + key.Value, values: values}
+	}
+	return settings, nil
+}
+
+// applySettings sets the flags not given on the command line from the
+// settings of each source, listed from the lowest precedence to the highest.
+// A flag takes the values of the highest source that sets it only, so a
+// repeatable flag set in the environment replaces the values of the file
+// rather than adding to them.
+func applySettings(explicit map[string]bool, sources ...map[string]flagSetting) error {
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || err != nil {
+			return
+		}
+		var setting flagSetting
+		for _, settings := range sources {
+			if s, ok := settings[f.Name]; ok {
+				setting = s
+			}
+		}
+		for _, value := range setting.values {
+			if setErr := flag.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s %q: %w", setting.source, value, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// configValues returns the flag values of a setting: the value of a scalar,
+// the items of a list, or key=value for each entry of a mapping in order
+func configValues(node *yaml.Node) ([]string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("expected a list of values")
+			}
+			values = append(values, item.Value)
+		}
+		return values, nil
+	case yaml.MappingNode:
+		values := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("expected a mapping of keys to values")
+			}
+			values = append(values, key.Value+"="+value.Value)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("expected a value, list or mapping")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestApplySettingsPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		wantLayer string
+		wantMerge []string
+	}{
+		{
+			name:      "file only",
+			wantLayer: "From file",
+			wantMerge: []string{"file1.pdf", "file2.pdf"},
+		},
+		{
+			name:      "environment over file",
+			env:       map[string]string{"PDFOCR_LAYER_NAME": "From environment", "PDFOCR_MERGE": "env.pdf"},
+			wantLayer: "From environment",
+			wantMerge: []string{"env.pdf"},
+		},
+		{
+			name:      "command line over both",
+			args:      []string{"-layer-name", "From flag", "-merge", "flag.pdf"},
+			env:       map[string]string{"PDFOCR_LAYER_NAME": "From environment", "PDFOCR_MERGE": "env.pdf"},
+			wantLayer: "From flag",
+			wantMerge: []string{"flag.pdf"},
+		},
+	}
+
+	config := filepath.Join(t.TempDir(), "pdfocr.yml")
+	if err := os.WriteFile(config, []byte("layer_name: From file\nmerge:\n  - file1.pdf\n  - file2.pdf\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(commandLine *flag.FlagSet) { flag.CommandLine = commandLine }(flag.CommandLine)
+			flag.CommandLine = flag.NewFlagSet("pdfocr", flag.ContinueOnError)
+			layerName := flag.String("layer-name", "", "")
+			var merge []string
+			flag.Var(mergeFlag{&merge}, "merge", "")
+			if err := flag.CommandLine.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			fileSettings, err := configFileSettings(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := applySettings(commandLineFlags(), fileSettings, environmentSettings()); err != nil {
+				t.Fatal(err)
+			}
+			if *layerName != tt.wantLayer {
+				t.Errorf("layer name = %q, want %q", *layerName, tt.wantLayer)
+			}
+			if !slices.Equal(merge, tt.wantMerge) {
+				t.Errorf("merge = %q, want %q", merge, tt.wantMerge)
+			}
+		})
+	}
+}
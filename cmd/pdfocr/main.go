@@ -7,34 +7,146 @@
 // Usage:
 //
 //	pdfocr -hocr document.hocr [options]
+//	pdfocr -engine tesseract -image-dir ./page_images -output document.pdf
 //	pdfocr -pdf document.pdf -check-ocr
+//	pdfocr -pdf document.pdf -remove-ocr -output document_clean.pdf
+//	pdfocr -hocr document.hocr -split-hocr ./pages
+//	pdfocr -pdf document.pdf -split-pdf ./parts
+//	pdfocr -merge a.pdf -merge b.pdf -output dossier.pdf
+//	pdfocr -pdf document.pdf -extract-hocr document.hocr
 //
 // Required flags:
 //
-//	-hocr string      Path to hOCR file (required except for -check-ocr)
+//	-hocr string      Path to hOCR, ALTO or PAGE XML file (required except for -check-ocr or -engine)
 //	-output string    Output PDF path (required except for -check-ocr)
 //
 // Input options (one required):
 //
 //	-pdf string       Path to existing PDF to enhance with OCR
 //	-image-dir string Directory containing page images to build a new PDF
+//	-tiff string      Multipage TIFF file with the page images to build a new PDF
+//
+// Multipage TIFF files in -image-dir are split into their pages as well, in
+// the order of the file names.
+//
+// Use "-" for -pdf or -hocr to read standard input, and for -output to write the
+// PDF to standard output. Messages are then written to standard error.
+//
+// -pdf, -hocr and -output also accept s3://bucket/key URIs to read from and write to
+// S3-compatible object storage. Credentials are resolved the standard AWS way
+// (AWS_ACCESS_KEY_ID, AWS_PROFILE, shared config files, instance roles, ...); set
+// AWS_ENDPOINT_URL_S3 to use a service such as MinIO.
 //
 // Processing options:
 //
 //	-start-page int   Start applying OCR from this page (default 1)
+//	-pages string     Apply the hOCR pages, in order, to just these PDF pages, e.g. "1-3,7,9-" (overrides -start-page)
 //	-debug            Enable debug mode (shows OCR bounding boxes)
+//	-heatmap          Color debug bounding boxes by OCR confidence, green to red, and print the confidence per page (implies -debug)
+//	-show-conf        Annotate debug bounding boxes with the OCR confidence value (implies -debug)
+//	-min-confidence float
+//	                  Leave words with a lower OCR confidence (0-100) out of the text layer
+//	-low-confidence-layer
+//	                  Draw the words below -min-confidence onto a separate hidden layer instead of dropping them
+//	-no-layers        Draw the invisible OCR text straight into the page content instead of onto optional content
+//	                  layers, for viewers and pipelines that mishandle them (-low-confidence-layer is ignored)
+//	-layer-name-template string
+//	                  Go template naming each page's OCR layer, e.g. "{{.Base}} p{{.Page}} - {{.Engine}}" (fields: Base,
+//	                  Page, Engine); also used to recognize the layers with -check-ocr, -remove-ocr and -replace
 //	-force            Force reapply OCR even if layer exists
 //	-strict           Error out when OCR detection fails or OCR already exists (unless Force is used)
+//	-replace          Strip an existing OCR layer and apply the new one in its place
+//	-skip-text-pages  Apply OCR only to the pages of -pdf without text, copying the pages that already have text as they are
 //	-overwrite        Overwrite output file if it exists
 //	-debug-pdf        Dump PDF structure for debugging
 //	-check-ocr        Check if the PDF already has OCR and exit
+//	-remove-ocr       Strip the existing OCR layer and invisible text from -pdf and write the result to -output
+//	-split-hocr string
+//	                  Split -hocr into standalone single-page hOCR files (page_<n>.hocr) in this directory and exit
+//	-merge string     Merge this PDF into -output after the ones listed before it, renumbering the OCR layers to the
+//	                  merged pages (repeatable)
+//	-split-pdf string
+//	                  Split -pdf into one PDF per page (page_<n>.pdf), or per -pages range (pages_<first>-<last>.pdf),
+//	                  in this directory and exit, keeping the OCR layer of every page
+//	-extract-hocr string
+//	                  Convert the existing text of a digitally created -pdf to hOCR with word positions, write it here and exit
+//	-detect-lang      Detect missing page languages locally and fill in the hOCR language tags
+//	-encoding-fallback string
+//	                  How to render words the OCR font can't encode: transliterate, replace or skip (default "transliterate")
+//	-unicode-font string
+//	                  TrueType font embedded for pages with text outside Windows-1252 (CJK, Arabic, Cyrillic, ...)
+//	-script-font script=path
+//	                  Draw words of a script (latin, cyrillic, cjk or arabic) with this TrueType font where it
+//	                  has their glyphs (repeatable; fonts listed first are preferred)
+//	-fit-height       Size each word's font to the height of its box and scale the glyphs to its width, so
+//	                  selections match tall headings and small footnotes
+//	-min-font-size float
+//	                  Smallest font size in points -fit-height may choose (0 for no limit)
+//	-max-font-size float
+//	                  Largest font size in points -fit-height may choose (0 for no limit)
+//	-verify-text      Extract the text layer from the output and check it matches the hOCR exactly
+//	-sidecar string   Also write the recognized text to this file, pages separated by form feeds (as ocrmypdf does)
+//	-metadata key=value
+//	                  Set a document information entry of the output: title, author, subject, keywords, creator or
+//	                  producer (repeatable). The metadata of -pdf is kept otherwise.
+//	-xmp property=value
+//	                  Set an XMP property of the output, as prefix:name=value for the dc, pdf, xmp, xmpRights,
+//	                  xmpMM, photoshop and pdfaid namespaces or {uri}prefix:name=value for others (repeatable)
+//	-keep-annotations Copy the links, highlights and comments of -pdf to the output (form fields are always kept)
+//	-password string  Password to open an encrypted -pdf (user or owner password); the output is not encrypted
+//	-image-dpi float  Downsample the page images to this resolution before embedding them
+//	-image-source-dpi float
+//	                  Resolution of page images that don't state one in their header (default 300)
+//	-image-quality int
+//	                  Re-encode the page images as JPEG with this quality (1-100) where that makes them smaller
+//	-compress-bitonal Embed black-and-white page images as CCITT Group 4, which keeps scanned archives small
+//	-json string      Write a JSON report of the run to this file, or - for standard output
+//	-warn-on string   Conditions that exit with code 2, comma separated or none (default "existing-ocr,warning"):
+//	                  existing-ocr, warning, encoding-fallback, low-confidence
+//	-fail-on string   Conditions that exit with code 3 although the output was written (same conditions)
+//
+// OCR engine options:
+//
+//	-engine string    Run OCR locally instead of reading -hocr; supported: tesseract (requires -image-dir or -tiff)
+//	-ocr-lang string  Languages for the OCR engine, e.g. eng+deu (default "eng")
+//
+// Output options:
+//
+//	-quiet            Print errors only, for cron jobs and scripts (same as -log-level error)
+//	-verbose          Print debug details as well (same as -log-level debug)
+//	-log-level string How much to print: error, warn, info or debug (default info; overrides -quiet and -verbose)
+//
+// Configuration:
+//
+//	-config string    YAML file with default values for the other flags
+//
+// The keys of the configuration file are the flag names with underscores for
+// dashes. Flags that can be repeated take a list, and -metadata and
+// -script-font a mapping. Flags given on the command line override the file:
+//
+//	layer_name_template: "{{.Base}} p{{.Page}}"
+//	unicode_font: /usr/share/fonts/truetype/dejavu/DejaVuSans.ttf
+//	start_page: 2
+//	strict: true
+//	debug: false
+//	metadata:
+//	  creator: Scanning department
+//	xmp:
+//	  - dc:rights=CC-BY-4.0
+//
+// Every flag can also be set with a PDFOCR_* environment variable named after
+// it in upper case with underscores for dashes, e.g. PDFOCR_START_PAGE=2 or
+// PDFOCR_CONFIG=pdfocr.yml. Flags that can be repeated take one value per
+// line. The environment overrides the configuration file, and the command line
+// overrides both. A flag that can be repeated takes its values from one of
+// them only.
 //
 // Exit codes:
 //
 //	0 - Success (no warnings or errors)
 //	1 - Error (operation failed)
-//	2 - Success with warnings (including OCR already detected)
-//	3 - Error: OCR already detected in strict mode
+//	2 - Success with warnings (the -warn-on conditions, by default existing OCR and warnings)
+//	3 - Error: OCR already detected in strict mode, or a -fail-on condition was met
 //
 // Examples:
 //
@@ -42,79 +154,264 @@
 //
 //	pdfocr -hocr document.hocr -pdf document.pdf -output document_searchable.pdf
 //
+// Replace the OCR layer of a PDF that was OCR'd before:
+//
+//	pdfocr -hocr document.hocr -pdf document.pdf -output document_searchable.pdf -replace
+//
+// Add OCR layer to the scanned pages of a partially scanned PDF:
+//
+//	pdfocr -hocr scanned_pages.hocr -pdf document.pdf -pages 1-3,7,9- -output document_searchable.pdf
+//
+// Or OCR every page and let the pages that already have text keep it:
+//
+//	pdfocr -hocr all_pages.hocr -pdf report.pdf -skip-text-pages -output report_searchable.pdf
+//
+// Write the recognized text to a sidecar file next to the output PDF:
+//
+//	pdfocr -hocr document.hocr -pdf document.pdf -output document_searchable.pdf -sidecar document_searchable.txt
+//
 // Create PDF from image directory with OCR:
 //
 //	pdfocr -hocr document.hocr -image-dir ./page_images -output document_searchable.pdf
 //
+// Create PDF from a multipage TIFF scan with OCR:
+//
+//	pdfocr -hocr document.hocr -tiff scan.tif -output document_searchable.pdf
+//
 // Check if a PDF already has OCR:
 //
 //	pdfocr -pdf document.pdf -check-ocr
+//
+// Strip a bad OCR layer before reprocessing:
+//
+//	pdfocr -pdf document.pdf -remove-ocr -output document_clean.pdf
+//
+// Split a multi-page hOCR file into one file per page:
+//
+//	pdfocr -hocr document.hocr -split-hocr ./pages
+//
+// Split an OCR'd PDF into one file per chapter, keeping the OCR layers:
+//
+//	pdfocr -pdf document.pdf -split-pdf ./parts -pages 1-12,13-40,41-
+//
+// Merge documents OCR'd one by one into a dossier, keeping their OCR layers:
+//
+//	pdfocr -merge letter.pdf -merge invoice.pdf -merge receipt.pdf -output dossier.pdf
+//
+// Convert the text of a digitally created PDF to hOCR, without OCR:
+//
+//	pdfocr -pdf document.pdf -extract-hocr document.hocr
+//
+// Use pdfocr in a pipeline, without temporary files:
+//
+//	scan-to-pdf | pdfocr -pdf - -hocr document.hocr -output - | upload
+//
+// Read from and write to S3:
+//
+//	pdfocr -pdf s3://scans/document.pdf -hocr s3://scans/document.hocr -output s3://searchable/document.pdf
+//
+// OCR page images with a local Tesseract installation and build a searchable PDF:
+//
+//	pdfocr -engine tesseract -ocr-lang eng -image-dir ./page_images -output document_searchable.pdf
+//
+// Use the settings of a configuration file:
+//
+//	pdfocr -config pdfocr.yml -hocr document.hocr -pdf document.pdf -output document_searchable.pdf
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/gardar/ocrchestra/internal/cli"
+	"github.com/gardar/ocrchestra/pkg/hocr"
+	"github.com/gardar/ocrchestra/pkg/ocrengine"
 	"github.com/gardar/ocrchestra/pkg/pdfocr"
+	"github.com/gardar/ocrchestra/pkg/s3store"
 )
 
-// Exit code constants for the CLI
-const (
-	exitSuccess          = 0 // Success with no warnings
-	exitError            = 1 // Error, operation failed
-	exitSuccessWithWarns = 2 // Success but with warnings
-	exitStrictOCRFailure = 3 // OCR already present in strict mode
-)
+// readInput reads the file at path, the object at an s3:// URI, or standard
+// input if the path is "-"
+func readInput(path string) ([]byte, error) {
+	if path == cli.Stdio {
+		return io.ReadAll(os.Stdin)
+	}
+	if s3store.IsURI(path) {
+		return s3store.ReadObject(context.Background(), path)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput writes data to the file at path, the object at an s3:// URI, or
+// standard output if the path is "-"
+func writeOutput(path string, data []byte) error {
+	if path == cli.Stdio {
+		_, err := cli.StandardOutput.Write(data)
+		return err
+	}
+	if s3store.IsURI(path) {
+		return s3store.WriteObject(context.Background(), path, data, http.DetectContentType(data))
+	}
+	return os.WriteFile(path, data, 0666)
+}
+
+// writeSidecar writes the text of the hOCR to path, one page after the other
+// separated by form feeds like the sidecar files of ocrmypdf
+func writeSidecar(path string, hOCR interface{}) error {
+	doc, ok := hOCR.(*hocr.HOCR)
+	if !ok {
+		parsed, err := hocr.Parse(hOCR.([]byte))
+		if err != nil {
+			return fmt.Errorf("failed to parse HOCR: %w", err)
+		}
+		doc = &parsed
+	}
 
-// warningWriter captures warnings written to the logger
-type warningWriter struct {
-	buf    bytes.Buffer
-	target io.Writer // Usually os.Stdout
+	pages := make([]string, 0, len(doc.Pages))
+	for _, page := range hocr.SplitPages(doc) {
+		lines := strings.Split(strings.TrimRight(hocr.ExtractHOCRText(page), "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " ")
+		}
+		pages = append(pages, strings.Join(lines, "\n")+"\n")
+	}
+	return writeOutput(path, []byte(strings.Join(pages, "\f")))
 }
 
-func newWarningWriter(target io.Writer) *warningWriter {
-	return &warningWriter{
-		target: target,
+// splitImage returns the pages of a multipage TIFF file, or the image itself
+// for other formats
+func splitImage(path string, data []byte) [][]byte {
+	if !pdfocr.IsTIFF(data) {
+		return [][]byte{data}
 	}
+	pages, err := pdfocr.SplitTIFF(data)
+	if err != nil {
+		fail(cli.ExitError, "Failed to split TIFF file %s: %v", path, err)
+	}
+	cli.Infof("Split %d page(s) from TIFF file %s\n", len(pages), path)
+	return pages
 }
 
-func (w *warningWriter) Write(p []byte) (n int, err error) {
-	// Write to both the buffer (for tracking) and the target (for display)
-	w.buf.Write(p)
-	return w.target.Write(p)
+// printPageConfidence prints the word confidence of each page for reviewing
+// the OCR quality alongside the heat-map
+func printPageConfidence(pages []pdfocr.PageConfidence) {
+	cli.Infof("Word confidence per page:\n")
+	for _, page := range pages {
+		if page.Words == 0 {
+			cli.Infof("  Page %d: no confidence values\n", page.Page)
+			continue
+		}
+		cli.Infof("  Page %d: mean %.1f, lowest %.1f over %d word(s)\n", page.Page, page.Mean, page.Min, page.Words)
+	}
 }
 
-// HasWarnings checks if any warnings were logged
-func (w *warningWriter) HasWarnings() bool {
-	return strings.Contains(w.buf.String(), "Warning:")
+// displayPath describes a path in messages, naming "-" after the standard stream it selects
+func displayPath(path, stream string) string {
+	if path == cli.Stdio {
+		return stream
+	}
+	return path
 }
 
-// HasOCRWarning specifically checks if OCR already exists warning was logged
-func (w *warningWriter) HasOCRWarning() bool {
-	return strings.Contains(w.buf.String(), "already has OCR")
+// checkOutputPath exits if the output file exists and may not be overwritten
+func checkOutputPath(path string, overwrite bool) {
+	if path == cli.Stdio || overwrite {
+		return
+	}
+	if s3store.IsURI(path) {
+		exists, err := s3store.Exists(context.Background(), path)
+		if err != nil {
+			fail(cli.ExitError, "Error: %v", err)
+		}
+		if exists {
+			fail(cli.ExitError, "Output file %s already exists. Use -overwrite to overwrite.", path)
+		}
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		fail(cli.ExitError, "Output file %s already exists. Use -overwrite to overwrite.", path)
+	}
 }
 
 func main() {
 	// Define command-line flags
-	hocrPath := flag.String("hocr", "", "Path to a multi-page HOCR file")
-	imageDirPath := flag.String("image-dir", "", "Directory containing images")
-	pdfPath := flag.String("pdf", "", "Path to an existing PDF to add OCR layer to")
-	pdfOcrPath := flag.String("output", "", "Output PDF path")
+	hocrPath := flag.String("hocr", "", "Path to a multi-page HOCR file (ALTO and PAGE XML are also accepted), or - for standard input")
+	imageDirPath := flag.String("image-dir", "", "Directory containing images (multipage TIFF files are split into their pages)")
+	tiffPath := flag.String("tiff", "", "Multipage TIFF file with the page images to build a new PDF from, as many scanners produce")
+	pdfPath := flag.String("pdf", "", "Path to an existing PDF to add OCR layer to, or - for standard input")
+	pdfOcrPath := flag.String("output", "", "Output PDF path, or - for standard output (messages then go to standard error)")
 	startPage := flag.Int("start-page", 1, "Start applying OCR from this page number (1-based index)")
+	pages := flag.String("pages", "", "Apply the hOCR pages, in order, to just these PDF pages, e.g. \"1-3,7,9-\" (overrides -start-page)")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	heatmap := flag.Bool("heatmap", false, "Color debug bounding boxes by OCR confidence, green to red, and print the confidence per page (implies -debug)")
+	showConf := flag.Bool("show-conf", false, "Annotate debug bounding boxes with the OCR confidence value (implies -debug)")
 	force := flag.Bool("force", false, "Force reapply OCR even if an OCR layer is already detected")
 	strict := flag.Bool("strict", false, "Error out when OCR detection fails or OCR already exists (unless Force is used)")
+	replace := flag.Bool("replace", false, "Strip an existing OCR layer and apply the new one in its place")
+	skipTextPages := flag.Bool("skip-text-pages", false, "Apply OCR only to the pages of -pdf without text, such as a scanned appendix, copying the pages that already have text as they are")
 	overwriteOutput := flag.Bool("overwrite", false, "Overwrite the output PDF if it already exists")
 	dumpPDF := flag.Bool("debug-pdf", false, "Dump PDF structure for debugging")
 	checkOCR := flag.Bool("check-ocr", false, "Check if the PDF already has OCR and exit")
+	removeOCR := flag.Bool("remove-ocr", false, "Strip the existing OCR layer and invisible text from the PDF and write the result to -output")
+	splitHOCR := flag.String("split-hocr", "", "Split -hocr into standalone single-page hOCR files (page_<n>.hocr) in this directory and exit")
+	var mergePaths []string
+	flag.Var(mergeFlag{&mergePaths}, "merge",
+		"Merge this PDF into -output after the ones listed before it, renumbering the OCR layers to the merged pages (repeatable)")
+	splitPDF := flag.String("split-pdf", "", "Split -pdf into one PDF per page (page_<n>.pdf), or per -pages range (pages_<first>-<last>.pdf), in this directory and exit, keeping the OCR layer of every page")
+	extractHOCR := flag.String("extract-hocr", "", "Convert the existing text of a digitally created -pdf to hOCR with word positions, write it to this file (or - for standard output) and exit")
+	detectLang := flag.Bool("detect-lang", false, "Detect missing page languages locally and fill in the hOCR language tags")
+	encodingFallback := flag.String("encoding-fallback", string(pdfocr.EncodingFallbackTransliterate),
+		"How to render words the OCR font can't encode: transliterate, replace or skip")
+	fontName := flag.String("font", pdfocr.DefaultFont.Name, "Standard PDF font for the OCR text: Helvetica, Times or Courier")
+	unicodeFont := flag.String("unicode-font", "",
+		"TrueType font to embed for pages with text outside Windows-1252 (CJK, Arabic, Cyrillic, ...)")
+	var scriptFonts pdfocr.FontConfig
+	flag.Var(scriptFontFlag{&scriptFonts}, "script-font",
+		"Draw words of a script with this TrueType font where it has their glyphs, as script=path (scripts: latin, cyrillic, cjk, arabic; repeatable, first listed preferred)")
+	fitHeight := flag.Bool("fit-height", false, "Size each word's font to the height of its box and scale the glyphs to its width")
+	minFontSize := flag.Float64("min-font-size", 0, "Smallest font size in points -fit-height may choose (0 for no limit)")
+	maxFontSize := flag.Float64("max-font-size", 0, "Largest font size in points -fit-height may choose (0 for no limit)")
+	minConfidence := flag.Float64("min-confidence", 0, "Leave words with a lower OCR confidence (0-100) out of the text layer")
+	lowConfidenceLayer := flag.Bool("low-confidence-layer", false, "Draw the words below -min-confidence onto a separate hidden layer instead of dropping them")
+	noLayers := flag.Bool("no-layers", false, "Draw the invisible OCR text straight into the page content instead of onto optional content layers")
+	layerName := flag.String("layer-name", pdfocr.DefaultConfig().LayerName, `Base name of the OCR layers, followed by " (Page N)" on each page`)
+	layerNameTemplate := flag.String("layer-name-template", "",
+		`Go template naming each page's OCR layer, e.g. "{{.Base}} p{{.Page}} - {{.Engine}}" (fields: Base, Page, Engine)`)
+	verifyText := flag.Bool("verify-text", false, "Extract the text layer from the output and check it matches the hOCR exactly")
+	sidecar := flag.String("sidecar", "", "Also write the recognized text to this file, pages separated by form feeds (as ocrmypdf does)")
+	var metadata pdfocr.Metadata
+	flag.Var(metadataFlag{&metadata}, "metadata",
+		"Set a document information entry of the output as key=value, e.g. title=Invoice (keys: title, author, subject, keywords, creator, producer; repeatable)")
+	flag.Var(xmpFlag{&metadata}, "xmp",
+		"Set an XMP property of the output as prefix:name=value, e.g. dc:rights=CC-BY-4.0, or {namespace-uri}prefix:name=value for a custom namespace (repeatable)")
+	keepAnnotations := flag.Bool("keep-annotations", false, "Copy the links, highlights and comments of -pdf to the output (form fields are always kept)")
+	password := flag.String("password", "", "Password to open an encrypted -pdf, as its user or owner password (the output is not encrypted)")
+	imageDPI := flag.Float64("image-dpi", 0, "Downsample the -image-dir or -tiff images to this resolution before embedding them (0 keeps every pixel)")
+	imageSourceDPI := flag.Float64("image-source-dpi", pdfocr.DefaultImageSourceDPI, "Resolution of -image-dir or -tiff images that don't state one in their header, for -image-dpi")
+	imageQuality := flag.Int("image-quality", 0, "Re-encode the -image-dir or -tiff images as JPEG with this quality (1-100) where that makes them smaller")
+	compressBitonal := flag.Bool("compress-bitonal", false, "Embed black-and-white -image-dir or -tiff images as CCITT Group 4, which keeps scanned archives small")
+	jsonReport := flag.String("json", "", "Write a JSON report of the run (inputs, outputs, OCR detected, warnings, pages, timing, exit reason) to this file, or - for standard output")
 
-	// Update the usage to include the exit codes
+	engineName := flag.String("engine", "", "Run OCR locally on the -image-dir or -tiff images instead of reading -hocr (supported: tesseract)")
+	ocrLang := flag.String("ocr-lang", "eng", "Languages for the OCR engine, e.g. eng+deu")
+	configPath := flag.String("config", "", "YAML file with default flag values, e.g. \"start_page: 2\" (flags given on the command line win)")
+	quiet := flag.Bool("quiet", false, "Print errors only, for cron jobs and scripts (same as -log-level error)")
+	verbose := flag.Bool("verbose", false, "Print debug details as well (same as -log-level debug)")
+	logLevelName := flag.String("log-level", "", "How much to print: error, warn, info or debug (default info; overrides -quiet and -verbose)")
+	warnOn := flag.String("warn-on", cli.DefaultWarnOn,
+		"Conditions that exit with code 2: existing-ocr, warning, encoding-fallback, low-confidence (comma separated, or none)")
+	failOn := flag.String("fail-on", "",
+		"Conditions that exit with code 3 although the output was written: existing-ocr, warning, encoding-fallback, low-confidence")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -hocr document.hocr -pdf document.pdf -output document_searchable.pdf\n", os.Args[0])
@@ -124,209 +421,801 @@ func main() {
 		flag.PrintDefaults()
 
 		fmt.Fprintf(flag.CommandLine.Output(), "\nExit Codes:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success\n", exitSuccess)
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error\n", exitError)
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success with warnings (including OCR already detected)\n", exitSuccessWithWarns)
-		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error: OCR already detected in strict mode\n", exitStrictOCRFailure)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success\n", cli.ExitSuccess)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error\n", cli.ExitError)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Success with warnings (the -warn-on conditions)\n", cli.ExitSuccessWithWarns)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %d - Error: OCR already detected in strict mode, or a -fail-on condition was met\n", cli.ExitStrictOCRFailure)
 
 		fmt.Fprintf(flag.CommandLine.Output(), "\nExamples:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -hocr document.hocr -pdf document.pdf -output document_searchable.pdf\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -hocr document.hocr -image-dir ./page_images -output document_searchable.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -hocr document.hocr -tiff scan.tif -output document_searchable.pdf\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf document.pdf -check-ocr\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf document.pdf -remove-ocr -output document_clean.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -hocr document.hocr -split-hocr ./pages\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf document.pdf -split-pdf ./parts -pages 1-12,13-40,41-\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -merge letter.pdf -merge invoice.pdf -output dossier.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -pdf document.pdf -extract-hocr document.hocr\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  cat document.pdf | %s -pdf - -hocr document.hocr -output - > document_searchable.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -engine tesseract -image-dir ./page_images -output document_searchable.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -hocr document.hocr -image-dir ./page_images -image-dpi 150 -image-quality 75 -output document_small.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -config pdfocr.yml -hocr document.hocr -pdf document.pdf -output document_searchable.pdf\n", os.Args[0])
 	}
 
 	flag.Parse()
 
+	// Fill in the flags not given from the environment and the config file,
+	// which the environment overrides
+	explicit := commandLineFlags()
+	environment := environmentSettings()
+	var fileSettings map[string]flagSetting
+	path := *configPath
+	if setting, ok := environment["config"]; ok && !explicit["config"] {
+		path = setting.values[len(setting.values)-1]
+	}
+	if path != "" {
+		var err error
+		if fileSettings, err = configFileSettings(path); err != nil {
+			fmt.Printf("Error: failed to load config: %v\n", err)
+			os.Exit(cli.ExitError)
+		}
+	}
+	if err := applySettings(explicit, fileSettings, environment); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(cli.ExitError)
+	}
+	if err := cli.SetOutputLevel(*quiet, *verbose, *logLevelName); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(cli.ExitError)
+	}
+	var err error
+	if cli.Policy, err = cli.ParseExitPolicy(conditions, *warnOn, *failOn); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(cli.ExitError)
+	}
+	if *configPath != "" {
+		cli.Debugf("Loaded settings from %s\n", *configPath)
+	}
+
+	if (*pdfOcrPath == cli.Stdio || *extractHOCR == cli.Stdio) && *jsonReport == cli.Stdio {
+		fmt.Println("Error: the output and -json can't both be written to standard output")
+		os.Exit(cli.ExitError)
+	}
+	report.Path = *jsonReport
+
+	// Keep standard output for the output or report when it is written there
+	if *pdfOcrPath == cli.Stdio || *extractHOCR == cli.Stdio || *jsonReport == cli.Stdio {
+		os.Stdout = os.Stderr
+	}
+
+	// Confidence visualization is part of debug rendering
+	if *heatmap || *showConf {
+		*debug = true
+	}
+
 	// Mode for checking OCR
 	if *checkOCR {
-		handleCheckOCRMode(pdfPath, debug, dumpPDF)
+		handleCheckOCRMode(pdfPath, password, layerName, layerNameTemplate, debug, dumpPDF)
 		return // Don't proceed further
 	}
 
+	// Mode for stripping an existing OCR layer
+	if *removeOCR {
+		handleRemoveOCRMode(pdfPath, pdfOcrPath, password, layerName, layerNameTemplate, overwriteOutput)
+		return
+	}
+
+	// Mode for splitting an hOCR file into pages
+	if *splitHOCR != "" {
+		handleSplitHOCRMode(hocrPath, splitHOCR, overwriteOutput)
+		return
+	}
+
+	// Mode for merging PDFs into one
+	if len(mergePaths) > 0 {
+		handleMergeMode(mergePaths, pdfOcrPath, password, layerName, layerNameTemplate, overwriteOutput)
+		return
+	}
+
+	// Mode for splitting a PDF into parts
+	if *splitPDF != "" {
+		handleSplitPDFMode(pdfPath, splitPDF, pages, password, layerName, layerNameTemplate, overwriteOutput)
+		return
+	}
+
+	// Mode for converting the text of a PDF to hOCR
+	if *extractHOCR != "" {
+		handleExtractHOCRMode(pdfPath, extractHOCR, password, overwriteOutput)
+		return
+	}
+
 	// Handle normal OCR application mode
-	handleOCRApplicationMode(hocrPath, imageDirPath, pdfPath, pdfOcrPath, startPage,
-		debug, force, strict, overwriteOutput, dumpPDF)
+	handleOCRApplicationMode(hocrPath, imageDirPath, tiffPath, pdfPath, pdfOcrPath, startPage, pages,
+		debug, heatmap, showConf, force, strict, replace, overwriteOutput, dumpPDF, detectLang, verifyText, encodingFallback,
+		fontName, unicodeFont, engineName, ocrLang, minConfidence, lowConfidenceLayer, sidecar, &metadata, keepAnnotations, password,
+		imageDPI, imageSourceDPI, imageQuality, compressBitonal, fitHeight, minFontSize, maxFontSize, &scriptFonts, layerName, layerNameTemplate, noLayers, skipTextPages)
+}
+
+// metadataFlag sets the entries of a Metadata from repeated key=value flags
+type metadataFlag struct {
+	metadata *pdfocr.Metadata
+}
+
+func (f metadataFlag) String() string { return "" }
+
+func (f metadataFlag) Set(value string) error {
+	key, text, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, e.g. title=Invoice")
+	}
+	return f.metadata.SetInfo(key, text)
+}
+
+// xmpFlag sets XMP properties of a Metadata from repeated property=value flags
+type xmpFlag struct {
+	metadata *pdfocr.Metadata
+}
+
+func (f xmpFlag) String() string { return "" }
+
+func (f xmpFlag) Set(value string) error {
+	// The namespace URI of a {uri}prefix:name property may itself contain '='
+	start := 0
+	if strings.HasPrefix(value, "{") {
+		start = max(strings.Index(value, "}"), 0)
+	}
+	i := strings.Index(value[start:], "=")
+	if i < 0 {
+		return fmt.Errorf("expected property=value, e.g. dc:rights=CC-BY-4.0")
+	}
+	return f.metadata.SetXMP(value[:start+i], value[start+i+1:])
+}
+
+// scriptFontFlag adds TrueType fonts for a script from repeated script=path flags
+type scriptFontFlag struct {
+	font *pdfocr.FontConfig
+}
+
+func (f scriptFontFlag) String() string { return "" }
+
+func (f scriptFontFlag) Set(value string) error {
+	script, path, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected script=path, e.g. cyrillic=DejaVuSans.ttf")
+	}
+	return f.font.AddScriptFont(script, path)
+}
+
+// mergeFlag collects the PDFs to merge from repeated flags
+type mergeFlag struct {
+	paths *[]string
+}
+
+func (f mergeFlag) String() string { return "" }
+
+func (f mergeFlag) Set(value string) error {
+	*f.paths = append(*f.paths, value)
+	return nil
 }
 
 // handleCheckOCRMode handles the OCR detection mode
-func handleCheckOCRMode(pdfPath *string, debug, dumpPDF *bool) {
+func handleCheckOCRMode(pdfPath, password, layerName, layerNameTemplate *string, debug, dumpPDF *bool) {
+	report.Mode = "check-ocr"
+	report.AddInput(*pdfPath)
 	if *pdfPath == "" {
-		fmt.Println("Error: Must provide -pdf for OCR checking")
-		os.Exit(exitError)
+		fail(cli.ExitError, "Error: Must provide -pdf for OCR checking")
 	}
 
-	// Read the input PDF
-	inputData, err := os.ReadFile(*pdfPath)
+	inputData, err := readInput(*pdfPath)
 	if err != nil {
-		fmt.Printf("Failed to read input PDF: %v\n", err)
-		os.Exit(exitError)
+		fail(cli.ExitError, "Failed to read input PDF: %v", err)
 	}
 
-	// Create a warning writer to capture messages
-	warningCapture := newWarningWriter(os.Stdout)
-
 	// Configure OCR detection
 	config := pdfocr.DefaultConfig()
+	config.Logger = cli.LibraryOutput()
 	config.Debug = *debug
 	config.DumpPDF = *dumpPDF
-	config.Logger = warningCapture
+	config.Password = *password
+	config.LayerName = *layerName
+	config.LayerNameTemplate = *layerNameTemplate
 
 	// Perform OCR detection
 	ocrResult, err := pdfocr.DetectOCR(inputData, config)
 	if err != nil {
-		fmt.Printf("Error during OCR detection: %v\n", err)
-		os.Exit(exitError)
+		fail(cli.ExitError, "Error during OCR detection: %v", err)
+	}
+
+	report.HasOCR = ocrResult.HasOCR
+	report.OCRLayer = ocrResult.LayerInfo.OCRLayerName
+	report.Pages = len(ocrResult.Pages)
+	report.PagesWithOCR = ocrResult.PagesWithOCR()
+	report.Events = cli.NewEventRecorder(conditions)
+	for _, warning := range ocrResult.Warnings {
+		report.Events.Record(pdfocr.EventWarning, warning)
 	}
 
 	// Display the results
-	fmt.Printf("OCR Detection Results for %s:\n", *pdfPath)
-	fmt.Printf("Has OCR: %v\n", ocrResult.HasOCR)
+	cli.Infof("OCR Detection Results for %s:\n", displayPath(*pdfPath, "standard input"))
+	cli.Infof("Has OCR: %v\n", ocrResult.HasOCR)
 
 	if ocrResult.HasLayerOCR && ocrResult.LayerInfo.OCRLayerName != "" {
-		fmt.Printf("OCR Layer: %s\n", ocrResult.LayerInfo.OCRLayerName)
+		cli.Infof("OCR Layer: %s\n", ocrResult.LayerInfo.OCRLayerName)
+	} else if ocrResult.HasInvisibleTextOCR {
+		cli.Infof("OCR Text: invisible text without a layer\n")
+	}
+	if p := ocrResult.Provenance; p != nil {
+		cli.Infof("OCR Provenance: engine %q, processor %q version %q, added %s by ocrchestra %s\n",
+			p.Engine, p.ProcessorID, p.ProcessorVersion, p.Timestamp.Format(time.RFC3339), p.Version)
 	}
 
 	if len(ocrResult.LayerInfo.Layers) > 0 {
-		fmt.Println("\nDetected Layers:")
+		cli.Infof("\nDetected Layers:\n")
 		for i, layer := range ocrResult.LayerInfo.Layers {
-			fmt.Printf("  %d. %s\n", i+1, layer)
+			cli.Infof("  %d. %s\n", i+1, layer)
+		}
+	}
+
+	if len(ocrResult.Pages) > 0 {
+		cli.Infof("\nPages:\n")
+		for _, page := range ocrResult.Pages {
+			status := "no text"
+			switch {
+			case page.InvisibleText:
+				status = "invisible text (OCR)"
+			case page.HasText:
+				status = "text"
+			}
+			if page.NeedsOCR() {
+				status += ", needs OCR"
+			}
+			layers := ""
+			if len(page.Layers) > 0 {
+				layers = ", layers: " + strings.Join(page.Layers, ", ")
+			}
+			cli.Infof("  %d. %s, %d word(s), %.0f%% images, text/image area %.2f%s\n",
+				page.Page, status, page.WordCount, page.ImageCoverage*100, page.TextImageRatio, layers)
+			if len(page.Fonts) > 0 {
+				cli.Debugf("     fonts: %s\n", strings.Join(page.Fonts, ", "))
+			}
 		}
 	}
 
 	if len(ocrResult.Warnings) > 0 {
-		fmt.Println("\nWarnings:")
+		cli.Warnf("\nWarnings:\n")
 		for _, warning := range ocrResult.Warnings {
-			fmt.Printf("  - %s\n", warning)
+			cli.Warnf("  - %s\n", warning)
 		}
 	}
 
 	// Exit with appropriate code based on OCR detection
 	if ocrResult.HasOCR {
-		os.Exit(exitSuccessWithWarns)
+		cli.Exit(report, cli.ExitSuccessWithWarns)
 	} else {
-		os.Exit(exitSuccess)
+		cli.Exit(report, cli.ExitSuccess)
 	}
 }
 
-// handleOCRApplicationMode handles the main OCR application mode
-func handleOCRApplicationMode(hocrPath, imageDirPath, pdfPath, pdfOcrPath *string, startPage *int,
-	debug, force, strict, overwriteOutput, dumpPDF *bool) {
+// handleRemoveOCRMode handles the OCR removal mode
+func handleRemoveOCRMode(pdfPath, pdfOcrPath, password, layerName, layerNameTemplate *string, overwriteOutput *bool) {
+	report.Mode = "remove-ocr"
+	report.AddInput(*pdfPath)
+	if *pdfPath == "" {
+		fail(cli.ExitError, "Error: Must provide -pdf for OCR removal")
+	}
+	if *pdfOcrPath == "" {
+		fail(cli.ExitError, "Error: Must provide -output path")
+	}
+	checkOutputPath(*pdfOcrPath, *overwriteOutput)
 
-	// Validate required flags
+	inputData, err := readInput(*pdfPath)
+	if err != nil {
+		fail(cli.ExitError, "Failed to read input PDF: %v", err)
+	}
+
+	events := cli.NewEventRecorder(conditions)
+	report.Events = events
+	config := pdfocr.DefaultConfig()
+	config.Logger = cli.LibraryOutput()
+	config.EventLogger = slog.New(events)
+	config.Password = *password
+	config.LayerName = *layerName
+	config.LayerNameTemplate = *layerNameTemplate
+
+	result, err := pdfocr.RemoveOCRWithResult(inputData, config)
+	if err != nil {
+		fail(cli.ExitError, "Error removing OCR: %v", err)
+	}
+
+	if err := writeOutput(*pdfOcrPath, result.PDF); err != nil {
+		fail(cli.ExitError, "Failed to write output PDF: %v", err)
+	}
+	report.AddOutput(*pdfOcrPath)
+	report.HasOCR = result.LayersRemoved > 0 || result.TextObjectsRemoved > 0
+	report.Pages = result.PagesModified
+	cli.Infof("Removed %d OCR layer(s) and %d invisible text object(s) from %d page(s)\n",
+		result.LayersRemoved, result.TextObjectsRemoved, result.PagesModified)
+	cli.Infof("✅ PDF without OCR created: %s\n", displayPath(*pdfOcrPath, "standard output"))
+
+	cli.Finish(report, events)
+}
+
+// handleSplitHOCRMode handles splitting an hOCR file into single-page files
+func handleSplitHOCRMode(hocrPath, splitDir *string, overwriteOutput *bool) {
+	report.Mode = "split-hocr"
+	report.AddInput(*hocrPath)
 	if *hocrPath == "" {
-		fmt.Println("Error: Must provide -hocr path")
-		os.Exit(exitError)
+		fail(cli.ExitError, "Error: Must provide -hocr for splitting")
+	}
+
+	hOCRData, err := readInput(*hocrPath)
+	if err != nil {
+		fail(cli.ExitError, "Failed to read HOCR file: %v", err)
+	}
+	parsed, err := hocr.Parse(hOCRData)
+	if err != nil {
+		fail(cli.ExitError, "Failed to parse HOCR file: %v", err)
 	}
-	if *imageDirPath == "" && *pdfPath == "" {
-		fmt.Println("Error: Must provide either -image-dir or -pdf")
-		os.Exit(exitError)
+
+	if err := os.MkdirAll(*splitDir, 0755); err != nil {
+		fail(cli.ExitError, "Failed to create directory %s: %v", *splitDir, err)
+	}
+
+	parts := hocr.SplitPages(&parsed)
+	for i, part := range parts {
+		outputPath := filepath.Join(*splitDir, fmt.Sprintf("page_%d.hocr", i+1))
+		if _, err := os.Stat(outputPath); err == nil && !*overwriteOutput {
+			fail(cli.ExitError, "Output file %s already exists. Use -overwrite to overwrite.", outputPath)
+		}
+
+		content, err := hocr.GenerateHOCRDocument(part)
+		if err != nil {
+			fail(cli.ExitError, "Failed to generate HOCR for page %d: %v", i+1, err)
+		}
+		if err := os.WriteFile(outputPath, []byte(content), 0666); err != nil {
+			fail(cli.ExitError, "Failed to write %s: %v", outputPath, err)
+		}
+		report.AddOutput(outputPath)
+	}
+	report.Pages = len(parts)
+	cli.Infof("✅ Split %d page(s) into %s\n", len(parts), *splitDir)
+	cli.Exit(report, cli.ExitSuccess)
+}
+
+// handleMergeMode handles merging PDFs into one
+func handleMergeMode(paths []string, pdfOcrPath, password, layerName, layerNameTemplate *string, overwriteOutput *bool) {
+	report.Mode = "merge"
+	for _, path := range paths {
+		report.AddInput(path)
 	}
 	if *pdfOcrPath == "" {
-		fmt.Println("Error: Must provide -output path")
-		os.Exit(exitError)
+		fail(cli.ExitError, "Error: Must provide -output path")
 	}
+	checkOutputPath(*pdfOcrPath, *overwriteOutput)
 
-	if _, err := os.Stat(*pdfOcrPath); err == nil {
-		if !*overwriteOutput {
-			fmt.Printf("Output file %s already exists. Use -overwrite to overwrite.\n", *pdfOcrPath)
-			os.Exit(exitError)
+	var inputs [][]byte
+	for _, path := range paths {
+		data, err := readInput(path)
+		if err != nil {
+			fail(cli.ExitError, "Failed to read input PDF %s: %v", path, err)
 		}
+		inputs = append(inputs, data)
+	}
+
+	config := pdfocr.DefaultConfig()
+	config.Password = *password
+	config.LayerName = *layerName
+	config.LayerNameTemplate = *layerNameTemplate
+	merged, err := pdfocr.MergePDFs(inputs, config)
+	if err != nil {
+		fail(cli.ExitError, "Error merging PDFs: %v", err)
+	}
+
+	if err := writeOutput(*pdfOcrPath, merged); err != nil {
+		fail(cli.ExitError, "Failed to write output PDF: %v", err)
+	}
+	report.AddOutput(*pdfOcrPath)
+	report.Pages, _ = pdfocr.PageCount(merged)
+	cli.Infof("✅ Merged %d PDF(s) with %d page(s) into %s\n", len(paths), report.Pages, displayPath(*pdfOcrPath, "standard output"))
+	cli.Exit(report, cli.ExitSuccess)
+}
+
+// handleSplitPDFMode handles splitting a PDF into one file per page or page range
+func handleSplitPDFMode(pdfPath, splitDir, pages, password, layerName, layerNameTemplate *string, overwriteOutput *bool) {
+	report.Mode = "split-pdf"
+	report.AddInput(*pdfPath)
+	if *pdfPath == "" {
+		fail(cli.ExitError, "Error: Must provide -pdf for splitting")
+	}
+	var ranges []pdfocr.PageRange
+	if *pages != "" {
+		var err error
+		if ranges, err = pdfocr.ParsePageRanges(*pages); err != nil {
+			fail(cli.ExitError, "Error: %v", err)
+		}
+	}
+
+	inputData, err := readInput(*pdfPath)
+	if err != nil {
+		fail(cli.ExitError, "Failed to read input PDF: %v", err)
+	}
+
+	config := pdfocr.DefaultConfig()
+	config.Password = *password
+	config.LayerName = *layerName
+	config.LayerNameTemplate = *layerNameTemplate
+	parts, err := pdfocr.SplitPDF(inputData, ranges, config)
+	if err != nil {
+		fail(cli.ExitError, "Error splitting PDF: %v", err)
+	}
+	if len(ranges) == 0 {
+		for i := range parts {
+			ranges = append(ranges, pdfocr.PageRange{First: i + 1, Last: i + 1})
+		}
+	}
+	pageCount, _ := pdfocr.PageCount(inputData)
+
+	if err := os.MkdirAll(*splitDir, 0755); err != nil {
+		fail(cli.ExitError, "Failed to create directory %s: %v", *splitDir, err)
+	}
+	for i, part := range parts {
+		first, last := ranges[i].First, ranges[i].Last
+		if last == 0 {
+			last = pageCount
+		}
+		name := fmt.Sprintf("page_%d.pdf", first)
+		if last != first {
+			name = fmt.Sprintf("pages_%d-%d.pdf", first, last)
+		}
+		outputPath := filepath.Join(*splitDir, name)
+		if _, err := os.Stat(outputPath); err == nil && !*overwriteOutput {
+			fail(cli.ExitError, "Output file %s already exists. Use -overwrite to overwrite.", outputPath)
+		}
+		if err := os.WriteFile(outputPath, part, 0666); err != nil {
+			fail(cli.ExitError, "Failed to write %s: %v", outputPath, err)
+		}
+		report.AddOutput(outputPath)
+	}
+	report.Pages = pageCount
+	cli.Infof("✅ Split %d page(s) into %d PDF(s) in %s\n", pageCount, len(parts), *splitDir)
+	cli.Exit(report, cli.ExitSuccess)
+}
+
+// handleExtractHOCRMode handles converting the text of a digitally created PDF to hOCR
+func handleExtractHOCRMode(pdfPath, hocrOutputPath, password *string, overwriteOutput *bool) {
+	report.Mode = "extract-hocr"
+	report.AddInput(*pdfPath)
+	if *pdfPath == "" {
+		fail(cli.ExitError, "Error: Must provide -pdf for hOCR extraction")
+	}
+	checkOutputPath(*hocrOutputPath, *overwriteOutput)
+
+	inputData, err := readInput(*pdfPath)
+	if err != nil {
+		fail(cli.ExitError, "Failed to read input PDF: %v", err)
+	}
+
+	// ExtractHOCR reads the PDF as is, so open an encrypted one first
+	if inputData, err = pdfocr.DecryptPDF(inputData, *password); err != nil {
+		fail(cli.ExitError, "Failed to decrypt input PDF: %v", err)
+	}
+
+	doc, err := pdfocr.ExtractHOCR(inputData)
+	if err != nil {
+		fail(cli.ExitError, "Error extracting text: %v", err)
+	}
+	content, err := hocr.GenerateHOCRDocument(doc)
+	if err != nil {
+		fail(cli.ExitError, "Failed to generate HOCR: %v", err)
+	}
+	if err := writeOutput(*hocrOutputPath, []byte(content)); err != nil {
+		fail(cli.ExitError, "Failed to write HOCR: %v", err)
+	}
+	report.AddOutput(*hocrOutputPath)
+	report.Pages = len(doc.Pages)
+
+	// Pages without text still need OCR
+	var empty []string
+	for _, page := range doc.Pages {
+		if len(page.Paragraphs) == 0 {
+			empty = append(empty, fmt.Sprint(page.PageNumber))
+		}
+	}
+	if len(empty) > 0 {
+		cli.Warnf("Note: %d page(s) have no text and need OCR: %s\n", len(empty), strings.Join(empty, ", "))
+	}
+	cli.Infof("✅ Extracted the text of %d page(s) to hOCR: %s\n", len(doc.Pages), displayPath(*hocrOutputPath, "standard output"))
+	cli.Exit(report, cli.ExitSuccess)
+}
+
+// handleOCRApplicationMode handles the main OCR application mode
+func handleOCRApplicationMode(hocrPath, imageDirPath, tiffPath, pdfPath, pdfOcrPath *string, startPage *int, pages *string,
+	debug, heatmap, showConf, force, strict, replace, overwriteOutput, dumpPDF, detectLang, verifyText *bool, encodingFallback *string,
+	fontName, unicodeFont, engineName, ocrLang *string, minConfidence *float64, lowConfidenceLayer *bool, sidecarPath *string,
+	metadata *pdfocr.Metadata, keepAnnotations *bool, password *string,
+	imageDPI, imageSourceDPI *float64, imageQuality *int, compressBitonal, fitHeight *bool, minFontSize, maxFontSize *float64,
+	scriptFonts *pdfocr.FontConfig, layerName, layerNameTemplate *string, noLayers, skipTextPages *bool) {
+	// Page images are given as a directory or as a multipage TIFF file
+	imageInput := *imageDirPath != "" || *tiffPath != ""
+	report.Mode = "apply"
+	if imageInput {
+		report.Mode = "assemble"
+	}
+	report.AddInput(*pdfPath)
+	report.AddInput(*hocrPath)
+	report.AddInput(*imageDirPath)
+	report.AddInput(*tiffPath)
+
+	// Validate required flags
+	if *hocrPath == "" && *engineName == "" {
+		fail(cli.ExitError, "Error: Must provide -hocr path or an OCR -engine")
+	}
+	if *hocrPath != "" && *engineName != "" {
+		fail(cli.ExitError, "Error: -hocr and -engine are mutually exclusive")
+	}
+	if *engineName != "" && !imageInput {
+		fail(cli.ExitError, "Error: -engine requires -image-dir or -tiff")
+	}
+	var engine ocrengine.Engine
+	switch *engineName {
+	case "":
+	case "tesseract":
+		engine = ocrengine.NewTesseract(strings.Split(*ocrLang, "+")...)
+	default:
+		fail(cli.ExitError, "Error: unsupported OCR engine %q (supported: tesseract)", *engineName)
+	}
+	if !imageInput && *pdfPath == "" {
+		fail(cli.ExitError, "Error: Must provide either -image-dir, -tiff or -pdf")
+	}
+	if *imageDirPath != "" && *tiffPath != "" {
+		fail(cli.ExitError, "Error: -image-dir and -tiff are mutually exclusive")
+	}
+	if *pdfOcrPath == "" {
+		fail(cli.ExitError, "Error: Must provide -output path")
+	}
+	if *hocrPath == cli.Stdio && *pdfPath == cli.Stdio {
+		fail(cli.ExitError, "Error: Only one of -hocr and -pdf can be read from standard input")
+	}
+	if *sidecarPath == cli.Stdio {
+		fail(cli.ExitError, "Error: -sidecar must be a file")
+	}
+	fallback, err := pdfocr.ParseEncodingFallback(*encodingFallback)
+	if err != nil {
+		fail(cli.ExitError, "Error: %v", err)
+	}
+	var pageRanges []pdfocr.PageRange
+	if *pages != "" {
+		if *pdfPath == "" {
+			fail(cli.ExitError, "Error: -pages requires -pdf")
+		}
+		pageRanges, err = pdfocr.ParsePageRanges(*pages)
+		if err != nil {
+			fail(cli.ExitError, "Error: %v", err)
+		}
+	}
+
+	checkOutputPath(*pdfOcrPath, *overwriteOutput)
+	if *pdfOcrPath != cli.Stdio && !s3store.IsURI(*pdfOcrPath) {
 		os.Remove(*pdfOcrPath)
 	}
+	if *sidecarPath != "" {
+		checkOutputPath(*sidecarPath, *overwriteOutput)
+	}
 
-	// Create a warning writer to capture warnings
-	warningCapture := newWarningWriter(os.Stdout)
+	// Record warning events to pick the exit code
+	events := cli.NewEventRecorder(conditions)
+	report.Events = events
 
 	// Build the OCRConfig
 	config := pdfocr.DefaultConfig()
+	config.Logger = cli.LibraryOutput()
 	config.Debug = *debug
+	config.Heatmap = *heatmap
+	config.ShowConfidence = *showConf
 	config.Force = *force
 	config.Strict = *strict
+	config.FailOnEncodingIssues = *strict && !*force
+	config.Replace = *replace
 	config.StartPage = *startPage
+	config.PageRanges = pageRanges
 	config.DumpPDF = *dumpPDF
-	config.Logger = warningCapture
+	config.EventLogger = slog.New(events)
+	config.EncodingFallback = fallback
+	config.Font.Name = *fontName
+	config.Font.UnicodeFontPath = *unicodeFont
+	config.Font.ScriptFonts = scriptFonts.ScriptFonts
+	config.Font.FitHeight = *fitHeight
+	config.Font.MinSize = *minFontSize
+	config.Font.MaxSize = *maxFontSize
+	config.MinWordConfidence = *minConfidence
+	config.LowConfidenceLayer = *lowConfidenceLayer
+	config.LayerName = *layerName
+	config.LayerNameTemplate = *layerNameTemplate
+	config.NoLayers = *noLayers
+	config.SkipPagesWithText = *skipTextPages
+	config.Metadata = *metadata
+	config.KeepAnnotations = *keepAnnotations
+	config.Password = *password
+	config.ImageDPI = *imageDPI
+	config.ImageSourceDPI = *imageSourceDPI
+	config.ImageQuality = *imageQuality
+	config.CompressBitonal = *compressBitonal
 
-	// Read and parse hOCR
-	hOCR, err := os.ReadFile(*hocrPath)
-	if err != nil {
-		fmt.Printf("Failed to read HOCR file: %v\n", err)
-		os.Exit(exitError)
-	}
-
-	// Either create a new PDF from images or modify an existing PDF
-	var finalPDF []byte
+	// Read all images into memory up front, they are needed for OCR as well as the PDF
+	var imagesData [][]byte
 	if *imageDirPath != "" {
-		// Create new PDF from images
 		imagePaths, err := filepath.Glob(filepath.Join(*imageDirPath, "*"))
 		if err != nil {
-			fmt.Printf("Error accessing image directory: %v\n", err)
-			os.Exit(exitError)
+			fail(cli.ExitError, "Error accessing image directory: %v", err)
 		}
 		sort.Strings(imagePaths)
-		fmt.Printf("Found %d image files in %s\n", len(imagePaths), *imageDirPath)
+		cli.Infof("Found %d image files in %s\n", len(imagePaths), *imageDirPath)
 
-		// Read all images into memory
-		var imagesData [][]byte
 		for _, imgPath := range imagePaths {
 			imgBytes, err := os.ReadFile(imgPath)
 			if err != nil {
-				fmt.Printf("Failed to read image %s: %v\n", imgPath, err)
-				os.Exit(exitError)
+				fail(cli.ExitError, "Failed to read image %s: %v", imgPath, err)
+			}
+			imagesData = append(imagesData, splitImage(imgPath, imgBytes)...)
+		}
+	}
+	if *tiffPath != "" {
+		tiffBytes, err := os.ReadFile(*tiffPath)
+		if err != nil {
+			fail(cli.ExitError, "Failed to read TIFF file %s: %v", *tiffPath, err)
+		}
+		if !pdfocr.IsTIFF(tiffBytes) {
+			fail(cli.ExitError, "Error: %s is not a TIFF file", *tiffPath)
+		}
+		imagesData = splitImage(*tiffPath, tiffBytes)
+	}
+
+	// The raw hOCR is passed through unless it needs to be modified first
+	var hOCR interface{}
+	var parsed hocr.HOCR
+	if engine != nil {
+		// Run the OCR engine on every image
+		var inputs []ocrengine.Input
+		for _, imgBytes := range imagesData {
+			inputs = append(inputs, ocrengine.Input{Data: imgBytes, MimeType: http.DetectContentType(imgBytes)})
+		}
+		cli.Infof("Running %s OCR on %d image(s)\n", engine.Name(), len(inputs))
+		doc, err := ocrengine.ProcessPages(context.Background(), engine, inputs)
+		if err != nil {
+			fail(cli.ExitError, "OCR failed: %v", err)
+		}
+		parsed = *doc
+		hOCR = &parsed
+	} else {
+		hOCRData, err := readInput(*hocrPath)
+		if err != nil {
+			fail(cli.ExitError, "Failed to read HOCR file: %v", err)
+		}
+		cli.Debugf("Read %d bytes of hOCR from %s\n", len(hOCRData), displayPath(*hocrPath, "standard input"))
+		hOCR = hOCRData
+
+		if *detectLang {
+			parsed, err = hocr.Parse(hOCRData)
+			if err != nil {
+				fail(cli.ExitError, "Failed to parse HOCR file: %v", err)
 			}
-			imagesData = append(imagesData, imgBytes)
+			hOCR = &parsed
+		}
+	}
+
+	if *detectLang {
+		if detected := hocr.DetectLanguages(&parsed); detected > 0 {
+			cli.Infof("Detected language for %d page(s): %s\n", detected, parsed.Metadata["ocr-langs"])
 		}
+	}
 
-		// Assemble the OCR'd PDF
-		finalPDF, err = pdfocr.AssembleWithOCR(hOCR, imagesData, config)
+	// Either create a new PDF from images or modify an existing PDF
+	var result *pdfocr.ApplyResult
+	if imageInput {
+		// Assemble the OCR'd PDF from the images
+		result, err = pdfocr.AssembleWithOCRWithResult(hOCR, imagesData, config)
 		if err != nil {
-			fmt.Printf("Error creating PDF from images: %v\n", err)
-			os.Exit(exitError)
+			fail(cli.ExitError, "Error creating PDF from images: %v", err)
 		}
 
 	} else {
 		// Modify an existing PDF
-		inputData, err := os.ReadFile(*pdfPath)
+		inputData, err := readInput(*pdfPath)
 		if err != nil {
-			fmt.Printf("Failed to read input PDF: %v\n", err)
-			os.Exit(exitError)
+			fail(cli.ExitError, "Failed to read input PDF: %v", err)
 		}
+		cli.Debugf("Read %d bytes of PDF from %s\n", len(inputData), displayPath(*pdfPath, "standard input"))
 
 		// Apply the OCR layer to the PDF
-		finalPDF, err = pdfocr.ApplyOCR(inputData, hOCR, config)
+		result, err = pdfocr.ApplyOCRWithResult(inputData, hOCR, config)
 		if err != nil {
 			// Special handling for OCR already detected in strict mode
-			if strings.Contains(err.Error(), "already has OCR") && *strict {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(exitStrictOCRFailure)
+			if errors.Is(err, pdfocr.ErrAlreadyHasOCR) {
+				report.HasOCR = true
+				fail(cli.ExitStrictOCRFailure, "Error: %v", err)
 			}
-			fmt.Printf("Error applying OCR to existing PDF: %v\n", err)
-			os.Exit(exitError)
+			fail(cli.ExitError, "Error applying OCR to existing PDF: %v", err)
+		}
+	}
+	finalPDF := result.PDF
+	report.HasOCR = events.HasOCRWarning() || result.ReplacedLayers > 0
+	report.Pages = result.PageCount
+	report.Words = result.WordCount
+	report.SkippedPages = result.SkippedPages
+	report.LowConfidenceWords = result.LowConfidenceWords
+	if result.LowConfidenceWords > 0 {
+		events.Raise(conditionLowConfidence)
+		if *lowConfidenceLayer && !*noLayers {
+			cli.Infof("Drew %d word(s) below confidence %g onto hidden low confidence layers\n", result.LowConfidenceWords, *minConfidence)
+		} else {
+			cli.Infof("Left %d word(s) below confidence %g out of the OCR layer\n", result.LowConfidenceWords, *minConfidence)
 		}
 	}
+	if *heatmap {
+		printPageConfidence(result.PageConfidence)
+	}
 
 	// Warning for potentially conflicting flag combinations
-	if *imageDirPath != "" && *force {
-		fmt.Println("Note: -force is only applicable when -pdf is set. Ignoring -force for image input.")
+	if imageInput && *force {
+		cli.Warnf("Note: -force is only applicable when -pdf is set. Ignoring -force for image input.\n")
+	}
+	if imageInput && *strict {
+		cli.Warnf("Note: -strict is only applicable when -pdf is set. Ignoring -strict for image input.\n")
+	}
+	if imageInput && *replace {
+		cli.Warnf("Note: -replace is only applicable when -pdf is set. Ignoring -replace for image input.\n")
+	}
+	if imageInput && *skipTextPages {
+		cli.Warnf("Note: -skip-text-pages is only applicable when -pdf is set. Ignoring -skip-text-pages for image input.\n")
+	}
+	if imageInput && *keepAnnotations {
+		cli.Warnf("Note: -keep-annotations is only applicable when -pdf is set. Ignoring -keep-annotations for image input.\n")
 	}
-	if *imageDirPath != "" && *strict {
-		fmt.Println("Note: -strict is only applicable when -pdf is set. Ignoring -strict for image input.")
+	if imageInput && *password != "" {
+		cli.Warnf("Note: -password is only applicable when -pdf is set. Ignoring -password for image input.\n")
+	}
+	if *noLayers && *lowConfidenceLayer {
+		cli.Warnf("Note: -low-confidence-layer is not applicable with -no-layers. Ignoring -low-confidence-layer.\n")
+	}
+	if *pdfPath != "" && (*imageDPI > 0 || *imageQuality > 0 || *compressBitonal) {
+		cli.Warnf("Note: -image-dpi, -image-quality and -compress-bitonal are only applicable when -image-dir or -tiff is set. The page content of -pdf is copied as is.\n")
 	}
 
 	// Write final PDF to disk
-	if err := os.WriteFile(*pdfOcrPath, finalPDF, 0666); err != nil {
-		fmt.Printf("Failed to write output PDF: %v\n", err)
-		os.Exit(exitError)
-	}
-	fmt.Println("✅ OCR-enhanced PDF created:", *pdfOcrPath)
-
-	// Exit with appropriate code based on warnings
-	if warningCapture.HasOCRWarning() {
-		fmt.Println("Note: Completed with OCR warnings - existing OCR was detected")
-		os.Exit(exitSuccessWithWarns)
-	} else if warningCapture.HasWarnings() {
-		fmt.Println("Note: Completed with warnings")
-		os.Exit(exitSuccessWithWarns)
-	} else {
-		os.Exit(exitSuccess)
+	if err := writeOutput(*pdfOcrPath, finalPDF); err != nil {
+		fail(cli.ExitError, "Failed to write output PDF: %v", err)
 	}
+	report.AddOutput(*pdfOcrPath)
+	cli.Infof("✅ OCR-enhanced PDF created: %s\n", displayPath(*pdfOcrPath, "standard output"))
+
+	if *sidecarPath != "" {
+		if err := writeSidecar(*sidecarPath, hOCR); err != nil {
+			fail(cli.ExitError, "Failed to write sidecar text: %v", err)
+		}
+		report.AddOutput(*sidecarPath)
+		cli.Infof("✅ Sidecar text written: %s\n", *sidecarPath)
+	}
+
+	// Check that copying text from the layer yields the hOCR text
+	if *verifyText {
+		verification, err := pdfocr.VerifyTextLayer(finalPDF, hOCR, config)
+		if err != nil {
+			warning := fmt.Sprintf("text layer verification failed: %v", err)
+			cli.Warnf("Warning: %s\n", warning)
+			events.Record(pdfocr.EventWarning, warning)
+		} else if !verification.OK() {
+			warning := fmt.Sprintf("%d of %d word(s) don't extract as in the hOCR", len(verification.Mismatches), verification.Words)
+			events.Record(pdfocr.EventWarning, warning)
+			cli.Warnf("Warning: %s:\n", warning)
+			for _, mismatch := range verification.Mismatches {
+				cli.Warnf("  page %d %s: expected %q, extracted %q\n",
+					mismatch.Page, mismatch.WordID, mismatch.Expected, mismatch.Extracted)
+			}
+		} else {
+			cli.Infof("Text layer verified: %d word(s) extract exactly as in the hOCR\n", verification.Matched)
+		}
+	}
+
+	// Exit with the code the -warn-on and -fail-on policy gives the outcome
+	cli.Finish(report, events)
 }
@@ -0,0 +1,10 @@
+package main
+
+import "github.com/gardar/ocrchestra/internal/cli"
+
+// conditionLowConfidence is the condition of words below -min-confidence,
+// which pdfocr adds to the conditions of -warn-on and -fail-on
+const conditionLowConfidence = "low-confidence"
+
+// conditions are the known conditions, in the order they are reported
+var conditions = []string{cli.ConditionExistingOCR, cli.ConditionWarning, cli.ConditionEncoding, conditionLowConfidence}
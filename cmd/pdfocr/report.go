@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gardar/ocrchestra/internal/cli"
+)
+
+// runReport is the machine-readable summary of a run, written by -json. Its
+// mode is apply, assemble, check-ocr, remove-ocr, split-hocr, split-pdf,
+// merge or extract-hocr.
+type runReport struct {
+	cli.Report
+	OCRLayer           string `json:"ocr_layer,omitempty"`            // Name of the existing OCR layer
+	Pages              int    `json:"pages"`                          // Pages that received an OCR layer, or pages checked or split
+	Words              int    `json:"words"`                          // Words rendered into the OCR layer
+	LowConfidenceWords int    `json:"low_confidence_words,omitempty"` // Words below -min-confidence
+	SkippedPages       []int  `json:"skipped_pages,omitempty"`        // Pages left without OCR by -skip-text-pages
+	PagesWithOCR       []int  `json:"pages_with_ocr,omitempty"`       // Pages -check-ocr found OCR text on
+}
+
+// report collects the summary of this run
+var report = &runReport{Report: cli.NewReport("pdfocr")}
+
+// fail prints an error message, records it in the report and exits with the code
+func fail(code int, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Println(message)
+	report.Error = strings.TrimPrefix(message, "Error: ")
+	cli.Exit(report, code)
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// conditionEvents are the conditions the events of the pdfocr package raise
+var conditionEvents = map[string]string{
+	pdfocr.EventOCRDetected:   ConditionExistingOCR,
+	pdfocr.EventWarning:       ConditionWarning,
+	pdfocr.EventEncodingError: ConditionEncoding,
+}
+
+// EventRecorder is a slog handler that remembers the warning events emitted
+// by the library, so the exit code can reflect them without parsing log output
+type EventRecorder struct {
+	mu         sync.Mutex
+	conditions []string // Known conditions of the tool, in the order they are reported
+	events     map[string]int
+	raised     map[string]bool // Conditions raised by the tool itself rather than by an event
+	warnings   []string        // Messages of the generic warning events, in order
+}
+
+// NewEventRecorder returns a recorder reporting the known conditions of a tool
+func NewEventRecorder(conditions []string) *EventRecorder {
+	return &EventRecorder{conditions: conditions, events: make(map[string]int), raised: make(map[string]bool)}
+}
+
+// Enabled reports whether records of a level are recorded (warnings and above)
+func (r *EventRecorder) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn
+}
+
+// Handle records the event name of a log record
+func (r *EventRecorder) Handle(_ context.Context, record slog.Record) error {
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == "event" {
+			r.Record(attr.Value.String(), record.Message)
+			return false
+		}
+		return true
+	})
+	return nil
+}
+
+// WithAttrs returns the recorder itself, attributes are not recorded
+func (r *EventRecorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+
+// WithGroup returns the recorder itself, groups are not recorded
+func (r *EventRecorder) WithGroup(string) slog.Handler { return r }
+
+// Record counts an event, keeping the message of warnings
+func (r *EventRecorder) Record(event, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[event]++
+	if event == pdfocr.EventWarning {
+		r.warnings = append(r.warnings, message)
+	}
+}
+
+// Warnings returns the messages of the warning events
+func (r *EventRecorder) Warnings() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.warnings...)
+}
+
+// Raise records a condition the run ended with, such as image-quality
+func (r *EventRecorder) Raise(condition string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.raised[condition] = true
+}
+
+// Conditions returns the conditions the recorded events and raised ones
+// amount to, for the exit policy
+func (r *EventRecorder) Conditions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	met := make(map[string]bool)
+	for event, count := range r.events {
+		if condition, ok := conditionEvents[event]; ok && count > 0 {
+			met[condition] = true
+		}
+	}
+	var list []string
+	for _, condition := range r.conditions {
+		if met[condition] || r.raised[condition] {
+			list = append(list, condition)
+		}
+	}
+	return list
+}
+
+// HasOCRWarning specifically checks if existing OCR was detected
+func (r *EventRecorder) HasOCRWarning() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events[pdfocr.EventOCRDetected] > 0
+}
@@ -0,0 +1,120 @@
+// Package cli holds what the pdfocr and gdocai commands share: the output
+// levels of -quiet, -verbose and -log-level, the exit policy of -warn-on and
+// -fail-on, and the -json run report.
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// LogLevel is how much a tool prints, from errors only to debug details
+type LogLevel int
+
+// Log levels, each printing the messages of the levels before it as well
+const (
+	LevelError LogLevel = iota // Errors only
+	LevelWarn                  // Warnings and notes about the run
+	LevelInfo                  // Progress and results (the default)
+	LevelDebug                 // Details for troubleshooting
+)
+
+// logLevelNames are the values of -log-level
+var logLevelNames = map[string]LogLevel{
+	"error": LevelError,
+	"warn":  LevelWarn,
+	"info":  LevelInfo,
+	"debug": LevelDebug,
+}
+
+// OutputLevel is the level set with -quiet, -verbose or -log-level
+var OutputLevel = LevelInfo
+
+// SetOutputLevel picks the log level from the -quiet, -verbose and
+// -log-level flags. -log-level wins over the other two.
+func SetOutputLevel(quiet, verbose bool, name string) error {
+	if name != "" {
+		level, ok := logLevelNames[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("invalid -log-level %q (expected error, warn, info or debug)", name)
+		}
+		OutputLevel = level
+		return nil
+	}
+	switch {
+	case quiet && verbose:
+		return fmt.Errorf("-quiet and -verbose can't be combined")
+	case quiet:
+		OutputLevel = LevelError
+	case verbose:
+		OutputLevel = LevelDebug
+	}
+	return nil
+}
+
+// SlogLevel returns the slog level of the log level, for the log of the
+// Document AI requests
+func (l LogLevel) SlogLevel() slog.Level {
+	switch l {
+	case LevelError:
+		return slog.LevelError
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelDebug:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Logf prints a message if the output level includes its level
+func Logf(level LogLevel, format string, args ...any) {
+	if level <= OutputLevel {
+		fmt.Printf(format, args...)
+	}
+}
+
+// Warnf prints a warning or note about the run
+func Warnf(format string, args ...any) { Logf(LevelWarn, format, args...) }
+
+// Infof prints progress or a result
+func Infof(format string, args ...any) { Logf(LevelInfo, format, args...) }
+
+// Debugf prints a detail for troubleshooting
+func Debugf(format string, args ...any) { Logf(LevelDebug, format, args...) }
+
+// LibraryOutput returns the writer for the messages of the pdfocr package,
+// which keeps its warnings at the warning level and the rest at the info level
+func LibraryOutput() io.Writer {
+	return &levelWriter{}
+}
+
+// levelWriter filters lines written by the pdfocr package by the output
+// level. Indented lines continue the message before them.
+type levelWriter struct {
+	level LogLevel
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !bytes.HasPrefix(line, []byte(" ")) {
+			w.level = LevelInfo
+			if bytes.HasPrefix(line, []byte("Warning")) {
+				w.level = LevelWarn
+			}
+		}
+		if w.level <= OutputLevel {
+			if _, err := os.Stdout.Write(line); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Conditions a successful run of either tool can end with, which -warn-on
+// and -fail-on map to exit codes. The tools add conditions of their own.
+const (
+	ConditionExistingOCR = "existing-ocr"      // An input PDF already had OCR
+	ConditionWarning     = "warning"           // A warning was raised while processing
+	ConditionEncoding    = "encoding-fallback" // A word needed an encoding fallback
+)
+
+// DefaultWarnOn are the conditions that exit with warnings unless -warn-on
+// says otherwise
+const DefaultWarnOn = ConditionExistingOCR + "," + ConditionWarning
+
+// ExitPolicy maps the conditions a run ended with to its exit code
+type ExitPolicy struct {
+	warnOn map[string]bool // Conditions that exit with ExitSuccessWithWarns
+	failOn map[string]bool // Conditions that exit with ExitStrictOCRFailure
+}
+
+// Policy is the exit policy set with -warn-on and -fail-on
+var Policy ExitPolicy
+
+// ParseExitPolicy reads the comma separated conditions of -warn-on and
+// -fail-on, which must be among the known conditions of the tool. A
+// condition in both fails.
+func ParseExitPolicy(conditions []string, warnOn, failOn string) (ExitPolicy, error) {
+	parse := func(flagName, list string) (map[string]bool, error) {
+		set := make(map[string]bool)
+		for _, name := range strings.Split(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || name == "none" {
+				continue
+			}
+			if !slices.Contains(conditions, name) {
+				return nil, fmt.Errorf("invalid -%s condition %q (expected %s)", flagName, name, strings.Join(conditions, ", "))
+			}
+			set[name] = true
+		}
+		return set, nil
+	}
+	warn, err := parse("warn-on", warnOn)
+	if err != nil {
+		return ExitPolicy{}, err
+	}
+	fail, err := parse("fail-on", failOn)
+	if err != nil {
+		return ExitPolicy{}, err
+	}
+	return ExitPolicy{warnOn: warn, failOn: fail}, nil
+}
+
+// ExitCode returns the exit code of a successful run that ended with the conditions
+func (p ExitPolicy) ExitCode(met []string) int {
+	code := ExitSuccess
+	for _, condition := range met {
+		if p.failOn[condition] {
+			return ExitStrictOCRFailure
+		}
+		if p.warnOn[condition] {
+			code = ExitSuccessWithWarns
+		}
+	}
+	return code
+}
+
+// Failed returns the conditions that fail by -fail-on
+func (p ExitPolicy) Failed(met []string) []string {
+	var failed []string
+	for _, condition := range met {
+		if p.failOn[condition] {
+			failed = append(failed, condition)
+		}
+	}
+	return failed
+}
+
+// Finish prints how a successful run ended and exits with the code the
+// policy gives the conditions it met
+func Finish(reporter Reporter, events *EventRecorder) {
+	r := reporter.report()
+	met := events.Conditions()
+	r.Conditions = met
+	code := Policy.ExitCode(met)
+	switch {
+	case code == ExitStrictOCRFailure:
+		failed := strings.Join(Policy.Failed(met), ", ")
+		fmt.Printf("Error: Completed with %s (-fail-on)\n", failed)
+		r.Error = fmt.Sprintf("completed with %s (-fail-on)", failed)
+	case code == ExitSuccessWithWarns && Policy.warnOn[ConditionExistingOCR] && slices.Contains(met, ConditionExistingOCR):
+		Warnf("Note: Completed with OCR warnings - existing OCR was detected\n")
+	case code == ExitSuccessWithWarns:
+		Warnf("Note: Completed with warnings\n")
+	}
+	Exit(reporter, code)
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Exit codes of the tools
+const (
+	ExitSuccess          = 0 // Success with no warnings
+	ExitError            = 1 // Error, operation failed
+	ExitSuccessWithWarns = 2 // Success but with warnings (including OCR already detected)
+	ExitStrictOCRFailure = 3 // OCR already present in strict mode, or a -fail-on condition met
+)
+
+// Stdio is the path that selects standard input or output
+const Stdio = "-"
+
+// StandardOutput is where an output or report written to "-" goes. Messages
+// are moved to standard error in that case, so the output is the only thing
+// on standard output.
+var StandardOutput = os.Stdout
+
+// exitReasons names the exit codes in the report
+var exitReasons = map[int]string{
+	ExitSuccess:          "success",
+	ExitError:            "error",
+	ExitSuccessWithWarns: "warnings",
+	ExitStrictOCRFailure: "strict_ocr",
+}
+
+// Report is the part of the machine-readable summary of a run, written by
+// -json, that both tools have. Each tool embeds it in its own report type
+// with the fields of its modes.
+type Report struct {
+	Tool       string    `json:"tool"`
+	Mode       string    `json:"mode"`
+	Inputs     []string  `json:"inputs"`
+	Outputs    []string  `json:"outputs"`
+	HasOCR     bool      `json:"has_ocr"` // Whether an input PDF already had OCR
+	Warnings   []string  `json:"warnings"`
+	Conditions []string  `json:"conditions,omitempty"` // Conditions the run ended with, e.g. existing-ocr, for -warn-on and -fail-on
+	Error      string    `json:"error,omitempty"`
+	ExitCode   int       `json:"exit_code"`
+	ExitReason string    `json:"exit_reason"` // success, warnings, ocr_detected, strict_ocr, fail_on or error
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+
+	Path   string         `json:"-"` // Where the report is written ("-" for standard output, "" for nowhere)
+	Events *EventRecorder `json:"-"` // Warning events of the run
+
+	mu sync.Mutex // Guards the report while PDFs are processed concurrently
+}
+
+// NewReport starts the report of a run of a tool
+func NewReport(tool string) Report {
+	return Report{Tool: tool, Inputs: []string{}, Outputs: []string{}, StartedAt: time.Now()}
+}
+
+// Reporter is the report of a tool, which embeds Report
+type Reporter interface {
+	report() *Report
+}
+
+func (r *Report) report() *Report { return r }
+
+// Lock locks the report, for a tool updating its own fields concurrently
+func (r *Report) Lock() { r.mu.Lock() }
+
+// Unlock unlocks the report
+func (r *Report) Unlock() { r.mu.Unlock() }
+
+// AddInput records an input path in the report
+func (r *Report) AddInput(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if path != "" {
+		r.Inputs = append(r.Inputs, path)
+	}
+}
+
+// AddOutput records an output path in the report
+func (r *Report) AddOutput(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Outputs = append(r.Outputs, path)
+}
+
+// SetHasOCR records in the report that an input PDF already had OCR
+func (r *Report) SetHasOCR() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.HasOCR = true
+}
+
+// AddConditions records conditions one of the inputs met in the report
+func (r *Report) AddConditions(met []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, condition := range met {
+		if !slices.Contains(r.Conditions, condition) {
+			r.Conditions = append(r.Conditions, condition)
+		}
+	}
+}
+
+// write finishes the report with the exit code and writes the tool's report
+// as JSON
+func write(reporter Reporter, code int) error {
+	r := reporter.report()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ExitCode = code
+	r.ExitReason = exitReasons[code]
+	if code == ExitSuccessWithWarns && r.HasOCR {
+		r.ExitReason = "ocr_detected"
+	}
+	if code == ExitStrictOCRFailure && len(Policy.Failed(r.Conditions)) > 0 {
+		r.ExitReason = "fail_on"
+	}
+	r.DurationMS = time.Since(r.StartedAt).Milliseconds()
+	r.Warnings = []string{}
+	if r.Events != nil {
+		r.Warnings = r.Events.Warnings()
+	}
+
+	data, err := json.MarshalIndent(reporter, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if r.Path == Stdio {
+		_, err = StandardOutput.Write(data)
+		return err
+	}
+	return os.WriteFile(r.Path, data, 0666)
+}
+
+// Exit writes the JSON report, if requested, and exits with the code
+func Exit(reporter Reporter, code int) {
+	if reporter.report().Path != "" {
+		if err := write(reporter, code); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write JSON report: %v\n", err)
+			if code == ExitSuccess || code == ExitSuccessWithWarns {
+				code = ExitError
+			}
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+func TestWriteReport(t *testing.T) {
+	defer func() { Policy = ExitPolicy{} }()
+	var err error
+	Policy, err = ParseExitPolicy([]string{ConditionExistingOCR, ConditionWarning}, "", ConditionWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type toolReport struct {
+		Report
+		Pages int `json:"pages"`
+	}
+	report := &toolReport{Report: NewReport("tool"), Pages: 3}
+	report.Path = filepath.Join(t.TempDir(), "report.json")
+	report.Events = NewEventRecorder([]string{ConditionExistingOCR, ConditionWarning})
+	report.Events.Record(pdfocr.EventWarning, "something happened")
+	report.AddInput("in.pdf")
+	report.AddOutput("out.pdf")
+	report.Conditions = report.Events.Conditions()
+
+	if err := write(report, Policy.ExitCode(report.Conditions)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(report.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Tool       string   `json:"tool"`
+		Inputs     []string `json:"inputs"`
+		Outputs    []string `json:"outputs"`
+		Pages      int      `json:"pages"`
+		Warnings   []string `json:"warnings"`
+		ExitCode   int      `json:"exit_code"`
+		ExitReason string   `json:"exit_reason"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tool != "tool" || got.Pages != 3 || !slices.Equal(got.Inputs, []string{"in.pdf"}) || !slices.Equal(got.Outputs, []string{"out.pdf"}) {
+		t.Errorf("report = %s, want the shared and the tool's fields", data)
+	}
+	if !slices.Equal(got.Warnings, []string{"something happened"}) {
+		t.Errorf("warnings = %q, want the recorded warning", got.Warnings)
+	}
+	if got.ExitCode != ExitStrictOCRFailure || got.ExitReason != "fail_on" {
+		t.Errorf("exit = %d (%s), want %d (fail_on)", got.ExitCode, got.ExitReason, ExitStrictOCRFailure)
+	}
+}
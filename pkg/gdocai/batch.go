@@ -0,0 +1,269 @@
+package gdocai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	documentai "cloud.google.com/go/documentai/apiv1"
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+)
+
+// BatchOptions describes the input and output locations of a batch processing job.
+// Batch processing reads from and writes to Cloud Storage, so all URIs are gs:// URIs.
+type BatchOptions struct {
+	InputURIs   []string // Individual documents to process
+	InputPrefix string   // Process every document under this prefix (used if InputURIs is empty)
+	OutputURI   string   // Directory where Document AI writes the resulting JSON documents
+	MimeType    string   // MIME type of the input documents (defaults to application/pdf)
+	MimeTypes   []string // MIME type of each of InputURIs, where set, overriding MimeType
+}
+
+// BatchStatus is a snapshot of a batch processing long-running operation
+type BatchStatus struct {
+	Name         string                // Operation name; persist it to resume polling later
+	Done         bool                  // True once the operation has finished
+	State        string                // WAITING, RUNNING, SUCCEEDED, CANCELLING, CANCELLED or FAILED
+	StateMessage string                // Details about the state, such as a failure reason
+	CreateTime   time.Time             // When the operation was created
+	UpdateTime   time.Time             // When the operation was last updated
+	Documents    []BatchDocumentStatus // Per-document progress reported so far
+}
+
+// BatchDocumentStatus reports the processing state of a single document in a batch
+type BatchDocumentStatus struct {
+	InputURI  string // Source document
+	OutputURI string // Result location, empty unless processing succeeded
+	Error     string // Error message if processing the document failed
+}
+
+// Progress returns the number of documents that have finished processing
+// (successfully or not) and the number of documents reported so far
+func (s *BatchStatus) Progress() (completed, total int) {
+	for _, doc := range s.Documents {
+		if doc.OutputURI != "" || doc.Error != "" {
+			completed++
+		}
+	}
+	return completed, len(s.Documents)
+}
+
+// PollOptions controls how WaitForBatch polls a long-running operation
+type PollOptions struct {
+	InitialInterval time.Duration      // Delay before the second poll
+	MaxInterval     time.Duration      // Upper bound for the delay between polls
+	Multiplier      float64            // Factor the delay grows by after each poll
+	OnProgress      func(*BatchStatus) // Called after every successful poll (optional)
+}
+
+// DefaultPollOptions returns polling settings suited to multi-hour batch jobs
+func DefaultPollOptions() PollOptions {
+	return PollOptions{
+		InitialInterval: 10 * time.Second,
+		MaxInterval:     5 * time.Minute,
+		Multiplier:      1.5,
+	}
+}
+
+// StartBatchProcess starts an asynchronous batch processing job and returns
+// its initial status. The operation name in the status can be stored and
+// later passed to GetBatchStatus, WaitForBatch or CancelBatch, even from
+// another process.
+func StartBatchProcess(ctx context.Context, cfg *Config, opts BatchOptions) (*BatchStatus, error) {
+	if opts.OutputURI == "" {
+		return nil, fmt.Errorf("batch output URI is required")
+	}
+	if len(opts.InputURIs) == 0 && opts.InputPrefix == "" {
+		return nil, fmt.Errorf("batch input URIs or input prefix is required")
+	}
+
+	mimeType := opts.MimeType
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+
+	input := &documentaipb.BatchDocumentsInputConfig{}
+	if len(opts.InputURIs) > 0 {
+		var documents []*documentaipb.GcsDocument
+		for i, uri := range opts.InputURIs {
+			documentType := mimeType
+			if i < len(opts.MimeTypes) && opts.MimeTypes[i] != "" {
+				documentType = opts.MimeTypes[i]
+			}
+			documents = append(documents, &documentaipb.GcsDocument{GcsUri: uri, MimeType: documentType})
+		}
+		input.Source = &documentaipb.BatchDocumentsInputConfig_GcsDocuments{
+			GcsDocuments: &documentaipb.GcsDocuments{Documents: documents},
+		}
+	} else {
+		input.Source = &documentaipb.BatchDocumentsInputConfig_GcsPrefix{
+			GcsPrefix: &documentaipb.GcsPrefix{GcsUriPrefix: opts.InputPrefix},
+		}
+	}
+
+	client, err := NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	req := &documentaipb.BatchProcessRequest{
+		Name:           processorName(cfg),
+		InputDocuments: input,
+		DocumentOutputConfig: &documentaipb.DocumentOutputConfig{
+			Destination: &documentaipb.DocumentOutputConfig_GcsOutputConfig_{
+				GcsOutputConfig: &documentaipb.DocumentOutputConfig_GcsOutputConfig{GcsUri: opts.OutputURI},
+			},
+		},
+		SkipHumanReview: true,
+		ProcessOptions:  processOptions(cfg),
+	}
+
+	release, err := cfg.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	op, err := client.BatchProcessDocuments(ctx, req)
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start batch processing: %w", err)
+	}
+	cfg.logEvent(slog.LevelInfo, EventBatchStarted, "batch processing started",
+		"operation", op.Name(), "output_uri", opts.OutputURI)
+
+	return batchStatusOf(op)
+}
+
+// GetBatchStatus fetches the current status of a batch processing operation
+func GetBatchStatus(ctx context.Context, cfg *Config, name string) (*BatchStatus, error) {
+	client, err := NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	op := client.BatchProcessDocumentsOperation(name)
+	return pollBatch(ctx, op)
+}
+
+// WaitForBatch polls a batch processing operation with exponential backoff
+// until it finishes or the context is cancelled. The final status is
+// returned together with an error if the operation failed.
+func WaitForBatch(ctx context.Context, cfg *Config, name string, poll PollOptions) (*BatchStatus, error) {
+	defaults := DefaultPollOptions()
+	if poll.InitialInterval <= 0 {
+		poll.InitialInterval = defaults.InitialInterval
+	}
+	if poll.MaxInterval < poll.InitialInterval {
+		poll.MaxInterval = max(defaults.MaxInterval, poll.InitialInterval)
+	}
+	if poll.Multiplier < 1 {
+		poll.Multiplier = defaults.Multiplier
+	}
+
+	client, err := NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	op := client.BatchProcessDocumentsOperation(name)
+	interval := poll.InitialInterval
+
+	for {
+		status, err := pollBatch(ctx, op)
+		if status != nil {
+			completed, total := status.Progress()
+			cfg.logEvent(slog.LevelInfo, EventBatchProgress, "batch processing polled",
+				"operation", name, "state", status.State, "completed", completed, "total", total)
+		}
+		if status != nil && poll.OnProgress != nil {
+			poll.OnProgress(status)
+		}
+		if err != nil || status.Done {
+			return status, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("stopped waiting for batch operation %s: %w", name, ctx.Err())
+		case <-time.After(interval):
+		}
+
+		interval = min(time.Duration(float64(interval)*poll.Multiplier), poll.MaxInterval)
+	}
+}
+
+// CancelBatch requests cancellation of a batch processing operation.
+// Cancellation is asynchronous; poll the operation to see when it takes effect.
+func CancelBatch(ctx context.Context, cfg *Config, name string) error {
+	client, err := NewClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: name}); err != nil {
+		return fmt.Errorf("failed to cancel batch operation %s: %w", name, err)
+	}
+	return nil
+}
+
+// pollBatch polls the operation once and converts it into a BatchStatus.
+// A status is returned alongside the error when the operation finished with a failure.
+func pollBatch(ctx context.Context, op *documentai.BatchProcessDocumentsOperation) (*BatchStatus, error) {
+	_, pollErr := op.Poll(ctx)
+	if pollErr != nil && !op.Done() {
+		return nil, fmt.Errorf("failed to poll batch operation %s: %w", op.Name(), pollErr)
+	}
+
+	status, err := batchStatusOf(op)
+	if err != nil {
+		return nil, err
+	}
+	if pollErr != nil {
+		return status, fmt.Errorf("batch operation %s failed: %w", op.Name(), pollErr)
+	}
+	return status, nil
+}
+
+// batchStatusOf builds a BatchStatus from the operation's last known metadata
+func batchStatusOf(op *documentai.BatchProcessDocumentsOperation) (*BatchStatus, error) {
+	status := &BatchStatus{
+		Name: op.Name(),
+		Done: op.Done(),
+	}
+
+	meta, err := op.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch operation metadata: %w", err)
+	}
+	if meta == nil {
+		return status, nil
+	}
+
+	status.State = strings.TrimPrefix(meta.GetState().String(), "STATE_")
+	status.StateMessage = meta.GetStateMessage()
+	if meta.GetCreateTime() != nil {
+		status.CreateTime = meta.GetCreateTime().AsTime()
+	}
+	if meta.GetUpdateTime() != nil {
+		status.UpdateTime = meta.GetUpdateTime().AsTime()
+	}
+
+	for _, individual := range meta.GetIndividualProcessStatuses() {
+		doc := BatchDocumentStatus{
+			InputURI:  individual.GetInputGcsSource(),
+			OutputURI: individual.GetOutputGcsDestination(),
+		}
+		if s := individual.GetStatus(); s != nil && s.GetCode() != 0 {
+			doc.Error = s.GetMessage()
+		}
+		status.Documents = append(status.Documents, doc)
+	}
+
+	return status, nil
+}
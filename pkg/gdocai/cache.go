@@ -0,0 +1,128 @@
+package gdocai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Cache stores Document AI responses by a key derived from the document,
+// set as Config.Cache, so documents processed before are read from it instead
+// of being processed, and billed, again. DirCache keeps the responses in a
+// local directory; implement Cache to keep them elsewhere, e.g. in a bucket.
+// A Cache must be safe for concurrent use.
+type Cache interface {
+	// Get returns the response stored under key, and false if there is none
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Put stores a response under key, replacing any stored before
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// DirCache is a Cache that keeps every response in a file of Dir named
+// after its key. The directory is created on first use.
+type DirCache struct {
+	Dir string
+}
+
+// Get reads the response stored under key
+func (c DirCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Put writes a response under key. It is written to a temporary file first,
+// so concurrent readers never see a partial response.
+func (c DirCache) Put(_ context.Context, key string, data []byte) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// path returns the file of a key
+func (c DirCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".pb")
+}
+
+// cacheKey derives the cache key of a request: the SHA-256 of the document
+// and of everything that changes the response, i.e. the processor and its
+// version, the MIME type, the processing options and the fields returned
+func cacheKey(req *documentaipb.ProcessRequest) (string, error) {
+	options, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.GetProcessOptions())
+	if err != nil {
+		return "", err
+	}
+	fields, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.GetFieldMask())
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, part := range [][]byte{
+		req.GetRawDocument().GetContent(),
+		[]byte(req.GetName()),
+		[]byte(req.GetRawDocument().GetMimeType()),
+		options,
+		fields,
+	} {
+		fmt.Fprintf(hash, "%d:", len(part))
+		hash.Write(part)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cachedResponse returns the document the cache has for a request, or nil.
+// A cache that fails is reported with an EventCacheError event and skipped.
+func cachedResponse(ctx context.Context, cfg *Config, key string) *documentaipb.Document {
+	data, ok, err := cfg.Cache.Get(ctx, key)
+	if err != nil {
+		cfg.logEvent(slog.LevelWarn, EventCacheError, "failed to read the response cache", "key", key, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	doc := &documentaipb.Document{}
+	if err := proto.Unmarshal(data, doc); err != nil {
+		cfg.logEvent(slog.LevelWarn, EventCacheError, "invalid cached response", "key", key, "error", err)
+		return nil
+	}
+	return doc
+}
+
+// cacheResponse stores the document of a processed request in the cache
+func cacheResponse(ctx context.Context, cfg *Config, key string, doc *documentaipb.Document) {
+	data, err := proto.Marshal(doc)
+	if err == nil {
+		err = cfg.Cache.Put(ctx, key, data)
+	}
+	if err != nil {
+		cfg.logEvent(slog.LevelWarn, EventCacheError, "failed to write the response cache", "key", key, "error", err)
+	}
+}
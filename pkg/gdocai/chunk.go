@@ -0,0 +1,196 @@
+package gdocai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// layoutIDPattern splits a layout block or chunk ID such as "12" or "c3"
+// into its prefix and sequence number
+var layoutIDPattern = regexp.MustCompile(`^(\D*)(\d+)$`)
+
+// chunkPages returns the pages of a PDF that is to be processed in chunks,
+// or 0 if it can be sent as one request: it has no more than SyncPageLimit
+// pages, only some of its pages are selected, or its pages can't be counted
+func chunkPages(pdfBytes []byte, cfg *Config) int {
+	if len(cfg.Pages) > 0 || cfg.FromStart > 0 || cfg.FromEnd > 0 {
+		return 0
+	}
+	pages, err := pdfocr.PageCount(pdfBytes)
+	if err != nil || pages <= SyncPageLimit {
+		return 0
+	}
+	return pages
+}
+
+// processChunks processes a PDF of more pages than SyncPageLimit as chunks
+// of SyncPageLimit pages, one online request each, and merges the chunks
+// into a document as if the PDF had been processed at once. Every chunk goes
+// through the cache and request limits of cfg on its own.
+func processChunks(ctx context.Context, pdfBytes []byte, pages int, cfg *Config) (*documentaipb.Document, error) {
+	count := (pages + SyncPageLimit - 1) / SyncPageLimit
+	cfg.logEvent(slog.LevelInfo, EventDocumentChunked, "document split into chunks",
+		"pages", pages, "chunks", count, "chunk_pages", SyncPageLimit)
+
+	chunks := make([]*documentaipb.Document, 0, count)
+	for first := 1; first <= pages; first += SyncPageLimit {
+		last := min(first+SyncPageLimit-1, pages)
+		selection := make([]int, 0, last-first+1)
+		for page := first; page <= last; page++ {
+			selection = append(selection, page)
+		}
+
+		chunkBytes, err := pdfocr.ExtractPages(pdfBytes, selection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract pages %d-%d: %w", first, last, err)
+		}
+		chunk, err := processRawDocument(ctx, chunkBytes, "application/pdf", cfg)
+		if err != nil {
+			return nil, fmt.Errorf("pages %d-%d: %w", first, last, err)
+		}
+		if len(chunk.GetPages()) != len(selection) {
+			return nil, fmt.Errorf("expected %d pages in result for pages %d-%d, got %d",
+				len(selection), first, last, len(chunk.GetPages()))
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return mergeChunks(chunks), nil
+}
+
+// mergeChunks combines documents processed from consecutive page ranges of
+// a PDF into a single document. Unlike batch shards, each chunk numbers its
+// pages, layout blocks and chunks from the start, so these are renumbered
+// to follow the chunks before it, and its text anchors, which index into its
+// own text by character, are shifted by the characters before it.
+func mergeChunks(chunks []*documentaipb.Document) *documentaipb.Document {
+	merged := chunks[0]
+	offsets := chunkOffsets{}
+	offsets.advance(merged)
+
+	for _, chunk := range chunks[1:] {
+		shiftTextAnchors(chunk.ProtoReflect(), offsets.text)
+		renumberChunk(chunk.ProtoReflect(), offsets)
+		offsets.advance(chunk)
+
+		merged.Text += chunk.Text
+		merged.Pages = append(merged.Pages, chunk.Pages...)
+		merged.Entities = append(merged.Entities, chunk.Entities...)
+		merged.EntityRelations = append(merged.EntityRelations, chunk.EntityRelations...)
+		merged.TextStyles = append(merged.TextStyles, chunk.TextStyles...)
+		if blocks := chunk.GetDocumentLayout().GetBlocks(); len(blocks) > 0 {
+			if merged.DocumentLayout == nil {
+				merged.DocumentLayout = &documentaipb.Document_DocumentLayout{}
+			}
+			merged.DocumentLayout.Blocks = append(merged.DocumentLayout.Blocks, blocks...)
+		}
+		if parts := chunk.GetChunkedDocument().GetChunks(); len(parts) > 0 {
+			if merged.ChunkedDocument == nil {
+				merged.ChunkedDocument = &documentaipb.Document_ChunkedDocument{}
+			}
+			merged.ChunkedDocument.Chunks = append(merged.ChunkedDocument.Chunks, parts...)
+		}
+	}
+
+	return merged
+}
+
+// chunkOffsets counts what the chunks merged so far hold, which the
+// numbering of the next chunk continues from
+type chunkOffsets struct {
+	text   int64 // Characters of text
+	pages  int32 // Pages
+	blocks int   // Highest layout block number
+	chunks int   // Highest chunk number
+}
+
+// advance adds what a merged chunk holds, after it has been renumbered
+func (o *chunkOffsets) advance(chunk *documentaipb.Document) {
+	o.text += int64(utf8.RuneCountInString(chunk.Text))
+	o.pages += int32(len(chunk.Pages))
+	visitMessages(chunk.ProtoReflect(), func(m protoreflect.Message) {
+		switch v := m.Interface().(type) {
+		case *documentaipb.Document_DocumentLayout_DocumentLayoutBlock:
+			o.blocks = max(o.blocks, layoutIDNumber(v.BlockId))
+		case *documentaipb.Document_ChunkedDocument_Chunk:
+			o.chunks = max(o.chunks, layoutIDNumber(v.ChunkId))
+		}
+	})
+}
+
+// renumberChunk moves the page numbers, page references, page spans and
+// layout IDs of a chunk past those of the chunks before it
+func renumberChunk(m protoreflect.Message, offsets chunkOffsets) {
+	visitMessages(m, func(m protoreflect.Message) {
+		switch v := m.Interface().(type) {
+		case *documentaipb.Document_Page:
+			if v.PageNumber > 0 {
+				v.PageNumber += offsets.pages
+			}
+		case *documentaipb.Document_PageAnchor_PageRef:
+			v.Page += int64(offsets.pages)
+		case *documentaipb.Document_DocumentLayout_DocumentLayoutBlock_LayoutPageSpan:
+			v.PageStart += offsets.pages
+			v.PageEnd += offsets.pages
+		case *documentaipb.Document_ChunkedDocument_Chunk_ChunkPageSpan:
+			v.PageStart += offsets.pages
+			v.PageEnd += offsets.pages
+		case *documentaipb.Document_DocumentLayout_DocumentLayoutBlock:
+			v.BlockId = shiftLayoutID(v.BlockId, offsets.blocks)
+		case *documentaipb.Document_ChunkedDocument_Chunk:
+			v.ChunkId = shiftLayoutID(v.ChunkId, offsets.chunks)
+			for i, id := range v.SourceBlockIds {
+				v.SourceBlockIds[i] = shiftLayoutID(id, offsets.blocks)
+			}
+		}
+	})
+}
+
+// visitMessages calls visit for every message in a message tree, parents
+// before their children
+func visitMessages(m protoreflect.Message, visit func(protoreflect.Message)) {
+	visit(m)
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil || fd.IsMap() {
+			return true
+		}
+		if fd.IsList() {
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				visitMessages(list.Get(i).Message(), visit)
+			}
+			return true
+		}
+		visitMessages(v.Message(), visit)
+		return true
+	})
+}
+
+// layoutIDNumber returns the sequence number of a layout ID, or 0
+func layoutIDNumber(id string) int {
+	match := layoutIDPattern.FindStringSubmatch(id)
+	if match == nil {
+		return 0
+	}
+	number, _ := strconv.Atoi(match[2])
+	return number
+}
+
+// shiftLayoutID adds an offset to the sequence number of a layout ID, so
+// "c3" becomes "c13" after 10 chunks. IDs without a number are kept.
+func shiftLayoutID(id string, offset int) string {
+	match := layoutIDPattern.FindStringSubmatch(id)
+	if match == nil || offset == 0 {
+		return id
+	}
+	number, _ := strconv.Atoi(match[2])
+	return match[1] + strconv.Itoa(number+offset)
+}
@@ -3,51 +3,203 @@ package gdocai
 import (
 	"context"
 	"fmt"
-	"os"
+	"log/slog"
 
 	documentai "cloud.google.com/go/documentai/apiv1"
 	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
+// DocumentProcessor sends online processing requests to Document AI.
+// *documentai.DocumentProcessorClient implements it; set Config.Client to
+// reuse a client across calls or to substitute a fake in tests.
+type DocumentProcessor interface {
+	ProcessDocument(ctx context.Context, req *documentaipb.ProcessRequest, opts ...gax.CallOption) (*documentaipb.ProcessResponse, error)
+}
+
 // ProcessDocument sends PDF bytes to Google Document AI for processing
-// and returns the raw Document proto response
+// and returns the raw Document proto response. A PDF of more pages than
+// SyncPageLimit is processed in chunks of that many pages, which are merged
+// back into one document, unless only some of its pages are selected.
 func ProcessDocument(ctx context.Context, pdfBytes []byte, cfg *Config) (*documentaipb.Document, error) {
-	endpoint := fmt.Sprintf("%s-documentai.googleapis.com:443", cfg.Location)
-
-	// Instantiate Document AI client using credentials from environment variable
-	client, err := documentai.NewDocumentProcessorClient(
-		ctx,
-		option.WithEndpoint(endpoint),
-		option.WithCredentialsFile(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Document AI client: %w", err)
+	if pages := chunkPages(pdfBytes, cfg); pages > 0 {
+		return processChunks(ctx, pdfBytes, pages, cfg)
 	}
-	defer client.Close()
-
-	// Build the resource name of the processor
-	name := fmt.Sprintf(
-		"projects/%s/locations/%s/processors/%s",
-		cfg.ProjectID, cfg.Location, cfg.ProcessorID,
-	)
+	return processRawDocument(ctx, pdfBytes, "application/pdf", cfg)
+}
 
+// processRawDocument sends document bytes of the given MIME type to Document AI
+func processRawDocument(ctx context.Context, content []byte, mimeType string, cfg *Config) (*documentaipb.Document, error) {
 	// Create the request
 	req := &documentaipb.ProcessRequest{
-		Name: name,
+		Name: processorName(cfg),
 		Source: &documentaipb.ProcessRequest_RawDocument{
 			RawDocument: &documentaipb.RawDocument{
-				Content:  pdfBytes,
-				MimeType: "application/pdf",
+				Content:  content,
+				MimeType: mimeType,
 			},
 		},
 		SkipHumanReview: true,
+		ProcessOptions:  processOptions(cfg),
+		FieldMask:       responseFieldMask(cfg),
+	}
+
+	// Documents processed before are read from the cache, without a client
+	var key string
+	if cfg.Cache != nil {
+		var err error
+		if key, err = cacheKey(req); err != nil {
+			return nil, fmt.Errorf("failed to derive the cache key: %w", err)
+		}
+		if doc := cachedResponse(ctx, cfg, key); doc != nil {
+			cfg.logEvent(slog.LevelInfo, EventCacheHit, "cached response used",
+				"processor", req.Name, "mime_type", mimeType, "key", key, "pages", len(doc.GetPages()))
+			return doc, nil
+		}
 	}
 
+	var client DocumentProcessor = cfg.Client
+	if client == nil {
+		owned, err := NewClient(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		defer owned.Close()
+		client = owned
+	}
+
+	release, err := cfg.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.ProcessDocument(ctx, req)
+	release()
 	if err != nil {
 		return nil, fmt.Errorf("failed to process document: %w", err)
 	}
+	cfg.logEvent(slog.LevelInfo, EventDocumentProcessed, "document processed",
+		"processor", req.Name, "mime_type", mimeType, "bytes", len(content), "pages", len(resp.Document.GetPages()))
 
+	if cfg.Cache != nil {
+		cacheResponse(ctx, cfg, key, resp.Document)
+	}
 	return resp.Document, nil
 }
+
+// NewClient creates a Document AI client for the configured location
+// using the credentials of the config. The caller must close it.
+// It can be shared through Config.Client.
+func NewClient(ctx context.Context, cfg *Config) (*documentai.DocumentProcessorClient, error) {
+	opts, err := cfg.clientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := documentai.NewDocumentProcessorClient(
+		ctx,
+		append(opts, option.WithEndpoint(cfg.endpoint()))...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Document AI client: %w", err)
+	}
+	return client, nil
+}
+
+// endpoint returns the configured Document AI endpoint, or the regional
+// endpoint of the configured location
+func (cfg *Config) endpoint() string {
+	if cfg.Endpoint != "" {
+		return cfg.Endpoint
+	}
+	return fmt.Sprintf("%s-documentai.googleapis.com:443", cfg.Location)
+}
+
+// processOptions returns the processing options of the config, or nil if it has none
+func processOptions(cfg *Config) *documentaipb.ProcessOptions {
+	var options *documentaipb.ProcessOptions
+	if cfg.ChunkSize > 0 {
+		options = &documentaipb.ProcessOptions{
+			LayoutConfig: &documentaipb.ProcessOptions_LayoutConfig{
+				ChunkingConfig: &documentaipb.ProcessOptions_LayoutConfig_ChunkingConfig{
+					ChunkSize:               int32(cfg.ChunkSize),
+					IncludeAncestorHeadings: true,
+				},
+			},
+		}
+	}
+	if cfg.ImageQualityScores || cfg.SymbolBoxes || len(cfg.LanguageHints) > 0 || len(cfg.AdvancedOCROptions) > 0 {
+		if options == nil {
+			options = &documentaipb.ProcessOptions{}
+		}
+		options.OcrConfig = &documentaipb.OcrConfig{
+			EnableImageQualityScores: cfg.ImageQualityScores,
+			AdvancedOcrOptions:       cfg.AdvancedOCROptions,
+			EnableSymbol:             cfg.SymbolBoxes,
+		}
+		if len(cfg.LanguageHints) > 0 {
+			options.OcrConfig.Hints = &documentaipb.OcrConfig_Hints{LanguageHints: cfg.LanguageHints}
+		}
+	}
+	if len(cfg.Pages) > 0 || cfg.FromStart > 0 || cfg.FromEnd > 0 {
+		if options == nil {
+			options = &documentaipb.ProcessOptions{}
+		}
+		switch {
+		case len(cfg.Pages) > 0:
+			pages := make([]int32, len(cfg.Pages))
+			for i, page := range cfg.Pages {
+				pages[i] = int32(page)
+			}
+			options.PageRange = &documentaipb.ProcessOptions_IndividualPageSelector_{
+				IndividualPageSelector: &documentaipb.ProcessOptions_IndividualPageSelector{Pages: pages},
+			}
+		case cfg.FromStart > 0:
+			options.PageRange = &documentaipb.ProcessOptions_FromStart{FromStart: int32(cfg.FromStart)}
+		default:
+			options.PageRange = &documentaipb.ProcessOptions_FromEnd{FromEnd: int32(cfg.FromEnd)}
+		}
+	}
+	return options
+}
+
+// responseFieldMask returns the fields of the document Document AI should
+// return, every field but the page images and the input document with
+// SkipPageImages, or nil for the whole document
+func responseFieldMask(cfg *Config) *fieldmaskpb.FieldMask {
+	if !cfg.SkipPageImages {
+		return nil
+	}
+	mask := &fieldmaskpb.FieldMask{}
+	fields := (&documentaipb.Document{}).ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		switch field.Name() {
+		case "content":
+			continue
+		case "pages":
+			pageFields := field.Message().Fields()
+			for j := 0; j < pageFields.Len(); j++ {
+				if name := pageFields.Get(j).Name(); name != "image" {
+					mask.Paths = append(mask.Paths, "pages."+string(name))
+				}
+			}
+		default:
+			mask.Paths = append(mask.Paths, string(field.Name()))
+		}
+	}
+	return mask
+}
+
+// processorName builds the resource name of the configured processor,
+// including the processor version when one is set
+func processorName(cfg *Config) string {
+	name := fmt.Sprintf(
+		"projects/%s/locations/%s/processors/%s",
+		cfg.ProjectID, cfg.Location, cfg.ProcessorID,
+	)
+	if cfg.ProcessorVersion != "" {
+		name += "/processorVersions/" + cfg.ProcessorVersion
+	}
+	return name
+}
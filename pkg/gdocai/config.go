@@ -1,8 +1,110 @@
 package gdocai
 
+import (
+	"log/slog"
+
+	"github.com/gardar/ocrchestra/pkg/preprocess"
+)
+
 // Config holds the settings needed for Google Document AI
 type Config struct {
 	ProjectID   string
 	Location    string
 	ProcessorID string
+
+	// ProcessorVersion optionally pins a processor version, either a version ID
+	// (e.g. "pretrained-ocr-v2.0-2023-06-02") or an alias such as "stable" or "rc".
+	// When empty, the processor's default version is used.
+	ProcessorVersion string
+
+	// Endpoint optionally overrides the Document AI API endpoint (host:port),
+	// e.g. for a Private Service Connect endpoint, a sovereign region or an
+	// emulator. When empty, "<Location>-documentai.googleapis.com:443" is used.
+	Endpoint string
+
+	// CredentialsFile optionally names a service account key or other credential
+	// JSON file. CredentialsJSON optionally holds the content of one instead,
+	// e.g. read from a secret, and takes precedence. Without either,
+	// Application Default Credentials are used, which include the
+	// GOOGLE_APPLICATION_CREDENTIALS file and GKE workload identity.
+	CredentialsFile string
+	CredentialsJSON []byte
+
+	// ImpersonateServiceAccount optionally names the email of a service account
+	// to act as, using the credentials above to obtain its tokens. The caller
+	// needs the Service Account Token Creator role on it.
+	ImpersonateServiceAccount string
+
+	// Client optionally sends the online processing requests instead of a new
+	// Document AI client being created, and closed, for every document. The
+	// caller owns the client. Batch processing always creates its own client.
+	Client DocumentProcessor
+
+	// Logger optionally receives structured events such as EventDocumentProcessed
+	Logger *slog.Logger
+
+	// MaxQPS optionally limits how many processing requests per second are sent
+	// to Document AI with this Config, to stay within the processor quota.
+	// Zero means no limit.
+	MaxQPS float64
+
+	// MaxConcurrent optionally limits how many processing requests are in flight
+	// at once with this Config. Zero means no limit.
+	MaxConcurrent int
+
+	// Preprocess optionally cleans up scanned pages (deskew, rotate, contrast,
+	// binarize, despeckle) before OCR. It is applied by Preprocess, not by
+	// ProcessDocument, as the cleaned up PDF replaces the original one.
+	Preprocess preprocess.Pipeline
+
+	// ChunkSize optionally sets the size, in tokens, of the chunks a Layout
+	// Parser processor splits the document into. Chunks then repeat the
+	// headings they fall under. Zero keeps the processor's default. It must
+	// stay zero for other processors, which reject layout options.
+	ChunkSize int
+
+	// ImageQualityScores asks Document AI to score the image quality of each
+	// page and detect defects such as blur, glare or darkness, reported as
+	// Page.ImageQuality. Only Enterprise Document OCR processors support it.
+	ImageQualityScores bool
+
+	// LanguageHints optionally lists the languages of the documents as BCP-47
+	// codes (e.g. "en", "de", "ja"), which improves recognition on corpora of
+	// known languages. Without hints, Document AI detects the languages itself.
+	LanguageHints []string
+
+	// AdvancedOCROptions optionally passes advanced options to the OCR
+	// processor, e.g. "legacy_layout" to use the heuristic layout detection
+	// of older processor versions.
+	AdvancedOCROptions []string
+
+	// SymbolBoxes asks Document AI for the boxes of the individual characters
+	// (symbols), which the generated hOCR then has as ocrx_cinfo elements
+	// within each word.
+	SymbolBoxes bool
+
+	// Pages optionally limits processing to these pages (1-based) of each
+	// document, so only the pages that need OCR are paid for. FromStart and
+	// FromEnd instead process only the first or the last pages of it, or all
+	// of a shorter document. Pages takes precedence over FromStart, and
+	// FromStart over FromEnd. The processed document then only has the
+	// selected pages, in order.
+	Pages     []int
+	FromStart int
+	FromEnd   int
+
+	// SkipPageImages asks Document AI to leave the page images out of online
+	// responses, which makes them far smaller when the OCR text is applied
+	// to an existing PDF. ExtractImageFromPage then finds no images, so
+	// searchable PDFs can't be assembled from them. Batch processing always
+	// returns the images.
+	SkipPageImages bool
+
+	// Cache optionally stores the responses of online processing requests,
+	// keyed by the SHA-256 of the document, processor version and options, so
+	// unchanged documents aren't processed and billed again, e.g. DirCache.
+	// Batch processing isn't cached.
+	Cache Cache
+
+	limiter *limiter // Created on first use from MaxQPS and MaxConcurrent
 }
@@ -0,0 +1,45 @@
+package gdocai
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// cloudPlatformScope is the OAuth scope requested for impersonated credentials
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// clientOptions returns the options that authenticate Google Cloud clients
+// with the credentials of the config. Without CredentialsJSON or
+// CredentialsFile, Application Default Credentials are used: the file named
+// by GOOGLE_APPLICATION_CREDENTIALS, the gcloud user credentials, or the
+// metadata server on GCE, Cloud Run and GKE (including workload identity).
+// A nil config uses Application Default Credentials.
+func (cfg *Config) clientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	var opts []option.ClientOption
+	switch {
+	case len(cfg.CredentialsJSON) > 0:
+		opts = append(opts, option.WithCredentialsJSON(cfg.CredentialsJSON))
+	case cfg.CredentialsFile != "":
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+
+	if cfg.ImpersonateServiceAccount != "" {
+		tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: cfg.ImpersonateServiceAccount,
+			Scopes:          []string{cloudPlatformScope},
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate service account %s: %w", cfg.ImpersonateServiceAccount, err)
+		}
+		opts = []option.ClientOption{option.WithTokenSource(tokenSource)}
+	}
+
+	return opts, nil
+}
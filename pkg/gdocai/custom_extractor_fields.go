@@ -20,17 +20,54 @@ func ExtractCustomExtractorFields(docProto *documentaipb.Document) map[string]in
 		}
 
 		// Process this entity and add to fields
-		processEntity(entity, fields)
+		processEntity(entity, fields, mentionText)
 	}
 
 	return fields
 }
 
+// ExtractNormalizedExtractorFields extracts entities from custom extractors
+// into a map shaped like ExtractCustomExtractorFields, but with the value
+// Document AI normalized (ISO 8601 dates, money amounts, addresses, ...)
+// wherever it has one, and the text as found in the document otherwise
+func ExtractNormalizedExtractorFields(docProto *documentaipb.Document) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	if docProto == nil || len(docProto.Entities) == 0 {
+		return fields
+	}
+
+	for _, entity := range docProto.Entities {
+		if entity.Type == "" {
+			continue
+		}
+		processEntity(entity, fields, normalizedOrMentionText)
+	}
+
+	return fields
+}
+
+// mentionText returns the text of an entity as found in the document
+func mentionText(entity *documentaipb.Document_Entity) string {
+	return entity.MentionText
+}
+
+// normalizedOrMentionText returns the normalized value of an entity, or its
+// text as found in the document if it has none
+func normalizedOrMentionText(entity *documentaipb.Document_Entity) string {
+	if value := NormalizedEntityValue(entity); value != "" {
+		return value
+	}
+	return entity.MentionText
+}
+
 // processEntity handles a single entity and adds it to the provided fields map
-// This function works recursively to handle any level of nesting
-func processEntity(entity *documentaipb.Document_Entity, fields map[string]interface{}) {
+// This function works recursively to handle any level of nesting, taking
+// the value of each entity from valueOf
+func processEntity(entity *documentaipb.Document_Entity, fields map[string]interface{},
+	valueOf func(*documentaipb.Document_Entity) string) {
 	key := entity.Type
-	value := entity.MentionText
+	value := valueOf(entity)
 
 	// If the entity has properties, create a nested map
 	if len(entity.Properties) > 0 {
@@ -58,7 +95,7 @@ func processEntity(entity *documentaipb.Document_Entity, fields map[string]inter
 
 		// Process all properties recursively
 		for _, prop := range entity.Properties {
-			processEntity(prop, propMap)
+			processEntity(prop, propMap, valueOf)
 		}
 
 		// Add the properties map to the fields
@@ -30,12 +30,20 @@ func DocumentFromProto(doc *documentaipb.Document) *Document {
 
 	// Create form data wrapper
 	formData := &FormData{
-		Fields: formFields,
+		Fields:  formFields,
+		Details: ExtractFormFieldDetails(doc),
 	}
 
 	// Create custom extractor data wrapper
 	customExtractorData := &CustomExtractorData{
-		Fields: customExtractorFields,
+		Fields:     customExtractorFields,
+		Normalized: ExtractNormalizedExtractorFields(doc),
+		Details:    ExtractCustomExtractorFieldDetails(doc),
+	}
+
+	// Create tables wrapper
+	tableData := &TableData{
+		Tables: ExtractTables(doc),
 	}
 
 	// Create text content wrapper
@@ -60,6 +68,8 @@ func DocumentFromProto(doc *documentaipb.Document) *Document {
 		Hocr:                  hocrContent,
 		FormFields:            formData,
 		CustomExtractorFields: customExtractorData,
+		Tables:                tableData,
+		Layout:                ExtractLayout(doc),
 	}
 }
 
@@ -76,6 +86,7 @@ func createPagesFromProtoDoc(doc *documentaipb.Document) []*Page {
 			DocumentText:     doc.Text,
 			PageNumber:       pageNum,
 			Text:             textFromLayout(page.Layout, doc.Text),
+			ImageQuality:     imageQualityFromProto(page),
 		}
 
 		// Collect form fields
@@ -89,6 +100,12 @@ func createPagesFromProtoDoc(doc *documentaipb.Document) []*Page {
 			})
 		}
 
+		// Collect tables
+		docAiPage.Tables = make([]*Table, 0, len(page.Tables))
+		for i, table := range page.Tables {
+			docAiPage.Tables = append(docAiPage.Tables, tableFromProto(table, pageNum, i+1, doc.Text))
+		}
+
 		// Collect tokens (words)
 		docAiPage.Tokens = make([]*Token, 0, len(page.Tokens))
 		for _, token := range page.Tokens {
@@ -0,0 +1,58 @@
+package gdocai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+	"github.com/gardar/ocrchestra/pkg/ocrengine"
+)
+
+// Engine adapts Google Document AI to the ocrengine.Engine interface
+type Engine struct {
+	Config *Config
+}
+
+// NewEngine returns a Document AI backed OCR engine
+func NewEngine(cfg *Config) *Engine {
+	return &Engine{Config: cfg}
+}
+
+// Name identifies the engine
+func (e *Engine) Name() string {
+	return "gdocai"
+}
+
+// Process sends the input to Document AI and converts the response to HOCR.
+// The input languages, as BCP-47 codes (e.g. "en", "de"), are sent as language
+// hints unless the Config sets LanguageHints; without either, Document AI
+// detects the languages itself.
+func (e *Engine) Process(ctx context.Context, input ocrengine.Input) (*hocr.HOCR, error) {
+	if e.Config == nil {
+		return nil, fmt.Errorf("Document AI config is nil")
+	}
+	cfg := e.Config
+	if len(cfg.LanguageHints) == 0 && len(input.Languages) > 0 {
+		// The copy shares the request limiter, so MaxQPS and MaxConcurrent
+		// still apply across calls
+		cfg.requestLimiter()
+		withHints := *cfg
+		withHints.LanguageHints = input.Languages
+		cfg = &withHints
+	}
+
+	mimeType := input.MimeType
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+
+	rawDoc, err := processRawDocument(ctx, input.Data, mimeType, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateHOCRStruct(rawDoc)
+}
+
+// Ensure Engine satisfies the ocrengine.Engine interface
+var _ ocrengine.Engine = (*Engine)(nil)
@@ -0,0 +1,67 @@
+package gdocai
+
+import (
+	"fmt"
+
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// SyncPageLimit is the most pages an online (synchronous) processing request
+// takes. ProcessDocument splits larger PDFs into chunks of this many pages;
+// other documents have to be split or batch processed.
+const SyncPageLimit = 15
+
+// ProcessorType is the kind of a processor, which sets the price of a page
+type ProcessorType string
+
+// Processor types with a list price in PagePrices
+const (
+	ProcessorOCR             ProcessorType = "ocr"              // Enterprise Document OCR
+	ProcessorForm            ProcessorType = "form"             // Form Parser
+	ProcessorLayout          ProcessorType = "layout"           // Layout Parser
+	ProcessorCustomExtractor ProcessorType = "custom-extractor" // Custom Extractor
+)
+
+// PagePrices are the Document AI list prices in USD per 1,000 pages, for the
+// first pricing tier. Prices vary by region, volume and contract, so the
+// estimates are a guide rather than a quote.
+var PagePrices = map[ProcessorType]float64{
+	ProcessorOCR:             1.50,
+	ProcessorForm:            30.00,
+	ProcessorLayout:          10.00,
+	ProcessorCustomExtractor: 30.00,
+}
+
+// Estimate is the expected cost of processing a document, worked out
+// locally before anything is sent to Document AI
+type Estimate struct {
+	Pages        int     // Pages of the document
+	PricePer1000 float64 // Price in USD per 1,000 pages
+	Cost         float64 // Estimated cost in USD
+	OverLimit    bool    // Whether the document has more pages than SyncPageLimit
+}
+
+// EstimatePages estimates the cost of processing a document of the given
+// pages with a processor of the given type
+func EstimatePages(pages int, processor ProcessorType) (Estimate, error) {
+	price, ok := PagePrices[processor]
+	if !ok {
+		return Estimate{}, fmt.Errorf("unknown processor type %q (expected ocr, form, layout or custom-extractor)", processor)
+	}
+	return Estimate{
+		Pages:        pages,
+		PricePer1000: price,
+		Cost:         float64(pages) * price / 1000,
+		OverLimit:    pages > SyncPageLimit,
+	}, nil
+}
+
+// EstimatePDF counts the pages of a PDF locally and estimates the cost of
+// processing it with a processor of the given type
+func EstimatePDF(pdfBytes []byte, processor ProcessorType) (Estimate, error) {
+	pages, err := pdfocr.PageCount(pdfBytes)
+	if err != nil {
+		return Estimate{}, fmt.Errorf("failed to count pages: %w", err)
+	}
+	return EstimatePages(pages, processor)
+}
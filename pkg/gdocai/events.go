@@ -0,0 +1,26 @@
+package gdocai
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Structured events emitted through Config.Logger. Every record carries the
+// event name in its "event" attribute, so handlers can filter on it.
+const (
+	EventDocumentProcessed = "document_processed" // Document AI returned a processed document (info)
+	EventDocumentChunked   = "document_chunked"   // A PDF over SyncPageLimit was split into chunks processed one by one (info)
+	EventBatchStarted      = "batch_started"      // A batch processing operation was started (info)
+	EventBatchProgress     = "batch_progress"     // A batch processing operation was polled (info)
+	EventThrottled         = "throttled"          // A request waited for the MaxQPS or MaxConcurrent limit (info)
+	EventCacheHit          = "cache_hit"          // A response was read from Config.Cache instead of processing the document (info)
+	EventCacheError        = "cache_error"        // Config.Cache failed and the document was processed regardless (warn)
+)
+
+// logEvent emits a structured event through the configured slog logger, if any
+func (cfg *Config) logEvent(level slog.Level, event, msg string, args ...any) {
+	if cfg == nil || cfg.Logger == nil {
+		return
+	}
+	cfg.Logger.Log(context.Background(), level, msg, append([]any{slog.String("event", event)}, args...)...)
+}
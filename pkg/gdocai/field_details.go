@@ -0,0 +1,131 @@
+package gdocai
+
+import (
+	"math"
+	"strings"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+// FieldDetail is an extracted field value with the confidence and location
+// Document AI reported for it, so uncertain values can be routed to review
+type FieldDetail struct {
+	Source          string        `json:"source"`                     // FieldSourceForm or FieldSourceExtractor
+	Field           string        `json:"field"`                      // Field name, with nested properties as dotted paths
+	Value           string        `json:"value"`                      // Field value
+	NormalizedValue string        `json:"normalized_value,omitempty"` // Value normalized by Document AI, e.g. an ISO 8601 date
+	Confidence      float64       `json:"confidence"`                 // Confidence of the value (0-100)
+	NameConfidence  float64       `json:"name_confidence,omitempty"`  // Confidence of a form field's name (0-100)
+	Page            int           `json:"page,omitempty"`             // Page of the value (1-based), 0 if unknown
+	BBox            *[4]float64   `json:"bbox,omitempty"`             // x1, y1, x2, y2 of the value in page pixels
+	TextSegments    []TextSegment `json:"text_segments,omitempty"`    // Where the value is in the document text
+}
+
+// TextSegment is a range of the document text, as rune offsets
+type TextSegment struct {
+	Start int `json:"start"` // Offset of the first character
+	End   int `json:"end"`   // Offset after the last character
+}
+
+// ExtractFormFieldDetails returns every form field of the document, in page
+// order, with the confidence, page and position of its value. Unlike
+// ExtractFormFields, repeated field names are kept as separate details.
+func ExtractFormFieldDetails(docProto *documentaipb.Document) []FieldDetail {
+	var details []FieldDetail
+	for i, page := range docProto.GetPages() {
+		pageNumber := int(page.PageNumber)
+		if pageNumber == 0 {
+			pageNumber = i + 1
+		}
+		for _, field := range page.FormFields {
+			key := strings.TrimSpace(textFromLayout(field.FieldName, docProto.Text))
+			key = strings.TrimSuffix(key, ":")
+			if key == "" {
+				continue
+			}
+			details = append(details, FieldDetail{
+				Source:         FieldSourceForm,
+				Field:          key,
+				Value:          strings.TrimSpace(textFromLayout(field.FieldValue, docProto.Text)),
+				Confidence:     float64(field.FieldValue.GetConfidence() * 100),
+				NameConfidence: float64(field.FieldName.GetConfidence() * 100),
+				Page:           pageNumber,
+				BBox:           layoutBox(field.FieldValue.GetBoundingPoly(), page.Dimension),
+				TextSegments:   textSegments(field.FieldValue.GetTextAnchor()),
+			})
+		}
+	}
+	return details
+}
+
+// ExtractCustomExtractorFieldDetails returns every entity found by a custom
+// extractor, in document order, with its confidence and where it was found.
+// Properties of nested entities follow their parent with dotted paths.
+func ExtractCustomExtractorFieldDetails(docProto *documentaipb.Document) []FieldDetail {
+	var details []FieldDetail
+	for _, entity := range docProto.GetEntities() {
+		if entity.Type == "" {
+			continue
+		}
+		details = appendEntityDetails(details, docProto, entity, "")
+	}
+	return details
+}
+
+// appendEntityDetails appends the details of an entity and its properties
+func appendEntityDetails(details []FieldDetail, docProto *documentaipb.Document,
+	entity *documentaipb.Document_Entity, prefix string) []FieldDetail {
+	if entity.Type == "" {
+		return details
+	}
+	path := prefix + entity.Type
+
+	// Entities that only group properties have no value of their own
+	if entity.MentionText != "" || len(entity.Properties) == 0 {
+		detail := FieldDetail{
+			Source:          FieldSourceExtractor,
+			Field:           path,
+			Value:           entity.MentionText,
+			NormalizedValue: NormalizedEntityValue(entity),
+			Confidence:      float64(entity.Confidence * 100),
+			TextSegments:    textSegments(entity.TextAnchor),
+		}
+		if refs := entity.GetPageAnchor().GetPageRefs(); len(refs) > 0 {
+			page := int(refs[0].Page)
+			detail.Page = page + 1
+			if page >= 0 && page < len(docProto.Pages) {
+				detail.BBox = layoutBox(refs[0].BoundingPoly, docProto.Pages[page].Dimension)
+			}
+		}
+		details = append(details, detail)
+	}
+
+	for _, property := range entity.Properties {
+		details = appendEntityDetails(details, docProto, property, path+".")
+	}
+	return details
+}
+
+// layoutBox converts a normalized bounding polygon to a box in whole page pixels
+func layoutBox(poly *documentaipb.BoundingPoly, dimension *documentaipb.Document_Page_Dimension) *[4]float64 {
+	if poly == nil || dimension == nil || len(poly.NormalizedVertices) == 0 {
+		return nil
+	}
+	vertices := poly.NormalizedVertices
+	box := [4]float64{float64(vertices[0].X), float64(vertices[0].Y), float64(vertices[0].X), float64(vertices[0].Y)}
+	for _, vertex := range vertices[1:] {
+		box[0], box[1] = min(box[0], float64(vertex.X)), min(box[1], float64(vertex.Y))
+		box[2], box[3] = max(box[2], float64(vertex.X)), max(box[3], float64(vertex.Y))
+	}
+	width, height := float64(dimension.Width), float64(dimension.Height)
+	return &[4]float64{math.Round(box[0] * width), math.Round(box[1] * height), math.Round(box[2] * width), math.Round(box[3] * height)}
+}
+
+// textSegments returns the ranges of the document text a text anchor points to
+func textSegments(anchor *documentaipb.Document_TextAnchor) []TextSegment {
+	var segments []TextSegment
+	for _, segment := range anchor.GetTextSegments() {
+		segments = append(segments, TextSegment{Start: int(segment.StartIndex), End: int(segment.EndIndex)})
+	}
+	return segments
+}
@@ -0,0 +1,163 @@
+package gdocai
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Field sources, named like the placeholder prefixes used in output paths
+const (
+	FieldSourceForm      = "form_field"
+	FieldSourceExtractor = "extractor_field"
+)
+
+// FieldRecord is a single field value flattened for spreadsheets
+type FieldRecord struct {
+	Source string // FieldSourceForm or FieldSourceExtractor
+	Field  string // Field name, with nested properties as dotted paths, e.g. "line_item.amount"
+	Value  string // Field value
+}
+
+// fieldsHeader is the header row of flattened field exports
+var fieldsHeader = []string{"source", "field", "value"}
+
+// FlattenFields flattens the form fields and custom extractor fields of a
+// document into one record per value, sorted by field name within each
+// source. Nested extractor properties become dotted paths, the value of an
+// entity that also has properties is listed under the entity's own name, and
+// fields with several values get a record for each.
+func FlattenFields(doc *Document) []FieldRecord {
+	var records []FieldRecord
+	if doc.FormFields != nil {
+		records = appendFieldRecords(records, FieldSourceForm, "", doc.FormFields.Fields)
+	}
+	if doc.CustomExtractorFields != nil {
+		records = appendFieldRecords(records, FieldSourceExtractor, "", doc.CustomExtractorFields.Fields)
+	}
+	return records
+}
+
+// appendFieldRecords appends the records of a field map, prefixing its keys with a path
+func appendFieldRecords(records []FieldRecord, source, prefix string, fields map[string]interface{}) []FieldRecord {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		path := prefix + key
+		if key == "_value" {
+			path = strings.TrimSuffix(prefix, ".")
+		}
+		switch value := fields[key].(type) {
+		case string:
+			records = append(records, FieldRecord{Source: source, Field: path, Value: value})
+		case []string:
+			for _, item := range value {
+				records = append(records, FieldRecord{Source: source, Field: path, Value: item})
+			}
+		case map[string]interface{}:
+			records = appendFieldRecords(records, source, path+".", value)
+		}
+	}
+	return records
+}
+
+// FieldsToCSV renders flattened fields as CSV with source, field and value columns
+func FieldsToCSV(records []FieldRecord) (string, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(fieldsHeader)
+	for _, record := range records {
+		writer.Write([]string{record.Source, record.Field, record.Value})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("failed to write fields CSV: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// FieldsToXLSX renders flattened fields as an Excel workbook with a single
+// "Fields" sheet that has source, field and value columns. Values are stored
+// as text so identifiers such as invoice numbers keep their leading zeros.
+func FieldsToXLSX(records []FieldRecord) ([]byte, error) {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	rows := [][]string{fieldsHeader}
+	for _, record := range records {
+		rows = append(rows, []string{record.Source, record.Field, record.Value})
+	}
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			fmt.Fprintf(&sheet, `<c r="%c%d" t="inlineStr"><is><t xml:space="preserve">`, 'A'+j, i+1)
+			if err := xml.EscapeText(&sheet, []byte(xlsxText(value))); err != nil {
+				return nil, fmt.Errorf("failed to write fields XLSX: %w", err)
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Fields" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, part := range parts {
+		writer, err := archive.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write fields XLSX: %w", err)
+		}
+		if _, err := writer.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("failed to write fields XLSX: %w", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write fields XLSX: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// xlsxText removes the control characters XML can't represent and cuts text
+// to the 32767 characters a spreadsheet cell holds
+func xlsxText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, text)
+	if runes := []rune(text); len(runes) > 32767 {
+		text = string(runes[:32767])
+	}
+	return text
+}
@@ -0,0 +1,376 @@
+package gdocai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// BatchStorage is the Cloud Storage location used to stage batch inputs and results.
+// Inputs are uploaded below <Prefix>/input/ and Document AI writes results below <Prefix>/output/.
+type BatchStorage struct {
+	Bucket string // Bucket name, without the gs:// scheme
+	Prefix string // Object name prefix for this job (optional)
+}
+
+// ParseBatchStorage parses a gs://bucket/prefix URI into a BatchStorage
+func ParseBatchStorage(uri string) (BatchStorage, error) {
+	rest, ok := strings.CutPrefix(uri, "gs://")
+	if !ok {
+		return BatchStorage{}, fmt.Errorf("invalid Cloud Storage URI %q: must start with gs://", uri)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return BatchStorage{}, fmt.Errorf("invalid Cloud Storage URI %q: missing bucket", uri)
+	}
+	return BatchStorage{Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// objectName joins the storage prefix with the given path elements
+func (s BatchStorage) objectName(elem ...string) string {
+	return path.Join(append([]string{s.Prefix}, elem...)...)
+}
+
+// uri returns the gs:// URI of an object or directory in the storage location
+func (s BatchStorage) uri(elem ...string) string {
+	return fmt.Sprintf("gs://%s/%s", s.Bucket, s.objectName(elem...))
+}
+
+// IsGCSURI reports whether a path is a gs:// Cloud Storage URI
+func IsGCSURI(path string) bool {
+	return strings.HasPrefix(path, "gs://")
+}
+
+// parseObjectURI splits a gs://bucket/object URI into the bucket and object name
+func parseObjectURI(uri string) (string, string, error) {
+	location, err := ParseBatchStorage(uri)
+	if err != nil {
+		return "", "", err
+	}
+	if location.Prefix == "" || strings.HasSuffix(uri, "/") {
+		return "", "", fmt.Errorf("invalid Cloud Storage URI %q: missing object name", uri)
+	}
+	return location.Bucket, location.Prefix, nil
+}
+
+// ReadObject downloads the content of a gs://bucket/object URI with the
+// credentials of cfg, or Application Default Credentials if cfg is nil
+func ReadObject(ctx context.Context, cfg *Config, uri string) ([]byte, error) {
+	bucket, name, err := parseObjectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newStorageClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reader, err := client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	return data, nil
+}
+
+// WriteObject uploads data to a gs://bucket/object URI with the credentials of
+// cfg, or Application Default Credentials if cfg is nil, replacing any existing
+// object. The content type is optional and detected from the data if empty.
+func WriteObject(ctx context.Context, cfg *Config, uri string, data []byte, contentType string) error {
+	bucket, name, err := parseObjectURI(uri)
+	if err != nil {
+		return err
+	}
+
+	client, err := newStorageClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	writer := client.Bucket(bucket).Object(name).NewWriter(ctx)
+	writer.ContentType = contentType
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write %s: %w", uri, err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", uri, err)
+	}
+	return nil
+}
+
+// BatchDocument is a document to upload for batch processing
+type BatchDocument struct {
+	Name     string // File name, unique within the batch
+	Content  []byte // Document bytes
+	MimeType string // MIME type (defaults to application/pdf)
+}
+
+// newStorageClient creates a Cloud Storage client using the same credentials
+// as Document AI, or Application Default Credentials if cfg is nil
+func newStorageClient(ctx context.Context, cfg *Config) (*storage.Client, error) {
+	opts, err := cfg.clientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Storage client: %w", err)
+	}
+	return client, nil
+}
+
+// UploadBatchInputs uploads documents to the staging location and returns
+// their gs:// URIs, in the same order, for use in BatchOptions.InputURIs.
+// Cloud Storage is accessed with the credentials of cfg, or Application
+// Default Credentials if cfg is nil.
+func UploadBatchInputs(ctx context.Context, cfg *Config, stage BatchStorage, docs []BatchDocument) ([]string, error) {
+	client, err := newStorageClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(stage.Bucket)
+	var uris []string
+	for _, doc := range docs {
+		if doc.Name == "" {
+			return nil, fmt.Errorf("batch document name is required")
+		}
+
+		writer := bucket.Object(stage.objectName("input", doc.Name)).NewWriter(ctx)
+		writer.ContentType = doc.MimeType
+		if writer.ContentType == "" {
+			writer.ContentType = "application/pdf"
+		}
+		if _, err := writer.Write(doc.Content); err != nil {
+			writer.Close()
+			return nil, fmt.Errorf("failed to upload %s: %w", doc.Name, err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", doc.Name, err)
+		}
+
+		uris = append(uris, stage.uri("input", doc.Name))
+	}
+
+	return uris, nil
+}
+
+// DownloadBatchResults downloads the result of every document in a finished
+// batch operation, in the order reported by the status. Documents that
+// Document AI split into several shards are merged back into one.
+// Cloud Storage is accessed with the credentials of cfg, or Application
+// Default Credentials if cfg is nil.
+func DownloadBatchResults(ctx context.Context, cfg *Config, status *BatchStatus) ([]*documentaipb.Document, error) {
+	if status == nil || !status.Done {
+		return nil, fmt.Errorf("batch operation has not finished")
+	}
+
+	client, err := newStorageClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var results []*documentaipb.Document
+	for _, doc := range status.Documents {
+		if doc.Error != "" {
+			return nil, fmt.Errorf("processing %s failed: %s", doc.InputURI, doc.Error)
+		}
+		result, err := downloadDocument(ctx, client, doc.OutputURI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download result for %s: %w", doc.InputURI, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// downloadDocument reads all JSON shards below an output URI and merges them
+func downloadDocument(ctx context.Context, client *storage.Client, outputURI string) (*documentaipb.Document, error) {
+	location, err := ParseBatchStorage(outputURI)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := client.Bucket(location.Bucket)
+	it := bucket.Objects(ctx, &storage.Query{Prefix: location.Prefix + "/"})
+
+	var shards []*documentaipb.Document
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list results: %w", err)
+		}
+		if !strings.HasSuffix(attrs.Name, ".json") {
+			continue
+		}
+
+		reader, err := bucket.Object(attrs.Name).NewReader(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", attrs.Name, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", attrs.Name, err)
+		}
+
+		shard := &documentaipb.Document{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, shard); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", attrs.Name, err)
+		}
+		shards = append(shards, shard)
+	}
+
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no results found at %s", outputURI)
+	}
+
+	return mergeShards(shards), nil
+}
+
+// mergeShards combines sharded Document AI output into a single document.
+// Text anchors in each shard index into that shard's text, so they are
+// shifted by the shard's offset in the combined text. Anchors count
+// characters, not bytes, so the offset is the shard's reported text offset,
+// or the number of characters before it.
+func mergeShards(shards []*documentaipb.Document) *documentaipb.Document {
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].GetShardInfo().GetShardIndex() < shards[j].GetShardInfo().GetShardIndex()
+	})
+
+	merged := shards[0]
+	for _, shard := range shards[1:] {
+		offset := shard.GetShardInfo().GetTextOffset()
+		if offset == 0 {
+			offset = int64(utf8.RuneCountInString(merged.Text))
+		}
+		shiftTextAnchors(shard.ProtoReflect(), offset)
+
+		merged.Text += shard.Text
+		merged.Pages = append(merged.Pages, shard.Pages...)
+		merged.Entities = append(merged.Entities, shard.Entities...)
+		merged.EntityRelations = append(merged.EntityRelations, shard.EntityRelations...)
+		merged.TextStyles = append(merged.TextStyles, shard.TextStyles...)
+	}
+	merged.ShardInfo = nil
+
+	return merged
+}
+
+// shiftTextAnchors adds an offset to every text segment in a message tree
+func shiftTextAnchors(m protoreflect.Message, offset int64) {
+	if segment, ok := m.Interface().(*documentaipb.Document_TextAnchor_TextSegment); ok {
+		segment.StartIndex += offset
+		segment.EndIndex += offset
+		return
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil || fd.IsMap() {
+			return true
+		}
+		if fd.IsList() {
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				shiftTextAnchors(list.Get(i).Message(), offset)
+			}
+			return true
+		}
+		shiftTextAnchors(v.Message(), offset)
+		return true
+	})
+}
+
+// ProcessDocumentsBatch runs documents through Document AI batch processing:
+// it uploads them to the staging location, starts the batch operation,
+// waits for it to finish and downloads and converts the results.
+// Use it for documents beyond the synchronous API limits. For jobs that may
+// outlive the process, use UploadBatchInputs, StartBatchProcess, WaitForBatch
+// and DownloadBatchResults individually and persist the operation name.
+func ProcessDocumentsBatch(ctx context.Context, cfg *Config, stage BatchStorage, docs []BatchDocument, poll PollOptions) ([]*Document, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no documents to process")
+	}
+
+	uris, err := UploadBatchInputs(ctx, cfg, stage, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeTypes := make([]string, len(docs))
+	for i, doc := range docs {
+		mimeTypes[i] = doc.MimeType
+	}
+	status, err := StartBatchProcess(ctx, cfg, BatchOptions{
+		InputURIs: uris,
+		OutputURI: stage.uri("output"),
+		MimeTypes: mimeTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, err = WaitForBatch(ctx, cfg, status.Name, poll)
+	if err != nil {
+		return nil, err
+	}
+
+	// Results are reported per input; keep them in the order the documents were given
+	order := make(map[string]int, len(uris))
+	for i, uri := range uris {
+		order[uri] = i
+	}
+	sort.SliceStable(status.Documents, func(i, j int) bool {
+		return order[status.Documents[i].InputURI] < order[status.Documents[j].InputURI]
+	})
+
+	rawDocs, err := DownloadBatchResults(ctx, cfg, status)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Document
+	for _, rawDoc := range rawDocs {
+		results = append(results, DocumentFromProto(rawDoc))
+	}
+	return results, nil
+}
+
+// DocumentHOCRBatch processes a single PDF through batch processing and
+// returns the structured document and hOCR HTML, like DocumentHOCR
+func DocumentHOCRBatch(ctx context.Context, pdfBytes []byte, name string, cfg *Config, stage BatchStorage, poll PollOptions) (*Document, string, error) {
+	docs, err := ProcessDocumentsBatch(ctx, cfg, stage, []BatchDocument{{Name: name, Content: pdfBytes}}, poll)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to batch process document: %w", err)
+	}
+	if len(docs) != 1 {
+		return nil, "", fmt.Errorf("expected 1 batch result, got %d", len(docs))
+	}
+	return docs[0], docs[0].Hocr.HTML, nil
+}
@@ -0,0 +1,68 @@
+package gdocai
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// readShards reads the shards of a sharded batch result, last shard first
+func readShards(t *testing.T) []*documentaipb.Document {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "shards", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shards []*documentaipb.Document
+	for _, path := range slices.Backward(paths) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shard := &documentaipb.Document{}
+		if err := protojson.Unmarshal(data, shard); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+func TestMergeShards(t *testing.T) {
+	tests := []struct {
+		name       string
+		textOffset bool // Whether the shards report their text offset
+	}{
+		{"reported text offset", true},
+		{"counted text offset", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := readShards(t)
+			if !tt.textOffset {
+				for _, shard := range shards {
+					shard.ShardInfo.TextOffset = 0
+				}
+			}
+
+			merged := mergeShards(shards)
+			if want := "Größe café\nStraße €5\n"; merged.Text != want {
+				t.Errorf("text = %q, want %q", merged.Text, want)
+			}
+			var tokens []string
+			for _, page := range merged.Pages {
+				for _, token := range page.Tokens {
+					tokens = append(tokens, textFromLayout(token.Layout, merged.Text))
+				}
+			}
+			if want := []string{"Größe", "café", "Straße", "€5"}; !slices.Equal(tokens, want) {
+				t.Errorf("tokens = %q, want %q", tokens, want)
+			}
+		})
+	}
+}
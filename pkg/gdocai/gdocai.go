@@ -7,7 +7,7 @@
 // Key Features:
 //
 // - Process PDFs with Google Document AI to extract text and structural information
-// - Create searchable PDFs with transparent OCR text overlaid at precise positions
+// - Create searchable PDFs with invisible OCR text overlaid at precise positions
 // - Extract form fields from documents with form elements
 // - Extract fields from custom extractors with full support for nested hierarchies
 // - Generate HOCR data for advanced OCR workflows
@@ -18,19 +18,35 @@
 //
 // Main Functions:
 //
-// - ProcessDocument: Sends a document to Google Document AI for processing
+// - Preprocess: Cleans up a scanned PDF (deskew, contrast, binarize, ...) before it is sent
+// - EstimatePDF / EstimatePages: Count the pages of a document locally and estimate the Document AI cost
+// - ProcessDocument: Sends a document to Google Document AI for processing, in chunks beyond SyncPageLimit pages
+// - NewClient: Creates a Document AI client to share between calls through Config.Client
+// - DirCache: Caches Document AI responses in a directory through Config.Cache, or implement Cache
 // - DocumentFromProto: Converts Document AI response to a structured format
+// - RawDocumentFromJSON: Reads a Document AI response saved as JSON, to convert it again offline
+// - DocumentFromJSON: Loads a Document saved with ToJSON (-debug-doc) for two-phase pipelines and tests
 // - DocumentHOCR: Processes a document and returns the structured data plus hOCR HTML
 // - DocumentHOCRFromPages: Processes multiple pages as a single document and returns the hOCR HTML
+// - DocumentHOCRFromImage: As DocumentHOCR for an image, such as a multipage TIFF scan
 // - ExtractFormFields: Gets form fields from the document as a map
 // - ExtractCustomExtractorFields: Gets custom extractor fields from the document as a nested map
+// - FlattenFields / FieldsToCSV / FieldsToXLSX: Export form and custom extractor fields as spreadsheets
+// - ExtractNormalizedExtractorFields / NormalizedEntityValue: Extractor fields normalized by Document AI, e.g. ISO 8601 dates
+// - ExtractFormFieldDetails / ExtractCustomExtractorFieldDetails: Fields with confidence, page and bounding box
+// - ToMarkdown: Renders the paragraphs, tables and form fields of a document as Markdown
+// - ExtractLayout / LayoutToMarkdown / ChunksToJSONL: Layout Parser blocks and chunks as Markdown and JSON Lines
+// - ExtractTables: Gets the tables from the document as rows of cells, exportable as CSV or JSON
 // - ExtractImageFromPage: Extracts the image data from a document page
+// - StartBatchProcess / GetBatchStatus / WaitForBatch / CancelBatch: Run and resume batch jobs
+// - ProcessDocumentsBatch: Uploads documents to Cloud Storage, batch processes them and downloads the results
+// - ReadObject / WriteObject: Read and write gs:// Cloud Storage objects
 //
 // Usage Requirements:
 //
 // - Google Cloud project with Document AI API enabled
 // - Document AI processor configured for OCR
-// - Authentication via GOOGLE_APPLICATION_CREDENTIALS environment variable
+// - Authentication via Application Default Credentials or the credentials set in Config
 package gdocai
 
 import (
@@ -60,6 +76,18 @@ func DocumentHOCR(ctx context.Context, pdfBytes []byte, cfg *Config) (*Document,
 	return doc, doc.Hocr.HTML, nil
 }
 
+// DocumentHOCRFromImage processes an image, such as a multipage TIFF scan,
+// with Document AI the way DocumentHOCR processes a PDF. mimeType is the
+// type of the image, e.g. "image/tiff".
+func DocumentHOCRFromImage(ctx context.Context, imageBytes []byte, mimeType string, cfg *Config) (*Document, string, error) {
+	rawDoc, err := processRawDocument(ctx, imageBytes, mimeType, cfg)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to process document: %w", err)
+	}
+	doc := DocumentFromProto(rawDoc)
+	return doc, doc.Hocr.HTML, nil
+}
+
 // DocumentHOCRFromPages processes multiple PDFs as individual pages
 // and combines them into a single document.
 //
@@ -71,6 +99,7 @@ func DocumentHOCR(ctx context.Context, pdfBytes []byte, cfg *Config) (*Document,
 func DocumentHOCRFromPages(ctx context.Context, pagePdfBytesList [][]byte, cfg *Config) (*Document, string, error) {
 	var hocrPages []hocr.Page
 	var structuredPages []*Page
+	var tables []*Table
 	var fullText string
 
 	// Process each page individually
@@ -92,8 +121,13 @@ func DocumentHOCRFromPages(ctx context.Context, pagePdfBytesList [][]byte, cfg *
 			DocumentText:     pageDoc.Text,
 			PageNumber:       pageNum,
 			Text:             textFromLayout(pageDoc.Pages[0].Layout, pageDoc.Text),
+			ImageQuality:     imageQualityFromProto(pageDoc.Pages[0]),
+		}
+		for j, table := range pageDoc.Pages[0].Tables {
+			docAiPage.Tables = append(docAiPage.Tables, tableFromProto(table, pageNum, j+1, pageDoc.Text))
 		}
 		structuredPages = append(structuredPages, docAiPage)
+		tables = append(tables, docAiPage.Tables...)
 
 		// Append this page's text to the full text
 		if i > 0 {
@@ -140,6 +174,9 @@ func DocumentHOCRFromPages(ctx context.Context, pagePdfBytesList [][]byte, cfg *
 		CustomExtractorFields: &CustomExtractorData{
 			Fields: make(map[string]interface{}),
 		},
+		Tables: &TableData{
+			Tables: tables,
+		},
 		// Other fields as needed
 	}
 
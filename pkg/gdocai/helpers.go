@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
@@ -30,6 +31,18 @@ func ToJSON(data interface{}) (string, error) {
 	}
 }
 
+// RawDocumentFromJSON reads a Document AI response saved as JSON, e.g. by
+// ToJSON(doc.Raw.Document) or the -debug-api flag, so it can be converted
+// with DocumentFromProto again without contacting Document AI. Fields
+// unknown to this version of the API are ignored.
+func RawDocumentFromJSON(jsonData []byte) (*documentaipb.Document, error) {
+	doc := &documentaipb.Document{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(jsonData, doc); err != nil {
+		return nil, fmt.Errorf("invalid Document AI response JSON: %w", err)
+	}
+	return doc, nil
+}
+
 // ExtractImageFromPage pulls out the image data from a Document AI page
 func ExtractImageFromPage(page *Page) ([]byte, error) {
 	if page == nil || page.DocumentaiObject == nil {
@@ -67,6 +67,15 @@ func CreateHOCRDocument(docProto *documentaipb.Document, pages ...hocr.Page) (*h
 
 		// Update document with language information from all pages
 		updateDocumentLanguages(result)
+
+		if hasCharBoxes(result.Pages) {
+			result.Metadata["ocr-capabilities"] += " ocrx_cinfo"
+		}
+		for _, areaType := range []string{hocr.AreaTable, hocr.AreaPhoto} {
+			if hasAreaType(result.Pages, areaType) {
+				result.Metadata["ocr-capabilities"] += " " + areaType
+			}
+		}
 	}
 
 	return result, nil
@@ -157,6 +166,7 @@ func CreateHOCRPage(page *documentaipb.Document_Page, fullText string, pageNumbe
 
 		ocrPage.Areas = append(ocrPage.Areas, ocrArea)
 	}
+	ocrPage.Areas = append(groupTableAreas(page, ocrPage.Areas, fullText, pageNumber), photoAreas(page, pageNumber)...)
 
 	// Process paragraphs not assigned to any block or area
 	for pidx, para := range page.Paragraphs {
@@ -216,6 +226,75 @@ func CreateHOCRPage(page *documentaipb.Document_Page, fullText string, pageNumbe
 	return ocrPage, nil
 }
 
+// groupTableAreas replaces the areas of the blocks that make up a table with
+// one ocr_table area holding their paragraphs, in the place of its first block.
+// The areas are those of the page's blocks, in the same order.
+func groupTableAreas(page *documentaipb.Document_Page, areas []hocr.Area, fullText string, pageNumber int) []hocr.Area {
+	if len(page.Tables) == 0 {
+		return areas
+	}
+	tableOf := make([]int, len(areas))
+	for i := range areas {
+		tableOf[i] = -1
+		for tidx, table := range page.Tables {
+			if isElementInParent(page.Blocks[i].Layout, table.Layout, fullText) {
+				tableOf[i] = tidx
+				break
+			}
+		}
+	}
+
+	var result []hocr.Area
+	tableAreas := make(map[int]int) // Table index to its position in result
+	for i, area := range areas {
+		tidx := tableOf[i]
+		if tidx < 0 {
+			result = append(result, area)
+			continue
+		}
+		pos, ok := tableAreas[tidx]
+		if !ok {
+			tableArea := hocr.Area{
+				ID:       fmt.Sprintf("table_%d_%d", pageNumber, tidx),
+				Type:     hocr.AreaTable,
+				Metadata: make(map[string]string),
+			}
+			if bbox := hocr.ParseBoundingBoxFromTitle(getHocrBoundingBox(page.Tables[tidx].Layout, page.Dimension)); bbox != nil {
+				tableArea.BBox = *bbox
+			}
+			pos = len(result)
+			tableAreas[tidx] = pos
+			result = append(result, tableArea)
+		}
+		result[pos].Paragraphs = append(result[pos].Paragraphs, area.Paragraphs...)
+	}
+	return result
+}
+
+// photoAreas returns an ocr_photo area for every image Document AI found on
+// the page. They have no text.
+func photoAreas(page *documentaipb.Document_Page, pageNumber int) []hocr.Area {
+	var areas []hocr.Area
+	for vidx, element := range page.VisualElements {
+		switch element.Type {
+		case "image", "figure", "photo":
+		default:
+			continue
+		}
+		bbox := hocr.ParseBoundingBoxFromTitle(getHocrBoundingBox(element.Layout, page.Dimension))
+		if bbox == nil {
+			continue
+		}
+		areas = append(areas, hocr.Area{
+			ID:       fmt.Sprintf("photo_%d_%d", pageNumber, vidx),
+			Type:     hocr.AreaPhoto,
+			BBox:     *bbox,
+			Metadata: make(map[string]string),
+		})
+	}
+	return areas
+}
+
 // updateDocumentLanguages collects all languages used in the document and updates metadata
 func updateDocumentLanguages(result *hocr.HOCR) {
 	// Collect all languages used in the document
@@ -345,12 +424,38 @@ func getHocrBoundingBox(layout *documentaipb.Document_Page_Layout, dimension *do
 	if layout == nil || layout.BoundingPoly == nil || dimension == nil || len(layout.BoundingPoly.NormalizedVertices) < 4 {
 		return ""
 	}
+	// The vertices follow the text orientation, so rotated text doesn't start at the top-left
 	vertices := layout.BoundingPoly.NormalizedVertices
-	minX := int(vertices[0].X*dimension.Width + 0.5)
-	minY := int(vertices[0].Y*dimension.Height + 0.5)
-	maxX := int(vertices[2].X*dimension.Width + 0.5)
-	maxY := int(vertices[2].Y*dimension.Height + 0.5)
-	return fmt.Sprintf("bbox %d %d %d %d", minX, minY, maxX, maxY)
+	minX, minY := vertices[0].X, vertices[0].Y
+	maxX, maxY := minX, minY
+	for _, vertex := range vertices[1:] {
+		minX, maxX = min(minX, vertex.X), max(maxX, vertex.X)
+		minY, maxY = min(minY, vertex.Y), max(maxY, vertex.Y)
+	}
+	return fmt.Sprintf("bbox %d %d %d %d",
+		int(minX*dimension.Width+0.5), int(minY*dimension.Height+0.5),
+		int(maxX*dimension.Width+0.5), int(maxY*dimension.Height+0.5))
+}
+
+// getTextAngle converts a Document AI layout orientation to an hOCR textangle
+// (degrees counter-clockwise), falling back to the page orientation.
+// Upright text has no textangle.
+func getTextAngle(layout, pageLayout *documentaipb.Document_Page_Layout) string {
+	orientation := layout.GetOrientation()
+	if orientation == documentaipb.Document_Page_Layout_ORIENTATION_UNSPECIFIED {
+		orientation = pageLayout.GetOrientation()
+	}
+	switch orientation {
+	case documentaipb.Document_Page_Layout_PAGE_RIGHT:
+		// Read with the head turned 90° clockwise
+		return "270"
+	case documentaipb.Document_Page_Layout_PAGE_DOWN:
+		return "180"
+	case documentaipb.Document_Page_Layout_PAGE_LEFT:
+		return "90"
+	default:
+		return ""
+	}
 }
 
 // getDocumentLanguage finds the most common language in the document
@@ -436,6 +541,11 @@ func convertLineFromProto(line *documentaipb.Document_Page_Line, page *documenta
 		}
 	}
 
+	// Rotated lines carry their orientation as textangle
+	if angle := getTextAngle(line.Layout, page.Layout); angle != "" {
+		ocrLine.Metadata["textangle"] = angle
+	}
+
 	// Extract line language
 	if len(line.DetectedLanguages) > 0 {
 		ocrLine.Lang = line.DetectedLanguages[0].LanguageCode
@@ -482,6 +592,7 @@ func convertLineFromProto(line *documentaipb.Document_Page_Line, page *documenta
 		// Extract confidence
 		if token.Layout != nil {
 			word.Confidence = float64(token.Layout.Confidence * 100)
+			word.HasConfidence = true
 		}
 
 		// Extract language
@@ -489,8 +600,70 @@ func convertLineFromProto(line *documentaipb.Document_Page_Line, page *documenta
 			word.Lang = token.DetectedLanguages[0].LanguageCode
 		}
 
+		// Extract character boxes, returned with Config.SymbolBoxes
+		for _, symbol := range page.Symbols {
+			if !isElementInParent(symbol.Layout, token.Layout, fullText) {
+				continue
+			}
+			char := hocr.Char{
+				Text:       strings.TrimSpace(textFromLayout(symbol.Layout, fullText)),
+				Confidence: float64(symbol.Layout.GetConfidence() * 100),
+			}
+			if char.Text == "" {
+				continue
+			}
+			if bbox := hocr.ParseBoundingBoxFromTitle(getHocrBoundingBox(symbol.Layout, page.Dimension)); bbox != nil {
+				char.BBox = *bbox
+			}
+			word.Chars = append(word.Chars, char)
+		}
+		// The characters stand in for the word text in the hOCR, so they have to spell it
+		var spelled strings.Builder
+		for _, char := range word.Chars {
+			spelled.WriteString(char.Text)
+		}
+		if spelled.String() != strings.Join(strings.Fields(word.Text), "") {
+			word.Chars = nil
+		}
+
 		ocrLine.Words = append(ocrLine.Words, word)
 	}
 
 	return ocrLine
 }
+
+// hasAreaType reports whether any page has an area of the type
+func hasAreaType(pages []hocr.Page, areaType string) bool {
+	for _, page := range pages {
+		for _, area := range page.Areas {
+			if area.Type == areaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasCharBoxes reports whether any word of the pages has character boxes
+func hasCharBoxes(pages []hocr.Page) bool {
+	for _, page := range pages {
+		lines := append([]hocr.Line(nil), page.Lines...)
+		for _, para := range page.Paragraphs {
+			lines = append(lines, para.Lines...)
+		}
+		for _, area := range page.Areas {
+			for _, para := range area.Paragraphs {
+				lines = append(lines, para.Lines...)
+			}
+			lines = append(lines, area.Lines...)
+		}
+		for _, line := range lines {
+			for _, word := range line.Words {
+				if len(word.Chars) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
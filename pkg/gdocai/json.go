@@ -0,0 +1,82 @@
+package gdocai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// DocumentFromJSON loads a Document saved with ToJSON, e.g. by the -debug-doc
+// flag, so a document processed once can be assembled, renamed or tested
+// later without Document AI. The Document AI objects are restored too, so the
+// loaded document works with the functions that read them, such as
+// ExtractImageFromPage. Documents without them, e.g. written by hand for a
+// test, load as well.
+func DocumentFromJSON(jsonData []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(jsonData, doc); err != nil {
+		return nil, fmt.Errorf("invalid document JSON: %w", err)
+	}
+	return doc, nil
+}
+
+// MarshalJSON writes the Document AI response as protojson, the format
+// Document AI itself uses, which unlike encoding/json keeps oneof fields
+func (r RawDocument) MarshalJSON() ([]byte, error) {
+	if r.Document == nil {
+		return []byte("null"), nil
+	}
+	return protojson.Marshal(r.Document)
+}
+
+// UnmarshalJSON reads a Document AI response written by MarshalJSON
+func (r *RawDocument) UnmarshalJSON(data []byte) error {
+	doc := &documentaipb.Document{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, doc); err != nil {
+		return err
+	}
+	r.Document = doc
+	return nil
+}
+
+// layoutBlockJSON is a LayoutBlock with its Document AI block as protojson,
+// as the block is a oneof of text, table and list blocks
+type layoutBlockJSON struct {
+	*layoutBlockFields
+	DocumentaiObject json.RawMessage `json:",omitempty"`
+}
+
+// layoutBlockFields are the fields of a LayoutBlock without its methods
+type layoutBlockFields LayoutBlock
+
+// MarshalJSON writes the block with its Document AI block as protojson
+func (b LayoutBlock) MarshalJSON() ([]byte, error) {
+	v := layoutBlockJSON{layoutBlockFields: (*layoutBlockFields)(&b)}
+	if b.DocumentaiObject != nil {
+		object, err := protojson.Marshal(b.DocumentaiObject)
+		if err != nil {
+			return nil, err
+		}
+		v.DocumentaiObject = object
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON reads a block written by MarshalJSON
+func (b *LayoutBlock) UnmarshalJSON(data []byte) error {
+	v := layoutBlockJSON{layoutBlockFields: (*layoutBlockFields)(b)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b.DocumentaiObject = nil
+	if len(v.DocumentaiObject) > 0 && string(v.DocumentaiObject) != "null" {
+		object := &documentaipb.Document_DocumentLayout_DocumentLayoutBlock{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(v.DocumentaiObject, object); err != nil {
+			return err
+		}
+		b.DocumentaiObject = object
+	}
+	return nil
+}
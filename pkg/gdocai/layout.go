@@ -0,0 +1,219 @@
+package gdocai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+// Kinds of layout blocks
+const (
+	LayoutBlockText  = "text"
+	LayoutBlockTable = "table"
+	LayoutBlockList  = "list"
+)
+
+// ExtractLayout collects the document layout and chunks returned by a Layout
+// Parser processor. Documents from other processors have neither, and give
+// empty layout data.
+func ExtractLayout(docProto *documentaipb.Document) *LayoutData {
+	layout := &LayoutData{
+		Blocks: layoutBlocksFromProto(docProto.GetDocumentLayout().GetBlocks()),
+	}
+	for _, chunk := range docProto.GetChunkedDocument().GetChunks() {
+		layoutChunk := &Chunk{
+			DocumentaiObject: chunk,
+			ID:               chunk.ChunkId,
+			Content:          chunk.Content,
+			SourceBlockIDs:   chunk.SourceBlockIds,
+			PageStart:        int(chunk.GetPageSpan().GetPageStart()),
+			PageEnd:          int(chunk.GetPageSpan().GetPageEnd()),
+		}
+		for _, header := range chunk.PageHeaders {
+			layoutChunk.PageHeaders = append(layoutChunk.PageHeaders, header.Text)
+		}
+		for _, footer := range chunk.PageFooters {
+			layoutChunk.PageFooters = append(layoutChunk.PageFooters, footer.Text)
+		}
+		layout.Chunks = append(layout.Chunks, layoutChunk)
+	}
+	return layout
+}
+
+// layoutBlocksFromProto converts Document AI layout blocks and their children
+func layoutBlocksFromProto(blocks []*documentaipb.Document_DocumentLayout_DocumentLayoutBlock) []*LayoutBlock {
+	var result []*LayoutBlock
+	for _, block := range blocks {
+		layoutBlock := &LayoutBlock{
+			DocumentaiObject: block,
+			ID:               block.BlockId,
+			PageStart:        int(block.GetPageSpan().GetPageStart()),
+			PageEnd:          int(block.GetPageSpan().GetPageEnd()),
+		}
+		switch {
+		case block.GetTextBlock() != nil:
+			text := block.GetTextBlock()
+			layoutBlock.Kind = LayoutBlockText
+			layoutBlock.Type = text.Type
+			layoutBlock.Text = strings.TrimSpace(text.Text)
+			layoutBlock.Blocks = layoutBlocksFromProto(text.Blocks)
+		case block.GetTableBlock() != nil:
+			table := block.GetTableBlock()
+			layoutBlock.Kind = LayoutBlockTable
+			layoutBlock.Text = strings.TrimSpace(table.Caption)
+			layoutBlock.Table = &Table{
+				PageNumber: layoutBlock.PageStart,
+				HeaderRows: layoutTableRowsFromProto(table.HeaderRows),
+				BodyRows:   layoutTableRowsFromProto(table.BodyRows),
+			}
+		case block.GetListBlock() != nil:
+			list := block.GetListBlock()
+			layoutBlock.Kind = LayoutBlockList
+			layoutBlock.Type = list.Type
+			for _, entry := range list.ListEntries {
+				layoutBlock.ListEntries = append(layoutBlock.ListEntries, layoutBlocksFromProto(entry.Blocks))
+			}
+		default:
+			continue
+		}
+		result = append(result, layoutBlock)
+	}
+	return result
+}
+
+// layoutTableRowsFromProto converts the rows of a layout table, joining the
+// text of the blocks in each cell
+func layoutTableRowsFromProto(rows []*documentaipb.Document_DocumentLayout_DocumentLayoutBlock_LayoutTableRow) []*TableRow {
+	result := make([]*TableRow, 0, len(rows))
+	for _, row := range rows {
+		tableRow := &TableRow{Cells: make([]*TableCell, 0, len(row.Cells))}
+		for _, cell := range row.Cells {
+			tableRow.Cells = append(tableRow.Cells, &TableCell{
+				Text:    layoutBlocksText(layoutBlocksFromProto(cell.Blocks)),
+				RowSpan: max(int(cell.RowSpan), 1),
+				ColSpan: max(int(cell.ColSpan), 1),
+			})
+		}
+		result = append(result, tableRow)
+	}
+	return result
+}
+
+// layoutBlocksText joins the text of blocks and their children with spaces
+func layoutBlocksText(blocks []*LayoutBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		if block.Text != "" && block.Kind == LayoutBlockText {
+			parts = append(parts, block.Text)
+		}
+		if text := layoutBlocksText(block.Blocks); text != "" {
+			parts = append(parts, text)
+		}
+		for _, entry := range block.ListEntries {
+			if text := layoutBlocksText(entry); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// LayoutToMarkdown renders layout blocks as Markdown: headings become "#"
+// headings, lists become bulleted or numbered lists and tables become pipe
+// tables. Page headers and footers are left out.
+func LayoutToMarkdown(blocks []*LayoutBlock) string {
+	var b strings.Builder
+	writeLayoutMarkdown(&b, blocks)
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// writeLayoutMarkdown writes blocks as Markdown
+func writeLayoutMarkdown(b *strings.Builder, blocks []*LayoutBlock) {
+	for _, block := range blocks {
+		switch block.Kind {
+		case LayoutBlockText:
+			switch {
+			case block.Type == "header" || block.Type == "footer":
+				continue
+			case strings.HasPrefix(block.Type, "heading-"):
+				level := 1
+				fmt.Sscanf(strings.TrimPrefix(block.Type, "heading-"), "%d", &level)
+				writeMarkdownParagraph(b, strings.Repeat("#", min(max(level, 1), 6))+" "+oneLine(block.Text))
+			case block.Type == "title":
+				writeMarkdownParagraph(b, "# "+oneLine(block.Text))
+			case block.Text != "":
+				writeMarkdownParagraph(b, block.Text)
+			}
+			writeLayoutMarkdown(b, block.Blocks)
+		case LayoutBlockTable:
+			if block.Text != "" {
+				writeMarkdownParagraph(b, "*"+oneLine(block.Text)+"*")
+			}
+			if table := tableToMarkdown(block.Table); table != "" {
+				writeMarkdownParagraph(b, table)
+			}
+		case LayoutBlockList:
+			writeListMarkdown(b, block, "")
+			b.WriteString("\n")
+		}
+	}
+}
+
+// writeListMarkdown writes the entries of a list block as list items, with
+// the lists nested in an entry indented under it
+func writeListMarkdown(b *strings.Builder, list *LayoutBlock, indent string) {
+	for i, entry := range list.ListEntries {
+		marker := "- "
+		if list.Type == "ordered" {
+			marker = fmt.Sprintf("%d. ", i+1)
+		}
+		var text []*LayoutBlock
+		var nested []*LayoutBlock
+		for _, block := range entry {
+			if block.Kind == LayoutBlockList {
+				nested = append(nested, block)
+			} else {
+				text = append(text, block)
+			}
+		}
+		fmt.Fprintf(b, "%s%s%s\n", indent, marker, oneLine(layoutBlocksText(text)))
+		for _, block := range nested {
+			writeListMarkdown(b, block, indent+strings.Repeat(" ", len(marker)))
+		}
+	}
+}
+
+// ChunksToJSONL renders chunks as JSON Lines, one chunk per line with its
+// id, content, page span, source blocks and page headers and footers
+func ChunksToJSONL(chunks []*Chunk) (string, error) {
+	type chunkLine struct {
+		ID             string   `json:"id"`
+		Content        string   `json:"content"`
+		PageStart      int      `json:"page_start"`
+		PageEnd        int      `json:"page_end"`
+		SourceBlockIDs []string `json:"source_block_ids,omitempty"`
+		PageHeaders    []string `json:"page_headers,omitempty"`
+		PageFooters    []string `json:"page_footers,omitempty"`
+	}
+
+	var b strings.Builder
+	for _, chunk := range chunks {
+		line, err := json.Marshal(chunkLine{
+			ID:             chunk.ID,
+			Content:        chunk.Content,
+			PageStart:      chunk.PageStart,
+			PageEnd:        chunk.PageEnd,
+			SourceBlockIDs: chunk.SourceBlockIDs,
+			PageHeaders:    chunk.PageHeaders,
+			PageFooters:    chunk.PageFooters,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to write chunks as JSON Lines: %w", err)
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,82 @@
+package gdocai
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiter enforces the request limits of a Config. It is shared by every
+// request made with the Config, including from concurrent goroutines.
+type limiter struct {
+	rate  *rate.Limiter // Requests per second, nil for no limit
+	slots chan struct{} // One entry per request in flight, nil for no limit
+}
+
+// limiterMu guards the lazy creation of Config limiters
+var limiterMu sync.Mutex
+
+// requestLimiter returns the limiter of the config, creating it on first use
+func (cfg *Config) requestLimiter() *limiter {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+
+	if cfg.limiter == nil {
+		cfg.limiter = &limiter{}
+		if cfg.MaxQPS > 0 {
+			cfg.limiter.rate = rate.NewLimiter(rate.Limit(cfg.MaxQPS), 1)
+		}
+		if cfg.MaxConcurrent > 0 {
+			cfg.limiter.slots = make(chan struct{}, cfg.MaxConcurrent)
+		}
+	}
+	return cfg.limiter
+}
+
+// acquire waits until a request may be sent within the configured limits and
+// returns a function that must be called once the request has finished.
+// Waiting is reported with an EventThrottled event.
+func (cfg *Config) acquire(ctx context.Context) (func(), error) {
+	if cfg.MaxQPS <= 0 && cfg.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	l := cfg.requestLimiter()
+
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			cfg.logEvent(slog.LevelInfo, EventThrottled, "waiting for a free request slot",
+				"max_concurrent", cfg.MaxConcurrent)
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		release = func() { <-l.slots }
+	}
+
+	if l.rate != nil {
+		reservation := l.rate.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			cfg.logEvent(slog.LevelInfo, EventThrottled, "waiting for the request rate limit",
+				"max_qps", cfg.MaxQPS, "delay", delay)
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				reservation.Cancel()
+				release()
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	return release, nil
+}
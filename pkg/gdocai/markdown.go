@@ -0,0 +1,152 @@
+package gdocai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToMarkdown renders a document as Markdown for wikis and retrieval
+// pipelines. The layout of a Layout Parser processor is rendered with
+// LayoutToMarkdown. Other documents are rendered page by page, separated by
+// thematic breaks: paragraphs in reading order with the tables of the page
+// as pipe tables where they appear on it, followed by a "Form fields"
+// section listing the form fields of the document.
+func ToMarkdown(doc *Document) string {
+	if doc.Layout != nil && len(doc.Layout.Blocks) > 0 {
+		return LayoutToMarkdown(doc.Layout.Blocks)
+	}
+
+	var b strings.Builder
+	if doc.Structured != nil {
+		for i, page := range doc.Structured.Pages {
+			if i > 0 {
+				b.WriteString("---\n\n")
+			}
+			writePageMarkdown(&b, page)
+		}
+	}
+
+	if doc.FormFields != nil && len(doc.FormFields.Details) > 0 {
+		b.WriteString("## Form fields\n\n")
+		for _, field := range doc.FormFields.Details {
+			fmt.Fprintf(&b, "- **%s:** %s\n", escapeMarkdown(oneLine(field.Field)), escapeMarkdown(oneLine(field.Value)))
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// writePageMarkdown writes the paragraphs and tables of a page. Each table
+// is placed before the first paragraph below its top, and paragraphs inside
+// a table are left out, as the table holds their text.
+func writePageMarkdown(b *strings.Builder, page *Page) {
+	dimension := page.DocumentaiObject.GetDimension()
+	tableBoxes := make([]*[4]float64, len(page.Tables))
+	for i, table := range page.Tables {
+		tableBoxes[i] = layoutBox(table.DocumentaiObject.GetLayout().GetBoundingPoly(), dimension)
+	}
+	written := make([]bool, len(page.Tables))
+
+	paragraphs := page.Paragraphs
+	if len(paragraphs) == 0 {
+		// Processors without paragraphs still give blocks
+		for _, block := range page.Blocks {
+			paragraphs = append(paragraphs, &Paragraph{Text: block.Text})
+		}
+	}
+
+	for _, paragraph := range paragraphs {
+		box := layoutBox(paragraph.DocumentaiObject.GetLayout().GetBoundingPoly(), dimension)
+		if box != nil && insideAny(box, tableBoxes) {
+			continue
+		}
+		for i, table := range page.Tables {
+			if !written[i] && box != nil && tableBoxes[i] != nil && tableBoxes[i][1] <= box[1] {
+				if markdown := tableToMarkdown(table); markdown != "" {
+					writeMarkdownParagraph(b, markdown)
+				}
+				written[i] = true
+			}
+		}
+		if text := oneLine(paragraph.Text); text != "" {
+			writeMarkdownParagraph(b, escapeMarkdown(text))
+		}
+	}
+
+	// Tables below the last paragraph, or on pages without positions
+	for i, table := range page.Tables {
+		if !written[i] {
+			if markdown := tableToMarkdown(table); markdown != "" {
+				writeMarkdownParagraph(b, markdown)
+			}
+		}
+	}
+}
+
+// insideAny reports whether the center of a box lies inside one of the boxes
+func insideAny(box *[4]float64, boxes []*[4]float64) bool {
+	x, y := (box[0]+box[2])/2, (box[1]+box[3])/2
+	for _, other := range boxes {
+		if other != nil && x >= other[0] && x <= other[2] && y >= other[1] && y <= other[3] {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeMarkdown escapes the characters that would turn OCR text into
+// Markdown markup at the start of a paragraph, such as "#" headings
+func escapeMarkdown(text string) string {
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(text, ">") || strings.HasPrefix(text, "---") {
+		text = `\` + text
+	}
+	return strings.NewReplacer("*", `\*`, "_", `\_`, "`", "\\`").Replace(text)
+}
+
+// writeMarkdownParagraph writes text as a paragraph followed by a blank line
+func writeMarkdownParagraph(b *strings.Builder, text string) {
+	b.WriteString(text + "\n\n")
+}
+
+// tableToMarkdown renders a table as a Markdown pipe table. Tables without
+// header rows get their first body row as the header.
+func tableToMarkdown(table *Table) string {
+	if table == nil {
+		return ""
+	}
+	header, body := table.Records()
+	if len(header) == 0 {
+		if len(body) == 0 {
+			return ""
+		}
+		header, body = body[:1], body[1:]
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" " + strings.ReplaceAll(oneLine(cell), "|", `\|`) + " |")
+		}
+		b.WriteString("\n")
+	}
+	// Markdown tables have a single header row, so further ones are merged into it
+	merged := header[0]
+	for _, row := range header[1:] {
+		for i, cell := range row {
+			if cell != "" {
+				merged[i] = strings.TrimSpace(merged[i] + " " + cell)
+			}
+		}
+	}
+	writeRow(merged)
+	b.WriteString("|" + strings.Repeat(" --- |", len(merged)) + "\n")
+	for _, row := range body {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// oneLine collapses the whitespace of text, including line breaks, to single spaces
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
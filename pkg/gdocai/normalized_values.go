@@ -0,0 +1,96 @@
+package gdocai
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+// NormalizedEntityValue returns the value Document AI normalized for an
+// entity, or "" if it has none. The normalized text is used when Document AI
+// provides one; otherwise structured values are formatted as follows:
+//
+// - Dates: ISO 8601, e.g. "2024-03-31" ("2024-03" or "2024" for partial dates)
+// - Date and time: ISO 8601, e.g. "2024-03-31T14:30:00+01:00"
+// - Money: amount and currency code, e.g. "1234.50 USD"
+// - Addresses: address lines, locality, region, postal code and country, comma-separated
+// - Booleans and numbers: as Go formats them, e.g. "true", "42", "0.5"
+func NormalizedEntityValue(entity *documentaipb.Document_Entity) string {
+	normalized := entity.GetNormalizedValue()
+	if normalized == nil {
+		return ""
+	}
+	if text := strings.TrimSpace(normalized.Text); text != "" {
+		return text
+	}
+
+	switch value := normalized.StructuredValue.(type) {
+	case *documentaipb.Document_Entity_NormalizedValue_DateValue:
+		return formatDate(value.DateValue.GetYear(), value.DateValue.GetMonth(), value.DateValue.GetDay())
+	case *documentaipb.Document_Entity_NormalizedValue_DatetimeValue:
+		datetime := value.DatetimeValue
+		formatted := formatDate(datetime.GetYear(), datetime.GetMonth(), datetime.GetDay())
+		formatted += fmt.Sprintf("T%02d:%02d:%02d", datetime.GetHours(), datetime.GetMinutes(), datetime.GetSeconds())
+		if offset := datetime.GetUtcOffset(); offset != nil {
+			minutes := offset.AsDuration().Round(time.Minute).Minutes()
+			sign := "+"
+			if minutes < 0 {
+				sign, minutes = "-", -minutes
+			}
+			formatted += fmt.Sprintf("%s%02d:%02d", sign, int(minutes)/60, int(minutes)%60)
+		}
+		return formatted
+	case *documentaipb.Document_Entity_NormalizedValue_MoneyValue:
+		money := value.MoneyValue
+		amount := strconv.FormatInt(money.GetUnits(), 10)
+		if nanos := money.GetNanos(); nanos != 0 {
+			if nanos < 0 {
+				nanos = -nanos
+				if money.GetUnits() == 0 {
+					amount = "-" + amount
+				}
+			}
+			// Keep at least cents, dropping the trailing zeros of the nanos
+			fraction := strings.TrimRight(fmt.Sprintf("%09d", nanos), "0")
+			amount += "." + fraction + strings.Repeat("0", max(0, 2-len(fraction)))
+		}
+		return strings.TrimSpace(amount + " " + money.GetCurrencyCode())
+	case *documentaipb.Document_Entity_NormalizedValue_AddressValue:
+		address := value.AddressValue
+		parts := append([]string{}, address.GetAddressLines()...)
+		parts = append(parts, address.GetLocality(), address.GetAdministrativeArea(),
+			address.GetPostalCode(), address.GetRegionCode())
+		var nonEmpty []string
+		for _, part := range parts {
+			if part = strings.TrimSpace(part); part != "" {
+				nonEmpty = append(nonEmpty, part)
+			}
+		}
+		return strings.Join(nonEmpty, ", ")
+	case *documentaipb.Document_Entity_NormalizedValue_BooleanValue:
+		return strconv.FormatBool(value.BooleanValue)
+	case *documentaipb.Document_Entity_NormalizedValue_IntegerValue:
+		return strconv.FormatInt(int64(value.IntegerValue), 10)
+	case *documentaipb.Document_Entity_NormalizedValue_FloatValue:
+		return strconv.FormatFloat(float64(value.FloatValue), 'f', -1, 32)
+	}
+	return ""
+}
+
+// formatDate formats a possibly partial date as ISO 8601
+func formatDate(year, month, day int32) string {
+	switch {
+	case year > 0 && month > 0 && day > 0:
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	case year > 0 && month > 0:
+		return fmt.Sprintf("%04d-%02d", year, month)
+	case year > 0:
+		return fmt.Sprintf("%04d", year)
+	case month > 0 && day > 0:
+		return fmt.Sprintf("--%02d-%02d", month, day)
+	}
+	return ""
+}
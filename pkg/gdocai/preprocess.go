@@ -0,0 +1,20 @@
+package gdocai
+
+import "fmt"
+
+// Preprocess runs the Preprocess pipeline of the config over a scanned PDF
+// and returns the cleaned up PDF to send to Document AI, or the PDF unchanged
+// if the pipeline is empty. Deskewing and rotation move the text on the page,
+// so the result, not the original, should also be the base of the searchable
+// PDF. PDFs that aren't made of one image per page fail with
+// pdfocr.ErrNotScanned.
+func Preprocess(pdfBytes []byte, cfg *Config) ([]byte, error) {
+	if cfg == nil || len(cfg.Preprocess) == 0 {
+		return pdfBytes, nil
+	}
+	processed, err := cfg.Preprocess.ProcessPDF(pdfBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to preprocess document: %w", err)
+	}
+	return processed, nil
+}
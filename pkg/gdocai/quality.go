@@ -0,0 +1,72 @@
+package gdocai
+
+import (
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+// Image defects Document AI detects when Config.ImageQualityScores is set
+const (
+	DefectBlurry         = "quality/defect_blurry"
+	DefectNoisy          = "quality/defect_noisy"
+	DefectDark           = "quality/defect_dark"
+	DefectFaint          = "quality/defect_faint"
+	DefectTextTooSmall   = "quality/defect_text_too_small"
+	DefectDocumentCutoff = "quality/defect_document_cutoff"
+	DefectTextCutoff     = "quality/defect_text_cutoff"
+	DefectGlare          = "quality/defect_glare"
+)
+
+// ImageQuality is how Document AI scored the image quality of a page
+type ImageQuality struct {
+	Score   float64       // Overall quality, from 0 (worst) to 1 (best)
+	Defects []ImageDefect // Defects detected on the page, most confident first
+}
+
+// ImageDefect is a defect detected in the image of a page
+type ImageDefect struct {
+	Type       string  // One of the Defect constants, e.g. DefectBlurry
+	Confidence float64 // Confidence the page has the defect, from 0 to 1
+}
+
+// Name returns the defect type without its "quality/defect_" prefix, e.g. "blurry"
+func (d ImageDefect) Name() string {
+	return strings.TrimPrefix(d.Type, "quality/defect_")
+}
+
+// Detected returns the defects detected with at least the given confidence
+// (0-1), to flag pages that may need rescanning
+func (q *ImageQuality) Detected(minConfidence float64) []ImageDefect {
+	if q == nil {
+		return nil
+	}
+	var detected []ImageDefect
+	for _, defect := range q.Defects {
+		if defect.Confidence >= minConfidence {
+			detected = append(detected, defect)
+		}
+	}
+	return detected
+}
+
+// imageQualityFromProto converts the image quality scores of a Document AI
+// page, returning nil if the page has none
+func imageQualityFromProto(page *documentaipb.Document_Page) *ImageQuality {
+	scores := page.GetImageQualityScores()
+	if scores == nil {
+		return nil
+	}
+	quality := &ImageQuality{Score: float64(scores.QualityScore)}
+	for _, defect := range scores.DetectedDefects {
+		quality.Defects = append(quality.Defects, ImageDefect{
+			Type:       defect.Type,
+			Confidence: float64(defect.Confidence),
+		})
+	}
+	sort.SliceStable(quality.Defects, func(i, j int) bool {
+		return quality.Defects[i].Confidence > quality.Defects[j].Confidence
+	})
+	return quality
+}
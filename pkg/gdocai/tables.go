@@ -0,0 +1,134 @@
+package gdocai
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+// ExtractTables collects the tables from all pages of the document
+func ExtractTables(docProto *documentaipb.Document) []*Table {
+	var tables []*Table
+
+	for _, page := range docProto.Pages {
+		for i, table := range page.Tables {
+			tables = append(tables, tableFromProto(table, int(page.PageNumber), i+1, docProto.Text))
+		}
+	}
+
+	return tables
+}
+
+// tableFromProto converts a Document AI table into our structure
+func tableFromProto(table *documentaipb.Document_Page_Table, pageNumber, index int, fullText string) *Table {
+	return &Table{
+		DocumentaiObject: table,
+		PageNumber:       pageNumber,
+		Index:            index,
+		HeaderRows:       tableRowsFromProto(table.HeaderRows, fullText),
+		BodyRows:         tableRowsFromProto(table.BodyRows, fullText),
+	}
+}
+
+// tableRowsFromProto converts Document AI table rows, resolving cell text
+func tableRowsFromProto(rows []*documentaipb.Document_Page_Table_TableRow, fullText string) []*TableRow {
+	result := make([]*TableRow, 0, len(rows))
+	for _, row := range rows {
+		tableRow := &TableRow{Cells: make([]*TableCell, 0, len(row.Cells))}
+		for _, cell := range row.Cells {
+			tableRow.Cells = append(tableRow.Cells, &TableCell{
+				Text:    strings.TrimSpace(textFromLayout(cell.Layout, fullText)),
+				RowSpan: max(int(cell.RowSpan), 1),
+				ColSpan: max(int(cell.ColSpan), 1),
+			})
+		}
+		result = append(result, tableRow)
+	}
+	return result
+}
+
+// Records returns the header and body rows as a rectangular grid of cell text.
+// A cell spanning several rows or columns places its text in its top-left
+// position and leaves the other positions it covers empty.
+func (t *Table) Records() (header, body [][]string) {
+	header = layoutRows(t.HeaderRows)
+	body = layoutRows(t.BodyRows)
+
+	width := 0
+	for _, grid := range [][][]string{header, body} {
+		for _, record := range grid {
+			width = max(width, len(record))
+		}
+	}
+	for _, grid := range [][][]string{header, body} {
+		for i, record := range grid {
+			for len(record) < width {
+				record = append(record, "")
+			}
+			grid[i] = record
+		}
+	}
+
+	return header, body
+}
+
+// ToCSV renders the table as CSV, header rows first
+func (t *Table) ToCSV() (string, error) {
+	header, body := t.Records()
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(append(header, body...)); err != nil {
+		return "", fmt.Errorf("failed to write table CSV: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// ToJSON renders the table as a JSON object with its page, index, header and body rows
+func (t *Table) ToJSON() (string, error) {
+	header, body := t.Records()
+	return ToJSON(struct {
+		Page   int        `json:"page"`
+		Index  int        `json:"index"`
+		Header [][]string `json:"header"`
+		Rows   [][]string `json:"rows"`
+	}{t.PageNumber, t.Index, header, body})
+}
+
+// layoutRows places cells on a grid, skipping positions already covered
+// by cells spanning down from earlier rows
+func layoutRows(rows []*TableRow) [][]string {
+	grid := make([][]string, len(rows))
+	covered := make(map[[2]int]bool)
+
+	for r, row := range rows {
+		col := 0
+		for _, cell := range row.Cells {
+			for covered[[2]int{r, col}] {
+				col++
+			}
+			for dr := 0; dr < cell.RowSpan && r+dr < len(rows); dr++ {
+				for dc := 0; dc < cell.ColSpan; dc++ {
+					covered[[2]int{r + dr, col + dc}] = true
+				}
+			}
+			for len(grid[r]) < col+cell.ColSpan {
+				grid[r] = append(grid[r], "")
+			}
+			grid[r][col] = cell.Text
+			col += cell.ColSpan
+		}
+	}
+
+	// Rows below a row-spanning cell may end before the positions it covers
+	for pos := range covered {
+		for len(grid[pos[0]]) <= pos[1] {
+			grid[pos[0]] = append(grid[pos[0]], "")
+		}
+	}
+
+	return grid
+}
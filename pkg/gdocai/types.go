@@ -14,6 +14,8 @@ type Document struct {
 	Hocr                  *HocrContent         // hOCR representation
 	FormFields            *FormData            // Extracted form fields
 	CustomExtractorFields *CustomExtractorData // Extracted custom extractor fields
+	Tables                *TableData           // Extracted tables
+	Layout                *LayoutData          // Document layout and chunks from a Layout Parser processor
 }
 
 // RawDocument is a thin wrapper around the Google Document AI response
@@ -39,7 +41,8 @@ type HocrContent struct {
 
 // FormData contains extracted form fields from the document
 type FormData struct {
-	Fields map[string]interface{} // Map of field names to values
+	Fields  map[string]interface{} // Map of field names to values
+	Details []FieldDetail          // Every field with its confidence and location
 }
 
 // Page represents a single page in the document with its structural elements
@@ -54,6 +57,9 @@ type Page struct {
 	Paragraphs []*Paragraph // Paragraphs on this page
 	Blocks     []*Block     // Layout blocks on this page
 	Tokens     []*Token     // Individual tokens/words on this page
+	Tables     []*Table     // Tables on this page
+
+	ImageQuality *ImageQuality // Image quality scores, nil unless Config.ImageQualityScores is set
 }
 
 // Block represents a block of content on a page
@@ -97,5 +103,66 @@ type FormField struct {
 
 // CustomExtractorData contains extracted entities from custom extractors
 type CustomExtractorData struct {
-	Fields map[string]interface{} // Map of entity types to values
+	Fields     map[string]interface{} // Map of entity types to values
+	Normalized map[string]interface{} // Like Fields, with values normalized by Document AI where available
+	Details    []FieldDetail          // Every entity with its confidence and location
+}
+
+// LayoutData contains the document layout and chunks returned by a Layout
+// Parser processor. Both are empty for other processors.
+type LayoutData struct {
+	Blocks []*LayoutBlock // Top-level layout blocks, in reading order
+	Chunks []*Chunk       // Chunks of the document for retrieval, in order
+}
+
+// LayoutBlock is a text, table or list block of the document layout
+type LayoutBlock struct {
+	DocumentaiObject *documentaipb.Document_DocumentLayout_DocumentLayoutBlock // Original Document AI block
+	ID               string                                                    // Block ID, referenced by chunks
+	Kind             string                                                    // LayoutBlockText, LayoutBlockTable or LayoutBlockList
+	Type             string                                                    // Text type (e.g. "heading-1", "paragraph", "header") or list type ("ordered", "unordered")
+	Text             string                                                    // Text of a text block, caption of a table
+	PageStart        int                                                       // First page of the block (1-based)
+	PageEnd          int                                                       // Last page of the block (1-based)
+	Blocks           []*LayoutBlock                                            // Blocks nested in a text block, e.g. the paragraphs under a heading
+	Table            *Table                                                    // Rows of a table block
+	ListEntries      [][]*LayoutBlock                                          // Blocks of each entry of a list block
+}
+
+// Chunk is a piece of the document sized for retrieval-augmented generation
+type Chunk struct {
+	DocumentaiObject *documentaipb.Document_ChunkedDocument_Chunk // Original Document AI chunk
+	ID               string                                       // Chunk ID
+	Content          string                                       // Text of the chunk
+	SourceBlockIDs   []string                                     // Layout blocks the chunk was made from
+	PageStart        int                                          // First page of the chunk (1-based)
+	PageEnd          int                                          // Last page of the chunk (1-based)
+	PageHeaders      []string                                     // Page headers of the pages the chunk spans
+	PageFooters      []string                                     // Page footers of the pages the chunk spans
+}
+
+// TableData contains the tables found in the document, in page order
+type TableData struct {
+	Tables []*Table // All tables in the document
+}
+
+// Table represents a table detected on a page
+type Table struct {
+	DocumentaiObject *documentaipb.Document_Page_Table // Original Document AI table
+	PageNumber       int                               // Parent page number
+	Index            int                               // Table number on the page (1-based)
+	HeaderRows       []*TableRow                       // Header rows
+	BodyRows         []*TableRow                       // Body rows
+}
+
+// TableRow represents a row of table cells
+type TableRow struct {
+	Cells []*TableCell // Cells in this row, left to right
+}
+
+// TableCell represents a single table cell
+type TableCell struct {
+	Text    string // Text content of this cell
+	RowSpan int    // Number of rows this cell spans
+	ColSpan int    // Number of columns this cell spans
 }
@@ -0,0 +1,545 @@
+package hocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ALTO 4 namespace and schema location
+const (
+	altoNamespace      = "http://www.loc.gov/standards/alto/ns-v4#"
+	altoSchemaLocation = "http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/alto/v4/alto-4-4.xsd"
+	xsiNamespace       = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// altoDocument is the root <alto> element
+type altoDocument struct {
+	XMLName        xml.Name        `xml:"alto"`
+	Xmlns          string          `xml:"xmlns,attr,omitempty"`
+	XmlnsXsi       string          `xml:"xmlns:xsi,attr,omitempty"`
+	SchemaLocation string          `xml:"xsi:schemaLocation,attr,omitempty"`
+	Description    altoDescription `xml:"Description"`
+	Layout         altoLayout      `xml:"Layout"`
+}
+
+// altoDescription holds the document level metadata
+type altoDescription struct {
+	MeasurementUnit        string                      `xml:"MeasurementUnit"`
+	SourceImageInformation *altoSourceImageInformation `xml:"sourceImageInformation,omitempty"`
+	Processing             []altoProcessing            `xml:"Processing,omitempty"`
+	OCRProcessing          []altoOCRProcessing         `xml:"OCRProcessing,omitempty"`
+}
+
+// altoOCRProcessing is the ALTO 2/3 form of the processing description, still written by ABBYY
+type altoOCRProcessing struct {
+	Steps []altoProcessing `xml:"ocrProcessingStep"`
+}
+
+// altoSourceImageInformation names the image the OCR was run on
+type altoSourceImageInformation struct {
+	FileName string `xml:"fileName"`
+}
+
+// altoProcessing describes a processing step, such as the OCR engine
+type altoProcessing struct {
+	ID                 string                 `xml:"ID,attr,omitempty"`
+	ProcessingSoftware altoProcessingSoftware `xml:"processingSoftware"`
+}
+
+// altoProcessingSoftware names the software used in a processing step
+type altoProcessingSoftware struct {
+	SoftwareName string `xml:"softwareName"`
+}
+
+// altoLayout contains the pages
+type altoLayout struct {
+	Pages []altoPage `xml:"Page"`
+}
+
+// altoPage is a single <Page> element
+type altoPage struct {
+	ID            string          `xml:"ID,attr,omitempty"`
+	PhysicalImgNr int             `xml:"PHYSICAL_IMG_NR,attr,omitempty"`
+	Width         altoCoord       `xml:"WIDTH,attr"`
+	Height        altoCoord       `xml:"HEIGHT,attr"`
+	TopMargin     *altoPrintSpace `xml:"TopMargin,omitempty"`
+	LeftMargin    *altoPrintSpace `xml:"LeftMargin,omitempty"`
+	RightMargin   *altoPrintSpace `xml:"RightMargin,omitempty"`
+	BottomMargin  *altoPrintSpace `xml:"BottomMargin,omitempty"`
+	PrintSpace    altoPrintSpace  `xml:"PrintSpace"`
+}
+
+// altoPrintSpace is the printed area of a page. Margins share the same structure.
+type altoPrintSpace struct {
+	altoBox
+	ComposedBlocks []altoComposedBlock `xml:"ComposedBlock"`
+	TextBlocks     []altoTextBlock     `xml:"TextBlock"`
+}
+
+// altoComposedBlock groups text blocks, corresponding to an hOCR area
+type altoComposedBlock struct {
+	ID   string `xml:"ID,attr,omitempty"`
+	Type string `xml:"TYPE,attr,omitempty"` // Area type without its "ocr_" prefix, e.g. "table"
+	altoBox
+	ComposedBlocks []altoComposedBlock `xml:"ComposedBlock"`
+	TextBlocks     []altoTextBlock     `xml:"TextBlock"`
+}
+
+// altoTextBlock is a block of text lines, corresponding to an hOCR paragraph
+type altoTextBlock struct {
+	ID string `xml:"ID,attr,omitempty"`
+	altoBox
+	Lang      string         `xml:"LANG,attr,omitempty"`
+	TextLines []altoTextLine `xml:"TextLine"`
+}
+
+// altoTextLine is a single line of text
+type altoTextLine struct {
+	ID string `xml:"ID,attr,omitempty"`
+	altoBox
+	Lang     string          `xml:"LANG,attr,omitempty"`
+	Children []altoLineChild `xml:",any"`
+}
+
+// altoLineChild is a <String>, <SP> or <HYP> element inside a text line.
+// They share one type so their order is preserved.
+type altoLineChild struct {
+	XMLName xml.Name
+	ID      string     `xml:"ID,attr,omitempty"`
+	Content string     `xml:"CONTENT,attr,omitempty"`
+	HPos    *altoCoord `xml:"HPOS,attr,omitempty"`
+	VPos    *altoCoord `xml:"VPOS,attr,omitempty"`
+	Width   *altoCoord `xml:"WIDTH,attr,omitempty"`
+	Height  *altoCoord `xml:"HEIGHT,attr,omitempty"`
+	WC      *altoCoord `xml:"WC,attr,omitempty"`
+	Lang    string     `xml:"LANG,attr,omitempty"`
+}
+
+// altoBox holds the position attributes shared by ALTO layout elements
+type altoBox struct {
+	HPos   altoCoord `xml:"HPOS,attr"`
+	VPos   altoCoord `xml:"VPOS,attr"`
+	Width  altoCoord `xml:"WIDTH,attr"`
+	Height altoCoord `xml:"HEIGHT,attr"`
+}
+
+// altoCoord is a numeric attribute written without an exponent or trailing zeros.
+// Values of the optional attributes may be absent, so those are pointers.
+type altoCoord float64
+
+// MarshalXMLAttr formats the value in plain decimal notation
+func (c altoCoord) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: strconv.FormatFloat(float64(c), 'f', -1, 64)}, nil
+}
+
+// altoBoxOf converts an hOCR bounding box into ALTO position attributes
+func altoBoxOf(bbox BoundingBox) altoBox {
+	return altoBox{
+		HPos:   altoCoord(bbox.X1),
+		VPos:   altoCoord(bbox.Y1),
+		Width:  altoCoord(bbox.X2 - bbox.X1),
+		Height: altoCoord(bbox.Y2 - bbox.Y1),
+	}
+}
+
+// coordPtr returns a pointer to a coordinate, for optional attributes
+func coordPtr(v float64) *altoCoord {
+	c := altoCoord(v)
+	return &c
+}
+
+// ToALTO renders the HOCR document as ALTO 4 XML. Areas become ComposedBlocks,
+// paragraphs become TextBlocks, and lines and words become TextLines and
+// Strings. Lines or words without a parent of the level ALTO requires are
+// wrapped in a synthetic TextBlock or TextLine. Coordinates are in pixels.
+func ToALTO(doc *HOCR) (string, error) {
+	if doc == nil {
+		return "", fmt.Errorf("HOCR document is nil")
+	}
+
+	alto := altoDocument{
+		Xmlns:          altoNamespace,
+		XmlnsXsi:       xsiNamespace,
+		SchemaLocation: altoSchemaLocation,
+		Description:    altoDescription{MeasurementUnit: "pixel"},
+	}
+
+	if len(doc.Pages) > 0 {
+		if imageName := strings.Trim(doc.Pages[0].ImageName, `"`); imageName != "" {
+			alto.Description.SourceImageInformation = &altoSourceImageInformation{FileName: imageName}
+		}
+	}
+	if software := doc.Metadata["ocr-system"]; software != "" {
+		alto.Description.Processing = []altoProcessing{{
+			ID:                 "OCR_0",
+			ProcessingSoftware: altoProcessingSoftware{SoftwareName: software},
+		}}
+	}
+
+	for pageIdx, page := range doc.Pages {
+		alto.Layout.Pages = append(alto.Layout.Pages, altoPageOf(doc, page, pageIdx))
+	}
+
+	output, err := xml.MarshalIndent(alto, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding ALTO XML: %w", err)
+	}
+
+	return xml.Header + string(output) + "\n", nil
+}
+
+// altoPageOf converts a single hOCR page
+func altoPageOf(doc *HOCR, page Page, pageIdx int) altoPage {
+	pageNum := pageNumberOf(page, pageIdx)
+	pageID := page.ID
+	if pageID == "" {
+		pageID = fmt.Sprintf("page_%d", pageNum)
+	}
+
+	pageLang := page.Lang
+	if pageLang == "" {
+		pageLang = doc.Language
+	}
+
+	result := altoPage{
+		ID:            pageID,
+		PhysicalImgNr: pageNum,
+		Width:         altoCoord(page.BBox.X2 - page.BBox.X1),
+		Height:        altoCoord(page.BBox.Y2 - page.BBox.Y1),
+		PrintSpace:    altoPrintSpace{altoBox: altoBoxOf(page.BBox)},
+	}
+
+	for _, area := range page.Areas {
+		areaLang := firstLanguage(area.Lang, pageLang)
+		block := altoComposedBlock{ID: area.ID, Type: strings.TrimPrefix(area.Type, "ocr_"), altoBox: altoBoxOf(area.BBox)}
+
+		for _, para := range area.Paragraphs {
+			block.TextBlocks = append(block.TextBlocks, altoTextBlockOf(para, areaLang))
+		}
+
+		// Lines and words directly under the area get a synthetic paragraph
+		if len(area.Lines) > 0 || len(area.Words) > 0 {
+			block.TextBlocks = append(block.TextBlocks, altoTextBlockOf(Paragraph{
+				BBox:  area.BBox,
+				Lines: area.Lines,
+				Words: area.Words,
+			}, areaLang))
+		}
+
+		result.PrintSpace.ComposedBlocks = append(result.PrintSpace.ComposedBlocks, block)
+	}
+
+	for _, para := range page.Paragraphs {
+		result.PrintSpace.TextBlocks = append(result.PrintSpace.TextBlocks, altoTextBlockOf(para, pageLang))
+	}
+
+	// Lines directly under the page share one synthetic paragraph
+	if len(page.Lines) > 0 {
+		result.PrintSpace.TextBlocks = append(result.PrintSpace.TextBlocks,
+			altoTextBlockOf(Paragraph{BBox: page.BBox, Lines: page.Lines}, pageLang))
+	}
+
+	return result
+}
+
+// altoTextBlockOf converts an hOCR paragraph into a TextBlock
+func altoTextBlockOf(para Paragraph, parentLang string) altoTextBlock {
+	lang := firstLanguage(para.Lang, parentLang)
+	block := altoTextBlock{ID: para.ID, altoBox: altoBoxOf(para.BBox), Lang: lang}
+
+	for _, line := range para.Lines {
+		block.TextLines = append(block.TextLines, altoTextLineOf(line, lang))
+	}
+
+	// Words directly under the paragraph form a synthetic line
+	if len(para.Words) > 0 {
+		block.TextLines = append(block.TextLines, altoTextLineOf(Line{BBox: para.BBox, Words: para.Words}, lang))
+	}
+
+	return block
+}
+
+// altoTextLineOf converts an hOCR line into a TextLine of Strings separated by SP elements
+func altoTextLineOf(line Line, parentLang string) altoTextLine {
+	lang := firstLanguage(line.Lang, parentLang)
+	result := altoTextLine{ID: line.ID, altoBox: altoBoxOf(line.BBox)}
+	if line.Lang != "" && line.Lang != parentLang {
+		result.Lang = line.Lang
+	}
+
+	for i, word := range line.Words {
+		if i > 0 {
+			// Place the space between the previous word and this one
+			prev := line.Words[i-1].BBox
+			sp := altoLineChild{XMLName: xml.Name{Local: "SP"}}
+			if word.BBox.X1 > prev.X2 {
+				sp.HPos = coordPtr(prev.X2)
+				sp.VPos = coordPtr(prev.Y1)
+				sp.Width = coordPtr(word.BBox.X1 - prev.X2)
+			}
+			result.Children = append(result.Children, sp)
+		}
+
+		str := altoLineChild{
+			XMLName: xml.Name{Local: "String"},
+			ID:      word.ID,
+			Content: word.Text,
+			HPos:    coordPtr(word.BBox.X1),
+			VPos:    coordPtr(word.BBox.Y1),
+			Width:   coordPtr(word.BBox.X2 - word.BBox.X1),
+			Height:  coordPtr(word.BBox.Y2 - word.BBox.Y1),
+		}
+		if word.ConfidenceKnown() {
+			str.WC = coordPtr(word.Confidence / 100)
+		}
+		if word.Lang != "" && word.Lang != lang {
+			str.Lang = word.Lang
+		}
+		result.Children = append(result.Children, str)
+	}
+
+	return result
+}
+
+// firstLanguage returns the first language tag that isn't missing
+func firstLanguage(langs ...string) string {
+	for _, lang := range langs {
+		if !isMissingLanguage(lang) {
+			return lang
+		}
+	}
+	return ""
+}
+
+// ParseALTO parses ALTO XML (versions 2 to 4) into an HOCR struct so that
+// output from ALTO producers such as ABBYY can be used with pdfocr.
+// ComposedBlocks become areas, TextBlocks paragraphs, TextLines lines and
+// Strings words, with WC confidences scaled to 0-100. Coordinates in mm10
+// or inch1200 units are converted to points; pixel coordinates are kept.
+// Missing element IDs are generated in Tesseract's naming style.
+func ParseALTO(data []byte) (HOCR, error) {
+	var alto altoDocument
+	if err := xml.Unmarshal(data, &alto); err != nil {
+		return HOCR{}, fmt.Errorf("error parsing ALTO XML: %w", err)
+	}
+	if alto.XMLName.Local != "alto" {
+		return HOCR{}, fmt.Errorf("not an ALTO document: root element is <%s>", alto.XMLName.Local)
+	}
+
+	scale, err := altoUnitScale(alto.Description.MeasurementUnit)
+	if err != nil {
+		return HOCR{}, err
+	}
+
+	result := HOCR{
+		Title:    "OCR Output",
+		Metadata: make(map[string]string),
+	}
+	if software := altoSoftwareName(alto.Description); software != "" {
+		result.Metadata["ocr-system"] = software
+	}
+
+	for pageIdx, altoPage := range alto.Layout.Pages {
+		pageNum := altoPage.PhysicalImgNr
+		if pageNum <= 0 {
+			pageNum = pageIdx + 1
+		}
+
+		b := &altoBuilder{scale: scale, page: pageNum}
+		page := Page{
+			ID:         altoPage.ID,
+			PageNumber: pageNum,
+			BBox:       NewBoundingBox(0, 0, float64(altoPage.Width)*scale, float64(altoPage.Height)*scale),
+			Metadata:   make(map[string]string),
+		}
+		if page.ID == "" {
+			page.ID = fmt.Sprintf("page_%d", pageNum)
+		}
+		if pageIdx == 0 && alto.Description.SourceImageInformation != nil {
+			page.ImageName = alto.Description.SourceImageInformation.FileName
+		}
+
+		// Margins hold headers, footers and marginalia; they come before and after the main text
+		spaces := []*altoPrintSpace{altoPage.TopMargin, altoPage.LeftMargin, &altoPage.PrintSpace,
+			altoPage.RightMargin, altoPage.BottomMargin}
+		for _, space := range spaces {
+			if space == nil {
+				continue
+			}
+			for _, composed := range space.ComposedBlocks {
+				page.Areas = append(page.Areas, b.area(composed))
+			}
+			for _, block := range space.TextBlocks {
+				page.Paragraphs = append(page.Paragraphs, b.paragraph(block))
+			}
+		}
+
+		page.Lang = b.dominantLanguage()
+		if result.Language == "" {
+			result.Language = page.Lang
+		}
+
+		result.Pages = append(result.Pages, page)
+	}
+
+	return result, nil
+}
+
+// altoBuilder converts the elements of one ALTO page, numbering elements without IDs
+type altoBuilder struct {
+	scale     float64
+	page      int
+	counters  map[string]int
+	langCount map[string]int
+}
+
+// id returns the element ID, generating one like "line_1_3" if it is missing
+func (b *altoBuilder) id(id, kind string) string {
+	if b.counters == nil {
+		b.counters = make(map[string]int)
+	}
+	b.counters[kind]++
+	if id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s_%d_%d", kind, b.page, b.counters[kind])
+}
+
+// bbox converts ALTO position attributes into an hOCR bounding box
+func (b *altoBuilder) bbox(box altoBox) BoundingBox {
+	x, y := float64(box.HPos)*b.scale, float64(box.VPos)*b.scale
+	return NewBoundingBox(x, y, x+float64(box.Width)*b.scale, y+float64(box.Height)*b.scale)
+}
+
+// area converts a ComposedBlock, flattening nested ComposedBlocks into it
+func (b *altoBuilder) area(composed altoComposedBlock) Area {
+	area := Area{
+		ID:       b.id(composed.ID, "block"),
+		BBox:     b.bbox(composed.altoBox),
+		Metadata: make(map[string]string),
+	}
+	if areaType, ok := areaTypeOf("ocr_" + strings.ToLower(composed.Type)); ok {
+		area.Type = areaType
+	}
+
+	var collect func(c altoComposedBlock)
+	collect = func(c altoComposedBlock) {
+		for _, block := range c.TextBlocks {
+			area.Paragraphs = append(area.Paragraphs, b.paragraph(block))
+		}
+		for _, nested := range c.ComposedBlocks {
+			collect(nested)
+		}
+	}
+	collect(composed)
+
+	return area
+}
+
+// paragraph converts a TextBlock
+func (b *altoBuilder) paragraph(block altoTextBlock) Paragraph {
+	para := Paragraph{
+		ID:       b.id(block.ID, "par"),
+		Lang:     block.Lang,
+		BBox:     b.bbox(block.altoBox),
+		Metadata: make(map[string]string),
+	}
+
+	for _, textLine := range block.TextLines {
+		para.Lines = append(para.Lines, b.line(textLine, block.Lang))
+	}
+
+	return para
+}
+
+// line converts a TextLine and its Strings; SP and HYP elements are skipped
+func (b *altoBuilder) line(textLine altoTextLine, parentLang string) Line {
+	line := Line{
+		ID:       b.id(textLine.ID, "line"),
+		Lang:     textLine.Lang,
+		BBox:     b.bbox(textLine.altoBox),
+		Metadata: make(map[string]string),
+	}
+	lineLang := firstLanguage(textLine.Lang, parentLang)
+
+	for _, child := range textLine.Children {
+		if child.XMLName.Local != "String" || child.Content == "" {
+			continue
+		}
+
+		word := Word{
+			ID:       b.id(child.ID, "word"),
+			Text:     child.Content,
+			BBox:     b.bbox(altoBox{HPos: altoValue(child.HPos), VPos: altoValue(child.VPos), Width: altoValue(child.Width), Height: altoValue(child.Height)}),
+			Lang:     child.Lang,
+			Metadata: make(map[string]string),
+		}
+		if child.WC != nil {
+			word.Confidence = float64(*child.WC) * 100
+			word.HasConfidence = true
+		}
+
+		if lang := firstLanguage(child.Lang, lineLang); lang != "" {
+			if b.langCount == nil {
+				b.langCount = make(map[string]int)
+			}
+			b.langCount[lang]++
+		}
+
+		line.Words = append(line.Words, word)
+	}
+
+	return line
+}
+
+// dominantLanguage returns the language used by most words on the page
+func (b *altoBuilder) dominantLanguage() string {
+	best, bestCount := "", 0
+	for lang, count := range b.langCount {
+		if count > bestCount || (count == bestCount && lang < best) {
+			best, bestCount = lang, count
+		}
+	}
+	return best
+}
+
+// altoValue dereferences an optional coordinate, treating a missing value as zero
+func altoValue(c *altoCoord) altoCoord {
+	if c == nil {
+		return 0
+	}
+	return *c
+}
+
+// altoUnitScale returns the factor converting ALTO measurement units to hOCR coordinates
+func altoUnitScale(unit string) (float64, error) {
+	switch strings.TrimSpace(unit) {
+	case "", "pixel":
+		return 1, nil
+	case "mm10":
+		return 72 / 254.0, nil // tenths of a millimetre to points
+	case "inch1200":
+		return 72 / 1200.0, nil // 1/1200 inch to points
+	default:
+		return 0, fmt.Errorf("unsupported ALTO measurement unit %q", unit)
+	}
+}
+
+// altoSoftwareName returns the name of the OCR software from the description
+func altoSoftwareName(desc altoDescription) string {
+	for _, step := range desc.Processing {
+		if name := strings.TrimSpace(step.ProcessingSoftware.SoftwareName); name != "" {
+			return name
+		}
+	}
+	for _, processing := range desc.OCRProcessing {
+		for _, step := range processing.Steps {
+			if name := strings.TrimSpace(step.ProcessingSoftware.SoftwareName); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
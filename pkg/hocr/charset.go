@@ -0,0 +1,84 @@
+package hocr
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// charsetPattern matches a charset declared in a meta tag, either
+// <meta charset="..."> or the content of <meta http-equiv="Content-Type">
+var charsetPattern = regexp.MustCompile(`(?i)<meta\b[^>]*?charset\s*=\s*["']?\s*([\w.:-]+)`)
+
+// xmlEncodingPattern matches the encoding of an XML declaration
+var xmlEncodingPattern = regexp.MustCompile(`^\s*<\?xml\b[^>]*?encoding\s*=\s*["']([\w.:-]+)["']`)
+
+// detectCharset returns the encoding of hOCR data and its name. A byte order
+// mark wins, then a known encoding declared in the XML declaration or a meta
+// tag. Otherwise data that isn't valid UTF-8 is taken to be windows-1252, the
+// encoding browsers fall back to for legacy documents.
+func detectCharset(data []byte) (encoding.Encoding, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8BOM, "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le"
+	}
+
+	// The declaration has to be near the start of the document, before the
+	// text that needs it
+	head := data[:min(len(data), 1024)]
+	for _, pattern := range []*regexp.Regexp{xmlEncodingPattern, charsetPattern} {
+		if match := pattern.FindSubmatch(head); match != nil {
+			if enc, name, ok := htmlEncoding(string(match[1])); ok {
+				return enc, name
+			}
+		}
+	}
+
+	if utf8.Valid(data) {
+		return encoding.Nop, "utf-8"
+	}
+	return charmap.Windows1252, "windows-1252"
+}
+
+// htmlEncoding looks up an encoding by one of its labels in the WHATWG
+// Encoding Standard, e.g. "latin2", "iso-8859-7", "koi8-r" or "shift_jis"
+func htmlEncoding(label string) (encoding.Encoding, string, bool) {
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, "", false
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		name = strings.ToLower(label)
+	}
+	if name == "utf-8" || strings.HasPrefix(name, "utf-16") {
+		// A declaration readable as ASCII can't be UTF-16, so like browsers
+		// take a document declaring it to be UTF-8
+		return encoding.Nop, "utf-8", true
+	}
+	return enc, name, true
+}
+
+// decodeHOCR converts hOCR data to UTF-8 from the encoding it is in
+func decodeHOCR(data []byte) ([]byte, error) {
+	enc, name := detectCharset(data)
+	if enc == encoding.Nop {
+		return data, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,224 @@
+package hocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tesseract TSV hierarchy levels as used in the "level" column
+const (
+	tsvLevelPage      = 1
+	tsvLevelBlock     = 2
+	tsvLevelParagraph = 3
+	tsvLevelLine      = 4
+	tsvLevelWord      = 5
+)
+
+// tsvHeader is the header row emitted by Tesseract's TSV renderer
+const tsvHeader = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext"
+
+// WordRecord is a flat representation of a single word with its position,
+// used for the JSONL export
+type WordRecord struct {
+	Page       int        `json:"page"`         // Page number (1-based)
+	ID         string     `json:"id,omitempty"` // hOCR word ID
+	Text       string     `json:"text"`         // Word text
+	BBox       [4]float64 `json:"bbox"`         // x1, y1, x2, y2 in page coordinates
+	Confidence float64    `json:"confidence"`   // Recognition confidence (0-100)
+	Lang       string     `json:"lang,omitempty"`
+}
+
+// tsvWriter accumulates Tesseract-style TSV rows
+type tsvWriter struct {
+	builder strings.Builder
+}
+
+// row writes a single TSV row for an element at the given hierarchy position
+func (t *tsvWriter) row(level, page, block, par, line, word int, bbox BoundingBox, conf float64, text string) {
+	fmt.Fprintf(&t.builder, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+		level, page, block, par, line, word,
+		int(bbox.X1), int(bbox.Y1),
+		int(bbox.X2-bbox.X1), int(bbox.Y2-bbox.Y1),
+		formatTSVConfidence(conf), sanitizeTSVText(text))
+}
+
+// lineRows writes a line row followed by rows for each of its words
+func (t *tsvWriter) lineRows(page, block, par, lineNum int, line Line) {
+	t.row(tsvLevelLine, page, block, par, lineNum, 0, line.BBox, -1, "")
+	for w, word := range line.Words {
+		t.row(tsvLevelWord, page, block, par, lineNum, w+1, word.BBox, word.Confidence, word.Text)
+	}
+}
+
+// wordRows writes words that have no parent line as a single synthetic line
+func (t *tsvWriter) wordRows(page, block, par, lineNum int, bbox BoundingBox, words []Word) {
+	t.lineRows(page, block, par, lineNum, Line{BBox: bbox, Words: words})
+}
+
+// paragraphRows writes a paragraph row followed by its lines and words
+func (t *tsvWriter) paragraphRows(page, block, parNum int, para Paragraph) {
+	t.row(tsvLevelParagraph, page, block, parNum, 0, 0, para.BBox, -1, "")
+	lineNum := 0
+	for _, line := range para.Lines {
+		lineNum++
+		t.lineRows(page, block, parNum, lineNum, line)
+	}
+	if len(para.Words) > 0 {
+		lineNum++
+		t.wordRows(page, block, parNum, lineNum, para.BBox, para.Words)
+	}
+}
+
+// ToTSV renders the HOCR document in the TSV format produced by Tesseract
+// (tesseract ... tsv). Each page, block, paragraph, line and word gets its own
+// row, with confidence set to -1 for non-word levels.
+func ToTSV(doc *HOCR) (string, error) {
+	if doc == nil {
+		return "", fmt.Errorf("HOCR document is nil")
+	}
+
+	t := &tsvWriter{}
+	t.builder.WriteString(tsvHeader)
+	t.builder.WriteString("\n")
+
+	for pageIdx, page := range doc.Pages {
+		pageNum := pageNumberOf(page, pageIdx)
+		t.row(tsvLevelPage, pageNum, 0, 0, 0, 0, page.BBox, -1, "")
+
+		blockNum := 0
+		for _, area := range page.Areas {
+			blockNum++
+			t.row(tsvLevelBlock, pageNum, blockNum, 0, 0, 0, area.BBox, -1, "")
+
+			parNum := 0
+			for _, para := range area.Paragraphs {
+				parNum++
+				t.paragraphRows(pageNum, blockNum, parNum, para)
+			}
+
+			// Lines and words directly under the area are wrapped in a synthetic paragraph
+			if len(area.Lines) > 0 || len(area.Words) > 0 {
+				parNum++
+				t.paragraphRows(pageNum, blockNum, parNum, Paragraph{
+					BBox:  area.BBox,
+					Lines: area.Lines,
+					Words: area.Words,
+				})
+			}
+		}
+
+		// Paragraphs directly under the page each get their own synthetic block
+		for _, para := range page.Paragraphs {
+			blockNum++
+			t.row(tsvLevelBlock, pageNum, blockNum, 0, 0, 0, para.BBox, -1, "")
+			t.paragraphRows(pageNum, blockNum, 1, para)
+		}
+
+		// Lines directly under the page share one synthetic block and paragraph
+		if len(page.Lines) > 0 {
+			blockNum++
+			t.row(tsvLevelBlock, pageNum, blockNum, 0, 0, 0, page.BBox, -1, "")
+			t.paragraphRows(pageNum, blockNum, 1, Paragraph{BBox: page.BBox, Lines: page.Lines})
+		}
+	}
+
+	return t.builder.String(), nil
+}
+
+// ToJSONL renders every word in the HOCR document as one JSON object per line
+// containing the page number, text, bounding box and confidence
+func ToJSONL(doc *HOCR) (string, error) {
+	if doc == nil {
+		return "", fmt.Errorf("HOCR document is nil")
+	}
+
+	var builder strings.Builder
+	for _, record := range WordRecords(doc) {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return "", fmt.Errorf("error encoding word %q: %w", record.ID, err)
+		}
+		builder.Write(line)
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
+// WordRecords flattens all words of the document, in document order,
+// into a list of WordRecord values
+func WordRecords(doc *HOCR) []WordRecord {
+	var records []WordRecord
+	if doc == nil {
+		return records
+	}
+
+	for pageIdx, page := range doc.Pages {
+		pageNum := pageNumberOf(page, pageIdx)
+		for _, word := range pageWords(page) {
+			records = append(records, WordRecord{
+				Page:       pageNum,
+				ID:         word.ID,
+				Text:       word.Text,
+				BBox:       [4]float64{word.BBox.X1, word.BBox.Y1, word.BBox.X2, word.BBox.Y2},
+				Confidence: word.Confidence,
+				Lang:       word.Lang,
+			})
+		}
+	}
+
+	return records
+}
+
+// pageWords collects all words on a page in document order
+func pageWords(page Page) []Word {
+	var words []Word
+
+	for _, area := range page.Areas {
+		for _, para := range area.Paragraphs {
+			for _, line := range para.Lines {
+				words = append(words, line.Words...)
+			}
+			words = append(words, para.Words...)
+		}
+		for _, line := range area.Lines {
+			words = append(words, line.Words...)
+		}
+		words = append(words, area.Words...)
+	}
+
+	for _, para := range page.Paragraphs {
+		for _, line := range para.Lines {
+			words = append(words, line.Words...)
+		}
+		words = append(words, para.Words...)
+	}
+
+	for _, line := range page.Lines {
+		words = append(words, line.Words...)
+	}
+
+	return words
+}
+
+// pageNumberOf returns the page's ppageno, falling back to its position in the document
+func pageNumberOf(page Page, index int) int {
+	if page.PageNumber > 0 {
+		return page.PageNumber
+	}
+	return index + 1
+}
+
+// formatTSVConfidence formats confidence the way Tesseract does (-1 for non-words)
+func formatTSVConfidence(conf float64) string {
+	if conf < 0 {
+		return "-1"
+	}
+	return fmt.Sprintf("%.6f", conf)
+}
+
+// sanitizeTSVText strips characters that would break the TSV column layout
+func sanitizeTSVText(text string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", "").Replace(text)
+}
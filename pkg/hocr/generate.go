@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"embed"
 	"fmt"
+	"html"
+	"maps"
 	"strings"
 	"text/template"
 )
@@ -11,22 +13,109 @@ import (
 //go:embed templates/hocr.tmpl
 var templateFS embed.FS
 
+// templateIndent is the indentation of each nesting level in the template
+const templateIndent = "    "
+
+// GenerateOptions controls how GenerateHOCRDocumentWithOptions writes hOCR.
+// The zero value gives the output of GenerateHOCRDocument.
+type GenerateOptions struct {
+	// Indent is the indentation of each nesting level, four spaces if empty
+	Indent string
+
+	// Compact leaves out all indentation and line breaks, for the smallest output
+	Compact bool
+
+	// OmitConfidence leaves out the word and character confidences (x_wconf, x_conf)
+	OmitConfidence bool
+
+	// OmitBaseline leaves out the line baselines
+	OmitBaseline bool
+
+	// StrictXHTML escapes the text and attribute values, and leaves out the id
+	// attribute of elements without an ID, so the output is well-formed XML
+	// even if the text has characters such as '&' or '<'
+	StrictXHTML bool
+
+	// Meta adds meta tags to the head, replacing the document's metadata of the same name
+	Meta map[string]string
+
+	// System names the OCR system in the ocr-system meta tag, replacing the document's
+	System string
+}
+
 // GenerateHOCRDocument creates an hOCR HTML document from the HOCR struct
 // Uses the embedded template to generate a complete HTML document
 func GenerateHOCRDocument(doc *HOCR) (string, error) {
-	// Set up the template with a helper function
+	return GenerateHOCRDocumentWithOptions(doc, GenerateOptions{})
+}
+
+// GenerateHOCRDocumentWithOptions creates an hOCR HTML document from the HOCR
+// struct, formatted as the options say
+func GenerateHOCRDocumentWithOptions(doc *HOCR, options GenerateOptions) (string, error) {
+	text := func(s string) string { return s }
+	if options.StrictXHTML {
+		text = html.EscapeString
+	}
+
+	// Set up the template with the helper functions the options switch
 	tmpl, err := template.New("hocr.tmpl").Funcs(template.FuncMap{
-		"trim": strings.TrimSpace,
+		"trim":       strings.TrimSpace,
+		"text":       text,
+		"confidence": func() bool { return !options.OmitConfidence },
+		"baseline":   func() bool { return !options.OmitBaseline },
+		"id": func(id string) string {
+			if id == "" && options.StrictXHTML {
+				return ""
+			}
+			return fmt.Sprintf(" id='%s'", text(id))
+		},
 	}).ParseFS(templateFS, "templates/hocr.tmpl")
 	if err != nil {
 		return "", fmt.Errorf("error parsing hOCR template: %w", err)
 	}
 
+	// Apply the metadata of the options to a copy of the document
+	if len(options.Meta) > 0 || options.System != "" {
+		copied := *doc
+		copied.Metadata = maps.Clone(doc.Metadata)
+		if copied.Metadata == nil {
+			copied.Metadata = make(map[string]string)
+		}
+		maps.Copy(copied.Metadata, options.Meta)
+		if options.System != "" {
+			copied.Metadata["ocr-system"] = options.System
+		}
+		doc = &copied
+	}
+
 	// Render the template with the hOCR data
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, doc); err != nil {
 		return "", fmt.Errorf("error rendering hOCR template: %w", err)
 	}
 
+	if options.Compact || (options.Indent != "" && options.Indent != templateIndent) {
+		return reindent(buf.String(), options), nil
+	}
 	return buf.String(), nil
 }
+
+// reindent replaces the indentation of the template with the configured one,
+// or removes it along with the line breaks for compact output
+func reindent(output string, options GenerateOptions) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(output, "\n") {
+		content := strings.TrimLeft(line, " ")
+		if options.Compact {
+			b.WriteString(strings.TrimSpace(content))
+			continue
+		}
+		level := (len(line) - len(content)) / len(templateIndent)
+		b.WriteString(strings.Repeat(options.Indent, level))
+		b.WriteString(content)
+	}
+	if options.Compact {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
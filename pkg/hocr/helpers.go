@@ -1,6 +1,7 @@
 package hocr
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -57,12 +58,8 @@ func extractAreaText(builder *strings.Builder, area Area, processed map[string]b
 	}
 
 	// Process words directly in the area (rare, but possible)
-	if len(area.Words) > 0 {
-		for _, word := range area.Words {
-			builder.WriteString(word.Text)
-			builder.WriteString(" ")
-		}
-		builder.WriteString("\n")
+	for _, line := range groupWords(area.Words) {
+		extractLineText(builder, line)
 	}
 }
 
@@ -78,12 +75,8 @@ func extractParagraphText(builder *strings.Builder, para Paragraph, processed ma
 	}
 
 	// Process words directly in the paragraph (if any)
-	if len(para.Words) > 0 {
-		for _, word := range para.Words {
-			builder.WriteString(word.Text)
-			builder.WriteString(" ")
-		}
-		builder.WriteString("\n")
+	for _, line := range groupWords(para.Words) {
+		extractLineText(builder, line)
 	}
 }
 
@@ -98,5 +91,10 @@ func extractLineText(builder *strings.Builder, line Line) {
 
 // getLineKey generates a unique key for a line to avoid duplication
 func getLineKey(line Line) string {
+	if line.ID == "" {
+		// Lines without an ID, such as those grouped from loose words, are
+		// told apart by their position
+		return fmt.Sprintf("bbox %v", line.BBox)
+	}
 	return line.ID
 }
@@ -18,14 +18,27 @@
 //
 // - HOCR: Top-level structure representing an entire hOCR document
 // - Page: Represents a single page with class 'ocr_page'
-// - Area: Represents a content area with class 'ocr_carea'
+// - Area: Represents a content area with class 'ocr_carea', or a photo, table, caption or separator
 // - Paragraph: Represents a paragraph with class 'ocr_par'
 // - Line: Represents a line of text with class 'ocr_line'
 // - Word: Represents a single word with class 'ocrx_word'
+// - Char: Represents a character box of a word with class 'ocrx_cinfo'
 // - BoundingBox: Represents a rectangle with coordinates for positioning elements
 //
 // Main Functions:
 //
-// - ParseHOCR: Parses hOCR data from HTML into the object model
+// - ParseHOCR: Parses hOCR data from HTML into the object model, decoding its declared charset
+// - Parse: Parses hOCR, ALTO or PAGE XML data, detecting the format
 // - GenerateHOCRDocument: Generates valid hOCR HTML from the object model
+// - GenerateHOCRDocumentWithOptions: Generates hOCR HTML with configurable indentation, properties and metadata
+// - Validate: Reports structural problems such as missing bounding boxes or duplicate IDs
+// - Merge: Combines documents into one, renumbering their pages
+// - SplitPages: Splits a document into standalone single-page documents
+// - Sort: Reorders areas, paragraphs and lines into reading order
+// - GroupWordsIntoLines: Clusters words without a line into lines by their positions
+// - Search: Finds text or regular expressions and returns their pages and bounding boxes
+// - ExtractTextLayout: Renders the text as monospaced text keeping the page layout, like pdftotext -layout
+// - ToTSV / ToJSONL: Export word coordinates as Tesseract-style TSV or JSON Lines
+// - ToALTO / ParseALTO: Convert the object model to and from ALTO XML
+// - ToPAGE / ParsePAGE: Convert the object model to and from PRImA PAGE XML
 package hocr
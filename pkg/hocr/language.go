@@ -0,0 +1,230 @@
+package hocr
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minDetectionWords is the minimum number of Latin-script words needed
+// before stopword based detection is attempted
+const minDetectionWords = 3
+
+// scriptLanguages maps non-Latin Unicode scripts to the language they most likely indicate
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// languageStopwords holds the most frequent short words for Latin-script languages
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "be", "by", "this", "are", "from", "or", "have"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "des", "auf", "für", "im", "dem", "auch", "wird"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "du", "en", "que", "pour", "dans", "qui", "pas", "au", "sur", "avec", "ce", "il", "sont"},
+	"es": {"el", "la", "de", "que", "y", "los", "las", "en", "un", "una", "por", "con", "para", "es", "del", "se", "al", "como", "su", "más"},
+	"it": {"il", "di", "che", "la", "e", "per", "un", "una", "sono", "del", "della", "non", "con", "gli", "le", "nel", "alla", "anche", "è", "si"},
+	"pt": {"o", "a", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "os", "as", "por", "se", "no", "na", "mais"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "die", "ook", "als", "aan", "er", "maar", "bij"},
+	"sv": {"och", "att", "det", "som", "en", "är", "av", "för", "med", "till", "den", "på", "har", "inte", "ett", "om", "var", "jag", "de", "kan"},
+	"da": {"og", "at", "det", "som", "en", "er", "af", "for", "med", "til", "den", "på", "har", "ikke", "et", "de", "der", "jeg", "var", "kan"},
+	"no": {"og", "at", "det", "som", "en", "er", "av", "for", "med", "til", "den", "på", "har", "ikke", "et", "de", "jeg", "var", "kan", "ble"},
+	"fi": {"ja", "on", "että", "ei", "se", "ole", "oli", "mutta", "kun", "hän", "tai", "myös", "kanssa", "joka", "ovat", "sen", "niin", "jos", "mitä", "vain"},
+	"is": {"og", "að", "er", "í", "á", "sem", "til", "við", "um", "ekki", "var", "með", "af", "það", "fyrir", "hann", "frá", "eru", "hún", "þess"},
+	"pl": {"i", "w", "nie", "na", "się", "jest", "że", "do", "to", "z", "jak", "ale", "co", "od", "po", "tak", "za", "przez", "są", "dla"},
+	"cs": {"a", "se", "na", "je", "že", "to", "v", "s", "z", "do", "jsou", "ale", "jak", "pro", "by", "od", "tak", "po", "jako", "není"},
+}
+
+// languageMarkers are characters that strongly suggest a particular Latin-script language
+var languageMarkers = map[rune]string{
+	'ð': "is", 'þ': "is", 'ß': "de", 'ñ': "es", 'ã': "pt", 'õ': "pt",
+	'å': "sv", 'ø': "no", 'æ': "da", 'ł': "pl", 'ą': "pl", 'ę': "pl",
+	'ř': "cs", 'ů': "cs", 'ě': "cs",
+}
+
+// DetectLanguage guesses the ISO 639-1 language code of a text sample using
+// Unicode script analysis and stopword frequencies. It runs entirely locally
+// and returns an empty string when the sample is too short or ambiguous.
+func DetectLanguage(text string) string {
+	scriptCounts := make(map[string]int)
+	latinLetters := 0
+	totalLetters := 0
+	markerCounts := make(map[string]int)
+
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		totalLetters++
+
+		if unicode.Is(unicode.Latin, r) {
+			latinLetters++
+			if lang, ok := languageMarkers[unicode.ToLower(r)]; ok {
+				markerCounts[lang]++
+			}
+			continue
+		}
+
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				scriptCounts[script.lang]++
+				break
+			}
+		}
+	}
+
+	if totalLetters == 0 {
+		return ""
+	}
+
+	// Japanese text mixes Kana with Han characters, so any Kana wins over Chinese
+	if scriptCounts["ja"] > 0 && scriptCounts["ja"]+scriptCounts["zh"] > latinLetters {
+		return "ja"
+	}
+
+	// Pick the dominant non-Latin script if it outweighs Latin letters
+	bestScript, bestCount := "", 0
+	for lang, count := range scriptCounts {
+		if count > bestCount || (count == bestCount && lang < bestScript) {
+			bestScript, bestCount = lang, count
+		}
+	}
+	if bestCount > latinLetters {
+		return bestScript
+	}
+
+	return detectLatinLanguage(text, markerCounts)
+}
+
+// detectLatinLanguage scores Latin-script text against stopword lists
+// and language specific characters
+func detectLatinLanguage(text string, markerCounts map[string]int) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) < minDetectionWords {
+		return ""
+	}
+
+	scores := make(map[string]float64)
+	for lang, stopwords := range languageStopwords {
+		set := make(map[string]bool, len(stopwords))
+		for _, w := range stopwords {
+			set[w] = true
+		}
+		for _, w := range words {
+			if set[w] {
+				scores[lang]++
+			}
+		}
+	}
+
+	// Distinctive characters weigh more than shared stopwords
+	for lang, count := range markerCounts {
+		scores[lang] += float64(count) * 2
+	}
+
+	langs := make([]string, 0, len(scores))
+	for lang := range scores {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	best, bestScore, secondScore := "", 0.0, 0.0
+	for _, lang := range langs {
+		score := scores[lang]
+		if score > bestScore {
+			secondScore = bestScore
+			best, bestScore = lang, score
+		} else if score > secondScore {
+			secondScore = score
+		}
+	}
+
+	// Require a minimum hit rate and a clear winner
+	if bestScore < float64(len(words))*0.05 || bestScore == secondScore {
+		return ""
+	}
+
+	return best
+}
+
+// DetectLanguages fills in missing language tags in an HOCR document using
+// local language detection over the text of each page. Pages that already
+// carry a language are left untouched. The document language and the
+// ocr-langs metadata are updated from the detected page languages.
+// It returns the number of pages whose language was detected.
+func DetectLanguages(doc *HOCR) int {
+	if doc == nil {
+		return 0
+	}
+
+	detected := 0
+	langCount := make(map[string]int)
+
+	for i := range doc.Pages {
+		page := &doc.Pages[i]
+		if !isMissingLanguage(page.Lang) {
+			langCount[page.Lang]++
+			continue
+		}
+
+		var texts []string
+		for _, word := range pageWords(*page) {
+			texts = append(texts, word.Text)
+		}
+
+		if lang := DetectLanguage(strings.Join(texts, " ")); lang != "" {
+			page.Lang = lang
+			langCount[lang]++
+			detected++
+		}
+	}
+
+	if len(langCount) == 0 {
+		return detected
+	}
+
+	// Sort languages by frequency for a stable document language and ocr-langs list
+	langs := make([]string, 0, len(langCount))
+	for lang := range langCount {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if langCount[langs[i]] != langCount[langs[j]] {
+			return langCount[langs[i]] > langCount[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+
+	if isMissingLanguage(doc.Language) {
+		doc.Language = langs[0]
+	}
+
+	if doc.Metadata == nil {
+		doc.Metadata = make(map[string]string)
+	}
+	if isMissingLanguage(doc.Metadata["ocr-langs"]) {
+		doc.Metadata["ocr-langs"] = strings.Join(langs, ", ")
+	}
+
+	return detected
+}
+
+// isMissingLanguage reports whether a language tag is absent or a placeholder
+func isMissingLanguage(lang string) bool {
+	lang = strings.TrimSpace(strings.ToLower(lang))
+	return lang == "" || lang == "unknown" || lang == "und"
+}
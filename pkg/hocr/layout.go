@@ -0,0 +1,161 @@
+package hocr
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// layoutLine is a run of words read as one line of a page, with the box
+// spanning them
+type layoutLine struct {
+	BBox  BoundingBox
+	Words []Word
+}
+
+// ExtractTextLayout renders the text of an hOCR document as monospaced text
+// that keeps the approximate layout of each page, like pdftotext -layout.
+// Words are placed at the column their left edge falls in, so side-by-side
+// columns and tables stay aligned, and larger vertical gaps become blank
+// lines. Lines that share a row on the page are joined into one line of
+// text. Pages are separated by form feeds.
+//
+// The column width and line height are the median character width and line
+// height of each page, so text set in very different sizes aligns roughly.
+func ExtractTextLayout(doc *HOCR) string {
+	if doc == nil {
+		return ""
+	}
+	pages := make([]string, 0, len(doc.Pages))
+	for _, page := range doc.Pages {
+		pages = append(pages, pageTextLayout(page))
+	}
+	return strings.Join(pages, "\f")
+}
+
+// pageTextLayout renders the text of one page, ending with a newline
+func pageTextLayout(page Page) string {
+	lines := layoutLines(page)
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// Measure the grid the text is placed on
+	var charWidths, lineHeights []float64
+	left := math.Inf(1)
+	for _, line := range lines {
+		lineHeights = append(lineHeights, line.BBox.Y2-line.BBox.Y1)
+		left = min(left, line.BBox.X1)
+		for _, word := range line.Words {
+			if runes := utf8.RuneCountInString(word.Text); runes > 0 && word.BBox.X2 > word.BBox.X1 {
+				charWidths = append(charWidths, (word.BBox.X2-word.BBox.X1)/float64(runes))
+			}
+		}
+	}
+	charWidth := median(charWidths)
+	lineHeight := median(lineHeights)
+
+	// Group lines that overlap vertically into rows, top to bottom
+	slices.SortStableFunc(lines, func(a, b layoutLine) int { return cmp.Compare(a.BBox.Y1, b.BBox.Y1) })
+	var builder strings.Builder
+	var previous BoundingBox
+	for start := 0; start < len(lines); {
+		row := lines[start].BBox
+		words := slices.Clone(lines[start].Words)
+		end := start + 1
+		for ; end < len(lines) && sameRow(row, lines[end].BBox); end++ {
+			row.Y1 = min(row.Y1, lines[end].BBox.Y1)
+			row.Y2 = max(row.Y2, lines[end].BBox.Y2)
+			words = append(words, lines[end].Words...)
+		}
+
+		if start > 0 && lineHeight > 0 {
+			for blank := int((row.Y1 - previous.Y2) / lineHeight); blank > 0; blank-- {
+				builder.WriteString("\n")
+			}
+		}
+		builder.WriteString(layoutRow(words, left, charWidth))
+		builder.WriteString("\n")
+		previous = row
+		start = end
+	}
+	return builder.String()
+}
+
+// layoutRow places the words of a row at the columns of their left edges,
+// keeping at least one space between words
+func layoutRow(words []Word, left, charWidth float64) string {
+	slices.SortStableFunc(words, func(a, b Word) int { return cmp.Compare(a.BBox.X1, b.BBox.X1) })
+	var builder strings.Builder
+	length := 0
+	for _, word := range words {
+		column := 0
+		if length > 0 {
+			column = length + 1
+		}
+		if charWidth > 0 {
+			column = max(column, int(math.Round((word.BBox.X1-left)/charWidth)))
+		}
+		builder.WriteString(strings.Repeat(" ", column-length))
+		builder.WriteString(word.Text)
+		length = column + utf8.RuneCountInString(word.Text)
+	}
+	return builder.String()
+}
+
+// layoutLines collects the lines of a page with text, in any order. Words
+// directly in a paragraph or area are grouped into lines.
+func layoutLines(page Page) []layoutLine {
+	var lines []layoutLine
+	add := func(words []Word) {
+		line := layoutLine{BBox: BoundingBox{X1: math.Inf(1), Y1: math.Inf(1), X2: math.Inf(-1), Y2: math.Inf(-1)}}
+		for _, word := range words {
+			if strings.TrimSpace(word.Text) == "" {
+				continue
+			}
+			line.Words = append(line.Words, word)
+			line.BBox = NewBoundingBox(min(line.BBox.X1, word.BBox.X1), min(line.BBox.Y1, word.BBox.Y1),
+				max(line.BBox.X2, word.BBox.X2), max(line.BBox.Y2, word.BBox.Y2))
+		}
+		if len(line.Words) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	addParagraphs := func(paragraphs []Paragraph) {
+		for _, para := range paragraphs {
+			for _, line := range para.Lines {
+				add(line.Words)
+			}
+			for _, line := range groupWords(para.Words) {
+				add(line.Words)
+			}
+		}
+	}
+
+	for _, area := range page.Areas {
+		addParagraphs(area.Paragraphs)
+		for _, line := range area.Lines {
+			add(line.Words)
+		}
+		for _, line := range groupWords(area.Words) {
+			add(line.Words)
+		}
+	}
+	addParagraphs(page.Paragraphs)
+	for _, line := range page.Lines {
+		add(line.Words)
+	}
+	return lines
+}
+
+// median returns the median of the values, or 0 if there are none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
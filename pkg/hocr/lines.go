@@ -0,0 +1,85 @@
+package hocr
+
+import (
+	"cmp"
+	"slices"
+)
+
+// lineGapFactor is how many times the height of a row the horizontal gap
+// between two of its words may be before they are taken to be in different
+// columns, and so on different lines
+const lineGapFactor = 2.0
+
+// GroupWordsIntoLines clusters the words directly under areas and paragraphs
+// into lines, in place, for input from engines that only report word boxes.
+// Words that overlap vertically by at least half the height of the smaller
+// one share a row, and a row is split into separate lines where a horizontal
+// gap between words is wider than twice the height of the row. The lines are
+// added to the element's Lines in reading order, each with its words left to
+// right, and its Words are emptied.
+func GroupWordsIntoLines(doc *HOCR) {
+	if doc == nil {
+		return
+	}
+	for i := range doc.Pages {
+		page := &doc.Pages[i]
+		for j := range page.Areas {
+			area := &page.Areas[j]
+			groupParagraphWords(area.Paragraphs)
+			area.Lines = append(area.Lines, groupWords(area.Words)...)
+			area.Words = nil
+		}
+		groupParagraphWords(page.Paragraphs)
+	}
+}
+
+// groupParagraphWords clusters the words directly under paragraphs into lines
+func groupParagraphWords(paragraphs []Paragraph) {
+	for i := range paragraphs {
+		paragraphs[i].Lines = append(paragraphs[i].Lines, groupWords(paragraphs[i].Words)...)
+		paragraphs[i].Words = nil
+	}
+}
+
+// groupWords clusters words into lines, top to bottom and left to right
+func groupWords(words []Word) []Line {
+	if len(words) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(words)
+	slices.SortStableFunc(sorted, func(a, b Word) int { return cmp.Compare(a.BBox.Y1+a.BBox.Y2, b.BBox.Y1+b.BBox.Y2) })
+
+	var lines []Line
+	for start := 0; start < len(sorted); {
+		row := sorted[start].BBox
+		end := start + 1
+		for ; end < len(sorted) && sameRow(row, sorted[end].BBox); end++ {
+			row.Y1 = min(row.Y1, sorted[end].BBox.Y1)
+			row.Y2 = max(row.Y2, sorted[end].BBox.Y2)
+		}
+
+		// Split the row where the words are too far apart to be one line
+		rowWords := sorted[start:end]
+		slices.SortStableFunc(rowWords, func(a, b Word) int { return cmp.Compare(a.BBox.X1, b.BBox.X1) })
+		maxGap := (row.Y2 - row.Y1) * lineGapFactor
+		first := 0
+		for k := 1; k <= len(rowWords); k++ {
+			if k == len(rowWords) || rowWords[k].BBox.X1-rowWords[k-1].BBox.X2 > maxGap {
+				lines = append(lines, lineOfWords(rowWords[first:k]))
+				first = k
+			}
+		}
+		start = end
+	}
+	return lines
+}
+
+// lineOfWords returns a line holding the words, with the box spanning them
+func lineOfWords(words []Word) Line {
+	line := Line{BBox: words[0].BBox, Words: slices.Clone(words)}
+	for _, word := range words[1:] {
+		line.BBox = NewBoundingBox(min(line.BBox.X1, word.BBox.X1), min(line.BBox.Y1, word.BBox.Y1),
+			max(line.BBox.X2, word.BBox.X2), max(line.BBox.Y2, word.BBox.Y2))
+	}
+	return line
+}
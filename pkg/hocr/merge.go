@@ -0,0 +1,201 @@
+package hocr
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pageIDPattern extracts the page number from a page ID such as "page_3"
+var pageIDPattern = regexp.MustCompile(`^page_(\d+)$`)
+
+// Merge combines hOCR documents into one by concatenating their pages in order,
+// e.g. to join the output of per-page Tesseract runs before calling ApplyOCR.
+// Pages are renumbered from 1: page IDs become page_N, ppageno becomes N and
+// element IDs that embed the old page number (such as word_1_4) follow along.
+// Title and description come from the first document that has them, and
+// metadata is merged with ocr-langs and ocr-capabilities combined across
+// documents. The input documents are not modified.
+func Merge(docs ...*HOCR) *HOCR {
+	result := &HOCR{Metadata: make(map[string]string)}
+	var langs, capabilities []string
+
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		if result.Title == "" {
+			result.Title = doc.Title
+		}
+		if result.Description == "" {
+			result.Description = doc.Description
+		}
+		if isMissingLanguage(result.Language) && !isMissingLanguage(doc.Language) {
+			result.Language = doc.Language
+		}
+
+		for key, value := range doc.Metadata {
+			switch key {
+			case "ocr-number-of-pages":
+				// Recomputed from the merged pages when the document is generated
+			case "ocr-langs":
+				langs = appendUnique(langs, strings.Split(value, ",")...)
+			case "ocr-capabilities":
+				capabilities = appendUnique(capabilities, strings.Fields(value)...)
+			default:
+				if _, ok := result.Metadata[key]; !ok {
+					result.Metadata[key] = value
+				}
+			}
+		}
+		langs = appendUnique(langs, doc.Language)
+
+		for _, page := range doc.Pages {
+			langs = appendUnique(langs, page.Lang)
+			result.Pages = append(result.Pages, renumberPage(page, len(result.Pages)+1))
+		}
+	}
+
+	if len(langs) > 0 {
+		result.Metadata["ocr-langs"] = strings.Join(langs, ", ")
+	}
+	if len(capabilities) > 0 {
+		result.Metadata["ocr-capabilities"] = strings.Join(capabilities, " ")
+	}
+	if isMissingLanguage(result.Language) && len(langs) > 0 {
+		result.Language = langs[0]
+	}
+
+	return result
+}
+
+// appendUnique appends the trimmed values that are not missing or already present
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if isMissingLanguage(value) {
+			continue
+		}
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// renumberPage returns a deep copy of a page moved to a new page number,
+// rewriting the page number embedded in the IDs of the page and its elements
+func renumberPage(page Page, pageNumber int) Page {
+	oldNumber := page.PageNumber
+	if match := pageIDPattern.FindStringSubmatch(page.ID); match != nil {
+		oldNumber, _ = strconv.Atoi(match[1])
+	}
+	rename := func(id string) string {
+		return renumberID(id, oldNumber, pageNumber)
+	}
+
+	result := clonePage(page, rename)
+	result.ID = fmt.Sprintf("page_%d", pageNumber)
+	result.PageNumber = pageNumber
+	result.Title = ""
+	return result
+}
+
+// renumberID replaces the page number in IDs of the form kind_<page>[_...]
+func renumberID(id string, oldNumber, newNumber int) string {
+	kind, rest, ok := strings.Cut(id, "_")
+	if !ok || oldNumber <= 0 {
+		return id
+	}
+	number, tail, _ := strings.Cut(rest, "_")
+	if number != strconv.Itoa(oldNumber) {
+		return id
+	}
+	id = kind + "_" + strconv.Itoa(newNumber)
+	if tail != "" {
+		id += "_" + tail
+	}
+	return id
+}
+
+// clonePage returns a deep copy of a page, passing every element ID below the
+// page through rename
+func clonePage(page Page, rename func(string) string) Page {
+	result := page
+	result.Metadata = maps.Clone(page.Metadata)
+	result.Areas = nil
+	for _, area := range page.Areas {
+		result.Areas = append(result.Areas, Area{
+			ID:         rename(area.ID),
+			Type:       area.Type,
+			Lang:       area.Lang,
+			BBox:       area.BBox,
+			Order:      area.Order,
+			Flow:       area.Flow,
+			Paragraphs: cloneParagraphs(area.Paragraphs, rename),
+			Lines:      cloneLines(area.Lines, rename),
+			Words:      cloneWords(area.Words, rename),
+			Metadata:   maps.Clone(area.Metadata),
+		})
+	}
+	result.Paragraphs = cloneParagraphs(page.Paragraphs, rename)
+	result.Lines = cloneLines(page.Lines, rename)
+	return result
+}
+
+// cloneParagraphs returns deep copies of paragraphs with renamed IDs
+func cloneParagraphs(paragraphs []Paragraph, rename func(string) string) []Paragraph {
+	var result []Paragraph
+	for _, paragraph := range paragraphs {
+		result = append(result, Paragraph{
+			ID:       rename(paragraph.ID),
+			Lang:     paragraph.Lang,
+			BBox:     paragraph.BBox,
+			Order:    paragraph.Order,
+			Flow:     paragraph.Flow,
+			Lines:    cloneLines(paragraph.Lines, rename),
+			Words:    cloneWords(paragraph.Words, rename),
+			Metadata: maps.Clone(paragraph.Metadata),
+		})
+	}
+	return result
+}
+
+// cloneLines returns deep copies of lines with renamed IDs
+func cloneLines(lines []Line, rename func(string) string) []Line {
+	var result []Line
+	for _, line := range lines {
+		result = append(result, Line{
+			ID:       rename(line.ID),
+			Lang:     line.Lang,
+			BBox:     line.BBox,
+			Order:    line.Order,
+			Baseline: line.Baseline,
+			Words:    cloneWords(line.Words, rename),
+			Metadata: maps.Clone(line.Metadata),
+		})
+	}
+	return result
+}
+
+// cloneWords returns deep copies of words with renamed IDs
+func cloneWords(words []Word, rename func(string) string) []Word {
+	var result []Word
+	for _, word := range words {
+		word.ID = rename(word.ID)
+		word.Metadata = maps.Clone(word.Metadata)
+		word.Chars = slices.Clone(word.Chars)
+		result = append(result, word)
+	}
+	return result
+}
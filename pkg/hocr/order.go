@@ -0,0 +1,105 @@
+package hocr
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Sort reorders the areas, paragraphs and lines of every page into reading
+// order, in place. OCR engines such as Document AI emit blocks in detection
+// order, which often interleaves the columns of a page.
+//
+// Siblings that all carry an hOCR 'order' property are sorted by it. Other
+// siblings are sorted by position: top to bottom, and left to right within
+// elements that share a row, so side-by-side columns are read left to right
+// and each column top to bottom. Words within a line keep their order.
+func Sort(doc *HOCR) {
+	if doc == nil {
+		return
+	}
+	for i := range doc.Pages {
+		sortPage(&doc.Pages[i])
+	}
+}
+
+// sortPage puts the elements of a page into reading order
+func sortPage(page *Page) {
+	sortElements(page.Areas, func(area Area) (BoundingBox, int) { return area.BBox, area.Order })
+	for i := range page.Areas {
+		area := &page.Areas[i]
+		sortParagraphs(area.Paragraphs)
+		sortLines(area.Lines)
+	}
+	sortParagraphs(page.Paragraphs)
+	sortLines(page.Lines)
+}
+
+// sortParagraphs puts paragraphs and their lines into reading order
+func sortParagraphs(paragraphs []Paragraph) {
+	sortElements(paragraphs, func(para Paragraph) (BoundingBox, int) { return para.BBox, para.Order })
+	for i := range paragraphs {
+		sortLines(paragraphs[i].Lines)
+	}
+}
+
+// sortLines puts lines into reading order
+func sortLines(lines []Line) {
+	sortElements(lines, func(line Line) (BoundingBox, int) { return line.BBox, line.Order })
+}
+
+// sortElements sorts sibling elements by their 'order' property if every one
+// has it, and by position otherwise
+func sortElements[E any](elements []E, key func(E) (BoundingBox, int)) {
+	if len(elements) < 2 {
+		return
+	}
+
+	ordered := true
+	for _, element := range elements {
+		if _, order := key(element); order <= 0 {
+			ordered = false
+			break
+		}
+	}
+	if ordered {
+		slices.SortStableFunc(elements, func(a, b E) int {
+			_, orderA := key(a)
+			_, orderB := key(b)
+			return cmp.Compare(orderA, orderB)
+		})
+		return
+	}
+
+	// Sort top to bottom, then group elements that overlap vertically into
+	// rows and sort each row left to right
+	slices.SortStableFunc(elements, func(a, b E) int {
+		boxA, _ := key(a)
+		boxB, _ := key(b)
+		return cmp.Compare(boxA.Y1, boxB.Y1)
+	})
+	for start := 0; start < len(elements); {
+		row, _ := key(elements[start])
+		end := start + 1
+		for ; end < len(elements); end++ {
+			box, _ := key(elements[end])
+			if !sameRow(row, box) {
+				break
+			}
+			row.Y2 = max(row.Y2, box.Y2)
+		}
+		slices.SortStableFunc(elements[start:end], func(a, b E) int {
+			boxA, _ := key(a)
+			boxB, _ := key(b)
+			return cmp.Compare(boxA.X1, boxB.X1)
+		})
+		start = end
+	}
+}
+
+// sameRow reports whether a box overlaps a row vertically by at least half
+// the height of the smaller of the two
+func sameRow(row, box BoundingBox) bool {
+	overlap := min(row.Y2, box.Y2) - max(row.Y1, box.Y1)
+	smaller := min(row.Y2-row.Y1, box.Y2-box.Y1)
+	return smaller > 0 && overlap >= smaller/2
+}
@@ -0,0 +1,491 @@
+package hocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PAGE XML (2019-07-15) namespace and schema location
+const (
+	pageNamespace      = "http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15"
+	pageSchemaLocation = "http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15 http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15/pagecontent.xsd"
+)
+
+// pcGts is the root <PcGts> element. A PAGE XML document describes a single page.
+type pcGts struct {
+	XMLName        xml.Name     `xml:"PcGts"`
+	Xmlns          string       `xml:"xmlns,attr,omitempty"`
+	XmlnsXsi       string       `xml:"xmlns:xsi,attr,omitempty"`
+	SchemaLocation string       `xml:"xsi:schemaLocation,attr,omitempty"`
+	Metadata       pageMetadata `xml:"Metadata"`
+	Page           pageXMLPage  `xml:"Page"`
+}
+
+// pageMetadata holds the document metadata required by the schema
+type pageMetadata struct {
+	Creator    string `xml:"Creator"`
+	Created    string `xml:"Created"`
+	LastChange string `xml:"LastChange"`
+}
+
+// pageXMLPage is the <Page> element
+type pageXMLPage struct {
+	ImageFilename string           `xml:"imageFilename,attr"`
+	ImageWidth    int              `xml:"imageWidth,attr"`
+	ImageHeight   int              `xml:"imageHeight,attr"`
+	TextRegions   []pageTextRegion `xml:"TextRegion"`
+}
+
+// pageTextRegion is a <TextRegion>, which may contain lines or nested regions
+type pageTextRegion struct {
+	ID          string           `xml:"id,attr"`
+	Type        string           `xml:"type,attr,omitempty"`
+	Coords      pageCoords       `xml:"Coords"`
+	TextRegions []pageTextRegion `xml:"TextRegion"`
+	TextLines   []pageTextLine   `xml:"TextLine"`
+	TextEquiv   *pageTextEquiv   `xml:"TextEquiv,omitempty"`
+}
+
+// pageTextLine is a <TextLine>
+type pageTextLine struct {
+	ID        string         `xml:"id,attr"`
+	Coords    pageCoords     `xml:"Coords"`
+	Baseline  *pageCoords    `xml:"Baseline,omitempty"`
+	Words     []pageWord     `xml:"Word"`
+	TextEquiv *pageTextEquiv `xml:"TextEquiv,omitempty"`
+}
+
+// pageWord is a <Word>
+type pageWord struct {
+	ID        string         `xml:"id,attr"`
+	Coords    pageCoords     `xml:"Coords"`
+	TextEquiv *pageTextEquiv `xml:"TextEquiv,omitempty"`
+}
+
+// pageCoords holds a polygon as a list of "x,y" points
+type pageCoords struct {
+	Points string `xml:"points,attr"`
+}
+
+// pageTextEquiv holds the recognized text of an element
+type pageTextEquiv struct {
+	Conf    string `xml:"conf,attr,omitempty"`
+	Unicode string `xml:"Unicode"`
+}
+
+// ToPAGE renders the HOCR document as PRImA PAGE XML (2019-07-15 schema).
+// PAGE XML describes one page per document, so one XML document is
+// returned for each page. Areas become TextRegions containing a nested
+// TextRegion per paragraph; paragraphs directly under the page become
+// top-level TextRegions.
+func ToPAGE(doc *HOCR) ([]string, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("HOCR document is nil")
+	}
+
+	creator := doc.Metadata["ocr-system"]
+	if creator == "" {
+		creator = "ocrchestra"
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	var documents []string
+	for pageIdx, page := range doc.Pages {
+		pageNum := pageNumberOf(page, pageIdx)
+		imageName := strings.Trim(page.ImageName, `"`)
+		if imageName == "" {
+			imageName = fmt.Sprintf("page_%d", pageNum)
+		}
+
+		gts := pcGts{
+			Xmlns:          pageNamespace,
+			XmlnsXsi:       xsiNamespace,
+			SchemaLocation: pageSchemaLocation,
+			Metadata:       pageMetadata{Creator: creator, Created: now, LastChange: now},
+			Page: pageXMLPage{
+				ImageFilename: imageName,
+				ImageWidth:    int(math.Round(page.BBox.X2 - page.BBox.X1)),
+				ImageHeight:   int(math.Round(page.BBox.Y2 - page.BBox.Y1)),
+			},
+		}
+
+		ids := &pageIDs{page: pageNum}
+		for _, area := range page.Areas {
+			region := pageTextRegion{ID: ids.next(area.ID, "block"), Coords: pageCoordsOf(area.BBox)}
+			for _, para := range area.Paragraphs {
+				region.TextRegions = append(region.TextRegions, pageRegionOf(para, ids))
+			}
+			if len(area.Lines) > 0 || len(area.Words) > 0 {
+				region.TextRegions = append(region.TextRegions, pageRegionOf(Paragraph{
+					BBox:  area.BBox,
+					Lines: area.Lines,
+					Words: area.Words,
+				}, ids))
+			}
+			gts.Page.TextRegions = append(gts.Page.TextRegions, region)
+		}
+		for _, para := range page.Paragraphs {
+			gts.Page.TextRegions = append(gts.Page.TextRegions, pageRegionOf(para, ids))
+		}
+		if len(page.Lines) > 0 {
+			gts.Page.TextRegions = append(gts.Page.TextRegions,
+				pageRegionOf(Paragraph{BBox: page.BBox, Lines: page.Lines}, ids))
+		}
+
+		output, err := xml.MarshalIndent(gts, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding PAGE XML for page %d: %w", pageNum, err)
+		}
+		documents = append(documents, xml.Header+string(output)+"\n")
+	}
+
+	return documents, nil
+}
+
+// pageIDs hands out element IDs, which PAGE XML requires on every region, line and word
+type pageIDs struct {
+	page     int
+	counters map[string]int
+}
+
+// next returns the element's own ID or a generated one like "line_1_3"
+func (p *pageIDs) next(id, kind string) string {
+	if p.counters == nil {
+		p.counters = make(map[string]int)
+	}
+	p.counters[kind]++
+	if id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s_%d_%d", kind, p.page, p.counters[kind])
+}
+
+// pageRegionOf converts an hOCR paragraph into a paragraph TextRegion
+func pageRegionOf(para Paragraph, ids *pageIDs) pageTextRegion {
+	region := pageTextRegion{
+		ID:     ids.next(para.ID, "par"),
+		Type:   "paragraph",
+		Coords: pageCoordsOf(para.BBox),
+	}
+
+	// Words directly under the paragraph form a synthetic line
+	lines := para.Lines[:len(para.Lines):len(para.Lines)]
+	if len(para.Words) > 0 {
+		lines = append(lines, Line{BBox: para.BBox, Words: para.Words})
+	}
+
+	var texts []string
+	for _, line := range lines {
+		textLine := pageTextLine{ID: ids.next(line.ID, "line"), Coords: pageCoordsOf(line.BBox)}
+		if baseline := pageBaselineOf(line); baseline != "" {
+			textLine.Baseline = &pageCoords{Points: baseline}
+		}
+
+		var words []string
+		for _, word := range line.Words {
+			pw := pageWord{
+				ID:        ids.next(word.ID, "word"),
+				Coords:    pageCoordsOf(word.BBox),
+				TextEquiv: &pageTextEquiv{Unicode: word.Text},
+			}
+			if word.ConfidenceKnown() {
+				pw.TextEquiv.Conf = strconv.FormatFloat(word.Confidence/100, 'f', -1, 64)
+			}
+			textLine.Words = append(textLine.Words, pw)
+			words = append(words, word.Text)
+		}
+
+		lineText := strings.Join(words, " ")
+		textLine.TextEquiv = &pageTextEquiv{Unicode: lineText}
+		region.TextLines = append(region.TextLines, textLine)
+		texts = append(texts, lineText)
+	}
+
+	region.TextEquiv = &pageTextEquiv{Unicode: strings.Join(texts, "\n")}
+	return region
+}
+
+// pageCoordsOf converts a bounding box into a rectangular polygon
+func pageCoordsOf(bbox BoundingBox) pageCoords {
+	x1, y1 := int(math.Round(bbox.X1)), int(math.Round(bbox.Y1))
+	x2, y2 := int(math.Round(bbox.X2)), int(math.Round(bbox.Y2))
+	return pageCoords{Points: fmt.Sprintf("%d,%d %d,%d %d,%d %d,%d", x1, y1, x2, y1, x2, y2, x1, y2)}
+}
+
+// pageBaselineOf converts an hOCR "slope offset" baseline, which is relative
+// to the bottom-left corner of the line, into a two point PAGE baseline
+func pageBaselineOf(line Line) string {
+	fields := strings.Fields(line.Baseline)
+	if len(fields) != 2 {
+		return ""
+	}
+	slope, err1 := strconv.ParseFloat(fields[0], 64)
+	offset, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+
+	width := line.BBox.X2 - line.BBox.X1
+	y1 := line.BBox.Y2 + offset
+	y2 := y1 + slope*width
+	return fmt.Sprintf("%d,%d %d,%d",
+		int(math.Round(line.BBox.X1)), int(math.Round(y1)),
+		int(math.Round(line.BBox.X2)), int(math.Round(y2)))
+}
+
+// pageXMLInput is the part of a PAGE XML document ParsePAGE reads. Unlike
+// pcGts it keeps regions of every type, in document order.
+type pageXMLInput struct {
+	XMLName  xml.Name     `xml:"PcGts"`
+	Metadata pageMetadata `xml:"Metadata"`
+	Page     struct {
+		ImageFilename string       `xml:"imageFilename,attr"`
+		ImageWidth    int          `xml:"imageWidth,attr"`
+		ImageHeight   int          `xml:"imageHeight,attr"`
+		Regions       []pageRegion `xml:",any"`
+	} `xml:"Page"`
+}
+
+// pageRegion is a region of any type, such as a TextRegion, a TableRegion
+// whose cells are nested TextRegions, or an ImageRegion. Other elements
+// caught by the any field are not regions and are skipped.
+type pageRegion struct {
+	XMLName   xml.Name
+	ID        string         `xml:"id,attr"`
+	Coords    pageCoords     `xml:"Coords"`
+	TextLines []pageTextLine `xml:"TextLine"`
+	TextEquiv *pageTextEquiv `xml:"TextEquiv"`
+	Regions   []pageRegion   `xml:",any"`
+}
+
+// isRegion reports whether the element is a region rather than, say, a
+// ReadingOrder or TextStyle
+func (r pageRegion) isRegion() bool {
+	return strings.HasSuffix(r.XMLName.Local, "Region")
+}
+
+// isText reports whether the region is a TextRegion
+func (r pageRegion) isText() bool {
+	return r.XMLName.Local == "TextRegion"
+}
+
+// nested returns the regions nested in the region
+func (r pageRegion) nested() []pageRegion {
+	var regions []pageRegion
+	for _, region := range r.Regions {
+		if region.isRegion() {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// ParsePAGE parses one or more PRImA PAGE XML documents, one per page, into
+// an HOCR struct. TextRegions without nested regions become paragraphs.
+// Other regions become areas holding a paragraph for each TextRegion found
+// in them at any depth, such as the cells of a TableRegion; regions without
+// any text, like images, are skipped. TextLines and Words keep their
+// polygon's bounding box. Lines that carry text but no Word elements, as
+// produced by many layout analysis tools, are split into words with
+// estimated positions.
+func ParsePAGE(documents ...[]byte) (HOCR, error) {
+	result := HOCR{
+		Title:    "OCR Output",
+		Metadata: make(map[string]string),
+	}
+
+	for i, data := range documents {
+		var gts pageXMLInput
+		if err := xml.Unmarshal(data, &gts); err != nil {
+			return HOCR{}, fmt.Errorf("error parsing PAGE XML document %d: %w", i+1, err)
+		}
+
+		if creator := strings.TrimSpace(gts.Metadata.Creator); creator != "" && result.Metadata["ocr-system"] == "" {
+			result.Metadata["ocr-system"] = creator
+		}
+
+		pageNum := i + 1
+		ids := &pageIDs{page: pageNum}
+		page := Page{
+			ID:         fmt.Sprintf("page_%d", pageNum),
+			PageNumber: pageNum,
+			ImageName:  gts.Page.ImageFilename,
+			BBox:       NewBoundingBox(0, 0, float64(gts.Page.ImageWidth), float64(gts.Page.ImageHeight)),
+			Metadata:   make(map[string]string),
+		}
+
+		for _, region := range gts.Page.Regions {
+			if !region.isRegion() {
+				continue
+			}
+			if region.isText() && len(region.nested()) == 0 {
+				page.Paragraphs = append(page.Paragraphs, paragraphOfPageRegion(region, ids))
+				continue
+			}
+
+			var paragraphs []Paragraph
+			if len(region.TextLines) > 0 {
+				paragraphs = append(paragraphs, paragraphOfPageRegion(pageRegion{
+					Coords:    region.Coords,
+					TextLines: region.TextLines,
+				}, ids))
+			}
+			var collect func(regions []pageRegion)
+			collect = func(regions []pageRegion) {
+				for _, nested := range regions {
+					if nested.isText() {
+						paragraphs = append(paragraphs, paragraphOfPageRegion(nested, ids))
+					}
+					collect(nested.nested())
+				}
+			}
+			collect(region.nested())
+			if len(paragraphs) == 0 && !region.isText() {
+				continue
+			}
+
+			page.Areas = append(page.Areas, Area{
+				ID:         ids.next(region.ID, "block"),
+				BBox:       bboxOfPoints(region.Coords.Points),
+				Paragraphs: paragraphs,
+				Metadata:   make(map[string]string),
+			})
+		}
+
+		result.Pages = append(result.Pages, page)
+	}
+
+	return result, nil
+}
+
+// paragraphOfPageRegion converts the lines of a TextRegion into a paragraph
+func paragraphOfPageRegion(region pageRegion, ids *pageIDs) Paragraph {
+	para := Paragraph{
+		ID:       ids.next(region.ID, "par"),
+		BBox:     bboxOfPoints(region.Coords.Points),
+		Metadata: make(map[string]string),
+	}
+
+	for _, textLine := range region.TextLines {
+		line := Line{
+			ID:       ids.next(textLine.ID, "line"),
+			BBox:     bboxOfPoints(textLine.Coords.Points),
+			Metadata: make(map[string]string),
+		}
+		if textLine.Baseline != nil {
+			line.Baseline = hocrBaselineOf(textLine.Baseline.Points, line.BBox)
+		}
+
+		for _, pw := range textLine.Words {
+			if pw.TextEquiv == nil || strings.TrimSpace(pw.TextEquiv.Unicode) == "" {
+				continue
+			}
+			word := Word{
+				ID:       ids.next(pw.ID, "word"),
+				Text:     strings.TrimSpace(pw.TextEquiv.Unicode),
+				BBox:     bboxOfPoints(pw.Coords.Points),
+				Metadata: make(map[string]string),
+			}
+			if conf, err := strconv.ParseFloat(pw.TextEquiv.Conf, 64); err == nil {
+				word.Confidence = conf * 100
+				word.HasConfidence = true
+			}
+			line.Words = append(line.Words, word)
+		}
+
+		// Without Word elements, spread the line text over the line's box
+		if len(textLine.Words) == 0 && textLine.TextEquiv != nil {
+			line.Words = estimateWords(textLine.TextEquiv, line.BBox, ids)
+		}
+
+		para.Lines = append(para.Lines, line)
+	}
+
+	return para
+}
+
+// estimateWords splits line text into words, giving each a share of the
+// line's width proportional to its number of characters
+func estimateWords(equiv *pageTextEquiv, bbox BoundingBox, ids *pageIDs) []Word {
+	texts := strings.Fields(equiv.Unicode)
+	if len(texts) == 0 {
+		return nil
+	}
+
+	// Count spaces between words as one character each
+	total := len(texts) - 1
+	for _, text := range texts {
+		total += utf8.RuneCountInString(text)
+	}
+	charWidth := (bbox.X2 - bbox.X1) / float64(total)
+
+	var confidence float64
+	hasConfidence := false
+	if conf, err := strconv.ParseFloat(equiv.Conf, 64); err == nil {
+		confidence, hasConfidence = conf*100, true
+	}
+
+	var words []Word
+	x := bbox.X1
+	for _, text := range texts {
+		width := charWidth * float64(utf8.RuneCountInString(text))
+		words = append(words, Word{
+			ID:            ids.next("", "word"),
+			Text:          text,
+			BBox:          NewBoundingBox(x, bbox.Y1, x+width, bbox.Y2),
+			Confidence:    confidence,
+			HasConfidence: hasConfidence,
+			Metadata:      make(map[string]string),
+		})
+		x += width + charWidth
+	}
+	return words
+}
+
+// bboxOfPoints returns the bounding box of a PAGE "x,y x,y ..." polygon
+func bboxOfPoints(points string) BoundingBox {
+	var bbox BoundingBox
+	first := true
+	for _, point := range strings.Fields(points) {
+		xs, ys, ok := strings.Cut(point, ",")
+		if !ok {
+			continue
+		}
+		x, err1 := strconv.ParseFloat(xs, 64)
+		y, err2 := strconv.ParseFloat(ys, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if first {
+			bbox = NewBoundingBox(x, y, x, y)
+			first = false
+			continue
+		}
+		bbox.X1, bbox.Y1 = math.Min(bbox.X1, x), math.Min(bbox.Y1, y)
+		bbox.X2, bbox.Y2 = math.Max(bbox.X2, x), math.Max(bbox.Y2, y)
+	}
+	return bbox
+}
+
+// hocrBaselineOf converts a PAGE baseline polyline into an hOCR "slope offset"
+// baseline relative to the bottom-left corner of the line box
+func hocrBaselineOf(points string, lineBox BoundingBox) string {
+	fields := strings.Fields(points)
+	if len(fields) < 2 {
+		return ""
+	}
+	start, end := bboxOfPoints(fields[0]), bboxOfPoints(fields[len(fields)-1])
+	if end.X1 == start.X1 {
+		return ""
+	}
+	slope := (end.Y1 - start.Y1) / (end.X1 - start.X1)
+	offset := start.Y1 + slope*(lineBox.X1-start.X1) - lineBox.Y2
+	return fmt.Sprintf("%s %s",
+		strconv.FormatFloat(math.Round(slope*1000)/1000, 'f', -1, 64),
+		strconv.FormatFloat(math.Round(offset), 'f', -1, 64))
+}
@@ -0,0 +1,68 @@
+package hocr
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePAGENestedRegions(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<pc:PcGts xmlns:pc="http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15">
+  <pc:Metadata><pc:Creator>layout</pc:Creator></pc:Metadata>
+  <pc:Page imageFilename="page.png" imageWidth="1000" imageHeight="1000">
+    <pc:ReadingOrder><pc:OrderedGroup id="ro"/></pc:ReadingOrder>
+    <pc:TextRegion id="heading">
+      <pc:Coords points="0,0 1000,0 1000,100 0,100"/>
+      <pc:TextLine id="heading_line">
+        <pc:Coords points="0,0 1000,0 1000,100 0,100"/>
+        <pc:TextEquiv><pc:Unicode>Invoice</pc:Unicode></pc:TextEquiv>
+      </pc:TextLine>
+    </pc:TextRegion>
+    <pc:ImageRegion id="logo"><pc:Coords points="0,100 100,100 100,200 0,200"/></pc:ImageRegion>
+    <pc:TableRegion id="table">
+      <pc:Coords points="0,200 1000,200 1000,400 0,400"/>
+      <pc:TextRegion id="cell_1">
+        <pc:Coords points="0,200 500,200 500,400 0,400"/>
+        <pc:TextLine id="cell_1_line">
+          <pc:Coords points="0,200 500,200 500,400 0,400"/>
+          <pc:TextEquiv><pc:Unicode>Total</pc:Unicode></pc:TextEquiv>
+        </pc:TextLine>
+      </pc:TextRegion>
+      <pc:TextRegion id="cell_2">
+        <pc:Coords points="500,200 1000,200 1000,400 500,400"/>
+        <pc:TextLine id="cell_2_line">
+          <pc:Coords points="500,200 1000,200 1000,400 500,400"/>
+          <pc:TextEquiv><pc:Unicode>42.00</pc:Unicode></pc:TextEquiv>
+        </pc:TextLine>
+      </pc:TextRegion>
+    </pc:TableRegion>
+  </pc:Page>
+</pc:PcGts>`)
+
+	doc, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata["ocr-system"] != "layout" || len(doc.Pages) != 1 {
+		t.Fatalf("Parse() = %+v, want a PAGE XML document of one page", doc)
+	}
+
+	page := doc.Pages[0]
+	if len(page.Paragraphs) != 1 || page.Paragraphs[0].ID != "heading" {
+		t.Errorf("paragraphs = %+v, want the heading region", page.Paragraphs)
+	}
+	if len(page.Areas) != 1 || page.Areas[0].ID != "table" {
+		t.Fatalf("areas = %+v, want just the table region", page.Areas)
+	}
+	var cells []string
+	for _, para := range page.Areas[0].Paragraphs {
+		for _, line := range para.Lines {
+			for _, word := range line.Words {
+				cells = append(cells, word.Text)
+			}
+		}
+	}
+	if !slices.Equal(cells, []string{"Total", "42.00"}) {
+		t.Errorf("table words = %q, want the text of both cells", cells)
+	}
+}
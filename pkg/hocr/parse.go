@@ -1,46 +1,61 @@
 package hocr
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
-	"golang.org/x/text/encoding/charmap"
 )
 
-// ParseHOCR converts raw hOCR data into a structured HOCR object.
-func ParseHOCR(data []byte) (HOCR, error) {
-	var result HOCR
-	result.Metadata = make(map[string]string)
+// Parse converts raw OCR data into a structured HOCR object, detecting
+// whether the data is hOCR HTML, ALTO XML or PAGE XML.
+func Parse(data []byte) (HOCR, error) {
+	switch xmlRootOf(data) {
+	case "alto":
+		return ParseALTO(data)
+	case "pcgts":
+		return ParsePAGE(data)
+	default:
+		return ParseHOCR(data)
+	}
+}
 
-	// Figure out the character encoding
-	content := string(data)
-	encoding := "utf-8"
-	if strings.Contains(content, "charset=") {
-		metaStart := strings.Index(content, "charset=") + len("charset=")
-		if metaStart > -1 && len(content) > metaStart+10 {
-			encSnippet := content[metaStart : metaStart+20]
-			enc := strings.ToLower(strings.FieldsFunc(encSnippet, func(r rune) bool {
-				return r == '"' || r == ';' || r == '\'' || r == '>'
-			})[0])
-			if enc != "" {
-				encoding = enc
+// xmlRootOf returns the lowercase local name of the root element of an
+// ALTO or PAGE XML document, so prefixed roots like <pc:PcGts> are
+// recognized too, returning "" for anything else
+func xmlRootOf(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch root := strings.ToLower(start.Name.Local); root {
+			case "alto", "pcgts":
+				return root
+			default:
+				return ""
 			}
 		}
 	}
+}
 
-	// Convert to UTF-8 if needed
-	var decoded []byte
-	var err error
-	if encoding != "utf-8" {
-		decoder := charmap.ISO8859_1.NewDecoder()
-		decoded, err = decoder.Bytes(data)
-		if err != nil {
-			return result, fmt.Errorf("failed to decode %s: %w", encoding, err)
-		}
-	} else {
-		decoded = data
+// ParseHOCR converts raw hOCR data into a structured HOCR object.
+func ParseHOCR(data []byte) (HOCR, error) {
+	var result HOCR
+	result.Metadata = make(map[string]string)
+
+	// Convert to UTF-8 from the encoding the document declares
+	decoded, err := decodeHOCR(data)
+	if err != nil {
+		return result, err
 	}
 
 	doc, err := html.Parse(strings.NewReader(string(decoded)))
@@ -223,16 +238,17 @@ func processPage(n *html.Node) (Page, error) {
 		}
 	}
 
-	// Process areas, paragraphs, lines directly under the page
+	// Process areas, paragraphs, lines and loose words directly under the page
 	var areaNodes []*html.Node
 	var paragraphNodes []*html.Node
 	var lineNodes []*html.Node
+	var wordNodes []*html.Node
 
 	var collectNodes func(*html.Node)
 	collectNodes = func(node *html.Node) {
 		if node.Type == html.ElementNode {
 			class := getAttrVal(node, "class")
-			if strings.Contains(class, "ocr_carea") {
+			if _, ok := areaTypeOf(class); ok {
 				areaNodes = append(areaNodes, node)
 				return
 			} else if strings.Contains(class, "ocr_par") {
@@ -241,6 +257,9 @@ func processPage(n *html.Node) (Page, error) {
 			} else if strings.Contains(class, "ocr_line") {
 				lineNodes = append(lineNodes, node)
 				return
+			} else if strings.Contains(class, "ocrx_word") {
+				wordNodes = append(wordNodes, node)
+				return
 			}
 		}
 		for c := node.FirstChild; c != nil; c = c.NextSibling {
@@ -276,6 +295,17 @@ func processPage(n *html.Node) (Page, error) {
 		}
 	}
 
+	// Group words without a line, as engines that only report word boxes
+	// write them, into lines of their own
+	var words []Word
+	for _, wordNode := range wordNodes {
+		word, err := processWord(wordNode)
+		if err == nil {
+			words = append(words, word)
+		}
+	}
+	page.Lines = append(page.Lines, groupWords(words)...)
+
 	return page, nil
 }
 
@@ -284,6 +314,7 @@ func processArea(n *html.Node) (Area, error) {
 	area := Area{
 		Metadata: make(map[string]string),
 	}
+	area.Type, _ = areaTypeOf(getAttrVal(n, "class"))
 
 	// Extract area attributes
 	for _, attr := range n.Attr {
@@ -297,10 +328,11 @@ func processArea(n *html.Node) (Area, error) {
 				area.BBox = *bbox
 			}
 
-			// Store other properties in metadata
+			// Extract reading order properties and store the others in metadata
 			props := ParseTitle(attr.Val)
+			area.Order, area.Flow = parseReadingOrder(props)
 			for k, v := range props {
-				if k != "bbox" {
+				if k != "bbox" && !isReadingOrderProperty(k) {
 					area.Metadata[k] = strings.Join(v, " ")
 				}
 			}
@@ -381,10 +413,11 @@ func processParagraph(n *html.Node) (Paragraph, error) {
 				paragraph.BBox = *bbox
 			}
 
-			// Store other properties in metadata
+			// Extract reading order properties and store the others in metadata
 			props := ParseTitle(attr.Val)
+			paragraph.Order, paragraph.Flow = parseReadingOrder(props)
 			for k, v := range props {
-				if k != "bbox" {
+				if k != "bbox" && !isReadingOrderProperty(k) {
 					paragraph.Metadata[k] = strings.Join(v, " ")
 				}
 			}
@@ -458,10 +491,11 @@ func processLine(n *html.Node) (Line, error) {
 			if baseline, ok := props["baseline"]; ok && len(baseline) > 0 {
 				line.Baseline = strings.Join(baseline, " ")
 			}
+			line.Order, _ = parseReadingOrder(props)
 
 			// Store other properties in metadata
 			for k, v := range props {
-				if k != "bbox" && k != "baseline" {
+				if k != "bbox" && k != "baseline" && !isReadingOrderProperty(k) {
 					line.Metadata[k] = strings.Join(v, " ")
 				}
 			}
@@ -516,6 +550,7 @@ func processWord(n *html.Node) (Word, error) {
 			props := ParseTitle(attr.Val)
 			if conf, ok := props["x_wconf"]; ok && len(conf) > 0 {
 				word.Confidence, _ = strconv.ParseFloat(conf[0], 64)
+				word.HasConfidence = true
 			}
 			if lang, ok := props["lang"]; ok && len(lang) > 0 {
 				word.Lang = lang[0]
@@ -535,9 +570,68 @@ func processWord(n *html.Node) (Word, error) {
 		word.Text = extractTextContent(n)
 	}
 
+	// Collect the character boxes, if any
+	var extractChars func(*html.Node)
+	extractChars = func(node *html.Node) {
+		if node.Type == html.ElementNode && strings.Contains(getAttrVal(node, "class"), "ocrx_cinfo") {
+			word.Chars = append(word.Chars, processChar(node))
+			return
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			extractChars(c)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		extractChars(c)
+	}
+
 	return word, nil
 }
 
+// processChar extracts a character and its box from an ocrx_cinfo element
+func processChar(n *html.Node) Char {
+	char := Char{Text: extractTextContent(n)}
+	props := ParseTitle(getAttrVal(n, "title"))
+	// An element of several characters, such as a ligature, lists a box per
+	// character, which are joined into one
+	if boxes, ok := props["x_bboxes"]; ok && len(boxes) >= 4 {
+		for i := 0; i+4 <= len(boxes); i += 4 {
+			var coords [4]float64
+			for j := range coords {
+				coords[j], _ = strconv.ParseFloat(boxes[i+j], 64)
+			}
+			box := NewBoundingBox(coords[0], coords[1], coords[2], coords[3])
+			if i > 0 {
+				box = NewBoundingBox(min(box.X1, char.BBox.X1), min(box.Y1, char.BBox.Y1),
+					max(box.X2, char.BBox.X2), max(box.Y2, char.BBox.Y2))
+			}
+			char.BBox = box
+		}
+	}
+	if conf, ok := props["x_conf"]; ok && len(conf) > 0 {
+		char.Confidence, _ = strconv.ParseFloat(conf[0], 64)
+	}
+	return char
+}
+
+// parseReadingOrder extracts the hOCR 'order' and 'cflow' properties from
+// parsed title properties
+func parseReadingOrder(props map[string][]string) (order int, flow string) {
+	if values, ok := props["order"]; ok && len(values) > 0 {
+		order, _ = strconv.Atoi(values[0])
+	}
+	if values, ok := props["cflow"]; ok && len(values) > 0 {
+		flow = values[0]
+	}
+	return order, flow
+}
+
+// isReadingOrderProperty reports whether a title property is one of the
+// reading order properties kept in dedicated fields
+func isReadingOrderProperty(key string) bool {
+	return key == "order" || key == "cflow"
+}
+
 // extractTextContent gets all text from a node and its children
 func extractTextContent(n *html.Node) string {
 	if n.Type == html.TextNode {
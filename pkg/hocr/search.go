@@ -0,0 +1,140 @@
+package hocr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SearchOptions controls how Search matches the query
+type SearchOptions struct {
+	Regexp     bool // Treat the query as a regular expression (RE2 syntax) instead of literal text
+	IgnoreCase bool // Match regardless of case, with Unicode case folding
+}
+
+// Match is an occurrence of a search query in a document
+type Match struct {
+	Page   int           // Page number (1-based)
+	Text   string        // The matched text
+	Words  []Word        // Words the match touches, in document order
+	BBoxes []BoundingBox // One box per line the match spans, enclosing the matched words of that line
+}
+
+// searchWord is a word of the searched page text with its byte range
+type searchWord struct {
+	word       Word
+	line       int // Index of the line the word belongs to
+	start, end int // Byte range of the word in the page text
+}
+
+// Search finds the occurrences of the query in the document and returns them
+// with their page and bounding boxes, e.g. to highlight or redact them in the
+// PDF. The words of each page are searched as one text in document order,
+// joined by single spaces, so queries can span several words and lines.
+// Matches cover whole words: a match inside a word returns that word's box.
+func Search(doc *HOCR, query string, options SearchOptions) ([]Match, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("HOCR document is nil")
+	}
+	if query == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+
+	pattern := query
+	if !options.Regexp {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if options.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern %q: %w", query, err)
+	}
+
+	var matches []Match
+	for pageIdx, page := range doc.Pages {
+		text, words := searchText(page)
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue // Empty matches of a regular expression have no words
+			}
+			matches = append(matches, newMatch(pageNumberOf(page, pageIdx), text[loc[0]:loc[1]], words, loc[0], loc[1]))
+		}
+	}
+	return matches, nil
+}
+
+// searchText joins the words of a page into the text that is searched and
+// records where each word is in it
+func searchText(page Page) (string, []searchWord) {
+	var builder strings.Builder
+	var words []searchWord
+	for lineIdx, line := range pageWordLines(page) {
+		for _, word := range line {
+			if builder.Len() > 0 {
+				builder.WriteString(" ")
+			}
+			start := builder.Len()
+			builder.WriteString(word.Text)
+			words = append(words, searchWord{word: word, line: lineIdx, start: start, end: builder.Len()})
+		}
+	}
+	return builder.String(), words
+}
+
+// newMatch collects the words overlapping the byte range of a match and
+// merges their boxes per line
+func newMatch(pageNum int, text string, words []searchWord, start, end int) Match {
+	match := Match{Page: pageNum, Text: text}
+	lastLine := -1
+	for _, word := range words {
+		if word.end <= start || word.start >= end {
+			continue
+		}
+		match.Words = append(match.Words, word.word)
+		if word.line != lastLine {
+			match.BBoxes = append(match.BBoxes, word.word.BBox)
+			lastLine = word.line
+			continue
+		}
+		box := &match.BBoxes[len(match.BBoxes)-1]
+		box.X1 = min(box.X1, word.word.BBox.X1)
+		box.Y1 = min(box.Y1, word.word.BBox.Y1)
+		box.X2 = max(box.X2, word.word.BBox.X2)
+		box.Y2 = max(box.Y2, word.word.BBox.Y2)
+	}
+	return match
+}
+
+// pageWordLines collects the words of a page in document order, grouped by
+// line. Words without a parent line form a group of their own.
+func pageWordLines(page Page) [][]Word {
+	var lines [][]Word
+	addLines := func(ls []Line) {
+		for _, line := range ls {
+			lines = append(lines, line.Words)
+		}
+	}
+	addWords := func(words []Word) {
+		if len(words) > 0 {
+			lines = append(lines, words)
+		}
+	}
+
+	for _, area := range page.Areas {
+		for _, para := range area.Paragraphs {
+			addLines(para.Lines)
+			addWords(para.Words)
+		}
+		addLines(area.Lines)
+		addWords(area.Words)
+	}
+	for _, para := range page.Paragraphs {
+		addLines(para.Lines)
+		addWords(para.Words)
+	}
+	addLines(page.Lines)
+
+	return lines
+}
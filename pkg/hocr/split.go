@@ -0,0 +1,43 @@
+package hocr
+
+import (
+	"maps"
+)
+
+// SplitPages splits a multi-page hOCR document into standalone single-page
+// documents, one per page in order, e.g. for processing pages in parallel.
+// Each document keeps the title, description and metadata of the original,
+// and pages keep their IDs and page numbers so results can be traced back
+// (Merge renumbers them when joining the pages again). The ocr-langs metadata
+// is narrowed to the page language when the page has one. The input document
+// is not modified.
+func SplitPages(doc *HOCR) []*HOCR {
+	if doc == nil {
+		return nil
+	}
+
+	result := make([]*HOCR, 0, len(doc.Pages))
+	for _, page := range doc.Pages {
+		part := &HOCR{
+			Title:       doc.Title,
+			Description: doc.Description,
+			Language:    doc.Language,
+			Metadata:    maps.Clone(doc.Metadata),
+			Pages:       []Page{clonePage(page, func(id string) string { return id })},
+		}
+		if part.Metadata == nil {
+			part.Metadata = make(map[string]string)
+		}
+		// The page count is recomputed when the document is generated
+		delete(part.Metadata, "ocr-number-of-pages")
+		if !isMissingLanguage(page.Lang) {
+			part.Metadata["ocr-langs"] = page.Lang
+			if isMissingLanguage(part.Language) {
+				part.Language = page.Lang
+			}
+		}
+		result = append(result, part)
+	}
+
+	return result
+}
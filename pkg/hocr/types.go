@@ -1,5 +1,10 @@
 package hocr
 
+import (
+	"slices"
+	"strings"
+)
+
 // HOCR represents the entire hOCR document structure
 type HOCR struct {
 	Title       string            // Document title
@@ -28,19 +33,55 @@ type Page struct {
 func (Page) Class() string { return "ocr_page" }
 
 // Area represents a content area (column or region)
-// Corresponds to hOCR element with class: 'ocr_carea'
+// Corresponds to hOCR element with class: 'ocr_carea', or the class of its
+// Type for photos, tables, captions and separators
 type Area struct {
 	ID         string            // Unique identifier
+	Type       string            // Kind of region: one of the Area constants, empty for a text area
 	Lang       string            // Language code
 	BBox       BoundingBox       // Area coordinates
+	Order      int               // Reading order position among its siblings (hOCR 'order' property, 0 if unset)
+	Flow       string            // Content flow the area belongs to (hOCR 'cflow' property)
 	Paragraphs []Paragraph       // Paragraphs in this area
 	Lines      []Line            // Text lines directly under area
 	Words      []Word            // Words directly under area (no line parent)
 	Metadata   map[string]string // Other area properties
 }
 
-// Class assign 'ocr_carea' to 'Area' struct
-func (Area) Class() string { return "ocr_carea" }
+// Class assign 'ocr_carea' to 'Area' struct, or the class of its Type
+func (a Area) Class() string {
+	if a.Type != "" {
+		return a.Type
+	}
+	return AreaText
+}
+
+// Area types, named after their hOCR classes. Photos and separators have no
+// text; tables and captions have their text as paragraphs and lines.
+const (
+	AreaText      = "ocr_carea"
+	AreaPhoto     = "ocr_photo"
+	AreaTable     = "ocr_table"
+	AreaCaption   = "ocr_caption"
+	AreaSeparator = "ocr_separator"
+)
+
+// areaTypes are the hOCR classes parsed into an Area
+var areaTypes = []string{AreaText, AreaPhoto, AreaTable, AreaCaption, AreaSeparator}
+
+// areaTypeOf returns the area type of an element by its class attribute, and
+// whether it is an area at all. Text areas have an empty type.
+func areaTypeOf(class string) (string, bool) {
+	for _, name := range strings.Fields(class) {
+		if slices.Contains(areaTypes, name) {
+			if name == AreaText {
+				return "", true
+			}
+			return name, true
+		}
+	}
+	return "", false
+}
 
 // Paragraph represents a paragraph within an area or block
 // Corresponds to hOCR element with class: 'ocr_par'
@@ -48,6 +89,8 @@ type Paragraph struct {
 	ID       string            // Unique identifier
 	Lang     string            // Language code
 	BBox     BoundingBox       // Paragraph coordinates
+	Order    int               // Reading order position among its siblings (hOCR 'order' property, 0 if unset)
+	Flow     string            // Content flow the paragraph belongs to (hOCR 'cflow' property)
 	Lines    []Line            // Text lines in this paragraph
 	Words    []Word            // Words directly under paragraph (no line parent)
 	Metadata map[string]string // Other paragraph properties
@@ -62,6 +105,7 @@ type Line struct {
 	ID       string            // Unique identifier
 	Lang     string            // Language code
 	BBox     BoundingBox       // Line coordinates
+	Order    int               // Reading order position among its siblings (hOCR 'order' property, 0 if unset)
 	Baseline string            // Baseline information
 	Words    []Word            // Words in this line
 	Metadata map[string]string // Other line properties
@@ -73,17 +117,34 @@ func (Line) Class() string { return "ocr_line" }
 // Word is a recognized word with bounding box
 // Corresponds to hOCR element with class: 'ocrx_word'
 type Word struct {
-	ID         string            // Unique identifier
-	Text       string            // The actual text content
-	BBox       BoundingBox       // Word coordinates
-	Confidence float64           // Recognition confidence (0-100)
-	Lang       string            // Language code
-	Metadata   map[string]string // Other word properties
+	ID            string            // Unique identifier
+	Text          string            // The actual text content
+	BBox          BoundingBox       // Word coordinates
+	Confidence    float64           // Recognition confidence (0-100)
+	HasConfidence bool              // Whether the OCR engine reported Confidence, which can be 0
+	Lang          string            // Language code
+	Chars         []Char            // Character boxes, if the OCR engine reported them; they spell out Text
+	Metadata      map[string]string // Other word properties
 }
 
 // Class assign 'ocrx_word' to 'Word' struct
 func (Word) Class() string { return "ocrx_word" }
 
+// ConfidenceKnown reports whether the word has a confidence value, as read
+// from the OCR output or set to a non-zero Confidence
+func (w Word) ConfidenceKnown() bool { return w.HasConfidence || w.Confidence != 0 }
+
+// Char is a recognized character (symbol) of a word with its bounding box
+// Corresponds to hOCR element with class: 'ocrx_cinfo'
+type Char struct {
+	Text       string      // The character, or the characters of a ligature
+	BBox       BoundingBox // Character coordinates (hOCR 'x_bboxes' property)
+	Confidence float64     // Recognition confidence (0-100, hOCR 'x_conf' property)
+}
+
+// Class assign 'ocrx_cinfo' to 'Char' struct
+func (Char) Class() string { return "ocrx_cinfo" }
+
 // BoundingBox represents a rectangle in the document
 // Used to store hOCR 'bbox' property values
 type BoundingBox struct {
@@ -0,0 +1,164 @@
+package hocr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProblemKind categorizes a problem found by Validate
+type ProblemKind string
+
+const (
+	// ProblemNoPages means the document has no pages at all
+	ProblemNoPages ProblemKind = "no-pages"
+	// ProblemEmptyPage means a page contains no words with text
+	ProblemEmptyPage ProblemKind = "empty-page"
+	// ProblemMissingBBox means an element has no bounding box
+	ProblemMissingBBox ProblemKind = "missing-bbox"
+	// ProblemBBoxOutOfRange means a bounding box is inverted, negative or extends beyond its page
+	ProblemBBoxOutOfRange ProblemKind = "bbox-out-of-range"
+	// ProblemDuplicateID means more than one element uses the same ID
+	ProblemDuplicateID ProblemKind = "duplicate-id"
+)
+
+// Problem describes something wrong with an hOCR document
+type Problem struct {
+	Kind      ProblemKind // Category of the problem
+	Page      int         // 1-based index of the page (0 for the document itself)
+	ElementID string      // ID of the offending element, if it has one
+	Message   string      // Human readable description
+}
+
+// String formats the problem with its location
+func (p Problem) String() string {
+	location := "document"
+	if p.Page > 0 {
+		location = fmt.Sprintf("page %d", p.Page)
+	}
+	if p.ElementID != "" {
+		location += fmt.Sprintf(" (%s)", p.ElementID)
+	}
+	return fmt.Sprintf("%s: %s", location, p.Message)
+}
+
+// Validate checks an hOCR document for problems that would make it unusable
+// for placing text, such as missing or out-of-range bounding boxes, duplicate
+// element IDs and pages without words. It returns nil if no problems are found.
+func Validate(doc *HOCR) []Problem {
+	if doc == nil || len(doc.Pages) == 0 {
+		return []Problem{{Kind: ProblemNoPages, Message: "document has no pages"}}
+	}
+
+	v := &validator{ids: make(map[string]int)}
+	for i, page := range doc.Pages {
+		v.page = i + 1
+		v.pageBox = page.BBox
+		v.words = 0
+
+		v.checkID(page.ID)
+		if page.BBox == (BoundingBox{}) {
+			v.add(ProblemMissingBBox, page.ID, "page has no bounding box")
+		} else if page.BBox.X1 < 0 || page.BBox.Y1 < 0 || page.BBox.X2 <= page.BBox.X1 || page.BBox.Y2 <= page.BBox.Y1 {
+			v.add(ProblemBBoxOutOfRange, page.ID, fmt.Sprintf("page bounding box %s is invalid", formatBBox(page.BBox)))
+		}
+
+		for _, area := range page.Areas {
+			v.checkElement("area", area.ID, area.BBox)
+			for _, paragraph := range area.Paragraphs {
+				v.checkParagraph(paragraph)
+			}
+			for _, line := range area.Lines {
+				v.checkLine(line)
+			}
+			v.checkWords(area.Words)
+		}
+		for _, paragraph := range page.Paragraphs {
+			v.checkParagraph(paragraph)
+		}
+		for _, line := range page.Lines {
+			v.checkLine(line)
+		}
+
+		if v.words == 0 {
+			v.add(ProblemEmptyPage, page.ID, "page contains no words")
+		}
+	}
+
+	return v.problems
+}
+
+// validator collects problems while walking a document
+type validator struct {
+	problems []Problem
+	ids      map[string]int // Page on which each element ID was first seen
+	page     int            // Page being checked (1-based)
+	pageBox  BoundingBox    // Bounding box of the page being checked
+	words    int            // Words with text on the page being checked
+}
+
+// add records a problem on the current page
+func (v *validator) add(kind ProblemKind, id, message string) {
+	v.problems = append(v.problems, Problem{Kind: kind, Page: v.page, ElementID: id, Message: message})
+}
+
+// checkID reports an ID that was already used by another element
+func (v *validator) checkID(id string) {
+	if id == "" {
+		return
+	}
+	if page, ok := v.ids[id]; ok {
+		v.add(ProblemDuplicateID, id, fmt.Sprintf("ID %q is already used on page %d", id, page))
+		return
+	}
+	v.ids[id] = v.page
+}
+
+// checkElement validates the ID and bounding box of an element below the page
+func (v *validator) checkElement(kind, id string, box BoundingBox) {
+	v.checkID(id)
+
+	if box == (BoundingBox{}) {
+		v.add(ProblemMissingBBox, id, fmt.Sprintf("%s has no bounding box", kind))
+		return
+	}
+	if box.X1 < 0 || box.Y1 < 0 || box.X2 < box.X1 || box.Y2 < box.Y1 {
+		v.add(ProblemBBoxOutOfRange, id, fmt.Sprintf("%s bounding box %s is inverted or negative", kind, formatBBox(box)))
+		return
+	}
+	page := v.pageBox
+	if page.X2 > page.X1 && page.Y2 > page.Y1 &&
+		(box.X1 < page.X1 || box.Y1 < page.Y1 || box.X2 > page.X2 || box.Y2 > page.Y2) {
+		v.add(ProblemBBoxOutOfRange, id, fmt.Sprintf("%s bounding box %s extends beyond the page %s",
+			kind, formatBBox(box), formatBBox(page)))
+	}
+}
+
+// checkParagraph validates a paragraph and its contents
+func (v *validator) checkParagraph(paragraph Paragraph) {
+	v.checkElement("paragraph", paragraph.ID, paragraph.BBox)
+	for _, line := range paragraph.Lines {
+		v.checkLine(line)
+	}
+	v.checkWords(paragraph.Words)
+}
+
+// checkLine validates a line and its words
+func (v *validator) checkLine(line Line) {
+	v.checkElement("line", line.ID, line.BBox)
+	v.checkWords(line.Words)
+}
+
+// checkWords validates words and counts those with text
+func (v *validator) checkWords(words []Word) {
+	for _, word := range words {
+		v.checkElement("word", word.ID, word.BBox)
+		if strings.TrimSpace(word.Text) != "" {
+			v.words++
+		}
+	}
+}
+
+// formatBBox formats a bounding box the way it appears in hOCR
+func formatBBox(box BoundingBox) string {
+	return fmt.Sprintf("bbox %g %g %g %g", box.X1, box.Y1, box.X2, box.Y2)
+}
@@ -0,0 +1,60 @@
+// Package ocrengine defines a common interface for OCR engines that turn
+// document images into hOCR structures.
+//
+// An Engine hides the details of how text is recognized, so the rest of the
+// pipeline (for example pdfocr) works the same whether the text comes from
+// Google Document AI or from a local Tesseract installation.
+//
+// Implementations:
+//
+// - Tesseract: Runs a local tesseract binary, no cloud dependency
+// - gdocai.Engine: Google Document AI (in the gdocai package)
+package ocrengine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// Input is a single document or page image handed to an OCR engine
+type Input struct {
+	Data      []byte   // Raw document or image bytes
+	MimeType  string   // MIME type of Data, e.g. image/png or application/pdf
+	Languages []string // Language hints, engine specific (e.g. "eng" for Tesseract)
+}
+
+// Engine recognizes text in a document and returns it as an HOCR structure
+type Engine interface {
+	// Name returns a short identifier for the engine, e.g. "tesseract"
+	Name() string
+
+	// Process runs OCR on the input and returns the recognized pages
+	Process(ctx context.Context, input Input) (*hocr.HOCR, error)
+}
+
+// ProcessPages runs the engine on each input in turn and combines the
+// results into a single HOCR document, renumbering pages in input order.
+// Element IDs that embed the page number, like word_1_4, are renumbered
+// along with their page, so IDs stay unique across inputs.
+func ProcessPages(ctx context.Context, engine Engine, inputs []Input) (*hocr.HOCR, error) {
+	if engine == nil {
+		return nil, fmt.Errorf("OCR engine is nil")
+	}
+
+	// Merge keeps the first title and metadata, so the engine name wins
+	docs := []*hocr.HOCR{{
+		Title:    "OCR Output",
+		Metadata: map[string]string{"ocr-system": engine.Name()},
+	}}
+	for i, input := range inputs {
+		doc, err := engine.Process(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed on input %d: %w", engine.Name(), i+1, err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return hocr.Merge(docs...), nil
+}
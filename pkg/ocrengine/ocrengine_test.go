@@ -0,0 +1,51 @@
+package ocrengine
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// pageEngine returns the same one-page document for every input, the way an
+// engine numbers each input it sees on its own from page 1
+type pageEngine struct{}
+
+func (pageEngine) Name() string { return "fake" }
+
+func (pageEngine) Process(context.Context, Input) (*hocr.HOCR, error) {
+	return &hocr.HOCR{Pages: []hocr.Page{{
+		ID:         "page_1",
+		PageNumber: 1,
+		Lines: []hocr.Line{{
+			ID:    "line_1_1",
+			Words: []hocr.Word{{ID: "word_1_1", Text: "Hello"}},
+		}},
+	}}}, nil
+}
+
+func TestProcessPagesRenumbersIDs(t *testing.T) {
+	doc, err := ProcessPages(context.Background(), pageEngine{}, make([]Input, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata["ocr-system"] != "fake" {
+		t.Errorf("ocr-system = %q, want the engine name", doc.Metadata["ocr-system"])
+	}
+
+	var ids []string
+	for _, page := range doc.Pages {
+		ids = append(ids, page.ID)
+		for _, line := range page.Lines {
+			ids = append(ids, line.ID)
+			for _, word := range line.Words {
+				ids = append(ids, word.ID)
+			}
+		}
+	}
+	want := []string{"page_1", "line_1_1", "word_1_1", "page_2", "line_2_1", "word_2_1"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("IDs = %q, want %q", ids, want)
+	}
+}
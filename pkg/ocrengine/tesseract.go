@@ -0,0 +1,82 @@
+package ocrengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// Tesseract runs a local tesseract binary and parses its hOCR output.
+// Tesseract reads images (PNG, JPEG, TIFF, ...) but not PDFs.
+type Tesseract struct {
+	Path      string   // Path to the tesseract binary (default "tesseract")
+	Languages []string // Default languages, e.g. ["eng", "deu"] (default "eng")
+	PSM       int      // Page segmentation mode, 0 uses the tesseract default
+	ExtraArgs []string // Additional command line arguments passed before the output format
+}
+
+// NewTesseract returns a Tesseract engine using the tesseract binary found in PATH
+func NewTesseract(languages ...string) *Tesseract {
+	return &Tesseract{Path: "tesseract", Languages: languages}
+}
+
+// Name identifies the engine
+func (t *Tesseract) Name() string {
+	return "tesseract"
+}
+
+// Process runs tesseract on a single image and returns its hOCR output
+func (t *Tesseract) Process(ctx context.Context, input Input) (*hocr.HOCR, error) {
+	if len(input.Data) == 0 {
+		return nil, fmt.Errorf("input data is empty")
+	}
+	if input.MimeType == "application/pdf" {
+		return nil, fmt.Errorf("tesseract cannot read PDF input, provide page images instead")
+	}
+
+	path := t.Path
+	if path == "" {
+		path = "tesseract"
+	}
+
+	languages := input.Languages
+	if len(languages) == 0 {
+		languages = t.Languages
+	}
+	if len(languages) == 0 {
+		languages = []string{"eng"}
+	}
+
+	// Read the image from stdin and write hOCR to stdout
+	args := []string{"stdin", "stdout", "-l", strings.Join(languages, "+")}
+	if t.PSM > 0 {
+		args = append(args, "--psm", strconv.Itoa(t.PSM))
+	}
+	args = append(args, t.ExtraArgs...)
+	args = append(args, "hocr")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(input.Data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("tesseract failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("tesseract failed: %w", err)
+	}
+
+	doc, err := hocr.ParseHOCR(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tesseract hOCR output: %w", err)
+	}
+
+	return &doc, nil
+}
@@ -0,0 +1,317 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// pdfRefPattern matches an indirect reference
+	pdfRefPattern = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+
+	// pdfWidgetPattern matches the subtype of form field widget annotations
+	pdfWidgetPattern = regexp.MustCompile(`/Subtype\s*/Widget\b`)
+
+	// pdfPageTreePattern matches the types of the catalog, page tree nodes and pages
+	pdfPageTreePattern = regexp.MustCompile(`/Type\s*/(Pages?|Catalog)\b`)
+
+	// pdfGeometryPattern matches the annotation entries holding coordinate pairs
+	pdfGeometryPattern = regexp.MustCompile(`/(Rect|QuadPoints|Vertices|L|CL)\s*\[([^\]]*)\]`)
+
+	// pdfInkListPattern matches the paths of an ink annotation
+	pdfInkListPattern = regexp.MustCompile(`/InkList\s*\[((?:\s*\[[^\]]*\])*)\s*\]`)
+
+	// pdfInkPathPattern matches a single path of an ink annotation's InkList
+	pdfInkPathPattern = regexp.MustCompile(`\[([^\]]*)\]`)
+
+	// pdfAnnotsArrayPattern matches the start of a page's direct Annots array
+	pdfAnnotsArrayPattern = regexp.MustCompile(`/Annots\s*\[`)
+
+	// pdfDestinationPattern matches an explicit destination pointing to a page object
+	pdfDestinationPattern = regexp.MustCompile(`\[\s*(\d+)\s+\d+\s+R\s*/(XYZ|FitH|FitBH|FitV|FitBV|FitR)\b([^\]]*)\]`)
+)
+
+// copyAnnotations copies the interactive form (AcroForm) of the source and
+// the widgets of its fields to the output, so the form stays fillable, and
+// with all set the other annotations of the source pages as well, such as
+// links and highlights. Their positions are converted to the pages they
+// became in the output, and references to source pages point to the output
+// pages instead, or to null if the page was left out. The output is
+// returned unchanged if there is nothing to copy.
+func copyAnnotations(inputPDFData, outputPDFData []byte, pages map[int]placedPage, all bool, result *ApplyResult) ([]byte, error) {
+	source := parsePDFObjects(inputPDFData)
+	catalog := source.dict(source.ref(source.trailer, "Root"))
+	form := source.wholeDict(catalog, "AcroForm")
+	if form == nil && !all {
+		return outputPDFData, nil
+	}
+	if bytes.Contains(source.trailer, []byte("/Encrypt")) {
+		result.addWarning("the annotations and form fields of an encrypted PDF can't be copied")
+		return outputPDFData, nil
+	}
+	output := parsePDFObjects(outputPDFData)
+
+	copier := &annotationCopier{
+		source:   source,
+		output:   output,
+		copied:   make(map[int]int),
+		pageRefs: make(map[int]int),
+		pageOf:   make(map[int]placedPage),
+		pageTree: make(map[int]bool),
+	}
+	for _, num := range output.sortedObjects() {
+		copier.next = max(copier.next, num+1)
+	}
+
+	sourcePages, outputPages := source.pages(), output.pages()
+	for i, num := range sourcePages {
+		if page, ok := pages[i+1]; ok && page.page <= len(outputPages) {
+			copier.pageRefs[num] = outputPages[page.page-1]
+			copier.pageOf[num] = page
+		}
+	}
+
+	for i, num := range sourcePages {
+		page, ok := copier.pageOf[num]
+		if !ok {
+			continue
+		}
+		var annots []string
+		for _, annot := range source.annotations(num) {
+			switch {
+			case !all && !pdfWidgetPattern.Match(annot.dict):
+				continue
+			case annot.num > 0:
+				annots = append(annots, fmt.Sprintf("%d 0 R", copier.copy(annot.num, &page)))
+			default:
+				annots = append(annots, fmt.Sprintf("%d 0 R", copier.copyInline(annot.dict, &page)))
+			}
+		}
+		if len(annots) == 0 {
+			continue
+		}
+
+		target := outputPages[page.page-1]
+		dict := output.dict(target)
+		if loc := pdfAnnotsArrayPattern.FindIndex(dict); loc != nil {
+			dict = append(append(append([]byte{}, dict[:loc[1]]...), strings.Join(annots, " ")+" "...), dict[loc[1]:]...)
+		} else if end := bytes.LastIndex(dict, []byte(">>")); end >= 0 {
+			dict = append(append(append([]byte{}, dict[:end]...), "/Annots ["+strings.Join(annots, " ")+"]"...), dict[end:]...)
+		} else {
+			return nil, fmt.Errorf("page %d of the output has no dictionary", i+1)
+		}
+		output.setDict(target, dict)
+	}
+
+	// The field tree reaches the widgets copied above, and any it has on
+	// pages left out of the output lose their page
+	if form != nil {
+		root := output.ref(output.trailer, "Root")
+		dict := output.dict(root)
+		end := bytes.LastIndex(dict, []byte(">>"))
+		if end < 0 {
+			return nil, fmt.Errorf("the output has no document catalog")
+		}
+		formNum := copier.copyInline(form, nil)
+		dict = append(append(append([]byte{}, dict[:end]...), fmt.Sprintf("/AcroForm %d 0 R\n", formNum)...), dict[end:]...)
+		output.setDict(root, dict)
+	}
+	return output.bytes()
+}
+
+// pdfAnnotation is an entry of the /Annots array of a page
+type pdfAnnotation struct {
+	num  int    // Object number, 0 for a dictionary given inline
+	dict []byte // Annotation dictionary
+}
+
+// annotations returns the annotations of a page. The /Annots array may be
+// stored in the page or in an object of its own, and holds references to
+// annotation dictionaries or, as fpdf writes them, the dictionaries themselves.
+func (f *pdfFile) annotations(page int) []pdfAnnotation {
+	array := f.dict(page)
+	if num := f.ref(array, "Annots"); num > 0 {
+		array = f.dict(num)
+	} else if loc := pdfAnnotsArrayPattern.FindIndex(array); loc != nil {
+		array = array[loc[1]-1:]
+	} else {
+		return nil
+	}
+	start := bytes.IndexByte(array, '[')
+	if start < 0 {
+		return nil
+	}
+
+	var annotations []pdfAnnotation
+	lexer := &pdfLexer{data: array, pos: start + 1}
+	for {
+		lexer.skipSpace()
+		if lexer.pos >= len(array) || array[lexer.pos] == ']' {
+			return annotations
+		}
+		// The lexer moves its start along while reading nested dictionaries
+		start := lexer.pos
+		token, ok := lexer.next()
+		if !ok {
+			return annotations
+		}
+		switch token.kind {
+		case pdfDict:
+			annotations = append(annotations, pdfAnnotation{dict: array[start:lexer.pos]})
+		case pdfNumber:
+			// A reference: object number, generation number and R
+			num, _ := strconv.Atoi(token.text)
+			lexer.next()
+			lexer.next()
+			annotations = append(annotations, pdfAnnotation{num: num, dict: f.dict(num)})
+		}
+	}
+}
+
+// annotationCopier copies objects from the source PDF into the output,
+// together with the objects they reference
+type annotationCopier struct {
+	source   *pdfFile
+	output   *pdfFile
+	next     int                // Next free object number in the output
+	copied   map[int]int        // Source object number to output object number
+	pageRefs map[int]int        // Source page object to output page object
+	pageOf   map[int]placedPage // Source page object to its placement in the output
+	pageTree map[int]bool       // Whether source objects are part of the page tree
+}
+
+// copy copies an object of the source that belongs to the given page, or
+// to no page in particular if it's nil, and returns its number in the output
+func (c *annotationCopier) copy(num int, page *placedPage) int {
+	if copied, ok := c.copied[num]; ok {
+		return copied
+	}
+	copied := c.next
+	c.next++
+	c.copied[num] = copied
+
+	dict := c.source.dict(num)
+	c.output.objects[copied] = append(c.rewrite(dict, page), c.source.objects[num][len(dict):]...)
+	return copied
+}
+
+// copyInline stores a dictionary given inline, such as an annotation in an
+// /Annots array, as an object of the output and returns its number
+func (c *annotationCopier) copyInline(dict []byte, page *placedPage) int {
+	copied := c.next
+	c.next++
+	c.output.objects[copied] = append([]byte("\n"), c.rewrite(dict, page)...)
+	return copied
+}
+
+// rewrite converts the geometry of a dictionary to the output page and
+// points its references to the output, copying the objects they refer to
+func (c *annotationCopier) rewrite(dict []byte, page *placedPage) []byte {
+	dict = c.placeGeometry(dict, page)
+	return pdfRefPattern.ReplaceAllFunc(dict, func(ref []byte) []byte {
+		target, _ := strconv.Atoi(string(pdfRefPattern.FindSubmatch(ref)[1]))
+		if outputPage, ok := c.pageRefs[target]; ok {
+			return fmt.Appendf(nil, "%d 0 R", outputPage)
+		}
+		if _, ok := c.source.objects[target]; !ok || c.isPageTree(target) {
+			return []byte("null")
+		}
+		return fmt.Appendf(nil, "%d 0 R", c.copy(target, page))
+	})
+}
+
+// isPageTree reports whether an object is a page, a page tree node or the
+// catalog, which must never be copied along with an annotation
+func (c *annotationCopier) isPageTree(num int) bool {
+	if _, ok := c.pageTree[num]; !ok {
+		c.pageTree[num] = pdfPageTreePattern.Match(c.source.dict(num))
+	}
+	return c.pageTree[num]
+}
+
+// placeGeometry converts the coordinates of an annotation on the given page
+// and of the destinations it points to from the source pages to the output
+// pages. Without a page, only the destinations are converted.
+func (c *annotationCopier) placeGeometry(dict []byte, page *placedPage) []byte {
+	if page != nil {
+		dict = pdfGeometryPattern.ReplaceAllFunc(dict, func(entry []byte) []byte {
+			match := pdfGeometryPattern.FindSubmatch(entry)
+			return fmt.Appendf(nil, "/%s [%s]", match[1], placePoints(match[2], *page))
+		})
+		dict = pdfInkListPattern.ReplaceAllFunc(dict, func(entry []byte) []byte {
+			paths := pdfInkPathPattern.ReplaceAllFunc(pdfInkListPattern.FindSubmatch(entry)[1], func(path []byte) []byte {
+				return []byte("[" + placePoints(path[1:len(path)-1], *page) + "]")
+			})
+			return append([]byte("/InkList ["), append(paths, ']')...)
+		})
+	}
+	return pdfDestinationPattern.ReplaceAllFunc(dict, func(dest []byte) []byte {
+		match := pdfDestinationPattern.FindSubmatch(dest)
+		num, _ := strconv.Atoi(string(match[1]))
+		target, ok := c.pageOf[num]
+		if !ok {
+			return dest
+		}
+		return fmt.Appendf(nil, "[%s 0 R /%s %s]", match[1], match[2], placeDestination(string(match[2]), match[3], target))
+	})
+}
+
+// placePoints converts a list of x, y coordinate pairs to the output page
+func placePoints(list []byte, page placedPage) string {
+	fields := strings.Fields(string(list))
+	for i := 0; i+1 < len(fields); i += 2 {
+		x, errX := strconv.ParseFloat(fields[i], 64)
+		y, errY := strconv.ParseFloat(fields[i+1], 64)
+		if errX != nil || errY != nil {
+			continue
+		}
+		x, y = page.point(x, y)
+		fields[i], fields[i+1] = formatPDFNumber(x), formatPDFNumber(y)
+	}
+	return strings.Join(fields, " ")
+}
+
+// placeDestination converts the parameters of a destination to the output
+// page. Parameters set to null keep the current view and are left as is.
+func placeDestination(kind string, parameters []byte, page placedPage) string {
+	fields := strings.Fields(string(parameters))
+	convert := func(i int, vertical bool) {
+		if i >= len(fields) {
+			return
+		}
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return
+		}
+		x, y := page.point(value, value)
+		if vertical {
+			fields[i] = formatPDFNumber(y)
+		} else {
+			fields[i] = formatPDFNumber(x)
+		}
+	}
+	switch kind {
+	case "XYZ":
+		convert(0, false)
+		convert(1, true)
+	case "FitH", "FitBH":
+		convert(0, true)
+	case "FitV", "FitBV":
+		convert(0, false)
+	case "FitR":
+		convert(0, false)
+		convert(1, true)
+		convert(2, false)
+		convert(3, true)
+	}
+	return strings.Join(fields, " ")
+}
+
+// formatPDFNumber writes a coordinate with at most two decimals
+func formatPDFNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
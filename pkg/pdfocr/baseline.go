@@ -0,0 +1,57 @@
+package pdfocr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// parseBaseline parses an hOCR "slope offset" baseline, which gives the
+// baseline as y = slope·x + offset relative to the bottom-left corner of the
+// element's bounding box
+func parseBaseline(baseline string) (slope, offset float64, ok bool) {
+	fields := strings.Fields(baseline)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	slope, err1 := strconv.ParseFloat(fields[0], 64)
+	offset, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || math.IsNaN(slope+offset) || math.IsInf(slope+offset, 0) {
+		return 0, 0, false
+	}
+	return slope, offset, true
+}
+
+// wordBaseline re-expresses the baseline of a line relative to the bounding
+// box of one of its words, so the word can be positioned on its own
+func wordBaseline(line hocr.Line, word hocr.Word) (string, bool) {
+	slope, offset, ok := parseBaseline(line.Baseline)
+	if !ok {
+		return "", false
+	}
+	y := line.BBox.Y2 + offset + slope*(word.BBox.X1-line.BBox.X1)
+	return fmt.Sprintf("%g %g", slope, y-word.BBox.Y2), true
+}
+
+// baselineAt returns the y coordinate, in hOCR space, of a word's baseline
+// at the horizontal center of the word. It returns false if the word has no
+// baseline or the baseline doesn't run through the word, which happens with
+// inaccurate OCR output.
+func baselineAt(word hocr.Word) (float64, bool) {
+	slope, offset, ok := parseBaseline(word.Metadata["baseline"])
+	if !ok {
+		return 0, false
+	}
+	box := word.BBox
+	y := box.Y2 + offset + slope*(box.X2-box.X1)/2
+
+	// Allow for descenders below the baseline, but not for a baseline outside the word
+	height := box.Y2 - box.Y1
+	if height <= 0 || y < box.Y1+height/4 || y > box.Y2+height/10 {
+		return 0, false
+	}
+	return y, true
+}
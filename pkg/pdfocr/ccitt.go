@@ -0,0 +1,552 @@
+package pdfocr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+)
+
+// pdfDoPattern matches the name of an XObject painted by a content stream
+var pdfDoPattern = regexp.MustCompile(`/([^\s/\[\]()<>{}%]+)\s+Do\b`)
+
+// Codes of the modified Huffman run lengths (ITU-T T.4), as bit strings.
+// Terminating codes give runs of 0 to 63 pixels, make-up codes multiples of
+// 64 up to 1728 and the extended make-up codes, shared by both colors, 1792
+// to 2560.
+var (
+	ccittWhiteTerminating = [64]string{
+		"00110101", "000111", "0111", "1000", "1011", "1100", "1110", "1111",
+		"10011", "10100", "00111", "01000", "001000", "000011", "110100", "110101",
+		"101010", "101011", "0100111", "0001100", "0001000", "0010111", "0000011", "0000100",
+		"0101000", "0101011", "0010011", "0100100", "0011000", "00000010", "00000011", "00011010",
+		"00011011", "00010010", "00010011", "00010100", "00010101", "00010110", "00010111", "00101000",
+		"00101001", "00101010", "00101011", "00101100", "00101101", "00000100", "00000101", "00001010",
+		"00001011", "01010010", "01010011", "01010100", "01010101", "00100100", "00100101", "01011000",
+		"01011001", "01011010", "01011011", "01001010", "01001011", "00110010", "00110011", "00110100",
+	}
+	ccittWhiteMakeUp = [27]string{
+		"11011", "10010", "010111", "0110111", "00110110", "00110111", "01100100", "01100101",
+		"01101000", "01100111", "011001100", "011001101", "011010010", "011010011", "011010100", "011010101",
+		"011010110", "011010111", "011011000", "011011001", "011011010", "011011011", "010011000", "010011001",
+		"010011010", "011000", "010011011",
+	}
+	ccittBlackTerminating = [64]string{
+		"0000110111", "010", "11", "10", "011", "0011", "0010", "00011",
+		"000101", "000100", "0000100", "0000101", "0000111", "00000100", "00000111", "000011000",
+		"0000010111", "0000011000", "0000001000", "00001100111", "00001101000", "00001101100", "00000110111", "00000101000",
+		"00000010111", "00000011000", "000011001010", "000011001011", "000011001100", "000011001101", "000001101000", "000001101001",
+		"000001101010", "000001101011", "000011010010", "000011010011", "000011010100", "000011010101", "000011010110", "000011010111",
+		"000001101100", "000001101101", "000011011010", "000011011011", "000001010100", "000001010101", "000001010110", "000001010111",
+		"000001100100", "000001100101", "000001010010", "000001010011", "000000100100", "000000110111", "000000111000", "000000100111",
+		"000000101000", "000001011000", "000001011001", "000000101011", "000000101100", "000001011010", "000001100110", "000001100111",
+	}
+	ccittBlackMakeUp = [27]string{
+		"0000001111", "000011001000", "000011001001", "000001011011", "000000110011", "000000110100", "000000110101", "0000001101100",
+		"0000001101101", "0000001001010", "0000001001011", "0000001001100", "0000001001101", "0000001110010", "0000001110011", "0000001110100",
+		"0000001110101", "0000001110110", "0000001110111", "0000001010010", "0000001010011", "0000001010100", "0000001010101", "0000001011010",
+		"0000001011011", "0000001100100", "0000001100101",
+	}
+	ccittExtendedMakeUp = [13]string{
+		"00000001000", "00000001100", "00000001101", "000000010010", "000000010011", "000000010100", "000000010101",
+		"000000010110", "000000010111", "000000011100", "000000011101", "000000011110", "000000011111",
+	}
+)
+
+// Codes of the two-dimensional coding modes (ITU-T T.6)
+const (
+	ccittPass       = "0001"
+	ccittHorizontal = "001"
+	ccittEOL        = "000000000001"
+)
+
+// ccittVertical holds the codes of the vertical modes by a1 - b1, from -3 to 3
+var ccittVertical = [7]string{"0000010", "000010", "010", "1", "011", "000011", "0000011"}
+
+// ccittImage is a black-and-white page image encoded with CCITT Group 4
+type ccittImage struct {
+	width, height int
+	data          []byte
+}
+
+// bitonalPage encodes a page image with CCITT Group 4 if it has only black
+// and white pixels, downsampled to config.ImageDPI and thresholded back to
+// black and white first. It reports false for other images, and for images
+// the encoding wouldn't make smaller.
+func bitonalPage(data []byte, config OCRConfig) (ccittImage, bool, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ccittImage{}, false, fmt.Errorf("failed to decode image: %w", err)
+	}
+	rows, ok := bitonalImage(img)
+	if !ok {
+		return ccittImage{}, false, nil
+	}
+
+	bounds := img.Bounds()
+	width, height, resized := downsampledSize(data, bounds, config)
+	if resized {
+		rows = thresholdImage(downsample(grayImage(rows, bounds.Dx()), width, height).(*image.Gray))
+	}
+	encoded := ccittImage{width: width, height: height, data: encodeCCITTG4(rows, width)}
+	return encoded, resized || len(encoded.data) < len(data), nil
+}
+
+// bitonalImage returns the rows of an image that has only black and white
+// pixels, with true for black, or false if it has any other color
+func bitonalImage(img image.Image) ([][]bool, bool) {
+	bounds := img.Bounds()
+	rows := make([][]bool, bounds.Dy())
+	for y := range rows {
+		rows[y] = make([]bool, bounds.Dx())
+	}
+
+	switch img := img.(type) {
+	case *image.Gray:
+		for y, row := range rows {
+			pixels := img.Pix[y*img.Stride : y*img.Stride+len(row)]
+			for x, value := range pixels {
+				if value != 0 && value != 0xFF {
+					return nil, false
+				}
+				row[x] = value == 0
+			}
+		}
+	case *image.Paletted:
+		black := make([]bool, len(img.Palette))
+		for i, c := range img.Palette {
+			var ok bool
+			if black[i], ok = bitonalColor(c); !ok {
+				return nil, false
+			}
+		}
+		for y, row := range rows {
+			pixels := img.Pix[y*img.Stride : y*img.Stride+len(row)]
+			for x, index := range pixels {
+				row[x] = black[index]
+			}
+		}
+	default:
+		for y, row := range rows {
+			for x := range row {
+				black, ok := bitonalColor(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+				if !ok {
+					return nil, false
+				}
+				row[x] = black
+			}
+		}
+	}
+	return rows, true
+}
+
+// bitonalColor reports whether an opaque color is black or white, and
+// whether it is either
+func bitonalColor(c color.Color) (black, ok bool) {
+	r, g, b, a := c.RGBA()
+	switch {
+	case a != 0xFFFF:
+		return false, false
+	case r == 0 && g == 0 && b == 0:
+		return true, true
+	case r == 0xFFFF && g == 0xFFFF && b == 0xFFFF:
+		return false, true
+	}
+	return false, false
+}
+
+// thresholdImage turns a grayscale image into black and white rows
+func thresholdImage(gray *image.Gray) [][]bool {
+	bounds := gray.Bounds()
+	rows := make([][]bool, bounds.Dy())
+	for y := range rows {
+		rows[y] = make([]bool, bounds.Dx())
+		for x, value := range gray.Pix[y*gray.Stride : y*gray.Stride+bounds.Dx()] {
+			rows[y][x] = value < 0x80
+		}
+	}
+	return rows
+}
+
+// grayImage converts black and white rows to a grayscale image
+func grayImage(rows [][]bool, width int) *image.Gray {
+	gray := image.NewGray(image.Rect(0, 0, width, len(rows)))
+	for y, row := range rows {
+		for x, black := range row {
+			if !black {
+				gray.Pix[y*gray.Stride+x] = 0xFF
+			}
+		}
+	}
+	return gray
+}
+
+// bitWriter collects codes written as bit strings into bytes
+type bitWriter struct {
+	data  []byte
+	count int // Number of bits written
+}
+
+// write appends the bits of a code
+func (w *bitWriter) write(code string) {
+	for _, bit := range []byte(code) {
+		if w.count%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if bit == '1' {
+			w.data[len(w.data)-1] |= 0x80 >> (w.count % 8)
+		}
+		w.count++
+	}
+}
+
+// writeRun appends the code of a run of pixels of one color
+func (w *bitWriter) writeRun(length int, black bool) {
+	terminating, makeUp := &ccittWhiteTerminating, &ccittWhiteMakeUp
+	if black {
+		terminating, makeUp = &ccittBlackTerminating, &ccittBlackMakeUp
+	}
+	for length > 2560 {
+		w.write(ccittExtendedMakeUp[len(ccittExtendedMakeUp)-1])
+		length -= 2560
+	}
+	switch {
+	case length >= 1792:
+		w.write(ccittExtendedMakeUp[length/64-28])
+	case length >= 64:
+		w.write(makeUp[length/64-1])
+	}
+	w.write(terminating[length%64])
+}
+
+// encodeCCITTG4 encodes black and white rows with CCITT Group 4 (ITU-T T.6),
+// as read by the PDF CCITTFaxDecode filter with K -1 and the default BlackIs1
+func encodeCCITTG4(rows [][]bool, width int) []byte {
+	var w bitWriter
+	pixel, next := ccittPixel, ccittChangingElement
+
+	// The row before the first one is imagined white
+	reference := make([]bool, width)
+	for _, row := range rows {
+		a0, black := -1, false
+		for a0 < width {
+			a1 := next(row, a0+1)
+			b1 := next(reference, a0+1)
+			for b1 < width && pixel(reference, b1) == black {
+				b1 = next(reference, b1+1)
+			}
+			b2 := next(reference, b1+1)
+
+			switch {
+			case b2 < a1:
+				w.write(ccittPass)
+				a0 = b2
+			case a1-b1 >= -3 && a1-b1 <= 3:
+				w.write(ccittVertical[a1-b1+3])
+				a0, black = a1, !black
+			default:
+				a2 := next(row, a1+1)
+				w.write(ccittHorizontal)
+				w.writeRun(a1-max(a0, 0), black)
+				w.writeRun(a2-a1, !black)
+				a0 = a2
+			}
+		}
+		reference = row
+	}
+
+	// End of facsimile block
+	w.write(ccittEOL)
+	w.write(ccittEOL)
+	return w.data
+}
+
+// ccittPixel returns the color of a pixel of a row, imagining white ones
+// before and after it
+func ccittPixel(row []bool, x int) bool {
+	return x >= 0 && x < len(row) && row[x]
+}
+
+// ccittChangingElement returns the first pixel of a row at or after x whose
+// color differs from the one before it, or the width of the row if none does
+func ccittChangingElement(row []bool, x int) int {
+	for x = max(x, 0); x < len(row); x++ {
+		if ccittPixel(row, x) != ccittPixel(row, x-1) {
+			return x
+		}
+	}
+	return len(row)
+}
+
+// ccittNode is a node of a binary tree of codes, to decode them bit by bit
+type ccittNode struct {
+	next  [2]*ccittNode
+	leaf  bool
+	value int // Decoded value of a leaf
+}
+
+// add adds a code with the value it decodes to below the node
+func (n *ccittNode) add(code string, value int) {
+	for _, bit := range []byte(code) {
+		if n.next[bit-'0'] == nil {
+			n.next[bit-'0'] = &ccittNode{}
+		}
+		n = n.next[bit-'0']
+	}
+	n.leaf, n.value = true, value
+}
+
+// Values of the mode codes other than the vertical modes, which decode to
+// a1 - b1
+const (
+	ccittPassMode = 10 + iota
+	ccittHorizontalMode
+	ccittEOLMode
+)
+
+// Decoding trees of the white and black run lengths and of the modes
+var ccittWhiteRuns, ccittBlackRuns, ccittModes = ccittTrees()
+
+// ccittTrees builds the decoding trees from the code tables
+func ccittTrees() (white, black, modes *ccittNode) {
+	white, black, modes = &ccittNode{}, &ccittNode{}, &ccittNode{}
+	for length := range 64 {
+		white.add(ccittWhiteTerminating[length], length)
+		black.add(ccittBlackTerminating[length], length)
+	}
+	for i := range ccittWhiteMakeUp {
+		white.add(ccittWhiteMakeUp[i], (i+1)*64)
+		black.add(ccittBlackMakeUp[i], (i+1)*64)
+	}
+	for i, code := range ccittExtendedMakeUp {
+		white.add(code, 1792+i*64)
+		black.add(code, 1792+i*64)
+	}
+	modes.add(ccittPass, ccittPassMode)
+	modes.add(ccittHorizontal, ccittHorizontalMode)
+	modes.add(ccittEOL, ccittEOLMode)
+	for i, code := range ccittVertical {
+		modes.add(code, i-3)
+	}
+	return white, black, modes
+}
+
+// bitReader reads data bit by bit, most significant bit first
+type bitReader struct {
+	data []byte
+	pos  int // Number of bits read
+}
+
+// bit reads the next bit, reporting false at the end of the data
+func (r *bitReader) bit() (int, bool) {
+	if r.pos >= len(r.data)*8 {
+		return 0, false
+	}
+	bit := int(r.data[r.pos/8]>>(7-r.pos%8)) & 1
+	r.pos++
+	return bit, true
+}
+
+// decode reads a code of a decoding tree, returning io.EOF at the end of the data
+func (r *bitReader) decode(tree *ccittNode) (int, error) {
+	for node := tree; ; {
+		bit, ok := r.bit()
+		if !ok {
+			return 0, io.EOF
+		}
+		if node = node.next[bit]; node == nil {
+			return 0, fmt.Errorf("invalid code at bit %d", r.pos)
+		}
+		if node.leaf {
+			return node.value, nil
+		}
+	}
+}
+
+// run reads the make-up and terminating codes of a run of one color
+func (r *bitReader) run(black bool) (int, error) {
+	tree := ccittWhiteRuns
+	if black {
+		tree = ccittBlackRuns
+	}
+	length := 0
+	for {
+		part, err := r.decode(tree)
+		if err != nil {
+			return 0, err
+		}
+		length += part
+		if part < 64 {
+			return length, nil
+		}
+	}
+}
+
+// skipEOL skips an end-of-line code, with any fill bits before it, if one follows
+func (r *bitReader) skipEOL() {
+	zeros := 0
+	for pos := r.pos; pos < len(r.data)*8 && r.data[pos/8]>>(7-pos%8)&1 == 0; pos++ {
+		zeros++
+	}
+	if zeros >= 11 && r.pos+zeros < len(r.data)*8 {
+		r.pos += zeros + 1
+	}
+}
+
+// decodeCCITT decodes black and white rows, with true for black, encoded
+// with CCITT Group 3 or 4. As for the PDF CCITTFaxDecode filter, k < 0
+// selects Group 4, k = 0 one-dimensional Group 3 and k > 0 mixed one- and
+// two-dimensional Group 3. With byteAligned each row starts on a byte. Rows
+// past the end of the data are left white.
+func decodeCCITT(data []byte, width, height, k int, byteAligned bool) ([][]bool, error) {
+	r := &bitReader{data: data}
+	rows := make([][]bool, height)
+	for y := range rows {
+		rows[y] = make([]bool, width)
+	}
+
+	// The row before the first one is imagined white
+	reference := make([]bool, width)
+	for y, row := range rows {
+		if byteAligned {
+			r.pos = (r.pos + 7) / 8 * 8
+		}
+		twoDimensional := k < 0
+		if k >= 0 {
+			r.skipEOL()
+			if k > 0 {
+				bit, _ := r.bit()
+				twoDimensional = bit == 0
+			}
+		}
+
+		var err error
+		if twoDimensional {
+			err = r.decodeRow2D(row, reference)
+		} else {
+			err = r.decodeRow1D(row)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", y+1, err)
+		}
+		reference = row
+	}
+	return rows, nil
+}
+
+// decodeRow1D decodes a row coded as alternating white and black runs
+func (r *bitReader) decodeRow1D(row []bool) error {
+	for a0, black := 0, false; a0 < len(row); black = !black {
+		length, err := r.run(black)
+		if err != nil {
+			return err
+		}
+		if a0+length > len(row) {
+			return fmt.Errorf("run of %d pixels overflows the row", length)
+		}
+		fillRun(row[a0:a0+length], black)
+		a0 += length
+	}
+	return nil
+}
+
+// decodeRow2D decodes a row coded relative to the reference row before it.
+// An end-of-line code in place of a mode ends the data with io.EOF.
+func (r *bitReader) decodeRow2D(row, reference []bool) error {
+	width := len(row)
+	for a0, black := -1, false; a0 < width; {
+		b1 := ccittChangingElement(reference, a0+1)
+		for b1 < width && ccittPixel(reference, b1) == black {
+			b1 = ccittChangingElement(reference, b1+1)
+		}
+		b2 := ccittChangingElement(reference, b1+1)
+
+		mode, err := r.decode(ccittModes)
+		if err != nil {
+			return err
+		}
+		start := max(a0, 0)
+		switch mode {
+		case ccittPassMode:
+			fillRun(row[start:b2], black)
+			a0 = b2
+		case ccittHorizontalMode:
+			first, err := r.run(black)
+			if err != nil {
+				return err
+			}
+			second, err := r.run(!black)
+			if err != nil {
+				return err
+			}
+			if start+first+second > width {
+				return fmt.Errorf("runs of %d and %d pixels overflow the row", first, second)
+			}
+			fillRun(row[start:start+first], black)
+			fillRun(row[start+first:start+first+second], !black)
+			a0 = start + first + second
+		case ccittEOLMode:
+			return io.EOF
+		default:
+			a1 := b1 + mode
+			if a1 < start || a1 > width {
+				return fmt.Errorf("vertical mode moves to pixel %d, outside of the row", a1)
+			}
+			fillRun(row[start:a1], black)
+			a0, black = a1, !black
+		}
+	}
+	return nil
+}
+
+// fillRun sets the pixels of a run to black, leaving white ones as they are
+func fillRun(run []bool, black bool) {
+	if black {
+		for i := range run {
+			run[i] = true
+		}
+	}
+}
+
+// embedCCITTImages replaces the images fpdf wrote for the given pages
+// (1-based) with their CCITT Group 4 encoding, which fpdf can't write itself
+func embedCCITTImages(pdfData []byte, images map[int]ccittImage) ([]byte, error) {
+	if len(images) == 0 {
+		return pdfData, nil
+	}
+	file := parsePDFObjects(pdfData)
+	pages := file.pages()
+	for pageNum, img := range images {
+		if pageNum > len(pages) {
+			return nil, fmt.Errorf("page %d is missing from the PDF", pageNum)
+		}
+		page := pages[pageNum-1]
+		content, err := file.stream(file.ref(file.dict(page), "Contents"))
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		match := pdfDoPattern.FindSubmatch(content)
+		if match == nil {
+			return nil, fmt.Errorf("page %d has no image", pageNum)
+		}
+		num := file.refs(file.subdict(file.resources(page), "XObject"))[string(match[1])]
+		if num == 0 {
+			return nil, fmt.Errorf("page %d: image /%s not found", pageNum, match[1])
+		}
+
+		var body bytes.Buffer
+		fmt.Fprintf(&body, "\n<</Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 1"+
+			" /Filter /CCITTFaxDecode /DecodeParms <</K -1 /Columns %d /Rows %d>> /Length %d>>\nstream\n",
+			img.width, img.height, img.width, img.height, len(img.data))
+		body.Write(img.data)
+		body.WriteString("\nendstream\n")
+		file.objects[num] = body.Bytes()
+	}
+	return file.bytes()
+}
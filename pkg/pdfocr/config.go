@@ -1,34 +1,171 @@
 package pdfocr
 
 import (
+	"fmt"
 	"io"
+	"log/slog"
+	"strings"
 )
 
 // OCRConfig holds user options for applying OCR to PDF
 type OCRConfig struct {
-	Debug       bool      // Enable debug mode
-	Force       bool      // Force OCR application, overriding all warnings and errors
-	Strict      bool      // If true, turn warnings into errors (unless Force is also true)
-	LayerName   string    // Base name of OCR layer (page number will be appended)
-	StartPage   int       // Start applying OCR from this page number
-	DumpPDF     bool      // Dump PDF structure for debugging
-	LogWarnings bool      // Whether to print warnings
-	Logger      io.Writer // Custom logger for warnings (nil = stdout)
-	Font        FontConfig
+	Debug          bool         // Enable debug mode
+	Heatmap        bool         // In debug mode, color word boxes by confidence (green = high, red = low)
+	ShowConfidence bool         // In debug mode, label each word box with its confidence value, drawn as lines rather than text
+	Force          bool         // Force OCR application, overriding all warnings and errors
+	Strict         bool         // If true, turn warnings into errors (unless Force is also true)
+	Replace        bool         // Strip an existing OCR layer before applying the new one, instead of warning or failing
+	LayerName      string       // Base name of OCR layer (page number will be appended)
+	StartPage      int          // Start applying OCR from this page number
+	PageRanges     []PageRange  // Apply hOCR pages, in order, to just these pages of an existing PDF (overrides StartPage)
+	DumpPDF        bool         // Dump PDF structure for debugging
+	LogWarnings    bool         // Whether to print warnings
+	Logger         io.Writer    // Custom logger for warnings (nil = stdout)
+	EventLogger    *slog.Logger // Structured logger for events such as EventOCRDetected (nil = no events)
+	Font           FontConfig
+
+	EncodingFallback EncodingFallback // What to do with words the font can't encode
+	ReplacementChar  string           // Replacement glyph used by EncodingFallbackReplace
+
+	// FailOnEncodingIssues fails with ErrEncodingIssues when more than a
+	// tenth of the words of a page needed an encoding fallback, instead of
+	// adding a warning to the result and keeping the fallbacks
+	FailOnEncodingIssues bool
+
+	// MinWordConfidence leaves words with a lower hOCR confidence (0-100) out of
+	// the OCR layer, so misrecognized text doesn't pollute search. Words without
+	// a confidence value are kept. Zero keeps every word.
+	MinWordConfidence float64
+	// LowConfidenceLayer draws the words below MinWordConfidence onto a
+	// separate layer per page, hidden by default, instead of dropping them
+	LowConfidenceLayer bool
+
+	// LayerNameTemplate, if set, names each page's OCR layer instead of
+	// appending " (Page N)" to LayerName. It is a text/template executed with
+	// LayerNameFields, e.g. "{{.Base}} p{{.Page}} - {{.Engine}}". Low
+	// confidence layers get " (low confidence)" appended. Detection, removal
+	// and verification recognize the layers by the same template.
+	LayerNameTemplate string
+
+	// NoLayers draws the OCR text straight into the page content, without
+	// optional content groups, for viewers and pipelines that mishandle them.
+	// The text stays invisible, and DetectOCR finds it as such. Words below
+	// MinWordConfidence are left out even with LowConfidenceLayer.
+	NoLayers bool
+
+	// SkipPagesWithText applies OCR to just the pages of an existing PDF that
+	// show no text yet, such as the scanned appendix of a digitally created
+	// report, and copies the pages that already show text, typed or OCR'd, as
+	// they are. The hOCR pages are paired with the PDF pages as without it.
+	// Existing OCR is then no reason to warn or fail, as it isn't duplicated;
+	// with Replace, it is stripped first, so only the typed pages are skipped.
+	SkipPagesWithText bool
+
+	// OnProgress, if set, is called as ApplyOCR and AssembleWithOCR work through
+	// the document, e.g. to drive a progress bar. Page is the number of pages
+	// done so far out of totalPages, and stage is one of the Progress constants.
+	OnProgress func(page, totalPages int, stage string)
+
+	// Metadata sets the document information of the output. The Info
+	// dictionary and XMP metadata of an existing PDF are carried over, with
+	// the fields set here replacing theirs.
+	Metadata Metadata
+
+	// Provenance is recorded in the XMP metadata of the output, replacing the
+	// provenance of an earlier OCR pass. Fields left empty are filled in:
+	// Engine from the ocr-system of the hOCR, Timestamp with the time of
+	// writing and Version with the version of this module.
+	Provenance Provenance
+
+	// KeepAnnotations copies the annotations of an existing PDF, such as
+	// links, highlights and comments, to the rebuilt pages. Fillable form
+	// fields are always kept.
+	KeepAnnotations bool
+
+	// Password opens an encrypted input PDF, as its user or owner password.
+	// PDFs that only restrict printing or copying open without one. The
+	// output is not encrypted.
+	Password string
+
+	// ImageDPI downsamples the page images AssembleWithOCR embeds to this
+	// resolution, if they have a higher one. The resolution of an image is
+	// read from its JPEG (JFIF) or PNG header, or else ImageSourceDPI is
+	// assumed (DefaultImageSourceDPI if unset). Zero keeps every pixel.
+	ImageDPI       float64
+	ImageSourceDPI float64
+
+	// ImageQuality re-encodes the page images AssembleWithOCR embeds as
+	// JPEG with this quality (1-100), where that makes them smaller. Zero
+	// keeps their format; downsampled JPEG images are saved at quality 85.
+	ImageQuality int
+
+	// CompressBitonal embeds the page images AssembleWithOCR is given that
+	// have only black and white pixels as CCITT Group 4, the compression
+	// of fax and document archives, instead of as PNG or JPEG
+	CompressBitonal bool
+}
+
+// Stages reported to OCRConfig.OnProgress
+const (
+	ProgressDetect = "detect" // Checking the input PDF for existing OCR (page and totalPages are 0)
+	ProgressRemove = "remove" // Removing an existing OCR layer in Replace mode (page and totalPages are 0)
+	ProgressPage   = "page"   // A page of the output was built
+	ProgressWrite  = "write"  // Writing the output PDF (page equals totalPages)
+)
+
+// progress reports progress to the OnProgress callback, if any
+func (c OCRConfig) progress(page, totalPages int, stage string) {
+	if c.OnProgress != nil {
+		c.OnProgress(page, totalPages, stage)
+	}
 }
 
 // DefaultConfig returns a config with sensible defaults
 func DefaultConfig() OCRConfig {
 	return OCRConfig{
-		Debug:       false,
-		Force:       false,
-		Strict:      false,
-		LayerName:   "OCR Text", // Will be formatted as "OCR Text (Page X)" in the final PDF
-		StartPage:   1,
-		DumpPDF:     false,
-		LogWarnings: true,
-		Logger:      nil, // stdout
-		Font:        DefaultFont,
+		Debug:          false,
+		Heatmap:        false,
+		ShowConfidence: false,
+		Force:          false,
+		Strict:         false,
+		Replace:        false,
+		LayerName:      "OCR Text", // Will be formatted as "OCR Text (Page X)" in the final PDF
+		StartPage:      1,
+		DumpPDF:        false,
+		LogWarnings:    true,
+		Logger:         nil, // stdout
+		Font:           DefaultFont,
+
+		EncodingFallback: EncodingFallbackTransliterate,
+		ReplacementChar:  "?",
+	}
+}
+
+// EncodingFallback is the policy applied to words containing characters
+// that can't be encoded in the OCR layer font
+type EncodingFallback string
+
+const (
+	// EncodingFallbackTransliterate replaces unencodable characters with
+	// their closest ASCII equivalent (e.g. "Ж" becomes "Zh")
+	EncodingFallbackTransliterate EncodingFallback = "transliterate"
+	// EncodingFallbackReplace replaces unencodable characters with ReplacementChar
+	EncodingFallbackReplace EncodingFallback = "replace"
+	// EncodingFallbackSkip leaves words with unencodable characters out of the OCR layer
+	EncodingFallbackSkip EncodingFallback = "skip"
+)
+
+// ParseEncodingFallback converts a policy name into an EncodingFallback
+func ParseEncodingFallback(name string) (EncodingFallback, error) {
+	switch EncodingFallback(strings.ToLower(strings.TrimSpace(name))) {
+	case EncodingFallbackTransliterate, "":
+		return EncodingFallbackTransliterate, nil
+	case EncodingFallbackReplace:
+		return EncodingFallbackReplace, nil
+	case EncodingFallbackSkip:
+		return EncodingFallbackSkip, nil
+	default:
+		return "", fmt.Errorf("unknown encoding fallback %q (expected transliterate, replace or skip)", name)
 	}
 }
 
@@ -37,13 +174,35 @@ type FontConfig struct {
 	Name        string  // Font name (e.g., "Helvetica")
 	Style       string  // Font style ("", "B", "I", "BI")
 	Size        float64 // Default font size
-	AscentRatio float64 // Vertical positioning ratio
+	AscentRatio float64 // Vertical positioning ratio, used for words whose line has no hOCR baseline
+
+	// Size each word's font from the height of its bounding box instead of its
+	// width, and stretch or squeeze the glyphs horizontally to fill the width, so
+	// selections cover tall headings and small footnotes alike. The box is taken
+	// to span the ascent and descent of the font. Sizes derived from the height
+	// are clamped to MinSize and MaxSize in points, where these are set.
+	FitHeight    bool
+	DescentRatio float64 // Depth of descenders below the baseline, relative to the font size
+	MinSize      float64
+	MaxSize      float64
+
+	// A TrueType font with Unicode coverage, embedded and used automatically for
+	// pages with text outside Windows-1252 (CJK, Arabic, Cyrillic, ...). Set either
+	// the path or the font data; without one, such text goes through EncodingFallback.
+	UnicodeFontPath string
+	UnicodeFontData []byte
+
+	// TrueType fonts per script, for documents that mix languages no single font
+	// covers. Each word is drawn with the first font of its script that has glyphs
+	// for all of its characters; words no font covers fall back to the fonts above.
+	ScriptFonts map[Script][]ScriptFont
 }
 
 // DefaultFont sets the default font to Helvetica which is tried and tested for the OCR layer
 var DefaultFont = FontConfig{
-	Name:        "Helvetica",
-	Style:       "",
-	Size:        10,
-	AscentRatio: 0.718,
+	Name:         "Helvetica",
+	Style:        "",
+	Size:         10,
+	AscentRatio:  0.718,
+	DescentRatio: 0.207,
 }
@@ -0,0 +1,341 @@
+package pdfocr
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pdfMediaBoxPattern extracts the coordinates of a page's media box
+var pdfMediaBoxPattern = regexp.MustCompile(`/MediaBox\s*\[\s*([-+.\d]+)\s+([-+.\d]+)\s+([-+.\d]+)\s+([-+.\d]+)\s*\]`)
+
+// pdfImagePattern matches the subtype of an image XObject
+var pdfImagePattern = regexp.MustCompile(`/Subtype\s*/Image\b`)
+
+// maxFormDepth limits how deeply nested form XObjects are followed
+const maxFormDepth = 8
+
+// averageGlyphWidth is the glyph width, relative to the font size, used to
+// estimate the area covered by text
+const averageGlyphWidth = 0.5
+
+// PageOCRInfo describes the text and images found on a single page of a PDF
+type PageOCRInfo struct {
+	Page           int      // Page number (1-based)
+	HasText        bool     // True if the page shows any text
+	InvisibleText  bool     // True if all text on the page is invisible (rendering mode 3), as OCR text is
+	WordCount      int      // Number of words shown on the page
+	ImageCoverage  float64  // Fraction of the page area covered by images (0 to 1)
+	TextImageRatio float64  // Estimated text area divided by image area (0 if the page has no images)
+	Partial        bool     // True if some of the page content could not be read
+	Fonts          []string // Base names of the fonts text is shown in, in the order they are first used
+
+	// Layers are the names of the optional content layers the page draws
+	// in, in the order they are first used, and HasOCR whether the page has
+	// OCR text: a layer named as the OCR layers are (see OCRConfig.LayerName
+	// and LayerNameTemplate), or invisible text over images
+	Layers []string
+	HasOCR bool
+}
+
+// NeedsOCR reports whether the page shows images but no text, as a scanned page does
+func (p PageOCRInfo) NeedsOCR() bool {
+	return !p.HasText && p.ImageCoverage > 0
+}
+
+// detectPageCoverage reports the text, images and layers of every page of a
+// PDF, recognizing OCR layers by the layer names of config
+func detectPageCoverage(pdfData []byte, config OCRConfig) []PageOCRInfo {
+	file := parsePDFObjects(pdfData)
+
+	var pages []PageOCRInfo
+	for i, page := range file.pages() {
+		scanner := &pageScanner{file: file, fonts: make(map[int]*pdfFontDecoder)}
+		for _, num := range pdfReferenceList(file.dict(page), "Contents") {
+			content, err := file.stream(num)
+			if err != nil {
+				scanner.partial = true
+				continue
+			}
+			scanner.scan(content, file.resources(page), 1, 0)
+		}
+
+		info := PageOCRInfo{
+			Page:          i + 1,
+			HasText:       scanner.visible || scanner.invisible,
+			InvisibleText: scanner.invisible && !scanner.visible,
+			WordCount:     len(strings.Fields(scanner.text.String())),
+			Partial:       scanner.partial,
+			Fonts:         scanner.fontNames,
+			Layers:        scanner.layers,
+		}
+		if width, height := file.mediaBox(page); width*height > 0 {
+			info.ImageCoverage = min(scanner.imageArea/(width*height), 1)
+		}
+		if scanner.imageArea > 0 {
+			info.TextImageRatio = scanner.textArea / scanner.imageArea
+		}
+		info.HasOCR = info.InvisibleText && info.ImageCoverage > 0 || slices.ContainsFunc(info.Layers, config.isOCRLayerName)
+		pages = append(pages, info)
+	}
+
+	return pages
+}
+
+// mediaBox returns the size of a page, which may be inherited from the page
+// tree. Pages without a media box are assumed to be US Letter.
+func (f *pdfFile) mediaBox(page int) (width, height float64) {
+	_, _, width, height = f.mediaBoxRect(page)
+	return width, height
+}
+
+// mediaBoxRect returns the lower-left corner and size of a page's media box
+func (f *pdfFile) mediaBoxRect(page int) (x, y, width, height float64) {
+	seen := make(map[int]bool)
+	for num := page; num > 0 && !seen[num]; num = f.ref(f.dict(num), "Parent") {
+		seen[num] = true
+		match := pdfMediaBoxPattern.FindSubmatch(f.dict(num))
+		if match == nil {
+			continue
+		}
+		var box [4]float64
+		for i := range box {
+			box[i], _ = strconv.ParseFloat(string(match[i+1]), 64)
+		}
+		return min(box[0], box[2]), min(box[1], box[3]), math.Abs(box[2] - box[0]), math.Abs(box[3] - box[1])
+	}
+	return 0, 0, 612, 792
+}
+
+// pageScanner walks the content of a page, collecting its text and the
+// areas covered by text and images. Only the scale of the transformation
+// matrices matters for areas, so they are tracked by their determinant.
+type pageScanner struct {
+	file      *pdfFile
+	fonts     map[int]*pdfFontDecoder
+	text      strings.Builder // Text shown on the page, with spaces between text runs
+	visible   bool            // Whether visible text was shown
+	invisible bool            // Whether invisible text was shown
+	textArea  float64         // Estimated area covered by glyphs, in default user space
+	imageArea float64         // Area covered by images, in default user space
+	partial   bool            // Whether some content streams could not be read
+	layers    []string        // Names of the optional content layers drawn in
+	fontNames []string        // Base names of the fonts text was shown in
+}
+
+// scan walks a content stream drawn with the given resources, where scale
+// is the determinant of the current transformation matrix
+func (s *pageScanner) scan(content, resources []byte, scale float64, depth int) {
+	fontRefs := s.file.refs(s.file.subdict(resources, "Font"))
+	xobjects := s.file.refs(s.file.subdict(resources, "XObject"))
+	properties := s.file.refs(s.file.subdict(resources, "Properties"))
+
+	var saved []float64
+	var operands []pdfToken
+	var font *pdfFontDecoder
+	var fontName string
+	var fontSize, mode float64
+	var savedModes []float64
+	textScale := 1.0 // Determinant of the text matrix
+
+	show := func(text []byte) {
+		if font == nil {
+			font = &pdfFontDecoder{codeLength: 1}
+		}
+		if fontName != "" && !slices.Contains(s.fontNames, fontName) {
+			s.fontNames = append(s.fontNames, fontName)
+		}
+		if mode == textRenderInvisible {
+			s.invisible = true
+		} else {
+			s.visible = true
+		}
+		s.text.WriteString(font.decode(text))
+		glyphs := float64(len(text) / font.codeLength)
+		s.textArea += glyphs * averageGlyphWidth * fontSize * fontSize * math.Abs(textScale*scale)
+	}
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch token.text {
+		case "q":
+			saved = append(saved, scale)
+			savedModes = append(savedModes, mode)
+		case "Q":
+			if len(saved) > 0 {
+				scale, saved = saved[len(saved)-1], saved[:len(saved)-1]
+				mode, savedModes = savedModes[len(savedModes)-1], savedModes[:len(savedModes)-1]
+			}
+		case "cm":
+			if m, ok := pdfMatrixOperands(operands); ok {
+				scale *= m[0]*m[3] - m[1]*m[2]
+			}
+		case "BT":
+			textScale = 1
+			s.text.WriteByte(' ')
+		case "ET", "Td", "TD", "T*":
+			s.text.WriteByte(' ')
+		case "Tm":
+			if m, ok := pdfMatrixOperands(operands); ok {
+				textScale = m[0]*m[3] - m[1]*m[2]
+			}
+			s.text.WriteByte(' ')
+		case "Tr":
+			if len(operands) == 1 && operands[0].kind == pdfNumber {
+				mode, _ = strconv.ParseFloat(operands[0].text, 64)
+			}
+		case "Tf":
+			if len(operands) >= 2 && operands[0].kind == pdfName {
+				fontSize, _ = strconv.ParseFloat(operands[1].text, 64)
+				font = s.font(fontRefs[operands[0].text])
+				fontName = s.fontName(fontRefs[operands[0].text], operands[0].text)
+			}
+		case "Tj", "'", "\"":
+			if token.text != "Tj" {
+				s.text.WriteByte(' ')
+			}
+			if len(operands) > 0 && operands[len(operands)-1].kind == pdfString {
+				show(operands[len(operands)-1].bytes)
+			}
+		case "TJ":
+			if len(operands) == 0 || operands[0].kind != pdfArray {
+				break
+			}
+			for _, item := range operands[0].items {
+				switch item.kind {
+				case pdfString:
+					show(item.bytes)
+				case pdfNumber:
+					// Large negative adjustments separate words
+					if adjustment, _ := strconv.ParseFloat(item.text, 64); adjustment < -200 {
+						s.text.WriteByte(' ')
+					}
+				}
+			}
+		case "Do":
+			if len(operands) == 1 && operands[0].kind == pdfName {
+				s.drawXObject(xobjects[operands[0].text], resources, scale, depth)
+			}
+		case "BDC":
+			// Content drawn in a layer is marked as /OC /name BDC ... EMC
+			if len(operands) == 2 && operands[0].kind == pdfName && operands[0].text == "OC" && operands[1].kind == pdfName {
+				s.addLayers(properties[operands[1].text])
+			}
+		case "ID":
+			lexer.skipInlineImage()
+			s.imageArea += math.Abs(scale)
+		}
+		operands = operands[:0]
+	}
+}
+
+// drawXObject adds the area of an image, or scans the content of a form
+func (s *pageScanner) drawXObject(num int, resources []byte, scale float64, depth int) {
+	if num == 0 {
+		return
+	}
+	dict := s.file.dict(num)
+	switch {
+	case pdfImagePattern.Match(dict):
+		s.imageArea += math.Abs(scale)
+	case pdfFormPattern.Match(dict) && depth < maxFormDepth:
+		content, err := s.file.stream(num)
+		if err != nil {
+			s.partial = true
+			return
+		}
+		if matrix, ok := pdfDictMatrix(dict, "Matrix"); ok {
+			scale *= matrix[0]*matrix[3] - matrix[1]*matrix[2]
+		}
+		// Forms without their own resources use those of the page
+		if own := s.file.subdict(dict, "Resources"); own != nil {
+			resources = own
+		}
+		s.scan(content, resources, scale, depth+1)
+	}
+}
+
+// addLayers records the name of an optional content group, or of the groups
+// of a membership dictionary, which makes content visible by several groups
+func (s *pageScanner) addLayers(num int) {
+	if num == 0 {
+		return
+	}
+	groups := []int{num}
+	if !s.file.hasType(num, "OCG") {
+		groups = pdfReferenceList(s.file.dict(num), "OCGs")
+	}
+	for _, group := range groups {
+		name, ok := pdfStringValue(s.file.dict(group), "Name")
+		if ok && !slices.Contains(s.layers, name) {
+			s.layers = append(s.layers, name)
+		}
+	}
+}
+
+// fontName returns the base name of a font, or its resource name if the
+// font doesn't have one
+func (s *pageScanner) fontName(num int, resourceName string) string {
+	if base, ok := s.file.value(s.file.dict(num), "BaseFont"); ok && base.kind == pdfName {
+		return base.text
+	}
+	return resourceName
+}
+
+// font returns the decoder of a font, falling back to single-byte codes
+// for fonts that can't be decoded
+func (s *pageScanner) font(num int) *pdfFontDecoder {
+	if decoder, ok := s.fonts[num]; ok {
+		return decoder
+	}
+	decoder, err := newPDFFontDecoder(s.file, num)
+	if err != nil || num == 0 {
+		decoder = &pdfFontDecoder{codeLength: 1}
+	}
+	s.fonts[num] = decoder
+	return decoder
+}
+
+// pdfMatrixOperands reads the six numbers of a matrix operator
+func pdfMatrixOperands(operands []pdfToken) ([6]float64, bool) {
+	var m [6]float64
+	if len(operands) != 6 {
+		return m, false
+	}
+	for i, operand := range operands {
+		if operand.kind != pdfNumber {
+			return m, false
+		}
+		m[i], _ = strconv.ParseFloat(operand.text, 64)
+	}
+	return m, true
+}
+
+// pdfDictMatrix reads a matrix stored as an array under a key of a dictionary
+func pdfDictMatrix(dict []byte, key string) ([6]float64, bool) {
+	match := pdfKeySubmatch(dict, key, `\s*\[([^\]]*)\]`)
+	if match == nil {
+		return [6]float64{}, false
+	}
+	var operands []pdfToken
+	lexer := &pdfLexer{data: match[1]}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		operands = append(operands, token)
+	}
+	return pdfMatrixOperands(operands)
+}
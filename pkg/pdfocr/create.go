@@ -3,6 +3,8 @@ package pdfocr
 import (
 	"bytes"
 	"codeberg.org/go-pdf/fpdf"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gardar/ocrchestra/pkg/hocr"
 	"image"
@@ -12,17 +14,28 @@ import (
 // createPDFFromImage builds a new PDF from images with their corresponding OCR data.
 // This function assumes inputs have been validated by the caller.
 func createPDFFromImage(
+	ctx context.Context,
 	hOCRData hocr.HOCR,
 	imagesData [][]byte,
-	startFromPage int,
-	debug bool,
-	layerName string,
-	fontConfig FontConfig,
+	config OCRConfig,
+	result *ApplyResult,
 ) ([]byte, error) {
-	startIdx := startFromPage - 1
+	font, err := config.Font.loadFonts()
+	if err != nil {
+		return nil, err
+	}
+	config.Font = font
+
+	startIdx := config.StartPage - 1
 	pdf := fpdf.New("P", "pt", "A4", "")
+	totalPages := max(min(len(hOCRData.Pages), len(imagesData))-startIdx, 0)
+	ccittImages := make(map[int]ccittImage)
 
 	for i := startIdx; i < len(hOCRData.Pages) && i < len(imagesData); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("stopped before page %d: %w", i+1, err)
+		}
+
 		page := hOCRData.Pages[i]
 		w, h := page.BBox.X2, page.BBox.Y2
 
@@ -40,8 +53,31 @@ func createPDFFromImage(
 			return nil, fmt.Errorf("failed to detect image type for image %d: %w", i, err)
 		}
 
+		// Shrink the image as configured; it still fills the whole page.
+		// fpdf embeds black-and-white images as given, and they are
+		// swapped for their CCITT encoding once the PDF is written.
+		imageData := imagesData[i]
+		var bitonal ccittImage
+		isBitonal := false
+		if config.CompressBitonal {
+			if bitonal, isBitonal, err = bitonalPage(imageData, config); err != nil {
+				result.addWarning(fmt.Sprintf("page %d: not compressing the image: %v", actualPageNum, err))
+			}
+		}
+		if isBitonal {
+			ccittImages[i-startIdx+1] = bitonal
+			result.OptimizedImages++
+			result.ImageBytesSaved += len(imageData) - len(bitonal.data)
+		} else if optimized, optimizedType, changed, err := optimizeImage(imageData, imageType, config); err != nil {
+			result.addWarning(fmt.Sprintf("page %d: not optimizing the image: %v", actualPageNum, err))
+		} else if changed {
+			result.OptimizedImages++
+			result.ImageBytesSaved += len(imageData) - len(optimized)
+			imageData, imageType = optimized, optimizedType
+		}
+
 		opts := fpdf.ImageOptions{ReadDpi: false, ImageType: imageType}
-		pdf.RegisterImageOptionsReader(imageName, opts, bytes.NewReader(imagesData[i]))
+		pdf.RegisterImageOptionsReader(imageName, opts, bytes.NewReader(imageData))
 		pdf.ImageOptions(imageName, 0, 0, w, h, false, opts, 0, "")
 
 		// Create transformation function for this page
@@ -50,18 +86,25 @@ func createPDFFromImage(
 		}
 
 		// Add OCR layer with page number
-		err = drawOCRLayer(pdf, page, debug, layerName, actualPageNum, transform, fontConfig)
+		err = drawOCRLayer(pdf, page, actualPageNum, hOCRData.Metadata["ocr-system"], transform, config, result)
 		if err != nil {
-			return nil, fmt.Errorf("failed to draw OCR layer for page %d: %w", i+1, err)
+			// Encoding problems are handled by the fallback policy, so they only block when asked to
+			if !errors.Is(err, ErrEncodingIssues) || config.FailOnEncodingIssues {
+				return nil, fmt.Errorf("failed to draw OCR layer for page %d: %w", i+1, err)
+			}
+			result.addWarning(fmt.Sprintf("page %d: %v", actualPageNum, err))
 		}
+		config.progress(i-startIdx+1, totalPages, ProgressPage)
 	}
 
 	// Generate final PDF
+	Metadata{}.merge(config.Metadata).withProvenance(config.provenance(hOCRData)).apply(pdf)
+	config.progress(totalPages, totalPages, ProgressWrite)
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
 		return nil, fmt.Errorf("failed to generate PDF: %w", err)
 	}
-	return buf.Bytes(), nil
+	return embedCCITTImages(buf.Bytes(), ccittImages)
 }
 
 // detectImageType tries to figure out whether the data is PNG, JPEG, etc.
@@ -0,0 +1,462 @@
+package pdfocr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// pdfObjectGenerationPattern matches the header of an indirect object with its generation number
+	pdfObjectGenerationPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+	// pdfUnencryptedStreamPattern matches the types of streams that are never encrypted
+	pdfUnencryptedStreamPattern = regexp.MustCompile(`/Type\s*/XRef\b`)
+
+	// pdfMetadataStreamPattern matches the type of XMP metadata streams
+	pdfMetadataStreamPattern = regexp.MustCompile(`/Type\s*/Metadata\b`)
+
+	// pdfLengthPattern matches the length entry of a stream dictionary
+	pdfLengthPattern = regexp.MustCompile(`/Length\s+\d+(\s+\d+\s+R)?`)
+)
+
+// pdfPasswordPadding pads passwords for the RC4 and AES-128 security handlers
+var pdfPasswordPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+// Ciphers of the standard security handler
+const (
+	cipherNone = "None"
+	cipherRC4  = "V2"
+	cipherAES  = "AESV2"
+	cipher256  = "AESV3"
+)
+
+// DecryptPDF returns an unencrypted copy of a PDF protected by the standard
+// security handler (RC4, AES-128 or AES-256), opening it with either its
+// user or its owner password. PDFs with only an owner password, which
+// restricts printing or copying, open with an empty password. PDFs that
+// aren't encrypted are returned as is.
+func DecryptPDF(pdfData []byte, password string) ([]byte, error) {
+	if !bytes.Contains(pdfData, []byte("/Encrypt")) {
+		return pdfData, nil
+	}
+	file := parsePDFObjects(pdfData)
+	if !bytes.Contains(file.trailer, []byte("/Encrypt")) {
+		return pdfData, nil
+	}
+
+	handler, err := newSecurityHandler(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := handler.authenticate(password); err != nil {
+		return nil, err
+	}
+
+	generations := make(map[int]int)
+	for _, match := range pdfObjectGenerationPattern.FindAllSubmatch(pdfData, -1) {
+		num, _ := strconv.Atoi(string(match[1]))
+		generation, _ := strconv.Atoi(string(match[2]))
+		generations[num] = generation
+	}
+
+	// Objects read from object streams are skipped, as they can only have
+	// been read if the stream wasn't encrypted
+	encryptNum := file.ref(file.trailer, "Encrypt")
+	for num, generation := range generations {
+		if _, ok := file.objects[num]; !ok || num == encryptNum {
+			continue
+		}
+		file.objects[num] = handler.decryptObject(file, num, generation)
+	}
+	delete(file.objects, encryptNum)
+	file.trailer = pdfRemoveEntry(file.trailer, "Encrypt")
+
+	// Object streams could only be read once decrypted
+	file.loadObjectStreams()
+	return file.bytes()
+}
+
+// decryptInput decrypts an input PDF with the configured password
+func decryptInput(pdfData []byte, config OCRConfig) ([]byte, error) {
+	decrypted, err := DecryptPDF(pdfData, config.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt PDF: %w", err)
+	}
+	return decrypted, nil
+}
+
+// securityHandler holds the parameters of the standard security handler of
+// an encrypted PDF and, once authenticated, its file encryption key
+type securityHandler struct {
+	revision        int
+	keyLength       int    // Length of the file encryption key in bytes
+	owner           []byte // /O entry
+	user            []byte // /U entry
+	ownerKey        []byte // /OE entry (AES-256)
+	userKey         []byte // /UE entry (AES-256)
+	permissions     int32  // /P entry
+	id              []byte // First element of the trailer /ID
+	encryptMetadata bool
+	streamCipher    string // Cipher of streams, one of the cipher constants
+	stringCipher    string // Cipher of strings
+	key             []byte // File encryption key
+}
+
+// newSecurityHandler reads the encryption dictionary of a PDF
+func newSecurityHandler(file *pdfFile) (*securityHandler, error) {
+	lexer := &pdfLexer{data: file.wholeDict(file.trailer, "Encrypt")}
+	token, ok := lexer.next()
+	if !ok || token.kind != pdfDict {
+		return nil, fmt.Errorf("%w: missing encryption dictionary", ErrEncryptedPDF)
+	}
+	entries := token.dict
+	if filter := entries["Filter"].text; filter != "Standard" {
+		return nil, fmt.Errorf("%w: security handler %q", ErrEncryptedPDF, filter)
+	}
+
+	number := func(key string, fallback int) int {
+		value, err := strconv.Atoi(entries[key].text)
+		if err != nil {
+			return fallback
+		}
+		return value
+	}
+	handler := &securityHandler{
+		revision:        number("R", 0),
+		keyLength:       number("Length", 40) / 8,
+		owner:           entries["O"].bytes,
+		user:            entries["U"].bytes,
+		ownerKey:        entries["OE"].bytes,
+		userKey:         entries["UE"].bytes,
+		permissions:     int32(number("P", 0)),
+		encryptMetadata: entries["EncryptMetadata"].text != "false",
+		streamCipher:    cipherRC4,
+		stringCipher:    cipherRC4,
+	}
+	trailer := &pdfLexer{data: file.trailer}
+	if token, ok := trailer.next(); ok && token.kind == pdfDict && len(token.dict["ID"].items) > 0 {
+		handler.id = token.dict["ID"].items[0].bytes
+	}
+
+	switch version := number("V", 0); {
+	case version == 1 || version == 2:
+		if handler.revision == 2 {
+			handler.keyLength = 5
+		}
+	case version == 4 || version == 5:
+		// Crypt filters name the cipher of streams and strings
+		filters := entries["CF"].dict
+		cipherOf := func(key string) string {
+			name := entries[key].text
+			if name == "" || name == "Identity" {
+				return cipherNone
+			}
+			return filters[name].dict["CFM"].text
+		}
+		handler.streamCipher, handler.stringCipher = cipherOf("StmF"), cipherOf("StrF")
+		handler.keyLength = 16
+		if version == 5 {
+			handler.keyLength = 32
+		}
+	default:
+		return nil, fmt.Errorf("%w: encryption algorithm %d", ErrEncryptedPDF, version)
+	}
+
+	for _, name := range []string{handler.streamCipher, handler.stringCipher} {
+		switch name {
+		case cipherNone, cipherRC4, cipherAES, cipher256:
+		default:
+			return nil, fmt.Errorf("%w: crypt filter method %q", ErrEncryptedPDF, name)
+		}
+	}
+	if handler.revision < 2 || handler.revision > 6 || handler.keyLength < 5 || handler.keyLength > 32 {
+		return nil, fmt.Errorf("%w: standard security handler revision %d", ErrEncryptedPDF, handler.revision)
+	}
+	return handler, nil
+}
+
+// authenticate derives the file encryption key from the user or the owner password
+func (h *securityHandler) authenticate(password string) error {
+	if h.revision >= 5 {
+		if key := h.aes256Key([]byte(password)); key != nil {
+			h.key = key
+			return nil
+		}
+		return ErrIncorrectPassword
+	}
+
+	// Older revisions take passwords in PDFDocEncoding, which matches Latin-1 for most text
+	var encoded []byte
+	for _, r := range password {
+		if r < 256 {
+			encoded = append(encoded, byte(r))
+		}
+	}
+	if key := h.rc4Key(encoded); h.checkUserKey(key) {
+		h.key = key
+		return nil
+	}
+
+	// The owner password decrypts the user password stored in /O
+	sum := md5.Sum(padPassword(encoded))
+	ownerKey := sum[:]
+	if h.revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(ownerKey)
+			ownerKey = sum[:]
+		}
+	}
+	ownerKey = ownerKey[:h.keyLength]
+	userPassword := append([]byte{}, h.owner...)
+	if h.revision == 2 {
+		userPassword = rc4Crypt(ownerKey, userPassword)
+	} else {
+		for i := 19; i >= 0; i-- {
+			userPassword = rc4Crypt(xorKey(ownerKey, byte(i)), userPassword)
+		}
+	}
+	if key := h.rc4Key(userPassword); h.checkUserKey(key) {
+		h.key = key
+		return nil
+	}
+	return ErrIncorrectPassword
+}
+
+// rc4Key computes the file encryption key from a user password (revisions 2 to 4)
+func (h *securityHandler) rc4Key(password []byte) []byte {
+	digest := md5.New()
+	digest.Write(padPassword(password))
+	digest.Write(h.owner[:min(len(h.owner), 32)])
+	digest.Write([]byte{byte(h.permissions), byte(h.permissions >> 8), byte(h.permissions >> 16), byte(h.permissions >> 24)})
+	digest.Write(h.id)
+	if h.revision >= 4 && !h.encryptMetadata {
+		digest.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	key := digest.Sum(nil)
+	if h.revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:h.keyLength])
+			key = sum[:]
+		}
+	}
+	return key[:h.keyLength]
+}
+
+// checkUserKey reports whether a file encryption key matches the /U entry
+func (h *securityHandler) checkUserKey(key []byte) bool {
+	if h.revision == 2 {
+		return bytes.Equal(rc4Crypt(key, pdfPasswordPadding), h.user[:min(len(h.user), 32)])
+	}
+	digest := md5.New()
+	digest.Write(pdfPasswordPadding)
+	digest.Write(h.id)
+	check := digest.Sum(nil)
+	for i := 0; i < 20; i++ {
+		check = rc4Crypt(xorKey(key, byte(i)), check)
+	}
+	return len(h.user) >= 16 && bytes.Equal(check, h.user[:16])
+}
+
+// aes256Key returns the file encryption key of an AES-256 PDF (revisions 5
+// and 6), or nil if the password is neither the owner nor the user password
+func (h *securityHandler) aes256Key(password []byte) []byte {
+	if len(h.owner) < 48 || len(h.user) < 48 || len(h.ownerKey) < 32 || len(h.userKey) < 32 {
+		return nil
+	}
+	password = password[:min(len(password), 127)]
+
+	var intermediate, encrypted []byte
+	switch {
+	case bytes.Equal(h.passwordHash(password, h.owner[32:40], h.user[:48]), h.owner[:32]):
+		intermediate, encrypted = h.passwordHash(password, h.owner[40:48], h.user[:48]), h.ownerKey[:32]
+	case bytes.Equal(h.passwordHash(password, h.user[32:40], nil), h.user[:32]):
+		intermediate, encrypted = h.passwordHash(password, h.user[40:48], nil), h.userKey[:32]
+	default:
+		return nil
+	}
+
+	block, err := aes.NewCipher(intermediate)
+	if err != nil {
+		return nil
+	}
+	key := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, encrypted)
+	return key
+}
+
+// passwordHash computes the hash of a password with a salt and, for the
+// owner password, the /U entry: SHA-256 for revision 5, and the iterated
+// hash of ISO 32000-2 for revision 6
+func (h *securityHandler) passwordHash(password, salt, userData []byte) []byte {
+	digest := sha256.New()
+	digest.Write(password)
+	digest.Write(salt)
+	digest.Write(userData)
+	key := digest.Sum(nil)
+	if h.revision == 5 {
+		return key
+	}
+
+	for round := 0; ; round++ {
+		block := append(append(append([]byte{}, password...), key...), userData...)
+		repeated := bytes.Repeat(block, 64)
+		aesBlock, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil
+		}
+		encrypted := make([]byte, len(repeated))
+		cipher.NewCBCEncrypter(aesBlock, key[16:32]).CryptBlocks(encrypted, repeated)
+
+		sum := 0
+		for _, b := range encrypted[:16] {
+			sum += int(b)
+		}
+		switch sum % 3 {
+		case 0:
+			digest := sha256.Sum256(encrypted)
+			key = digest[:]
+		case 1:
+			digest := sha512.Sum384(encrypted)
+			key = digest[:]
+		default:
+			digest := sha512.Sum512(encrypted)
+			key = digest[:]
+		}
+		if round >= 63 && int(encrypted[len(encrypted)-1]) <= round-31 {
+			return key[:32]
+		}
+	}
+}
+
+// decryptObject returns the decrypted contents of an object: its strings
+// and, for streams, its stream data
+func (h *securityHandler) decryptObject(file *pdfFile, num, generation int) []byte {
+	key := h.objectKey(num, generation)
+	original := file.dict(num)
+	dict := decryptStrings(original, func(data []byte) []byte {
+		return h.decrypt(h.stringCipher, key, data)
+	})
+
+	data, err := file.rawStream(num)
+	if err != nil {
+		return dict
+	}
+	if !pdfUnencryptedStreamPattern.Match(original) && (h.encryptMetadata || !pdfMetadataStreamPattern.Match(original)) {
+		data = h.decrypt(h.streamCipher, key, data)
+	}
+
+	dict = pdfLengthPattern.ReplaceAll(dict, nil)
+	dict = bytes.TrimSuffix(bytes.TrimSpace(dict), []byte(">>"))
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "\n%s /Length %d>>\nstream\n", dict, len(data))
+	body.Write(data)
+	body.WriteString("\nendstream\n")
+	return body.Bytes()
+}
+
+// objectKey derives the key of an object from the file encryption key
+func (h *securityHandler) objectKey(num, generation int) []byte {
+	if h.revision >= 5 {
+		return h.key
+	}
+	digest := md5.New()
+	digest.Write(h.key)
+	digest.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), byte(generation), byte(generation >> 8)})
+	if h.streamCipher == cipherAES || h.stringCipher == cipherAES {
+		digest.Write([]byte("sAlT"))
+	}
+	return digest.Sum(nil)[:min(len(h.key)+5, 16)]
+}
+
+// decrypt decrypts a string or stream with a cipher
+func (h *securityHandler) decrypt(method string, key, data []byte) []byte {
+	switch method {
+	case cipherRC4:
+		return rc4Crypt(key, data)
+	case cipherAES, cipher256:
+		return aesDecrypt(key, data)
+	default:
+		return data
+	}
+}
+
+// decryptStrings decrypts the strings in PDF syntax, writing them back as hex strings
+func decryptStrings(data []byte, decrypt func([]byte) []byte) []byte {
+	var out bytes.Buffer
+	lexer := &pdfLexer{data: data}
+	for lexer.pos < len(data) {
+		c := data[lexer.pos]
+		switch {
+		case c == '(' || (c == '<' && (lexer.pos+1 >= len(data) || data[lexer.pos+1] != '<')):
+			var value []byte
+			if c == '(' {
+				value = lexer.literalString()
+			} else {
+				value = lexer.hexString()
+			}
+			out.WriteString("<" + hex.EncodeToString(decrypt(value)) + ">")
+		case c == '<':
+			out.WriteString("<<")
+			lexer.pos += 2
+		default:
+			out.WriteByte(c)
+			lexer.pos++
+		}
+	}
+	return out.Bytes()
+}
+
+// padPassword pads or truncates a password to 32 bytes
+func padPassword(password []byte) []byte {
+	padded := append([]byte{}, password[:min(len(password), 32)]...)
+	return append(padded, pdfPasswordPadding[:32-len(padded)]...)
+}
+
+// xorKey returns a copy of a key with every byte XORed with a value
+func xorKey(key []byte, value byte) []byte {
+	result := make([]byte, len(key))
+	for i, b := range key {
+		result[i] = b ^ value
+	}
+	return result
+}
+
+// rc4Crypt encrypts or decrypts data with RC4
+func rc4Crypt(key, data []byte) []byte {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return data
+	}
+	result := make([]byte, len(data))
+	c.XORKeyStream(result, data)
+	return result
+}
+
+// aesDecrypt decrypts AES-CBC data that starts with its initialization
+// vector, removing the padding
+func aesDecrypt(key, data []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil || len(data) < 2*aes.BlockSize {
+		return nil
+	}
+	iv, data := data[:aes.BlockSize], data[aes.BlockSize:]
+	data = data[:len(data)-len(data)%aes.BlockSize]
+	result := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(result, data)
+	if padding := int(result[len(result)-1]); padding >= 1 && padding <= aes.BlockSize {
+		result = result[:len(result)-padding]
+	}
+	return result
+}
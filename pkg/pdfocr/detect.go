@@ -3,69 +3,46 @@ package pdfocr
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 )
 
-// detectPDFLayers attempts to find layer names in the raw PDF data.
+// layerPageSuffixPattern matches the " (Page N" that follows LayerName in
+// the name of a page's OCR layer, lenient about the spacing
+var layerPageSuffixPattern = regexp.MustCompile(`^\s*\(Page\s*(\d+)`)
+
+// detectPDFLayers returns the names of the optional content groups (layers)
+// of a PDF: those listed in the optional content properties of the catalog,
+// in their order, then any others the pages use. The objects are parsed, so
+// groups in compressed object streams and names written as escaped or
+// UTF-16 strings are found as well. Groups nothing refers to any more, such
+// as those an incremental update removed, are not part of the document.
 func detectPDFLayers(pdfData []byte) ([]string, error) {
 	if len(pdfData) == 0 {
 		return nil, fmt.Errorf("empty PDF data")
 	}
-
-	content := string(pdfData)
-	ocgPatterns := []string{
-		`/Type\s*/OCG\s*/Name\s*\(([^)]+)\)`,
-		`/Title\s*\(([^)]+)\)`,
-		`/OCG\s*<<[^>]*?/Name\s*\(([^)]+)\)`,
-		`<</Type/OCG/Name\(([^)]+)\)`,
-		`/OCProperties.*?/OCGs\s*\[\s*.*?/Name\s*\(([^)]+)\)`,
-		`/Name\s*\(([^)]+)\)[\s\S]{1,50}/Type\s*/OCG`,
+	file := parsePDFObjects(pdfData)
+	if len(file.objects) == 0 {
+		return nil, fmt.Errorf("no PDF objects found")
 	}
 
-	var layers []string
-	for _, pattern := range ocgPatterns {
-		// Use a panic recovery block for regex operations
-		var regex *regexp.Regexp
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// Log recovery but continue with other patterns
-					fmt.Printf("Warning: Regex pattern caused error: %v\n", pattern)
-				}
-			}()
-			regex = regexp.MustCompile(pattern)
-		}()
-
-		if regex != nil {
-			matches := regex.FindAllStringSubmatch(content, -1)
-			for _, match := range matches {
-				if len(match) >= 2 {
-					layers = append(layers, unescapePDFString(match[1]))
-				}
-			}
+	catalog := file.dict(file.ref(file.trailer, "Root"))
+	groups := file.refArray(file.subdict(catalog, "OCProperties"), "OCGs")
+	onPages := file.reachable([]int{file.ref(catalog, "Pages")})
+	for _, num := range file.sortedObjects() {
+		if onPages[num] && !slices.Contains(groups, num) && file.hasType(num, "OCG") {
+			groups = append(groups, num)
 		}
 	}
 
-	// Check if any are UTF-16 BOM
-	for i, layer := range layers {
-		if len(layer) >= 2 && layer[0] == '\xfe' && layer[1] == '\xff' {
-			decoded, err := decodeUTF16BE([]byte(layer))
-			if err == nil {
-				layers[i] = decoded
-			}
-		}
-	}
-
-	// Deduplicate
-	unique := make([]string, 0, len(layers))
-	seen := make(map[string]bool)
-	for _, l := range layers {
-		if !seen[l] {
-			seen[l] = true
-			unique = append(unique, l)
+	var layers []string
+	for _, num := range groups {
+		name, ok := pdfStringValue(file.dict(num), "Name")
+		if ok && !slices.Contains(layers, name) {
+			layers = append(layers, name)
 		}
 	}
-	return unique, nil
+	return layers, nil
 }
 
 // LayerCheckResult contains the results of checking for OCR layers
@@ -78,7 +55,14 @@ type LayerCheckResult struct {
 
 // CheckExistingOCRLayers checks for existing OCR layers in a PDF
 func CheckExistingOCRLayers(pdfData []byte, ocrLayerName string) (LayerCheckResult, error) {
+	return checkOCRLayers(pdfData, OCRConfig{LayerName: ocrLayerName})
+}
+
+// checkOCRLayers checks for existing layers named after config.LayerName,
+// or by config.LayerNameTemplate
+func checkOCRLayers(pdfData []byte, config OCRConfig) (LayerCheckResult, error) {
 	result := LayerCheckResult{}
+	ocrLayerName := config.LayerName
 
 	// Detect existing layers
 	layers, err := detectPDFLayers(pdfData)
@@ -88,21 +72,9 @@ func CheckExistingOCRLayers(pdfData []byte, ocrLayerName string) (LayerCheckResu
 
 	result.Layers = layers
 
-	// Create a regex to match layer names with page numbers - more lenient pattern
-	// This accounts for potential formatting issues in the PDF layer names
-	pageLayerPattern := regexp.MustCompile(fmt.Sprintf(`^%s\s*\(Page\s*\d+.*`, regexp.QuoteMeta(ocrLayerName)))
-
 	// Check for OCR layers
 	for _, layer := range layers {
-		// Check for exact match
-		if layer == ocrLayerName {
-			result.HasOCRLayer = true
-			result.OCRLayerName = layer
-			break
-		}
-
-		// Check for page-specific match with more lenient pattern
-		if pageLayerPattern.MatchString(layer) {
+		if config.isOCRLayerName(layer) {
 			result.HasOCRLayer = true
 			result.OCRLayerName = layer
 			break
@@ -119,22 +91,70 @@ func CheckExistingOCRLayers(pdfData []byte, ocrLayerName string) (LayerCheckResu
 	return result, nil
 }
 
+// isOCRLayerName reports whether a layer is named as an OCR layer: after
+// LayerName, as is or with a page number, or by LayerNameTemplate
+func (c OCRConfig) isOCRLayerName(name string) bool {
+	if name == c.LayerName || c.isTemplatedLayerName(name) {
+		return true
+	}
+	// Lenient about the spacing, which some writers change
+	rest, ok := strings.CutPrefix(name, c.LayerName)
+	return ok && layerPageSuffixPattern.MatchString(rest)
+}
+
 // OCRDetectionResult contains comprehensive OCR detection information
 type OCRDetectionResult struct {
 	HasOCR      bool // True if any OCR is detected by any method
 	HasLayerOCR bool // True if OCR layers are detected
+	// True if pages show images under nothing but invisible text, as OCR text
+	// drawn without layers (NoLayers, or other OCR tools) does
+	HasInvisibleTextOCR bool
+
+	// How the existing OCR text was made, as ocrchestra recorded it in the XMP
+	// metadata (nil if not recorded)
+	Provenance *Provenance
 
 	LayerInfo LayerCheckResult // Details from layer detection
+	Pages     []PageOCRInfo    // Text, image coverage, layers and OCR of each page
 
 	Warnings []string // Warnings from any detection method
 }
 
-// DetectOCR performs OCR detection using available methods
+// PagesNeedingOCR returns the numbers (1-based) of the pages that show
+// images but no text
+func (r OCRDetectionResult) PagesNeedingOCR() []int {
+	var pages []int
+	for _, page := range r.Pages {
+		if page.NeedsOCR() {
+			pages = append(pages, page.Page)
+		}
+	}
+	return pages
+}
+
+// PagesWithOCR returns the numbers (1-based) of the pages that have OCR
+// text, in an OCR layer or as invisible text over images
+func (r OCRDetectionResult) PagesWithOCR() []int {
+	var pages []int
+	for _, page := range r.Pages {
+		if page.HasOCR {
+			pages = append(pages, page.Page)
+		}
+	}
+	return pages
+}
+
+// DetectOCR performs OCR detection using available methods. Encrypted PDFs
+// are decrypted with config.Password first.
 func DetectOCR(pdfData []byte, config OCRConfig) (OCRDetectionResult, error) {
 	result := OCRDetectionResult{}
+	pdfData, err := decryptInput(pdfData, config)
+	if err != nil {
+		return result, err
+	}
 
 	// Check for OCR layers
-	layerResult, err := CheckExistingOCRLayers(pdfData, config.LayerName)
+	layerResult, err := checkOCRLayers(pdfData, config)
 	if err != nil {
 		result.Warnings = append(result.Warnings,
 			fmt.Sprintf("Layer detection error: %v", err))
@@ -158,9 +178,32 @@ func DetectOCR(pdfData []byte, config OCRConfig) (OCRDetectionResult, error) {
 		}
 	}
 
-	// For now, HasOCR is the same as HasLayerOCR
-	// This will be expanded when new detection methods are added
-	result.HasOCR = result.HasLayerOCR
+	if truncated := parsePDFObjects(pdfData).truncated; len(truncated) > 0 {
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("PDF objects %v are truncated and were skipped", truncated))
+	}
+
+	if provenance, ok := ReadProvenance(pdfData); ok {
+		result.Provenance = &provenance
+	}
+
+	// Report what each page shows so callers can OCR just the pages that need it
+	result.Pages = detectPageCoverage(pdfData, config)
+
+	for _, page := range result.Pages {
+		if page.InvisibleText && page.ImageCoverage > 0 {
+			result.HasInvisibleTextOCR = true
+		}
+		// The layers pages draw in are found even where their names are
+		// written in a way the layer scan above misses
+		if i := slices.IndexFunc(page.Layers, config.isOCRLayerName); i >= 0 && !result.HasLayerOCR {
+			result.HasLayerOCR = true
+			result.LayerInfo.HasOCRLayer = true
+			result.LayerInfo.OCRLayerName = page.Layers[i]
+		}
+	}
+
+	result.HasOCR = result.HasLayerOCR || result.HasInvisibleTextOCR
 
 	return result, nil
 }
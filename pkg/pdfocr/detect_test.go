@@ -0,0 +1,175 @@
+package pdfocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// testObject is an indirect object of a test PDF
+type testObject struct {
+	num  int
+	body string
+}
+
+// testPDF appends objects to a PDF, or starts a new one if base is empty,
+// followed by a cross-reference section and a trailer, as an incremental
+// update does
+func testPDF(base []byte, objects []testObject, trailer string) []byte {
+	out := bytes.NewBuffer(append([]byte{}, base...))
+	if len(base) == 0 {
+		out.WriteString("%PDF-1.5\n")
+	}
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", object.num, object.body)
+	}
+	xref := out.Len()
+	out.WriteString("xref\n")
+	for i, object := range objects {
+		fmt.Fprintf(out, "%d 1\n%010d 00000 n \n", object.num, offsets[i])
+	}
+	fmt.Fprintf(out, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+	return out.Bytes()
+}
+
+// testObjectStream returns a compressed object stream holding objects
+func testObjectStream(num int, objects []testObject) testObject {
+	var header, body bytes.Buffer
+	for _, object := range objects {
+		fmt.Fprintf(&header, "%d %d ", object.num, body.Len())
+		body.WriteString(object.body + "\n")
+	}
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(header.Bytes())
+	writer.Write(body.Bytes())
+	writer.Close()
+	return testObject{num, fmt.Sprintf("<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+		len(objects), header.Len(), compressed.Len(), compressed.Bytes())}
+}
+
+// testContent returns a content stream object
+func testContent(num int, content string) testObject {
+	return testObject{num, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)}
+}
+
+const (
+	testLayerContent = "/OC /oc1 BDC BT 3 Tr /F1 12 Tf 10 10 Td (Hello) Tj ET EMC"
+	testFont         = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+	testTrailer      = "<< /Root 1 0 R /Size 11 >>"
+)
+
+// testLayeredPDF returns a one-page PDF whose text is drawn in an OCR layer
+func testLayeredPDF() []byte {
+	return testPDF(nil, []testObject{
+		{1, "<< /Type /Catalog /Pages 2 0 R /OCProperties << /OCGs [6 0 R] /D << /Order [6 0 R] >> >> >>"},
+		{2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		{3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /Font << /F1 7 0 R >> /Properties << /oc1 6 0 R >> >> /Contents 4 0 R >>"},
+		testContent(4, testLayerContent),
+		{6, "<< /Type /OCG /Name (OCR Text \\(Page 1\\)) >>"},
+		{7, testFont},
+	}, testTrailer)
+}
+
+func TestDetectPDFLayersObjectStream(t *testing.T) {
+	pdf := testPDF(nil, []testObject{
+		{1, "<< /Type /Catalog /Pages 2 0 R /Outlines 8 0 R /OCProperties << /OCGs [6 0 R 10 0 R] /D << /Order [6 0 R 10 0 R] >> >> >>"},
+		{2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		{3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /Font << /F1 7 0 R >> /Properties << /oc1 6 0 R >> >> /Contents 4 0 R >>"},
+		testContent(4, testLayerContent),
+		testObjectStream(5, []testObject{
+			{6, "<< /Type /OCG /Name (OCR Text \\(Page 1\\)) >>"},
+			{7, testFont},
+			{9, "<< /Title (Not a layer) /Parent 8 0 R >>"},
+			// "Ébauche" as a UTF-16 text string
+			{10, "<< /Type /OCG /Name <FEFF00C9006200610075006300680065> >>"},
+		}),
+		{8, "<< /Type /Outlines /First 9 0 R /Last 9 0 R /Count 1 >>"},
+	}, testTrailer)
+
+	layers, err := detectPDFLayers(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"OCR Text (Page 1)", "Ébauche"}; !slices.Equal(layers, want) {
+		t.Errorf("layers = %q, want %q", layers, want)
+	}
+
+	result, err := DetectOCR(pdf, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.HasLayerOCR || result.LayerInfo.OCRLayerName != "OCR Text (Page 1)" {
+		t.Errorf("HasLayerOCR = %v, OCRLayerName = %q, want the OCR layer", result.HasLayerOCR, result.LayerInfo.OCRLayerName)
+	}
+	if len(result.Pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(result.Pages))
+	}
+	page := result.Pages[0]
+	if !page.HasOCR || !slices.Equal(page.Layers, []string{"OCR Text (Page 1)"}) || !slices.Equal(page.Fonts, []string{"Helvetica"}) {
+		t.Errorf("page = %+v, want OCR in layer %q with font Helvetica", page, "OCR Text (Page 1)")
+	}
+}
+
+func TestDetectPDFLayersIncrementalUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update []testObject
+		want   []string
+	}{
+		{
+			name: "no update",
+			want: []string{"OCR Text (Page 1)"},
+		},
+		{
+			// The update drops the layer from the catalog and the page, leaving
+			// the old group object in the file
+			name: "layer removed",
+			update: []testObject{
+				{1, "<< /Type /Catalog /Pages 2 0 R >>"},
+				{3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /Font << /F1 7 0 R >> >> /Contents 4 0 R >>"},
+				testContent(4, "BT /F1 12 Tf 10 10 Td (Hello) Tj ET"),
+			},
+		},
+		{
+			// The update replaces the group with one in an object stream
+			name: "layer renamed in object stream",
+			update: []testObject{
+				testObjectStream(10, []testObject{{6, "<< /Type /OCG /Name (Renamed layer) >>"}}),
+			},
+			want: []string{"Renamed layer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := testLayeredPDF()
+			if tt.update != nil {
+				pdf = testPDF(pdf, tt.update, "<< /Root 1 0 R /Size 11 /Prev 9 >>")
+			}
+
+			layers, err := detectPDFLayers(pdf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(layers, tt.want) {
+				t.Errorf("layers = %q, want %q", layers, tt.want)
+			}
+
+			result, err := DetectOCR(pdf, DefaultConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.Pages[0].Layers; !slices.Equal(got, tt.want) {
+				t.Errorf("page layers = %q, want %q", got, tt.want)
+			}
+			if want := slices.Contains(tt.want, "OCR Text (Page 1)"); result.HasLayerOCR != want {
+				t.Errorf("HasLayerOCR = %v, want %v", result.HasLayerOCR, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package pdfocr
+
+import "errors"
+
+// Errors returned by the pdfocr functions. They are wrapped with details
+// about the failure, so check for them with errors.Is rather than by
+// comparing error text.
+var (
+	// ErrAlreadyHasOCR means the input PDF already has OCR and Strict is set without Force
+	ErrAlreadyHasOCR = errors.New("file already has OCR")
+	// ErrOCRDetectionFailed means the input PDF could not be checked for existing OCR
+	// and Strict is set without Force
+	ErrOCRDetectionFailed = errors.New("OCR detection failed")
+	// ErrEmptyPDF means no PDF data was provided
+	ErrEmptyPDF = errors.New("input PDF data is empty")
+	// ErrNoPages means the hOCR data or the PDF contains no pages
+	ErrNoPages = errors.New("no pages")
+	// ErrNoImages means no image data was provided
+	ErrNoImages = errors.New("no image data provided")
+	// ErrImageCountMismatch means there are fewer images than hOCR pages
+	ErrImageCountMismatch = errors.New("not enough images for HOCR pages")
+	// ErrInvalidImage means an image is empty or not in a supported format
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrInvalidPageRange means StartPage or a page range does not select valid pages
+	ErrInvalidPageRange = errors.New("invalid page range")
+	// ErrInvalidHOCR means the hOCR input could not be parsed or has an unsupported type
+	ErrInvalidHOCR = errors.New("invalid HOCR input")
+	// ErrEncodingIssues means too many words needed an encoding fallback and FailOnEncodingIssues is set
+	ErrEncodingIssues = errors.New("character encoding issues")
+	// ErrNotScanned means a PDF page is not made of a single scanned image
+	ErrNotScanned = errors.New("PDF is not a scan")
+	// ErrEncryptedPDF means the input PDF is encrypted in a way that is not
+	// supported, or was passed encrypted to a function that doesn't decrypt
+	ErrEncryptedPDF = errors.New("encrypted PDFs are not supported")
+	// ErrIncorrectPassword means OCRConfig.Password (or the empty password if
+	// unset) opens neither as the user nor as the owner of an encrypted PDF
+	ErrIncorrectPassword = errors.New("incorrect PDF password")
+)
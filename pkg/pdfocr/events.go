@@ -0,0 +1,34 @@
+package pdfocr
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Structured events emitted through OCRConfig.EventLogger. Every record
+// carries the event name in its "event" attribute, so handlers can filter
+// on it instead of matching message text.
+const (
+	EventOCRDetected   = "ocr_detected"   // Existing OCR was found in the input PDF (warn, info in Replace and SkipPagesWithText mode)
+	EventOCRRemoved    = "ocr_removed"    // Existing OCR layers were stripped (info)
+	EventLayerAdded    = "layer_added"    // An OCR layer was drawn on a page (info)
+	EventPagesSkipped  = "pages_skipped"  // Pages that already show text were left without OCR in SkipPagesWithText mode (info)
+	EventEncodingError = "encoding_error" // A word needed an encoding fallback (warn)
+	EventWarning       = "warning"        // Any other warning raised while processing (warn)
+)
+
+// logEvent emits a structured event through the configured slog logger, if any.
+// Events are emitted regardless of LogWarnings, which only controls the text output.
+func logEvent(config OCRConfig, level slog.Level, event, msg string, args ...any) {
+	if config.EventLogger == nil {
+		return
+	}
+	config.EventLogger.Log(context.Background(), level, msg, append([]any{slog.String("event", event)}, args...)...)
+}
+
+// logWarningEvents emits a warning event for each warning
+func logWarningEvents(config OCRConfig, warnings []string) {
+	for _, warning := range warnings {
+		logEvent(config, slog.LevelWarn, EventWarning, warning)
+	}
+}
@@ -0,0 +1,563 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// Thresholds for grouping extracted glyphs, relative to the font size
+const (
+	wordGapRatio      = 0.15 // Horizontal gap that separates words
+	lineGapRatio      = 2.0  // Horizontal gap that separates lines on the same baseline, e.g. columns
+	baselineDiffRatio = 0.5  // Vertical offset of glyphs still on the same line
+	paragraphGapRatio = 1.7  // Largest distance between the baselines of lines in a paragraph
+)
+
+// Font metrics used when a font doesn't provide its own, in thousandths of the font size
+const (
+	defaultGlyphWidth = 500
+	defaultAscent     = 800
+	defaultDescent    = -200
+)
+
+// ExtractHOCR extracts the text of a digitally created PDF with the position
+// of every word and converts it to hOCR, so pages that already have text can
+// be handled like OCR output without running OCR on them. Glyphs are grouped
+// into words at spaces and gaps, words into lines along their baseline and
+// lines into paragraphs by their spacing. Coordinates are in PDF points from
+// the top-left corner of the page's media box, and every word has a
+// confidence of 100. Pages without text are returned without content.
+func ExtractHOCR(pdfData []byte) (*hocr.HOCR, error) {
+	if len(pdfData) == 0 {
+		return nil, ErrEmptyPDF
+	}
+	file := parsePDFObjects(pdfData)
+	if bytes.Contains(file.trailer, []byte("/Encrypt")) {
+		return nil, ErrEncryptedPDF
+	}
+	pages := file.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF has %w", ErrNoPages)
+	}
+
+	doc := &hocr.HOCR{
+		Title: "PDF text",
+		Metadata: map[string]string{
+			"ocr-system":          "ocrchestra pdfocr text extraction",
+			"ocr-number-of-pages": strconv.Itoa(len(pages)),
+			"ocr-capabilities":    "ocr_page ocr_par ocr_line ocrx_word",
+		},
+	}
+	extractor := &textExtractor{
+		file:    file,
+		fonts:   make(map[int]*pdfFontDecoder),
+		metrics: make(map[int]*pdfFontMetrics),
+	}
+	for i, page := range pages {
+		x, y, width, height := file.mediaBoxRect(page)
+		var content []byte
+		for _, num := range pdfReferenceList(file.dict(page), "Contents") {
+			data, err := file.stream(num)
+			if err != nil {
+				return nil, fmt.Errorf("page %d: %w", i+1, err)
+			}
+			content = append(content, data...)
+			content = append(content, '\n')
+		}
+		extractor.glyphs = nil
+		extractor.scan(content, file.resources(page), [6]float64{1, 0, 0, 1, -x, -y}, 0)
+
+		doc.Pages = append(doc.Pages, hocr.Page{
+			ID:         fmt.Sprintf("page_%d", i+1),
+			PageNumber: i + 1,
+			BBox:       hocr.NewBoundingBox(0, 0, roundPoints(width), roundPoints(height)),
+			Paragraphs: groupGlyphs(extractor.glyphs, i+1, height),
+			Metadata:   make(map[string]string),
+		})
+	}
+	return doc, nil
+}
+
+// extractedGlyph is a character shown on a page, in default user space
+type extractedGlyph struct {
+	text   string
+	origin [2]float64 // Start of the glyph on its baseline
+	end    [2]float64 // Start of the next glyph, without character and word spacing
+	box    [4]float64 // Bounding box: left, bottom, right, top
+	angle  float64    // Direction of the baseline in degrees counter-clockwise
+	size   float64    // Font size
+}
+
+// along returns the position of a point along the glyph's baseline direction
+func (g extractedGlyph) along(point [2]float64) float64 {
+	sin, cos := math.Sincos(g.angle * math.Pi / 180)
+	return point[0]*cos + point[1]*sin
+}
+
+// across returns the position of a point perpendicular to the glyph's
+// baseline direction, increasing upwards
+func (g extractedGlyph) across(point [2]float64) float64 {
+	sin, cos := math.Sincos(g.angle * math.Pi / 180)
+	return point[1]*cos - point[0]*sin
+}
+
+// textExtractor walks the content of a page and collects the glyphs it shows
+type textExtractor struct {
+	file    *pdfFile
+	fonts   map[int]*pdfFontDecoder
+	metrics map[int]*pdfFontMetrics
+	glyphs  []extractedGlyph
+}
+
+// textState holds the graphics state parameters that affect text
+type textState struct {
+	ctm         [6]float64
+	font        int
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	scale       float64 // Horizontal scaling, 1 for 100%
+	leading     float64
+	rise        float64
+}
+
+// scan walks a content stream drawn with the given resources and
+// transformation matrix, collecting the glyphs it shows
+func (e *textExtractor) scan(content, resources []byte, ctm [6]float64, depth int) {
+	fontRefs := e.file.refs(e.file.subdict(resources, "Font"))
+	xobjects := e.file.refs(e.file.subdict(resources, "XObject"))
+
+	state := textState{ctm: ctm, scale: 1}
+	var saved []textState
+	var operands []pdfToken
+	identity := [6]float64{1, 0, 0, 1, 0, 0}
+	textMatrix, lineMatrix := identity, identity
+
+	number := func(i int) float64 {
+		if i >= len(operands) || operands[i].kind != pdfNumber {
+			return 0
+		}
+		value, _ := strconv.ParseFloat(operands[i].text, 64)
+		return value
+	}
+	moveLine := func(tx, ty float64) {
+		lineMatrix = multiplyMatrix([6]float64{1, 0, 0, 1, tx, ty}, lineMatrix)
+		textMatrix = lineMatrix
+	}
+	show := func(text []byte) {
+		decoder, metrics := e.font(state.font)
+		for i := 0; i+decoder.codeLength <= len(text); i += decoder.codeLength {
+			code := text[i : i+decoder.codeLength]
+			width := metrics.width(cmapCode(code)) / 1000
+			glyph := multiplyMatrix(multiplyMatrix(
+				[6]float64{state.fontSize * state.scale, 0, 0, state.fontSize, 0, state.rise}, textMatrix), state.ctm)
+			e.addGlyph(decoder.decode(code), glyph, width, metrics)
+
+			advance := width*state.fontSize + state.charSpacing
+			if decoder.codeLength == 1 && code[0] == ' ' {
+				advance += state.wordSpacing
+			}
+			textMatrix = multiplyMatrix([6]float64{1, 0, 0, 1, advance * state.scale, 0}, textMatrix)
+		}
+	}
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch token.text {
+		case "q":
+			saved = append(saved, state)
+		case "Q":
+			if len(saved) > 0 {
+				state, saved = saved[len(saved)-1], saved[:len(saved)-1]
+			}
+		case "cm":
+			if m, ok := pdfMatrixOperands(operands); ok {
+				state.ctm = multiplyMatrix(m, state.ctm)
+			}
+		case "BT":
+			textMatrix, lineMatrix = identity, identity
+		case "Tm":
+			if m, ok := pdfMatrixOperands(operands); ok {
+				textMatrix, lineMatrix = m, m
+			}
+		case "Td":
+			moveLine(number(0), number(1))
+		case "TD":
+			state.leading = -number(1)
+			moveLine(number(0), number(1))
+		case "T*":
+			moveLine(0, -state.leading)
+		case "Tc":
+			state.charSpacing = number(0)
+		case "Tw":
+			state.wordSpacing = number(0)
+		case "Tz":
+			state.scale = number(0) / 100
+		case "TL":
+			state.leading = number(0)
+		case "Ts":
+			state.rise = number(0)
+		case "Tf":
+			if len(operands) >= 2 && operands[0].kind == pdfName {
+				state.font = fontRefs[operands[0].text]
+				state.fontSize = number(1)
+			}
+		case "Tj", "'", "\"":
+			if token.text == "\"" {
+				state.wordSpacing, state.charSpacing = number(0), number(1)
+			}
+			if token.text != "Tj" {
+				moveLine(0, -state.leading)
+			}
+			if len(operands) > 0 && operands[len(operands)-1].kind == pdfString {
+				show(operands[len(operands)-1].bytes)
+			}
+		case "TJ":
+			if len(operands) == 0 || operands[0].kind != pdfArray {
+				break
+			}
+			for _, item := range operands[0].items {
+				switch item.kind {
+				case pdfString:
+					show(item.bytes)
+				case pdfNumber:
+					adjustment, _ := strconv.ParseFloat(item.text, 64)
+					textMatrix = multiplyMatrix([6]float64{1, 0, 0, 1, -adjustment / 1000 * state.fontSize * state.scale, 0}, textMatrix)
+				}
+			}
+		case "Do":
+			if len(operands) == 1 && operands[0].kind == pdfName {
+				e.drawForm(xobjects[operands[0].text], resources, state.ctm, depth)
+			}
+		case "ID":
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// drawForm scans the content of a form XObject
+func (e *textExtractor) drawForm(num int, resources []byte, ctm [6]float64, depth int) {
+	if num == 0 || depth >= maxFormDepth {
+		return
+	}
+	dict := e.file.dict(num)
+	if !pdfFormPattern.Match(dict) {
+		return
+	}
+	content, err := e.file.stream(num)
+	if err != nil {
+		return
+	}
+	if matrix, ok := pdfDictMatrix(dict, "Matrix"); ok {
+		ctm = multiplyMatrix(matrix, ctm)
+	}
+	// Forms without their own resources use those of the page
+	if own := e.file.subdict(dict, "Resources"); own != nil {
+		resources = own
+	}
+	e.scan(content, resources, ctm, depth+1)
+}
+
+// addGlyph records a glyph drawn with the given glyph space matrix, where
+// width is its advance in units of the font size
+func (e *textExtractor) addGlyph(text string, m [6]float64, width float64, metrics *pdfFontMetrics) {
+	point := func(x, y float64) [2]float64 {
+		return [2]float64{x*m[0] + y*m[2] + m[4], x*m[1] + y*m[3] + m[5]}
+	}
+	glyph := extractedGlyph{
+		text:   text,
+		origin: point(0, 0),
+		end:    point(width, 0),
+		angle:  math.Round(math.Atan2(m[1], m[0]) * 180 / math.Pi),
+		size:   math.Hypot(m[2], m[3]),
+	}
+	if glyph.size == 0 {
+		return
+	}
+
+	glyph.box = [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, corner := range [][2]float64{
+		point(0, metrics.descent/1000), point(width, metrics.descent/1000),
+		point(0, metrics.ascent/1000), point(width, metrics.ascent/1000),
+	} {
+		glyph.box[0], glyph.box[1] = min(glyph.box[0], corner[0]), min(glyph.box[1], corner[1])
+		glyph.box[2], glyph.box[3] = max(glyph.box[2], corner[0]), max(glyph.box[3], corner[1])
+	}
+	e.glyphs = append(e.glyphs, glyph)
+}
+
+// font returns the decoder and metrics of a font, falling back to
+// single-byte codes and default widths for fonts that can't be read
+func (e *textExtractor) font(num int) (*pdfFontDecoder, *pdfFontMetrics) {
+	decoder, ok := e.fonts[num]
+	if !ok {
+		var err error
+		decoder, err = newPDFFontDecoder(e.file, num)
+		switch {
+		case num == 0:
+			decoder = &pdfFontDecoder{codeLength: 1}
+		case err != nil && bytes.Contains(e.file.dict(num), []byte("/Type0")):
+			// Composite fonts without a ToUnicode CMap show unknown characters
+			decoder = &pdfFontDecoder{codeLength: 2, toUnicode: map[uint32]string{}}
+		case err != nil:
+			decoder = &pdfFontDecoder{codeLength: 1}
+		}
+		e.fonts[num] = decoder
+	}
+	metrics, ok := e.metrics[num]
+	if !ok {
+		metrics = newPDFFontMetrics(e.file, num)
+		e.metrics[num] = metrics
+	}
+	return decoder, metrics
+}
+
+// multiplyMatrix returns the product of two PDF transformation matrices
+func multiplyMatrix(a, b [6]float64) [6]float64 {
+	return [6]float64{
+		a[0]*b[0] + a[1]*b[2],
+		a[0]*b[1] + a[1]*b[3],
+		a[2]*b[0] + a[3]*b[2],
+		a[2]*b[1] + a[3]*b[3],
+		a[4]*b[0] + a[5]*b[2] + b[4],
+		a[4]*b[1] + a[5]*b[3] + b[5],
+	}
+}
+
+// pdfFontMetrics holds the glyph widths and vertical extent of a font, in
+// thousandths of the font size
+type pdfFontMetrics struct {
+	widths       map[uint32]float64
+	defaultWidth float64
+	ascent       float64
+	descent      float64
+}
+
+// newPDFFontMetrics reads the widths of a simple font from its Widths array,
+// or those of a composite font from the W array of its descendant font
+func newPDFFontMetrics(file *pdfFile, num int) *pdfFontMetrics {
+	metrics := &pdfFontMetrics{
+		widths:       make(map[uint32]float64),
+		defaultWidth: defaultGlyphWidth,
+		ascent:       defaultAscent,
+		descent:      defaultDescent,
+	}
+	if num == 0 {
+		return metrics
+	}
+	dict := file.dict(num)
+
+	if descendants := pdfReferenceList(dict, "DescendantFonts"); len(descendants) > 0 {
+		dict = file.dict(descendants[0])
+		metrics.defaultWidth = 1000
+		if width, ok := pdfNumberValue(dict, "DW"); ok {
+			metrics.defaultWidth = width
+		}
+		items := file.array(dict, "W")
+		for i := 0; i+1 < len(items); {
+			first, _ := strconv.ParseFloat(items[i].text, 64)
+			if items[i+1].kind == pdfArray {
+				for j, width := range items[i+1].items {
+					metrics.widths[uint32(first)+uint32(j)], _ = strconv.ParseFloat(width.text, 64)
+				}
+				i += 2
+				continue
+			}
+			if i+2 >= len(items) {
+				break
+			}
+			last, _ := strconv.ParseFloat(items[i+1].text, 64)
+			width, _ := strconv.ParseFloat(items[i+2].text, 64)
+			for code := uint32(first); code <= uint32(last) && code-uint32(first) < 0x10000; code++ {
+				metrics.widths[code] = width
+			}
+			i += 3
+		}
+	} else {
+		firstChar := file.intValue(dict, "FirstChar")
+		for i, width := range file.array(dict, "Widths") {
+			metrics.widths[uint32(firstChar+i)], _ = strconv.ParseFloat(width.text, 64)
+		}
+	}
+
+	descriptor := file.subdict(dict, "FontDescriptor")
+	if width, ok := pdfNumberValue(descriptor, "MissingWidth"); ok && width > 0 {
+		metrics.defaultWidth = width
+	}
+	ascent, hasAscent := pdfNumberValue(descriptor, "Ascent")
+	descent, hasDescent := pdfNumberValue(descriptor, "Descent")
+	if hasAscent && hasDescent && ascent > descent {
+		metrics.ascent, metrics.descent = ascent, min(descent, 0)
+	}
+	return metrics
+}
+
+// width returns the width of a character code
+func (m *pdfFontMetrics) width(code uint32) float64 {
+	if width, ok := m.widths[code]; ok && width > 0 {
+		return width
+	}
+	return m.defaultWidth
+}
+
+// pdfNumberValue returns the number stored directly under a key
+func pdfNumberValue(dict []byte, key string) (float64, bool) {
+	match := pdfKeySubmatch(dict, key, `\s+([-+]?(?:\d+\.?\d*|\.\d+))(\s+\d+\s+R)?`)
+	if match == nil || len(match[2]) > 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(string(match[1]), 64)
+	return value, err == nil
+}
+
+// array returns the elements of the array stored under a key, resolving a reference to it
+func (f *pdfFile) array(dict []byte, key string) []pdfToken {
+	data := dict
+	if num := f.ref(dict, key); num > 0 {
+		data = f.dict(num)
+	} else if loc := pdfKeyIndex(dict, key, `\s*\[`); loc != nil {
+		data = dict[loc[1]-1:]
+	} else {
+		return nil
+	}
+	lexer := &pdfLexer{data: data}
+	token, ok := lexer.next()
+	if !ok || token.kind != pdfArray {
+		return nil
+	}
+	return token.items
+}
+
+// extractedWord is a run of glyphs without spaces or gaps between them
+type extractedWord struct {
+	glyphs []extractedGlyph
+	text   strings.Builder
+}
+
+// groupGlyphs groups the glyphs of a page, in the order they are drawn, into
+// words, lines and paragraphs with hOCR coordinates
+func groupGlyphs(glyphs []extractedGlyph, pageNum int, pageHeight float64) []hocr.Paragraph {
+	// Split into words at whitespace, gaps and jumps
+	var words []*extractedWord
+	var word *extractedWord
+	for _, glyph := range glyphs {
+		if strings.TrimFunc(glyph.text, unicode.IsSpace) == "" {
+			word = nil
+			continue
+		}
+		if word != nil {
+			last := word.glyphs[len(word.glyphs)-1]
+			gap := glyph.along(glyph.origin) - last.along(last.end)
+			if glyph.angle != last.angle || gap > wordGapRatio*last.size || gap < -baselineDiffRatio*last.size ||
+				math.Abs(glyph.across(glyph.origin)-last.across(last.origin)) > baselineDiffRatio*last.size {
+				word = nil
+			}
+		}
+		if word == nil {
+			word = &extractedWord{}
+			words = append(words, word)
+		}
+		word.glyphs = append(word.glyphs, glyph)
+		word.text.WriteString(glyph.text)
+	}
+
+	// Join words that continue along the same baseline into lines, and lines
+	// that follow each other closely into paragraphs
+	var paragraphs [][][]*extractedWord
+	for _, word := range words {
+		first := word.glyphs[0]
+		if len(paragraphs) > 0 {
+			lines := paragraphs[len(paragraphs)-1]
+			line := lines[len(lines)-1]
+			lastWord := line[len(line)-1]
+			last := lastWord.glyphs[len(lastWord.glyphs)-1]
+			gap := first.along(first.origin) - last.along(last.end)
+			if first.angle == last.angle && gap > -baselineDiffRatio*last.size && gap < lineGapRatio*last.size &&
+				math.Abs(first.across(first.origin)-last.across(last.origin)) <= baselineDiffRatio*last.size {
+				lines[len(lines)-1] = append(line, word)
+				continue
+			}
+			lineStart := line[0].glyphs[0]
+			step := lineStart.across(lineStart.origin) - first.across(first.origin)
+			if first.angle == lineStart.angle && step > 0 && step <= paragraphGapRatio*lineStart.size &&
+				math.Abs(first.along(first.origin)-lineStart.along(lineStart.origin)) <= lineGapRatio*lineStart.size {
+				paragraphs[len(paragraphs)-1] = append(lines, []*extractedWord{word})
+				continue
+			}
+		}
+		paragraphs = append(paragraphs, [][]*extractedWord{{word}})
+	}
+
+	// toHOCR converts a box in user space to hOCR coordinates
+	toHOCR := func(box [4]float64) hocr.BoundingBox {
+		return hocr.NewBoundingBox(roundPoints(box[0]), roundPoints(pageHeight-box[3]),
+			roundPoints(box[2]), roundPoints(pageHeight-box[1]))
+	}
+	union := func(a, b [4]float64) [4]float64 {
+		return [4]float64{min(a[0], b[0]), min(a[1], b[1]), max(a[2], b[2]), max(a[3], b[3])}
+	}
+
+	var result []hocr.Paragraph
+	wordNum, lineNum := 0, 0
+	for p, lines := range paragraphs {
+		paragraph := hocr.Paragraph{ID: fmt.Sprintf("par_%d_%d", pageNum, p+1), Metadata: make(map[string]string)}
+		paragraphBox := lines[0][0].glyphs[0].box
+		for _, lineWords := range lines {
+			lineNum++
+			line := hocr.Line{ID: fmt.Sprintf("line_%d_%d", pageNum, lineNum), Metadata: make(map[string]string)}
+			lineBox := lineWords[0].glyphs[0].box
+			var baseline, glyphCount float64
+			for _, extracted := range lineWords {
+				box := extracted.glyphs[0].box
+				for _, glyph := range extracted.glyphs {
+					box = union(box, glyph.box)
+					baseline += glyph.origin[1]
+					glyphCount++
+				}
+				lineBox = union(lineBox, box)
+				wordNum++
+				line.Words = append(line.Words, hocr.Word{
+					ID:         fmt.Sprintf("word_%d_%d", pageNum, wordNum),
+					Text:       extracted.text.String(),
+					BBox:       toHOCR(box),
+					Confidence: 100,
+					Metadata:   make(map[string]string),
+				})
+			}
+			line.BBox = toHOCR(lineBox)
+			paragraphBox = union(paragraphBox, lineBox)
+
+			// Upright lines get their exact baseline, others their angle
+			if angle := lineWords[0].glyphs[0].angle; angle != 0 {
+				line.Metadata["textangle"] = strconv.FormatFloat(math.Mod(angle+360, 360), 'f', -1, 64)
+			} else {
+				line.Baseline = fmt.Sprintf("0 %g", roundPoints(lineBox[1]-baseline/glyphCount))
+			}
+			paragraph.Lines = append(paragraph.Lines, line)
+		}
+		paragraph.BBox = toHOCR(paragraphBox)
+		result = append(result, paragraph)
+	}
+	return result
+}
+
+// roundPoints rounds a coordinate in points to two decimals
+func roundPoints(value float64) float64 {
+	return math.Round(value*100) / 100
+}
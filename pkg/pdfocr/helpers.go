@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
 )
 
 // normalizeCoords rescales hOCR Bounding Box (bbox) coords to the PDF coords.
@@ -15,36 +14,6 @@ func normalizeCoords(x, y, hocrW, hocrH, pdfW, pdfH float64) (float64, float64)
 	return nx, ny
 }
 
-func unescapePDFString(s string) string {
-	s = strings.ReplaceAll(s, "\\(", "(")
-	s = strings.ReplaceAll(s, "\\)", ")")
-	s = strings.ReplaceAll(s, "\\\\", "\\")
-	return s
-}
-
-func decodeUTF16BE(b []byte) (string, error) {
-	if len(b) < 2 {
-		return "", fmt.Errorf("input too short for UTF-16BE")
-	}
-	hasBOM := false
-	if b[0] == 0xFE && b[1] == 0xFF {
-		hasBOM = true
-		b = b[2:]
-	}
-	if !hasBOM {
-		return "", fmt.Errorf("no BOM detected, cannot confirm UTF-16BE")
-	}
-	var runes []rune
-	for i := 0; i < len(b); i += 2 {
-		if i+1 >= len(b) {
-			break
-		}
-		charCode := uint16(b[i])<<8 | uint16(b[i+1])
-		runes = append(runes, rune(charCode))
-	}
-	return string(runes), nil
-}
-
 // getLogger returns the appropriate io.Writer to use for logging
 // based on the configuration settings, defaulting to os.Stdout if nil.
 func getLogger(config OCRConfig) io.Writer {
@@ -2,143 +2,536 @@ package pdfocr
 
 import (
 	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"strings"
+	"unicode/utf16"
 
 	"codeberg.org/go-pdf/fpdf"
+	"github.com/anyascii/go"
 	"golang.org/x/text/encoding/charmap"
 
 	"github.com/gardar/ocrchestra/pkg/hocr"
 )
 
-// drawOCRLayer draws the OCR text onto a layer in a pdf page.
-// The pageNum parameter is used to create unique layer names for each page.
+// PDF text rendering modes (Tr operator)
+const (
+	textRenderFill      = 0 // Fill glyphs (normal visible text)
+	textRenderInvisible = 3 // Neither fill nor stroke glyphs
+)
+
+// drawOCRLayer draws the OCR text onto a layer in a pdf page, or straight
+// into the page content with NoLayers.
+// The pageNum parameter is used to create unique layer names for each page,
+// along with the engine that produced the hOCR where the name template has it.
+// Words below MinWordConfidence are left out, or drawn onto a separate hidden
+// layer with LowConfidenceLayer (unless NoLayers is set).
 func drawOCRLayer(
 	pdf *fpdf.Fpdf,
 	page hocr.Page,
-	debug bool,
-	layerName string,
 	pageNum int,
+	engine string,
 	transform func(x, y float64) (float64, float64),
-	fontConfig FontConfig,
+	config OCRConfig,
+	result *ApplyResult,
 ) error {
-	// Format layer name with page number if not already included
-	formattedLayerName := layerName
-	if pageNum > 0 {
-		formattedLayerName = fmt.Sprintf("%s (Page %d)", layerName, pageNum)
+	formattedLayerName := config.pageLayerName(pageNum, engine)
+
+	allWords := layerWords(page)
+	words, lowConfidence := splitByConfidence(allWords, config.MinWordConfidence)
+
+	state, err := drawWordLayer(pdf, formattedLayerName, true, words, pageNum, transform, config, result)
+	if err != nil {
+		return err
+	}
+	drawn := state.wordCount - state.skippedWords
+
+	if config.LowConfidenceLayer && !config.NoLayers && len(lowConfidence) > 0 {
+		// Hidden by default, so viewers neither show nor search it until it is switched on
+		lowState, err := drawWordLayer(pdf, config.pageLowConfidenceLayerName(pageNum, engine), false,
+			lowConfidence, pageNum, transform, config, result)
+		if err != nil {
+			return err
+		}
+		state.wordCount += lowState.wordCount
+		state.encodingErrors += lowState.encodingErrors
+	}
+
+	if result != nil {
+		result.PageCount++
+		result.WordCount += drawn
+		result.LowConfidenceWords += len(lowConfidence)
+		result.PageConfidence = append(result.PageConfidence, pageConfidence(pageNum, allWords))
+	}
+	logEvent(config, slog.LevelInfo, EventLayerAdded, "OCR layer added",
+		"page", pageNum, "layer", formattedLayerName, "words", drawn, "low_confidence_words", len(lowConfidence))
+
+	// Report encoding issues if more than a threshold
+	if state.wordCount > 0 && state.encodingErrors > 0 && state.encodingErrors > state.wordCount/10 {
+		return fmt.Errorf("%w in %d of %d words (fallback: %s)",
+			ErrEncodingIssues, state.encodingErrors, state.wordCount, encodingFallbackOf(config))
 	}
 
-	layer := pdf.AddLayer(formattedLayerName, true)
-	pdf.BeginLayer(layer)
-	pdf.SetFont(fontConfig.Name, fontConfig.Style, fontConfig.Size)
+	return nil
+}
+
+// drawWordLayer draws words onto a new layer of the current page, or onto the
+// page itself with NoLayers, and returns the rendering state with the word counts
+func drawWordLayer(
+	pdf *fpdf.Fpdf,
+	name string,
+	visible bool,
+	words []hocr.Word,
+	pageNum int,
+	transform func(x, y float64) (float64, float64),
+	config OCRConfig,
+	result *ApplyResult,
+) (*wordState, error) {
+	fontConfig := config.Font
+
+	if !config.NoLayers {
+		layer := pdf.AddLayer(name, visible)
+		pdf.BeginLayer(layer)
+	}
+	unicode, err := selectLayerFont(pdf, words, config)
+	if err != nil {
+		pdf.EndLayer()
+		return nil, err
+	}
 
-	if debug {
+	if config.Debug {
 		pdf.SetTextColor(255, 0, 0) // highlight text in red
 	} else {
-		pdf.SetAlpha(0.0, "Normal") // hide text from normal view
+		// Render mode 3 neither fills nor strokes the glyphs, so the text is invisible
+		// but still selectable and searchable, even in viewers that flatten transparency
+		pdf.SetTextRenderingMode(textRenderInvisible)
+	}
+
+	family := fontConfig.Name
+	if unicode {
+		family = unicodeFontFamily
+	}
+	metrics := newFontMetrics(pdf, fontConfig, family, unicode)
+	state := &wordState{
+		pageNum:      pageNum,
+		config:       config,
+		result:       result,
+		metrics:      metrics,
+		layerMetrics: metrics,
+	}
+
+	for _, word := range words {
+		drawWord(pdf, word, transform, state)
+	}
+
+	state.switchFont(state.layerMetrics)
+	state.metrics.setSize(pdf, fontConfig.Size)
+	state.metrics.setScale(pdf, 100)
+	if !config.Debug {
+		pdf.SetTextRenderingMode(textRenderFill)
+	}
+	pdf.EndLayer()
+
+	return state, nil
+}
+
+// pageConfidence summarizes the confidence of the words of a page
+func pageConfidence(pageNum int, words []hocr.Word) PageConfidence {
+	summary := PageConfidence{Page: pageNum}
+	var total float64
+	for _, word := range words {
+		if !word.ConfidenceKnown() {
+			continue
+		}
+		if summary.Words == 0 || word.Confidence < summary.Min {
+			summary.Min = word.Confidence
+		}
+		summary.Words++
+		total += word.Confidence
+	}
+	if summary.Words > 0 {
+		summary.Mean = total / float64(summary.Words)
+	}
+	return summary
+}
+
+// lowConfidenceLayerName names the layer of the low confidence words of a page
+func lowConfidenceLayerName(layerName string, pageNum int) string {
+	if pageNum > 0 {
+		return fmt.Sprintf("%s (Page %d, low confidence)", layerName, pageNum)
+	}
+	return layerName + " (low confidence)"
+}
+
+// splitByConfidence separates the words whose confidence is below the minimum.
+// Words without a confidence value are kept, as is everything when the minimum
+// is 0; a reported confidence of 0 counts as a value.
+func splitByConfidence(words []hocr.Word, minConfidence float64) (kept, low []hocr.Word) {
+	if minConfidence <= 0 {
+		return words, nil
+	}
+	for _, word := range words {
+		if word.ConfidenceKnown() && word.Confidence < minConfidence {
+			low = append(low, word)
+		} else {
+			kept = append(kept, word)
+		}
 	}
+	return kept, low
+}
 
-	encodingErrors := 0
-	wordCount := 0
+// layerWords collects the words of a page in the order they are drawn
+func layerWords(page hocr.Page) []hocr.Word {
+	var words []hocr.Word
 
 	// Process words from areas
 	for _, area := range page.Areas {
 		// Words directly under area
-		for _, word := range area.Words {
-			drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-			wordCount++
-		}
+		words = append(words, area.Words...)
 
 		// Words in lines under area
 		for _, line := range area.Lines {
-			for _, word := range line.Words {
-				drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-				wordCount++
-			}
+			words = appendLineWords(words, line)
 		}
 
 		// Process words from paragraphs under area
 		for _, paragraph := range area.Paragraphs {
 			// Words directly under paragraph
-			for _, word := range paragraph.Words {
-				drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-				wordCount++
-			}
+			words = append(words, paragraph.Words...)
 
 			// Words in lines under paragraph
 			for _, line := range paragraph.Lines {
-				for _, word := range line.Words {
-					drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-					wordCount++
-				}
+				words = appendLineWords(words, line)
 			}
 		}
 	}
 
 	// Process words from paragraphs directly under page
 	for _, paragraph := range page.Paragraphs {
-		// Words directly under paragraph
-		for _, word := range paragraph.Words {
-			drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-			wordCount++
-		}
-
-		// Words in lines under paragraph
+		words = append(words, paragraph.Words...)
 		for _, line := range paragraph.Lines {
-			for _, word := range line.Words {
-				drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-				wordCount++
-			}
+			words = appendLineWords(words, line)
 		}
 	}
 
 	// Process words from lines directly under page
 	for _, line := range page.Lines {
-		for _, word := range line.Words {
-			drawWord(pdf, word, transform, fontConfig, debug, &encodingErrors)
-			wordCount++
-		}
+		words = appendLineWords(words, line)
 	}
 
-	pdf.EndLayer()
+	return words
+}
 
-	// Report encoding errors if more than a threshold
-	if wordCount > 0 && encodingErrors > 0 && encodingErrors > wordCount/10 {
-		return fmt.Errorf("character encoding issues in %d of %d words",
-			encodingErrors, wordCount)
+// appendLineWords appends the words of a line, passing the line's text angle
+// and baseline on to words that don't have their own
+func appendLineWords(words []hocr.Word, line hocr.Line) []hocr.Word {
+	angle, hasAngle := line.Metadata["textangle"]
+	if !hasAngle && line.Baseline == "" {
+		return append(words, line.Words...)
+	}
+	for _, word := range line.Words {
+		// Copy the metadata so the caller's hOCR isn't modified
+		word.Metadata = maps.Clone(word.Metadata)
+		if word.Metadata == nil {
+			word.Metadata = make(map[string]string)
+		}
+		if _, ok := word.Metadata["textangle"]; !ok && hasAngle {
+			word.Metadata["textangle"] = angle
+		}
+		if _, ok := word.Metadata["baseline"]; !ok {
+			if baseline, ok := wordBaseline(line, word); ok {
+				word.Metadata["baseline"] = baseline
+			}
+		}
+		words = append(words, word)
 	}
+	return words
+}
 
-	return nil
+// wordState tracks per-page rendering state while drawing words
+type wordState struct {
+	pageNum        int
+	config         OCRConfig
+	result         *ApplyResult
+	metrics        *fontMetrics            // Font the current word is drawn with
+	layerMetrics   *fontMetrics            // Font of the layer, for words no script font covers
+	scriptMetrics  map[string]*fontMetrics // Script fonts used on the layer by family
+	wordCount      int
+	skippedWords   int
+	encodingErrors int
 }
 
 // drawWord renders a single word onto the PDF layer
-func drawWord(pdf *fpdf.Fpdf, word hocr.Word, transform func(x, y float64) (float64, float64),
-	fontConfig FontConfig, debug bool, encodingErrors *int) {
+func drawWord(pdf *fpdf.Fpdf, word hocr.Word, transform func(x, y float64) (float64, float64), state *wordState) {
+	placement, ok := placeWord(word, transform, state)
+	if !ok {
+		return
+	}
+
+	state.metrics.setSize(pdf, placement.FontSize)
+	state.metrics.setScale(pdf, placement.HScale)
+
+	// Rotated text is drawn along its baseline by rotating around the start of it
+	if placement.Angle != 0 {
+		pdf.TransformBegin()
+		pdf.TransformRotate(placement.Angle, placement.BaselineX, placement.Baseline)
+	}
+
+	// When a fallback or right-to-left visual order changed the text, tell viewers
+	// what to copy instead of the drawn glyphs
+	actualText := placement.Rendered != placement.Text
+	if actualText {
+		pdf.RawWriteStr(fmt.Sprintf("/Span <</ActualText %s>> BDC", pdfTextString(placement.Text)))
+	}
+	pdf.Text(placement.BaselineX, placement.Baseline, placement.encoded)
+	if actualText {
+		pdf.RawWriteStr("EMC")
+	}
+
+	if placement.Angle == 0 {
+		if state.config.Debug {
+			drawDebugBox(pdf, word, placement.X, placement.Y, placement.Width, placement.Height, state)
+		}
+		return
+	}
+
+	// Outline the rotated text run rather than the upright bounding box
+	if state.config.Debug {
+		top := placement.Baseline - placement.FontSize*state.metrics.ascentRatio
+		drawDebugBox(pdf, word, placement.BaselineX, top, placement.length, placement.thickness, state)
+	}
+	pdf.TransformEnd()
+}
+
+// placeWord computes where and at what size a word is drawn in the OCR layer.
+// It returns false if the word is left out of the layer.
+func placeWord(word hocr.Word, transform func(x, y float64) (float64, float64), state *wordState) (WordPlacement, bool) {
+	fontConfig := state.config.Font
+
+	state.wordCount++
+	state.selectWordFont(word.Text)
+
+	// Convert text to the font's WinAnsi encoding, unless the Unicode font is in use
+	rendered, encoded, ok := encodeWord(word, state)
+	if !ok {
+		state.skippedWords++
+		return WordPlacement{}, false
+	}
 
 	x, y := transform(word.BBox.X1, word.BBox.Y1)
-	x2, _ := transform(word.BBox.X2, word.BBox.Y1)
-	wordWidth := x2 - x
+	x2, y2 := transform(word.BBox.X2, word.BBox.Y2)
+	wordWidth, wordHeight := x2-x, y2-y
 
-	// Convert text to ISO-8859-1 to avoid PDF encoding issues
-	latin1, err := charmap.ISO8859_1.NewEncoder().String(word.Text)
-	if err != nil {
-		// Track encoding errors but continue
-		*encodingErrors++
-		latin1 = word.Text // fallback to raw text
+	// Rotated text runs along a diagonal of its upright bounding box
+	angle := wordAngle(word)
+	length, thickness := rotatedTextExtent(wordWidth, wordHeight, angle)
+
+	// Scale the font so the rendered text spans the word's bounding box
+	fontSize := fontConfig.Size
+	if strWidth := state.metrics.stringWidth(encoded); strWidth > 0 {
+		fontSize = fontConfig.Size * length / strWidth
+	}
+
+	// Or size it to the word's height and make up the width by scaling the glyphs
+	hScale := 100.0
+	if heightRatio := state.metrics.ascentRatio + state.metrics.descentRatio; fontConfig.FitHeight && heightRatio > 0 && thickness > 0 {
+		heightSize := thickness / heightRatio
+		if fontConfig.MinSize > 0 {
+			heightSize = max(heightSize, fontConfig.MinSize)
+		}
+		if fontConfig.MaxSize > 0 {
+			heightSize = min(heightSize, fontConfig.MaxSize)
+		}
+		hScale = math.Round(100*fontSize/heightSize*100) / 100
+		fontSize = heightSize
+	}
+
+	placement := WordPlacement{
+		Page:      state.pageNum,
+		WordID:    word.ID,
+		Text:      word.Text,
+		Rendered:  rendered,
+		X:         x,
+		Y:         y,
+		Width:     wordWidth,
+		Height:    wordHeight,
+		Angle:     angle,
+		BaselineX: x,
+		Baseline:  y + fontSize*state.metrics.ascentRatio,
+		FontSize:  fontSize,
+		HScale:    hScale,
+		encoded:   encoded,
+		length:    length,
+		thickness: thickness,
+	}
+	// Sit upright text on the baseline the OCR engine measured, where there is one
+	if baselineY, ok := baselineAt(word); ok && angle == 0 {
+		_, placement.Baseline = transform(word.BBox.X1, baselineY)
+	}
+	if angle != 0 {
+		placement.BaselineX, placement.Baseline = rotatedBaselineStart(
+			x+wordWidth/2, y+wordHeight/2, length, thickness, fontSize*state.metrics.ascentRatio, angle)
+	}
+	return placement, true
+}
+
+// drawDebugBox outlines a word's bounding box in debug mode, optionally
+// colored and annotated by the word's recognition confidence
+func drawDebugBox(pdf *fpdf.Fpdf, word hocr.Word, x, y, w, h float64, state *wordState) {
+	config := state.config
+	if !config.Heatmap {
+		pdf.Rect(x, y, w, h, "D")
+	} else {
+		r, g, b := confidenceColor(word.Confidence, word.ConfidenceKnown())
+		pdf.SetDrawColor(r, g, b)
+		pdf.Rect(x, y, w, h, "D")
+		pdf.SetDrawColor(0, 0, 0)
+	}
+
+	if config.ShowConfidence && word.ConfidenceKnown() {
+		pdf.SetDrawColor(255, 0, 0)
+		if config.Heatmap {
+			pdf.SetDrawColor(confidenceColor(word.Confidence, true))
+		}
+		drawConfidenceLabel(pdf, x, y-1, word.Confidence)
+		pdf.SetDrawColor(0, 0, 0)
+	}
+}
+
+// segmentDigits are the segments of each digit in seven-segment form, one
+// bit each: top, top right, bottom right, bottom, bottom left, top left and
+// middle
+var segmentDigits = [10]uint8{0x3F, 0x06, 0x5B, 0x4F, 0x66, 0x6D, 0x7D, 0x07, 0x7F, 0x6F}
+
+// drawConfidenceLabel draws a confidence as seven-segment digits standing on
+// a baseline. The digits are lines rather than text, so the label doesn't end
+// up in the text of the OCR layer that is selected, searched and extracted.
+func drawConfidenceLabel(pdf *fpdf.Fpdf, x, baseline, confidence float64) {
+	const width, height, advance = 2.0, 3.5, 3.0
+	lineWidth := pdf.GetLineWidth()
+	pdf.SetLineWidth(0.4)
+	top, middle := baseline-height, baseline-height/2
+	for _, digit := range fmt.Sprintf("%.0f", confidence) {
+		if digit < '0' || digit > '9' {
+			continue
+		}
+		segments := [7][4]float64{
+			{x, top, x + width, top},
+			{x + width, top, x + width, middle},
+			{x + width, middle, x + width, baseline},
+			{x, baseline, x + width, baseline},
+			{x, middle, x, baseline},
+			{x, top, x, middle},
+			{x, middle, x + width, middle},
+		}
+		for i, segment := range segments {
+			if segmentDigits[digit-'0']&(1<<i) != 0 {
+				pdf.Line(segment[0], segment[1], segment[2], segment[3])
+			}
+		}
+		x += advance
+	}
+	pdf.SetLineWidth(lineWidth)
+}
+
+// confidenceColor maps a 0-100 confidence onto a red → yellow → green gradient.
+// Words without a known confidence are drawn in gray; a known confidence of 0
+// is as low as it gets.
+func confidenceColor(confidence float64, known bool) (r, g, b int) {
+	if !known {
+		return 128, 128, 128
+	}
+	t := min(max(confidence/100, 0), 1)
+	if t < 0.5 {
+		return 255, int(510 * t), 0
+	}
+	return int(510 * (1 - t)), 200, 0
+}
+
+// encodeWord converts the word text to Windows-1252, the WinAnsiEncoding the
+// core fonts are declared with, so viewers map the bytes back to the same
+// characters on copy/paste. It applies the configured fallback policy to
+// unencodable characters; text drawn with the Unicode font is used as is,
+// apart from right-to-left words, which are drawn in visual order.
+// It returns the rendered text as UTF-8 along with its encoded form, and
+// false if the word should be left out of the OCR layer.
+func encodeWord(word hocr.Word, state *wordState) (rendered, encoded string, ok bool) {
+	if state.metrics.unicode {
+		if isRTL(word.Text) {
+			visual := visualOrder(word.Text)
+			return visual, visual, true
+		}
+		return word.Text, word.Text, true
+	}
+
+	encoder := charmap.Windows1252.NewEncoder()
+	encoded, err := encoder.String(word.Text)
+	if err == nil {
+		return word.Text, encoded, true
+	}
+
+	// Track encoding errors and apply the fallback policy
+	state.encodingErrors++
+	policy := encodingFallbackOf(state.config)
+
+	if policy != EncodingFallbackSkip {
+		// The replacement glyph itself has to be encodable
+		replacement := state.config.ReplacementChar
+		if encodedReplacement, err := encoder.String(replacement); err != nil || encodedReplacement == "" {
+			replacement = "?"
+		}
+
+		var builder strings.Builder
+		for _, r := range word.Text {
+			if _, err := encoder.String(string(r)); err == nil {
+				builder.WriteRune(r)
+				continue
+			}
+			if policy == EncodingFallbackTransliterate {
+				builder.WriteString(anyascii.TransliterateRune(r))
+			} else {
+				builder.WriteString(replacement)
+			}
+		}
+		rendered = builder.String()
 	}
 
-	strWidth := pdf.GetStringWidth(latin1)
-	if strWidth > 0 {
-		scale := wordWidth / strWidth
-		pdf.SetFontSize(fontConfig.Size * scale)
+	if state.result != nil {
+		state.result.EncodingIssues = append(state.result.EncodingIssues, EncodingIssue{
+			Page:     state.pageNum,
+			WordID:   word.ID,
+			Text:     word.Text,
+			Rendered: rendered,
+			Action:   policy,
+		})
 	}
+	logEvent(state.config, slog.LevelWarn, EventEncodingError, "word needed an encoding fallback",
+		"page", state.pageNum, "word_id", word.ID, "text", word.Text, "rendered", rendered, "action", string(policy))
 
-	fontSize, _ := pdf.GetFontSize()
-	y += fontSize * fontConfig.AscentRatio
+	if strings.TrimSpace(rendered) == "" {
+		return "", "", false
+	}
+	// Every character is encodable by now
+	encoded, _ = encoder.String(rendered)
+	return rendered, encoded, true
+}
 
-	pdf.Text(x, y, latin1)
-	pdf.SetFontSize(fontConfig.Size)
+// encodingFallbackOf returns the configured fallback policy, defaulting to transliteration
+func encodingFallbackOf(config OCRConfig) EncodingFallback {
+	if config.EncodingFallback == "" {
+		return EncodingFallbackTransliterate
+	}
+	return config.EncodingFallback
+}
 
-	if debug {
-		height := word.BBox.Y2 - word.BBox.Y1
-		pdf.Rect(x, y-(fontSize*fontConfig.AscentRatio), wordWidth, height, "D")
+// pdfTextString encodes text as a UTF-16BE PDF hex string with byte order mark
+func pdfTextString(text string) string {
+	var builder strings.Builder
+	builder.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&builder, "%04X", unit)
 	}
+	builder.WriteString(">")
+	return builder.String()
 }
@@ -0,0 +1,67 @@
+package pdfocr
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+func TestSplitByConfidence(t *testing.T) {
+	doc, err := hocr.Parse([]byte(`<html><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 1000 1000'>
+<span class='ocr_line' id='line_1' title='bbox 0 0 1000 100'>
+<span class='ocrx_word' id='word_1' title='bbox 0 0 100 100; x_wconf 95'>sure</span>
+<span class='ocrx_word' id='word_2' title='bbox 200 0 300 100; x_wconf 40'>unsure</span>
+<span class='ocrx_word' id='word_3' title='bbox 400 0 500 100; x_wconf 0'>garbage</span>
+<span class='ocrx_word' id='word_4' title='bbox 600 0 700 100'>unscored</span>
+</span>
+</div>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, low := splitByConfidence(layerWords(doc.Pages[0]), 50)
+	if got, want := wordTexts(kept), []string{"sure", "unscored"}; !slices.Equal(got, want) {
+		t.Errorf("kept = %q, want %q", got, want)
+	}
+	if got, want := wordTexts(low), []string{"unsure", "garbage"}; !slices.Equal(got, want) {
+		t.Errorf("low = %q, want %q", got, want)
+	}
+
+	summary := pageConfidence(1, layerWords(doc.Pages[0]))
+	if summary.Words != 3 || summary.Min != 0 {
+		t.Errorf("page confidence = %+v, want 3 words with a minimum of 0", summary)
+	}
+}
+
+// wordTexts returns the text of each word
+func wordTexts(words []hocr.Word) []string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+	}
+	return texts
+}
+
+func TestConfidenceColor(t *testing.T) {
+	tests := []struct {
+		name       string
+		confidence float64
+		known      bool
+		r, g, b    int
+	}{
+		{"unknown", 0, false, 128, 128, 128},
+		{"known zero", 0, true, 255, 0, 0},
+		{"medium", 50, true, 255, 200, 0},
+		{"certain", 100, true, 0, 200, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r, g, b := confidenceColor(tt.confidence, tt.known); r != tt.r || g != tt.g || b != tt.b {
+				t.Errorf("confidenceColor(%v, %v) = %d, %d, %d, want %d, %d, %d", tt.confidence, tt.known, r, g, b, tt.r, tt.g, tt.b)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package pdfocr
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// LayerNameFields are the fields OCRConfig.LayerNameTemplate is executed with
+type LayerNameFields struct {
+	Base   string // OCRConfig.LayerName
+	Page   int    // Page number (1-based) in the resulting PDF
+	Engine string // OCR system that produced the hOCR (its ocr-system metadata), empty if unknown
+}
+
+// lowConfidenceSuffix is appended to the templated layer name of a page to
+// name its low confidence layer
+const lowConfidenceSuffix = " (low confidence)"
+
+// Stand-ins for the page number and engine while a layer name template is
+// turned into a pattern matching the names it produces
+const (
+	layerNamePageSentinel   = 918273645
+	layerNameEngineSentinel = "\x00engine\x00"
+)
+
+// layerNameTemplate parses LayerNameTemplate and tries it out, so an invalid
+// template fails before the first page is drawn
+func (c OCRConfig) layerNameTemplate() (*template.Template, error) {
+	tmpl, err := template.New("layer").Parse(c.LayerNameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layer name template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, LayerNameFields{Base: c.LayerName, Page: 1}); err != nil {
+		return nil, fmt.Errorf("invalid layer name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// pageLayerName names the OCR layer of a page: LayerName with the page
+// number appended, or whatever LayerNameTemplate makes of them
+func (c OCRConfig) pageLayerName(pageNum int, engine string) string {
+	if pageNum <= 0 {
+		return c.LayerName
+	}
+	defaultName := fmt.Sprintf("%s (Page %d)", c.LayerName, pageNum)
+	if c.LayerNameTemplate == "" {
+		return defaultName
+	}
+	// The template has been validated up front, so this only guards against surprises
+	tmpl, err := c.layerNameTemplate()
+	if err != nil {
+		return defaultName
+	}
+	var name strings.Builder
+	if err := tmpl.Execute(&name, LayerNameFields{Base: c.LayerName, Page: pageNum, Engine: engine}); err != nil {
+		return defaultName
+	}
+	return name.String()
+}
+
+// pageLowConfidenceLayerName names the layer of the low confidence words of a page
+func (c OCRConfig) pageLowConfidenceLayerName(pageNum int, engine string) string {
+	if c.LayerNameTemplate == "" || pageNum <= 0 {
+		return lowConfidenceLayerName(c.LayerName, pageNum)
+	}
+	return c.pageLayerName(pageNum, engine) + lowConfidenceSuffix
+}
+
+// layerNamePatterns caches the patterns of layerNamePattern by their source
+var layerNamePatterns sync.Map
+
+// layerNamePattern returns a pattern matching the page layer names
+// LayerNameTemplate produces, or nil without a template. Its first submatch
+// is the page number, empty if the template leaves it out.
+func (c OCRConfig) layerNamePattern() *regexp.Regexp {
+	if c.LayerNameTemplate == "" {
+		return nil
+	}
+	tmpl, err := c.layerNameTemplate()
+	if err != nil {
+		return nil
+	}
+	var name strings.Builder
+	fields := LayerNameFields{Base: c.LayerName, Page: layerNamePageSentinel, Engine: layerNameEngineSentinel}
+	if err := tmpl.Execute(&name, fields); err != nil {
+		return nil
+	}
+
+	pattern := regexp.QuoteMeta(name.String())
+	page := strconv.Itoa(layerNamePageSentinel)
+	if strings.Contains(pattern, page) {
+		pattern = strings.Replace(pattern, page, `(\d+)`, 1)
+		pattern = strings.ReplaceAll(pattern, page, `\d+`)
+	} else {
+		pattern = "()" + pattern
+	}
+	pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta(layerNameEngineSentinel), `.*`)
+
+	source := "^" + pattern + "$"
+	if compiled, ok := layerNamePatterns.Load(source); ok {
+		return compiled.(*regexp.Regexp)
+	}
+	compiled, _ := layerNamePatterns.LoadOrStore(source, regexp.MustCompile(source))
+	return compiled.(*regexp.Regexp)
+}
+
+// isTemplatedLayerName reports whether a layer is named by LayerNameTemplate,
+// including the low confidence layers
+func (c OCRConfig) isTemplatedLayerName(name string) bool {
+	pattern := c.layerNamePattern()
+	return pattern != nil && pattern.MatchString(strings.TrimSuffix(name, lowConfidenceSuffix))
+}
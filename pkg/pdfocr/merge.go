@@ -0,0 +1,229 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MergePDFs concatenates PDFs, such as documents OCR'd one by one, into a
+// single PDF with the pages of each in order, keeping their OCR layers. The
+// layers are renumbered to the pages they end up on, so "OCR Text (Page 1)"
+// of the second of two 3-page documents becomes "OCR Text (Page 4)"; layers
+// named by config.LayerNameTemplate are renumbered the same way. Other
+// layers whose name an earlier document already uses get a number appended,
+// e.g. "OCR-tesseract 5.3 (2)", so every document's layers can be told apart.
+// Form fields are combined, and the metadata of the first document is kept.
+// Outlines and structure trees, which refer to pages of one document, are
+// left out. Encrypted PDFs are opened with config.Password.
+func MergePDFs(pdfs [][]byte, config OCRConfig) ([]byte, error) {
+	if len(pdfs) == 0 {
+		return nil, fmt.Errorf("no PDFs to merge")
+	}
+	if config.LayerName == "" {
+		config.LayerName = DefaultConfig().LayerName
+	}
+
+	merged := &pdfFile{version: "1.4", objects: make(map[int][]byte)}
+	next := 1 // Lowest object number not taken yet
+	var kids, ocgs, order, off []string
+	var fields []string
+	var acroForm []byte
+	var catalogDict []byte
+	var info int
+	layerNames := make(map[string]bool) // Layer names of the documents merged so far
+	for i, pdfData := range pdfs {
+		if len(pdfData) == 0 {
+			return nil, fmt.Errorf("PDF %d: %w", i+1, ErrEmptyPDF)
+		}
+		pdfData, err := decryptInput(pdfData, config)
+		if err != nil {
+			return nil, fmt.Errorf("PDF %d: %w", i+1, err)
+		}
+
+		// Hang every page straight off the root of the page tree and leave
+		// out what refers to pages by position
+		source := parsePDFObjects(pdfData)
+		all := source.pages()
+		if len(all) == 0 {
+			return nil, fmt.Errorf("PDF %d has %w", i+1, ErrNoPages)
+		}
+		selection := make([]int, len(all))
+		for page := range selection {
+			selection[page] = page + 1
+		}
+		file, err := source.copyPages(all, selection)
+		if err != nil {
+			return nil, fmt.Errorf("PDF %d: %w", i+1, err)
+		}
+		file.renumber(next - 1)
+		if file.version > merged.version {
+			merged.version = file.version
+		}
+
+		catalog := file.ref(file.trailer, "Root")
+		tree := file.ref(file.dict(catalog), "Pages")
+		properties := file.subdict(file.dict(catalog), "OCProperties")
+
+		// Rename the layers before the names of this document are taken
+		var names []string
+		for _, num := range file.refArray(properties, "OCGs") {
+			name, ok := pdfStringValue(file.dict(num), "Name")
+			if !ok {
+				continue
+			}
+			renamed := config.renumberLayerName(name, len(kids))
+			for n := 2; layerNames[renamed]; n++ {
+				renamed = fmt.Sprintf("%s (%d)", config.renumberLayerName(name, len(kids)), n)
+			}
+			if renamed != name {
+				file.setLayerName(num, renamed)
+			}
+			names = append(names, renamed)
+			ocgs = append(ocgs, fmt.Sprintf("%d 0 R", num))
+		}
+		for _, name := range names {
+			layerNames[name] = true
+		}
+		defaults := file.subdict(properties, "D")
+		for _, num := range file.refArray(defaults, "Order") {
+			order = append(order, fmt.Sprintf("%d 0 R", num))
+		}
+		for _, num := range file.refArray(defaults, "OFF") {
+			off = append(off, fmt.Sprintf("%d 0 R", num))
+		}
+
+		if form := file.wholeDict(file.dict(catalog), "AcroForm"); form != nil {
+			if acroForm == nil {
+				acroForm = form
+			}
+			for _, num := range file.refArray(form, "Fields") {
+				fields = append(fields, fmt.Sprintf("%d 0 R", num))
+			}
+		}
+		if i == 0 {
+			catalogDict = file.dict(catalog)
+			info = file.ref(file.trailer, "Info")
+		}
+
+		for _, num := range file.pages() {
+			kids = append(kids, fmt.Sprintf("%d 0 R", num))
+		}
+		for num, body := range file.objects {
+			merged.objects[num] = body
+			next = max(next, num+1)
+		}
+		delete(merged.objects, catalog)
+		delete(merged.objects, tree)
+	}
+
+	// A new page tree and catalog, based on the first document's
+	tree, catalog := next, next+1
+	for _, kid := range kids {
+		num, _ := strconv.Atoi(strings.Fields(kid)[0])
+		dict := pdfParentPattern.ReplaceAll(merged.dict(num), nil)
+		dict = []byte(strings.TrimSuffix(strings.TrimSpace(string(dict)), ">>"))
+		merged.setDict(num, fmt.Appendf(dict, " /Parent %d 0 R>>", tree))
+	}
+	merged.objects[tree] = fmt.Appendf(nil, "\n<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(kids))
+
+	for _, key := range []string{"Pages", "OCProperties", "AcroForm"} {
+		catalogDict = pdfRemoveEntry(catalogDict, key)
+	}
+	catalogDict = []byte(strings.TrimSuffix(strings.TrimSpace(string(catalogDict)), ">>"))
+	catalogDict = fmt.Appendf(catalogDict, " /Pages %d 0 R", tree)
+	if len(ocgs) > 0 {
+		catalogDict = fmt.Appendf(catalogDict, " /OCProperties << /OCGs [%s] /D << /Order [%s] /OFF [%s] >> >>",
+			strings.Join(ocgs, " "), strings.Join(order, " "), strings.Join(off, " "))
+	}
+	if acroForm != nil {
+		acroForm = pdfRemoveEntry(acroForm, "Fields")
+		acroForm = []byte(strings.TrimSuffix(strings.TrimSpace(string(acroForm)), ">>"))
+		catalogDict = fmt.Appendf(catalogDict, " /AcroForm %s /Fields [%s]>>", acroForm, strings.Join(fields, " "))
+	}
+	merged.objects[catalog] = fmt.Appendf(nil, "\n%s>>\n", catalogDict)
+
+	merged.trailer = fmt.Appendf(nil, "<< /Root %d 0 R", catalog)
+	if info > 0 {
+		merged.trailer = fmt.Appendf(merged.trailer, " /Info %d 0 R", info)
+	}
+	merged.trailer = append(merged.trailer, " >>"...)
+
+	// Drop what only the replaced catalogs and page trees used
+	reachable := merged.reachable([]int{catalog, info})
+	for num := range merged.objects {
+		if !reachable[num] {
+			delete(merged.objects, num)
+		}
+	}
+	return merged.bytes()
+}
+
+// renumber adds an offset to the number of every object of a parsed PDF
+// and to every reference to one, so it can be combined with another PDF
+// whose objects are numbered up to the offset
+func (f *pdfFile) renumber(offset int) {
+	if offset == 0 {
+		return
+	}
+	shift := func(ref []byte) []byte {
+		match := pdfAnyReferencePattern.FindSubmatch(ref)
+		num, _ := strconv.Atoi(string(match[1]))
+		return fmt.Appendf(nil, "%d 0 R", num+offset)
+	}
+
+	objects := make(map[int][]byte, len(f.objects))
+	for num := range f.objects {
+		// Stream data isn't searched for references, as it may contain anything
+		objects[num+offset] = append(pdfAnyReferencePattern.ReplaceAllFunc(f.dict(num), shift), f.objects[num][len(f.dict(num)):]...)
+	}
+	f.objects = objects
+	f.trailer = pdfAnyReferencePattern.ReplaceAllFunc(f.trailer, shift)
+}
+
+// refArray returns the object numbers of an array of references stored
+// under a key, resolving a reference to the array
+func (f *pdfFile) refArray(dict []byte, key string) []int {
+	if num := f.ref(dict, key); num > 0 {
+		dict = fmt.Appendf(nil, "/%s %s", key, f.dict(num))
+	}
+	return pdfReferenceList(dict, key)
+}
+
+// setLayerName replaces the name of an optional content group
+func (f *pdfFile) setLayerName(num int, name string) {
+	dict := pdfRemoveEntry(f.dict(num), "Name")
+	dict = []byte(strings.TrimSuffix(strings.TrimSpace(string(dict)), ">>"))
+	f.setDict(num, fmt.Appendf(dict, " /Name %s>>", pdfTextString(name)))
+}
+
+// renumberLayerName moves the page number of a page's OCR layer name, as
+// named by LayerName or LayerNameTemplate, by an offset, which is negative
+// for a part split off a PDF. Other names, and names the offset would take
+// below page 1, are returned as they are.
+func (c OCRConfig) renumberLayerName(name string, offset int) string {
+	if offset == 0 {
+		return name
+	}
+	var loc []int
+	if rest, ok := strings.CutPrefix(name, c.LayerName); ok {
+		if loc = layerPageSuffixPattern.FindStringSubmatchIndex(rest); loc != nil {
+			for i := range loc {
+				loc[i] += len(c.LayerName)
+			}
+		}
+	}
+	if loc == nil {
+		if pattern := c.layerNamePattern(); pattern != nil {
+			loc = pattern.FindStringSubmatchIndex(strings.TrimSuffix(name, lowConfidenceSuffix))
+		}
+	}
+	if loc == nil || loc[2] == loc[3] {
+		return name
+	}
+	page, _ := strconv.Atoi(name[loc[2]:loc[3]])
+	if page+offset < 1 {
+		return name
+	}
+	return name[:loc[2]] + strconv.Itoa(page+offset) + name[loc[3]:]
+}
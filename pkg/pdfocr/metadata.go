@@ -0,0 +1,176 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// Metadata is the document information of a PDF: the entries of its Info
+// dictionary and its XMP metadata packet
+type Metadata struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string    // Application that created the original document
+	Producer     string    // Application that produced the PDF
+	CreationDate time.Time // Zero if unknown
+	ModDate      time.Time // Zero if unknown
+	XMP          []byte    // XMP metadata packet, as stored in the document catalog
+
+	// Properties are set in the XMP packet of the output, along with the
+	// text entries above that are set; see SetXMP
+	Properties []XMPProperty
+}
+
+// metadataKeys are the keys accepted by Metadata.SetInfo
+var metadataKeys = []string{"title", "author", "subject", "keywords", "creator", "producer"}
+
+// SetInfo sets a text entry by its lowercase key (title, author, subject,
+// keywords, creator or producer), e.g. from a "key=value" command-line flag
+func (m *Metadata) SetInfo(key, value string) error {
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "title":
+		m.Title = value
+	case "author":
+		m.Author = value
+	case "subject":
+		m.Subject = value
+	case "keywords":
+		m.Keywords = value
+	case "creator":
+		m.Creator = value
+	case "producer":
+		m.Producer = value
+	default:
+		return fmt.Errorf("unknown metadata key %q (valid: %s)", key, strings.Join(metadataKeys, ", "))
+	}
+	return nil
+}
+
+// ReadMetadata reads the Info dictionary and the XMP metadata of a PDF.
+// Missing entries are left empty; the metadata of encrypted PDFs can't be
+// read and is returned empty.
+func ReadMetadata(pdfData []byte) Metadata {
+	var metadata Metadata
+	file := parsePDFObjects(pdfData)
+	if bytes.Contains(file.trailer, []byte("/Encrypt")) {
+		return metadata
+	}
+
+	if info := file.wholeDict(file.trailer, "Info"); info != nil {
+		metadata.Title, _ = pdfStringValue(info, "Title")
+		metadata.Author, _ = pdfStringValue(info, "Author")
+		metadata.Subject, _ = pdfStringValue(info, "Subject")
+		metadata.Keywords, _ = pdfStringValue(info, "Keywords")
+		metadata.Creator, _ = pdfStringValue(info, "Creator")
+		metadata.Producer, _ = pdfStringValue(info, "Producer")
+		if date, ok := pdfStringValue(info, "CreationDate"); ok {
+			metadata.CreationDate = parsePDFDate(date)
+		}
+		if date, ok := pdfStringValue(info, "ModDate"); ok {
+			metadata.ModDate = parsePDFDate(date)
+		}
+	}
+
+	if num := file.ref(file.dict(file.ref(file.trailer, "Root")), "Metadata"); num > 0 {
+		if xmp, err := file.stream(num); err == nil {
+			metadata.XMP = xmp
+		}
+	}
+	return metadata
+}
+
+// pdfDatePattern matches a PDF date string, "D:YYYYMMDDHHmmSSOHH'mm'", of
+// which everything after the year is optional
+var pdfDatePattern = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:([Zz])|([+-])(\d{2})'?(\d{2})?'?)?`)
+
+// parsePDFDate parses a PDF date string, returning the zero time if it isn't one
+func parsePDFDate(value string) time.Time {
+	match := pdfDatePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return time.Time{}
+	}
+	part := func(i, fallback int) int {
+		if match[i] == "" {
+			return fallback
+		}
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+
+	location := time.UTC
+	if match[8] != "" {
+		offset := part(9, 0)*3600 + part(10, 0)*60
+		if match[8] == "-" {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	return time.Date(part(1, 0), time.Month(part(2, 1)), part(3, 1), part(4, 0), part(5, 0), part(6, 0), 0, location)
+}
+
+// merge returns the metadata with the set fields of override replacing its own.
+// The entries and properties set by override are written into the XMP packet.
+func (m Metadata) merge(override Metadata) Metadata {
+	for _, field := range []struct {
+		target *string
+		value  string
+	}{
+		{&m.Title, override.Title},
+		{&m.Author, override.Author},
+		{&m.Subject, override.Subject},
+		{&m.Keywords, override.Keywords},
+		{&m.Creator, override.Creator},
+		{&m.Producer, override.Producer},
+	} {
+		if field.value != "" {
+			*field.target = field.value
+		}
+	}
+	if !override.CreationDate.IsZero() {
+		m.CreationDate = override.CreationDate
+	}
+	if !override.ModDate.IsZero() {
+		m.ModDate = override.ModDate
+	}
+	if len(override.XMP) > 0 {
+		m.XMP = override.XMP
+	}
+	m.XMP = override.stampXMP(m.XMP)
+	return m
+}
+
+// apply sets the metadata on a PDF being generated. Empty entries keep the
+// defaults of fpdf, and missing dates become the time of writing.
+func (m Metadata) apply(pdf *fpdf.Fpdf) {
+	if m.Title != "" {
+		pdf.SetTitle(m.Title, true)
+	}
+	if m.Author != "" {
+		pdf.SetAuthor(m.Author, true)
+	}
+	if m.Subject != "" {
+		pdf.SetSubject(m.Subject, true)
+	}
+	if m.Keywords != "" {
+		pdf.SetKeywords(m.Keywords, true)
+	}
+	if m.Creator != "" {
+		pdf.SetCreator(m.Creator, true)
+	}
+	if m.Producer != "" {
+		pdf.SetProducer(m.Producer, true)
+	}
+	pdf.SetCreationDate(m.CreationDate)
+	pdf.SetModificationDate(m.ModDate)
+	if len(m.XMP) > 0 {
+		pdf.SetXmpMetadata(m.XMP)
+	}
+}
@@ -0,0 +1,101 @@
+package pdfocr
+
+import (
+	"fmt"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// fontMetrics caches glyph and string widths of the OCR layer font so word
+// placement doesn't have to query fpdf for every word. It also tracks the
+// font size currently set on the PDF, since every SetFontSize call writes a
+// font operator into the page content stream. The same goes for the
+// horizontal scaling of words fitted to their height.
+type fontMetrics struct {
+	baseSize     float64        // Configured font size in points
+	unitScale    float64        // Factor converting glyph units to user units at the base size
+	ascentRatio  float64        // Baseline offset from the top of the word box, relative to the font size
+	descentRatio float64        // Depth of descenders below the baseline, relative to the font size
+	family       string         // Font family the metrics are of
+	style        string         // Font style the metrics are of
+	unicode      bool           // True for the UTF-8 encoded Unicode font
+	pdf          *fpdf.Fpdf     // Source of Unicode font glyph widths
+	glyphWidths  [256]int       // Glyph widths of the single-byte encoded characters
+	widths       map[string]int // Cached glyph-unit widths of whole strings
+	currentSize  float64        // Font size currently selected on the PDF
+	currentScale float64        // Horizontal scaling in percent currently set on the PDF
+}
+
+// newFontMetrics precomputes glyph widths for the font currently selected on the PDF,
+// the layer font or the UTF-8 encoded font registered as family. For UTF-8 fonts,
+// widths are looked up per string instead, so the font must still be selected
+// whenever stringWidth is called.
+func newFontMetrics(pdf *fpdf.Fpdf, font FontConfig, family string, unicode bool) *fontMetrics {
+	_, unitSize := pdf.GetFontSize()
+	m := &fontMetrics{
+		baseSize:     font.Size,
+		unitScale:    unitSize / 1000,
+		ascentRatio:  font.AscentRatio,
+		descentRatio: font.DescentRatio,
+		family:       font.Name,
+		style:        font.Style,
+		unicode:      unicode,
+		pdf:          pdf,
+		widths:       make(map[string]int),
+		currentSize:  font.Size,
+		currentScale: 100,
+	}
+	if unicode {
+		m.family, m.style = family, ""
+		desc := pdf.GetFontDesc(family, "")
+		if desc.Ascent > 0 {
+			m.ascentRatio = float64(desc.Ascent) / 1000
+		}
+		if desc.Descent < 0 {
+			m.descentRatio = float64(-desc.Descent) / 1000
+		}
+		return m
+	}
+	for ch := 1; ch < len(m.glyphWidths); ch++ {
+		m.glyphWidths[ch] = pdf.GetStringSymbolWidth(string([]byte{byte(ch)}))
+	}
+	return m
+}
+
+// stringWidth returns the width of an encoded string at the base font size,
+// in the unit of measure of the PDF
+func (m *fontMetrics) stringWidth(s string) float64 {
+	width, ok := m.widths[s]
+	if !ok && m.unicode {
+		width = m.pdf.GetStringSymbolWidth(s)
+		m.widths[s] = width
+	} else if !ok {
+		for i := 0; i < len(s); i++ {
+			if s[i] == 0 {
+				break
+			}
+			width += m.glyphWidths[s[i]]
+		}
+		m.widths[s] = width
+	}
+	return float64(width) * m.unitScale
+}
+
+// setSize selects a font size on the PDF unless it is already current
+func (m *fontMetrics) setSize(pdf *fpdf.Fpdf, size float64) {
+	if size == m.currentSize {
+		return
+	}
+	pdf.SetFontSize(size)
+	m.currentSize = size
+}
+
+// setScale sets the horizontal scaling of glyphs in percent unless it is
+// already current. fpdf has no call for it, so the operator is written directly.
+func (m *fontMetrics) setScale(pdf *fpdf.Fpdf, scale float64) {
+	if scale == m.currentScale {
+		return
+	}
+	pdf.RawWriteStr(fmt.Sprintf("%.2f Tz", scale))
+	m.currentScale = scale
+}
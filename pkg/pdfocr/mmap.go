@@ -0,0 +1,44 @@
+package pdfocr
+
+// MappedFile is a read-only view of a file's contents. On Unix systems the
+// file is memory-mapped so that multi-gigabyte PDFs can be passed to DetectOCR
+// and ApplyOCR without first copying them onto the heap. On other platforms
+// the file is read into memory.
+//
+// The returned bytes must not be modified and must not be used after Close.
+// The file must not be truncated or rewritten while it is mapped: reading
+// past the new end of a mapped file raises SIGBUS, which crashes the program.
+// Read files that other processes may still be changing with os.ReadFile.
+//
+// MapFile is not available in js/wasm builds, which have no filesystem;
+// pass PDF bytes to the byte-slice APIs directly instead.
+type MappedFile struct {
+	data  []byte
+	unmap func([]byte) error
+}
+
+// Bytes returns the file contents
+func (m *MappedFile) Bytes() []byte {
+	if m == nil {
+		return nil
+	}
+	return m.data
+}
+
+// Len returns the size of the file in bytes
+func (m *MappedFile) Len() int {
+	return len(m.Bytes())
+}
+
+// Close releases the mapping. It is safe to call Close more than once.
+func (m *MappedFile) Close() error {
+	if m == nil || m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	if m.unmap == nil {
+		return nil
+	}
+	return m.unmap(data)
+}
@@ -0,0 +1,16 @@
+//go:build !unix && !js
+
+package pdfocr
+
+import (
+	"os"
+)
+
+// MapFile reads the file at path into memory on platforms without mmap support
+func MapFile(path string) (*MappedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data}, nil
+}
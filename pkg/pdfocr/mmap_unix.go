@@ -0,0 +1,39 @@
+//go:build unix
+
+package pdfocr
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// MapFile memory-maps the file at path for reading. The file must not be
+// truncated while it is mapped: reading the pages past its new end faults.
+func MapFile(path string) (*MappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return &MappedFile{data: []byte{}}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file %s is too large to map (%d bytes)", path, size)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s: %w", path, err)
+	}
+
+	return &MappedFile{data: data, unmap: syscall.Munmap}, nil
+}
@@ -2,7 +2,11 @@ package pdfocr
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"time"
 
 	"codeberg.org/go-pdf/fpdf"
 	"codeberg.org/go-pdf/fpdf/contrib/gofpdi"
@@ -12,27 +16,60 @@ import (
 
 // modifyExistingPDF imports pages from an existing PDF and overlays OCR text layer.
 func modifyExistingPDF(
+	ctx context.Context,
 	inputPDFData []byte,
 	hOCRData hocr.HOCR,
-	startFromPage int,
-	debug bool,
-	layerName string,
-	fontConfig FontConfig,
+	config OCRConfig,
+	result *ApplyResult,
 ) ([]byte, error) {
 
+	font, err := config.Font.loadFonts()
+	if err != nil {
+		return nil, err
+	}
+	config.Font = font
+
 	pdf := fpdf.New("P", "pt", "", "")
 	importer := gofpdi.NewImporter()
 	rs := io.ReadSeeker(bytes.NewReader(inputPDFData))
 
-	for i, page := range hOCRData.Pages {
-		targetPage := i + startFromPage
+	// Pair each page of the resulting PDF with a source page and the hOCR page drawn on it
+	var plan []pagePlan
+	if len(config.PageRanges) > 0 {
+		plan = selectedPagePlan(inputPDFData, len(hOCRData.Pages), config.PageRanges, result)
+	} else {
+		for i := range hOCRData.Pages {
+			plan = append(plan, pagePlan{sourcePage: i + config.StartPage, hocrPage: i})
+		}
+	}
+	if config.SkipPagesWithText {
+		skipPagesWithText(inputPDFData, plan, config, result)
+	}
+
+	for i, entry := range plan {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("stopped before page %d: %w", i+1, err)
+		}
 
 		// Calculate the actual page number in the PDF
 		actualPageNum := i + 1 // 1-based page number in the resulting PDF
 
-		pdf.AddPageFormat("P", fpdf.SizeType{Wd: page.BBox.X2, Ht: page.BBox.Y2})
+		// The importer copies the objects of the page, images included, with
+		// their dictionaries and encoded stream data as read, so CCITT G4,
+		// JBIG2 and JPEG 2000 images pass through without being re-encoded
+		tpl := importer.ImportPageFromStream(pdf, &rs, entry.sourcePage, "/MediaBox")
+
+		// Pages without OCR keep their own size
+		if entry.hocrPage < 0 {
+			size := importer.GetPageSizes()[entry.sourcePage]["/MediaBox"]
+			pdf.AddPageFormat("P", fpdf.SizeType{Wd: size["w"], Ht: size["h"]})
+			importer.UseImportedTemplate(pdf, tpl, 0, 0, size["w"], 0)
+			config.progress(i+1, len(plan), ProgressPage)
+			continue
+		}
 
-		tpl := importer.ImportPageFromStream(pdf, &rs, targetPage, "/MediaBox")
+		page := hOCRData.Pages[entry.hocrPage]
+		pdf.AddPageFormat("P", fpdf.SizeType{Wd: page.BBox.X2, Ht: page.BBox.Y2})
 		importer.UseImportedTemplate(pdf, tpl, 0, 0, page.BBox.X2, 0)
 
 		identity := func(x, y float64) (float64, float64) {
@@ -40,12 +77,120 @@ func modifyExistingPDF(
 		}
 
 		// Pass the page number to drawOCRLayer
-		drawOCRLayer(pdf, page, debug, layerName, actualPageNum, identity, fontConfig)
+		if err := drawOCRLayer(pdf, page, actualPageNum, hOCRData.Metadata["ocr-system"], identity, config, result); err != nil {
+			// Encoding problems are handled by the fallback policy, so they only block when asked to
+			if !errors.Is(err, ErrEncodingIssues) || config.FailOnEncodingIssues {
+				return nil, fmt.Errorf("failed to draw OCR layer for page %d: %w", actualPageNum, err)
+			}
+			result.addWarning(fmt.Sprintf("page %d: %v", actualPageNum, err))
+		}
+		config.progress(i+1, len(plan), ProgressPage)
+	}
+
+	var pages map[int]placedPage
+	if len(plan) > 0 {
+		pages = placedPages(plan, hOCRData, importer.GetPageSizes())
+	}
+
+	// Carry over the bookmarks, which fpdf would drop as well
+	if outline := readOutline(inputPDFData); len(outline) > 0 && len(plan) > 0 {
+		addOutline(pdf, outline, pages, config.Font)
 	}
 
+	// Carry over the document information of the input, which fpdf would drop.
+	// The output is a modification of it, dated now.
+	metadata := ReadMetadata(inputPDFData)
+	metadata.ModDate = time.Time{}
+	metadata.merge(config.Metadata).withProvenance(config.provenance(hOCRData)).apply(pdf)
+
+	config.progress(len(plan), len(plan), ProgressWrite)
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
 		return nil, err
 	}
+	// Keep the form fillable, and the other annotations if asked to
+	if len(plan) > 0 {
+		output, err := copyAnnotations(inputPDFData, buf.Bytes(), pages, config.KeepAnnotations, result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy annotations: %w", err)
+		}
+		return output, nil
+	}
 	return buf.Bytes(), nil
 }
+
+// pagePlan pairs a page of the source PDF with the hOCR page drawn on it
+type pagePlan struct {
+	sourcePage int // 1-based page number in the source PDF
+	hocrPage   int // Index of the hOCR page, or -1 to copy the page without OCR
+}
+
+// placedPage is where a page of the source PDF ended up in the output
+type placedPage struct {
+	page    int     // 1-based page number in the output
+	height  float64 // Height of the output page
+	scale   float64 // Scale of the source page on the output page
+	boxLeft float64 // Left edge of the source page's MediaBox, in default user space
+	boxTop  float64 // Top edge of the source page's MediaBox, in default user space
+	present bool
+}
+
+// point converts a point in the default user space of the source page to
+// the output page, which has its origin at the bottom left
+func (p placedPage) point(x, y float64) (float64, float64) {
+	return (x - p.boxLeft) * p.scale, p.height - (p.boxTop-y)*p.scale
+}
+
+// placedPages maps the pages of the source PDF to where they are in the
+// output, which scales OCR'ed pages to the size of their hOCR page
+func placedPages(plan []pagePlan, hOCRData hocr.HOCR, sizes map[int]map[string]map[string]float64) map[int]placedPage {
+	pages := make(map[int]placedPage, len(plan))
+	for i, entry := range plan {
+		box := sizes[entry.sourcePage]["/MediaBox"]
+		page := placedPage{page: i + 1, height: box["h"], scale: 1, boxLeft: box["llx"], boxTop: box["ury"], present: true}
+		if entry.hocrPage >= 0 && box["w"] > 0 {
+			bbox := hOCRData.Pages[entry.hocrPage].BBox
+			page.height, page.scale = bbox.Y2, bbox.X2/box["w"]
+		}
+		pages[entry.sourcePage] = page
+	}
+	return pages
+}
+
+// skipPagesWithText copies the pages of the plan that already show text
+// without OCR, recording them in the result
+func skipPagesWithText(inputPDFData []byte, plan []pagePlan, config OCRConfig, result *ApplyResult) {
+	coverage := detectPageCoverage(inputPDFData, config)
+	for i, entry := range plan {
+		if entry.hocrPage < 0 || entry.sourcePage > len(coverage) || !coverage[entry.sourcePage-1].HasText {
+			continue
+		}
+		plan[i].hocrPage = -1
+		result.SkippedPages = append(result.SkippedPages, entry.sourcePage)
+	}
+}
+
+// selectedPagePlan keeps every page of the source PDF and assigns the hOCR
+// pages, in order, to the pages selected by the ranges
+func selectedPagePlan(inputPDFData []byte, hocrPages int, ranges []PageRange, result *ApplyResult) []pagePlan {
+	pageCount := len(parsePDFObjects(inputPDFData).pages())
+	selected := selectedPages(ranges, pageCount, hocrPages)
+	if len(selected) < hocrPages {
+		result.addWarning(fmt.Sprintf("only %d of %d hOCR page(s) applied, the selected page ranges don't cover the rest",
+			len(selected), hocrPages))
+	}
+
+	hocrPageOf := make(map[int]int, len(selected))
+	for i, page := range selected {
+		hocrPageOf[page] = i
+	}
+
+	plan := make([]pagePlan, pageCount)
+	for i := range plan {
+		plan[i] = pagePlan{sourcePage: i + 1, hocrPage: -1}
+		if index, ok := hocrPageOf[i+1]; ok {
+			plan[i].hocrPage = index
+		}
+	}
+	return plan
+}
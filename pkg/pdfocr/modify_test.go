@@ -0,0 +1,86 @@
+package pdfocr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestApplyOCRKeepsImageStreams(t *testing.T) {
+	// CCITT G4 data the library can't decode, which looks like the end of the
+	// stream and ends with a line break that is part of the data
+	image := []byte("\x26\xa0\x0f\xffendstream\x00\x01\r\n")
+	pdf := testPDF(nil, []testObject{
+		{1, "<< /Type /Catalog /Pages 2 0 R >>"},
+		{2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		{3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /XObject << /Im1 5 0 R >> >> /Contents 4 0 R >>"},
+		testContent(4, "q 200 0 0 200 0 0 cm /Im1 Do Q"),
+		{5, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 8 /Height 8 /ColorSpace /DeviceGray /BitsPerComponent 1 "+
+			"/Filter /CCITTFaxDecode /DecodeParms << /K -1 /Columns 8 /Rows 8 >> /Length %d >>\nstream\n%s\nendstream", len(image), image)},
+	}, "<< /Root 1 0 R /Size 6 >>")
+	hocrData := []byte(`<html><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 200 200'>
+<span class='ocr_line' id='line_1' title='bbox 10 10 190 30'>
+<span class='ocrx_word' id='word_1' title='bbox 10 10 190 30; x_wconf 90'>Scanned</span>
+</span>
+</div>
+</body></html>`)
+
+	output, err := ApplyOCR(pdf, hocrData, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := parsePDFObjects(output)
+	var images int
+	for _, num := range file.sortedObjects() {
+		dict := file.dict(num)
+		if !imageSubtypePattern.Match(dict) {
+			continue
+		}
+		images++
+		if !bytes.Contains(dict, []byte("/CCITTFaxDecode")) {
+			t.Errorf("image dictionary %s lost its filter", dict)
+		}
+		data, err := file.rawStream(num)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, image) {
+			t.Errorf("image data = %q, want %q", data, image)
+		}
+	}
+	if images != 1 {
+		t.Errorf("output has %d images, want 1", images)
+	}
+}
+
+func TestApplyOCREncodingIssues(t *testing.T) {
+	hocrData := []byte(`<html><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 1240 1754'>
+<span class='ocr_line' id='line_1' title='bbox 100 100 1100 160'>
+<span class='ocrx_word' id='word_1' title='bbox 100 100 300 160'>Счёт</span>
+<span class='ocrx_word' id='word_2' title='bbox 350 100 600 160'>фактура</span>
+</span>
+</div>
+</body></html>`)
+
+	// Strict is about existing OCR, so encoding issues stay a warning
+	config := DefaultConfig()
+	config.Strict = true
+	config.LogWarnings = false
+	result, err := ApplyOCRWithResult(testBlankPDF(t), hocrData, config)
+	if err != nil {
+		t.Fatalf("ApplyOCRWithResult() with Strict error = %v, want a warning", err)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("warnings = %q, want one about the encoding issues", result.Warnings)
+	}
+
+	config = DefaultConfig()
+	config.FailOnEncodingIssues = true
+	if _, err := ApplyOCRWithResult(testBlankPDF(t), hocrData, config); !errors.Is(err, ErrEncodingIssues) {
+		t.Errorf("ApplyOCRWithResult() with FailOnEncodingIssues error = %v, want ErrEncodingIssues", err)
+	}
+}
@@ -0,0 +1,164 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// DefaultImageSourceDPI is the resolution assumed for page images that don't
+// state their own, the usual resolution of document scans
+const DefaultImageSourceDPI = 300
+
+// defaultJPEGQuality encodes downsampled JPEG images when no ImageQuality is set
+const defaultJPEGQuality = 85
+
+// optimizeImage downsamples a page image to config.ImageDPI and re-encodes
+// it as JPEG with config.ImageQuality, as far as these are set. It returns
+// the image data to embed with its type, and whether it was changed. An
+// image is only replaced if that makes it smaller.
+func optimizeImage(data []byte, imageType string, config OCRConfig) ([]byte, string, bool, error) {
+	if config.ImageDPI <= 0 && config.ImageQuality <= 0 {
+		return data, imageType, false, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	width, height, resized := downsampledSize(data, img.Bounds(), config)
+	if resized {
+		img = downsample(img, width, height)
+	}
+
+	// Downsampled PNG and GIF images stay lossless unless a quality is set
+	var buf bytes.Buffer
+	switch {
+	case config.ImageQuality > 0:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: config.ImageQuality})
+		imageType = "JPEG"
+	case !resized:
+		return data, imageType, false, nil
+	case imageType == "JPEG":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: defaultJPEGQuality})
+	default:
+		err = png.Encode(&buf, img)
+		imageType = "PNG"
+	}
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	// Re-encoding alone can make an image larger, e.g. a JPEG saved at a
+	// lower quality than it was requested with
+	if !resized && buf.Len() >= len(data) {
+		return data, imageType, false, nil
+	}
+	return buf.Bytes(), imageType, true, nil
+}
+
+// downsampledSize returns the size of a page image downsampled to
+// config.ImageDPI, and whether that is smaller than its own
+func downsampledSize(data []byte, bounds image.Rectangle, config OCRConfig) (int, int, bool) {
+	if config.ImageDPI <= 0 {
+		return bounds.Dx(), bounds.Dy(), false
+	}
+	sourceDPI := imageDPI(data)
+	if sourceDPI <= 0 {
+		sourceDPI = config.ImageSourceDPI
+	}
+	if sourceDPI <= 0 {
+		sourceDPI = DefaultImageSourceDPI
+	}
+	scale := config.ImageDPI / sourceDPI
+	if scale >= 1 {
+		return bounds.Dx(), bounds.Dy(), false
+	}
+	width := max(int(math.Round(float64(bounds.Dx())*scale)), 1)
+	height := max(int(math.Round(float64(bounds.Dy())*scale)), 1)
+	return width, height, true
+}
+
+// imageDPI returns the horizontal resolution a JPEG (JFIF) or PNG image
+// states in its header, or 0 if it states none
+func imageDPI(data []byte) float64 {
+	switch {
+	case len(data) >= 18 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF && data[3] == 0xE0 &&
+		string(data[6:11]) == "JFIF\x00":
+		density := float64(binary.BigEndian.Uint16(data[14:16]))
+		switch data[13] {
+		case 1: // Dots per inch
+			return density
+		case 2: // Dots per centimeter
+			return density * 2.54
+		}
+	case len(data) >= 8 && string(data[:8]) == "\x89PNG\r\n\x1a\n":
+		// The physical pixel dimensions come before the image data
+		for pos := 8; pos+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+			kind := string(data[pos+4 : pos+8])
+			if kind == "IDAT" || pos+8+length > len(data) {
+				break
+			}
+			if kind == "pHYs" && length >= 9 && data[pos+16] == 1 {
+				// Pixels per meter
+				return float64(binary.BigEndian.Uint32(data[pos+8:pos+12])) * 0.0254
+			}
+			pos += 12 + length
+		}
+	}
+	return 0
+}
+
+// downsample shrinks an image to the given size, averaging the source pixels
+// that fall into each target pixel. Grayscale images stay grayscale, others
+// become opaque RGBA.
+func downsample(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	if gray, ok := img.(*image.Gray); ok {
+		out := image.NewGray(image.Rect(0, 0, width, height))
+		averagePixels(gray.Pix, gray.Stride, 1, bounds.Dx(), bounds.Dy(), out.Pix, out.Stride, width, height)
+		return out
+	}
+
+	// Transparent areas become white paper rather than black in a JPEG
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Over)
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	averagePixels(rgba.Pix, rgba.Stride, 4, bounds.Dx(), bounds.Dy(), out.Pix, out.Stride, width, height)
+	return out
+}
+
+// averagePixels fills each pixel of dst with the average of the block of
+// src pixels it covers, channel by channel
+func averagePixels(src []uint8, srcStride, channels, srcWidth, srcHeight int,
+	dst []uint8, dstStride, dstWidth, dstHeight int) {
+	sums := make([]int, channels)
+	for y := range dstHeight {
+		y0 := y * srcHeight / dstHeight
+		y1 := max((y+1)*srcHeight/dstHeight, y0+1)
+		for x := range dstWidth {
+			x0 := x * srcWidth / dstWidth
+			x1 := max((x+1)*srcWidth/dstWidth, x0+1)
+			clear(sums)
+			for sy := y0; sy < y1; sy++ {
+				row := src[sy*srcStride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range channels {
+						sums[c] += int(row[sx*channels+c])
+					}
+				}
+			}
+			count := (y1 - y0) * (x1 - x0)
+			for c := range channels {
+				dst[y*dstStride+x*channels+c] = uint8((sums[c] + count/2) / count)
+			}
+		}
+	}
+}
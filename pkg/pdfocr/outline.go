@@ -0,0 +1,233 @@
+package pdfocr
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// outlineItem is an entry of a PDF's outline, the bookmarks shown in the
+// sidebar of PDF viewers
+type outlineItem struct {
+	title  string
+	level  int     // Nesting depth, 0 for top-level entries
+	page   int     // 1-based page the entry points to, 0 if unknown
+	top    float64 // Vertical position it points to, in default user space
+	hasTop bool    // Whether top is set; otherwise it points to the top of the page
+}
+
+// pdfGoToPattern matches the action type of a GoTo action
+var pdfGoToPattern = regexp.MustCompile(`/S\s*/GoTo\b`)
+
+// readOutline reads the outline tree of a PDF as a flat list in document
+// order, resolving the page each entry points to. Encrypted PDFs give no
+// entries, as their strings can't be read.
+func readOutline(pdfData []byte) []outlineItem {
+	file := parsePDFObjects(pdfData)
+	if bytes.Contains(file.trailer, []byte("/Encrypt")) {
+		return nil
+	}
+	catalog := file.dict(file.ref(file.trailer, "Root"))
+
+	pageNumbers := make(map[int]int)
+	for i, num := range file.pages() {
+		pageNumbers[num] = i + 1
+	}
+
+	var items []outlineItem
+	seen := make(map[int]bool)
+	var walk func(num, level int)
+	walk = func(num, level int) {
+		for ; num > 0 && !seen[num]; num = file.ref(file.dict(num), "Next") {
+			seen[num] = true
+			dict := file.dict(num)
+			title, _ := pdfStringValue(dict, "Title")
+			item := outlineItem{title: title, level: level}
+			if dest, ok := file.outlineDestination(dict, catalog); ok {
+				item.page, item.top, item.hasTop = destinationPage(dest, pageNumbers)
+			}
+			items = append(items, item)
+			walk(file.ref(dict, "First"), level+1)
+		}
+	}
+	walk(file.ref(file.wholeDict(catalog, "Outlines"), "First"), 0)
+	return items
+}
+
+// outlineDestination returns the explicit destination of an outline item,
+// given directly, through a GoTo action or by name
+func (f *pdfFile) outlineDestination(item, catalog []byte) (pdfToken, bool) {
+	dest, ok := f.value(item, "Dest")
+	if !ok {
+		action := f.wholeDict(item, "A")
+		if action == nil || !pdfGoToPattern.Match(action) {
+			return pdfToken{}, false
+		}
+		if dest, ok = f.value(action, "D"); !ok {
+			return pdfToken{}, false
+		}
+	}
+	if dest.kind == pdfName || dest.kind == pdfString {
+		return f.namedDestination(catalog, dest)
+	}
+	return dest, dest.kind == pdfArray
+}
+
+// namedDestination looks up a named destination, in the /Dests dictionary
+// of the catalog (PDF 1.1) or the /Dests name tree (PDF 1.2 and later)
+func (f *pdfFile) namedDestination(catalog []byte, name pdfToken) (pdfToken, bool) {
+	var dest pdfToken
+	found := false
+	if name.kind == pdfName {
+		if dests := f.wholeDict(catalog, "Dests"); dests != nil {
+			dest, found = f.value(dests, name.text)
+		}
+	} else if names := f.wholeDict(catalog, "Names"); names != nil {
+		dest, found = f.nameTreeValue(f.wholeDict(names, "Dests"), string(name.bytes), make(map[int]bool))
+	}
+
+	// Destinations may be wrapped in a dictionary with the array under /D
+	if found && dest.kind == pdfDict {
+		dest, found = dest.dict["D"]
+	}
+	return dest, found && dest.kind == pdfArray
+}
+
+// nameTreeValue looks up a key in a name tree, resolving a referenced value
+func (f *pdfFile) nameTreeValue(node []byte, key string, seen map[int]bool) (pdfToken, bool) {
+	lexer := &pdfLexer{data: node}
+	token, ok := lexer.next()
+	if !ok || token.kind != pdfDict {
+		return pdfToken{}, false
+	}
+
+	items := token.dict["Names"].items
+	for i := 0; i+1 < len(items); i += 2 {
+		value := items[i+1]
+		isRef := i+3 < len(items) && value.kind == pdfNumber && items[i+3].text == "R"
+		if items[i].kind == pdfString && string(items[i].bytes) == key {
+			if isRef {
+				num, _ := strconv.Atoi(value.text)
+				return (&pdfLexer{data: f.dict(num)}).next()
+			}
+			return value, true
+		}
+		if isRef {
+			i += 2
+		}
+	}
+
+	for _, kid := range pdfReferenceList(node, "Kids") {
+		if seen[kid] {
+			continue
+		}
+		seen[kid] = true
+		if value, ok := f.nameTreeValue(f.dict(kid), key, seen); ok {
+			return value, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// destinationPage returns the page an explicit destination array points to
+// and the top of the view, if the destination sets one
+func destinationPage(dest pdfToken, pageNumbers map[int]int) (page int, top float64, hasTop bool) {
+	items := dest.items
+	if len(items) < 2 || items[0].kind != pdfNumber {
+		return 0, 0, false
+	}
+	num, _ := strconv.Atoi(items[0].text)
+	if len(items) >= 3 && items[2].text == "R" {
+		page = pageNumbers[num]
+		items = items[3:]
+	} else {
+		// Some producers give a 0-based page index instead of a reference
+		page = num + 1
+		items = items[1:]
+	}
+	if len(items) == 0 {
+		return page, 0, false
+	}
+
+	// The first item is now the destination type, followed by its parameters
+	topIndex := -1
+	switch items[0].text {
+	case "XYZ":
+		topIndex = 2
+	case "FitH", "FitBH":
+		topIndex = 1
+	case "FitR":
+		topIndex = 4
+	}
+	if topIndex > 0 && topIndex < len(items) && items[topIndex].kind == pdfNumber {
+		top, _ = strconv.ParseFloat(items[topIndex].text, 64)
+		return page, top, true
+	}
+	return page, 0, false
+}
+
+// addOutline adds the outline items as bookmarks to the output. Items that
+// point to pages left out of the output are dropped, and items without a
+// destination point to the page of the item before them.
+func addOutline(pdf *fpdf.Fpdf, items []outlineItem, pages map[int]placedPage, font FontConfig) {
+	// fpdf encodes bookmark titles as UTF-16 only while a Unicode font is
+	// selected, so select the regular font and encode them here
+	pdf.SetFont(font.Name, font.Style, font.Size)
+
+	// fpdf positions bookmarks from the top of the page that is current
+	// when the document is written, which is the last one
+	_, lastHeight := pdf.GetPageSize()
+
+	var previous placedPage
+	for _, page := range pages {
+		if !previous.present || page.page < previous.page {
+			previous = page
+		}
+	}
+
+	level := -1
+	for _, item := range items {
+		target := previous
+		if item.page > 0 {
+			if target = pages[item.page]; !target.present {
+				continue
+			}
+		}
+
+		offset := 0.0
+		if item.hasTop {
+			offset = min(max((target.boxTop-item.top)*target.scale, 0), target.height)
+		}
+
+		// Dropped items may leave a gap in the nesting, which fpdf can't represent
+		level = min(item.level, level+1)
+		pdf.SetPage(target.page)
+		pdf.Bookmark(bookmarkTitle(item.title), level, lastHeight-target.height+offset)
+		previous = target
+	}
+	pdf.SetPage(pdf.PageCount())
+}
+
+// bookmarkTitle encodes a title for fpdf to write as is: ASCII text
+// unchanged, other text as UTF-16BE with a byte order mark
+func bookmarkTitle(text string) string {
+	ascii := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return text
+	}
+
+	encoded := []byte{0xFE, 0xFF}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return string(encoded)
+}
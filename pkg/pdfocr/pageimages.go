@@ -0,0 +1,265 @@
+package pdfocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// pdfNamePattern extracts the name stored directly under a key
+var pdfNamePattern = regexp.MustCompile(`^\s*/(\w+)`)
+
+// imageSubtypePattern identifies image XObject dictionaries
+var imageSubtypePattern = regexp.MustCompile(`/Subtype\s*/Image\b`)
+
+// imageFilterPattern extracts the filter(s) of an image XObject
+var imageFilterPattern = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/\w+)`)
+
+// imageColorSpacePattern extracts the color space of an image XObject, given
+// by name, as an array or by reference
+var imageColorSpacePattern = regexp.MustCompile(`/ColorSpace\s*(/\w+|\[[^\]]*\]|\d+\s+\d+\s+R)`)
+
+// iccBasedPattern extracts the ICC profile stream of an ICCBased color space
+var iccBasedPattern = regexp.MustCompile(`/ICCBased\s+(\d+)\s+\d+\s+R`)
+
+// PageImage is the scanned image that makes up a page of a PDF
+type PageImage struct {
+	Page   int         // Page number (1-based)
+	Width  float64     // Page width in points
+	Height float64     // Page height in points
+	Image  image.Image // The decoded scan
+}
+
+// ExtractPageImages decodes the scan of every page of a PDF that consists of
+// one image per page, as scanners and image-to-PDF tools produce. It fails
+// with ErrNotScanned if a page has text or not exactly one image, and with
+// ErrInvalidImage for images it can't decode: JPEG and uncompressed or
+// Flate-compressed gray and RGB images, with or without PNG predictors, are
+// supported.
+func ExtractPageImages(pdfData []byte) ([]PageImage, error) {
+	if len(pdfData) == 0 {
+		return nil, ErrEmptyPDF
+	}
+	file := parsePDFObjects(pdfData)
+	if bytes.Contains(file.trailer, []byte("/Encrypt")) {
+		return nil, ErrEncryptedPDF
+	}
+	pages := file.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF has %w", ErrNoPages)
+	}
+
+	var images []PageImage
+	for i, page := range pages {
+		resources := file.resources(page)
+		if len(file.refs(file.subdict(resources, "Font"))) > 0 {
+			return nil, fmt.Errorf("%w: page %d has text", ErrNotScanned, i+1)
+		}
+		xobjects := file.refs(file.subdict(resources, "XObject"))
+		if len(xobjects) != 1 {
+			return nil, fmt.Errorf("%w: page %d has %d images or forms instead of one image", ErrNotScanned, i+1, len(xobjects))
+		}
+		var num int
+		for _, ref := range xobjects {
+			num = ref
+		}
+		if !imageSubtypePattern.Match(file.dict(num)) {
+			return nil, fmt.Errorf("%w: page %d shows a form rather than an image", ErrNotScanned, i+1)
+		}
+
+		img, err := file.decodeImage(num)
+		if err != nil {
+			return nil, fmt.Errorf("%w: page %d: %w", ErrInvalidImage, i+1, err)
+		}
+		width, height := file.mediaBox(page)
+		images = append(images, PageImage{Page: i + 1, Width: width, Height: height, Image: img})
+	}
+	return images, nil
+}
+
+// decodeImage decodes an image XObject
+func (f *pdfFile) decodeImage(num int) (image.Image, error) {
+	dict := f.dict(num)
+	if bytes.Contains(dict, []byte("/ImageMask true")) {
+		return nil, fmt.Errorf("image masks are not supported")
+	}
+
+	filter := imageFilterPattern.FindSubmatch(dict)
+	if filter != nil && bytes.Contains(filter[1], []byte("/DCTDecode")) {
+		if len(bytes.Fields(bytes.Trim(filter[1], "[]"))) != 1 {
+			return nil, fmt.Errorf("unsupported image filter %s", filter[1])
+		}
+		data, err := f.rawStream(num)
+		if err != nil {
+			return nil, err
+		}
+		return jpeg.Decode(bytes.NewReader(data))
+	}
+
+	width, height := f.intValue(dict, "Width"), f.intValue(dict, "Height")
+	bits := f.intValue(dict, "BitsPerComponent")
+	components, err := f.colorComponents(dict)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+
+	var data []byte
+	if predictor := f.intValue(dict, "Predictor"); predictor >= 10 {
+		// Images converted from PNG keep the PNG row filters
+		if data, err = f.rawStream(num); err == nil {
+			data, err = inflate(data)
+		}
+		if err == nil {
+			data, err = unpredictPNG(data, (width*components*bits+7)/8, max(1, components*bits/8))
+		}
+	} else {
+		data, err = f.stream(num)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rasterImage(data, width, height, components, bits)
+}
+
+// inflate decompresses Flate-compressed data
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// unpredictPNG reverses the PNG row filters of image data, where each row of
+// stride bytes is preceded by its filter type and bpp is the number of bytes
+// per pixel
+func unpredictPNG(data []byte, stride, bpp int) ([]byte, error) {
+	rows := len(data) / (stride + 1)
+	out := make([]byte, rows*stride)
+	previous := make([]byte, stride)
+	for y := range rows {
+		filter, in := data[y*(stride+1)], data[y*(stride+1)+1:(y+1)*(stride+1)]
+		row := out[y*stride : (y+1)*stride]
+		for x := range stride {
+			var left, upLeft byte
+			if x >= bpp {
+				left, upLeft = row[x-bpp], previous[x-bpp]
+			}
+			up := previous[x]
+			switch filter {
+			case 0:
+				row[x] = in[x]
+			case 1:
+				row[x] = in[x] + left
+			case 2:
+				row[x] = in[x] + up
+			case 3:
+				row[x] = in[x] + byte((int(left)+int(up))/2)
+			case 4:
+				row[x] = in[x] + paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("invalid PNG row filter %d", filter)
+			}
+		}
+		previous = row
+	}
+	return out, nil
+}
+
+// paeth returns the neighbouring byte the PNG Paeth filter predicts from
+func paeth(left, up, upLeft byte) byte {
+	p := int(left) + int(up) - int(upLeft)
+	pa, pb, pc := abs(p-int(left)), abs(p-int(up)), abs(p-int(upLeft))
+	switch {
+	case pa <= pb && pa <= pc:
+		return left
+	case pb <= pc:
+		return up
+	}
+	return upLeft
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// colorComponents returns the number of color components of an image's
+// color space: 1 for gray and 3 for RGB
+func (f *pdfFile) colorComponents(dict []byte) (int, error) {
+	colorSpace := imageColorSpacePattern.FindSubmatch(dict)
+	if colorSpace == nil {
+		return 0, fmt.Errorf("image has no color space")
+	}
+	value := colorSpace[1]
+	if num := f.ref(dict, "ColorSpace"); num > 0 {
+		value = f.objects[num]
+	}
+
+	switch name := pdfNamePattern.FindSubmatch(bytes.TrimLeft(value, "[ ")); {
+	case name == nil:
+	case string(name[1]) == "DeviceGray" || string(name[1]) == "CalGray":
+		return 1, nil
+	case string(name[1]) == "DeviceRGB" || string(name[1]) == "CalRGB":
+		return 3, nil
+	case string(name[1]) == "ICCBased":
+		// The number of components is in the ICC profile stream
+		match := iccBasedPattern.FindSubmatch(value)
+		if match != nil {
+			profile, _ := strconv.Atoi(string(match[1]))
+			if n := f.intValue(f.dict(profile), "N"); n == 1 || n == 3 {
+				return n, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unsupported color space %s", value)
+}
+
+// rasterImage builds an image from raw samples, rows padded to whole bytes
+func rasterImage(data []byte, width, height, components, bits int) (image.Image, error) {
+	if bits != 8 && !(bits == 1 && components == 1) {
+		return nil, fmt.Errorf("unsupported image depth of %d bits with %d color components", bits, components)
+	}
+	stride := (width*components*bits + 7) / 8
+	if len(data) < stride*height {
+		return nil, fmt.Errorf("image data is %d bytes, expected %d", len(data), stride*height)
+	}
+
+	if components == 3 {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := range height {
+			row := data[y*stride:]
+			for x := range width {
+				img.Set(x, y, color.RGBA{R: row[3*x], G: row[3*x+1], B: row[3*x+2], A: 255})
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		row := data[y*stride:]
+		for x := range width {
+			switch {
+			case bits == 8:
+				img.Pix[y*img.Stride+x] = row[x]
+			case row[x/8]&(0x80>>(x%8)) != 0:
+				img.Pix[y*img.Stride+x] = 255
+			}
+		}
+	}
+	return img, nil
+}
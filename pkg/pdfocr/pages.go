@@ -0,0 +1,119 @@
+package pdfocr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PageRange is an inclusive range of 1-based page numbers.
+// A Last of 0 means the range runs to the end of the document.
+type PageRange struct {
+	First int
+	Last  int
+}
+
+// String formats the range the way ParsePageRanges accepts it
+func (r PageRange) String() string {
+	switch {
+	case r.Last == 0:
+		return fmt.Sprintf("%d-", r.First)
+	case r.First == r.Last:
+		return strconv.Itoa(r.First)
+	default:
+		return fmt.Sprintf("%d-%d", r.First, r.Last)
+	}
+}
+
+// contains reports whether a page number falls within the range
+func (r PageRange) contains(page int) bool {
+	return page >= r.First && (r.Last == 0 || page <= r.Last)
+}
+
+// ParsePageRanges parses a comma separated list of pages and page ranges,
+// e.g. "1-3,7,9-" selects pages 1 to 3, page 7 and page 9 to the end
+func ParsePageRanges(spec string) ([]PageRange, error) {
+	var ranges []PageRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		var r PageRange
+		var err error
+		if r.First, err = strconv.Atoi(strings.TrimSpace(first)); err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidPageRange, part)
+		}
+		r.Last = r.First
+		if isRange {
+			r.Last = 0
+			if last = strings.TrimSpace(last); last != "" {
+				if r.Last, err = strconv.Atoi(last); err != nil {
+					return nil, fmt.Errorf("%w %q", ErrInvalidPageRange, part)
+				}
+			}
+		}
+		if err := validatePageRange(r); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("%w: no pages selected in %q", ErrInvalidPageRange, spec)
+	}
+	return ranges, nil
+}
+
+// validatePageRange checks that a range selects at least one valid page
+func validatePageRange(r PageRange) error {
+	if r.First < 1 {
+		return fmt.Errorf("%w %s: must start at page 1 or later", ErrInvalidPageRange, r)
+	}
+	if r.Last != 0 && r.Last < r.First {
+		return fmt.Errorf("%w %s: ends before it starts", ErrInvalidPageRange, r)
+	}
+	return nil
+}
+
+// selectedPages returns up to count page numbers selected by the ranges, in
+// ascending order. Pages after lastPage are left out, unless lastPage is 0.
+func selectedPages(ranges []PageRange, lastPage, count int) []int {
+	limit := lastPage
+	if limit == 0 {
+		for _, r := range ranges {
+			if r.Last == 0 {
+				limit = math.MaxInt
+				break
+			}
+			limit = max(limit, r.Last)
+		}
+	}
+
+	var pages []int
+	for page := 1; page <= limit && len(pages) < count; page++ {
+		for _, r := range ranges {
+			if r.contains(page) {
+				pages = append(pages, page)
+				break
+			}
+		}
+	}
+	return pages
+}
+
+// PageCount counts the pages of a PDF from its page tree, without rendering
+// or decoding their content
+func PageCount(pdfData []byte) (int, error) {
+	if len(pdfData) == 0 {
+		return 0, ErrEmptyPDF
+	}
+	pages := parsePDFObjects(pdfData).pages()
+	if len(pages) == 0 {
+		return 0, fmt.Errorf("PDF has %w", ErrNoPages)
+	}
+	return len(pages), nil
+}
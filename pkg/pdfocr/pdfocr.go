@@ -14,66 +14,114 @@
 // - Apply OCR text layers to existing PDFs, making them searchable and text selectable
 // - Create new PDFs from images with OCR text layers
 // - Detect existing OCR layers to prevent duplication
+// - Remove existing OCR layers and invisible text, or replace them with a new layer
 // - Position text with precise bounding boxes matching the original content
 //
 // Main Functions:
 //
 // - ApplyOCR: Adds OCR text layer to an existing PDF
 // - AssembleWithOCR: Creates a new PDF from images with OCR text layer
+// - ApplyOCRWithResult / AssembleWithOCRWithResult: As above, returning a rendering report
+// - ApplyOCRContext / AssembleWithOCRContext: As above, cancellable between pages through a context
 // - DetectOCR: Best effort detection if OCR has already been applied to PDF
+// - RemoveOCR: Strips an existing OCR text layer so the PDF can be reprocessed
+// - ComputeTextMap: Computes where each word would be placed, without writing a PDF
+// - ExtractHOCR: Converts the existing text of a digitally created PDF to hOCR
+// - ReadMetadata: Reads the document information and XMP metadata kept in the OCR'ed PDF
+// - ReadProvenance: Reads which engine, processor and ocrchestra version added the OCR text
+// - DecryptPDF: Opens a password-protected PDF, which the functions above also do with OCRConfig.Password
+// - ExtractPages: Copies some pages of a PDF, with their OCR layer, into a new PDF
+// - SplitPDF: Splits a PDF into one PDF per page or page range, keeping the OCR layers
+// - MergePDFs: Concatenates PDFs into one, renumbering their OCR layers to avoid collisions
+// - SplitTIFF: Splits a multipage TIFF scan into page images for AssembleWithOCR
+//
+// Errors wrap the exported Err* sentinels (such as ErrAlreadyHasOCR), so
+// callers can check for them with errors.Is.
 package pdfocr
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
 
 	"github.com/gardar/ocrchestra/pkg/hocr"
 )
 
 // AssembleWithOCR is a high-level function for creating a PDF from images
 // and applying the HOCR text overlay.
-// It accepts either raw HOCR data ([]byte) or a parsed HOCR struct (*hocr.HOCR).
+// It accepts either raw HOCR or ALTO data ([]byte) or a parsed HOCR struct (*hocr.HOCR).
 func AssembleWithOCR(
 	hocrInput interface{},
 	imagesData [][]byte,
 	config OCRConfig,
 ) ([]byte, error) {
-	// Handle different input types for HOCR
-	var hocrStruct hocr.HOCR
-	var err error
+	return AssembleWithOCRContext(context.Background(), hocrInput, imagesData, config)
+}
 
-	switch h := hocrInput.(type) {
-	case []byte:
-		// Parse raw HOCR data
-		hocrStruct, err = hocr.ParseHOCR(h)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse HOCR data: %w", err)
-		}
-	case *hocr.HOCR:
-		// Use the provided struct directly
-		if h == nil {
-			return nil, fmt.Errorf("HOCR struct is nil")
-		}
-		hocrStruct = *h
-	default:
-		return nil, fmt.Errorf("unsupported HOCR input type: %T", hocrInput)
+// AssembleWithOCRContext works like AssembleWithOCR but stops between pages
+// once the context is cancelled or its deadline passes, returning the context error.
+func AssembleWithOCRContext(
+	ctx context.Context,
+	hocrInput interface{},
+	imagesData [][]byte,
+	config OCRConfig,
+) ([]byte, error) {
+	result, err := AssembleWithOCRWithResultContext(ctx, hocrInput, imagesData, config)
+	if err != nil {
+		return nil, err
+	}
+	return result.PDF, nil
+}
+
+// AssembleWithOCRWithResult works like AssembleWithOCR but returns an ApplyResult
+// that reports how the OCR layer was rendered, including per-word encoding fallbacks.
+func AssembleWithOCRWithResult(
+	hocrInput interface{},
+	imagesData [][]byte,
+	config OCRConfig,
+) (*ApplyResult, error) {
+	return AssembleWithOCRWithResultContext(context.Background(), hocrInput, imagesData, config)
+}
+
+// AssembleWithOCRWithResultContext combines AssembleWithOCRWithResult and AssembleWithOCRContext
+func AssembleWithOCRWithResultContext(
+	ctx context.Context,
+	hocrInput interface{},
+	imagesData [][]byte,
+	config OCRConfig,
+) (*ApplyResult, error) {
+	hocrStruct, err := parseHOCRInput(hocrInput)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate inputs
 	if len(hocrStruct.Pages) == 0 {
-		return nil, fmt.Errorf("HOCR data contains no pages")
+		return nil, fmt.Errorf("HOCR data contains %w", ErrNoPages)
 	}
 
 	if len(imagesData) == 0 {
-		return nil, fmt.Errorf("no image data provided")
+		return nil, ErrNoImages
 	}
 	if config.StartPage < 1 {
-		return nil, fmt.Errorf("start page must be at least 1, got %d", config.StartPage)
+		return nil, fmt.Errorf("%w: start page must be at least 1, got %d", ErrInvalidPageRange, config.StartPage)
+	}
+	if config.LayerNameTemplate != "" {
+		if _, err := config.layerNameTemplate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.ImageQuality < 0 || config.ImageQuality > 100 {
+		return nil, fmt.Errorf("image quality must be between 1 and 100, got %d", config.ImageQuality)
 	}
 
 	// Check if we have enough images for hOCR pages
 	if len(imagesData) < len(hocrStruct.Pages) {
-		return nil, fmt.Errorf("not enough images (%d) for HOCR pages (%d)",
-			len(imagesData), len(hocrStruct.Pages))
+		return nil, fmt.Errorf("%w: %d image(s) for %d page(s)",
+			ErrImageCountMismatch, len(imagesData), len(hocrStruct.Pages))
 	}
 
 	// Get the logger
@@ -82,11 +130,11 @@ func AssembleWithOCR(
 	// Validate image formats
 	for i, imgData := range imagesData {
 		if len(imgData) == 0 {
-			return nil, fmt.Errorf("image %d is empty", i+1)
+			return nil, fmt.Errorf("%w: image %d is empty", ErrInvalidImage, i+1)
 		}
 		imageType, err := detectImageType(imgData)
 		if err != nil {
-			return nil, fmt.Errorf("image %d has invalid format: %w", i+1, err)
+			return nil, fmt.Errorf("%w: image %d has invalid format: %w", ErrInvalidImage, i+1, err)
 		}
 		if config.Debug {
 			fmt.Fprintf(logger, "Image %d is of type: %s\n", i+1, imageType)
@@ -94,58 +142,87 @@ func AssembleWithOCR(
 	}
 
 	// Build the PDF from images
-	finalPDF, err := createPDFFromImage(
-		hocrStruct,
-		imagesData,
-		config.StartPage,
-		config.Debug,
-		config.LayerName,
-		config.Font,
-	)
+	result := &ApplyResult{}
+	finalPDF, err := createPDFFromImage(ctx, hocrStruct, imagesData, config, result)
 	if err != nil {
 		return nil, fmt.Errorf("error creating PDF from images: %w", err)
 	}
-	return finalPDF, nil
+	result.PDF = finalPDF
+
+	logResult(result, config, logger)
+	return result, nil
 }
 
 // ApplyOCR is a high-level function for taking an existing PDF and applying hOCR overlays.
 // It performs validation and safety checks.
-// It accepts either raw hOCR data ([]byte) or a parsed hOCR struct (*hocr.HOCR).
+// It accepts either raw hOCR or ALTO data ([]byte) or a parsed hOCR struct (*hocr.HOCR).
 func ApplyOCR(
 	inputPDFData []byte,
 	hocrInput interface{},
 	config OCRConfig,
 ) ([]byte, error) {
-	// Handle different input types for hOCR
-	var hocrStruct hocr.HOCR
-	var err error
+	return ApplyOCRContext(context.Background(), inputPDFData, hocrInput, config)
+}
 
-	switch h := hocrInput.(type) {
-	case []byte:
-		// Parse raw hOCR data
-		hocrStruct, err = hocr.ParseHOCR(h)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse HOCR data: %w", err)
-		}
-	case *hocr.HOCR:
-		// Use the provided struct directly
-		if h == nil {
-			return nil, fmt.Errorf("HOCR struct is nil")
-		}
-		hocrStruct = *h
-	default:
-		return nil, fmt.Errorf("unsupported HOCR input type: %T", hocrInput)
+// ApplyOCRContext works like ApplyOCR but stops between pages once the
+// context is cancelled or its deadline passes, returning the context error.
+func ApplyOCRContext(
+	ctx context.Context,
+	inputPDFData []byte,
+	hocrInput interface{},
+	config OCRConfig,
+) ([]byte, error) {
+	result, err := ApplyOCRWithResultContext(ctx, inputPDFData, hocrInput, config)
+	if err != nil {
+		return nil, err
+	}
+	return result.PDF, nil
+}
+
+// ApplyOCRWithResult works like ApplyOCR but returns an ApplyResult that reports
+// how the OCR layer was rendered, including per-word encoding fallbacks.
+func ApplyOCRWithResult(
+	inputPDFData []byte,
+	hocrInput interface{},
+	config OCRConfig,
+) (*ApplyResult, error) {
+	return ApplyOCRWithResultContext(context.Background(), inputPDFData, hocrInput, config)
+}
+
+// ApplyOCRWithResultContext combines ApplyOCRWithResult and ApplyOCRContext
+func ApplyOCRWithResultContext(
+	ctx context.Context,
+	inputPDFData []byte,
+	hocrInput interface{},
+	config OCRConfig,
+) (*ApplyResult, error) {
+	hocrStruct, err := parseHOCRInput(hocrInput)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate inputs
 	if len(inputPDFData) == 0 {
-		return nil, fmt.Errorf("input PDF data is empty")
+		return nil, ErrEmptyPDF
+	}
+	if inputPDFData, err = decryptInput(inputPDFData, config); err != nil {
+		return nil, err
 	}
 	if len(hocrStruct.Pages) == 0 {
-		return nil, fmt.Errorf("HOCR data contains no pages")
+		return nil, fmt.Errorf("HOCR data contains %w", ErrNoPages)
 	}
 	if config.StartPage < 1 {
-		return nil, fmt.Errorf("start page must be at least 1, got %d", config.StartPage)
+		return nil, fmt.Errorf("%w: start page must be at least 1, got %d", ErrInvalidPageRange, config.StartPage)
+	}
+	if config.LayerNameTemplate != "" {
+		if _, err := config.layerNameTemplate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, pageRange := range config.PageRanges {
+		if err := validatePageRange(pageRange); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get the logger
@@ -158,19 +235,25 @@ func ApplyOCR(
 
 	// Collect all warnings and potential errors first
 	var warnings []string
-	var blockers []string // Conditions that would block in strict mode
+	var blockers []error // Conditions that would block in strict mode
 	var hasOCR bool
 	var ocrLayerName string
 	var layerInfo LayerCheckResult
+	var removed *RemoveResult
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Check for existing OCR
+	config.progress(0, 0, ProgressDetect)
 	ocrResult, err := DetectOCR(inputPDFData, config)
 
 	// Process detection results
 	if err != nil {
 		// OCR detection failed
-		detectionErr := fmt.Sprintf("OCR detection failed: %v", err)
-		warnings = append(warnings, detectionErr)
+		detectionErr := fmt.Errorf("%w: %w", ErrOCRDetectionFailed, err)
+		warnings = append(warnings, detectionErr.Error())
 		blockers = append(blockers, detectionErr)
 	} else {
 		// Store layer info for possible display
@@ -179,8 +262,34 @@ func ApplyOCR(
 		// Add detection warnings
 		warnings = append(warnings, ocrResult.Warnings...)
 
-		// Handle existing OCR detection
 		if ocrResult.HasOCR {
+			// Existing OCR is expected, and not a problem, when replacing it or
+			// leaving the pages that have it as they are
+			level := slog.LevelWarn
+			if config.Replace || config.SkipPagesWithText {
+				level = slog.LevelInfo
+			}
+			logEvent(config, level, EventOCRDetected, "PDF already has OCR",
+				"layer", ocrResult.LayerInfo.OCRLayerName, "replace", config.Replace, "skip_pages_with_text", config.SkipPagesWithText, "force", config.Force)
+		}
+
+		// Handle existing OCR detection
+		if ocrResult.HasOCR && config.Replace {
+			// Strip the old layer so the new one doesn't duplicate it
+			config.progress(0, 0, ProgressRemove)
+			removeConfig := config
+			removeConfig.LogWarnings = false
+			removeConfig.EventLogger = nil // Its warnings are reported with the others below
+			removed, err = RemoveOCRWithResult(inputPDFData, removeConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to remove existing OCR: %w", err)
+			}
+			inputPDFData = removed.PDF
+			logEvent(config, slog.LevelInfo, EventOCRRemoved, "existing OCR removed",
+				"layers", removed.LayersRemoved, "text_objects", removed.TextObjectsRemoved)
+			ocrLayerName = ocrResult.LayerInfo.OCRLayerName
+			warnings = append(warnings, removed.Warnings...)
+		} else if ocrResult.HasOCR && !config.SkipPagesWithText {
 			hasOCR = true
 			ocrLayerName = ocrResult.LayerInfo.OCRLayerName
 
@@ -188,11 +297,13 @@ func ApplyOCR(
 			layerText := ""
 			if ocrLayerName != "" {
 				layerText = fmt.Sprintf(" (layer '%s')", ocrLayerName)
+			} else if ocrResult.HasInvisibleTextOCR {
+				layerText = " (invisible text)"
 			}
 
-			ocrMsg := fmt.Sprintf("file already has OCR%s", layerText)
-			warnings = append(warnings, ocrMsg)
-			blockers = append(blockers, ocrMsg)
+			ocrErr := fmt.Errorf("%w%s", ErrAlreadyHasOCR, layerText)
+			warnings = append(warnings, ocrErr.Error())
+			blockers = append(blockers, ocrErr)
 		}
 	}
 
@@ -201,9 +312,11 @@ func ApplyOCR(
 
 	// If we have blockers and we're in strict mode without force, we should stop
 	if len(blockers) > 0 && config.Strict && !config.Force {
-		return nil, fmt.Errorf("%s - set Force option to override", blockers[0])
+		return nil, fmt.Errorf("%w - set Force option to override", blockers[0])
 	}
 
+	logWarningEvents(config, warnings)
+
 	// Log warnings and info if we're proceeding
 	if shouldProceed && config.LogWarnings {
 		// If force is enabled, explain we're overriding potential issues
@@ -211,6 +324,15 @@ func ApplyOCR(
 			fmt.Fprintln(logger, "Force mode enabled: proceeding regardless of OCR detection issues")
 		}
 
+		if removed != nil {
+			layerText := "invisible text"
+			if ocrLayerName != "" {
+				layerText = fmt.Sprintf("layer '%s'", ocrLayerName)
+			}
+			fmt.Fprintf(logger, "Replacing existing OCR (%s): removed %d layer(s) and %d invisible text object(s)\n",
+				layerText, removed.LayersRemoved, removed.TextObjectsRemoved)
+		}
+
 		// Display layer information if available
 		if len(layerInfo.Layers) > 0 {
 			fmt.Fprintln(logger, "Existing layers detected in PDF:")
@@ -244,17 +366,78 @@ func ApplyOCR(
 	}
 
 	// Proceed with PDF modification
-	finalPDF, err := modifyExistingPDF(
-		inputPDFData,
-		hocrStruct,
-		config.StartPage,
-		config.Debug,
-		config.LayerName,
-		config.Font,
-	)
+	result := &ApplyResult{Warnings: warnings}
+	if removed != nil {
+		result.ReplacedLayers = removed.LayersRemoved
+	}
+	finalPDF, err := modifyExistingPDF(ctx, inputPDFData, hocrStruct, config, result)
 	if err != nil {
 		return nil, fmt.Errorf("error modifying existing PDF: %w", err)
 	}
+	result.PDF = finalPDF
+
+	if len(result.SkippedPages) > 0 {
+		logEvent(config, slog.LevelInfo, EventPagesSkipped, "pages with text left without OCR", "pages", result.SkippedPages)
+		if config.LogWarnings {
+			pages := make([]string, len(result.SkippedPages))
+			for i, page := range result.SkippedPages {
+				pages[i] = strconv.Itoa(page)
+			}
+			fmt.Fprintf(logger, "Left %d page(s) that already have text without OCR: %s\n",
+				len(pages), strings.Join(pages, ", "))
+		}
+	}
+
+	// Detection warnings were already logged above
+	logResult(&ApplyResult{
+		Warnings:       result.Warnings[len(warnings):],
+		EncodingIssues: result.EncodingIssues,
+	}, config, logger)
+
+	return result, nil
+}
+
+// parseHOCRInput handles the different input types accepted for hOCR data
+func parseHOCRInput(hocrInput interface{}) (hocr.HOCR, error) {
+	switch h := hocrInput.(type) {
+	case []byte:
+		// Parse raw hOCR (or ALTO) data
+		hocrStruct, err := hocr.Parse(h)
+		if err != nil {
+			return hocr.HOCR{}, fmt.Errorf("%w: failed to parse HOCR data: %w", ErrInvalidHOCR, err)
+		}
+		return hocrStruct, nil
+	case *hocr.HOCR:
+		// Use the provided struct directly
+		if h == nil {
+			return hocr.HOCR{}, fmt.Errorf("%w: HOCR struct is nil", ErrInvalidHOCR)
+		}
+		return *h, nil
+	default:
+		return hocr.HOCR{}, fmt.Errorf("%w: unsupported HOCR input type: %T", ErrInvalidHOCR, hocrInput)
+	}
+}
+
+// logResult prints rendering warnings and, in debug mode, the encoding fallbacks applied
+func logResult(result *ApplyResult, config OCRConfig, logger io.Writer) {
+	logWarningEvents(config, result.Warnings)
+	if !config.LogWarnings {
+		return
+	}
 
-	return finalPDF, nil
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(logger, "Warning:", warning)
+	}
+
+	if config.Debug && result.OptimizedImages > 0 {
+		fmt.Fprintf(logger, "Debug: %d page image(s) downsampled or re-encoded, saving %d bytes\n", result.OptimizedImages, result.ImageBytesSaved)
+	}
+
+	if config.Debug && len(result.EncodingIssues) > 0 {
+		fmt.Fprintf(logger, "Debug: %d word(s) needed an encoding fallback:\n", len(result.EncodingIssues))
+		for _, issue := range result.EncodingIssues {
+			fmt.Fprintf(logger, "  page %d %s: %q -> %q (%s)\n",
+				issue.Page, issue.WordID, issue.Text, issue.Rendered, issue.Action)
+		}
+	}
 }
@@ -0,0 +1,1003 @@
+package pdfocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf16"
+)
+
+var (
+	// pdfObjectPattern matches the header of an indirect object
+	pdfObjectPattern = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+
+	// pdfStreamPattern matches the end of a stream dictionary and the stream keyword
+	pdfStreamPattern = regexp.MustCompile(`>>\s*stream\r?\n`)
+
+	// pdfReferencePattern matches a named indirect reference inside a dictionary
+	pdfReferencePattern = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R`)
+
+	// pdfVersionPattern extracts the version from the file header
+	pdfVersionPattern = regexp.MustCompile(`^%PDF-(\d\.\d)`)
+
+	// pdfStreamKeysPattern matches the dictionary entries that describe a stream's encoding
+	pdfStreamKeysPattern = regexp.MustCompile(`/(Length\s+\d+(\s+\d+\s+R)?|Filter\s*(/\w+|\[[^\]]*\])|DecodeParms\s*(<<[^>]*>>|\[[^\]]*\]|null))`)
+
+	// pdfInlineImageEndPattern matches the end of inline image data
+	pdfInlineImageEndPattern = regexp.MustCompile(`\sEI(\s|$)`)
+)
+
+// pdfKeyPatterns caches the patterns of pdfKeyPattern by their source
+var pdfKeyPatterns sync.Map
+
+// pdfKeyPattern returns the pattern matching a dictionary key followed by a
+// value pattern, compiled once for every key and value pattern
+func pdfKeyPattern(key, value string) *regexp.Regexp {
+	source := `/` + regexp.QuoteMeta(key) + value
+	if pattern, ok := pdfKeyPatterns.Load(source); ok {
+		return pattern.(*regexp.Regexp)
+	}
+	pattern, _ := pdfKeyPatterns.LoadOrStore(source, regexp.MustCompile(source))
+	return pattern.(*regexp.Regexp)
+}
+
+// pdfKeyIndex returns the submatch indices of the first entry of a dictionary
+// whose key is followed by the value pattern. Only the dictionary's own
+// entries count: keys of nested dictionaries and arrays, and text inside
+// strings, are skipped. The dictionary may be given with or without its
+// brackets.
+func pdfKeyIndex(dict []byte, key, value string) []int {
+	matches := pdfKeyPattern(key, value).FindAllSubmatchIndex(dict, -1)
+	if matches == nil {
+		return nil
+	}
+	top := 0
+	if bytes.HasPrefix(bytes.TrimLeft(dict, pdfWhitespace), []byte("<<")) {
+		top = 1
+	}
+	scanner := pdfNestingScanner{data: dict}
+	for _, match := range matches {
+		if scanner.depthAt(match[0]) == top {
+			return match
+		}
+	}
+	return nil
+}
+
+// pdfKeySubmatch is like pdfKeyIndex, but returns the matched text and submatches
+func pdfKeySubmatch(dict []byte, key, value string) [][]byte {
+	loc := pdfKeyIndex(dict, key, value)
+	if loc == nil {
+		return nil
+	}
+	match := make([][]byte, len(loc)/2)
+	for i := range match {
+		if loc[2*i] >= 0 {
+			match[i] = dict[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return match
+}
+
+// pdfWhitespace holds the PDF white-space characters
+const pdfWhitespace = "\x00\t\n\f\r "
+
+// pdfNestingScanner tracks how deeply dictionaries and arrays are nested at
+// increasing positions of PDF data
+type pdfNestingScanner struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// depthAt returns the nesting depth at a position, which must not lie before
+// the previous one, or -1 if the position is inside a string or comment
+func (s *pdfNestingScanner) depthAt(target int) int {
+	data := s.data
+	for s.pos < target {
+		switch c := data[s.pos]; {
+		case c == '(':
+			// Literal strings may nest balanced parentheses and escape others
+			nesting := 0
+			for ; s.pos < len(data); s.pos++ {
+				if data[s.pos] == '\\' {
+					s.pos++
+				} else if data[s.pos] == '(' {
+					nesting++
+				} else if data[s.pos] == ')' {
+					if nesting--; nesting == 0 {
+						break
+					}
+				}
+			}
+			s.pos++
+		case c == '%':
+			for s.pos < len(data) && data[s.pos] != '\r' && data[s.pos] != '\n' {
+				s.pos++
+			}
+		case c == '<' && s.pos+1 < len(data) && data[s.pos+1] == '<':
+			s.depth++
+			s.pos += 2
+		case c == '>' && s.pos+1 < len(data) && data[s.pos+1] == '>':
+			s.depth--
+			s.pos += 2
+		case c == '<':
+			// Hex string
+			if end := bytes.IndexByte(data[s.pos:], '>'); end >= 0 {
+				s.pos += end + 1
+			} else {
+				s.pos = len(data)
+			}
+		case c == '[':
+			s.depth++
+			s.pos++
+		case c == ']':
+			s.depth--
+			s.pos++
+		default:
+			s.pos++
+		}
+	}
+	if s.pos > target {
+		return -1
+	}
+	return s.depth
+}
+
+// pdfFile gives access to the objects of a PDF. It reads top-level objects
+// and objects stored in object streams, and can write the objects back out
+// as a new, compacted file.
+type pdfFile struct {
+	version string         // PDF version from the header, e.g. "1.4"
+	objects map[int][]byte // Object contents between "obj" and "endobj"
+	trailer []byte         // Trailer dictionary (or cross-reference stream dictionary)
+
+	// positions are where the current definition of each parsed object
+	// starts, or the object stream holding it starts, in file order
+	positions map[int]int
+
+	// truncated lists the objects whose end is missing, in file order. They
+	// are left out, keeping any earlier definition.
+	truncated []int
+}
+
+// parsePDFObjects indexes the indirect objects of a PDF by object number.
+// When an object appears more than once, as in incrementally updated files,
+// the last definition wins, including definitions in object streams, which
+// count as written where their object stream is. An object cut off before its
+// end is skipped, and the objects after it are still read.
+func parsePDFObjects(data []byte) *pdfFile {
+	file := &pdfFile{version: "1.4", objects: make(map[int][]byte), positions: make(map[int]int)}
+	if match := pdfVersionPattern.FindSubmatch(data); match != nil {
+		file.version = string(match[1])
+	}
+
+	pos := 0
+	for {
+		loc := pdfObjectPattern.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+		end := pdfObjectEnd(data, start)
+		if end < 0 {
+			file.truncated = append(file.truncated, num)
+			pos = start
+			continue
+		}
+		file.objects[num] = data[start:end]
+		file.positions[num] = start
+		pos = end + len("endobj")
+	}
+
+	// The newest cross-reference section holds the current document catalog
+	if trailer := pdfNewestTrailer(data); trailer != nil {
+		file.trailer = trailer
+	} else if idx := bytes.LastIndex(data, []byte("trailer")); idx >= 0 {
+		file.trailer = data[idx+len("trailer"):]
+		if end := bytes.Index(file.trailer, []byte("startxref")); end >= 0 {
+			file.trailer = file.trailer[:end]
+		}
+	} else {
+		// Cross-reference streams carry the trailer entries in their dictionary
+		for _, num := range file.sortedObjects() {
+			dict := file.dict(num)
+			if bytes.Contains(dict, []byte("/XRef")) && bytes.Contains(dict, []byte("/Root")) {
+				file.trailer = dict
+			}
+		}
+	}
+
+	file.loadObjectStreams()
+	return file
+}
+
+// pdfNewestTrailer returns the trailer dictionary of the cross-reference
+// section the last startxref points to, which is the dictionary of a
+// cross-reference stream in files that use them. When that section has no
+// document catalog, the older sections are followed through /Prev. It
+// returns nil when the offsets don't lead to a trailer with a catalog, as in
+// damaged files.
+func pdfNewestTrailer(data []byte) []byte {
+	idx := bytes.LastIndex(data, []byte("startxref"))
+	if idx < 0 {
+		return nil
+	}
+	fields := bytes.Fields(data[idx+len("startxref"):])
+	if len(fields) == 0 {
+		return nil
+	}
+	offset, err := strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	for offset > 0 && offset < len(data) && !seen[offset] {
+		seen[offset] = true
+		trailer := pdfTrailerAt(data, offset)
+		if trailer == nil {
+			return nil
+		}
+		if pdfKeyIndex(trailer, "Root", `\s+\d+\s+\d+\s+R`) != nil {
+			return trailer
+		}
+		match := pdfKeySubmatch(trailer, "Prev", `\s+(\d+)`)
+		if match == nil {
+			return nil
+		}
+		offset, _ = strconv.Atoi(string(match[1]))
+	}
+	return nil
+}
+
+// pdfTrailerAt returns the trailer dictionary of the cross-reference table or
+// stream at an offset, or nil if there is none
+func pdfTrailerAt(data []byte, offset int) []byte {
+	section := data[offset:]
+	start := len(section) - len(bytes.TrimLeft(section, pdfWhitespace))
+	if bytes.HasPrefix(section[start:], []byte("xref")) {
+		idx := bytes.Index(section, []byte("trailer"))
+		if idx < 0 {
+			return nil
+		}
+		trailer := section[idx+len("trailer"):]
+		if end := bytes.Index(trailer, []byte("startxref")); end >= 0 {
+			trailer = trailer[:end]
+		}
+		return trailer
+	}
+
+	loc := pdfObjectPattern.FindIndex(section)
+	if loc == nil || loc[0] != start {
+		return nil
+	}
+	end := pdfObjectEnd(section, loc[1])
+	if end < 0 {
+		return nil
+	}
+	body := section[loc[1]:end]
+	stream := pdfStreamPattern.FindIndex(body)
+	if stream == nil {
+		return nil
+	}
+	dict := body[:stream[0]+2]
+	if pdfKeyIndex(dict, "Type", `\s*/XRef\b`) == nil {
+		return nil
+	}
+	return dict
+}
+
+// pdfObjectEnd returns the position of the endobj keyword of an object whose
+// contents start at the given position, skipping over stream data, or -1 if
+// the object is cut off: the data ends, or another object starts, first
+func pdfObjectEnd(data []byte, start int) int {
+	endobj := bytes.Index(data[start:], []byte("endobj"))
+	if endobj < 0 {
+		return -1
+	}
+	endobj += start
+
+	loc := pdfStreamPattern.FindIndex(data[start:endobj])
+	if loc == nil {
+		if pdfObjectPattern.Match(data[start:endobj]) {
+			return -1
+		}
+		return endobj
+	}
+	if pdfObjectPattern.Match(data[start : start+loc[0]]) {
+		return -1
+	}
+
+	// Stream data can contain anything, so look for endobj after endstream
+	streamStart := start + loc[1]
+	length := -1
+	if match := pdfKeySubmatch(data[start:start+loc[0]], "Length", `\s+(\d+)(\s+\d+\s+R)?`); match != nil && len(match[2]) == 0 {
+		length, _ = strconv.Atoi(string(match[1]))
+	}
+	searchFrom := streamStart
+	if length >= 0 && streamStart+length <= len(data) {
+		searchFrom = streamStart + length
+	}
+	endstream := bytes.Index(data[searchFrom:], []byte("endstream"))
+	if endstream < 0 {
+		return -1
+	}
+	endstream += searchFrom
+	endobj = bytes.Index(data[endstream:], []byte("endobj"))
+	if endobj < 0 || pdfObjectPattern.Match(data[endstream:endstream+endobj]) {
+		return -1
+	}
+	return endstream + endobj
+}
+
+// loadObjectStreams adds the objects stored in object streams. Object
+// streams are read in file order, and an object is only replaced by a
+// definition written after it, so the objects an incremental update stores
+// in an object stream replace the old ones.
+func (f *pdfFile) loadObjectStreams() {
+	positions := f.positions
+	var streams []int
+	for _, num := range f.sortedObjects() {
+		if pdfKeyIndex(f.dict(num), "Type", `\s*/ObjStm\b`) != nil {
+			streams = append(streams, num)
+		}
+	}
+	sort.SliceStable(streams, func(i, j int) bool { return positions[streams[i]] < positions[streams[j]] })
+
+	for _, num := range streams {
+		dict := f.dict(num)
+		data, err := f.stream(num)
+		if err != nil {
+			continue
+		}
+		count := f.intValue(dict, "N")
+		first := f.intValue(dict, "First")
+		if first <= 0 || first > len(data) {
+			continue
+		}
+
+		// The header lists pairs of object numbers and offsets relative to First
+		fields := bytes.Fields(data[:first])
+		var nums, offsets []int
+		for i := 0; i+1 < len(fields) && len(nums) < count; i += 2 {
+			objNum, _ := strconv.Atoi(string(fields[i]))
+			offset, _ := strconv.Atoi(string(fields[i+1]))
+			nums = append(nums, objNum)
+			offsets = append(offsets, first+offset)
+		}
+		for i, objNum := range nums {
+			if written, ok := positions[objNum]; ok && written > positions[num] {
+				continue
+			}
+			end := len(data)
+			if i+1 < len(offsets) {
+				end = offsets[i+1]
+			}
+			if offsets[i] <= end && end <= len(data) {
+				f.objects[objNum] = append([]byte{'\n'}, data[offsets[i]:end]...)
+				positions[objNum] = positions[num]
+			}
+		}
+	}
+}
+
+// sortedObjects returns the object numbers in ascending order
+func (f *pdfFile) sortedObjects() []int {
+	nums := make([]int, 0, len(f.objects))
+	for num := range f.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// dict returns the dictionary part of an object, before any stream data
+func (f *pdfFile) dict(num int) []byte {
+	body := f.objects[num]
+	if loc := pdfStreamPattern.FindIndex(body); loc != nil {
+		return body[:loc[0]+2]
+	}
+	return body
+}
+
+// ref returns the object number of a reference stored under a key, or 0
+func (f *pdfFile) ref(dict []byte, key string) int {
+	match := pdfKeySubmatch(dict, key, `\s+(\d+)\s+\d+\s+R`)
+	if match == nil {
+		return 0
+	}
+	num, _ := strconv.Atoi(string(match[1]))
+	return num
+}
+
+// intValue returns the integer stored directly under a key, or 0
+func (f *pdfFile) intValue(dict []byte, key string) int {
+	match := pdfKeySubmatch(dict, key, `\s+(\d+)\b(\s+\d+\s+R)?`)
+	if match == nil || len(match[2]) > 0 {
+		return 0
+	}
+	value, _ := strconv.Atoi(string(match[1]))
+	return value
+}
+
+// subdict returns the contents of the dictionary stored under a key, resolving references
+func (f *pdfFile) subdict(dict []byte, key string) []byte {
+	if num := f.ref(dict, key); num > 0 {
+		return f.dict(num)
+	}
+	start := pdfKeyIndex(dict, key, `\s*<<`)
+	if start == nil {
+		return nil
+	}
+
+	// Find the matching closing brackets
+	depth := 0
+	for i := start[1] - 2; i+1 < len(dict); i++ {
+		switch {
+		case dict[i] == '<' && dict[i+1] == '<':
+			depth++
+			i++
+		case dict[i] == '>' && dict[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return dict[start[1] : i-1]
+			}
+		}
+	}
+	return nil
+}
+
+// wholeDict returns the dictionary stored under a key with its brackets,
+// resolving references, so it can be passed on to the lexer
+func (f *pdfFile) wholeDict(dict []byte, key string) []byte {
+	if num := f.ref(dict, key); num > 0 {
+		return f.dict(num)
+	}
+	if contents := f.subdict(dict, key); contents != nil {
+		return append(append([]byte("<<"), contents...), ">>"...)
+	}
+	return nil
+}
+
+// value returns the value stored under a key of a dictionary, resolving an
+// indirect reference to the object it points to
+func (f *pdfFile) value(dict []byte, key string) (pdfToken, bool) {
+	if num := f.ref(dict, key); num > 0 {
+		lexer := &pdfLexer{data: f.dict(num)}
+		return lexer.next()
+	}
+	lexer := &pdfLexer{data: dict}
+	token, ok := lexer.next()
+	if !ok || token.kind != pdfDict {
+		return pdfToken{}, false
+	}
+	value, ok := token.dict[key]
+	return value, ok
+}
+
+// resources returns the resource dictionary of a page, which may be inherited from the page tree
+func (f *pdfFile) resources(page int) []byte {
+	seen := make(map[int]bool)
+	for num := page; num > 0 && !seen[num]; num = f.ref(f.dict(num), "Parent") {
+		seen[num] = true
+		if resources := f.subdict(f.dict(num), "Resources"); resources != nil {
+			return resources
+		}
+	}
+	return nil
+}
+
+// setDict replaces the dictionary part of an object, keeping any stream data
+func (f *pdfFile) setDict(num int, dict []byte) {
+	body := f.objects[num]
+	rest := body[len(f.dict(num)):]
+	f.objects[num] = append(append([]byte{}, dict...), rest...)
+}
+
+// refs returns the named references of a dictionary
+func (f *pdfFile) refs(dict []byte) map[string]int {
+	result := make(map[string]int)
+	for _, match := range pdfReferencePattern.FindAllSubmatch(dict, -1) {
+		num, _ := strconv.Atoi(string(match[2]))
+		result[string(match[1])] = num
+	}
+	return result
+}
+
+// rawStream returns the stream data of an object as stored, without decoding it
+func (f *pdfFile) rawStream(num int) ([]byte, error) {
+	body := f.objects[num]
+	loc := pdfStreamPattern.FindIndex(body)
+	if loc == nil {
+		return nil, fmt.Errorf("object %d has no stream", num)
+	}
+	dict := body[:loc[0]+2]
+
+	data := body[loc[1]:]
+	if length := f.intValue(dict, "Length"); length > 0 && length <= len(data) {
+		data = data[:length]
+	} else if end := bytes.LastIndex(data, []byte("endstream")); end >= 0 {
+		data = bytes.TrimRight(data[:end], "\r\n")
+	}
+	return data, nil
+}
+
+// stream returns the decoded stream data of an object. Only unfiltered and
+// Flate-compressed streams without predictors are supported.
+func (f *pdfFile) stream(num int) ([]byte, error) {
+	data, err := f.rawStream(num)
+	if err != nil {
+		return nil, err
+	}
+	dict := f.dict(num)
+
+	filter := pdfKeySubmatch(dict, "Filter", `\s*(/\w+|\[[^\]]*\])`)
+	if filter == nil {
+		return data, nil
+	}
+	filters := bytes.Fields(bytes.Trim(filter[1], "[]"))
+	if len(filters) != 1 || string(filters[0]) != "/FlateDecode" || bytes.Contains(dict, []byte("/Predictor")) {
+		return nil, fmt.Errorf("object %d uses unsupported stream filter %s", num, filter[1])
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", num, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// setStream replaces the stream data of an object with Flate-compressed data,
+// keeping the other dictionary entries
+func (f *pdfFile) setStream(num int, data []byte) {
+	dict := pdfStreamKeysPattern.ReplaceAll(f.dict(num), nil)
+	dict = bytes.TrimSuffix(bytes.TrimSpace(dict), []byte(">>"))
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(data)
+	writer.Close()
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "\n%s /Length %d /Filter /FlateDecode>>\nstream\n", dict, compressed.Len())
+	body.Write(compressed.Bytes())
+	body.WriteString("\nendstream\n")
+	f.objects[num] = body.Bytes()
+}
+
+// hasType reports whether an object is a dictionary of the given /Type
+func (f *pdfFile) hasType(num int, typ string) bool {
+	token, ok := f.value(f.dict(num), "Type")
+	return ok && token.kind == pdfName && token.text == typ
+}
+
+// pages returns the page objects in document order
+func (f *pdfFile) pages() []int {
+	root := f.ref(f.dict(f.ref(f.trailer, "Root")), "Pages")
+	if root == 0 {
+		// Fall back to scanning for the catalog
+		for _, num := range f.sortedObjects() {
+			if pdfKeyIndex(f.dict(num), "Type", `\s*/Catalog\b`) != nil {
+				root = f.ref(f.dict(num), "Pages")
+			}
+		}
+	}
+
+	var pages []int
+	seen := make(map[int]bool)
+	var walk func(num int)
+	walk = func(num int) {
+		if seen[num] {
+			return
+		}
+		seen[num] = true
+		dict := f.dict(num)
+		if pdfKeyIndex(dict, "Kids", `\s*\[`) == nil {
+			pages = append(pages, num)
+			return
+		}
+		for _, child := range pdfReferenceList(dict, "Kids") {
+			walk(child)
+		}
+	}
+	if root > 0 {
+		walk(root)
+	}
+	return pages
+}
+
+// bytes writes the objects out as a complete PDF with a classic
+// cross-reference table. Object and cross-reference streams are left out,
+// since their contents are written as regular objects.
+func (f *pdfFile) bytes() ([]byte, error) {
+	if bytes.Contains(f.trailer, []byte("/Encrypt")) {
+		return nil, ErrEncryptedPDF
+	}
+	root := f.ref(f.trailer, "Root")
+	if root == 0 {
+		return nil, fmt.Errorf("PDF trailer has no document catalog")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", f.version)
+
+	nums := f.sortedObjects()
+	offsets := make(map[int]int)
+	size := 1
+	for _, num := range nums {
+		dict := f.dict(num)
+		if pdfKeyIndex(dict, "Type", `\s*/(ObjStm|XRef)\b`) != nil {
+			continue
+		}
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj", num)
+		out.Write(f.objects[num])
+		out.WriteString("\nendobj\n")
+		size = max(size, num+1)
+	}
+
+	// One subsection per run of consecutive object numbers, so the table
+	// grows with the objects written rather than with the highest number
+	written := make([]int, 0, len(offsets))
+	for num := range offsets {
+		written = append(written, num)
+	}
+	sort.Ints(written)
+	xref := out.Len()
+	out.WriteString("xref\n0 1\n0000000000 65535 f \n")
+	for start := 0; start < len(written); {
+		end := start + 1
+		for end < len(written) && written[end] == written[end-1]+1 {
+			end++
+		}
+		fmt.Fprintf(&out, "%d %d\n", written[start], end-start)
+		for _, num := range written[start:end] {
+			fmt.Fprintf(&out, "%010d 00000 n \n", offsets[num])
+		}
+		start = end
+	}
+
+	fmt.Fprintf(&out, "trailer\n<</Size %d /Root %d 0 R", size, root)
+	if info := f.ref(f.trailer, "Info"); info > 0 && offsets[info] > 0 {
+		fmt.Fprintf(&out, " /Info %d 0 R", info)
+	}
+	if id := pdfKeySubmatch(f.trailer, "ID", `\s*\[[^\]]*\]`); id != nil {
+		out.WriteString(" ")
+		out.Write(id[0])
+	}
+	fmt.Fprintf(&out, ">>\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	return out.Bytes(), nil
+}
+
+// pdfReferenceList returns the object numbers referenced by a key holding a single reference or an array
+func pdfReferenceList(dict []byte, key string) []int {
+	match := pdfKeySubmatch(dict, key, `\s*(\[[^\]]*\]|\d+\s+\d+\s+R)`)
+	if match == nil {
+		return nil
+	}
+	var nums []int
+	for _, ref := range pdfRefPattern.FindAllSubmatch(match[1], -1) {
+		num, _ := strconv.Atoi(string(ref[1]))
+		nums = append(nums, num)
+	}
+	return nums
+}
+
+// pdfRemoveEntry removes a key and its value from the top level of a dictionary
+func pdfRemoveEntry(dict []byte, key string) []byte {
+	open := bytes.Index(dict, []byte("<<"))
+	if open < 0 {
+		return dict
+	}
+
+	lexer := &pdfLexer{data: dict, pos: open + 2}
+	for {
+		name, ok := lexer.next()
+		if !ok || name.kind != pdfName {
+			return dict
+		}
+		start := lexer.start
+		value, ok := lexer.next()
+		if !ok {
+			return dict
+		}
+
+		// An indirect reference spans three tokens
+		if value.kind == pdfNumber {
+			pos := lexer.pos
+			generation, ok1 := lexer.next()
+			keyword, ok2 := lexer.next()
+			if !ok1 || !ok2 || generation.kind != pdfNumber || keyword.text != "R" {
+				lexer.pos = pos
+			}
+		}
+
+		if name.text == key {
+			return append(append([]byte{}, dict[:start]...), dict[lexer.pos:]...)
+		}
+	}
+}
+
+// pdfStringValue returns the decoded text string stored under a key of an object's dictionary
+func pdfStringValue(dict []byte, key string) (string, bool) {
+	lexer := &pdfLexer{data: dict}
+	token, ok := lexer.next()
+	if !ok || token.kind != pdfDict {
+		return "", false
+	}
+	value, ok := token.dict[key]
+	if !ok || value.kind != pdfString {
+		return "", false
+	}
+	return decodePDFTextString(value.bytes), true
+}
+
+// decodePDFTextString decodes a PDF text string (UTF-16BE with BOM or PDFDocEncoding)
+func decodePDFTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return utf16BEString(b[2:])
+	}
+	// PDFDocEncoding matches Latin-1 for the printable characters
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// utf16BEString decodes UTF-16BE without a byte order mark, including surrogate pairs
+func utf16BEString(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfTokenKind is the type of a content stream token
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfNumber
+	pdfName
+	pdfString
+	pdfArray
+	pdfDict
+)
+
+// pdfToken is a lexical token of a content stream, CMap or dictionary
+type pdfToken struct {
+	kind  pdfTokenKind
+	text  string              // Operator, number or name (without the slash)
+	bytes []byte              // Decoded string data
+	items []pdfToken          // Array elements
+	dict  map[string]pdfToken // Dictionary entries
+}
+
+// pdfLexer splits PDF syntax into tokens, parsing arrays and dictionaries as single tokens
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	start int // Position where the last returned token starts
+}
+
+// isPDFDelimiter reports whether a byte ends a regular token
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// next returns the next token, or false at the end of the data
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		l.start = l.pos
+		c := l.data[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0:
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '/':
+			l.pos++
+			return pdfToken{kind: pdfName, text: l.regular()}, true
+		case c == '(':
+			return pdfToken{kind: pdfString, bytes: l.literalString()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return l.dictionary(), true
+		case c == '<':
+			return pdfToken{kind: pdfString, bytes: l.hexString()}, true
+		case c == '[':
+			l.pos++
+			return l.array(), true
+		case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+			// Closing delimiters are consumed by the compound parsers
+			l.pos++
+			return pdfToken{kind: pdfOperator, text: string(c)}, true
+		default:
+			text := l.regular()
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				return pdfToken{kind: pdfNumber, text: text}, true
+			}
+			return pdfToken{kind: pdfOperator, text: text}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// regular reads a run of regular characters
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start && l.pos < len(l.data) {
+		l.pos++ // Skip a stray delimiter so lexing always advances
+	}
+	return string(l.data[start:l.pos])
+}
+
+// array reads array elements up to the closing bracket
+func (l *pdfLexer) array() pdfToken {
+	token := pdfToken{kind: pdfArray}
+	for l.pos < len(l.data) {
+		l.skipSpace()
+		if l.pos < len(l.data) && l.data[l.pos] == ']' {
+			l.pos++
+			break
+		}
+		item, ok := l.next()
+		if !ok {
+			break
+		}
+		token.items = append(token.items, item)
+	}
+	return token
+}
+
+// dictionary reads key/value pairs up to the closing brackets
+func (l *pdfLexer) dictionary() pdfToken {
+	token := pdfToken{kind: pdfDict, dict: make(map[string]pdfToken)}
+	var key string
+	for l.pos < len(l.data) {
+		l.skipSpace()
+		if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			break
+		}
+		item, ok := l.next()
+		if !ok {
+			break
+		}
+		if key == "" && item.kind == pdfName {
+			key = item.text
+			continue
+		}
+		if key != "" {
+			token.dict[key] = item
+			key = ""
+		}
+	}
+	return token
+}
+
+// skipSpace advances past whitespace
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch l.data[l.pos] {
+		case ' ', '\t', '\r', '\n', '\f', 0:
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+// literalString reads a parenthesized string, resolving escape sequences
+func (l *pdfLexer) literalString() []byte {
+	l.pos++ // opening parenthesis
+	var result []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return result
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return result
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for n := 0; n < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; n++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(value)
+				} else {
+					c = e
+				}
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// hexString reads a hexadecimal string
+func (l *pdfLexer) hexString() []byte {
+	l.pos++ // opening angle bracket
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	result := make([]byte, len(digits)/2)
+	for i := range result {
+		value, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		result[i] = byte(value)
+	}
+	return result
+}
+
+// skipInlineImage moves past the binary data of an inline image, which
+// follows the ID operator and ends with the EI operator
+func (l *pdfLexer) skipInlineImage() {
+	end := pdfInlineImageEndPattern.FindIndex(l.data[l.pos:])
+	if end == nil {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += end[1]
+}
@@ -0,0 +1,118 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPDFKeyLookupTopLevel(t *testing.T) {
+	// Every key also appears nested, or inside a string, before the entry
+	dict := []byte(`<< /Type /Catalog /OCProperties << /D << /Pages 9 0 R /Count 7 /Order [1 0 R] >> >>
+/Names [(/Pages 8 0 R) <2F50616765732038> /Count 6] /Lang (a\) /Count 5) % /Count 4
+/Pages 2 0 R /Count 3 /Order << /Nested true >> >>`)
+	file := &pdfFile{}
+
+	if got := file.ref(dict, "Pages"); got != 2 {
+		t.Errorf("ref(Pages) = %d, want 2", got)
+	}
+	if got := file.intValue(dict, "Count"); got != 3 {
+		t.Errorf("intValue(Count) = %d, want 3", got)
+	}
+	if got := string(file.subdict(dict, "Order")); got != " /Nested true " {
+		t.Errorf("subdict(Order) = %q, want the top-level dictionary", got)
+	}
+	if got := file.ref(dict, "D"); got != 0 {
+		t.Errorf("ref(D) = %d, want 0 for a nested key", got)
+	}
+
+	// Dictionary contents without their brackets work the same way
+	contents := file.subdict(dict, "OCProperties")
+	if got := string(file.subdict(contents, "D")); !strings.Contains(got, "/Pages 9 0 R") {
+		t.Errorf("subdict(D) = %q, want the group configuration", got)
+	}
+}
+
+func TestParsePDFObjectsTrailer(t *testing.T) {
+	base := testLayeredPDF()
+	prev := bytes.LastIndex(base, []byte("\nxref\n")) + 1
+
+	tests := []struct {
+		name   string
+		update func(base []byte) []byte
+		root   int
+	}{
+		{
+			name:   "no update",
+			update: func(base []byte) []byte { return base },
+			root:   1,
+		},
+		{
+			// The update writes a new catalog and a cross-reference stream,
+			// so the last "trailer" keyword belongs to the original file
+			name: "cross-reference stream update",
+			update: func(base []byte) []byte {
+				out := bytes.NewBuffer(append([]byte{}, base...))
+				out.WriteString("11 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+				xref := out.Len()
+				fmt.Fprintf(out, "12 0 obj\n<< /Type /XRef /Size 13 /Root 11 0 R /Prev %d /W [1 2 1] /Length 0 >>\nstream\n\nendstream\nendobj\n", prev)
+				fmt.Fprintf(out, "startxref\n%d\n%%%%EOF\n", xref)
+				return out.Bytes()
+			},
+			root: 11,
+		},
+		{
+			// The update's trailer leaves the catalog to the older section
+			name: "trailer without catalog",
+			update: func(base []byte) []byte {
+				return testPDF(base, []testObject{{7, testFont}},
+					fmt.Sprintf("<< /Size 11 /Prev %d >>", prev))
+			},
+			root: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := parsePDFObjects(tt.update(base))
+			if got := file.ref(file.trailer, "Root"); got != tt.root {
+				t.Errorf("trailer root = %d, want %d", got, tt.root)
+			}
+			if got := file.pages(); !slices.Equal(got, []int{3}) {
+				t.Errorf("pages = %v, want [3]", got)
+			}
+		})
+	}
+}
+
+func TestParsePDFObjectsTruncated(t *testing.T) {
+	// Object 5 is cut off before the objects that follow it
+	pdf := []byte("%PDF-1.5\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"5 0 obj\n<< /Type /Font /Subtype /Type1\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"6 0 obj\n<< /Length 100 >>\nstream\nq Q\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] >>\nendobj\n" +
+		"trailer\n<< /Root 1 0 R /Size 7 >>\n%%EOF\n")
+
+	file := parsePDFObjects(pdf)
+	if !slices.Equal(file.truncated, []int{5, 6}) {
+		t.Errorf("truncated = %v, want [5 6]", file.truncated)
+	}
+	if got := file.pages(); !slices.Equal(got, []int{3}) {
+		t.Errorf("pages = %v, want [3]", got)
+	}
+	if _, ok := file.objects[5]; ok {
+		t.Errorf("truncated object 5 = %q, want it left out", file.objects[5])
+	}
+
+	result, err := DetectOCR(pdf, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(result.Warnings, func(warning string) bool { return strings.Contains(warning, "truncated") }) {
+		t.Errorf("warnings = %q, want one about the truncated objects", result.Warnings)
+	}
+}
@@ -0,0 +1,129 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// Provenance records how the OCR text of a PDF was made. ApplyOCR and
+// AssembleWithOCR write it into the XMP metadata of their output, where
+// DetectOCR and ReadProvenance find it again, so a document can be told apart
+// from one OCR'd by another engine or an older version.
+type Provenance struct {
+	Engine           string    // OCR engine that recognized the text, e.g. "tesseract 5.3.4"
+	ProcessorID      string    // Document AI processor the text came from, if any
+	ProcessorVersion string    // Version of the processor or engine model, if known
+	Timestamp        time.Time // When the OCR text was added
+	Version          string    // Version of ocrchestra that added it
+}
+
+// provenanceNamespace is the XMP namespace of the provenance properties
+const provenanceNamespace = "https://github.com/gardar/ocrchestra/ns/provenance/1.0/"
+
+// modulePath is the module whose version is recorded as Provenance.Version
+const modulePath = "github.com/gardar/ocrchestra"
+
+// provenance returns the configured provenance with the missing fields filled
+// in from the hOCR, the clock and the build
+func (c OCRConfig) provenance(hOCRData hocr.HOCR) Provenance {
+	p := c.Provenance
+	if p.Engine == "" {
+		p.Engine = hOCRData.Metadata["ocr-system"]
+	}
+	if p.Timestamp.IsZero() {
+		p.Timestamp = time.Now()
+	}
+	if p.Version == "" {
+		p.Version = moduleVersion()
+	}
+	return p
+}
+
+// moduleVersion returns the version of ocrchestra built into the program,
+// "(devel)" when built from a checkout
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// ReadProvenance reads the provenance ocrchestra recorded in the XMP metadata
+// of a PDF. It returns false if there is none.
+func ReadProvenance(pdfData []byte) (Provenance, bool) {
+	return parseProvenance(ReadMetadata(pdfData).XMP)
+}
+
+// parseProvenance reads the provenance properties from an XMP packet
+func parseProvenance(xmp []byte) (Provenance, bool) {
+	description := xmpDescription(xmp, provenanceNamespace)
+	if description == nil {
+		return Provenance{}, false
+	}
+	prefix := xmpPrefix(description, provenanceNamespace)
+	property := func(name string) string {
+		element := regexp.MustCompile(`(?s)<` + prefix + `:` + name + `>(.*?)</` + prefix + `:` + name + `>`)
+		if match := element.FindSubmatch(description); match != nil {
+			return html.UnescapeString(string(bytes.TrimSpace(match[1])))
+		}
+		attribute := regexp.MustCompile(`\b` + prefix + `:` + name + `="([^"]*)"`)
+		if match := attribute.FindSubmatch(description); match != nil {
+			return html.UnescapeString(string(match[1]))
+		}
+		return ""
+	}
+
+	p := Provenance{
+		Engine:           property("Engine"),
+		ProcessorID:      property("ProcessorID"),
+		ProcessorVersion: property("ProcessorVersion"),
+		Version:          property("Version"),
+	}
+	p.Timestamp, _ = time.Parse(time.RFC3339, property("Timestamp"))
+	return p, true
+}
+
+// xmpDescriptionXML returns the provenance as an rdf:Description of an XMP packet
+func (p Provenance) xmpDescriptionXML() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<rdf:Description rdf:about=\"\" xmlns:ocrchestra=\"%s\">\n", provenanceNamespace)
+	for _, property := range []struct{ name, value string }{
+		{"Engine", p.Engine},
+		{"ProcessorID", p.ProcessorID},
+		{"ProcessorVersion", p.ProcessorVersion},
+		{"Timestamp", p.Timestamp.Format(time.RFC3339)},
+		{"Version", p.Version},
+	} {
+		if property.value == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "  <ocrchestra:%s>", property.name)
+		xml.EscapeText(&b, []byte(property.value))
+		fmt.Fprintf(&b, "</ocrchestra:%s>\n", property.name)
+	}
+	b.WriteString("</rdf:Description>")
+	return b.Bytes()
+}
+
+// withProvenance returns the metadata with the provenance recorded in its XMP
+// packet, replacing any recorded before
+func (m Metadata) withProvenance(p Provenance) Metadata {
+	m.XMP = setXMPDescription(m.XMP, provenanceNamespace, p.xmpDescriptionXML())
+	return m
+}
@@ -0,0 +1,286 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pdfUseOCPattern matches a catalog's PageMode that opens the layers panel
+var pdfUseOCPattern = regexp.MustCompile(`/PageMode\s*/UseOC\b`)
+
+// RemoveResult contains the cleaned PDF along with a report of what was removed
+type RemoveResult struct {
+	PDF                []byte   // The PDF without OCR text
+	LayersRemoved      int      // Number of OCR layers (optional content groups) removed
+	TextObjectsRemoved int      // Number of invisible text objects removed outside OCR layers
+	PagesModified      int      // Number of pages whose content changed
+	Warnings           []string // Warnings raised while removing OCR
+}
+
+// RemoveOCR strips an existing OCR text layer from a PDF so it can be OCR'd again.
+// It deletes the optional content groups named after config.LayerName together
+// with the content drawn in them, as written by ApplyOCR and AssembleWithOCR,
+// and removes text objects that only show invisible text (rendering mode 3),
+// which is how other OCR tools hide their text layer. Everything else on the
+// pages, including the original images, is left untouched, apart from the OCR
+// provenance recorded in the XMP metadata.
+func RemoveOCR(pdfData []byte, config OCRConfig) ([]byte, error) {
+	result, err := RemoveOCRWithResult(pdfData, config)
+	if err != nil {
+		return nil, err
+	}
+	return result.PDF, nil
+}
+
+// RemoveOCRWithResult works like RemoveOCR but returns a RemoveResult that
+// reports which layers and text objects were removed.
+func RemoveOCRWithResult(pdfData []byte, config OCRConfig) (*RemoveResult, error) {
+	if len(pdfData) == 0 {
+		return nil, ErrEmptyPDF
+	}
+	pdfData, err := decryptInput(pdfData, config)
+	if err != nil {
+		return nil, err
+	}
+	layerName := config.LayerName
+	if layerName == "" {
+		layerName = DefaultConfig().LayerName
+	}
+
+	file := parsePDFObjects(pdfData)
+	pages := file.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w found", ErrNoPages)
+	}
+
+	// Find the optional content groups of the OCR layers
+	ocgs := make(map[int]bool)
+	for _, num := range file.sortedObjects() {
+		dict := file.dict(num)
+		if !pdfOCGPattern.Match(dict) {
+			continue
+		}
+		if name, ok := pdfStringValue(dict, "Name"); ok && (isOCRLayerName(name, layerName) || config.isTemplatedLayerName(name)) {
+			ocgs[num] = true
+		}
+	}
+
+	result := &RemoveResult{LayersRemoved: len(ocgs)}
+	stripped := make(map[int]bool)
+	for i, page := range pages {
+		// Map the resource names used in the content stream to OCR layers
+		layers := make(map[string]bool)
+		for name, num := range file.refs(file.subdict(file.resources(page), "Properties")) {
+			if ocgs[num] {
+				layers[name] = true
+			}
+		}
+
+		modified := false
+		for _, num := range pdfReferenceList(file.dict(page), "Contents") {
+			// Content streams shared between pages only need stripping once
+			if stripped[num] {
+				continue
+			}
+			stripped[num] = true
+
+			content, err := file.stream(num)
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("page %d: %v", i+1, err))
+				continue
+			}
+			cleaned, sections, textObjects := stripOCRContent(content, layers)
+			if sections == 0 && textObjects == 0 {
+				continue
+			}
+			file.setStream(num, cleaned)
+			result.TextObjectsRemoved += textObjects
+			modified = true
+		}
+		if modified {
+			result.PagesModified++
+		}
+	}
+
+	if len(ocgs) > 0 {
+		file.removeOCGs(ocgs)
+	}
+
+	// The recorded provenance was that of the OCR text just removed
+	if num := file.ref(file.dict(file.ref(file.trailer, "Root")), "Metadata"); num > 0 {
+		if xmp, err := file.stream(num); err == nil && xmpDescription(xmp, provenanceNamespace) != nil {
+			file.setStream(num, setXMPDescription(xmp, provenanceNamespace, nil))
+		}
+	}
+
+	out, err := file.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	result.PDF = out
+
+	logRemoveResult(result, config)
+	return result, nil
+}
+
+// isOCRLayerName reports whether a layer is named after the OCR layer,
+// either exactly or with a page number appended, including the low
+// confidence layers
+func isOCRLayerName(name, layerName string) bool {
+	if name == layerName || name == lowConfidenceLayerName(layerName, 0) {
+		return true
+	}
+	return strings.HasPrefix(name, layerName) &&
+		(pdfPageLayerPattern.MatchString(name) || lowConfidenceLayerPattern.MatchString(name))
+}
+
+// lowConfidenceLayerPattern matches the names of the low confidence layers of pages
+var lowConfidenceLayerPattern = regexp.MustCompile(`\(Page (\d+), low confidence\)$`)
+
+// stripOCRContent removes the marked-content sections drawn in the given
+// layers, and the text objects that only show invisible text, from a content
+// stream. It returns the new content along with the number of sections and
+// text objects removed.
+func stripOCRContent(content []byte, layers map[string]bool) ([]byte, int, int) {
+	var out bytes.Buffer
+	copied := 0 // Position up to which the content has been copied or skipped
+	cut := func(start, end int, mode, startMode float64) {
+		out.Write(content[copied:start])
+		// The rendering mode outlives the removed content, so keep its last value
+		if mode != startMode {
+			fmt.Fprintf(&out, "\n%s Tr\n", strconv.FormatFloat(mode, 'f', -1, 64))
+		}
+		copied = end
+	}
+
+	var sections, textObjects int
+	var operands []pdfToken
+	operandStart := 0
+
+	depth := 0      // Marked-content nesting depth
+	layerDepth := 0 // Depth of the OCR layer section being removed (0 if none)
+	layerStart := 0
+	var layerMode float64
+
+	var mode float64 // Current text rendering mode
+	var saved []float64
+	inText := false
+	textStart, textShows, textVisible := 0, 0, false
+	var textMode float64
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != pdfOperator {
+			if len(operands) == 0 {
+				operandStart = lexer.start
+			}
+			operands = append(operands, token)
+			continue
+		}
+		start := lexer.start
+		if len(operands) > 0 {
+			start = operandStart
+		}
+
+		switch token.text {
+		case "BDC", "BMC":
+			depth++
+			if layerDepth == 0 && token.text == "BDC" && len(operands) >= 2 &&
+				operands[0].kind == pdfName && operands[0].text == "OC" &&
+				operands[1].kind == pdfName && layers[operands[1].text] {
+				layerDepth, layerStart, layerMode = depth, start, mode
+			}
+		case "EMC":
+			if depth == 0 {
+				break
+			}
+			if depth == layerDepth {
+				cut(layerStart, lexer.pos, mode, layerMode)
+				sections++
+				layerDepth = 0
+			}
+			depth--
+		case "q":
+			saved = append(saved, mode)
+		case "Q":
+			if len(saved) > 0 {
+				mode = saved[len(saved)-1]
+				saved = saved[:len(saved)-1]
+			}
+		case "Tr":
+			if len(operands) == 1 && operands[0].kind == pdfNumber {
+				mode, _ = strconv.ParseFloat(operands[0].text, 64)
+			}
+		case "BT":
+			inText = true
+			textStart, textShows, textVisible, textMode = start, 0, false, mode
+		case "Tj", "'", "\"", "TJ":
+			if inText {
+				textShows++
+				textVisible = textVisible || mode != textRenderInvisible
+			}
+		case "ET":
+			// Text objects inside a removed layer go with the layer
+			if inText && layerDepth == 0 && textStart >= copied && textShows > 0 && !textVisible {
+				cut(textStart, lexer.pos, mode, textMode)
+				textObjects++
+			}
+			inText = false
+		case "ID":
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	out.Write(content[copied:])
+
+	return out.Bytes(), sections, textObjects
+}
+
+// removeOCGs deletes optional content groups along with the references to
+// them, and drops the optional content properties once no group is left
+func (f *pdfFile) removeOCGs(ocgs map[int]bool) {
+	var nums []string
+	for num := range ocgs {
+		nums = append(nums, strconv.Itoa(num))
+		delete(f.objects, num)
+	}
+	// Named references (e.g. page /Properties entries) go with their key, others are array items
+	refPattern := regexp.MustCompile(`(/[^\s/<>\[\]()]+\s+)?\b(` + strings.Join(nums, "|") + `)\s+\d+\s+R\b`)
+
+	for _, num := range f.sortedObjects() {
+		dict := f.dict(num)
+		if refPattern.Match(dict) {
+			f.setDict(num, refPattern.ReplaceAll(dict, nil))
+		}
+	}
+
+	// Viewers expect optional content properties to list at least one group
+	catalog := f.ref(f.trailer, "Root")
+	properties := f.subdict(f.dict(catalog), "OCProperties")
+	if properties != nil && len(pdfReferenceList(properties, "OCGs")) == 0 {
+		dict := pdfRemoveEntry(f.dict(catalog), "OCProperties")
+		if pdfUseOCPattern.Match(dict) {
+			dict = pdfRemoveEntry(dict, "PageMode")
+		}
+		f.setDict(catalog, dict)
+	}
+}
+
+// logRemoveResult prints the warnings raised while removing OCR
+func logRemoveResult(result *RemoveResult, config OCRConfig) {
+	logWarningEvents(config, result.Warnings)
+	if !config.LogWarnings {
+		return
+	}
+	logger := getLogger(config)
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(logger, "Warning:", warning)
+	}
+}
@@ -0,0 +1,42 @@
+package pdfocr
+
+// ApplyResult contains the generated PDF along with a report
+// of how the OCR layer was rendered
+type ApplyResult struct {
+	PDF                []byte           // The generated PDF
+	PageCount          int              // Number of pages that received an OCR layer
+	WordCount          int              // Number of words rendered into the OCR layer
+	LowConfidenceWords int              // Number of words below MinWordConfidence, dropped or drawn onto the low confidence layers
+	OptimizedImages    int              // Number of page images downsampled or re-encoded by AssembleWithOCR
+	ImageBytesSaved    int              // Bytes saved on the page images by downsampling and re-encoding
+	ReplacedLayers     int              // Number of existing OCR layers stripped in Replace mode
+	SkippedPages       []int            // Pages (1-based) of the source PDF left without OCR as they already show text, in SkipPagesWithText mode
+	EncodingIssues     []EncodingIssue  // Words that needed an encoding fallback
+	PageConfidence     []PageConfidence // Recognition confidence of each page that received an OCR layer
+	Warnings           []string         // Warnings raised while applying OCR
+}
+
+// EncodingIssue describes a word that could not be encoded
+// in the OCR layer font and how it was handled
+type EncodingIssue struct {
+	Page     int              // Page number (1-based) in the resulting PDF
+	WordID   string           // hOCR word ID
+	Text     string           // Original word text
+	Rendered string           // Text written to the OCR layer (empty if skipped)
+	Action   EncodingFallback // Fallback policy that was applied
+}
+
+// PageConfidence summarizes the recognition confidence of the words of a page,
+// for reviewing the OCR quality page by page. Words without a confidence
+// value are not counted.
+type PageConfidence struct {
+	Page  int     // Page number (1-based) in the resulting PDF
+	Words int     // Number of words with a confidence value
+	Mean  float64 // Mean word confidence (0-100), 0 if no word has one
+	Min   float64 // Lowest word confidence (0-100), 0 if no word has one
+}
+
+// addWarning records a warning in the result
+func (r *ApplyResult) addWarning(warning string) {
+	r.Warnings = append(r.Warnings, warning)
+}
@@ -0,0 +1,66 @@
+package pdfocr
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// wordAngle returns the hOCR textangle of a word in degrees counter-clockwise,
+// normalized to [0, 360). Words without a textangle are upright.
+func wordAngle(word hocr.Word) float64 {
+	angle, err := strconv.ParseFloat(strings.TrimSpace(word.Metadata["textangle"]), 64)
+	if err != nil || math.IsNaN(angle) || math.IsInf(angle, 0) {
+		return 0
+	}
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	return angle
+}
+
+// rotatedTextExtent returns the length and thickness of a text run rotated by
+// the given angle whose upright bounding box is width by height
+func rotatedTextExtent(width, height, angle float64) (length, thickness float64) {
+	if angle == 0 {
+		return width, height
+	}
+
+	// Split the angle into quarter turns and the remaining skew within ±45°
+	quarters := math.Round(angle / 90)
+	skew := (angle - quarters*90) * math.Pi / 180
+	along, across := width, height
+	if int(quarters)%2 == 1 {
+		along, across = height, width
+	}
+
+	// Solve along = l·cos + t·sin and across = l·sin + t·cos for l and t;
+	// close to 45° the system degenerates, so fall back to the skewed length
+	cos, sin := math.Cos(skew), math.Abs(math.Sin(skew))
+	if det := cos*cos - sin*sin; det > 0.1 {
+		length = (along*cos - across*sin) / det
+		thickness = (across*cos - along*sin) / det
+	}
+	if length <= 0 || thickness <= 0 {
+		length, thickness = along/cos, across
+	}
+	return length, thickness
+}
+
+// rotatedBaselineStart returns where the baseline of a rotated text run
+// starts, given the center of its bounding box and the distance from the
+// top of the run to the baseline. Coordinates have y pointing down.
+func rotatedBaselineStart(centerX, centerY, length, thickness, ascent, angle float64) (x, y float64) {
+	rad := angle * math.Pi / 180
+	// Unit vectors along the text and towards the top of the glyphs
+	alongX, alongY := math.Cos(rad), -math.Sin(rad)
+	upX, upY := -math.Sin(rad), -math.Cos(rad)
+
+	// Top-left corner of the run, then down to the baseline
+	x = centerX - alongX*length/2 + upX*thickness/2 - upX*ascent
+	y = centerY - alongY*length/2 + upY*thickness/2 - upY*ascent
+	return x, y
+}
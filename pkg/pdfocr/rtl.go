@@ -0,0 +1,90 @@
+package pdfocr
+
+import (
+	"slices"
+	"unicode"
+)
+
+// rtlScripts are the scripts written right to left
+var rtlScripts = []*unicode.RangeTable{unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko}
+
+// mirroredBrackets maps brackets to their counterpart, which is what a
+// bracket looks like in right-to-left text
+var mirroredBrackets = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+	'<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+// isRTLRune reports whether a character is a letter of a right-to-left
+// script. Digits, including Arabic-Indic ones, are read left to right.
+func isRTLRune(r rune) bool {
+	return !unicode.IsDigit(r) && unicode.IsOneOf(rtlScripts, r)
+}
+
+// isRTL reports whether text contains right-to-left letters, e.g. Hebrew or Arabic
+func isRTL(text string) bool {
+	for _, r := range text {
+		if isRTLRune(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// visualOrder reorders a right-to-left word from logical (reading) order into
+// the left-to-right order its glyphs appear in on the page. PDF text extractors
+// expect right-to-left text in that order and reverse it back on copy/paste.
+// Embedded numbers and Latin text keep their order, combining marks stay with
+// their base character and brackets are mirrored.
+func visualOrder(text string) string {
+	// Split into clusters of a base character and its combining marks
+	var clusters [][]rune
+	for _, r := range text {
+		if len(clusters) > 0 && unicode.In(r, unicode.Mn, unicode.Me) {
+			clusters[len(clusters)-1] = append(clusters[len(clusters)-1], r)
+			continue
+		}
+		clusters = append(clusters, []rune{r})
+	}
+
+	// Left-to-right runs are numbers and Latin letters, along with the
+	// neutral characters between them such as the point in "3.14"
+	ltr := make([]bool, len(clusters))
+	last := -1
+	for i, cluster := range clusters {
+		r := cluster[0]
+		if isRTLRune(r) || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			continue
+		}
+		if last >= 0 && !slices.ContainsFunc(clusters[last+1:i], func(c []rune) bool { return isRTLRune(c[0]) }) {
+			for j := last + 1; j < i; j++ {
+				ltr[j] = true
+			}
+		}
+		ltr[i] = true
+		last = i
+	}
+
+	// Reverse the word, then restore the order within each left-to-right run
+	var visual []rune
+	for i := len(clusters) - 1; i >= 0; {
+		if !ltr[i] {
+			cluster := clusters[i]
+			if mirrored, ok := mirroredBrackets[cluster[0]]; ok {
+				cluster = append([]rune{mirrored}, cluster[1:]...)
+			}
+			visual = append(visual, cluster...)
+			i--
+			continue
+		}
+		start := i
+		for start > 0 && ltr[start-1] {
+			start--
+		}
+		for _, cluster := range clusters[start : i+1] {
+			visual = append(visual, cluster...)
+		}
+		i = start - 1
+	}
+	return string(visual)
+}
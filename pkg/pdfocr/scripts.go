@@ -0,0 +1,308 @@
+package pdfocr
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Script is a Unicode script, or group of related scripts, that OCR layer
+// fonts can be configured for
+type Script string
+
+const (
+	ScriptLatin    Script = "latin"
+	ScriptCyrillic Script = "cyrillic"
+	ScriptCJK      Script = "cjk" // Han, Hiragana, Katakana and Hangul
+	ScriptArabic   Script = "arabic"
+)
+
+// scriptTables lists the Unicode ranges of each Script, in the order ties
+// between scripts are resolved in
+var scriptTables = []struct {
+	script Script
+	tables []*unicode.RangeTable
+}{
+	{ScriptLatin, []*unicode.RangeTable{unicode.Latin}},
+	{ScriptCyrillic, []*unicode.RangeTable{unicode.Cyrillic}},
+	{ScriptCJK, []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul}},
+	{ScriptArabic, []*unicode.RangeTable{unicode.Arabic}},
+}
+
+// ParseScript converts a script name into a Script
+func ParseScript(name string) (Script, error) {
+	script := Script(strings.ToLower(strings.TrimSpace(name)))
+	for _, entry := range scriptTables {
+		if entry.script == script {
+			return script, nil
+		}
+	}
+	return "", fmt.Errorf("unknown script %q (expected latin, cyrillic, cjk or arabic)", name)
+}
+
+// ScriptFont is a TrueType font for the words of a script. Set either the
+// path or the font data.
+type ScriptFont struct {
+	Path string
+	Data []byte
+
+	family   string        // Family name the font is registered under, shared by identical fonts
+	coverage map[rune]bool // Characters the font has glyphs for, read from its cmap table
+}
+
+// AddScriptFont appends a TrueType font file to the fonts of a script by the
+// script's name, e.g. from a "script=path" command-line flag. Fonts added
+// first are preferred.
+func (f *FontConfig) AddScriptFont(script, path string) error {
+	s, err := ParseScript(script)
+	if err != nil {
+		return err
+	}
+	// Copy the map rather than adding to one shared with another config
+	fonts := make(map[Script][]ScriptFont, len(f.ScriptFonts)+1)
+	for key, list := range f.ScriptFonts {
+		fonts[key] = list
+	}
+	fonts[s] = append(fonts[s][:len(fonts[s]):len(fonts[s])], ScriptFont{Path: path})
+	f.ScriptFonts = fonts
+	return nil
+}
+
+// loadScriptFonts reads the configured script font files and their character
+// coverage, so that's done once per document rather than once per word
+func (f FontConfig) loadScriptFonts() (FontConfig, error) {
+	if len(f.ScriptFonts) == 0 {
+		return f, nil
+	}
+	fonts := make(map[Script][]ScriptFont, len(f.ScriptFonts))
+	for script, list := range f.ScriptFonts {
+		loaded := make([]ScriptFont, len(list))
+		for i, font := range list {
+			if len(font.Data) == 0 {
+				data, err := os.ReadFile(font.Path)
+				if err != nil {
+					return f, fmt.Errorf("failed to read %s font: %w", script, err)
+				}
+				font.Data = data
+			}
+			if font.coverage == nil {
+				coverage, err := trueTypeCoverage(font.Data)
+				if err != nil {
+					return f, fmt.Errorf("failed to read %s font %s: %w", script, fontLabel(font, i), err)
+				}
+				font.coverage = coverage
+				// The same font configured for several scripts is embedded once
+				font.family = fmt.Sprintf("OCRScript-%x", sha1.Sum(font.Data))
+			}
+			loaded[i] = font
+		}
+		fonts[script] = loaded
+	}
+	f.ScriptFonts = fonts
+	return f, nil
+}
+
+// fontLabel names a script font in error messages
+func fontLabel(font ScriptFont, index int) string {
+	if font.Path != "" {
+		return font.Path
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// scriptFont returns the script of a word and the index of the first font
+// configured for it that has glyphs for all of the word's characters, or -1
+// if there is none
+func (f FontConfig) scriptFont(text string) (Script, int) {
+	script := wordScript(text)
+	for i, font := range f.ScriptFonts[script] {
+		if font.covers(text) {
+			return script, i
+		}
+	}
+	return script, -1
+}
+
+// covers reports whether the font has glyphs for every character of the text
+func (font ScriptFont) covers(text string) bool {
+	for _, r := range text {
+		if !font.coverage[r] && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// wordScript returns the script most letters of a word are written in, or an
+// empty Script if it has no letters of a known script
+func wordScript(text string) Script {
+	counts := make([]int, len(scriptTables))
+	for _, r := range text {
+		for i, entry := range scriptTables {
+			if unicode.In(r, entry.tables...) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	best := -1
+	for i, count := range counts {
+		if count > 0 && (best < 0 || count > counts[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return scriptTables[best].script
+}
+
+// selectWordFont switches the layer to the first font configured for the
+// word's script that can draw it, or back to the layer font if there is none
+func (state *wordState) selectWordFont(text string) {
+	metrics := state.layerMetrics
+	if script, index := state.config.Font.scriptFont(text); index >= 0 {
+		font := state.config.Font.ScriptFonts[script][index]
+		family := font.family
+		metrics = state.scriptMetrics[family]
+		if metrics == nil {
+			// Registering is a no-op once the font has been added to the document
+			pdf := state.layerMetrics.pdf
+			pdf.AddUTF8FontFromBytes(family, "", font.Data)
+			pdf.SetFont(family, "", state.config.Font.Size)
+			metrics = newFontMetrics(pdf, state.config.Font, family, true)
+			if state.scriptMetrics == nil {
+				state.scriptMetrics = make(map[string]*fontMetrics)
+			}
+			state.scriptMetrics[family] = metrics
+		}
+	}
+	state.switchFont(metrics)
+}
+
+// switchFont selects another font on the PDF, keeping the current font size
+// and horizontal scaling
+func (state *wordState) switchFont(metrics *fontMetrics) {
+	previous := state.metrics
+	if metrics == previous {
+		return
+	}
+	metrics.pdf.SetFont(metrics.family, metrics.style, previous.currentSize)
+	metrics.currentSize = previous.currentSize
+	metrics.currentScale = previous.currentScale
+	state.metrics = metrics
+}
+
+// trueTypeCoverage reads the characters a TrueType font has glyphs for from
+// its Unicode cmap subtable (format 4 or 12)
+func trueTypeCoverage(data []byte) (map[rune]bool, error) {
+	be := binary.BigEndian
+	if len(data) < 12 {
+		return nil, errors.New("not a TrueType font")
+	}
+	var cmap []byte
+	numTables := int(be.Uint16(data[4:6]))
+	for i := range numTables {
+		record := 12 + i*16
+		if record+16 > len(data) {
+			break
+		}
+		if string(data[record:record+4]) == "cmap" {
+			offset, length := int(be.Uint32(data[record+8:])), int(be.Uint32(data[record+12:]))
+			if offset+length > len(data) {
+				return nil, errors.New("truncated cmap table")
+			}
+			cmap = data[offset : offset+length]
+		}
+	}
+	if len(cmap) < 4 {
+		return nil, errors.New("font has no cmap table")
+	}
+
+	// Prefer the full Unicode subtable to the one limited to the Basic Multilingual Plane
+	var subtable []byte
+	best := 0
+	for i := range int(be.Uint16(cmap[2:4])) {
+		record := 4 + i*8
+		if record+8 > len(cmap) {
+			break
+		}
+		platform, encoding := be.Uint16(cmap[record:]), be.Uint16(cmap[record+2:])
+		offset := int(be.Uint32(cmap[record+4:]))
+		if offset+2 > len(cmap) {
+			continue
+		}
+		rank := 0
+		switch format := be.Uint16(cmap[offset:]); {
+		case format == 12 && (platform == 0 || platform == 3 && encoding == 10):
+			rank = 2
+		case format == 4 && (platform == 0 || platform == 3 && encoding == 1):
+			rank = 1
+		}
+		if rank > best {
+			best, subtable = rank, cmap[offset:]
+		}
+	}
+
+	coverage := make(map[rune]bool)
+	switch best {
+	case 2:
+		if len(subtable) < 16 {
+			return nil, errors.New("truncated cmap subtable")
+		}
+		groups := int(be.Uint32(subtable[12:16]))
+		for i := range groups {
+			group := 16 + i*12
+			if group+12 > len(subtable) {
+				break
+			}
+			start, end := be.Uint32(subtable[group:]), be.Uint32(subtable[group+4:])
+			glyph := be.Uint32(subtable[group+8:])
+			for c := start; c <= end && c <= unicode.MaxRune; c++ {
+				// Glyph 0 is the missing glyph
+				if glyph+(c-start) != 0 {
+					coverage[rune(c)] = true
+				}
+			}
+		}
+	case 1:
+		if len(subtable) < 14 {
+			return nil, errors.New("truncated cmap subtable")
+		}
+		segments := int(be.Uint16(subtable[6:8])) / 2
+		ends := 14
+		starts := ends + segments*2 + 2
+		deltas := starts + segments*2
+		rangeOffsets := deltas + segments*2
+		if rangeOffsets+segments*2 > len(subtable) {
+			return nil, errors.New("truncated cmap subtable")
+		}
+		for i := range segments {
+			start, end := int(be.Uint16(subtable[starts+i*2:])), int(be.Uint16(subtable[ends+i*2:]))
+			delta := int(be.Uint16(subtable[deltas+i*2:]))
+			rangeOffset := int(be.Uint16(subtable[rangeOffsets+i*2:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				glyph := (c + delta) & 0xFFFF
+				if rangeOffset != 0 {
+					pos := rangeOffsets + i*2 + rangeOffset + (c-start)*2
+					if pos+2 > len(subtable) {
+						break
+					}
+					if glyph = int(be.Uint16(subtable[pos:])); glyph != 0 {
+						glyph = (glyph + delta) & 0xFFFF
+					}
+				}
+				if glyph != 0 {
+					coverage[rune(c)] = true
+				}
+			}
+		}
+	default:
+		return nil, errors.New("font has no Unicode cmap subtable")
+	}
+	return coverage, nil
+}
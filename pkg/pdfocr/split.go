@@ -0,0 +1,327 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// pdfParentPattern matches the parent reference of a page
+	pdfParentPattern = regexp.MustCompile(`/Parent\s+\d+\s+\d+\s+R`)
+
+	// pdfAnyReferencePattern matches any indirect reference
+	pdfAnyReferencePattern = regexp.MustCompile(`\b(\d+)\s+\d+\s+R\b`)
+
+	// pdfOCGPattern matches the type of an optional content group
+	pdfOCGPattern = regexp.MustCompile(`/Type\s*/OCG\b`)
+
+	// pdfFormPattern matches the subtype of a form XObject
+	pdfFormPattern = regexp.MustCompile(`/Subtype\s*/Form\b`)
+
+	// pdfContentNamePattern matches a name in a content stream
+	pdfContentNamePattern = regexp.MustCompile(`/([^\s/<>\[\]()%{}]+)`)
+)
+
+// inheritedPageKeys are the page attributes a page can inherit from the page
+// tree, which a page taken out of its tree needs a copy of
+var inheritedPageKeys = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// prunableResources are the resource categories a page's content refers to
+// by name, whose entries the page doesn't use are left out when it is copied
+var prunableResources = []string{"XObject", "Properties", "Font", "ExtGState", "Pattern", "Shading", "ColorSpace"}
+
+// documentPageKeys are the catalog entries that refer to pages by position
+// or to the whole page tree, which no longer fit once pages are left out
+var documentPageKeys = []string{"Outlines", "Names", "Dests", "PageLabels", "StructTreeRoot", "MarkInfo", "OpenAction", "Threads"}
+
+// ExtractPages returns a PDF of just the given pages (1-based) of a PDF, in
+// the given order. The pages are copied as they are, with their content,
+// annotations and the resources and optional content layers they use, such
+// as an OCR text layer; the document metadata is kept. The outline, page
+// labels and structure tree of the document refer to all of its pages, so
+// they are left out.
+func ExtractPages(pdfData []byte, pages []int) ([]byte, error) {
+	if len(pdfData) == 0 {
+		return nil, ErrEmptyPDF
+	}
+	source := parsePDFObjects(pdfData)
+	all := source.pages()
+	if len(all) == 0 {
+		return nil, fmt.Errorf("PDF has %w", ErrNoPages)
+	}
+	file, err := source.copyPages(all, pages)
+	if err != nil {
+		return nil, err
+	}
+	return file.bytes()
+}
+
+// SplitPDF splits a PDF into one PDF per page range, or into one PDF per
+// page without ranges, copying the pages as ExtractPages does, so every part
+// keeps the OCR layer of its pages and the document metadata. A range open
+// at the end runs to the last page. The OCR layers are renumbered to the
+// pages of their part, the way MergePDFs does, so "OCR Text (Page 5)" of a
+// part starting at page 5 becomes "OCR Text (Page 1)"; layers named by
+// config.LayerNameTemplate are renumbered the same way. Encrypted PDFs are
+// opened with config.Password.
+func SplitPDF(pdfData []byte, ranges []PageRange, config OCRConfig) ([][]byte, error) {
+	if len(pdfData) == 0 {
+		return nil, ErrEmptyPDF
+	}
+	if config.LayerName == "" {
+		config.LayerName = DefaultConfig().LayerName
+	}
+	pdfData, err := decryptInput(pdfData, config)
+	if err != nil {
+		return nil, err
+	}
+	source := parsePDFObjects(pdfData)
+	all := source.pages()
+	if len(all) == 0 {
+		return nil, fmt.Errorf("PDF has %w", ErrNoPages)
+	}
+	if len(ranges) == 0 {
+		for page := 1; page <= len(all); page++ {
+			ranges = append(ranges, PageRange{First: page, Last: page})
+		}
+	}
+
+	parts := make([][]byte, 0, len(ranges))
+	for _, r := range ranges {
+		if err := validatePageRange(r); err != nil {
+			return nil, err
+		}
+		last := r.Last
+		if last == 0 {
+			last = len(all)
+		}
+		if r.First > len(all) || last > len(all) {
+			return nil, fmt.Errorf("%w %s: the PDF has %d pages", ErrInvalidPageRange, r, len(all))
+		}
+		var pages []int
+		for page := r.First; page <= last; page++ {
+			pages = append(pages, page)
+		}
+		file, err := source.copyPages(all, pages)
+		if err != nil {
+			return nil, fmt.Errorf("pages %s: %w", r, err)
+		}
+		properties := file.subdict(file.dict(file.ref(file.trailer, "Root")), "OCProperties")
+		for _, num := range file.refArray(properties, "OCGs") {
+			if name, ok := pdfStringValue(file.dict(num), "Name"); ok {
+				if renamed := config.renumberLayerName(name, 1-r.First); renamed != name {
+					file.setLayerName(num, renamed)
+				}
+			}
+		}
+		part, err := file.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("pages %s: %w", r, err)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// copyPages returns a copy of a parsed PDF, whose page objects are all, with
+// just the given pages, each hung straight off the root of the page tree
+func (f *pdfFile) copyPages(all []int, pages []int) (*pdfFile, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: no pages selected", ErrInvalidPageRange)
+	}
+	file := &pdfFile{version: f.version, objects: maps.Clone(f.objects), trailer: f.trailer}
+
+	catalog := file.ref(file.trailer, "Root")
+	tree := file.ref(file.dict(catalog), "Pages")
+	if catalog == 0 || tree == 0 {
+		return nil, fmt.Errorf("PDF has no page tree")
+	}
+
+	// Hang the selected pages straight off the root of the page tree, with
+	// copies of the attributes they inherited from it
+	kids := make([]string, 0, len(pages))
+	used := make(map[int]bool)
+	for _, page := range pages {
+		if page < 1 || page > len(all) {
+			return nil, fmt.Errorf("%w: page %d of a %d page PDF", ErrInvalidPageRange, page, len(all))
+		}
+		num := all[page-1]
+		if used[num] {
+			return nil, fmt.Errorf("%w: page %d selected twice", ErrInvalidPageRange, page)
+		}
+		used[num] = true
+		kids = append(kids, fmt.Sprintf("%d 0 R", num))
+
+		dict := file.dict(num)
+		var inherited []string
+		resources := file.pageResources(num)
+		if resources != nil {
+			dict = pdfRemoveEntry(dict, "Resources")
+			inherited = append(inherited, "/Resources "+string(resources))
+		}
+		for _, key := range inheritedPageKeys {
+			if key == "Resources" && resources != nil {
+				continue
+			}
+			if value := file.inheritedValue(num, key); value != "" && file.ownValue(dict, key) == "" {
+				inherited = append(inherited, "/"+key+" "+value)
+			}
+		}
+		dict = pdfParentPattern.ReplaceAll(dict, nil)
+		dict = []byte(strings.TrimSuffix(strings.TrimSpace(string(dict)), ">>"))
+		dict = fmt.Appendf(dict, " /Parent %d 0 R %s>>", tree, strings.Join(inherited, " "))
+		file.setDict(num, dict)
+	}
+	file.objects[tree] = fmt.Appendf(nil, "\n<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(kids))
+
+	catalogDict := file.dict(catalog)
+	for _, key := range documentPageKeys {
+		catalogDict = pdfRemoveEntry(catalogDict, key)
+	}
+	file.setDict(catalog, catalogDict)
+
+	// Pages left out may still be referenced, e.g. by link annotations,
+	// which then point nowhere as a PDF reader allows
+	for _, num := range all {
+		if !used[num] {
+			delete(file.objects, num)
+		}
+	}
+
+	// Layers only the pages left out draw on, such as their OCR layers, go
+	// with them, so the layer panel lists just the layers of these pages
+	onPages := file.reachable([]int{tree})
+	unused := make(map[int]bool)
+	for _, num := range pdfReferenceList(file.subdict(file.dict(catalog), "OCProperties"), "OCGs") {
+		if !onPages[num] && pdfOCGPattern.Match(file.dict(num)) {
+			unused[num] = true
+		}
+	}
+	if len(unused) > 0 {
+		file.removeOCGs(unused)
+	}
+
+	reachable := file.reachable([]int{catalog, file.ref(file.trailer, "Info")})
+	for num := range file.objects {
+		if !reachable[num] {
+			delete(file.objects, num)
+		}
+	}
+	return file, nil
+}
+
+// inheritedValue returns the value of a page attribute as written in the
+// page or the closest node of the page tree that has it, or ""
+func (f *pdfFile) inheritedValue(page int, key string) string {
+	seen := make(map[int]bool)
+	for num := page; num > 0 && !seen[num]; num = f.ref(f.dict(num), "Parent") {
+		seen[num] = true
+		if value := f.ownValue(f.dict(num), key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ownValue returns the value stored under a key of a dictionary as written,
+// a reference, array, dictionary or number, or "" if it has none
+func (f *pdfFile) ownValue(dict []byte, key string) string {
+	if num := f.ref(dict, key); num > 0 {
+		return fmt.Sprintf("%d 0 R", num)
+	}
+	if contents := f.subdict(dict, key); contents != nil {
+		return "<<" + string(contents) + ">>"
+	}
+	match := pdfKeySubmatch(dict, key, `\s*(\[[^\]]*\]|-?[\d.]+)`)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// pageResources returns a resource dictionary of just the resources a page
+// uses, or nil to keep its resources as they are. Many PDF writers share one
+// resource dictionary among all pages, which would otherwise carry the
+// images and OCR layers of every page into a PDF of some of them.
+func (f *pdfFile) pageResources(page int) []byte {
+	var resources []byte
+	seen := make(map[int]bool)
+	for num := page; num > 0 && !seen[num] && resources == nil; num = f.ref(f.dict(num), "Parent") {
+		seen[num] = true
+		resources = f.wholeDict(f.dict(num), "Resources")
+	}
+	if resources == nil {
+		return nil
+	}
+
+	// The names a page uses are the names in its content
+	used := make(map[string]bool)
+	for _, num := range pdfReferenceList(f.dict(page), "Contents") {
+		content, err := f.stream(num)
+		if err != nil {
+			return nil
+		}
+		for _, match := range pdfContentNamePattern.FindAllSubmatch(content, -1) {
+			used[string(match[1])] = true
+		}
+	}
+
+	pruned := bytes.TrimSpace(resources)
+	changed := false
+	for _, category := range prunableResources {
+		entries := f.subdict(resources, category)
+		if entries == nil || len(bytes.TrimSpace(pdfReferencePattern.ReplaceAll(entries, nil))) > 0 {
+			// Only dictionaries of plain references are pruned
+			continue
+		}
+		var kept []string
+		for name, num := range f.refs(entries) {
+			if !used[name] {
+				changed = true
+				continue
+			}
+			// Forms without resources of their own use the page's resources
+			if category == "XObject" && pdfFormPattern.Match(f.dict(num)) && f.subdict(f.dict(num), "Resources") == nil {
+				return nil
+			}
+			kept = append(kept, fmt.Sprintf("/%s %d 0 R", name, num))
+		}
+		sort.Strings(kept)
+		pruned = bytes.TrimSpace(pdfRemoveEntry(pruned, category))
+		pruned = fmt.Appendf(bytes.TrimSpace(pruned[:len(pruned)-2]), "\n/%s <<%s>>\n>>", category, strings.Join(kept, " "))
+	}
+	if !changed {
+		return nil
+	}
+	return pruned
+}
+
+// reachable returns the objects that can be reached from the given ones,
+// which are included, by following their references
+func (f *pdfFile) reachable(roots []int) map[int]bool {
+	reachable := make(map[int]bool)
+	queue := roots
+	for len(queue) > 0 {
+		num := queue[0]
+		queue = queue[1:]
+		if num == 0 || reachable[num] {
+			continue
+		}
+		if _, ok := f.objects[num]; !ok {
+			continue
+		}
+		reachable[num] = true
+
+		// Stream data isn't searched for references, as it may contain anything
+		for _, match := range pdfAnyReferencePattern.FindAllSubmatch(f.dict(num), -1) {
+			ref, _ := strconv.Atoi(string(match[1]))
+			queue = append(queue, ref)
+		}
+	}
+	return reachable
+}
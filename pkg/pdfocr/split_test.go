@@ -0,0 +1,94 @@
+package pdfocr
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// testTwoPagePDF returns a two-page PDF with an OCR layer per page
+func testTwoPagePDF() []byte {
+	return testPDF(nil, []testObject{
+		{1, "<< /Type /Catalog /Pages 2 0 R /OCProperties << /OCGs [6 0 R 8 0 R] /D << /Order [6 0 R 8 0 R] >> >> >>"},
+		{2, "<< /Type /Pages /Kids [3 0 R 9 0 R] /Count 2 >>"},
+		{3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /Font << /F1 7 0 R >> /Properties << /oc1 6 0 R >> >> /Contents 4 0 R >>"},
+		testContent(4, testLayerContent),
+		testContent(5, "/OC /oc2 BDC BT 3 Tr /F1 12 Tf 10 10 Td (World) Tj ET EMC"),
+		{6, "<< /Type /OCG /Name (OCR Text \\(Page 1\\)) >>"},
+		{7, testFont},
+		{8, "<< /Type /OCG /Name (OCR Text \\(Page 2\\)) >>"},
+		{9, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /Font << /F1 7 0 R >> /Properties << /oc2 8 0 R >> >> /Contents 5 0 R >>"},
+	}, "<< /Root 1 0 R /Size 10 >>")
+}
+
+func TestSplitPDFRenumbersLayers(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []PageRange
+		want   [][]string
+	}{
+		{
+			name: "per page",
+			want: [][]string{{"OCR Text (Page 1)"}, {"OCR Text (Page 1)"}},
+		},
+		{
+			name:   "open range",
+			ranges: []PageRange{{First: 2}},
+			want:   [][]string{{"OCR Text (Page 1)"}},
+		},
+		{
+			name:   "all pages",
+			ranges: []PageRange{{First: 1, Last: 2}},
+			want:   [][]string{{"OCR Text (Page 1)", "OCR Text (Page 2)"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := SplitPDF(testTwoPagePDF(), tt.ranges, DefaultConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parts) != len(tt.want) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.want))
+			}
+			for i, part := range parts {
+				layers, err := detectPDFLayers(part)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(layers, tt.want[i]) {
+					t.Errorf("part %d layers = %q, want %q", i+1, layers, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPDFEncrypted(t *testing.T) {
+	pdf := fpdf.New("P", "pt", "A4", "")
+	pdf.SetProtection(fpdf.CnProtectPrint, "secret", "owner")
+	pdf.AddPage()
+	pdf.AddPage()
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SplitPDF(out.Bytes(), nil, DefaultConfig()); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("SplitPDF() without the password error = %v, want ErrIncorrectPassword", err)
+	}
+
+	config := DefaultConfig()
+	config.Password = "secret"
+	parts, err := SplitPDF(out.Bytes(), nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Errorf("got %d parts, want 2", len(parts))
+	}
+}
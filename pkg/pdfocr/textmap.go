@@ -0,0 +1,97 @@
+package pdfocr
+
+import (
+	"fmt"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// WordPlacement describes where a word is drawn in the OCR layer.
+// Coordinates are in PDF points with the origin at the top-left corner of the page.
+type WordPlacement struct {
+	Page      int     // Page number (1-based) in the resulting PDF
+	WordID    string  // hOCR word ID
+	Text      string  // Original word text
+	Rendered  string  // Text written to the OCR layer after encoding fallbacks, in visual order for right-to-left words
+	X         float64 // Left edge of the word rectangle
+	Y         float64 // Top edge of the word rectangle
+	Width     float64 // Width of the word rectangle
+	Height    float64 // Height of the word rectangle
+	Angle     float64 // Counter-clockwise rotation of the text in degrees (0 for upright text)
+	BaselineX float64 // Horizontal position where the text baseline starts
+	Baseline  float64 // Vertical position where the text baseline starts
+	FontSize  float64 // Font size in points the word is scaled to
+	HScale    float64 // Horizontal scaling of the glyphs in percent, 100 unless Font.FitHeight is set
+
+	encoded   string  // Rendered text in the encoding of the layer font
+	length    float64 // Length of the text run along the baseline
+	thickness float64 // Height of the text run across the baseline
+}
+
+// ComputeTextMap returns the placement ApplyOCR would give each hOCR word,
+// without generating a PDF. Words that the encoding fallback policy leaves
+// out of the OCR layer are not included. It accepts either raw HOCR or ALTO data
+// ([]byte) or a parsed HOCR struct (*hocr.HOCR).
+func ComputeTextMap(hocrInput interface{}, config OCRConfig) ([]WordPlacement, error) {
+	hocrStruct, err := parseHOCRInput(hocrInput)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Font, err = config.Font.loadFonts()
+	if err != nil {
+		return nil, err
+	}
+
+	// Font metrics come from fpdf, but nothing is ever drawn on this document
+	pdf := fpdf.New("P", "pt", "", "")
+	pdf.SetFont(config.Font.Name, config.Font.Style, config.Font.Size)
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("failed to load OCR layer font: %w", err)
+	}
+	metrics := newFontMetrics(pdf, config.Font, config.Font.Name, false)
+	var unicodeMetrics *fontMetrics
+
+	identity := func(x, y float64) (float64, float64) {
+		return x, y
+	}
+
+	// With page ranges, each hOCR page lands on the next selected page of the PDF
+	var pageNums []int
+	if len(config.PageRanges) > 0 {
+		pageNums = selectedPages(config.PageRanges, 0, len(hocrStruct.Pages))
+	}
+
+	var placements []WordPlacement
+	for i, page := range hocrStruct.Pages {
+		pageNum := i + 1
+		if pageNums != nil {
+			if i >= len(pageNums) {
+				break
+			}
+			pageNum = pageNums[i]
+		}
+		words, _ := splitByConfidence(layerWords(page), config.MinWordConfidence)
+
+		// Pick the font the page's OCR layer would be drawn with
+		unicode, err := selectLayerFont(pdf, words, config)
+		if err != nil {
+			return nil, err
+		}
+		state := &wordState{pageNum: pageNum, config: config, metrics: metrics, layerMetrics: metrics}
+		if unicode {
+			if unicodeMetrics == nil {
+				unicodeMetrics = newFontMetrics(pdf, config.Font, unicodeFontFamily, true)
+			}
+			state.metrics, state.layerMetrics = unicodeMetrics, unicodeMetrics
+		}
+
+		for _, word := range words {
+			if placement, ok := placeWord(word, identity, state); ok {
+				placements = append(placements, placement)
+			}
+		}
+	}
+
+	return placements, nil
+}
@@ -0,0 +1,631 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Tags of the TIFF fields read from an image file directory (IFD)
+const (
+	tiffNewSubfileType  = 254
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffFillOrder       = 266
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffXResolution     = 282
+	tiffYResolution     = 283
+	tiffPlanarConfig    = 284
+	tiffT4Options       = 292
+	tiffResolutionUnit  = 296
+	tiffPredictor       = 317
+	tiffColorMap        = 320
+	tiffTileWidth       = 322
+	tiffJPEGTables      = 347
+)
+
+// TIFF compression schemes
+const (
+	tiffNone       = 1
+	tiffCCITTRLE   = 2 // Modified Huffman, each row starting on a byte
+	tiffCCITTFax3  = 3
+	tiffCCITTFax4  = 4
+	tiffLZW        = 5
+	tiffJPEG       = 7
+	tiffDeflate    = 8
+	tiffPackBits   = 32773
+	tiffDeflateOld = 32946
+)
+
+// TIFF photometric interpretations, the color spaces of the samples
+const (
+	tiffWhiteIsZero = 0
+	tiffBlackIsZero = 1
+	tiffRGB         = 2
+	tiffPalette     = 3
+)
+
+// maxTIFFSamples bounds the samples of a TIFF page, its width times height
+// times samples per pixel, so that a malformed file can't exhaust memory. An
+// A3 page scanned in color at 1200 dpi has about 600 million.
+const maxTIFFSamples = 1 << 30
+
+// tiffTypeSizes holds the size in bytes of a value of each TIFF field type
+var tiffTypeSizes = [...]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// IsTIFF reports whether data starts with the header of a TIFF file
+func IsTIFF(data []byte) bool {
+	return len(data) >= 8 && (string(data[:4]) == "II*\x00" || string(data[:4]) == "MM\x00*")
+}
+
+// SplitTIFF splits a TIFF file, such as the multipage scans many scanners
+// produce, into one image per page to pass to AssembleWithOCR. Pages stored
+// as a single JPEG image are returned as is, others as PNG, black-and-white
+// ones with one bit per pixel, keeping the resolution stated by the TIFF.
+// Reduced-resolution copies of pages, such as thumbnails, are skipped.
+//
+// It fails with ErrInvalidImage for data that isn't a TIFF file or that
+// uses features that aren't supported: tiles, separate color planes, and
+// color spaces other than black and white, gray, RGB and palette colors.
+func SplitTIFF(data []byte) ([][]byte, error) {
+	if !IsTIFF(data) {
+		return nil, fmt.Errorf("%w: not a TIFF file", ErrInvalidImage)
+	}
+	r := &tiffReader{data: data, order: binary.LittleEndian}
+	if data[0] == 'M' {
+		r.order = binary.BigEndian
+	}
+
+	var pages [][]byte
+	seen := make(map[int]bool)
+	for offset := int(r.order.Uint32(data[4:8])); offset != 0; {
+		if seen[offset] {
+			return nil, fmt.Errorf("%w: the image file directories of the TIFF file form a loop", ErrInvalidImage)
+		}
+		seen[offset] = true
+
+		ifd, next, err := r.readIFD(offset)
+		if err != nil {
+			return nil, fmt.Errorf("%w: page %d: %w", ErrInvalidImage, len(pages)+1, err)
+		}
+		offset = next
+		if ifd.int(tiffNewSubfileType, 0)&1 != 0 {
+			continue
+		}
+		page, err := ifd.encodePage()
+		if err != nil {
+			return nil, fmt.Errorf("%w: page %d: %w", ErrInvalidImage, len(pages)+1, err)
+		}
+		pages = append(pages, page)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("TIFF file has %w", ErrNoPages)
+	}
+	return pages, nil
+}
+
+// tiffReader reads the structures of a TIFF file
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// slice returns n bytes of the file from an offset
+func (r *tiffReader) slice(offset, n int) ([]byte, error) {
+	if offset < 0 || n < 0 || offset > len(r.data) || n > len(r.data)-offset {
+		return nil, fmt.Errorf("%d bytes at offset %d are beyond the end of the file", n, offset)
+	}
+	return r.data[offset : offset+n], nil
+}
+
+// tiffField is a field of an image file directory
+type tiffField struct {
+	kind  int    // Field type
+	value []byte // Values, in the byte order of the file
+}
+
+// tiffIFD is an image file directory, describing one image of a TIFF file
+type tiffIFD struct {
+	r      *tiffReader
+	fields map[int]tiffField
+}
+
+// readIFD reads the image file directory at an offset, and returns it with
+// the offset of the next one, 0 for the last
+func (r *tiffReader) readIFD(offset int) (tiffIFD, int, error) {
+	header, err := r.slice(offset, 2)
+	if err != nil {
+		return tiffIFD{}, 0, err
+	}
+	count := int(r.order.Uint16(header))
+	entries, err := r.slice(offset+2, count*12+4)
+	if err != nil {
+		return tiffIFD{}, 0, err
+	}
+
+	ifd := tiffIFD{r: r, fields: make(map[int]tiffField)}
+	for i := range count {
+		entry := entries[i*12 : (i+1)*12]
+		tag, kind, n := int(r.order.Uint16(entry)), int(r.order.Uint16(entry[2:])), int(r.order.Uint32(entry[4:]))
+		if kind >= len(tiffTypeSizes) || tiffTypeSizes[kind] == 0 {
+			continue // Unknown field types may be skipped
+		}
+
+		// Values that don't fit into the entry are stored elsewhere
+		value, size := entry[8:12], tiffTypeSizes[kind]*n
+		if size > 4 {
+			if value, err = r.slice(int(r.order.Uint32(entry[8:])), size); err != nil {
+				return tiffIFD{}, 0, fmt.Errorf("field %d: %w", tag, err)
+			}
+		}
+		ifd.fields[tag] = tiffField{kind: kind, value: value[:size]}
+	}
+	return ifd, int(r.order.Uint32(entries[count*12:])), nil
+}
+
+// ints returns the values of an integer field
+func (d tiffIFD) ints(tag int) []int {
+	field, ok := d.fields[tag]
+	if !ok {
+		return nil
+	}
+	size := tiffTypeSizes[field.kind]
+	values := make([]int, 0, len(field.value)/size)
+	for pos := 0; pos < len(field.value); pos += size {
+		switch field.kind {
+		case 1, 6, 7: // BYTE, SBYTE, UNDEFINED
+			values = append(values, int(field.value[pos]))
+		case 3, 8: // SHORT, SSHORT
+			values = append(values, int(d.r.order.Uint16(field.value[pos:])))
+		case 4, 9: // LONG, SLONG
+			values = append(values, int(d.r.order.Uint32(field.value[pos:])))
+		default:
+			return nil
+		}
+	}
+	return values
+}
+
+// int returns the first value of an integer field, or fallback if it's missing
+func (d tiffIFD) int(tag, fallback int) int {
+	if values := d.ints(tag); len(values) > 0 {
+		return values[0]
+	}
+	return fallback
+}
+
+// resolution returns the resolution of an image in dots per inch, or 0 if
+// the file doesn't state it
+func (d tiffIFD) resolution(tag int) float64 {
+	field, ok := d.fields[tag]
+	if !ok || field.kind != 5 { // RATIONAL
+		return 0
+	}
+	numerator, denominator := d.r.order.Uint32(field.value), d.r.order.Uint32(field.value[4:])
+	if denominator == 0 {
+		return 0
+	}
+	resolution := float64(numerator) / float64(denominator)
+	switch d.int(tiffResolutionUnit, 2) {
+	case 2: // Inch
+		return resolution
+	case 3: // Centimeter
+		return resolution * 2.54
+	}
+	return 0
+}
+
+// encodePage decodes the image described by the directory and encodes it
+// as PNG, or returns the JPEG image a page is stored as
+func (d tiffIFD) encodePage() ([]byte, error) {
+	width, height := d.int(tiffImageWidth, 0), d.int(tiffImageLength, 0)
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	sampleBits, samples := d.int(tiffBitsPerSample, 1), d.int(tiffSamplesPerPixel, 1)
+	if samples < 1 || !slices.Contains([]int{1, 2, 4, 8, 16}, sampleBits) {
+		return nil, fmt.Errorf("unsupported %d samples of %d bits per pixel", samples, sampleBits)
+	}
+	if width > maxTIFFSamples/height/samples {
+		return nil, fmt.Errorf("image size %dx%d with %d samples per pixel is too large", width, height, samples)
+	}
+	if _, tiled := d.fields[tiffTileWidth]; tiled {
+		return nil, fmt.Errorf("tiled images are not supported")
+	}
+	xDPI, yDPI := d.resolution(tiffXResolution), d.resolution(tiffYResolution)
+
+	// Rows are stored in strips, their size missing if there's just one
+	offsets, counts := d.ints(tiffStripOffsets), d.ints(tiffStripByteCounts)
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("image has no data")
+	}
+	strips := make([][]byte, len(offsets))
+	for i, offset := range offsets {
+		n := len(d.r.data) - offset
+		if i < len(counts) {
+			n = counts[i]
+		}
+		var err error
+		if strips[i], err = d.r.slice(offset, n); err != nil {
+			return nil, fmt.Errorf("strip %d: %w", i+1, err)
+		}
+	}
+	rowsPerStrip := d.int(tiffRowsPerStrip, height)
+	if rowsPerStrip <= 0 || rowsPerStrip > height {
+		rowsPerStrip = height
+	}
+
+	var img image.Image
+	var err error
+	if d.int(tiffCompression, tiffNone) == tiffJPEG {
+		if len(strips) == 1 {
+			return withJPEGResolution(d.jpegStrip(strips[0]), xDPI, yDPI), nil
+		}
+		if img, err = d.decodeJPEGStrips(strips, width, height, rowsPerStrip); err != nil {
+			return nil, err
+		}
+		// Re-encoded with little loss rather than as a many times larger PNG
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		return withJPEGResolution(buf.Bytes(), xDPI, yDPI), nil
+	}
+
+	if img, err = d.decodeStrips(strips, width, height, rowsPerStrip); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return withPNGResolution(buf.Bytes(), xDPI, yDPI), nil
+}
+
+// jpegStrip returns a strip of a JPEG-compressed image as a complete JPEG
+// image. Tables shared by all strips are stored apart, as an abbreviated
+// JPEG stream, and go in front of the strip's own markers.
+func (d tiffIFD) jpegStrip(strip []byte) []byte {
+	tables := d.fields[tiffJPEGTables].value
+	if len(tables) < 4 || len(strip) < 2 {
+		return strip
+	}
+	return slices.Concat(tables[:len(tables)-2], strip[2:])
+}
+
+// decodeJPEGStrips decodes the strips of a JPEG-compressed image, each of
+// them a JPEG image of its own
+func (d tiffIFD) decodeJPEGStrips(strips [][]byte, width, height, rowsPerStrip int) (image.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, strip := range strips {
+		part, err := jpeg.Decode(bytes.NewReader(d.jpegStrip(strip)))
+		if err != nil {
+			return nil, fmt.Errorf("strip %d: %w", i+1, err)
+		}
+		draw.Draw(img, image.Rect(0, i*rowsPerStrip, width, height), part, part.Bounds().Min, draw.Src)
+	}
+	return img, nil
+}
+
+// decodeStrips decompresses the strips of an image and builds the image
+// from its samples
+func (d tiffIFD) decodeStrips(strips [][]byte, width, height, rowsPerStrip int) (image.Image, error) {
+	sampleBits, samples := d.int(tiffBitsPerSample, 1), d.int(tiffSamplesPerPixel, 1)
+	if samples > 1 && d.int(tiffPlanarConfig, 1) != 1 {
+		return nil, fmt.Errorf("images with separate color planes are not supported")
+	}
+	compression := d.int(tiffCompression, tiffNone)
+	fax := compression == tiffCCITTRLE || compression == tiffCCITTFax3 || compression == tiffCCITTFax4
+	if fax && (sampleBits != 1 || samples != 1) {
+		return nil, fmt.Errorf("CCITT compression of %d samples of %d bits", samples, sampleBits)
+	}
+
+	stride := (width*samples*sampleBits + 7) / 8
+	if compression == tiffNone {
+		// Uncompressed strips hold the image data as is
+		var size int
+		for _, strip := range strips {
+			size += len(strip)
+		}
+		if size < stride*height {
+			return nil, fmt.Errorf("image has %d bytes of image data, expected %d", size, stride*height)
+		}
+	}
+	raster := make([]byte, 0, stride*height)
+	for i, strip := range strips {
+		rows := min(rowsPerStrip, height-i*rowsPerStrip)
+		if rows <= 0 {
+			break
+		}
+		var data []byte
+		var err error
+		switch compression {
+		case tiffNone:
+			data = strip
+		case tiffPackBits:
+			data = unpackBits(strip)
+		case tiffLZW:
+			data, err = decodeTIFFLZW(strip)
+		case tiffDeflate, tiffDeflateOld:
+			data, err = inflate(strip)
+		case tiffCCITTRLE, tiffCCITTFax3, tiffCCITTFax4:
+			data, err = d.decodeFax(strip, width, rows)
+		default:
+			return nil, fmt.Errorf("unsupported compression %d", compression)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("strip %d: %w", i+1, err)
+		}
+		if len(data) < rows*stride {
+			return nil, fmt.Errorf("strip %d has %d bytes of image data, expected %d", i+1, len(data), rows*stride)
+		}
+		raster = append(raster, data[:rows*stride]...)
+	}
+	if len(raster) < stride*height {
+		return nil, fmt.Errorf("strips have %d rows of image data, expected %d", len(raster)/stride, height)
+	}
+
+	switch predictor := d.int(tiffPredictor, 1); {
+	case predictor == 2 && sampleBits == 8:
+		// Horizontal differencing
+		for y := range height {
+			row := raster[y*stride : (y+1)*stride]
+			for x := samples; x < len(row); x++ {
+				row[x] += row[x-samples]
+			}
+		}
+	case predictor != 1:
+		return nil, fmt.Errorf("unsupported predictor %d for %d-bit samples", predictor, sampleBits)
+	}
+
+	// 16-bit samples keep their most significant byte
+	if sampleBits == 16 {
+		high := 1
+		if d.r.order == binary.BigEndian {
+			high = 0
+		}
+		for i := range len(raster) / 2 {
+			raster[i] = raster[2*i+high]
+		}
+		sampleBits, stride = 8, width*samples
+	}
+	return d.rasterImage(raster, width, height, stride, sampleBits, samples)
+}
+
+// decodeFax decodes a CCITT-compressed strip to rows of one bit per pixel,
+// with 1 for black
+func (d tiffIFD) decodeFax(strip []byte, width, height int) ([]byte, error) {
+	if d.int(tiffFillOrder, 1) == 2 {
+		// Bits are stored starting with the least significant one
+		reversed := make([]byte, len(strip))
+		for i, b := range strip {
+			reversed[i] = bits.Reverse8(b)
+		}
+		strip = reversed
+	}
+
+	var rows [][]bool
+	var err error
+	switch d.int(tiffCompression, tiffNone) {
+	case tiffCCITTRLE:
+		rows, err = decodeCCITT(strip, width, height, 0, true)
+	case tiffCCITTFax3:
+		// The first option bit selects two-dimensional coding
+		rows, err = decodeCCITT(strip, width, height, d.int(tiffT4Options, 0)&1, false)
+	default:
+		rows, err = decodeCCITT(strip, width, height, -1, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stride := (width + 7) / 8
+	data := make([]byte, stride*height)
+	for y, row := range rows {
+		for x, black := range row {
+			if black {
+				data[y*stride+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return data, nil
+}
+
+// rasterImage builds the image of a page from its samples, rows padded to
+// whole bytes, by its photometric interpretation
+func (d tiffIFD) rasterImage(raster []byte, width, height, stride, sampleBits, samples int) (image.Image, error) {
+	photometric := d.int(tiffPhotometric, tiffBlackIsZero)
+	if sampleBits != 8 && (samples != 1 || 8%sampleBits != 0) {
+		return nil, fmt.Errorf("unsupported %d samples of %d bits per pixel", samples, sampleBits)
+	}
+	// sample returns the value of a sample of one pixel
+	sample := func(x, y, i int) int {
+		if sampleBits == 8 {
+			return int(raster[y*stride+x*samples+i])
+		}
+		bit := x * sampleBits
+		return int(raster[y*stride+bit/8]>>(8-sampleBits-bit%8)) & (1<<sampleBits - 1)
+	}
+
+	switch {
+	case photometric == tiffRGB && samples >= 3 && sampleBits == 8:
+		// Any extra samples, such as alpha, are left out
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := range height {
+			for x := range width {
+				pixel := img.Pix[y*img.Stride+4*x:]
+				copy(pixel, raster[y*stride+x*samples:y*stride+x*samples+3])
+				pixel[3] = 255
+			}
+		}
+		return img, nil
+
+	case photometric == tiffPalette || sampleBits == 1:
+		var palette color.Palette
+		switch {
+		case photometric == tiffPalette:
+			colorMap := d.ints(tiffColorMap)
+			n := 1 << sampleBits
+			if len(colorMap) < 3*n {
+				return nil, fmt.Errorf("color map has %d entries, expected %d", len(colorMap), 3*n)
+			}
+			for i := range n {
+				palette = append(palette, color.RGBA{
+					R: uint8(colorMap[i] >> 8), G: uint8(colorMap[n+i] >> 8), B: uint8(colorMap[2*n+i] >> 8), A: 255,
+				})
+			}
+		case photometric == tiffWhiteIsZero:
+			palette = color.Palette{color.White, color.Black}
+		case photometric == tiffBlackIsZero:
+			palette = color.Palette{color.Black, color.White}
+		default:
+			return nil, fmt.Errorf("unsupported photometric interpretation %d", photometric)
+		}
+		img := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		for y := range height {
+			for x := range width {
+				img.Pix[y*img.Stride+x] = uint8(sample(x, y, 0))
+			}
+		}
+		return img, nil
+
+	case photometric == tiffWhiteIsZero || photometric == tiffBlackIsZero:
+		// Any extra samples, such as alpha, are left out
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		maxValue := 1<<sampleBits - 1
+		for y := range height {
+			for x := range width {
+				value := sample(x, y, 0) * 255 / maxValue
+				if photometric == tiffWhiteIsZero {
+					value = 255 - value
+				}
+				img.Pix[y*img.Stride+x] = uint8(value)
+			}
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("unsupported photometric interpretation %d with %d samples of %d bits", photometric, samples, sampleBits)
+}
+
+// unpackBits decompresses PackBits data, a simple run-length encoding
+func unpackBits(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := int(int8(data[i]))
+		i++
+		switch {
+		case n >= 0:
+			// n+1 literal bytes
+			end := min(i+n+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end
+		case n > -128 && i < len(data):
+			// One byte repeated 1-n times
+			out = append(out, bytes.Repeat(data[i:i+1], 1-n)...)
+			i++
+		}
+	}
+	return out
+}
+
+// decodeTIFFLZW decompresses LZW data as TIFF stores it, which, unlike the
+// variant of compress/lzw, switches to longer codes one code early
+func decodeTIFFLZW(data []byte) ([]byte, error) {
+	const clearCode, endCode = 256, 257
+	table := make([][]byte, 4096)
+	for i := range 256 {
+		table[i] = []byte{byte(i)}
+	}
+
+	r := &bitReader{data: data}
+	var out, previous []byte
+	next, width := endCode+1, 9
+	for {
+		code := 0
+		for range width {
+			bit, ok := r.bit()
+			if !ok {
+				// Some encoders leave out the end code
+				return out, nil
+			}
+			code = code<<1 | bit
+		}
+
+		var entry []byte
+		switch {
+		case code == endCode:
+			return out, nil
+		case code == clearCode:
+			next, width, previous = endCode+1, 9, nil
+			continue
+		case code < next:
+			entry = table[code]
+		case code == next && previous != nil:
+			entry = append(slices.Clip(previous), previous[0])
+		default:
+			return nil, fmt.Errorf("invalid LZW code %d", code)
+		}
+		out = append(out, entry...)
+
+		if previous != nil && next < len(table) {
+			table[next] = append(slices.Clip(previous), entry[0])
+			next++
+		}
+		previous = entry
+		if next+1 >= 1<<width && width < 12 {
+			width++
+		}
+	}
+}
+
+// withPNGResolution adds the resolution of an image, if known, to its PNG
+// encoding as the physical pixel dimensions chunk (pHYs)
+func withPNGResolution(data []byte, xDPI, yDPI float64) []byte {
+	if xDPI <= 0 {
+		return data
+	}
+	if yDPI <= 0 {
+		yDPI = xDPI
+	}
+	chunk := make([]byte, 21)
+	binary.BigEndian.PutUint32(chunk, 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], uint32(math.Round(xDPI/0.0254)))
+	binary.BigEndian.PutUint32(chunk[12:], uint32(math.Round(yDPI/0.0254)))
+	chunk[16] = 1 // Pixels per meter
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	// The chunk goes right after the 8-byte signature and the header chunk
+	const headerEnd = 8 + 25
+	return slices.Concat(data[:headerEnd], chunk, data[headerEnd:])
+}
+
+// withJPEGResolution adds the resolution of an image, if known, to a JPEG
+// image without a JFIF header, as one
+func withJPEGResolution(data []byte, xDPI, yDPI float64) []byte {
+	if xDPI <= 0 || len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 || (data[2] == 0xFF && data[3] == 0xE0) {
+		return data
+	}
+	if yDPI <= 0 {
+		yDPI = xDPI
+	}
+	header := []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 1, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(header[12:], uint16(min(math.Round(xDPI), math.MaxUint16)))
+	binary.BigEndian.PutUint16(header[14:], uint16(min(math.Round(yDPI), math.MaxUint16)))
+	return slices.Concat(data[:2], header, data[2:])
+}
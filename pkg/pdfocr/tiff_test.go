@@ -0,0 +1,149 @@
+package pdfocr
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testTIFFField is a SHORT or LONG field of a test TIFF image
+type testTIFFField struct {
+	tag    uint16
+	values []uint32
+}
+
+// testTIFF returns a little-endian TIFF file with one image file directory of
+// LONG fields, followed by the strip data
+func testTIFF(fields []testTIFFField, data []byte) []byte {
+	out := []byte("II*\x00\x08\x00\x00\x00")
+	ifdSize := 2 + 12*len(fields) + 4
+	extra := 8 + ifdSize // Where values that don't fit into an entry go
+	var values []byte
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(fields)))
+	for _, field := range fields {
+		out = binary.LittleEndian.AppendUint16(out, field.tag)
+		out = binary.LittleEndian.AppendUint16(out, 4) // LONG
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(field.values)))
+		if len(field.values) == 1 {
+			out = binary.LittleEndian.AppendUint32(out, field.values[0])
+			continue
+		}
+		out = binary.LittleEndian.AppendUint32(out, uint32(extra+len(values)))
+		for _, value := range field.values {
+			values = binary.LittleEndian.AppendUint32(values, value)
+		}
+	}
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	out = append(out, values...)
+	return append(out, data...)
+}
+
+// testGrayTIFF returns an uncompressed 8-bit gray TIFF image of the given size
+// with the fields overridden, its strips pointing to the data after the header
+func testGrayTIFF(width, height uint32, overrides ...testTIFFField) []byte {
+	fields := []testTIFFField{
+		{tiffImageWidth, []uint32{width}},
+		{tiffImageLength, []uint32{height}},
+		{tiffBitsPerSample, []uint32{8}},
+		{tiffPhotometric, []uint32{tiffBlackIsZero}},
+		{tiffStripOffsets, []uint32{0}},
+		{tiffSamplesPerPixel, []uint32{1}},
+		{tiffRowsPerStrip, []uint32{height}},
+		{tiffStripByteCounts, []uint32{width * height}},
+	}
+	for _, override := range overrides {
+		for i := range fields {
+			if fields[i].tag == override.tag {
+				fields[i] = override
+			}
+		}
+	}
+	// The strip data follows the directory, which has no values stored apart
+	header := len(testTIFF(fields, nil))
+	for i := range fields {
+		if fields[i].tag == tiffStripOffsets {
+			for j := range fields[i].values {
+				fields[i].values[j] += uint32(header)
+			}
+		}
+	}
+	return testTIFF(fields, make([]byte, int(width)*int(height)))
+}
+
+func TestSplitTIFFMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			// Two strips of 2 rows declared for 8 rows
+			name: "fewer strips than rows",
+			data: testGrayTIFF(4, 8,
+				testTIFFField{tiffRowsPerStrip, []uint32{2}},
+				testTIFFField{tiffStripOffsets, []uint32{0, 8}},
+				testTIFFField{tiffStripByteCounts, []uint32{8, 8}}),
+		},
+		{
+			name: "truncated strip",
+			data: testGrayTIFF(4, 8, testTIFFField{tiffStripByteCounts, []uint32{12}}),
+		},
+		{
+			name: "zero bits per sample",
+			data: testGrayTIFF(4, 4, testTIFFField{tiffBitsPerSample, []uint32{0}}),
+		},
+		{
+			name: "odd bits per sample",
+			data: testGrayTIFF(4, 4, testTIFFField{tiffBitsPerSample, []uint32{3}}),
+		},
+		{
+			name: "zero samples per pixel",
+			data: testGrayTIFF(4, 4, testTIFFField{tiffSamplesPerPixel, []uint32{0}}),
+		},
+		{
+			name: "huge size",
+			data: testGrayTIFF(2, 2,
+				testTIFFField{tiffImageWidth, []uint32{0xFFFFFFFF}},
+				testTIFFField{tiffImageLength, []uint32{0xFFFFFFFF}}),
+		},
+		{
+			name: "huge width",
+			data: testGrayTIFF(2, 2, testTIFFField{tiffImageWidth, []uint32{1 << 31}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SplitTIFF(tt.data); !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("SplitTIFF() error = %v, want ErrInvalidImage", err)
+			}
+		})
+	}
+}
+
+func TestSplitTIFF(t *testing.T) {
+	pages, err := SplitTIFF(testGrayTIFF(4, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Errorf("got %d pages, want 1", len(pages))
+	}
+}
+
+func FuzzSplitTIFF(f *testing.F) {
+	f.Add(testGrayTIFF(4, 8))
+	f.Add(testGrayTIFF(4, 8,
+		testTIFFField{tiffRowsPerStrip, []uint32{2}},
+		testTIFFField{tiffStripOffsets, []uint32{0, 8}},
+		testTIFFField{tiffStripByteCounts, []uint32{8, 8}}))
+	f.Add(testGrayTIFF(16, 2, testTIFFField{tiffBitsPerSample, []uint32{1}}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pages, err := SplitTIFF(data)
+		if err != nil && !errors.Is(err, ErrInvalidImage) && !errors.Is(err, ErrNoPages) {
+			t.Errorf("SplitTIFF() error = %v, want ErrInvalidImage or ErrNoPages", err)
+		}
+		if err == nil && len(pages) == 0 {
+			t.Error("SplitTIFF() returned no pages without an error")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package pdfocr
+
+import (
+	"fmt"
+	"os"
+
+	"codeberg.org/go-pdf/fpdf"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/gardar/ocrchestra/pkg/hocr"
+)
+
+// unicodeFontFamily is the family name the Unicode fallback font is registered under
+const unicodeFontFamily = "OCRUnicode"
+
+// hasUnicodeFont reports whether a Unicode fallback font is configured
+func (f FontConfig) hasUnicodeFont() bool {
+	return f.UnicodeFontPath != "" || len(f.UnicodeFontData) > 0
+}
+
+// loadFonts reads the configured Unicode and script font files into memory,
+// so they're read once per document rather than once per page
+func (f FontConfig) loadFonts() (FontConfig, error) {
+	if len(f.UnicodeFontData) == 0 && f.UnicodeFontPath != "" {
+		data, err := os.ReadFile(f.UnicodeFontPath)
+		if err != nil {
+			return f, fmt.Errorf("failed to read Unicode font: %w", err)
+		}
+		f.UnicodeFontData = data
+	}
+	return f.loadScriptFonts()
+}
+
+// needsUnicodeFont reports whether any word that no script font covers
+// contains characters the single-byte WinAnsi (Windows-1252) encoding of
+// the core fonts can't represent
+func needsUnicodeFont(words []hocr.Word, font FontConfig) bool {
+	encoder := charmap.Windows1252.NewEncoder()
+	for _, word := range words {
+		if _, index := font.scriptFont(word.Text); index >= 0 {
+			continue
+		}
+		if _, err := encoder.String(word.Text); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// selectLayerFont selects the font for a page's OCR layer: the configured
+// Unicode font if the words need it, otherwise the regular single-byte font.
+// It returns true if the Unicode font was selected.
+func selectLayerFont(pdf *fpdf.Fpdf, words []hocr.Word, config OCRConfig) (bool, error) {
+	fontConfig := config.Font
+	if !fontConfig.hasUnicodeFont() || !needsUnicodeFont(words, fontConfig) {
+		pdf.SetFont(fontConfig.Name, fontConfig.Style, fontConfig.Size)
+		return false, pdf.Error()
+	}
+
+	// Registering is a no-op once the font has been added to the document
+	pdf.AddUTF8FontFromBytes(unicodeFontFamily, "", fontConfig.UnicodeFontData)
+	pdf.SetFont(unicodeFontFamily, "", fontConfig.Size)
+	if err := pdf.Error(); err != nil {
+		return false, fmt.Errorf("failed to load Unicode font: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,405 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// TextMismatch describes a word whose text extracted from the OCR layer differs from the hOCR
+type TextMismatch struct {
+	Page      int    // Page number (1-based) of the OCR layer
+	WordID    string // hOCR word ID (empty for extracted text without a matching word)
+	Expected  string // Word text in the hOCR
+	Extracted string // Text a viewer extracts for the word
+}
+
+// TextVerification reports how the text extracted from OCR layers compares to the hOCR words
+type TextVerification struct {
+	Words      int            // Number of words expected in the OCR layers
+	Matched    int            // Number of words extracted exactly as in the hOCR
+	Mismatches []TextMismatch // Words that were extracted differently, missing or unexpected
+}
+
+// OK reports whether every word round-tripped exactly
+func (v *TextVerification) OK() bool {
+	return len(v.Mismatches) == 0
+}
+
+// VerifyTextLayer extracts the text of the OCR layers of a PDF produced by
+// ApplyOCR or AssembleWithOCR the way a viewer does on copy/paste, decoding
+// each word through its font's encoding or ToUnicode CMap and honoring
+// ActualText spans, and compares it word by word with the hOCR input.
+// The config must match the one the PDF was produced with. Words left out
+// by the encoding fallback policy are not expected in the layer.
+func VerifyTextLayer(pdfData []byte, hocrInput interface{}, config OCRConfig) (*TextVerification, error) {
+	placements, err := ComputeTextMap(hocrInput, config)
+	if err != nil {
+		return nil, err
+	}
+
+	extracted, err := extractOCRLayerText(pdfData, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract OCR layer text: %w", err)
+	}
+
+	// Compare the words of each page in drawing order
+	expected := make(map[int][]WordPlacement)
+	var pages []int
+	for _, placement := range placements {
+		if _, ok := expected[placement.Page]; !ok {
+			pages = append(pages, placement.Page)
+		}
+		expected[placement.Page] = append(expected[placement.Page], placement)
+	}
+	for page := range extracted {
+		if _, ok := expected[page]; !ok {
+			pages = append(pages, page)
+		}
+	}
+
+	result := &TextVerification{Words: len(placements)}
+	for _, page := range pages {
+		words, texts := expected[page], extracted[page]
+		for i := 0; i < max(len(words), len(texts)); i++ {
+			var mismatch TextMismatch
+			mismatch.Page = page
+			if i < len(words) {
+				mismatch.WordID = words[i].WordID
+				mismatch.Expected = words[i].Text
+			}
+			if i < len(texts) {
+				mismatch.Extracted = texts[i]
+			}
+			if i < len(words) && i < len(texts) && mismatch.Expected == mismatch.Extracted {
+				result.Matched++
+				continue
+			}
+			result.Mismatches = append(result.Mismatches, mismatch)
+		}
+	}
+
+	return result, nil
+}
+
+// pdfPageLayerPattern extracts the page number from an OCR layer name
+var pdfPageLayerPattern = regexp.MustCompile(`\(Page (\d+)\)$`)
+
+// extractOCRLayerText returns the text of each word drawn in the OCR layers
+// of a PDF, keyed by the page number in the layer name. Layers named by a
+// template without the page number are keyed by the page they're on, as is
+// all text outside layers with NoLayers.
+func extractOCRLayerText(pdfData []byte, config OCRConfig) (map[int][]string, error) {
+	layerName := config.LayerName
+	templated := config.layerNamePattern()
+	file := parsePDFObjects(pdfData)
+	pages := file.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w found", ErrNoPages)
+	}
+
+	result := make(map[int][]string)
+	fonts := make(map[int]*pdfFontDecoder)
+
+	for i, page := range pages {
+		pageDict := file.dict(page)
+		resources := file.resources(page)
+
+		// Map the resource names used in the content stream to fonts and layers
+		fontRefs := file.refs(file.subdict(resources, "Font"))
+		layers := make(map[string]int)
+		for name, num := range file.refs(file.subdict(resources, "Properties")) {
+			ocgName, ok := pdfStringValue(file.dict(num), "Name")
+			if ok && templated != nil {
+				if match := templated.FindStringSubmatch(ocgName); match != nil {
+					layers[name] = i + 1
+					if match[1] != "" {
+						layers[name], _ = strconv.Atoi(match[1])
+					}
+				}
+				continue
+			}
+			if !ok || len(ocgName) < len(layerName) || ocgName[:len(layerName)] != layerName {
+				continue
+			}
+			if match := pdfPageLayerPattern.FindStringSubmatch(ocgName); match != nil {
+				layers[name], _ = strconv.Atoi(match[1])
+			}
+		}
+		pageText := 0
+		if config.NoLayers {
+			pageText = i + 1
+		} else if len(layers) == 0 {
+			continue
+		}
+
+		var content []byte
+		contents := pdfReferenceList(pageDict, "Contents")
+		for _, num := range contents {
+			data, err := file.stream(num)
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, data...)
+			content = append(content, '\n')
+		}
+
+		decoderOf := func(name string) (*pdfFontDecoder, error) {
+			num, ok := fontRefs[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown font /%s", name)
+			}
+			if decoder, ok := fonts[num]; ok {
+				return decoder, nil
+			}
+			decoder, err := newPDFFontDecoder(file, num)
+			if err != nil {
+				return nil, err
+			}
+			fonts[num] = decoder
+			return decoder, nil
+		}
+
+		if err := extractLayerWords(content, layers, pageText, decoderOf, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// extractLayerWords walks a content stream and appends the text of every word
+// shown inside one of the given OCR layers to the result. Text outside them
+// is added to pageText, unless that is 0.
+func extractLayerWords(
+	content []byte,
+	layers map[string]int,
+	pageText int,
+	decoderOf func(name string) (*pdfFontDecoder, error),
+	result map[int][]string,
+) error {
+	type markedContent struct {
+		layerPage  int    // Page of the OCR layer opened by this section (0 if none)
+		actualText string // Replacement text of a /Span section
+		hasActual  bool
+	}
+
+	var stack []markedContent
+	var operands []pdfToken
+	var font *pdfFontDecoder
+
+	currentLayer := func() int {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].layerPage > 0 {
+				return stack[i].layerPage
+			}
+		}
+		return pageText
+	}
+	insideActualText := func() bool {
+		for _, section := range stack {
+			if section.hasActual {
+				return true
+			}
+		}
+		return false
+	}
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch token.text {
+		case "BDC", "BMC":
+			section := markedContent{}
+			if len(operands) >= 2 && operands[0].kind == pdfName {
+				switch operands[0].text {
+				case "OC":
+					section.layerPage = layers[operands[1].text]
+				case "Span":
+					if text, ok := operands[1].dict["ActualText"]; ok {
+						section.actualText = decodePDFTextString(text.bytes)
+						section.hasActual = true
+					}
+				}
+			}
+			stack = append(stack, section)
+		case "EMC":
+			if len(stack) == 0 {
+				break
+			}
+			section := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if section.hasActual && !insideActualText() {
+				if page := currentLayer(); page > 0 {
+					result[page] = append(result[page], section.actualText)
+				}
+			}
+		case "Tf":
+			if len(operands) >= 2 && operands[0].kind == pdfName {
+				decoder, err := decoderOf(operands[0].text)
+				if err != nil {
+					return err
+				}
+				font = decoder
+			}
+		case "Tj", "'", "\"", "TJ":
+			page := currentLayer()
+			if page == 0 || insideActualText() || len(operands) == 0 {
+				break
+			}
+			if font == nil {
+				return fmt.Errorf("text shown without a font")
+			}
+			var text []byte
+			for _, operand := range operands {
+				switch operand.kind {
+				case pdfString:
+					text = append(text, operand.bytes...)
+				case pdfArray:
+					for _, item := range operand.items {
+						if item.kind == pdfString {
+							text = append(text, item.bytes...)
+						}
+					}
+				}
+			}
+			result[page] = append(result[page], font.decode(text))
+		case "ID":
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	return nil
+}
+
+// pdfFontDecoder maps the character codes of a font to Unicode text
+type pdfFontDecoder struct {
+	codeLength int               // Bytes per character code
+	toUnicode  map[uint32]string // ToUnicode CMap mappings (nil if the font has none)
+}
+
+// newPDFFontDecoder builds a decoder from a font's ToUnicode CMap or its WinAnsi encoding
+func newPDFFontDecoder(file *pdfFile, num int) (*pdfFontDecoder, error) {
+	dict := file.dict(num)
+	decoder := &pdfFontDecoder{codeLength: 1}
+	if bytes.Contains(dict, []byte("/Type0")) {
+		decoder.codeLength = 2
+	}
+
+	if ref := file.ref(dict, "ToUnicode"); ref > 0 {
+		cmap, err := file.stream(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ToUnicode CMap: %w", err)
+		}
+		decoder.toUnicode = parseToUnicodeCMap(cmap)
+	} else if decoder.codeLength == 2 {
+		return nil, fmt.Errorf("composite font %d has no ToUnicode CMap", num)
+	}
+
+	return decoder, nil
+}
+
+// decode converts a shown string to Unicode text
+func (d *pdfFontDecoder) decode(text []byte) string {
+	if d.toUnicode == nil {
+		decoded, _ := charmap.Windows1252.NewDecoder().Bytes(text)
+		return string(decoded)
+	}
+
+	var result []byte
+	for i := 0; i+d.codeLength <= len(text); i += d.codeLength {
+		var code uint32
+		for _, b := range text[i : i+d.codeLength] {
+			code = code<<8 | uint32(b)
+		}
+		if mapped, ok := d.toUnicode[code]; ok {
+			result = append(result, mapped...)
+		} else {
+			result = append(result, string(rune(0xFFFD))...)
+		}
+	}
+	return string(result)
+}
+
+// parseToUnicodeCMap reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicodeCMap(data []byte) map[uint32]string {
+	mappings := make(map[uint32]string)
+	lexer := &pdfLexer{data: data}
+
+	var operands []pdfToken
+	inChar, inRange := false, false
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch token.text {
+		case "beginbfchar":
+			inChar = true
+		case "beginbfrange":
+			inRange = true
+		case "endbfchar":
+			for i := 0; inChar && i+1 < len(operands); i += 2 {
+				mappings[cmapCode(operands[i].bytes)] = utf16BEString(operands[i+1].bytes)
+			}
+			inChar = false
+		case "endbfrange":
+			for i := 0; inRange && i+2 < len(operands); i += 3 {
+				lo, hi := cmapCode(operands[i].bytes), cmapCode(operands[i+1].bytes)
+				dst := operands[i+2]
+				for code := lo; code <= hi && code-lo < 0x10000; code++ {
+					if dst.kind == pdfArray {
+						if int(code-lo) < len(dst.items) {
+							mappings[code] = utf16BEString(dst.items[code-lo].bytes)
+						}
+						continue
+					}
+					mappings[code] = utf16BEString(incrementUTF16BE(dst.bytes, code-lo))
+				}
+			}
+			inRange = false
+		}
+		operands = operands[:0]
+	}
+
+	return mappings
+}
+
+// cmapCode converts a CMap source code to an integer
+func cmapCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+// incrementUTF16BE adds an offset to the last code unit of a bfrange destination
+func incrementUTF16BE(b []byte, offset uint32) []byte {
+	if len(b) < 2 {
+		return b
+	}
+	result := append([]byte(nil), b...)
+	last := uint32(result[len(result)-2])<<8 | uint32(result[len(result)-1])
+	last += offset
+	result[len(result)-2] = byte(last >> 8)
+	result[len(result)-1] = byte(last)
+	return result
+}
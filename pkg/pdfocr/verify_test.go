@@ -0,0 +1,100 @@
+package pdfocr
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"codeberg.org/go-pdf/fpdf"
+)
+
+// testBlankPDF returns a PDF with one empty A4 page
+func testBlankPDF(t *testing.T) []byte {
+	t.Helper()
+	pdf := fpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestTextLayerRoundTrip(t *testing.T) {
+	words := []string{"Café", "Straße", "€42", "naïve"}
+	hocrData := []byte(`<html><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 1240 1754'>
+<span class='ocr_line' id='line_1' title='bbox 100 100 1100 160; baseline 0 -10'>
+<span class='ocrx_word' id='word_1' title='bbox 100 100 300 160; x_wconf 96'>Café</span>
+<span class='ocrx_word' id='word_2' title='bbox 350 100 600 160; x_wconf 93'>Straße</span>
+<span class='ocrx_word' id='word_3' title='bbox 650 100 800 160; x_wconf 90'>€42</span>
+<span class='ocrx_word' id='word_4' title='bbox 850 100 1100 160; x_wconf 88'>naïve</span>
+</span>
+</div>
+</body></html>`)
+
+	for _, noLayers := range []bool{false, true} {
+		config := DefaultConfig()
+		config.NoLayers = noLayers
+
+		pdfData, err := ApplyOCR(testBlankPDF(t), hocrData, config)
+		if err != nil {
+			t.Fatalf("NoLayers %v: %v", noLayers, err)
+		}
+
+		verification, err := VerifyTextLayer(pdfData, hocrData, config)
+		if err != nil {
+			t.Fatalf("NoLayers %v: %v", noLayers, err)
+		}
+		if !verification.OK() || verification.Matched != len(words) {
+			t.Errorf("NoLayers %v: matched %d of %d words, mismatches %+v",
+				noLayers, verification.Matched, verification.Words, verification.Mismatches)
+		}
+
+		extracted, err := ExtractHOCR(pdfData)
+		if err != nil {
+			t.Fatalf("NoLayers %v: %v", noLayers, err)
+		}
+		var got []string
+		for _, page := range extracted.Pages {
+			got = append(got, wordTexts(layerWords(page))...)
+		}
+		if !slices.Equal(got, words) {
+			t.Errorf("NoLayers %v: extracted %q, want %q", noLayers, got, words)
+		}
+	}
+}
+
+func TestConfidenceLabelsNotInText(t *testing.T) {
+	hocrData := []byte(`<html><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 1240 1754'>
+<span class='ocr_line' id='line_1' title='bbox 100 100 1100 160'>
+<span class='ocrx_word' id='word_1' title='bbox 100 100 300 160; x_wconf 96'>Invoice</span>
+<span class='ocrx_word' id='word_2' title='bbox 350 100 600 160; x_wconf 0'>Total</span>
+</span>
+</div>
+</body></html>`)
+	config := DefaultConfig()
+	config.Debug = true
+	config.Heatmap = true
+	config.ShowConfidence = true
+
+	pdfData, err := ApplyOCR(testBlankPDF(t), hocrData, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verification, err := VerifyTextLayer(pdfData, hocrData, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verification.OK() || verification.Matched != 2 {
+		t.Errorf("matched %d of %d words, mismatches %+v", verification.Matched, verification.Words, verification.Mismatches)
+	}
+	extracted, err := ExtractHOCR(pdfData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wordTexts(layerWords(extracted.Pages[0])); !slices.Equal(got, []string{"Invoice", "Total"}) {
+		t.Errorf("extracted %q, want just the words", got)
+	}
+}
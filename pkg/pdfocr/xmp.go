@@ -0,0 +1,247 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// XMPProperty is a property written into the XMP metadata packet of the
+// output, in the namespace it belongs to
+type XMPProperty struct {
+	Namespace string // Namespace URI, e.g. "http://purl.org/dc/elements/1.1/"
+	Prefix    string // Prefix the namespace is declared with if the packet doesn't declare it yet, e.g. "dc"
+	Name      string // Local name of the property, e.g. "rights"
+	Value     string
+}
+
+// xmpNamespaces are the namespaces SetXMP knows the URI of by their prefix
+var xmpNamespaces = map[string]string{
+	"dc":        "http://purl.org/dc/elements/1.1/",
+	"pdf":       "http://ns.adobe.com/pdf/1.3/",
+	"xmp":       "http://ns.adobe.com/xap/1.0/",
+	"xmpRights": "http://ns.adobe.com/xap/1.0/rights/",
+	"xmpMM":     "http://ns.adobe.com/xap/1.0/mm/",
+	"photoshop": "http://ns.adobe.com/photoshop/1.0/",
+	"pdfaid":    "http://www.aiim.org/pdfa/ns/id/",
+}
+
+// xmpArrays are the properties of the well-known namespaces whose values are
+// RDF arrays rather than plain text, with the characters their items are
+// separated by in a value
+var xmpArrays = map[string]struct{ kind, separators string }{
+	xmpNamespaces["dc"] + "title":       {"Alt", ""},
+	xmpNamespaces["dc"] + "description": {"Alt", ""},
+	xmpNamespaces["dc"] + "rights":      {"Alt", ""},
+	xmpNamespaces["dc"] + "creator":     {"Seq", ";"},
+	xmpNamespaces["dc"] + "date":        {"Seq", ";"},
+	xmpNamespaces["dc"] + "contributor": {"Bag", ";"},
+	xmpNamespaces["dc"] + "language":    {"Bag", ";,"},
+	xmpNamespaces["dc"] + "publisher":   {"Bag", ";"},
+	xmpNamespaces["dc"] + "relation":    {"Bag", ";"},
+	xmpNamespaces["dc"] + "subject":     {"Bag", ";,"},
+	xmpNamespaces["dc"] + "type":        {"Bag", ";"},
+}
+
+// xmlNamePattern matches an XML name without a colon, as XMP prefixes and
+// property names must be
+var xmlNamePattern = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+
+// SetXMP sets a property of the XMP metadata of the output by its qualified
+// name, e.g. from a "property=value" command-line flag. The name is
+// "prefix:name" for the namespaces dc, pdf, xmp, xmpRights, xmpMM, photoshop
+// and pdfaid, or "{uri}prefix:name" for any other namespace. Setting a
+// property again replaces its value.
+func (m *Metadata) SetXMP(property, value string) error {
+	var namespace string
+	qualified := strings.TrimSpace(property)
+	if rest, ok := strings.CutPrefix(qualified, "{"); ok {
+		var found bool
+		namespace, qualified, found = strings.Cut(rest, "}")
+		if !found || namespace == "" || strings.ContainsAny(namespace, "\"<>&") {
+			return fmt.Errorf("invalid XMP property %q (expected {namespace-uri}prefix:name)", property)
+		}
+	}
+	prefix, name, ok := strings.Cut(qualified, ":")
+	if !ok || !xmlNamePattern.MatchString(prefix) || !xmlNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid XMP property %q (expected prefix:name or {namespace-uri}prefix:name)", property)
+	}
+	if namespace == "" {
+		if namespace = xmpNamespaces[prefix]; namespace == "" {
+			return fmt.Errorf("unknown XMP namespace prefix %q (give its URI as {namespace-uri}%s:%s)", prefix, prefix, name)
+		}
+	}
+
+	for i, existing := range m.Properties {
+		if existing.Namespace == namespace && existing.Name == name {
+			m.Properties[i].Value = value
+			return nil
+		}
+	}
+	m.Properties = append(m.Properties, XMPProperty{Namespace: namespace, Prefix: prefix, Name: name, Value: value})
+	return nil
+}
+
+// xmpProperties returns the properties the metadata sets in an XMP packet:
+// those mirroring the Info entries that are set, so viewers reading either
+// agree, followed by the custom Properties
+func (m Metadata) xmpProperties() []XMPProperty {
+	var properties []XMPProperty
+	for _, entry := range []struct {
+		prefix, name, value string
+	}{
+		{"dc", "title", m.Title},
+		{"dc", "creator", m.Author},
+		{"dc", "description", m.Subject},
+		{"dc", "subject", m.Keywords},
+		{"pdf", "Keywords", m.Keywords},
+		{"xmp", "CreatorTool", m.Creator},
+		{"pdf", "Producer", m.Producer},
+	} {
+		if entry.value != "" {
+			properties = append(properties, XMPProperty{
+				Namespace: xmpNamespaces[entry.prefix],
+				Prefix:    entry.prefix,
+				Name:      entry.name,
+				Value:     entry.value,
+			})
+		}
+	}
+	return append(properties, m.Properties...)
+}
+
+// stampXMP writes the properties the metadata sets into an XMP packet,
+// making a packet if there is none and there is something to write
+func (m Metadata) stampXMP(xmp []byte) []byte {
+	for _, property := range m.xmpProperties() {
+		xmp = setXMPProperty(xmp, property)
+	}
+	return xmp
+}
+
+// valueXML returns the value of a property as the content of its XMP element
+func (p XMPProperty) valueXML() []byte {
+	var b bytes.Buffer
+	array, ok := xmpArrays[p.Namespace+p.Name]
+	if !ok {
+		xml.EscapeText(&b, []byte(p.Value))
+		return b.Bytes()
+	}
+
+	items := []string{p.Value}
+	if array.separators != "" {
+		items = strings.FieldsFunc(p.Value, func(r rune) bool { return strings.ContainsRune(array.separators, r) })
+	}
+	fmt.Fprintf(&b, "<rdf:%s>", array.kind)
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		if array.kind == "Alt" {
+			b.WriteString(`<rdf:li xml:lang="x-default">`)
+		} else {
+			b.WriteString("<rdf:li>")
+		}
+		xml.EscapeText(&b, []byte(item))
+		b.WriteString("</rdf:li>")
+	}
+	fmt.Fprintf(&b, "</rdf:%s>", array.kind)
+	return b.Bytes()
+}
+
+// setXMPProperty sets a property in an XMP packet, replacing its value if the
+// packet has one. It is added to the rdf:Description declaring its namespace,
+// or to a new one.
+func setXMPProperty(xmp []byte, p XMPProperty) []byte {
+	location := xmpDescriptionPattern(p.Namespace).FindIndex(xmp)
+	if location == nil {
+		var description bytes.Buffer
+		fmt.Fprintf(&description, "<rdf:Description rdf:about=\"\" xmlns:%s=\"%s\">\n  <%s:%s>%s</%s:%s>\n</rdf:Description>",
+			p.Prefix, p.Namespace, p.Prefix, p.Name, p.valueXML(), p.Prefix, p.Name)
+		return setXMPDescription(xmp, p.Namespace, description.Bytes())
+	}
+
+	description := xmp[location[0]:location[1]]
+	prefix := xmpPrefix(description, p.Namespace)
+	qualified := regexp.QuoteMeta(prefix + ":" + p.Name)
+	// Drop the current value, which may be an element or an attribute
+	description = regexp.MustCompile(`(?s)\s*<`+qualified+`\b[^>]*?(?:/>|>.*?</`+qualified+`>)`).ReplaceAll(description, nil)
+	description = regexp.MustCompile(`\s`+qualified+`="[^"]*"`).ReplaceAll(description, nil)
+
+	element := fmt.Sprintf("\n  <%s:%s>%s</%s:%s>\n", prefix, p.Name, p.valueXML(), prefix, p.Name)
+	var updated []byte
+	if bytes.HasSuffix(description, []byte("/>")) {
+		updated = append(updated, bytes.TrimSuffix(description, []byte("/>"))...)
+		updated = append(updated, '>')
+		updated = append(updated, element...)
+		updated = append(updated, "</rdf:Description>"...)
+	} else {
+		end := bytes.LastIndex(description, []byte("</rdf:Description>"))
+		updated = append(updated, bytes.TrimRight(description[:end], " \t\r\n")...)
+		updated = append(updated, element...)
+		updated = append(updated, description[end:]...)
+	}
+
+	var out bytes.Buffer
+	out.Write(xmp[:location[0]])
+	out.Write(updated)
+	out.Write(xmp[location[1]:])
+	return out.Bytes()
+}
+
+// xmpPrefix returns the prefix an rdf:Description declares a namespace with.
+// Other tools rewriting a packet may have picked another prefix than ours.
+func xmpPrefix(description []byte, namespace string) string {
+	match := regexp.MustCompile(`xmlns:([\w.-]+)="` + regexp.QuoteMeta(namespace) + `"`).FindSubmatch(description)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// xmpPacketTemplate is an empty XMP packet, for PDFs without one
+const xmpPacketTemplate = "<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+	"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+	"<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n" +
+	"</rdf:RDF>\n" +
+	"</x:xmpmeta>\n" +
+	"<?xpacket end=\"w\"?>"
+
+// xmpDescriptionPattern matches the rdf:Description of an XMP packet that
+// declares a namespace
+func xmpDescriptionPattern(namespace string) *regexp.Regexp {
+	return regexp.MustCompile(`(?s)\s*<rdf:Description\b[^>]*xmlns:[\w.-]+="` + regexp.QuoteMeta(namespace) +
+		`"[^>]*?(?:/>|>.*?</rdf:Description>)`)
+}
+
+// xmpDescription returns the rdf:Description of an XMP packet that declares
+// a namespace, or nil if there is none
+func xmpDescription(xmp []byte, namespace string) []byte {
+	return xmpDescriptionPattern(namespace).Find(xmp)
+}
+
+// setXMPDescription replaces the rdf:Description of an XMP packet that
+// declares a namespace, or adds it. An empty description removes it. A new
+// packet is made if there is none to add to.
+func setXMPDescription(xmp []byte, namespace string, description []byte) []byte {
+	xmp = xmpDescriptionPattern(namespace).ReplaceAll(xmp, nil)
+	if len(description) == 0 {
+		return xmp
+	}
+	end := bytes.LastIndex(xmp, []byte("</rdf:RDF>"))
+	if end < 0 {
+		xmp = []byte(xmpPacketTemplate)
+		end = bytes.LastIndex(xmp, []byte("</rdf:RDF>"))
+	}
+	var out bytes.Buffer
+	out.Write(xmp[:end])
+	if end > 0 && xmp[end-1] != '\n' {
+		out.WriteByte('\n')
+	}
+	out.Write(description)
+	out.WriteByte('\n')
+	out.Write(xmp[end:])
+	return out.Bytes()
+}
@@ -0,0 +1,248 @@
+package preprocess
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+)
+
+// Deskew search range and resolution in degrees
+const (
+	maxSkew  = 5.0
+	skewStep = 0.1
+)
+
+// deskewSampleSize is the largest side of the downscaled image the skew is measured on
+const deskewSampleSize = 1000
+
+// toGray returns the image in grayscale with its origin at 0,0 and no row
+// padding, copying it unless it already is
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok && gray.Bounds().Min == (image.Point{}) && gray.Stride == gray.Bounds().Dx() {
+		return gray
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	return gray
+}
+
+// Rotate turns an image clockwise by a multiple of 90 degrees
+func Rotate(img image.Image, degrees int) image.Image {
+	quarters := ((degrees/90)%4 + 4) % 4
+	if quarters == 0 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	size := image.Rect(0, 0, h, w)
+	if quarters == 2 {
+		size = image.Rect(0, 0, w, h)
+	}
+	var rotated draw.Image = image.NewRGBA(size)
+	if _, ok := img.(*image.Gray); ok {
+		rotated = image.NewGray(size)
+	}
+
+	for y := range h {
+		for x := range w {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			switch quarters {
+			case 1:
+				rotated.Set(h-1-y, x, c)
+			case 2:
+				rotated.Set(w-1-x, h-1-y, c)
+			case 3:
+				rotated.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return rotated
+}
+
+// Deskew straightens a page scanned at a slight angle. The skew is the angle,
+// up to 5 degrees either way, at which the rows of dark pixels line up best
+// with the text lines. Pages without a measurable skew are returned as they are.
+func Deskew(img image.Image) image.Image {
+	gray := toGray(img)
+	angle := measureSkew(gray)
+	if math.Abs(angle) < skewStep {
+		return gray
+	}
+	return rotateGray(gray, -angle)
+}
+
+// measureSkew returns the skew of the text lines in degrees, clockwise
+func measureSkew(gray *image.Gray) float64 {
+	bounds := gray.Bounds()
+	step := max(1, max(bounds.Dx(), bounds.Dy())/deskewSampleSize)
+	threshold := otsuThreshold(gray)
+
+	// Collect the dark pixels of a downscaled image
+	var xs, ys []float64
+	for y := 0; y < bounds.Dy(); y += step {
+		for x := 0; x < bounds.Dx(); x += step {
+			if gray.Pix[y*gray.Stride+x] < threshold {
+				xs = append(xs, float64(x/step))
+				ys = append(ys, float64(y/step))
+			}
+		}
+	}
+	if len(xs) == 0 {
+		return 0
+	}
+
+	// Text lines give the sharpest row profile, measured by the sum of
+	// squared row counts, when the rows follow their angle
+	height := bounds.Dy()/step + 1
+	width := float64(bounds.Dx()/step + 1)
+	offset := int(math.Ceil(width * math.Tan(maxSkew*math.Pi/180)))
+	rows := make([]int, height+2*offset+1)
+	best, bestScore := 0.0, -1.0
+	for angle := -maxSkew; angle <= maxSkew+skewStep/2; angle += skewStep {
+		clear(rows)
+		slope := math.Tan(angle * math.Pi / 180)
+		for i := range xs {
+			row := int(math.Round(ys[i]-xs[i]*slope)) + offset
+			if row >= 0 && row < len(rows) {
+				rows[row]++
+			}
+		}
+		score := 0.0
+		for _, count := range rows {
+			score += float64(count) * float64(count)
+		}
+		if score > bestScore {
+			best, bestScore = angle, score
+		}
+	}
+	return best
+}
+
+// rotateGray rotates a grayscale image around its center by an angle in
+// degrees, clockwise, keeping its size and filling the corners with white
+func rotateGray(gray *image.Gray, degrees float64) *image.Gray {
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rotated := image.NewGray(image.Rect(0, 0, w, h))
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx, cy := float64(w)/2, float64(h)/2
+
+	for y := range h {
+		for x := range w {
+			// Sample the source pixel that lands here
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := int(math.Round(cx + dx*cos + dy*sin))
+			sy := int(math.Round(cy - dx*sin + dy*cos))
+			value := uint8(255)
+			if sx >= 0 && sx < w && sy >= 0 && sy < h {
+				value = gray.Pix[sy*gray.Stride+sx]
+			}
+			rotated.Pix[y*rotated.Stride+x] = value
+		}
+	}
+	return rotated
+}
+
+// Contrast stretches the gray levels of an image so that the darkest and
+// lightest percent of the pixels become black and white
+func Contrast(img image.Image) image.Image {
+	gray := toGray(img)
+	var histogram [256]int
+	for _, value := range gray.Pix {
+		histogram[value]++
+	}
+
+	clip := len(gray.Pix) / 100
+	low, high := 0, 255
+	for count := 0; low < 255 && count+histogram[low] <= clip; low++ {
+		count += histogram[low]
+	}
+	for count := 0; high > 0 && count+histogram[high] <= clip; high-- {
+		count += histogram[high]
+	}
+	if high <= low {
+		return gray
+	}
+
+	stretched := image.NewGray(gray.Bounds())
+	for i, value := range gray.Pix {
+		scaled := (float64(value) - float64(low)) * 255 / float64(high-low)
+		stretched.Pix[i] = uint8(math.Round(min(max(scaled, 0), 255)))
+	}
+	return stretched
+}
+
+// Binarize converts an image to black and white, splitting the gray levels
+// at the threshold found with Otsu's method
+func Binarize(img image.Image) image.Image {
+	gray := toGray(img)
+	threshold := otsuThreshold(gray)
+	binary := image.NewGray(gray.Bounds())
+	for i, value := range gray.Pix {
+		if value >= threshold {
+			binary.Pix[i] = 255
+		}
+	}
+	return binary
+}
+
+// otsuThreshold returns the gray level that best separates the dark and light
+// pixels of an image, maximizing the variance between the two classes
+func otsuThreshold(gray *image.Gray) uint8 {
+	var histogram [256]float64
+	for _, value := range gray.Pix {
+		histogram[value]++
+	}
+	total := float64(len(gray.Pix))
+	var sum float64
+	for level, count := range histogram {
+		sum += float64(level) * count
+	}
+
+	var darkCount, darkSum, bestVariance float64
+	threshold := 128
+	for level, count := range histogram {
+		darkCount += count
+		if darkCount == 0 {
+			continue
+		}
+		lightCount := total - darkCount
+		if lightCount == 0 {
+			break
+		}
+		darkSum += float64(level) * count
+		darkMean, lightMean := darkSum/darkCount, (sum-darkSum)/lightCount
+		if variance := darkCount * lightCount * (darkMean - lightMean) * (darkMean - lightMean); variance > bestVariance {
+			bestVariance, threshold = variance, level+1
+		}
+	}
+	return uint8(min(threshold, 255))
+}
+
+// Despeckle removes isolated dots with a 3x3 median filter
+func Despeckle(img image.Image) image.Image {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	filtered := image.NewGray(image.Rect(0, 0, w, h))
+
+	var window [9]uint8
+	for y := range h {
+		for x := range w {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					sx, sy := min(max(x+dx, 0), w-1), min(max(y+dy, 0), h-1)
+					window[n] = gray.Pix[sy*gray.Stride+sx]
+					n++
+				}
+			}
+			slices.Sort(window[:])
+			filtered.Pix[y*filtered.Stride+x] = window[4]
+		}
+	}
+	return filtered
+}
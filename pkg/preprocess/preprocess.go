@@ -0,0 +1,186 @@
+// Package preprocess cleans up scanned pages before they are sent to OCR.
+//
+// Poor scans (skewed, low contrast, noisy or upside down pages) lower the
+// recognition quality of every OCR engine. A Pipeline runs a sequence of
+// steps over each page image:
+//
+// - deskew: Straightens pages scanned at a slight angle (up to 5 degrees)
+// - rotate=N: Rotates pages clockwise by a multiple of 90 degrees
+// - contrast: Stretches the gray levels to the full range
+// - binarize: Converts to black and white with Otsu's threshold
+// - despeckle: Removes isolated dots with a 3x3 median filter
+//
+// Every step except rotate converts the page to grayscale.
+//
+// Main Functions:
+//
+// - ParsePipeline: Parses a comma-separated list of steps, e.g. "deskew,contrast,binarize"
+// - Pipeline.Apply: Runs the steps over an image
+// - Pipeline.ProcessImage: Runs the steps over an encoded image and returns it as PNG
+// - Pipeline.ProcessPDF: Runs the steps over every page of a scanned PDF and rebuilds it
+// - Deskew, Rotate, Contrast, Binarize, Despeckle: The individual steps
+package preprocess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+
+	_ "image/gif" // Register the GIF decoder for ProcessImage
+
+	"codeberg.org/go-pdf/fpdf"
+
+	"github.com/gardar/ocrchestra/pkg/pdfocr"
+)
+
+// Operation names a preprocessing step
+type Operation string
+
+// Supported operations
+const (
+	OpDeskew    Operation = "deskew"
+	OpRotate    Operation = "rotate"
+	OpContrast  Operation = "contrast"
+	OpBinarize  Operation = "binarize"
+	OpDespeckle Operation = "despeckle"
+)
+
+// Step is one operation of a pipeline
+type Step struct {
+	Operation Operation
+	Degrees   int // Clockwise rotation for OpRotate: 90, 180 or 270
+}
+
+// String formats the step the way ParsePipeline reads it
+func (s Step) String() string {
+	if s.Operation == OpRotate {
+		return fmt.Sprintf("%s=%d", s.Operation, s.Degrees)
+	}
+	return string(s.Operation)
+}
+
+// Pipeline is a sequence of steps applied in order
+type Pipeline []Step
+
+// String formats the pipeline the way ParsePipeline reads it
+func (p Pipeline) String() string {
+	steps := make([]string, len(p))
+	for i, step := range p {
+		steps[i] = step.String()
+	}
+	return strings.Join(steps, ",")
+}
+
+// ParsePipeline parses a comma-separated list of steps such as
+// "rotate=90,deskew,contrast,binarize". An empty spec is an empty pipeline.
+func ParsePipeline(spec string) (Pipeline, error) {
+	var pipeline Pipeline
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, arg, hasArg := strings.Cut(field, "=")
+		step := Step{Operation: Operation(strings.ToLower(strings.TrimSpace(name)))}
+		switch step.Operation {
+		case OpRotate:
+			degrees, err := strconv.Atoi(strings.TrimSpace(arg))
+			if !hasArg || err != nil || degrees%90 != 0 {
+				return nil, fmt.Errorf("invalid preprocessing step %q: rotate needs a multiple of 90 degrees, e.g. rotate=90", field)
+			}
+			step.Degrees = ((degrees % 360) + 360) % 360
+		case OpDeskew, OpContrast, OpBinarize, OpDespeckle:
+			if hasArg {
+				return nil, fmt.Errorf("invalid preprocessing step %q: %s takes no value", field, step.Operation)
+			}
+		default:
+			return nil, fmt.Errorf("unknown preprocessing step %q (valid: deskew, rotate=N, contrast, binarize, despeckle)", name)
+		}
+		pipeline = append(pipeline, step)
+	}
+	return pipeline, nil
+}
+
+// Apply runs the steps over an image and returns the result
+func (p Pipeline) Apply(img image.Image) image.Image {
+	for _, step := range p {
+		switch step.Operation {
+		case OpDeskew:
+			img = Deskew(img)
+		case OpRotate:
+			img = Rotate(img, step.Degrees)
+		case OpContrast:
+			img = Contrast(img)
+		case OpBinarize:
+			img = Binarize(img)
+		case OpDespeckle:
+			img = Despeckle(img)
+		}
+	}
+	return img
+}
+
+// ProcessImage decodes a PNG, JPEG or GIF image, runs the steps over it and
+// returns the result encoded as PNG
+func (p Pipeline) ProcessImage(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, p.Apply(img)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ProcessPDF runs the steps over the scan of every page of a PDF made of one
+// image per page and builds a new PDF of the processed pages, keeping the page
+// sizes. Pages turned sideways by a rotation get their width and height swapped.
+// It fails with pdfocr.ErrNotScanned for PDFs with text or vector content.
+// Grayscale pages are stored losslessly and color pages as JPEG.
+func (p Pipeline) ProcessPDF(pdfData []byte) ([]byte, error) {
+	pages, err := pdfocr.ExtractPageImages(pdfData)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("P", "pt", "A4", "")
+	for _, page := range pages {
+		img := p.Apply(page.Image)
+
+		// Keep the orientation of the page in line with the processed image
+		width, height := page.Width, page.Height
+		bounds := img.Bounds()
+		if (bounds.Dx() > bounds.Dy()) != (width > height) {
+			width, height = height, width
+		}
+
+		var buf bytes.Buffer
+		options := fpdf.ImageOptions{ImageType: "PNG"}
+		if _, gray := img.(*image.Gray); gray {
+			err = png.Encode(&buf, img)
+		} else {
+			options.ImageType = "JPEG"
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode page %d: %w", page.Page, err)
+		}
+
+		name := fmt.Sprintf("page%d", page.Page)
+		pdf.AddPageFormat("P", fpdf.SizeType{Wd: width, Ht: height})
+		pdf.RegisterImageOptionsReader(name, options, &buf)
+		pdf.ImageOptions(name, 0, 0, width, height, false, options, 0, "")
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, fmt.Errorf("failed to generate PDF: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,147 @@
+// Package s3store reads and writes documents in S3-compatible object storage.
+//
+// Objects are addressed with s3://bucket/key URIs. Credentials and the region
+// are resolved the standard AWS way: environment variables (AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_REGION, AWS_PROFILE, ...), the shared config and
+// credentials files, and the container or instance role.
+//
+// For S3-compatible services such as MinIO, set AWS_ENDPOINT_URL_S3 (or
+// AWS_ENDPOINT_URL) to the service URL; path-style addressing is then used,
+// as most of those services expect.
+//
+// Main Functions:
+//
+// - IsURI: Reports whether a path is an s3:// URI
+// - ParseURI: Splits an s3:// URI into bucket and key
+// - ReadObject: Downloads an object
+// - WriteObject: Uploads an object
+// - Exists: Reports whether an object exists
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// IsURI reports whether a path is an s3:// URI
+func IsURI(path string) bool {
+	return strings.HasPrefix(path, "s3://")
+}
+
+// ParseURI splits an s3://bucket/key URI into the bucket and key
+func ParseURI(uri string) (string, string, error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid S3 URI %q: must start with s3://", uri)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URI %q: missing bucket", uri)
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("invalid S3 URI %q: missing object key", uri)
+	}
+	return bucket, key, nil
+}
+
+// newClient creates an S3 client with the standard AWS configuration
+func newClient(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	// S3-compatible services are usually addressed by path rather than by virtual host
+	customEndpoint := os.Getenv("AWS_ENDPOINT_URL_S3") != "" || os.Getenv("AWS_ENDPOINT_URL") != ""
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = customEndpoint
+		// Not every S3-compatible service returns checksums; don't log each time one is missing
+		o.DisableLogOutputChecksumValidationSkipped = true
+	}), nil
+}
+
+// ReadObject downloads the content of an s3://bucket/key URI
+func ReadObject(ctx context.Context, uri string) ([]byte, error) {
+	bucket, key, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	return data, nil
+}
+
+// WriteObject uploads data to an s3://bucket/key URI, replacing any existing
+// object. The content type is optional.
+func WriteObject(ctx context.Context, uri string, data []byte, contentType string) error {
+	bucket, key, err := ParseURI(uri)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if _, err := client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to write %s: %w", uri, err)
+	}
+	return nil
+}
+
+// Exists reports whether an object exists at an s3://bucket/key URI
+func Exists(ctx context.Context, uri string) (bool, error) {
+	bucket, key, err := ParseURI(uri)
+	if err != nil {
+		return false, err
+	}
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	var notFound *types.NotFound
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &notFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check %s: %w", uri, err)
+	}
+}